@@ -9,3 +9,9 @@ import refs "github.com/ssbc/go-ssb-refs"
 type Authorizer interface {
 	Authorize(remote refs.FeedRef) error
 }
+
+// AuthorizerFunc allows to use a plain function as an Authorizer
+type AuthorizerFunc func(remote refs.FeedRef) error
+
+// Authorize calls the function
+func (fn AuthorizerFunc) Authorize(remote refs.FeedRef) error { return fn(remote) }
@@ -63,7 +63,7 @@ type WantManager interface {
 	Want(ref refs.BlobRef) error
 	Wants(ref refs.BlobRef) bool
 	WantWithDist(ref refs.BlobRef, dist int64) error
-	//Unwant(ref refs.BlobRef) error
+	Unwant(ref refs.BlobRef) error
 	CreateWants(context.Context, *muxrpc.ByteSink, muxrpc.Endpoint) luigi.Sink
 
 	AllWants() []BlobWant
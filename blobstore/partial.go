@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ssbc/go-luigi"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// ErrHashMismatch is returned by PutPartial if the completed download doesn't match the blob it should be.
+// The partial download is removed in that case.
+var ErrHashMismatch = errors.New("ssb: blob does not match its reference")
+
+// PartialStore is implemented by blob stores that can resume interrupted downloads
+type PartialStore interface {
+	// PartialSize returns how many bytes of the blob were already downloaded, or 0 if there is no partial download
+	PartialSize(ref refs.BlobRef) (int64, error)
+
+	// PutPartial writes the data of r at offset of the partial download of ref.
+	// If r ends with an error, the received data is kept so that the download can be resumed.
+	// Otherwise the download is complete, its hash is checked and the blob is stored.
+	PutPartial(ref refs.BlobRef, offset int64, r io.Reader) error
+
+	// DiscardPartial removes the partial download of ref, for instance if it grew bigger than the blob can be.
+	DiscardPartial(ref refs.BlobRef) error
+}
+
+var _ PartialStore = (*blobStore)(nil)
+
+// the partial downloads are kept next to the other temporary files, named after the hash they should have
+func (store *blobStore) getPartPath(ref refs.BlobRef) (string, error) {
+	if err := ref.IsValid(); err != nil {
+		return "", fmt.Errorf("blobs: invalid reference: %w", err)
+	}
+
+	var hash = make([]byte, 32)
+	err := ref.CopyHashTo(hash)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(store.basePath, "tmp", hex.EncodeToString(hash)+".part"), nil
+}
+
+func (store *blobStore) PartialSize(ref refs.BlobRef) (int64, error) {
+	partPath, err := store.getPartPath(ref)
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := os.Stat(partPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error getting partial blob info: %w", err)
+	}
+	return fi.Size(), nil
+}
+
+func (store *blobStore) DiscardPartial(ref refs.BlobRef) error {
+	partPath, err := store.getPartPath(ref)
+	if err != nil {
+		return err
+	}
+
+	unlock := store.lockPart(ref)
+	defer unlock()
+
+	err = os.Remove(partPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("blobstore.DiscardPartial: error removing partial file: %w", err)
+	}
+	return nil
+}
+
+func (store *blobStore) PutPartial(ref refs.BlobRef, offset int64, r io.Reader) error {
+	unlock := store.lockPart(ref)
+	defer unlock()
+
+	partPath, err := store.getPartPath(ref)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error opening partial file: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error getting partial file info: %w", err)
+	}
+	if offset > fi.Size() {
+		return fmt.Errorf("blobstore.PutPartial: offset %d is after the %d bytes that are stored", offset, fi.Size())
+	}
+
+	// drop what comes after the offset, it will be received again
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error truncating partial file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error seeking partial file: %w", err)
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil && !luigi.IsEOS(err) {
+		// keep what we have for the next try
+		if syncErr := f.Sync(); syncErr != nil {
+			return fmt.Errorf("blobstore.PutPartial: error saving partial file: %w", syncErr)
+		}
+		return fmt.Errorf("blobstore.PutPartial: download interrupted: %w", err)
+	}
+
+	// the download is complete, check that it is what we wanted
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error seeking partial file: %w", err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error hashing partial file: %w", err)
+	}
+
+	got, err := refs.NewBlobRefFromBytes(h.Sum(nil), refs.RefAlgoBlobSSB1)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error closing partial file: %w", err)
+	}
+
+	if !got.Equal(ref) {
+		if err := os.Remove(partPath); err != nil {
+			return fmt.Errorf("blobstore.PutPartial: error removing mismatched partial file: %w", err)
+		}
+		return ErrHashMismatch
+	}
+
+	hexDirPath, err := store.getHexDirPath(ref)
+	if err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error getting hex dir path: %w", err)
+	}
+	if err := os.MkdirAll(hexDirPath, 0700); err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error creating hex dir: %w", err)
+	}
+
+	finalPath, err := store.getPath(ref)
+	if err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error getting final path: %w", err)
+	}
+
+	if err := os.Rename(partPath, finalPath); err != nil {
+		return fmt.Errorf("error moving blob from partial path %q to final path %q: %w", partPath, finalPath, err)
+	}
+
+	err = store.bcst.EmitBlob(ssb.BlobStoreNotification{
+		Op:  ssb.BlobStoreOpPut,
+		Ref: ref,
+
+		Size: n,
+	})
+	if err != nil {
+		return fmt.Errorf("blobstore.PutPartial: error in notification handler: %w", err)
+	}
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/ssbc/go-luigi"
 	"github.com/ssbc/go-ssb/internal/broadcasts"
@@ -52,6 +53,45 @@ type blobStore struct {
 	basePath string
 
 	bcst *broadcasts.BlobStoreBroadcast
+
+	// serializes the writes to each partial download
+	partMu    sync.Mutex
+	partLocks map[string]*partLock
+}
+
+type partLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockPart locks the partial download of ref, other downloads are not blocked.
+// The returned function releases it.
+func (store *blobStore) lockPart(ref refs.BlobRef) func() {
+	key := ref.Sigil()
+
+	store.partMu.Lock()
+	if store.partLocks == nil {
+		store.partLocks = make(map[string]*partLock)
+	}
+	l, has := store.partLocks[key]
+	if !has {
+		l = &partLock{}
+		store.partLocks[key] = l
+	}
+	l.users++
+	store.partMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		store.partMu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(store.partLocks, key)
+		}
+		store.partMu.Unlock()
+	}
 }
 
 func (store *blobStore) Register(sink ssb.BlobStoreEmitter) ssb.CancelFunc {
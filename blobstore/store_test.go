@@ -6,11 +6,14 @@ package blobstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/stretchr/testify/assert"
@@ -187,3 +190,146 @@ func TestStore(t *testing.T) {
 		t.Run(fmt.Sprint(i), mkTest(tc))
 	}
 }
+
+type failingReader struct {
+	data string
+}
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	if fr.data == "" {
+		return 0, errors.New("connection lost")
+	}
+	n := copy(p, fr.data)
+	fr.data = fr.data[n:]
+	return n, nil
+}
+
+func TestPutPartial(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	name := strings.Replace(t.Name(), "/", "_", -1)
+	os.RemoveAll(name)
+	defer os.RemoveAll(name)
+
+	store, err := New(name)
+	r.NoError(err)
+	ps := store.(PartialStore)
+
+	ref, err := refs.ParseBlobRef("&ZR3jMW+ifnTWqd5hnrrGjjt4HpUn/dAMXvcUOx+lgbY=.sha256") // omg
+	r.NoError(err)
+
+	sz, err := ps.PartialSize(ref)
+	r.NoError(err)
+	a.EqualValues(0, sz)
+
+	// the connection breaks after the first byte
+	err = ps.PutPartial(ref, 0, &failingReader{data: "o"})
+	r.Error(err)
+
+	sz, err = ps.PartialSize(ref)
+	r.NoError(err)
+	a.EqualValues(1, sz)
+
+	_, err = store.Size(ref)
+	a.Equal(ErrNoSuchBlob, err, "not stored before it's complete")
+
+	// offsets after what we have are rejected
+	r.Error(ps.PutPartial(ref, 2, strings.NewReader("g")))
+
+	// the rest arrives
+	r.NoError(ps.PutPartial(ref, 1, strings.NewReader("mg")))
+
+	rd, err := store.Get(ref)
+	r.NoError(err)
+	data, err := ioutil.ReadAll(rd)
+	r.NoError(err)
+	rd.Close()
+	a.Equal("omg", string(data))
+
+	sz, err = ps.PartialSize(ref)
+	r.NoError(err)
+	a.EqualValues(0, sz, "partial file should be gone")
+
+	// complete downloads that don't match are dropped
+	other, err := refs.ParseBlobRef("&8Ap4f3SSqV4WW0cHAvT+k3NYP73AJbLIvfAmLMSPz/Q=.sha256") // wat
+	r.NoError(err)
+	r.Error(ps.PutPartial(other, 0, &failingReader{data: "wa"}))
+	err = ps.PutPartial(other, 2, strings.NewReader("s"))
+	a.ErrorIs(err, ErrHashMismatch)
+
+	sz, err = ps.PartialSize(other)
+	r.NoError(err)
+	a.EqualValues(0, sz)
+
+	_, err = store.Size(other)
+	a.Equal(ErrNoSuchBlob, err)
+}
+
+// blockingReader returns data once release is closed
+type blockingReader struct {
+	release chan struct{}
+	data    io.Reader
+}
+
+func (br *blockingReader) Read(p []byte) (int, error) {
+	<-br.release
+	return br.data.Read(p)
+}
+
+func TestPartialPerRef(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	name := strings.Replace(t.Name(), "/", "_", -1)
+	os.RemoveAll(name)
+	defer os.RemoveAll(name)
+
+	store, err := New(name)
+	r.NoError(err)
+	ps := store.(PartialStore)
+
+	slow, err := refs.ParseBlobRef("&ZR3jMW+ifnTWqd5hnrrGjjt4HpUn/dAMXvcUOx+lgbY=.sha256") // omg
+	r.NoError(err)
+	fast, err := refs.ParseBlobRef("&8Ap4f3SSqV4WW0cHAvT+k3NYP73AJbLIvfAmLMSPz/Q=.sha256") // wat
+	r.NoError(err)
+
+	// a stalled download doesn't hold up the others
+	release := make(chan struct{})
+	slowDone := make(chan error, 1)
+	go func() {
+		slowDone <- ps.PutPartial(slow, 0, &blockingReader{release: release, data: strings.NewReader("omg")})
+	}()
+
+	fastDone := make(chan error, 1)
+	go func() {
+		fastDone <- ps.PutPartial(fast, 0, strings.NewReader("wat"))
+	}()
+	select {
+	case err := <-fastDone:
+		r.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("download of the other blob is blocked")
+	}
+
+	close(release)
+	r.NoError(<-slowDone)
+
+	_, err = store.Size(slow)
+	a.NoError(err)
+
+	// discarding removes what was received
+	other, err := refs.ParseBlobRef("&MTXpGIJFNwX+1rnvvYGxvGXqsKAgwz0F8dl7vThQbss=.sha256")
+	r.NoError(err)
+	r.Error(ps.PutPartial(other, 0, &failingReader{data: "too much"}))
+	sz, err := ps.PartialSize(other)
+	r.NoError(err)
+	a.EqualValues(8, sz)
+
+	r.NoError(ps.DiscardPartial(other))
+	sz, err = ps.PartialSize(other)
+	r.NoError(err)
+	a.EqualValues(0, sz)
+
+	r.NoError(ps.DiscardPartial(other), "discarding twice is fine")
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"time"
 
@@ -105,6 +106,10 @@ func (wmgr *WantManager) handleHasBlob(has *hasBlob) {
 		return
 	}
 
+	if !wmgr.Wants(has.want.Ref) { // unwanted in the meantime
+		return
+	}
+
 	err := wmgr.getBlob(ctx, has.remote, has.want.Ref)
 	if err == nil {
 		return
@@ -161,7 +166,30 @@ type hasBlob struct {
 func (wmgr *WantManager) getBlob(ctx context.Context, edp muxrpc.Endpoint, ref refs.BlobRef) error {
 	log := log.With(wmgr.info, "event", "blobs.get", "ref", ref.ShortSigil())
 
-	arg := GetWithSize{ref, wmgr.maxSize}
+	partial, canResume := wmgr.bs.(PartialStore)
+
+	var offset int64
+	if canResume {
+		var err error
+		offset, err = partial.PartialSize(ref)
+		if err != nil {
+			level.Warn(log).Log("msg", "failed to get partial download", "err", err)
+			offset = 0
+		}
+		if offset >= int64(wmgr.maxSize) {
+			// it can't become a blob we want, start over
+			level.Warn(log).Log("msg", "discarding oversized partial download", "size", offset)
+			if err := partial.DiscardPartial(ref); err != nil {
+				level.Warn(log).Log("msg", "failed to discard partial download", "err", err)
+			}
+			offset = 0
+		}
+		if offset > 0 {
+			level.Debug(log).Log("msg", "resuming download", "offset", offset)
+		}
+	}
+
+	arg := GetWithSize{Key: ref, Max: wmgr.maxSize, Offset: offset}
 	src, err := edp.Source(ctx, 0, muxrpc.Method{"blobs", "get"}, arg)
 	if err != nil {
 		err = fmt.Errorf("blob create source failed: %w", err)
@@ -170,21 +198,34 @@ func (wmgr *WantManager) getBlob(ctx context.Context, edp muxrpc.Endpoint, ref r
 	}
 
 	r := muxrpc.NewSourceReader(src)
-	r = io.LimitReader(r, int64(wmgr.maxSize))
-	newBr, err := wmgr.bs.Put(r)
-	if err != nil {
-		err = fmt.Errorf("blob data piping failed: %w", err)
-		level.Warn(log).Log("err", err)
-		return err
-	}
+	r = io.LimitReader(r, int64(wmgr.maxSize)-offset)
 
-	if !newBr.Equal(ref) {
-		// TODO: make this a type of error?
-		wmgr.bs.Delete(newBr)
-		level.Warn(log).Log("msg", "removed after missmatch", "want", ref.ShortSigil())
-		return errors.New("blobs: inconsitency(or size limit)")
+	if canResume {
+		err = partial.PutPartial(ref, offset, r)
+		if err != nil {
+			err = fmt.Errorf("blob data piping failed: %w", err)
+			level.Warn(log).Log("err", err)
+			if errors.Is(err, ErrHashMismatch) {
+				return errors.New("blobs: inconsitency(or size limit)")
+			}
+			return err
+		}
+	} else {
+		newBr, err := wmgr.bs.Put(r)
+		if err != nil {
+			err = fmt.Errorf("blob data piping failed: %w", err)
+			level.Warn(log).Log("err", err)
+			return err
+		}
+
+		if !newBr.Equal(ref) {
+			// TODO: make this a type of error?
+			wmgr.bs.Delete(newBr)
+			level.Warn(log).Log("msg", "removed after missmatch", "want", ref.ShortSigil())
+			return errors.New("blobs: inconsitency(or size limit)")
+		}
 	}
-	sz, _ := wmgr.bs.Size(newBr)
+	sz, _ := wmgr.bs.Size(ref)
 	level.Info(log).Log("msg", "stored", "ref", ref.ShortSigil(), "sz", sz)
 	return nil
 }
@@ -217,6 +258,7 @@ func (wmgr *WantManager) Close() error {
 	return nil
 }
 
+// AllWants returns the current wants, the closest first
 func (wmgr *WantManager) AllWants() []ssb.BlobWant {
 	wmgr.l.Lock()
 	defer wmgr.l.Unlock()
@@ -231,6 +273,13 @@ func (wmgr *WantManager) AllWants() []ssb.BlobWant {
 			Dist: dist,
 		})
 	}
+
+	sort.Slice(bws, func(i, j int) bool {
+		if bws[i].Dist != bws[j].Dist {
+			return bws[i].Dist > bws[j].Dist
+		}
+		return bws[i].Ref.Sigil() < bws[j].Ref.Sigil()
+	})
 	return bws
 }
 
@@ -271,6 +320,20 @@ func (wmgr *WantManager) WantWithDist(ref refs.BlobRef, dist int64) error {
 	return nil
 }
 
+// Unwant removes ref from our wants. Peers that already forwarded the want might still offer the blob, which is ignored then.
+func (wmgr *WantManager) Unwant(ref refs.BlobRef) error {
+	wmgr.l.Lock()
+	defer wmgr.l.Unlock()
+
+	if _, wanted := wmgr.wants[ref.Sigil()]; !wanted {
+		return fmt.Errorf("blobs: %s is not wanted", ref.ShortSigil())
+	}
+
+	delete(wmgr.wants, ref.Sigil())
+	wmgr.promGaugeSet("nwants", len(wmgr.wants))
+	return nil
+}
+
 func (wmgr *WantManager) CreateWants(ctx context.Context, sink *muxrpc.ByteSink, edp muxrpc.Endpoint) luigi.Sink {
 	wmgr.l.Lock()
 	defer wmgr.l.Unlock()
@@ -401,9 +464,12 @@ func (proc *wantProc) EmitWant(w ssb.BlobWant) error {
 
 // GetWithSize is a muxrpc argument helper.
 // It can be used to request a blob named _key_ with a different maximum size than the default.
+// Offset skips the first bytes of the blob, to resume an interrupted download.
+// Peers that don't support it send the whole blob, which fails the hash check of the resumed download.
 type GetWithSize struct {
-	Key refs.BlobRef `json:"key"`
-	Max uint         `json:"max"`
+	Key    refs.BlobRef `json:"key"`
+	Max    uint         `json:"max"`
+	Offset int64        `json:"offset,omitempty"`
 }
 
 func (proc *wantProc) Close() error {
@@ -7,12 +7,16 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/ssbc/go-netwrap"
@@ -25,7 +29,15 @@ import (
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/blobstore"
 	"github.com/ssbc/go-ssb/message"
+	"github.com/ssbc/go-ssb/plugins/blobs"
+	"github.com/ssbc/go-ssb/plugins/get"
+	privplug "github.com/ssbc/go-ssb/plugins/private"
+	"github.com/ssbc/go-ssb/plugins/replicate"
+	"github.com/ssbc/go-ssb/plugins/server"
+	"github.com/ssbc/go-ssb/plugins/verify"
 	"github.com/ssbc/go-ssb/plugins/whoami"
+	"github.com/ssbc/go-ssb/plugins2/names"
+	"github.com/ssbc/go-ssb/query"
 )
 
 type Client struct {
@@ -35,12 +47,18 @@ type Client struct {
 	logger        log.Logger
 
 	closer io.Closer
+	// done is closed once the muxrpc serve loop of the connection exited
+	done chan struct{}
 
 	appKeyBytes []byte
+	dialer      netwrap.Dialer
+
+	reconnectMin, reconnectMax time.Duration
 }
 
 func newClientWithOptions(opts []Option) (*Client, error) {
 	var c Client
+	c.done = make(chan struct{})
 	for i, o := range opts {
 		err := o(&c)
 		if err != nil {
@@ -59,6 +77,10 @@ func newClientWithOptions(opts []Option) (*Client, error) {
 	}
 	c.rootCtx, c.rootCtxCancel = context.WithCancel(c.rootCtx)
 
+	if c.dialer == nil {
+		c.dialer = netwrap.Dial
+	}
+
 	if c.appKeyBytes == nil {
 		var err error
 		c.appKeyBytes, err = base64.StdEncoding.DecodeString("1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=")
@@ -107,7 +129,7 @@ func NewTCP(own ssb.KeyPair, remote net.Addr, opts ...Option) (*Client, error) {
 	}
 	copy(pubKey[:], shsAddr.PubKey)
 
-	conn, err := netwrap.Dial(netwrap.GetAddr(remote, "tcp"), shsClient.ConnWrapper(pubKey))
+	conn, err := c.dialer(netwrap.GetAddr(remote, "tcp"), shsClient.ConnWrapper(pubKey))
 	if err != nil {
 		return nil, fmt.Errorf("error dialing: %w", err)
 	}
@@ -133,6 +155,7 @@ func NewTCP(own ssb.KeyPair, remote net.Addr, opts ...Option) (*Client, error) {
 			level.Warn(c.logger).Log("event", "muxrpc.Serve exited", "err", err)
 		}
 		conn.Close()
+		close(c.done)
 	}()
 
 	return c, nil
@@ -171,6 +194,7 @@ func NewUnix(path string, opts ...Option) (*Client, error) {
 			level.Warn(c.logger).Log("event", "muxrpc.Serve exited", "err", err)
 		}
 		conn.Close()
+		close(c.done)
 	}()
 
 	return c, nil
@@ -192,6 +216,26 @@ func (c Client) Whoami() (refs.FeedRef, error) {
 	return resp.ID, nil
 }
 
+// WhoamiDetailed returns the feed of the server together with its key algorithm, hops and enabled plugins
+func (c Client) WhoamiDetailed() (whoami.Details, error) {
+	var resp whoami.Details
+	err := c.Async(c.rootCtx, &resp, muxrpc.TypeJSON, muxrpc.Method{"whoamiDetailed"})
+	if err != nil {
+		return whoami.Details{}, fmt.Errorf("ssbClient: whoamiDetailed failed: %w", err)
+	}
+	return resp, nil
+}
+
+// ServerInfo returns the version, commit and Go version the server was built with and the optional features it has enabled
+func (c Client) ServerInfo() (server.VersionInfo, error) {
+	var resp server.VersionInfo
+	err := c.Async(c.rootCtx, &resp, muxrpc.TypeJSON, muxrpc.Method{"server", "version"})
+	if err != nil {
+		return server.VersionInfo{}, fmt.Errorf("ssbClient: server.version failed: %w", err)
+	}
+	return resp, nil
+}
+
 func (c Client) ReplicateUpTo() (*muxrpc.ByteSource, error) {
 	src, err := c.Source(c.rootCtx, muxrpc.TypeJSON, muxrpc.Method{"replicate", "upto"})
 	if err != nil {
@@ -210,28 +254,271 @@ func (c Client) BlobsWant(ref refs.BlobRef) error {
 	return nil
 }
 
+// BlobsUnwant stops the server from fetching the blob
+func (c Client) BlobsUnwant(ref refs.BlobRef) error {
+	var v interface{}
+	err := c.Async(c.rootCtx, &v, muxrpc.TypeJSON, muxrpc.Method{"blobs", "unwant"}, ref.Sigil())
+	if err != nil {
+		return fmt.Errorf("ssbClient: blobs.unwant failed: %w", err)
+	}
+	return nil
+}
+
+// BlobsWants returns the blobs the server is trying to fetch, the closest wants first
+func (c Client) BlobsWants() ([]ssb.BlobWant, error) {
+	var resp []blobs.Want
+	err := c.Async(c.rootCtx, &resp, muxrpc.TypeJSON, muxrpc.Method{"blobs", "wants"})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: blobs.wants failed: %w", err)
+	}
+
+	wants := make([]ssb.BlobWant, len(resp))
+	for i, w := range resp {
+		wants[i] = ssb.BlobWant{Ref: w.Ref, Dist: w.Dist}
+	}
+	return wants, nil
+}
+
 func (c Client) BlobsHas(ref refs.BlobRef) (bool, error) {
-	var has bool
-	err := c.Async(c.rootCtx, &has, muxrpc.TypeJSON, muxrpc.Method{"blobs", "want"}, ref.Sigil())
+	has, err := c.BlobsHave([]refs.BlobRef{ref})
 	if err != nil {
-		return false, fmt.Errorf("ssbClient: whoami failed: %w", err)
+		return false, err
 	}
-	level.Debug(c.logger).Log("blob", "has", "has", has, "ref", ref.Sigil())
+	return has[ref.Sigil()], nil
+}
+
+// BlobsHave checks which of the blobs the server has with a single call.
+// The result is keyed by the sigil of the blob references.
+func (c Client) BlobsHave(blobRefs []refs.BlobRef) (map[string]bool, error) {
+	has := make(map[string]bool, len(blobRefs))
+	if len(blobRefs) == 0 {
+		return has, nil
+	}
+
+	var resp []bool
+	err := c.Async(c.rootCtx, &resp, muxrpc.TypeJSON, muxrpc.Method{"blobs", "has"}, blobRefs)
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: blobs.has failed: %w", err)
+	}
+	if len(resp) != len(blobRefs) {
+		return nil, fmt.Errorf("ssbClient: blobs.has returned %d results for %d blobs", len(resp), len(blobRefs))
+	}
+
+	for i, ref := range blobRefs {
+		has[ref.Sigil()] = resp[i]
+	}
+	level.Debug(c.logger).Log("blob", "has", "n", len(blobRefs))
 	return has, nil
+}
 
+// BlobSize returns the size of a blob in bytes. It returns an error if the server doesn't have the blob.
+func (c Client) BlobSize(ref refs.BlobRef) (int64, error) {
+	var sz int64
+	err := c.Async(c.rootCtx, &sz, muxrpc.TypeJSON, muxrpc.Method{"blobs", "size"}, ref.Sigil())
+	if err != nil {
+		return 0, fmt.Errorf("ssbClient: blobs.size failed: %w", err)
+	}
+	return sz, nil
+}
+
+// blobsAddTimeout is how long BlobsAdd waits for the server to store the blob after it was sent
+const blobsAddTimeout = 5 * time.Second
+
+// BlobsAdd streams the data of r to the blob store of the server and returns the reference of the new blob.
+// The sink call can't return the reference, so it is computed while sending and the call waits on blobs.changes until the server has the blob.
+func (c Client) BlobsAdd(r io.Reader) (refs.BlobRef, error) {
+	ctx, cancel := context.WithCancel(c.rootCtx)
+	defer cancel()
+
+	// listen before sending, so that the blob can't be stored before we look for it
+	changes, err := c.Source(ctx, muxrpc.TypeJSON, muxrpc.Method{"blobs", "changes"})
+	if err != nil {
+		return refs.BlobRef{}, fmt.Errorf("ssbClient: blobs.changes failed: %w", err)
+	}
+
+	snk, err := c.Sink(c.rootCtx, muxrpc.TypeBinary, muxrpc.Method{"blobs", "add"})
+	if err != nil {
+		return refs.BlobRef{}, fmt.Errorf("ssbClient: blobs.add failed: %w", err)
+	}
+
+	h := sha256.New()
+	w := muxrpc.NewSinkWriter(snk)
+	if _, err := io.Copy(io.MultiWriter(w, h), r); err != nil {
+		snk.CloseWithError(err)
+		return refs.BlobRef{}, fmt.Errorf("ssbClient: failed to send blob: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return refs.BlobRef{}, fmt.Errorf("ssbClient: failed to close blob stream: %w", err)
+	}
+
+	// sending can take as long as it needs, only the wait for the server is limited
+	waitCtx, waitCancel := context.WithTimeout(ctx, blobsAddTimeout)
+	defer waitCancel()
+
+	ref, err := refs.NewBlobRefFromBytes(h.Sum(nil), refs.RefAlgoBlobSSB1)
+	if err != nil {
+		return refs.BlobRef{}, err
+	}
+
+	// blobs that were already stored don't show up as changes
+	has, err := c.BlobsHas(ref)
+	if err != nil {
+		return refs.BlobRef{}, err
+	}
+
+	for !has && changes.Next(waitCtx) {
+		var added string
+		err = changes.Reader(func(r io.Reader) error {
+			return json.NewDecoder(r).Decode(&added)
+		})
+		if err != nil {
+			return refs.BlobRef{}, fmt.Errorf("ssbClient: failed to decode blobs.changes: %w", err)
+		}
+		has = added == ref.Sigil()
+	}
+	if !has {
+		err = waitCtx.Err()
+		if err == nil {
+			err = changes.Err()
+		}
+		if err != nil {
+			return refs.BlobRef{}, fmt.Errorf("ssbClient: server didn't store blob %s: %w", ref.Sigil(), err)
+		}
+		return refs.BlobRef{}, fmt.Errorf("ssbClient: server didn't store blob %s", ref.Sigil())
+	}
+
+	level.Debug(c.logger).Log("blob", "added", "ref", ref.Sigil())
+	return ref, nil
 }
 
 func (c Client) BlobsGet(ref refs.BlobRef) (io.Reader, error) {
-	args := blobstore.GetWithSize{Key: ref, Max: blobstore.DefaultMaxSize}
-	v, err := c.Source(c.rootCtx, 0, muxrpc.Method{"blobs", "get"}, args)
+	return c.BlobsGetFrom(ref, 0)
+}
+
+// BlobsGetFrom is like BlobsGet but skips the first offset bytes of the blob, to resume a download.
+// Older peers ignore the offset and send the whole blob, see BlobsDownload.
+func (c Client) BlobsGetFrom(ref refs.BlobRef, offset int64) (io.Reader, error) {
+	return c.blobsGetFrom(c.rootCtx, ref, offset)
+}
+
+func (c Client) blobsGetFrom(ctx context.Context, ref refs.BlobRef, offset int64) (io.Reader, error) {
+	args := blobstore.GetWithSize{Key: ref, Max: blobstore.DefaultMaxSize, Offset: offset}
+	v, err := c.Source(ctx, 0, muxrpc.Method{"blobs", "get"}, args)
 	if err != nil {
 		return nil, fmt.Errorf("ssbClient: blobs.get failed: %w", err)
 	}
-	level.Debug(c.logger).Log("blob", "got", "ref", ref.Sigil())
+	level.Debug(c.logger).Log("blob", "got", "ref", ref.Sigil(), "offset", offset)
 
 	return muxrpc.NewSourceReader(v), nil
 }
 
+// BlobsDownload fetches a blob into the file at dst. The data is written to dst.part first,
+// which is used to resume the download if it was interrupted before. dst is only created once the hash of the
+// data matches ref, otherwise the partial file is removed and blobstore.ErrHashMismatch is returned.
+// If the peer doesn't honor the offset of the resumed download, the partial file is emptied and the blob is fetched from the start.
+func (c Client) BlobsDownload(ref refs.BlobRef, dst string) error {
+	partPath := dst + ".part"
+
+	f, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("ssbClient: failed to open partial download: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	// hash what we already have and continue after it
+	offset, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("ssbClient: failed to read partial download: %w", err)
+	}
+
+	resumed := false
+	if offset > 0 {
+		resumed, err = c.resumeDownload(ref, io.MultiWriter(f, h), offset)
+		if err != nil {
+			return err
+		}
+		if !resumed {
+			level.Warn(c.logger).Log("blob", "resume failed, starting over", "ref", ref.Sigil(), "offset", offset)
+			if err := f.Truncate(0); err != nil {
+				return fmt.Errorf("ssbClient: failed to empty partial download: %w", err)
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("ssbClient: failed to empty partial download: %w", err)
+			}
+			h.Reset()
+		}
+	}
+
+	if !resumed {
+		r, err := c.BlobsGet(ref)
+		if err != nil {
+			return err
+		}
+
+		n, err := io.Copy(io.MultiWriter(f, h), r)
+		if err != nil {
+			return fmt.Errorf("ssbClient: blob download interrupted after %d bytes: %w", n, err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("ssbClient: failed to close partial download: %w", err)
+	}
+
+	got, err := refs.NewBlobRefFromBytes(h.Sum(nil), refs.RefAlgoBlobSSB1)
+	if err != nil {
+		return err
+	}
+	if !got.Equal(ref) {
+		os.Remove(partPath)
+		return blobstore.ErrHashMismatch
+	}
+
+	if err := os.Rename(partPath, dst); err != nil {
+		return fmt.Errorf("ssbClient: failed to move finished download: %w", err)
+	}
+	return nil
+}
+
+// resumeDownload writes the rest of the blob after offset to w. The reply doesn't say which part of the blob it has,
+// so it returns false if it isn't exactly the rest of the blob by its size: older peers ignore the offset and send all of it.
+// It also returns false if the blob is shorter than offset. What was written to w is of no use then.
+func (c Client) resumeDownload(ref refs.BlobRef, w io.Writer, offset int64) (bool, error) {
+	size, err := c.BlobSize(ref)
+	if err != nil {
+		return false, err
+	}
+	if size < offset {
+		return false, nil
+	}
+
+	// stop the stream if it's longer than it should be
+	ctx, cancel := context.WithCancel(c.rootCtx)
+	defer cancel()
+
+	r, err := c.blobsGetFrom(ctx, ref, offset)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := io.CopyN(w, r, size-offset)
+	if err != nil {
+		return false, fmt.Errorf("ssbClient: blob download interrupted after %d bytes: %w", offset+n, err)
+	}
+
+	// the stream needs to end here
+	var extra [1]byte
+	_, err = io.ReadFull(r, extra[:])
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("ssbClient: blob download failed after %d bytes: %w", size, err)
+	}
+	return false, nil
+}
+
 type NamesGetResult map[string]map[string]string
 
 func (ngr NamesGetResult) GetCommonName(feed refs.FeedRef) (string, bool) {
@@ -265,6 +552,20 @@ func (c Client) NamesGet() (NamesGetResult, error) {
 	return res, nil
 }
 
+// NamesProfileResult is the display info of a feed with its follow and block counts, see NamesProfile
+type NamesProfileResult = names.Profile
+
+// NamesProfile returns the resolved name, description and image of ref and how many feeds it follows and blocks and are followed and blocked by.
+// Only current follows and blocks are counted.
+func (c Client) NamesProfile(ref refs.FeedRef) (NamesProfileResult, error) {
+	var res NamesProfileResult
+	err := c.Async(c.rootCtx, &res, muxrpc.TypeJSON, muxrpc.Method{"names", "getProfile"}, ref.String())
+	if err != nil {
+		return NamesProfileResult{}, fmt.Errorf("ssbClient: names.getProfile failed: %w", err)
+	}
+	return res, nil
+}
+
 func (c Client) NamesSignifier(ref refs.FeedRef) (string, error) {
 	var name string
 	err := c.Async(c.rootCtx, &name, muxrpc.TypeString, muxrpc.Method{"names", "getSignifier"}, ref.String())
@@ -285,6 +586,57 @@ func (c Client) NamesImageFor(ref refs.FeedRef) (refs.BlobRef, error) {
 	return refs.ParseBlobRef(blobRef)
 }
 
+// GetRaw returns the value of the message ref with the exact bytes that were signed, so that it can be verified again.
+// If the sbot doesn't have the message, the error wraps ssb.ErrMessageNotFound.
+func (c Client) GetRaw(ref refs.MessageRef) (json.RawMessage, error) {
+	var raw string
+	err := c.Async(c.rootCtx, &raw, muxrpc.TypeString, muxrpc.Method{"get"}, get.Option{ID: ref, Raw: true})
+	if err != nil {
+		return nil, getError(ref, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// GetMessage returns the message ref, decoded from the key and value the sbot returns for it.
+// If the sbot doesn't have the message, the error wraps ssb.ErrMessageNotFound.
+func (c Client) GetMessage(ref refs.MessageRef) (refs.Message, error) {
+	var kv refs.KeyValueRaw
+	err := c.Async(c.rootCtx, &kv, muxrpc.TypeJSON, muxrpc.Method{"get"}, get.Option{ID: ref})
+	if err != nil {
+		return nil, getError(ref, err)
+	}
+	return kv, nil
+}
+
+// GetMany returns the messages of msgRefs in one call, in the same order.
+// Messages the sbot doesn't have are nil.
+func (c Client) GetMany(msgRefs []refs.MessageRef) ([]refs.Message, error) {
+	var kvs []*refs.KeyValueRaw
+	err := c.Async(c.rootCtx, &kvs, muxrpc.TypeJSON, muxrpc.Method{"getMany"}, get.ManyOption{IDs: msgRefs})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: getMany call failed: %w", err)
+	}
+	if len(kvs) != len(msgRefs) {
+		return nil, fmt.Errorf("ssbClient: getMany returned %d messages for %d references", len(kvs), len(msgRefs))
+	}
+
+	msgs := make([]refs.Message, len(kvs))
+	for i, kv := range kvs {
+		if kv != nil {
+			msgs[i] = *kv
+		}
+	}
+	return msgs, nil
+}
+
+// getError turns the not found error of the remote back into ssb.ErrMessageNotFound
+func getError(ref refs.MessageRef, err error) error {
+	if strings.Contains(err.Error(), ssb.ErrMessageNotFound.Error()) {
+		return fmt.Errorf("ssbClient: get %s: %w", ref.ShortSigil(), ssb.ErrMessageNotFound)
+	}
+	return fmt.Errorf("ssbClient: get %s call failed: %w", ref.ShortSigil(), err)
+}
+
 func (c Client) Publish(v interface{}) (refs.MessageRef, error) {
 	var resp string
 	err := c.Async(c.rootCtx, &resp, muxrpc.TypeString, muxrpc.Method{"publish"}, v)
@@ -319,6 +671,28 @@ func (c Client) PrivateRead() (*muxrpc.ByteSource, error) {
 	return src, nil
 }
 
+// PrivateReadPage returns up to limit decrypted private messages which were received before the passed message, newest first.
+// If before is the zero value, the page starts with the newest message.
+// The returned cursor is passed as before to get the next page and is the zero value once there are no older messages.
+func (c Client) PrivateReadPage(before refs.MessageRef, limit int) ([]refs.KeyValueRaw, refs.MessageRef, error) {
+	var args privplug.ReadPageArgs
+	args.Limit = limit
+	if !before.Equal(refs.MessageRef{}) {
+		args.Before = &before
+	}
+
+	var resp privplug.ReadPage
+	err := c.Async(c.rootCtx, &resp, muxrpc.TypeJSON, muxrpc.Method{"private", "readPage"}, args)
+	if err != nil {
+		return nil, refs.MessageRef{}, fmt.Errorf("ssbClient: private.readPage call failed: %w", err)
+	}
+
+	if resp.Next == nil {
+		return resp.Messages, refs.MessageRef{}, nil
+	}
+	return resp.Messages, *resp.Next, nil
+}
+
 func (c Client) CreateLogStream(o message.CreateLogArgs) (*muxrpc.ByteSource, error) {
 	src, err := c.Source(c.rootCtx, muxrpc.TypeJSON, muxrpc.Method{"createLogStream"}, o)
 	if err != nil {
@@ -359,6 +733,16 @@ func (c Client) TanglesThread(o message.TanglesArgs) (*muxrpc.ByteSource, error)
 	return src, nil
 }
 
+// Subset streams the messages matching the passed query, using partialReplication.getSubset.
+// Queries are build with the constructors of the query package, like query.NewSubsetAndCombination.
+func (c Client) Subset(op query.SubsetOperation, o query.SubsetOptions) (*muxrpc.ByteSource, error) {
+	src, err := c.Source(c.rootCtx, muxrpc.TypeJSON, muxrpc.Method{"partialReplication", "getSubset"}, op, o)
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: getSubset query failed: %w", err)
+	}
+	return src, nil
+}
+
 func (c Client) InviteCreate(o message.InviteCreateArgs) (string, error) {
 	var invite string
 	err := c.Async(c.rootCtx, &invite, muxrpc.TypeString, muxrpc.Method{"invite", "create"}, o)
@@ -368,6 +752,97 @@ func (c Client) InviteCreate(o message.InviteCreateArgs) (string, error) {
 	return invite, nil
 }
 
+// VerifyFeed asks the sbot to check the signatures and previous links of the stored messages of feed.
+// A broken feed is not an error, it is reported in the result.
+func (c Client) VerifyFeed(feed refs.FeedRef) (verify.Result, error) {
+	var res verify.Result
+	err := c.Async(c.rootCtx, &res, muxrpc.TypeJSON, muxrpc.Method{"verify", "feed"}, verify.FeedArgs{ID: feed})
+	if err != nil {
+		return verify.Result{}, fmt.Errorf("ssbClient: verify.feed failed: %w", err)
+	}
+	return res, nil
+}
+
+// ReplicationStatus returns the replication state of the feeds the server replicates, using replicate.status
+func (c Client) ReplicationStatus() ([]replicate.FeedStatus, error) {
+	var status []replicate.FeedStatus
+	err := c.Async(c.rootCtx, &status, muxrpc.TypeJSON, muxrpc.Method{"replicate", "status"})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: replicate.status failed: %w", err)
+	}
+	return status, nil
+}
+
+// IgnoreFeed stops the replication of feed on the server, even if it is within the hop range, using replicate.ignore
+func (c Client) IgnoreFeed(feed refs.FeedRef) error {
+	var ok bool
+	err := c.Async(c.rootCtx, &ok, muxrpc.TypeJSON, muxrpc.Method{"replicate", "ignore"}, feed.String())
+	if err != nil {
+		return fmt.Errorf("ssbClient: replicate.ignore failed: %w", err)
+	}
+	return nil
+}
+
+// UnignoreFeed reverts IgnoreFeed, using replicate.unignore
+func (c Client) UnignoreFeed(feed refs.FeedRef) error {
+	var ok bool
+	err := c.Async(c.rootCtx, &ok, muxrpc.TypeJSON, muxrpc.Method{"replicate", "unignore"}, feed.String())
+	if err != nil {
+		return fmt.Errorf("ssbClient: replicate.unignore failed: %w", err)
+	}
+	return nil
+}
+
+// IgnoredFeeds returns the feeds the server doesn't replicate because of IgnoreFeed, using replicate.ignored
+func (c Client) IgnoredFeeds() ([]refs.FeedRef, error) {
+	var feeds []refs.FeedRef
+	err := c.Async(c.rootCtx, &feeds, muxrpc.TypeJSON, muxrpc.Method{"replicate", "ignored"})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: replicate.ignored failed: %w", err)
+	}
+	return feeds, nil
+}
+
+// ReplicateFeed replicates feed on the server, even if it is not within the hop range, using replicate.add
+func (c Client) ReplicateFeed(feed refs.FeedRef) error {
+	var ok bool
+	err := c.Async(c.rootCtx, &ok, muxrpc.TypeJSON, muxrpc.Method{"replicate", "add"}, feed.String())
+	if err != nil {
+		return fmt.Errorf("ssbClient: replicate.add failed: %w", err)
+	}
+	return nil
+}
+
+// UnreplicateFeed reverts ReplicateFeed, using replicate.remove
+func (c Client) UnreplicateFeed(feed refs.FeedRef) error {
+	var ok bool
+	err := c.Async(c.rootCtx, &ok, muxrpc.TypeJSON, muxrpc.Method{"replicate", "remove"}, feed.String())
+	if err != nil {
+		return fmt.Errorf("ssbClient: replicate.remove failed: %w", err)
+	}
+	return nil
+}
+
+// ReplicatedFeeds returns the feeds that were added with ReplicateFeed, using replicate.added
+func (c Client) ReplicatedFeeds() ([]refs.FeedRef, error) {
+	var feeds []refs.FeedRef
+	err := c.Async(c.rootCtx, &feeds, muxrpc.TypeJSON, muxrpc.Method{"replicate", "added"})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: replicate.added failed: %w", err)
+	}
+	return feeds, nil
+}
+
+// DiskUsage returns the bytes used by the parts of the repo of the server, using repo.diskUsage
+func (c Client) DiskUsage() (ssb.DiskReport, error) {
+	var report ssb.DiskReport
+	err := c.Async(c.rootCtx, &report, muxrpc.TypeJSON, muxrpc.Method{"repo", "diskUsage"})
+	if err != nil {
+		return ssb.DiskReport{}, fmt.Errorf("ssbClient: repo.diskUsage failed: %w", err)
+	}
+	return report, nil
+}
+
 // TODO: TanglesHeads
 
 type noopHandler struct{ logger log.Logger }
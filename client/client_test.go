@@ -5,30 +5,42 @@
 package client_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
 	"github.com/ssbc/go-netwrap"
 	"github.com/ssbc/margaret"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mindeco.de/log"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/blobstore"
 	"github.com/ssbc/go-ssb/client"
 	"github.com/ssbc/go-ssb/internal/testutils"
 	"github.com/ssbc/go-ssb/message"
+	"github.com/ssbc/go-ssb/message/legacy"
 	"github.com/ssbc/go-ssb/network"
+	"github.com/ssbc/go-ssb/query"
+	"github.com/ssbc/go-ssb/repo"
 	"github.com/ssbc/go-ssb/sbot"
 )
 
@@ -110,6 +122,7 @@ func TestWhoami(t *testing.T) {
 	srv, err := sbot.New(
 		sbot.WithInfo(srvLog),
 		sbot.WithRepoPath(srvRepo),
+		sbot.WithHops(3),
 		sbot.WithListenAddr(":0"))
 	r.NoError(err, "sbot srv init failed")
 
@@ -135,6 +148,20 @@ func TestWhoami(t *testing.T) {
 	r.NotNil(ref)
 	a.Equal(kp.ID().String(), ref.String())
 
+	details, err := c.WhoamiDetailed()
+	r.NoError(err, "failed to call whoamiDetailed")
+	a.Equal(kp.ID().String(), details.ID.String())
+	a.Equal(refs.RefAlgoFeedSSB1, details.Algo)
+	a.EqualValues(3, details.Hops)
+	a.True(details.HasPlugin("ebt"))
+	a.True(details.HasPlugin("gossip"))
+	a.True(details.HasPlugin("blobs"))
+
+	srv.SetHops(1)
+	details, err = c.WhoamiDetailed()
+	r.NoError(err)
+	a.EqualValues(1, details.Hops)
+
 	a.NoError(c.Close())
 
 	srv.Shutdown()
@@ -142,6 +169,92 @@ func TestWhoami(t *testing.T) {
 	r.NoError(<-srvErrc)
 }
 
+func TestServerInfo(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.WithVersion("v1.2.3", "2021-01-01"),
+		sbot.WithPromisc(true),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	info, err := c.ServerInfo()
+	r.NoError(err)
+	a.Equal("v1.2.3", info.Version)
+	a.Equal("2021-01-01", info.Build)
+	a.Equal(runtime.Version(), info.GoVersion)
+	a.Contains(info.Features, "ebt")
+	a.Contains(info.Features, "promisc")
+	a.NotContains(info.Features, "metafeeds")
+
+	a.NoError(c.Close())
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
+
+func TestMultipleListeners(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr("127.0.0.1:0"),
+		sbot.WithListenAddr("127.0.0.1:0"))
+	r.NoError(err, "sbot srv init failed")
+
+	var srvErrc = make(chan error, 1)
+	go func() {
+		err := srv.Network.Serve(context.TODO())
+		if err != nil {
+			srvErrc <- fmt.Errorf("ali serve exited: %w", err)
+		}
+		close(srvErrc)
+	}()
+
+	kp, err := ssb.LoadKeyPair(filepath.Join(srvRepo, "secret"))
+	r.NoError(err, "failed to load servers keypair")
+
+	srvAddrs := srv.Network.GetListenAddrs()
+	r.Len(srvAddrs, 2)
+	r.NotEqual(srvAddrs[0].String(), srvAddrs[1].String())
+	r.Equal(srvAddrs[0].String(), srv.Network.GetListenAddr().String(), "the first one should be the main listener")
+
+	for i, srvAddr := range srvAddrs {
+		c, err := client.NewTCP(kp, srvAddr)
+		r.NoError(err, "failed to make client connection to listener %d", i)
+
+		ref, err := c.Whoami()
+		r.NoError(err, "failed to call whoami on listener %d", i)
+		a.Equal(kp.ID().String(), ref.String())
+
+		_, err = c.Publish(map[string]interface{}{"type": "test", "listener": i})
+		r.NoError(err, "failed to publish through listener %d", i)
+
+		a.EqualValues(i, srv.ReceiveLog.Seq(), "both listeners should publish to the same log")
+
+		a.NoError(c.Close())
+	}
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-srvErrc)
+}
+
 func TestLotsOfWhoami(t *testing.T) {
 	// defer leakcheck.Check(t)
 	r, a := require.New(t), assert.New(t)
@@ -432,6 +545,186 @@ func TestPublish(t *testing.T) {
 	r.NoError(<-srvErrc)
 }
 
+func TestGet(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	ref, err := c.Publish(testMsg{"test", "hello", 23})
+	r.NoError(err)
+
+	// the raw value can be verified again
+	raw, err := c.GetRaw(ref)
+	r.NoError(err)
+	verifiedRef, _, err := legacy.Verify(raw, nil)
+	r.NoError(err)
+	a.True(verifiedRef.Equal(ref), "raw value should hash to the same reference")
+
+	msg, err := c.GetMessage(ref)
+	r.NoError(err)
+	a.True(msg.Key().Equal(ref))
+	a.True(msg.Author().Equal(srv.KeyPair.ID()))
+	a.EqualValues(1, msg.Seq())
+
+	var content testMsg
+	r.NoError(json.Unmarshal(msg.ContentBytes(), &content))
+	a.Equal(testMsg{"test", "hello", 23}, content)
+
+	// unknown messages can be told apart from other errors
+	unknown, err := refs.NewMessageRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoMessageSSB1)
+	r.NoError(err)
+
+	_, err = c.GetRaw(unknown)
+	a.True(errors.Is(err, ssb.ErrMessageNotFound), "raw: %v", err)
+
+	_, err = c.GetMessage(unknown)
+	a.True(errors.Is(err, ssb.ErrMessageNotFound), "message: %v", err)
+
+	a.NoError(c.Close())
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
+
+func TestGetMany(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	var published []refs.MessageRef
+	for i := 0; i < 3; i++ {
+		ref, err := c.Publish(testMsg{"test", "hello", i})
+		r.NoError(err)
+		published = append(published, ref)
+	}
+
+	unknown, err := refs.NewMessageRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoMessageSSB1)
+	r.NoError(err)
+
+	// missing messages are nil, the others are in the requested order
+	msgs, err := c.GetMany([]refs.MessageRef{published[2], unknown, published[0], published[2]})
+	r.NoError(err)
+	r.Len(msgs, 4)
+	a.Nil(msgs[1])
+	for i, want := range []int{2, -1, 0, 2} {
+		if want < 0 {
+			continue
+		}
+		r.NotNil(msgs[i], "message %d", i)
+		a.True(msgs[i].Key().Equal(published[want]), "message %d", i)
+		a.EqualValues(want+1, msgs[i].Seq(), "message %d", i)
+
+		var content testMsg
+		r.NoError(json.Unmarshal(msgs[i].ContentBytes(), &content))
+		a.Equal(testMsg{"test", "hello", want}, content)
+	}
+
+	msgs, err = c.GetMany(nil)
+	r.NoError(err)
+	a.Len(msgs, 0)
+
+	a.NoError(c.Close())
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
+
+func TestNamesProfile(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	for _, nick := range []string{"bob", "claire", "debora"} {
+		_, err := repo.NewKeyPair(repo.New(srvRepo), nick, refs.RefAlgoFeedSSB1)
+		r.NoError(err)
+	}
+	debora, err := repo.LoadKeyPair(repo.New(srvRepo), "debora")
+	r.NoError(err)
+	bob, err := repo.LoadKeyPair(repo.New(srvRepo), "bob")
+	r.NoError(err)
+
+	me := srv.KeyPair.ID()
+	unfollow := refs.NewContactFollow(me)
+	unfollow.Following = false
+	for _, p := range []struct {
+		as      string
+		content interface{}
+	}{
+		{"", refs.NewAboutName(me, "me")},
+		{"", refs.NewContactFollow(bob.ID())},
+		{"", refs.NewContactBlock(debora.ID())},
+		{"bob", refs.NewContactFollow(me)},
+		{"claire", refs.NewContactFollow(me)},
+		{"claire", unfollow},
+		{"debora", refs.NewContactBlock(me)},
+	} {
+		if p.as == "" {
+			_, err = srv.PublishLog.Publish(p.content)
+		} else {
+			_, err = srv.PublishAs(p.as, p.content)
+		}
+		r.NoError(err)
+	}
+	srv.WaitUntilIndexesAreSynced()
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	profile, err := c.NamesProfile(me)
+	r.NoError(err)
+	a.True(profile.ID.Equal(me))
+	a.Equal("me", profile.Name)
+	a.Equal(1, profile.Followers, "claire unfollowed")
+	a.Equal(1, profile.Following)
+	a.Equal(1, profile.BlockedBy)
+	a.Equal(1, profile.Blocking)
+
+	profile, err = c.NamesProfile(debora.ID())
+	r.NoError(err)
+	a.Equal("", profile.Name)
+	a.Equal(0, profile.Followers)
+	a.Equal(1, profile.BlockedBy)
+	a.Equal(1, profile.Blocking)
+
+	a.NoError(c.Close())
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
+
 func TestTanglesThread(t *testing.T) {
 	// defer leakcheck.Check(t)
 	r, a := require.New(t), assert.New(t)
@@ -605,6 +898,294 @@ func TestFriendsBlocks(t *testing.T) {
 	r.NotNil(src)
 }
 
+func TestBlobs(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"))
+	r.NoError(err, "sbot srv init failed")
+
+	var srvErrc = make(chan error, 1)
+	go func() {
+		err := srv.Network.Serve(context.TODO())
+		if err != nil {
+			srvErrc <- fmt.Errorf("ali serve exited: %w", err)
+		}
+		close(srvErrc)
+	}()
+
+	kp, err := ssb.LoadKeyPair(filepath.Join(srvRepo, "secret"))
+	r.NoError(err, "failed to load servers keypair")
+	srvAddr := srv.Network.GetListenAddr()
+
+	c, err := client.NewTCP(kp, srvAddr)
+	r.NoError(err, "failed to make client connection")
+	// end test boilerplate
+
+	stored, err := srv.BlobStore.Put(strings.NewReader("foobar"))
+	r.NoError(err)
+
+	missing, err := refs.NewBlobRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoBlobSSB1)
+	r.NoError(err)
+
+	has, err := c.BlobsHave([]refs.BlobRef{stored, missing})
+	r.NoError(err)
+	a.Equal(map[string]bool{stored.Sigil(): true, missing.Sigil(): false}, has)
+
+	one, err := c.BlobsHas(stored)
+	r.NoError(err)
+	a.True(one)
+
+	sz, err := c.BlobSize(stored)
+	r.NoError(err)
+	a.EqualValues(6, sz)
+
+	_, err = c.BlobSize(missing)
+	a.Error(err)
+
+	// the want list
+	r.NoError(c.BlobsWant(missing))
+	wants, err := c.BlobsWants()
+	r.NoError(err)
+	r.Len(wants, 1)
+	a.True(wants[0].Ref.Equal(missing))
+	a.EqualValues(-1, wants[0].Dist)
+
+	r.NoError(c.BlobsUnwant(missing))
+	wants, err = c.BlobsWants()
+	r.NoError(err)
+	a.Len(wants, 0)
+
+	// resume from a partial download
+	dst := filepath.Join("testrun", t.Name(), "foobar")
+	r.NoError(os.WriteFile(dst+".part", []byte("foo"), 0600))
+	r.NoError(c.BlobsDownload(stored, dst))
+	got, err := os.ReadFile(dst)
+	r.NoError(err)
+	a.Equal("foobar", string(got))
+	a.NoFileExists(dst + ".part")
+
+	// a broken partial download is thrown away
+	broken := filepath.Join("testrun", t.Name(), "broken")
+	r.NoError(os.WriteFile(broken+".part", []byte("xyz"), 0600))
+	a.ErrorIs(c.BlobsDownload(stored, broken), blobstore.ErrHashMismatch)
+	a.NoFileExists(broken)
+	a.NoFileExists(broken + ".part")
+
+	a.NoError(c.Close())
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-srvErrc)
+}
+
+// oldBlobPeer serves blobs.get like peers from before offsets were supported: it always sends the whole blob
+type oldBlobPeer struct {
+	data []byte
+
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (p *oldBlobPeer) Handler() muxrpc.Handler {
+	tm := typemux.New(log.NewNopLogger())
+	tm.RegisterAsync(muxrpc.Method{"manifest"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return map[string]interface{}{"blobs": map[string]string{"get": "source", "size": "async"}}, nil
+	}))
+	tm.RegisterAsync(muxrpc.Method{"blobs", "size"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return len(p.data), nil
+	}))
+	tm.RegisterSource(muxrpc.Method{"blobs", "get"}, typemux.SourceFunc(p.get))
+	return &tm
+}
+
+func (p *oldBlobPeer) get(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+	var args []blobstore.GetWithSize
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil || len(args) != 1 {
+		return fmt.Errorf("bad request - invalid json: %v", err)
+	}
+	p.mu.Lock()
+	p.offsets = append(p.offsets, args[0].Offset)
+	p.mu.Unlock()
+
+	snk.SetEncoding(muxrpc.TypeBinary)
+	w := muxrpc.NewSinkWriter(snk)
+	if _, err := w.Write(p.data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func TestBlobsDownloadIgnoredOffset(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	testPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(testPath)
+	r.NoError(os.MkdirAll(testPath, 0700))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sockPath := filepath.Join(testPath, "socket")
+	lis, err := net.Listen("unix", sockPath)
+	r.NoError(err)
+	defer lis.Close()
+
+	peer := &oldBlobPeer{data: []byte("foobar")}
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		edp := muxrpc.Handle(muxrpc.NewPacker(conn), peer.Handler(), muxrpc.WithContext(ctx))
+		edp.(muxrpc.Server).Serve()
+		conn.Close()
+	}()
+
+	c, err := client.NewUnix(sockPath)
+	r.NoError(err)
+
+	sum := sha256.Sum256(peer.data)
+	ref, err := refs.NewBlobRefFromBytes(sum[:], refs.RefAlgoBlobSSB1)
+	r.NoError(err)
+
+	// the peer sends all of it instead of the rest after foo
+	dst := filepath.Join(testPath, "foobar")
+	r.NoError(os.WriteFile(dst+".part", []byte("foo"), 0600))
+	r.NoError(c.BlobsDownload(ref, dst))
+	got, err := os.ReadFile(dst)
+	r.NoError(err)
+	a.Equal("foobar", string(got))
+	a.NoFileExists(dst + ".part")
+
+	peer.mu.Lock()
+	a.Equal([]int64{3, 0}, peer.offsets)
+	peer.mu.Unlock()
+
+	a.NoError(c.Close())
+}
+
+func TestSubset(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	bob, err := repo.NewKeyPair(repo.New(srvRepo), "bob", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	me := srv.KeyPair.ID()
+	for _, p := range []struct {
+		as, typ string
+	}{
+		{"", "post"},
+		{"", "about"},
+		{"", "vote"},
+		{"bob", "post"},
+		{"bob", "about"},
+		{"bob", "contact"},
+	} {
+		content := map[string]interface{}{"type": p.typ}
+		if p.as == "" {
+			_, err = srv.PublishLog.Publish(content)
+		} else {
+			_, err = srv.PublishAs(p.as, content)
+		}
+		r.NoError(err)
+	}
+	srv.WaitUntilIndexesAreSynced()
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+	// end test boilerplate
+
+	type result struct {
+		author refs.FeedRef
+		typ    string
+	}
+
+	collect := func(op query.SubsetOperation, opts query.SubsetOptions) []result {
+		src, err := c.Subset(op, opts)
+		r.NoError(err)
+
+		var results []result
+		ctx := context.Background()
+		for src.Next(ctx) {
+			var kv struct {
+				Key   refs.MessageRef `json:"key"`
+				Value struct {
+					Author  refs.FeedRef `json:"author"`
+					Content struct {
+						Type string `json:"type"`
+					} `json:"content"`
+				} `json:"value"`
+			}
+			err := src.Reader(decodeMuxMsg(&kv))
+			r.NoError(err)
+			results = append(results, result{kv.Value.Author, kv.Value.Content.Type})
+		}
+		r.NoError(src.Err())
+		return results
+	}
+
+	opts := query.SubsetOptions{Keys: true, PageLimit: -1}
+
+	posts := collect(query.NewSubsetOpByType("post"), opts)
+	a.Len(posts, 2)
+
+	bobsPosts := collect(query.NewSubsetAndCombination(
+		query.NewSubsetOpByType("post"),
+		query.NewSubsetOpByAuthor(bob.ID()),
+	), opts)
+	r.Len(bobsPosts, 1)
+	a.True(bobsPosts[0].author.Equal(bob.ID()))
+
+	// (my abouts or votes) or bobs contacts
+	nested := collect(query.NewSubsetOrCombination(
+		query.NewSubsetAndCombination(
+			query.NewSubsetOpByAuthor(me),
+			query.NewSubsetOrCombination(
+				query.NewSubsetOpByType("about"),
+				query.NewSubsetOpByType("vote"),
+			),
+		),
+		query.NewSubsetAndCombination(
+			query.NewSubsetOpByAuthor(bob.ID()),
+			query.NewSubsetOpByType("contact"),
+		),
+	), opts)
+	r.Len(nested, 3)
+	a.Equal("about", nested[0].typ)
+	a.Equal("vote", nested[1].typ)
+	a.Equal("contact", nested[2].typ)
+	a.True(nested[2].author.Equal(bob.ID()))
+
+	opts.Descending = true
+	opts.PageLimit = 1
+	latest := collect(query.NewSubsetOpByAuthor(bob.ID()), opts)
+	r.Len(latest, 1)
+	a.Equal("contact", latest[0].typ)
+
+	c.Terminate()
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
+
 func decodeMuxMsg(msg interface{}) func(r io.Reader) error {
 	return func(r io.Reader) error {
 		return json.NewDecoder(r).Decode(msg)
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// The content types the As* helpers decode into
+type (
+	Post    = refs.Post
+	Contact = refs.Contact
+	About   = refs.About
+	Vote    = refs.Vote
+)
+
+// ErrWrongType is returned by the As* helpers if the content of a message isn't of the expected type.
+// Has is "private" for encrypted messages that weren't unboxed.
+type ErrWrongType struct {
+	Want, Has string
+}
+
+func (e ErrWrongType) Error() string {
+	return fmt.Sprintf("ssbClient: expected a message of type %q, got %q", e.Want, e.Has)
+}
+
+// AsPost decodes the content of a type:post message
+func AsPost(m refs.Message) (*Post, error) {
+	var p Post
+	if err := decodeContent(m, "post", &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AsContact decodes the content of a type:contact message, like a follow or a block
+func AsContact(m refs.Message) (*Contact, error) {
+	var c Contact
+	if err := decodeContent(m, "contact", &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AsAbout decodes the content of a type:about message
+func AsAbout(m refs.Message) (*About, error) {
+	var a About
+	if err := decodeContent(m, "about", &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// AsVote decodes the content of a type:vote message
+func AsVote(m refs.Message) (*Vote, error) {
+	var v Vote
+	if err := decodeContent(m, "vote", &v); err != nil {
+		return nil, err
+	}
+	if v.Vote.Link == nil {
+		return nil, errors.New("ssbClient: vote without a link")
+	}
+	return &v, nil
+}
+
+// decodeContent checks that the content of m is of type want and unmarshals it into v
+func decodeContent(m refs.Message, want string, v interface{}) error {
+	content := m.ContentBytes()
+	if len(content) > 0 && content[0] == '"' {
+		return ErrWrongType{Want: want, Has: "private"}
+	}
+
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(content, &typed); err != nil {
+		return fmt.Errorf("ssbClient: invalid content of %s: %w", m.Key().ShortSigil(), err)
+	}
+	if typed.Type != want {
+		return ErrWrongType{Want: want, Has: typed.Type}
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("ssbClient: invalid %s message %s: %w", want, m.Key().ShortSigil(), err)
+	}
+	return nil
+}
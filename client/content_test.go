@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/client"
+)
+
+func TestContentHelpers(t *testing.T) {
+	r := require.New(t)
+
+	root, err := refs.NewMessageRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoMessageSSB1)
+	r.NoError(err)
+	feed, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	msg := func(content string) refs.Message {
+		return refs.KeyValueRaw{
+			Key_:  root,
+			Value: refs.Value{Author: feed, Content: []byte(content)},
+		}
+	}
+
+	post, err := client.AsPost(msg(`{"type":"post","text":"hello","root":"` + root.String() + `","branch":["` + root.String() + `"],"mentions":[{"link":"` + feed.String() + `","name":"bob"}]}`))
+	r.NoError(err)
+	r.Equal("hello", post.Text)
+	r.NotNil(post.Root)
+	r.True(post.Root.Equal(root))
+	r.Len(post.Branch, 1)
+	r.Len(post.Mentions, 1)
+	r.Equal("bob", post.Mentions[0].Name)
+
+	contact, err := client.AsContact(msg(`{"type":"contact","contact":"` + feed.String() + `","following":true}`))
+	r.NoError(err)
+	r.True(contact.Contact.Equal(feed))
+	r.True(contact.Following)
+	r.False(contact.Blocking)
+
+	about, err := client.AsAbout(msg(`{"type":"about","about":"` + feed.String() + `","name":"bob"}`))
+	r.NoError(err)
+	r.Equal("bob", about.Name)
+
+	vote, err := client.AsVote(msg(`{"type":"vote","vote":{"link":"` + root.String() + `","value":1,"expression":"like"}}`))
+	r.NoError(err)
+	r.Equal(1, vote.Vote.Value)
+	r.Equal("like", vote.Vote.Expression)
+
+	_, err = client.AsVote(msg(`{"type":"vote","vote":{"value":1}}`))
+	r.Error(err, "votes need a link")
+
+	// the wrong type
+	_, err = client.AsPost(msg(`{"type":"contact","contact":"` + feed.String() + `"}`))
+	var wrongType client.ErrWrongType
+	r.True(errors.As(err, &wrongType), "got %v", err)
+	r.Equal("post", wrongType.Want)
+	r.Equal("contact", wrongType.Has)
+
+	_, err = client.AsContact(msg(`"c29tZXRoaW5nIHNlY3JldA==.box"`))
+	r.True(errors.As(err, &wrongType), "got %v", err)
+	r.Equal("private", wrongType.Has)
+
+	_, err = client.AsAbout(msg(`{"text":"no type"}`))
+	r.True(errors.As(err, &wrongType), "got %v", err)
+	r.Equal("", wrongType.Has)
+
+	// the right type but invalid fields
+	_, err = client.AsContact(msg(`{"type":"contact","contact":"not a feed"}`))
+	r.Error(err)
+	r.False(errors.As(err, &wrongType))
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-muxrpc/v2"
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/message"
+	feedplug "github.com/ssbc/go-ssb/plugins/feed"
+	"github.com/ssbc/go-ssb/query"
+)
+
+// LogStreamOpts selects the messages of LogStream.
+// Gt and Lt are sequences of the receive log of the server, zero means no bound.
+type LogStreamOpts struct {
+	// Live keeps the stream open and delivers new messages as they are received
+	Live bool
+
+	Gt, Lt int64
+
+	// Limit is the maximum number of messages, zero means no limit
+	Limit int
+
+	// Reverse returns the newest messages first
+	Reverse bool
+
+	// Seq is the receive log sequence to start from, zero means the start of the log
+	Seq int64
+
+	// Private asks the server to return private messages it can decrypt in their cleartext form
+	Private bool
+}
+
+// LogStream streams the messages of the server in the order they were received.
+// The returned source yields refs.KeyValueRaw values. With opts.Live it only ends once the client is closed.
+func (c Client) LogStream(opts LogStreamOpts) (luigi.Source, error) {
+	var args message.CreateLogArgs
+	args.Keys = true
+	args.Live = opts.Live
+	args.Limit = -1
+	if opts.Limit > 0 {
+		args.Limit = int64(opts.Limit)
+	}
+	args.Gt = message.RoundedInteger(opts.Gt)
+	args.Lt = message.RoundedInteger(opts.Lt)
+	args.Reverse = opts.Reverse
+	args.Seq = opts.Seq
+	args.Private = opts.Private
+
+	src, err := c.CreateLogStream(args)
+	if err != nil {
+		return nil, err
+	}
+	return &logStreamSource{src: src}, nil
+}
+
+// SubscribeTypes streams the new messages of any of the passed types as they are received by the server.
+// The filtering happens on the server and the subscription starts once it handled the request. The returned source yields refs.KeyValueRaw values and only ends once the client is closed.
+func (c Client) SubscribeTypes(types []string) (luigi.Source, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("ssbClient: no types to subscribe to")
+	}
+
+	ops := make([]query.SubsetOperation, len(types))
+	for i, t := range types {
+		ops[i] = query.NewSubsetOpByType(t)
+	}
+
+	src, err := c.Subset(query.NewSubsetOrCombination(ops...), query.SubsetOptions{Keys: true, LiveOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: failed to subscribe to types: %w", err)
+	}
+	return &logStreamSource{src: src}, nil
+}
+
+// FeedTail streams the messages of feed from sequence seq on and then the new ones, as the server receives them.
+// With seq zero only the messages that are received from now on are streamed.
+// The returned source yields refs.KeyValueRaw values and only ends once the client is closed.
+func (c Client) FeedTail(feed refs.FeedRef, seq int64) (luigi.Source, error) {
+	args := feedplug.TailArgs{ID: feed.String(), Seq: seq, Keys: true}
+	src, err := c.Source(c.rootCtx, muxrpc.TypeJSON, muxrpc.Method{"feed", "tail"}, args)
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: failed to tail feed: %w", err)
+	}
+	return &logStreamSource{src: src}, nil
+}
+
+type logStreamSource struct {
+	src *muxrpc.ByteSource
+}
+
+func (ls *logStreamSource) Next(ctx context.Context) (interface{}, error) {
+	if !ls.src.Next(ctx) {
+		if err := ls.src.Err(); err != nil {
+			return nil, fmt.Errorf("ssbClient: log stream failed: %w", err)
+		}
+		return nil, luigi.EOS{}
+	}
+
+	var msg refs.KeyValueRaw
+	err := ls.src.Reader(func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&msg)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ssbClient: failed to decode log stream message: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,305 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/client"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/query"
+	"github.com/ssbc/go-ssb/sbot"
+)
+
+func TestLogStream(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	const n = 6
+	var published []refs.MessageRef
+	for i := 0; i < n; i++ {
+		msg, err := srv.PublishLog.Publish(map[string]interface{}{"type": "test", "i": i})
+		r.NoError(err)
+		published = append(published, msg.Key())
+	}
+
+	ctx := context.TODO()
+	readAll := func(opts client.LogStreamOpts) []refs.MessageRef {
+		src, err := c.LogStream(opts)
+		r.NoError(err)
+
+		var got []refs.MessageRef
+		for {
+			v, err := src.Next(ctx)
+			if luigi.IsEOS(err) {
+				break
+			}
+			r.NoError(err)
+			got = append(got, v.(refs.KeyValueRaw).Key())
+		}
+		return got
+	}
+
+	a.Equal(published, readAll(client.LogStreamOpts{}))
+	a.Equal(published[3:], readAll(client.LogStreamOpts{Gt: 2}))
+	a.Equal(published[:2], readAll(client.LogStreamOpts{Lt: 2}))
+	a.Equal(published[2:4], readAll(client.LogStreamOpts{Gt: 1, Limit: 2}))
+	a.Equal([]refs.MessageRef{published[5], published[4]}, readAll(client.LogStreamOpts{Reverse: true, Limit: 2}))
+	a.Equal([]refs.MessageRef{published[3], published[2]}, readAll(client.LogStreamOpts{Reverse: true, Gt: 1, Lt: 4}))
+	a.Equal([]refs.MessageRef{published[3]}, readAll(client.LogStreamOpts{Reverse: true, Lt: 4, Limit: 1}))
+
+	// a live stream delivers the new messages as they come in
+	src, err := c.LogStream(client.LogStreamOpts{Live: true, Gt: n - 2})
+	r.NoError(err)
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	a.True(v.(refs.KeyValueRaw).Key().Equal(published[n-1]))
+
+	for i := 0; i < 3; i++ {
+		msg, err := srv.PublishLog.Publish(map[string]interface{}{"type": "test", "live": i})
+		r.NoError(err)
+
+		tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		v, err := src.Next(tctx)
+		cancel()
+		r.NoError(err, "live message %d", i)
+		a.True(v.(refs.KeyValueRaw).Key().Equal(msg.Key()), "wrong live message %d", i)
+	}
+
+	c.Terminate()
+
+	srv.Shutdown()
+	srv.Close()
+}
+
+func TestSubscribeTypes(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.WithPromisc(true),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	srvErrc := make(chan error, 1)
+	go func() {
+		srvErrc <- srv.Network.Serve(ctx)
+	}()
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	// stored messages are not part of the subscription
+	_, err = srv.PublishLog.Publish(refs.NewPost("before"))
+	r.NoError(err)
+
+	_, err = c.SubscribeTypes(nil)
+	r.Error(err, "need at least one type")
+
+	src, err := c.SubscribeTypes([]string{"post", "vote"})
+	r.NoError(err)
+
+	received := collectKeys(ctx, src)
+	pings := waitForLiveStart(t, srv, received)
+
+	var want []refs.MessageRef
+	for i, content := range []interface{}{
+		map[string]interface{}{"type": "test", "i": 0},
+		refs.NewPost("hello"),
+		map[string]interface{}{"type": "test", "i": 1},
+		map[string]interface{}{"type": "vote", "vote": map[string]interface{}{"value": 1}},
+		refs.NewPost("world"),
+	} {
+		msg, err := srv.PublishLog.Publish(content)
+		r.NoError(err, "publish %d", i)
+		if i%2 == 1 || i == 4 {
+			want = append(want, msg.Key())
+		}
+	}
+
+	for i, key := range want {
+		got := nextKey(t, received, pings)
+		a.True(got.Equal(key), "wrong live message %d", i)
+	}
+
+	// nothing else is delivered
+	select {
+	case got := <-received:
+		a.Fail("unexpected message", "got %s", got.String())
+	case <-time.After(time.Second):
+	}
+
+	// other peers can't keep a live subset open
+	kp, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	peer, err := client.NewTCP(kp, srv.Network.GetListenAddr())
+	r.NoError(err, "failed to make peer connection")
+
+	peerSrc, err := peer.Subset(query.NewSubsetOpByType("post"), query.SubsetOptions{Keys: true, LiveOnly: true})
+	r.NoError(err)
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	a.False(peerSrc.Next(tctx), "live subset should be refused")
+	tcancel()
+	a.Error(peerSrc.Err())
+
+	peer.Terminate()
+	c.Terminate()
+
+	srv.Shutdown()
+	srv.Close()
+	cancel()
+	<-srvErrc
+}
+
+func TestFeedTail(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	var published []refs.MessageRef
+	for i := 0; i < 4; i++ {
+		msg, err := srv.PublishLog.Publish(map[string]interface{}{"type": "test", "i": i})
+		r.NoError(err)
+		published = append(published, msg.Key())
+	}
+	srv.WaitUntilIndexesAreSynced()
+	r.NoError(srv.NullMessage(published[2]))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	next := func(src luigi.Source, want refs.MessageRef) {
+		tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		v, err := src.Next(tctx)
+		cancel()
+		r.NoError(err)
+		a.True(v.(refs.KeyValueRaw).Key().Equal(want), "wrong message")
+	}
+
+	// from the second message on, skipping the nulled third
+	fromTwo, err := c.FeedTail(srv.KeyPair.ID(), 2)
+	r.NoError(err)
+	next(fromTwo, published[1])
+	next(fromTwo, published[3])
+
+	// only new ones
+	onlyNew, err := c.FeedTail(srv.KeyPair.ID(), 0)
+	r.NoError(err)
+	onlyNewKeys := collectKeys(ctx, onlyNew)
+	pings := waitForLiveStart(t, srv, onlyNewKeys)
+	fromTwoKeys := collectKeys(ctx, fromTwo)
+
+	for i := 0; i < 2; i++ {
+		msg, err := srv.PublishLog.Publish(map[string]interface{}{"type": "test", "live": i})
+		r.NoError(err)
+		a.True(nextKey(t, fromTwoKeys, pings).Equal(msg.Key()), "wrong message")
+		a.True(nextKey(t, onlyNewKeys, pings).Equal(msg.Key()), "wrong message")
+	}
+
+	c.Terminate()
+
+	srv.Shutdown()
+	srv.Close()
+}
+
+// collectKeys sends the keys of the messages of src on the returned channel, until src fails
+func collectKeys(ctx context.Context, src luigi.Source) <-chan refs.MessageRef {
+	keys := make(chan refs.MessageRef)
+	go func() {
+		for {
+			v, err := src.Next(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case keys <- v.(refs.KeyValueRaw).Key():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return keys
+}
+
+// waitForLiveStart publishes posts on srv until one of them is received, since a live stream only starts once the server handled the request.
+// It returns the keys of the posts, later ones can still be on their way.
+func waitForLiveStart(t *testing.T, srv *sbot.Sbot, received <-chan refs.MessageRef) map[string]bool {
+	pings := make(map[string]bool)
+	require.Eventually(t, func() bool {
+		msg, err := srv.PublishLog.Publish(refs.NewPost("ping"))
+		if err != nil {
+			return false
+		}
+		pings[msg.Key().String()] = true
+
+		select {
+		case <-received:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond, "live stream didn't start")
+	return pings
+}
+
+// nextKey returns the next key from received which isn't one of skip
+func nextKey(t *testing.T, received <-chan refs.MessageRef, skip map[string]bool) refs.MessageRef {
+	for {
+		select {
+		case got := <-received:
+			if !skip[got.String()] {
+				return got
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout waiting for a message")
+		}
+	}
+}
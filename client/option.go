@@ -8,7 +8,9 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"time"
 
+	"github.com/ssbc/go-netwrap"
 	"go.mindeco.de/log"
 )
 
@@ -41,3 +43,24 @@ func WithSHSAppKey(appKey string) Option {
 		return nil
 	}
 }
+
+// WithDialer changes how NewTCP opens the connection, like network.NewWebsocketDialer to connect over websockets.
+func WithDialer(dial netwrap.Dialer) Option {
+	return func(c *Client) error {
+		c.dialer = dial
+		return nil
+	}
+}
+
+// WithReconnectBackoff sets the minimum and maximum wait time between redial attempts of a Reconnecting client.
+// The wait time doubles after each failed attempt until it reaches max.
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(c *Client) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("ssbClient: invalid reconnect backoff (min:%s max:%s)", min, max)
+		}
+		c.reconnectMin = min
+		c.reconnectMax = max
+		return nil
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/ssbc/go-muxrpc/v2"
+	refs "github.com/ssbc/go-ssb-refs"
 
 	"github.com/ssbc/go-ssb/client"
 	"github.com/ssbc/go-ssb/internal/testutils"
@@ -93,6 +94,85 @@ func TestAutomaticUnboxing(t *testing.T) {
 	srv.Close()
 }
 
+func TestPrivateReadPage(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	c, err := client.NewUnix(filepath.Join(srvRepo, "socket"))
+	r.NoError(err, "failed to make client connection")
+
+	// no private messages yet
+	msgs, next, err := c.PrivateReadPage(refs.MessageRef{}, 3)
+	r.NoError(err)
+	a.Len(msgs, 0)
+	a.True(next.Equal(refs.MessageRef{}))
+
+	// interleave private and public messages
+	const n = 7
+	var published []refs.MessageRef
+	for i := 0; i < n; i++ {
+		ref, err := c.PrivatePublish(map[string]interface{}{"type": "test", "i": i}, srv.KeyPair.ID())
+		r.NoError(err)
+		published = append(published, ref)
+
+		_, err = srv.PublishLog.Publish(map[string]interface{}{"type": "test", "public": i})
+		r.NoError(err)
+	}
+
+	var (
+		got   []refs.MessageRef
+		pages int
+	)
+	for {
+		msgs, next, err = c.PrivateReadPage(next, 3)
+		r.NoError(err)
+		pages++
+
+		for _, msg := range msgs {
+			a.Contains(string(msg.Value.Content), `"type":"test"`, "not decrypted")
+			got = append(got, msg.Key())
+		}
+
+		if next.Equal(refs.MessageRef{}) {
+			break
+		}
+		r.Less(pages, n, "too many pages")
+	}
+	a.Equal(3, pages)
+
+	r.Len(got, n)
+	for i, ref := range got {
+		a.True(ref.Equal(published[n-1-i]), "wrong message at %d", i)
+	}
+
+	// a public message works as a cursor, too
+	pub, err := srv.PublishLog.Publish(map[string]interface{}{"type": "test", "public": "cursor"})
+	r.NoError(err)
+	msgs, _, err = c.PrivateReadPage(pub.Key(), 1)
+	r.NoError(err)
+	r.Len(msgs, 1)
+	a.True(msgs[0].Key().Equal(published[n-1]))
+
+	_, _, err = c.PrivateReadPage(refs.MessageRef{}, 0)
+	a.Error(err, "limit 0 should fail")
+
+	c.Terminate()
+
+	srv.Shutdown()
+	srv.Close()
+}
+
 func testElementsInSource(t *testing.T, src *muxrpc.ByteSource, cnt int) {
 	ctx := context.Background()
 	r, a := require.New(t), assert.New(t)
@@ -0,0 +1,294 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"go.mindeco.de/log"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// ErrReconnected is returned by streams of a Reconnecting client when the connection they were opened on was lost.
+// Nothing is replayed on the new connection, callers need to re-subscribe.
+var ErrReconnected = errors.New("ssbClient: connection was lost, streams need to be re-subscribed")
+
+// ErrNotConnected is returned by a Reconnecting client while it waits for the next redial attempt.
+var ErrNotConnected = errors.New("ssbClient: not connected")
+
+const (
+	defaultReconnectMin = 250 * time.Millisecond
+	defaultReconnectMax = 30 * time.Second
+)
+
+// ConnState describes the state of the connection of a Reconnecting client
+type ConnState uint
+
+const (
+	ConnStateConnected ConnState = iota
+	ConnStateDisconnected
+	ConnStateRedialFailed
+)
+
+func (cs ConnState) String() string {
+	switch cs {
+	case ConnStateConnected:
+		return "connected"
+	case ConnStateDisconnected:
+		return "disconnected"
+	case ConnStateRedialFailed:
+		return "redial-failed"
+	}
+	return fmt.Sprintf("ConnState(%d)", uint(cs))
+}
+
+// ConnEvent is emitted by a Reconnecting client whenever the state of it's connection changes
+type ConnEvent struct {
+	State ConnState
+
+	// Attempt counts the redials since the connection was lost
+	Attempt int
+
+	// Err is set for ConnStateRedialFailed
+	Err error
+}
+
+// Reconnecting wraps a Client and transparently redials it with exponential backoff when the connection is lost.
+// Calls made while disconnected fail with ErrNotConnected.
+type Reconnecting struct {
+	logger log.Logger
+
+	dial func() (*Client, error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	backoffMin, backoffMax time.Duration
+
+	mu      sync.Mutex
+	current *Client
+	gen     uint64
+
+	events chan ConnEvent
+
+	wg sync.WaitGroup
+}
+
+// NewReconnecting connects to the unix socket at path, like NewUnix, and redials it when the connection is lost.
+func NewReconnecting(path string, opts ...Option) (*Reconnecting, error) {
+	return newReconnecting(func() (*Client, error) {
+		return NewUnix(path, opts...)
+	}, opts)
+}
+
+// NewReconnectingTCP connects to remote, like NewTCP, and redials it when the connection is lost.
+func NewReconnectingTCP(own ssb.KeyPair, remote net.Addr, opts ...Option) (*Reconnecting, error) {
+	return newReconnecting(func() (*Client, error) {
+		return NewTCP(own, remote, opts...)
+	}, opts)
+}
+
+func newReconnecting(dial func() (*Client, error), opts []Option) (*Reconnecting, error) {
+	// only used to get the shared settings (context, logger and backoff)
+	settings, err := newClientWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &Reconnecting{
+		logger: settings.logger,
+		dial:   dial,
+
+		backoffMin: settings.reconnectMin,
+		backoffMax: settings.reconnectMax,
+
+		events: make(chan ConnEvent, 16),
+	}
+	rc.ctx, rc.cancel = settings.rootCtx, settings.rootCtxCancel
+
+	if rc.backoffMin == 0 {
+		rc.backoffMin = defaultReconnectMin
+	}
+	if rc.backoffMax == 0 {
+		rc.backoffMax = defaultReconnectMax
+	}
+
+	c, err := dial()
+	if err != nil {
+		rc.cancel()
+		return nil, err
+	}
+	rc.current = c
+
+	rc.wg.Add(1)
+	go rc.loop(c)
+
+	return rc, nil
+}
+
+// Events returns the channel of connection state changes.
+// Events are dropped if the channel isn't drained. It is closed after Close was called.
+func (rc *Reconnecting) Events() <-chan ConnEvent { return rc.events }
+
+// Client returns the currently connected client or ErrNotConnected.
+// The returned client must not be closed by the caller.
+func (rc *Reconnecting) Client() (*Client, error) {
+	c, _, err := rc.get()
+	return c, err
+}
+
+func (rc *Reconnecting) get() (*Client, uint64, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.current == nil {
+		return nil, rc.gen, ErrNotConnected
+	}
+	return rc.current, rc.gen, nil
+}
+
+// Async calls method on the current connection.
+func (rc *Reconnecting) Async(ret interface{}, tipe muxrpc.RequestEncoding, method muxrpc.Method, args ...interface{}) error {
+	c, _, err := rc.get()
+	if err != nil {
+		return err
+	}
+	return c.Async(rc.ctx, ret, tipe, method, args...)
+}
+
+// Source opens a stream on the current connection.
+// If that connection is lost, Err() of the returned source reports ErrReconnected.
+func (rc *Reconnecting) Source(tipe muxrpc.RequestEncoding, method muxrpc.Method, args ...interface{}) (*ReconnectingSource, error) {
+	c, gen, err := rc.get()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := c.Source(rc.ctx, tipe, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &ReconnectingSource{ByteSource: src, rc: rc, gen: gen}, nil
+}
+
+// ReconnectingSource is a muxrpc source that was opened by a Reconnecting client
+type ReconnectingSource struct {
+	*muxrpc.ByteSource
+
+	rc  *Reconnecting
+	gen uint64
+}
+
+// Err returns ErrReconnected if the stream ended because it's connection was lost.
+func (rs ReconnectingSource) Err() error {
+	err := rs.ByteSource.Err()
+	if err == nil || !errors.Is(err, muxrpc.ErrSessionTerminated) {
+		return err
+	}
+
+	if rs.rc.ctx.Err() != nil { // closed by us
+		return err
+	}
+
+	rs.rc.mu.Lock()
+	lost := rs.rc.gen != rs.gen || rs.rc.current == nil
+	rs.rc.mu.Unlock()
+	if lost {
+		return ErrReconnected
+	}
+	return err
+}
+
+// Close stops redialing and closes the current connection.
+func (rc *Reconnecting) Close() error {
+	rc.cancel()
+
+	rc.mu.Lock()
+	c := rc.current
+	rc.current = nil
+	rc.mu.Unlock()
+
+	var err error
+	if c != nil {
+		err = c.Close()
+	}
+	rc.wg.Wait()
+	return err
+}
+
+func (rc *Reconnecting) emit(evt ConnEvent) {
+	select {
+	case rc.events <- evt:
+	default:
+		level.Debug(rc.logger).Log("event", "dropped connection event", "state", evt.State)
+	}
+}
+
+func (rc *Reconnecting) loop(c *Client) {
+	defer rc.wg.Done()
+	defer close(rc.events)
+
+	for {
+		select {
+		case <-c.done:
+		case <-rc.ctx.Done():
+			return
+		}
+		if rc.ctx.Err() != nil {
+			return
+		}
+
+		rc.mu.Lock()
+		rc.current = nil
+		rc.gen++
+		rc.mu.Unlock()
+		c.rootCtxCancel()
+
+		level.Warn(rc.logger).Log("event", "connection lost")
+		rc.emit(ConnEvent{State: ConnStateDisconnected})
+
+		backoff := rc.backoffMin
+		for attempt := 1; ; attempt++ {
+			select {
+			case <-time.After(backoff):
+			case <-rc.ctx.Done():
+				return
+			}
+
+			var err error
+			c, err = rc.dial()
+			if err == nil {
+				rc.mu.Lock()
+				if rc.ctx.Err() != nil { // closed while the redial was in flight
+					rc.mu.Unlock()
+					c.Close()
+					return
+				}
+				rc.current = c
+				rc.mu.Unlock()
+
+				level.Info(rc.logger).Log("event", "reconnected", "attempt", attempt)
+				rc.emit(ConnEvent{State: ConnStateConnected, Attempt: attempt})
+				break
+			}
+
+			level.Debug(rc.logger).Log("event", "redial failed", "attempt", attempt, "err", err)
+			rc.emit(ConnEvent{State: ConnStateRedialFailed, Attempt: attempt, Err: err})
+
+			backoff *= 2
+			if backoff > rc.backoffMax {
+				backoff = rc.backoffMax
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/client"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/message"
+	"github.com/ssbc/go-ssb/sbot"
+)
+
+func TestReconnectingUnixSock(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	mkSrv := func() *sbot.Sbot {
+		srv, err := sbot.New(
+			sbot.WithInfo(srvLog),
+			sbot.WithRepoPath(srvRepo),
+			sbot.WithListenAddr(":0"),
+			sbot.LateOption(sbot.WithUNIXSocket()),
+		)
+		r.NoError(err, "sbot srv init failed")
+		return srv
+	}
+	srv := mkSrv()
+
+	rc, err := client.NewReconnecting(filepath.Join(srvRepo, "socket"),
+		client.WithReconnectBackoff(50*time.Millisecond, 500*time.Millisecond))
+	r.NoError(err, "failed to make client connection")
+
+	c, err := rc.Client()
+	r.NoError(err)
+	ref, err := c.Whoami()
+	r.NoError(err, "failed to call whoami")
+	a.Equal(srv.KeyPair.ID().String(), ref.String())
+
+	// open a live stream that will be cut by the restart
+	var args message.CreateLogArgs
+	args.Live = true
+	src, err := rc.Source(muxrpc.TypeJSON, muxrpc.Method{"createLogStream"}, args)
+	r.NoError(err)
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+
+	waitFor := func(want client.ConnState) {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case evt := <-rc.Events():
+				t.Log("event:", evt.State, evt.Attempt, evt.Err)
+				if evt.State == want {
+					return
+				}
+			case <-timeout:
+				t.Fatal("timeout waiting for", want)
+			}
+		}
+	}
+	waitFor(client.ConnStateDisconnected)
+
+	for src.Next(context.TODO()) {
+	}
+	r.ErrorIs(src.Err(), client.ErrReconnected)
+
+	srv = mkSrv()
+	waitFor(client.ConnStateConnected)
+
+	c, err = rc.Client()
+	r.NoError(err)
+	ref, err = c.Whoami()
+	r.NoError(err, "failed to call whoami after reconnect")
+	a.Equal(srv.KeyPair.ID().String(), ref.String())
+
+	a.NoError(rc.Close())
+	_, err = rc.Client()
+	a.ErrorIs(err, client.ErrNotConnected)
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
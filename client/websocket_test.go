@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package client_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ssbc/go-netwrap"
+	"github.com/ssbc/go-secretstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/client"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/network"
+	"github.com/ssbc/go-ssb/sbot"
+)
+
+func TestWebsocket(t *testing.T) {
+	t.Run("ws", func(t *testing.T) { testWebsocket(t, false) })
+	t.Run("wss", func(t *testing.T) { testWebsocket(t, true) })
+}
+
+func testWebsocket(t *testing.T, withTLS bool) {
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	opts := []sbot.Option{
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithListenAddr("127.0.0.1:0"),
+		sbot.WithWebsocketAddress("127.0.0.1:0"),
+	}
+
+	var tlsConf *tls.Config
+	if withTLS {
+		certFile, keyFile := writeTestCert(t, filepath.Join("testrun", t.Name()))
+		opts = append(opts,
+			sbot.WithWebsocketTLSCert(certFile),
+			sbot.WithWebsocketTLSKey(keyFile))
+		tlsConf = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	srv, err := sbot.New(opts...)
+	r.NoError(err, "sbot srv init failed")
+
+	kp, err := ssb.LoadKeyPair(filepath.Join(srvRepo, "secret"))
+	r.NoError(err, "failed to load servers keypair")
+
+	wsAddr := srv.Network.GetWebsocketAddr()
+	r.NotNil(wsAddr)
+
+	appKey, err := base64.StdEncoding.DecodeString("1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=")
+	r.NoError(err)
+
+	remote := netwrap.WrapAddr(wsAddr, secretstream.Addr{PubKey: kp.ID().PubKey()})
+	c, err := client.NewTCP(kp, remote, client.WithDialer(network.NewWebsocketDialer(appKey, tlsConf)))
+	r.NoError(err, "failed to make websocket client connection")
+
+	ref, err := c.Whoami()
+	r.NoError(err, "failed to call whoami")
+	a.Equal(kp.ID().String(), ref.String())
+	a.NoError(c.Close())
+
+	// offering only a subprotocol of another network is rejected before the handshake
+	scheme := "ws"
+	if withTLS {
+		scheme = "wss"
+	}
+	otherKey := make([]byte, 32)
+	dialer := websocket.Dialer{
+		Subprotocols:    []string{network.WebsocketSubprotocol(otherKey)},
+		TLSClientConfig: tlsConf,
+	}
+	_, resp, err := dialer.Dial(scheme+"://"+wsAddr.String()+"/", nil)
+	r.Error(err)
+	r.NotNil(resp)
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key to dir
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	r := require.New(t)
+	r.NoError(os.MkdirAll(dir, 0700))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-ssb test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	r.NoError(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	r.NoError(err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	r.NoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	r.NoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
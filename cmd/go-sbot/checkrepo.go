@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+
+	"go.mindeco.de/log/level"
+
+	mksbot "github.com/ssbc/go-ssb/sbot"
+)
+
+// checkRepo logs everything CheckRepo finds and stops the sbot afterwards.
+// The broken parts are only dropped if repair is set.
+func checkRepo(sbot *mksbot.Sbot, repair bool) error {
+	level.Info(log).Log("event", "checking repo", "repair", repair)
+
+	report, err := sbot.CheckRepo()
+	if err != nil {
+		return fmt.Errorf("checkrepo: %w", err)
+	}
+
+	for _, seq := range report.TruncatedEntries {
+		level.Warn(log).Log("checkrepo", "truncated entry", "seq", seq)
+	}
+	for _, mm := range report.IndexMismatches {
+		level.Warn(log).Log("checkrepo", "index mismatch", "index", mm.Index, "processed", mm.Processed, "total", mm.Total)
+	}
+	for _, mm := range report.FeedMismatches {
+		level.Warn(log).Log("checkrepo", "feed mismatch", "feed", mm.Ref.String(), "stored", mm.Stored, "logical", mm.Logical)
+	}
+	for _, broken := range report.BrokenFeeds {
+		level.Warn(log).Log("checkrepo", "broken feed", "feed", broken.Feed.String(), "last-good", broken.Seq-1, "reason", broken.Reason, "err", broken.Err)
+	}
+	for _, ref := range report.OrphanedBlobs {
+		level.Warn(log).Log("checkrepo", "orphaned blob", "ref", ref.String())
+	}
+
+	level.Info(log).Log("checkrepo", "completed",
+		"ok", report.OK(),
+		"msgs", report.Messages,
+		"truncated", len(report.TruncatedEntries),
+		"index-mismatches", len(report.IndexMismatches),
+		"feed-mismatches", len(report.FeedMismatches),
+		"broken-feeds", len(report.BrokenFeeds),
+		"orphaned-blobs", len(report.OrphanedBlobs),
+	)
+
+	if repair && !report.OK() {
+		if err := sbot.RepairRepo(report); err != nil {
+			level.Error(log).Log("checkrepo", "repair failed", "err", err)
+		} else {
+			level.Info(log).Log("checkrepo", "repaired", "msgs", report.Sequences.GetCardinality())
+		}
+	}
+
+	sbot.Shutdown()
+	if err := sbot.Close(); err != nil {
+		return fmt.Errorf("checkrepo: failed to stop sbot: %w", err)
+	}
+	return nil
+}
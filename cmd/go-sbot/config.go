@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	loglib "log"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -17,14 +18,19 @@ import (
 
 	"github.com/komkom/toml"
 	"github.com/ssbc/go-ssb/internal/testutils"
+	mksbot "github.com/ssbc/go-ssb/sbot"
 	"go.mindeco.de/log/level"
 )
 
+// defaultMetricsAddress is used if metrics are enabled without an address to listen on
+const defaultMetricsAddress = "127.0.0.1:9100"
+
 type ConfigBool bool
 type SbotConfig struct {
-	ShsCap string `json:"shscap,omitempty"`
-	Hmac   string `json:"hmac,omitempty"`
-	Hops   uint   `json:"hops,omitempty"`
+	Network string `json:"network,omitempty"`
+	ShsCap  string `json:"shscap,omitempty"`
+	Hmac    string `json:"hmac,omitempty"`
+	Hops    uint   `json:"hops,omitempty"`
 
 	Repo     string `json:"repo,omitempty"`
 	DebugDir string `json:"debugdir,omitempty"`
@@ -35,7 +41,9 @@ type SbotConfig struct {
 	WebsocketTLSKey  string `json:"wstlskey,omitempty"`
 	MetricsAddress   string `json:"debuglis,omitempty"`
 
+	EnableMetrics       ConfigBool `json:"enable-metrics"`
 	NoUnixSocket        ConfigBool `json:"nounixsock"`
+	UnixSocket          string     `json:"unixsock,omitempty"`
 	EnableAdvertiseUDP  ConfigBool `json:"localadv"`
 	EnableDiscoveryUDP  ConfigBool `json:"localdiscov"`
 	EnableEBT           ConfigBool `json:"enable-ebt"`
@@ -45,6 +53,11 @@ type SbotConfig struct {
 	NumPeer uint `json:"numPeer,omitempty"`
 	NumRepl uint `json:"numRepl,omitempty"`
 
+	EBTIdleTimeout string `json:"ebt-idle-timeout,omitempty"`
+	EBTMaxSessions uint   `json:"ebt-max-sessions,omitempty"`
+
+	PeersFile string `json:"peers,omitempty"`
+
 	presence map[string]interface{}
 }
 
@@ -53,7 +66,59 @@ func (config SbotConfig) Has(flagname string) bool {
 	return ok
 }
 
+// Validate checks the values of config which can't be checked while decoding it
+func (config SbotConfig) Validate() error {
+	if config.Network != "" {
+		if _, has := mksbot.LookupNetwork(config.Network); !has {
+			return fmt.Errorf("unknown network %q (known: %v)", config.Network, mksbot.NetworkNames())
+		}
+		if config.ShsCap != "" || config.Hmac != "" {
+			return fmt.Errorf("network %q sets the shscap and hmac, they can't be set as well", config.Network)
+		}
+	}
+
+	if config.MetricsAddress != "" {
+		_, port, err := net.SplitHostPort(config.MetricsAddress)
+		if err != nil {
+			return eout(err, "invalid metrics address %q", config.MetricsAddress)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return eout(err, "invalid port in metrics address %q", config.MetricsAddress)
+		}
+	}
+	return nil
+}
+
+// resolveMetrics settles the metrics address from the debuglis address and the enable-metrics toggle.
+// An address implies that metrics are enabled, enabling them without an address listens on defaultMetricsAddress.
+// Explicitly disabling them clears the address.
+func (config *SbotConfig) resolveMetrics() {
+	if !config.Has("enable-metrics") {
+		return
+	}
+
+	config.presence["debuglis"] = true
+	if !config.EnableMetrics {
+		config.MetricsAddress = ""
+		return
+	}
+
+	if config.MetricsAddress == "" {
+		config.MetricsAddress = defaultMetricsAddress
+		level.Info(log).Log("event", "read config", "msg", "metrics enabled without an address, using the default", "addr", defaultMetricsAddress)
+	}
+}
+
 func readConfig(configPath string) (SbotConfig, bool) {
+	conf, exists, err := loadConfig(configPath)
+	if err != nil {
+		loglib.Fatalln(err)
+	}
+	return conf, exists
+}
+
+// loadConfig is like readConfig but returns decoding errors instead of exiting
+func loadConfig(configPath string) (SbotConfig, bool, error) {
 	var conf SbotConfig
 
 	conf.presence = make(map[string]interface{})
@@ -66,25 +131,77 @@ func readConfig(configPath string) (SbotConfig, bool) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		level.Info(log).Log("event", "read config", "msg", "no config detected", "path", configPath)
-		return conf, false
+		return conf, false, nil
 	}
 
 	level.Info(log).Log("event", "read config", "msg", "config detected", "path", configPath)
 
-	// 1) first we unmarshal into struct for type checks
+	err = conf.decode(configPath, data, nil)
+	if err != nil {
+		return conf, true, err
+	}
+
+	// help repo path's default to align with common user expectations
+	conf.Repo = expandPath(conf.Repo)
+
+	return conf, true, nil
+}
+
+// decode applies the config file at path with the contents data on top of config.
+// The files listed under include are applied first, in order, so that the keys of the including file win.
+// Relative includes are relative to the directory of the including file. including holds the chain of files
+// which led to path, to detect cycles.
+func (config *SbotConfig) decode(path string, data []byte, including []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return eout(err, "resolve config path %s", path)
+	}
+	for _, p := range including {
+		if p == abs {
+			return fmt.Errorf("config include cycle: %s -> %s", strings.Join(including, " -> "), abs)
+		}
+	}
+	including = append(including, abs)
+
+	var includes struct {
+		Include []string `json:"include"`
+	}
 	decoder := json.NewDecoder(toml.New(bytes.NewBuffer(data)))
-	err = decoder.Decode(&conf)
-	check(err, "decode into struct")
+	err = decoder.Decode(&includes)
+	if err != nil {
+		return eout(err, "decode includes of %s", path)
+	}
 
-	// 2) then we unmarshal into a map for presence check (to make sure bools are treated correctly)
+	for _, inc := range includes.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(abs), inc)
+		}
+		incData, err := os.ReadFile(inc)
+		if err != nil {
+			return eout(err, "read config %s included by %s", inc, path)
+		}
+		level.Info(log).Log("event", "read config", "msg", "including config", "path", inc)
+		if err := config.decode(inc, incData, including); err != nil {
+			return err
+		}
+	}
+
+	// 1) first we unmarshal into struct for type checks
 	decoder = json.NewDecoder(toml.New(bytes.NewBuffer(data)))
-	err = decoder.Decode(&conf.presence)
-	check(err, "decode into presence map")
+	err = decoder.Decode(config)
+	if err != nil {
+		return eout(err, "decode %s into struct", path)
+	}
 
-	// help repo path's default to align with common user expectations
-	conf.Repo = expandPath(conf.Repo)
+	// 2) then we unmarshal into a map for presence check (to make sure bools are treated correctly)
+	decoder = json.NewDecoder(toml.New(bytes.NewBuffer(data)))
+	err = decoder.Decode(&config.presence)
+	if err != nil {
+		return eout(err, "decode %s into presence map", path)
+	}
+	delete(config.presence, "include")
 
-	return conf, true
+	return nil
 }
 
 // ensure the following type of path expansions take place:
@@ -116,7 +233,8 @@ func ReadEnvironmentVariables(config *SbotConfig) {
 	}
 
 	if val := os.Getenv("SSB_SOCKET_FILE"); val != "" {
-		loglib.Fatalln("flag SSB_SOCKET_FILE not implemented")
+		config.UnixSocket = val
+		config.presence["unixsock"] = true
 	}
 
 	if val := os.Getenv("SSB_LOG_LEVEL"); val != "" {
@@ -149,7 +267,8 @@ func ReadEnvironmentVariables(config *SbotConfig) {
 	}
 
 	if val := os.Getenv("SSB_PROMETHEUS_ENABLED"); val != "" {
-		config.presence["debuglis"] = readEnvironmentBoolean(val)
+		config.EnableMetrics = readEnvironmentBoolean(val)
+		config.presence["enable-metrics"] = true
 	}
 
 	if val := os.Getenv("SSB_HOPS"); val != "" {
@@ -184,6 +303,23 @@ func ReadEnvironmentVariables(config *SbotConfig) {
 		config.presence["enable-ebt"] = true
 	}
 
+	if val := os.Getenv("SSB_EBT_IDLE_TIMEOUT"); val != "" {
+		config.EBTIdleTimeout = val
+		config.presence["ebt-idle-timeout"] = true
+	}
+
+	if val := os.Getenv("SSB_EBT_MAX_SESSIONS"); val != "" {
+		n, err := strconv.Atoi(val)
+		check(err, "parse ebt max sessions from environment variable")
+		config.EBTMaxSessions = uint(n)
+		config.presence["ebt-max-sessions"] = true
+	}
+
+	if val := os.Getenv("SSB_PEERS_FILE"); val != "" {
+		config.PeersFile = val
+		config.presence["peers"] = true
+	}
+
 	if val := os.Getenv("SSB_CONN_FIREWALL_ENABLED"); val != "" {
 		config.EnableFirewall = readEnvironmentBoolean(val)
 		config.presence["promisc"] = true
@@ -204,6 +340,11 @@ func ReadEnvironmentVariables(config *SbotConfig) {
 		config.presence["localadv"] = true
 	}
 
+	if val := os.Getenv("SSB_NETWORK"); val != "" {
+		config.Network = val
+		config.presence["network"] = true
+	}
+
 	if val := os.Getenv("SSB_CAP_SHS_KEY"); val != "" {
 		config.ShsCap = val
 		config.presence["shscap"] = true
@@ -218,12 +359,14 @@ func ReadEnvironmentVariables(config *SbotConfig) {
 		numPeer, err := strconv.Atoi(val)
 		check(err, "parse numPeer from environment variable")
 		config.NumPeer = uint(numPeer)
+		config.presence["numPeer"] = true
 	}
 
 	if val := os.Getenv("SSB_NUM_REPL"); val != "" {
 		numRepl, err := strconv.Atoi(val)
 		check(err, "parse numRepl from environment variable")
 		config.NumRepl = uint(numRepl)
+		config.presence["numRepl"] = true
 	}
 }
 
@@ -267,7 +410,7 @@ func booleanIsTrue(s string) bool {
 
 func readEnvironmentBoolean(s string) ConfigBool {
 	var booly ConfigBool
-	err := json.Unmarshal([]byte(s), booly)
+	err := json.Unmarshal([]byte(strconv.Quote(s)), &booly)
 	check(err, "parsing environment variable bool")
 	return booly
 }
@@ -275,9 +418,25 @@ func readEnvironmentBoolean(s string) ConfigBool {
 func readConfigAndEnv(configPath string) (SbotConfig, bool) {
 	config, exists := readConfig(configPath)
 	ReadEnvironmentVariables(&config)
+	config.resolveMetrics()
+	check(config.Validate(), "invalid config")
 	return config, exists
 }
 
+// reloadConfigAndEnv is like readConfigAndEnv but doesn't exit if the config file has errors
+func reloadConfigAndEnv(configPath string) (SbotConfig, error) {
+	config, _, err := loadConfig(configPath)
+	if err != nil {
+		return config, err
+	}
+	ReadEnvironmentVariables(&config)
+	config.resolveMetrics()
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
 func eout(err error, msg string, args ...interface{}) error {
 	if err != nil {
 		msg = fmt.Sprintf(msg, args...)
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -17,6 +18,7 @@ import (
 	"time"
 
 	"github.com/ssbc/go-ssb/client"
+	mksbot "github.com/ssbc/go-ssb/sbot"
 	"github.com/stretchr/testify/require"
 )
 
@@ -309,3 +311,314 @@ func TestGenerateDefaultConfig(t *testing.T) {
 	_, exists := readConfig(confPath)
 	r.True(exists)
 }
+
+type fakeLiveSettings struct {
+	hops           []uint
+	promisc        []bool
+	perPeer, total uint
+	policies       [][]mksbot.PeerPolicy
+}
+
+func (f *fakeLiveSettings) SetHops(h uint)    { f.hops = append(f.hops, h) }
+func (f *fakeLiveSettings) SetPromisc(b bool) { f.promisc = append(f.promisc, b) }
+func (f *fakeLiveSettings) SetConcurrentReplications(perPeer, total uint) {
+	f.perPeer, f.total = perPeer, total
+}
+func (f *fakeLiveSettings) SetPeerPolicies(p []mksbot.PeerPolicy) error {
+	f.policies = append(f.policies, p)
+	return nil
+}
+
+func TestReloadConfig(t *testing.T) {
+	r := require.New(t)
+
+	testPath := filepath.Join(".", "testrun", t.Name())
+	r.NoError(os.RemoveAll(testPath), "remove testrun folder")
+	r.NoError(os.MkdirAll(testPath, 0700), "make new testrun folder")
+	configPath := filepath.Join(testPath, "config.toml")
+
+	oldHops, oldPromisc, oldNumPeer, oldNumRepl := flagHops, flagPromisc, flagNumPeer, flagNumRepl
+	oldListenAddr, oldRepoDir := listenAddr, repoDir
+	t.Cleanup(func() {
+		flagHops, flagPromisc, flagNumPeer, flagNumRepl = oldHops, oldPromisc, oldNumPeer, oldNumRepl
+		listenAddr, repoDir = oldListenAddr, oldRepoDir
+	})
+
+	// the running settings
+	flagHops, flagPromisc, flagNumPeer, flagNumRepl = 1, false, 5, 10
+	listenAddr = ":8008"
+	repoDir = filepath.Join(testPath, "repo")
+
+	err := os.WriteFile(configPath, []byte(`hops = 2
+promisc = true
+numPeer = 5
+numRepl = 20
+lis = ":9009"
+`), 0700)
+	r.NoError(err)
+
+	config, err := reloadConfigAndEnv(configPath)
+	r.NoError(err)
+
+	var bot fakeLiveSettings
+	applied, ignored := applyLiveConfig(&bot, config)
+	r.Equal([]string{"hops", "promisc", "numRepl"}, applied)
+	r.Equal([]string{"lis"}, ignored)
+
+	r.Equal([]uint{2}, bot.hops)
+	r.Equal([]bool{true}, bot.promisc)
+	r.EqualValues(0, bot.perPeer, "unchanged")
+	r.EqualValues(20, bot.total)
+	r.EqualValues(2, flagHops)
+	r.Equal(":8008", listenAddr, "listen address shouldn't change")
+
+	// applying it again doesn't change anything
+	bot = fakeLiveSettings{}
+	applied, ignored = applyLiveConfig(&bot, config)
+	r.Len(applied, 0)
+	r.Equal([]string{"lis"}, ignored)
+	r.Len(bot.hops, 0)
+
+	// broken files are reported instead of stopping the bot
+	r.NoError(os.WriteFile(configPath, []byte(`hops = "many"`), 0700))
+	_, err = reloadConfigAndEnv(configPath)
+	r.Error(err)
+}
+
+func TestReloadPeers(t *testing.T) {
+	r := require.New(t)
+
+	testPath := filepath.Join(".", "testrun", t.Name())
+	r.NoError(os.RemoveAll(testPath), "remove testrun folder")
+	r.NoError(os.MkdirAll(testPath, 0700), "make new testrun folder")
+
+	oldConfigPath := configPath
+	configPath = filepath.Join(testPath, "config.toml")
+	t.Cleanup(func() {
+		configPath = oldConfigPath
+		flagPeersFile = ""
+	})
+	flagPeersFile = ""
+
+	const (
+		alice = "@AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=.ed25519"
+		bob   = "@AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=.ed25519"
+	)
+	peersPath := filepath.Join(testPath, "peers.toml")
+	r.NoError(os.WriteFile(peersPath, []byte(`[[peer]]
+id = "`+alice+`"
+policy = "always"
+address = "net:127.0.0.1:8008~shs:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+
+[[peer]]
+id = "`+bob+`"
+policy = "never"
+`), 0600))
+	r.NoError(os.WriteFile(configPath, []byte(`peers = "peers.toml"`), 0600))
+
+	config, err := reloadConfigAndEnv(configPath)
+	r.NoError(err)
+
+	var bot fakeLiveSettings
+	applied, _ := applyLiveConfig(&bot, config)
+	r.Equal([]string{"peers"}, applied)
+	r.Equal("peers.toml", flagPeersFile)
+	r.Len(bot.policies, 1)
+	r.Len(bot.policies[0], 2)
+	r.Equal(alice, bot.policies[0][0].Peer.String())
+	r.Equal(mksbot.ConnPolicyAlways, bot.policies[0][0].Policy)
+	r.NotEmpty(bot.policies[0][0].Addr)
+	r.Equal(mksbot.ConnPolicyNever, bot.policies[0][1].Policy)
+
+	// the file is read again on every reload
+	r.NoError(os.WriteFile(peersPath, []byte(`[[peer]]
+id = "`+bob+`"
+policy = "default"
+`), 0600))
+	applied, _ = applyLiveConfig(&bot, config)
+	r.Equal([]string{"peers"}, applied)
+	r.Len(bot.policies, 2)
+	r.Len(bot.policies[1], 1)
+	r.Equal(mksbot.ConnPolicyDefault, bot.policies[1][0].Policy)
+
+	// broken files keep the current policies
+	r.NoError(os.WriteFile(peersPath, []byte(`[[peer]]
+id = "`+bob+`"
+policy = "sometimes"
+`), 0600))
+	applied, ignored := applyLiveConfig(&bot, config)
+	r.Len(applied, 0)
+	r.Equal([]string{"peers"}, ignored)
+	r.Len(bot.policies, 2)
+	r.Equal("peers.toml", flagPeersFile)
+}
+
+func TestPrintConfig(t *testing.T) {
+	r := require.New(t)
+
+	testPath := filepath.Join(".", "testrun", t.Name())
+	r.NoError(os.RemoveAll(testPath), "remove testrun folder")
+	r.NoError(os.MkdirAll(testPath, 0700), "make new testrun folder")
+
+	oldConfigPath, oldHmac := configPath, hmacSec
+	oldHops, oldNumPeer, oldNumRepl := flagHops, flagNumPeer, flagNumRepl
+	t.Cleanup(func() {
+		configPath, hmacSec = oldConfigPath, oldHmac
+		flagHops, flagNumPeer, flagNumRepl = oldHops, oldNumPeer, oldNumRepl
+	})
+	configPath = filepath.Join(testPath, "config.toml")
+
+	err := os.WriteFile(configPath, []byte(`hops = 2
+hmac = "c2VjcmV0"
+numRepl = 20
+`), 0700)
+	r.NoError(err)
+	t.Setenv("SSB_NUM_REPL", "30")
+
+	config, _ := readConfigAndEnv(configPath)
+	flagHops, flagNumPeer, flagNumRepl = 1, 5, 10
+	applyConfig(config)
+
+	var buf bytes.Buffer
+	r.NoError(printConfig(&buf, config))
+	printed := buf.String()
+
+	r.Contains(printed, "hops = 2 # file\n")
+	r.Contains(printed, "numRepl = 30 # env\n")
+	r.Contains(printed, "numPeer = 5 # default\n")
+	r.Contains(printed, `hmac = "<redacted>" # file`)
+	r.NotContains(printed, "c2VjcmV0")
+
+	// the output is a valid config file
+	printedPath := filepath.Join(testPath, "printed.toml")
+	r.NoError(os.WriteFile(printedPath, buf.Bytes(), 0700))
+	reread, exists := readConfig(printedPath)
+	r.True(exists)
+	r.EqualValues(2, reread.Hops)
+	r.EqualValues(30, reread.NumRepl)
+}
+
+func TestSocketFileConfig(t *testing.T) {
+	r := require.New(t)
+	testPath := filepath.Join(".", "testrun", t.Name())
+	r.NoError(os.RemoveAll(testPath), "remove testrun folder")
+	r.NoError(os.MkdirAll(testPath, 0700))
+	configPath := filepath.Join(testPath, "config.toml")
+
+	r.NoError(os.WriteFile(configPath, []byte(`unixsock = "/mnt/tmpfs/from-file"`), 0600))
+	config, err := reloadConfigAndEnv(configPath)
+	r.NoError(err)
+	r.Equal("/mnt/tmpfs/from-file", config.UnixSocket)
+
+	t.Setenv("SSB_SOCKET_FILE", "/mnt/tmpfs/from-env")
+	config, err = reloadConfigAndEnv(configPath)
+	r.NoError(err)
+	r.True(config.Has("unixsock"))
+	r.Equal("/mnt/tmpfs/from-env", config.UnixSocket)
+}
+
+func TestMetricsConfig(t *testing.T) {
+	testPath := filepath.Join(".", "testrun", t.Name())
+	require.NoError(t, os.RemoveAll(testPath), "remove testrun folder")
+	configPath := filepath.Join(testPath, "config.toml")
+
+	cases := []struct {
+		name    string
+		enabled string
+		address string
+
+		setsAddress bool
+		wantAddress string
+	}{
+		{"neither", "", "", false, ""},
+		{"address implies enabled", "", "localhost:6078", true, "localhost:6078"},
+		{"enabled without address", "yes", "", true, defaultMetricsAddress},
+		{"enabled with address", "yes", "localhost:6078", true, "localhost:6078"},
+		{"disabled wins over address", "no", "localhost:6078", true, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			t.Setenv("SSB_PROMETHEUS_ENABLED", tc.enabled)
+			t.Setenv("SSB_PROMETHEUS_ADDRESS", tc.address)
+
+			config, err := reloadConfigAndEnv(configPath)
+			r.NoError(err)
+			r.Equal(tc.setsAddress, config.Has("debuglis"))
+			r.Equal(tc.wantAddress, config.MetricsAddress)
+		})
+	}
+
+	for _, addr := range []string{"localhost", "localhost:metrics", ":70000"} {
+		t.Setenv("SSB_PROMETHEUS_ADDRESS", addr)
+		_, err := reloadConfigAndEnv(configPath)
+		require.Error(t, err, addr)
+	}
+}
+
+func TestNetworkConfig(t *testing.T) {
+	r := require.New(t)
+	testPath := filepath.Join(".", "testrun", t.Name())
+	r.NoError(os.RemoveAll(testPath), "remove testrun folder")
+	r.NoError(os.MkdirAll(testPath, 0700))
+	configPath := filepath.Join(testPath, "config.toml")
+
+	r.NoError(os.WriteFile(configPath, []byte(`network = "mainnet"`), 0600))
+	config, err := reloadConfigAndEnv(configPath)
+	r.NoError(err)
+	r.Equal("mainnet", config.Network)
+
+	t.Setenv("SSB_NETWORK", "no-such-net")
+	_, err = reloadConfigAndEnv(configPath)
+	r.Error(err, "unknown network")
+	t.Setenv("SSB_NETWORK", "")
+
+	// a network and an explicit shscap don't go together
+	r.NoError(os.WriteFile(configPath, []byte("network = \"mainnet\"\nshscap = \"1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=\""), 0600))
+	_, err = reloadConfigAndEnv(configPath)
+	r.Error(err)
+
+	r.NoError(os.WriteFile(configPath, []byte(`network = "mainnet"`), 0600))
+	t.Setenv("SSB_CAP_HMAC_KEY", "1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=")
+	_, err = reloadConfigAndEnv(configPath)
+	r.Error(err)
+}
+
+func TestIncludeConfig(t *testing.T) {
+	r := require.New(t)
+	testPath := filepath.Join(".", "testrun", t.Name())
+	r.NoError(os.RemoveAll(testPath), "remove testrun folder")
+	r.NoError(os.MkdirAll(filepath.Join(testPath, "shared"), 0700))
+
+	write := func(name, content string) string {
+		p := filepath.Join(testPath, name)
+		r.NoError(os.WriteFile(p, []byte(content), 0600))
+		return p
+	}
+
+	write("shared/common.toml", "hops = 3\nnumPeer = 7\nlocaladv = true")
+	write("shared/base.toml", "include = [\"common.toml\"]\nhops = 2\nlis = \":8009\"")
+	configPath := write("config.toml", "include = [\"shared/base.toml\"]\nlis = \":8010\"\nlocaladv = false")
+
+	config, exists, err := loadConfig(configPath)
+	r.NoError(err)
+	r.True(exists)
+	r.EqualValues(2, config.Hops)
+	r.EqualValues(7, config.NumPeer)
+	r.Equal(":8010", config.MuxRPCAddress)
+	r.True(config.Has("localadv"))
+	r.False(bool(config.EnableAdvertiseUDP), "the including file wins")
+	r.True(config.Has("hops"))
+	r.False(config.Has("include"))
+	r.False(config.Has("numRepl"))
+
+	write("missing.toml", `include = ["nope.toml"]`)
+	_, _, err = loadConfig(filepath.Join(testPath, "missing.toml"))
+	r.Error(err)
+	r.Contains(err.Error(), "nope.toml")
+
+	write("shared/common.toml", `include = ["../config.toml"]`)
+	_, _, err = loadConfig(configPath)
+	r.Error(err)
+	r.Contains(err.Error(), "cycle")
+}
@@ -53,9 +53,14 @@ var (
 	flagNumPeer  uint
 	flagNumRepl  uint
 
-	flagEnableEBT bool
+	flagEnableEBT      bool
+	flagEBTIdleTimeout time.Duration
+	flagEBTMaxSessions uint
 
 	flagDisableUNIXSock bool
+	flagUNIXSockPath    string
+
+	flagPeersFile string
 
 	repoDir     string
 	listenAddr  string
@@ -71,8 +76,9 @@ var (
 	checkFatal = logging.CheckFatal
 
 	// juicy bits
-	appKey  string
-	hmacSec string
+	flagNetwork string
+	appKey      string
+	hmacSec     string
 
 	//go:embed default-config.toml
 	defaultConfig string
@@ -84,6 +90,7 @@ var (
 	Build   = ""
 
 	flagPrintVersion bool
+	flagPrintConfig  bool
 )
 
 const DEFAULT_GO_SSB_DIR string = ".ssb-go"
@@ -106,6 +113,7 @@ func initFlags() {
 	flag.UintVar(&flagHops, "hops", 1, "how many hops to fetch (1: friends, 2:friends of friends)")
 	flag.BoolVar(&flagPromisc, "promisc", false, "bypass graph auth and fetch remote's feed")
 
+	flag.StringVar(&flagNetwork, "network", "", fmt.Sprintf("pick the shscap and hmac by network name %v, can't be combined with -shscap or -hmac", mksbot.NetworkNames()))
 	flag.StringVar(&appKey, "shscap", "1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=", "secret-handshake app-key (or capability)")
 	flag.StringVar(&hmacSec, "hmac", "", "if set, sign with hmac hash of msg, instead of plain message object, using this key")
 
@@ -118,8 +126,13 @@ func initFlags() {
 	flag.StringVar(&wsTLSKey, "wstlskey", "", "tls key file for ssb-ws connections")
 
 	flag.BoolVar(&flagEnableEBT, "enable-ebt", false, "enable syncing by using epidemic-broadcast-trees (new code, test with caution)")
+	flag.DurationVar(&flagEBTIdleTimeout, "ebt-idle-timeout", 0, "close ebt sessions that didn't receive anything for this long and fall back to legacy gossip (0 disables it)")
+	flag.UintVar(&flagEBTMaxSessions, "ebt-max-sessions", 0, "limit the number of concurrent ebt sessions, peers over it are replicated with legacy gossip (0 means no limit)")
 
 	flag.BoolVar(&flagDisableUNIXSock, "nounixsock", false, "disable the UNIX socket RPC interface")
+	flag.StringVar(&flagUNIXSockPath, "unixsock", "", "where to put the UNIX socket (defaults to $repo/socket)")
+
+	flag.StringVar(&flagPeersFile, "peers", "", "toml file with a connection policy (always, never or default) per peer; relative to the config file")
 
 	flag.StringVar(&repoDir, "repo", filepath.Join(u.HomeDir, DEFAULT_GO_SSB_DIR), "where to put the log and indexes")
 
@@ -132,15 +145,27 @@ func initFlags() {
 
 	flag.BoolVar(&flagCleanup, "cleanup", false, "remove blocked feeds")
 
-	flag.StringVar(&flagFSCK, "fsck", "", "run a filesystem check on the repo (possible values: length, sequences)")
+	flag.StringVar(&flagFSCK, "fsck", "", "run a filesystem check on the repo (possible values: length, sequences, repo)")
 	flag.BoolVar(&flagRepair, "repair", false, "run repo healing if fsck fails")
 
 	flag.BoolVar(&flagPrintVersion, "version", false, "print version number and build date")
+	flag.BoolVar(&flagPrintConfig, "print-config", false, "print the effective configuration, with where each value came from, and exit")
 
 	flag.Parse()
 }
 
-func applyConfigValues() {
+// returns true if the named flag was passed to go-sbot on startup
+func isFlagPassed(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func applyConfigValues() SbotConfig {
 	/*
 	 It's config & environment variable reading time! We read the config and/or any set environment variables first.
 	 Then, for each flag that has NOT been set and which corresponds to a config/env value, we set the flag variable's
@@ -153,17 +178,6 @@ func applyConfigValues() {
 	 * default flag values are the final fallback, if the corresponding config value or environment variable has not been
 	   set
 	*/
-	// returns true if the named flag was passed to go-sbot on startup
-	isFlagPassed := func(name string) bool {
-		found := false
-		flag.Visit(func(f *flag.Flag) {
-			if f.Name == name {
-				found = true
-			}
-		})
-		return found
-	}
-
 	/* order of looking for a config file:
 	* 1. $SSB_CONFIG_FILE or --config passed
 	* 2. --repo is passed (=> used as configdir)
@@ -186,6 +200,12 @@ func applyConfigValues() {
 	configDir := filepath.Dir(configPath)
 	config, exists := readConfigAndEnv(configPath)
 
+	// only look, don't touch
+	if flagPrintConfig {
+		applyConfig(config)
+		return config
+	}
+
 	if !exists {
 		err := os.WriteFile(configPath, []byte(defaultConfig), 0644)
 		if err != nil {
@@ -205,6 +225,13 @@ func applyConfigValues() {
 	} else {
 		level.Info(log).Log("event", "write running-config.json", "msg", "active config and env vars have been persisted", "path", runningConfigPath)
 	}
+
+	applyConfig(config)
+	return config
+}
+
+// applyConfig sets the flag variables to the values of config, if the flag wasn't passed
+func applyConfig(config SbotConfig) {
 	// Returns true if the config has a value for flagname set, and the flag itself isn't passed on invocation
 	UseConfigValue := func(flagname string) bool {
 		return config.Has(flagname) && !isFlagPassed(flagname)
@@ -222,6 +249,9 @@ func applyConfigValues() {
 	if UseConfigValue("promisc") {
 		flagPromisc = (bool)(config.EnableFirewall)
 	}
+	if UseConfigValue("network") {
+		flagNetwork = config.Network
+	}
 	if UseConfigValue("shscap") {
 		appKey = config.ShsCap
 	}
@@ -249,12 +279,26 @@ func applyConfigValues() {
 	if UseConfigValue("enable-ebt") {
 		flagEnableEBT = (bool)(config.EnableEBT)
 	}
+	if UseConfigValue("ebt-idle-timeout") {
+		d, err := time.ParseDuration(config.EBTIdleTimeout)
+		check(err, "parse ebt-idle-timeout")
+		flagEBTIdleTimeout = d
+	}
+	if UseConfigValue("ebt-max-sessions") {
+		flagEBTMaxSessions = config.EBTMaxSessions
+	}
 	if UseConfigValue("nounixsock") {
 		flagDisableUNIXSock = (bool)(config.NoUnixSocket)
 	}
+	if UseConfigValue("unixsock") {
+		flagUNIXSockPath = config.UnixSocket
+	}
 	if UseConfigValue("hmac") {
 		hmacSec = config.Hmac
 	}
+	if UseConfigValue("peers") {
+		flagPeersFile = config.PeersFile
+	}
 	if UseConfigValue("debugdir") {
 		debugLogDir = config.DebugDir
 	}
@@ -266,6 +310,22 @@ func applyConfigValues() {
 	}
 }
 
+// networkOption picks the network by name if -network is set, otherwise it uses the shscap
+func networkOption() (mksbot.Option, error) {
+	if flagNetwork == "" {
+		ak, err := base64.StdEncoding.DecodeString(appKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid application key/shs-cap: %w", err)
+		}
+		return mksbot.WithAppKey(ak), nil
+	}
+
+	if appKey != flag.Lookup("shscap").DefValue || hmacSec != "" {
+		return nil, fmt.Errorf("network %q sets the shscap and hmac, they can't be set as well", flagNetwork)
+	}
+	return mksbot.WithNetwork(flagNetwork), nil
+}
+
 func runSbot() error {
 	initFlags()
 
@@ -290,7 +350,11 @@ func runSbot() error {
 
 	// try to read config && environment variables, and apply any set values on variables that
 	// have not been explicitly configured using flags on startup
-	applyConfigValues()
+	config := applyConfigValues()
+
+	if flagPrintConfig {
+		return printConfig(os.Stdout, config)
+	}
 
 	// add a log on is used by the sbot to aid ambient debugging for operators
 	absRepo, err := filepath.Abs(repoDir)
@@ -313,17 +377,18 @@ func runSbot() error {
 		//logging.SetupLogging(os.Stderr)
 	}
 
-	ak, err := base64.StdEncoding.DecodeString(appKey)
+	networkOpt, err := networkOption()
 	if err != nil {
-		return fmt.Errorf("invalid application key/shs-cap: %w", err)
+		return err
 	}
 
-	startDebug()
+	metricsReg := startDebug()
 	opts := []mksbot.Option{
 		mksbot.WithHops(flagHops),
 		mksbot.WithPromisc(flagPromisc),
 		mksbot.WithInfo(log),
-		mksbot.WithAppKey(ak),
+		mksbot.WithVersion(Version, Build),
+		networkOpt,
 		mksbot.WithRepoPath(repoDir),
 		mksbot.WithListenAddr(listenAddr),
 		mksbot.EnableAdvertismentBroadcasts(flagEnAdv),
@@ -335,11 +400,16 @@ func runSbot() error {
 		mksbot.DisableLegacyLiveReplication(true),
 		// new code, test with caution
 		mksbot.DisableEBT(!flagEnableEBT),
+		mksbot.WithEBTIdleTimeout(flagEBTIdleTimeout),
+		mksbot.WithEBTMaxSessions(int(flagEBTMaxSessions), 0),
 		mksbot.WithNumberOfConcurrentReplicationsPerPeer(flagNumPeer),
 		mksbot.WithNumberOfConcurrentReplications(flagNumRepl),
 	}
 
 	if !flagDisableUNIXSock {
+		if flagUNIXSockPath != "" {
+			opts = append(opts, mksbot.WithUNIXSocketPath(flagUNIXSockPath))
+		}
 		opts = append(opts, mksbot.LateOption(mksbot.WithUNIXSocket()))
 	}
 
@@ -362,9 +432,9 @@ func runSbot() error {
 		}))
 	}
 
-	if debugAddr != "" {
+	if metricsReg != nil {
 		opts = append(opts,
-			mksbot.WithEventMetrics(SystemEvents, RepoStats, SystemSummary),
+			mksbot.WithMetricsRegistry(metricsReg),
 			mksbot.WithPreSecureConnWrapper(promCountConn()),
 		)
 	}
@@ -377,10 +447,21 @@ func runSbot() error {
 		opts = append(opts, mksbot.WithHMACSigning(hcbytes))
 	}
 
+	if flagPeersFile != "" {
+		policies, err := loadPeerPolicies(peersFilePath(flagPeersFile))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, mksbot.WithPeerPolicies(policies...))
+	}
+
 	sbot, err := mksbot.New(opts...)
 	if err != nil {
 		return fmt.Errorf("failed to instantiate ssb server: %w", err)
 	}
+	if sbot.Metrics != nil {
+		SystemEvents = sbot.Metrics.Events
+	}
 
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -399,6 +480,9 @@ func runSbot() error {
 	}()
 	logging.SetCloseChan(c)
 
+	// apply changes to some of the settings without restarting
+	go reloadOnHangup(ctx, sbot)
+
 	id := sbot.KeyPair.ID()
 	uf, ok := sbot.GetMultiLog(multilogs.IndexNameFeeds)
 	if !ok {
@@ -417,6 +501,9 @@ func runSbot() error {
 			fsckMode = mksbot.FSCKModeSequences
 		case "length":
 			fsckMode = mksbot.FSCKModeLength
+		case "repo":
+			// only changes the repo if -repair is set
+			return checkRepo(sbot, flagRepair)
 		default:
 			return fmt.Errorf("unknown fsck mode: %q", flagFSCK)
 		}
@@ -471,20 +558,26 @@ func runSbot() error {
 		checkAndLog(err)
 		return nil
 	}
-	SystemEvents.With("event", "openedRepo").Add(1)
 	// establish message anf feed numbers in the repo
 
 	feeds, err := uf.List()
 	if err != nil {
 		return fmt.Errorf("user feed: %w", err)
 	}
-	RepoStats.With("part", "feeds").Set(float64(len(feeds)))
 
 	msgCount := sbot.ReceiveLog.Seq() + 1
-	RepoStats.With("part", "msgs").Set(float64(msgCount))
 
 	level.Info(log).Log("event", "repo open", "feeds", len(feeds), "msgs", msgCount)
 
+	if m := sbot.Metrics; m != nil {
+		m.Events.With("event", "openedRepo").Add(1)
+
+		m.Repo.With("part", "feeds").Set(float64(len(feeds)))
+		m.Repo.With("part", "msgs").Set(float64(msgCount))
+
+		startDiskUsageGauge(ctx, sbot)
+	}
+
 	if flagReindex {
 		level.Warn(log).Log("mode", "reindexing")
 		if fsckMode != mksbot.FSCKModeSequences {
@@ -540,7 +633,9 @@ func runSbot() error {
 		if err != nil {
 			level.Warn(log).Log("event", "sbot node.Serve returned", "err", err)
 		}
-		SystemEvents.With("event", "nodeServ exited").Add(1)
+		if SystemEvents != nil {
+			SystemEvents.With("event", "nodeServ exited").Add(1)
+		}
 		time.Sleep(1 * time.Second)
 		select {
 		case <-ctx.Done():
@@ -5,23 +5,25 @@
 package main
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"time"
 
-	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/kit/metrics"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/ssbc/go-netwrap"
+	"go.mindeco.de/log/level"
 	"go.mindeco.de/logging/countconn"
-)
 
-var (
-	SystemEvents  *prometheus.Counter
-	SystemSummary *prometheus.Summary
-	RepoStats     *prometheus.Gauge
+	mksbot "github.com/ssbc/go-ssb/sbot"
 )
 
+// SystemEvents is the event counter of the sbot, once it is opened with a metrics registry
+var SystemEvents metrics.Counter
+
 //	muxrpcSummary *prometheus.Summary
 
 /*
@@ -53,41 +55,71 @@ func HandlerWithLatency(s *prometheus.Summary) muxrpc.HandlerWrapper {
 }
 */
 
-func startDebug() {
-	if debugAddr == "" {
+// diskUsageInterval is how often the bytes-* parts of the repo gauge are measured again
+const diskUsageInterval = 10 * time.Minute
+
+// startDiskUsageGauge measures the disk usage of the repo of sbot now and then every diskUsageInterval, until ctx is done.
+// It needs the metrics of the sbot.
+func startDiskUsageGauge(ctx context.Context, sbot *mksbot.Sbot) {
+	go func() {
+		updateDiskUsage(sbot)
+
+		tick := time.NewTicker(diskUsageInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				updateDiskUsage(sbot)
+			}
+		}
+	}()
+}
+
+// updateDiskUsage sets the bytes-* parts of the repo gauge. It walks the whole repo, errors are only logged.
+func updateDiskUsage(sbot *mksbot.Sbot) {
+	du, err := sbot.DiskUsage()
+	if err != nil {
+		level.Warn(log).Log("event", "disk usage failed", "err", err)
 		return
 	}
 
-	SystemEvents = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
-		Namespace: "gossb",
-		Subsystem: "events",
-		Name:      "ssb_sysevents",
-	}, []string{"event"})
-
-	RepoStats = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
-		Namespace: "gossb",
-		Subsystem: "repo",
-		Name:      "ssb_repostats",
-	}, []string{"part"})
-
-	// muxrpcSummary = prometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
-	// 	Namespace: "gossb",
-	// 	Subsystem: "muxrpc",
-	// 	Name:      "muxrpc_durrations_seconds",
-	// }, []string{"method", "type", "error"})
-
-	SystemSummary = prometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
-		Namespace: "gossb",
-		Subsystem: "sbot",
-		Name:      "general_durrations",
-	}, []string{"part"})
+	m := sbot.Metrics
+	m.Repo.With("part", "bytes-log").Set(float64(du.Log))
+	m.Repo.With("part", "bytes-blobs").Set(float64(du.Blobs))
+	m.Repo.With("part", "bytes-statematrix").Set(float64(du.StateMatrix))
+	m.Repo.With("part", "bytes-other").Set(float64(du.Other))
+	for idx, size := range du.Indexes {
+		m.Repo.With("part", "bytes-"+idx).Set(float64(size))
+	}
+}
 
+// startDebug serves the metrics on debugAddr. It returns the registry for sbot.WithMetricsRegistry or nil, if debugAddr is empty.
+func startDebug() *stdprometheus.Registry {
+	if debugAddr == "" {
+		return nil
+	}
+
+	reg := newMetricsRegistry()
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 		log.Log("starting", "metrics", "addr", debugAddr)
 		err := http.ListenAndServe(debugAddr, nil)
 		checkAndLog(err)
 	}()
+	return reg
+}
+
+// newMetricsRegistry returns a registry of its own with the Go runtime and process collectors, the sbot adds its metrics to it.
+// Only what is registered there is exported, nothing that ends up in the global default registry.
+func newMetricsRegistry() *stdprometheus.Registry {
+	reg := stdprometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
 }
 
 /* TODO: refactor for luigi-less api
@@ -235,6 +267,9 @@ func promCountConn() netwrap.ConnWrapper {
 
 func (c *promCount) Close() error {
 	err := c.conn.Close()
+	if SystemEvents == nil {
+		return err
+	}
 	SystemEvents.With("event", "bytes.tx").Add(float64(c.Writer.N()))
 	SystemEvents.With("event", "bytes.rx").Add(float64(c.Reader.N()))
 	return err
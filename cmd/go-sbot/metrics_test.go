@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2023 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/require"
+
+	mksbot "github.com/ssbc/go-ssb/sbot"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	r := require.New(t)
+
+	// each registry is its own, so setting them up twice doesn't collide
+	_, err := mksbot.NewMetrics(newMetricsRegistry())
+	r.NoError(err)
+	reg := newMetricsRegistry()
+	m, err := mksbot.NewMetrics(reg)
+	r.NoError(err)
+
+	m.Events.With("event", "test").Add(1)
+	m.Repo.With("part", "test").Set(23)
+
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	r.Equal(http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	r.Contains(body, "go_goroutines")
+	r.Contains(body, "go_gc_duration_seconds")
+	if runtime.GOOS == "linux" {
+		r.Contains(body, "process_resident_memory_bytes")
+	}
+	r.Contains(body, `gossb_events_ssb_sysevents{event="test"} 1`)
+	r.Contains(body, `gossb_repo_ssb_repostats{part="test"} 23`)
+}
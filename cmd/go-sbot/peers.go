@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2023 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/komkom/toml"
+	refs "github.com/ssbc/go-ssb-refs"
+
+	mksbot "github.com/ssbc/go-ssb/sbot"
+)
+
+// peersFile is the format of the peers file, with one [[peer]] table per peer:
+//
+//	[[peer]]
+//	id = "@...=.ed25519"
+//	policy = "always"
+//	address = "net:example.org:8008~shs:..."
+type peersFile struct {
+	Peers []struct {
+		ID      string `json:"id"`
+		Policy  string `json:"policy"`
+		Address string `json:"address"`
+	} `json:"peer"`
+}
+
+// peersFilePath resolves the peers setting, relative paths are relative to the folder of the config file
+func peersFilePath(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(configPath), p)
+}
+
+// loadPeerPolicies reads the connection policies per peer from the toml file at path
+func loadPeerPolicies(path string) ([]mksbot.PeerPolicy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, eout(err, "open peers file")
+	}
+	defer f.Close()
+
+	var pf peersFile
+	if err := json.NewDecoder(toml.New(f)).Decode(&pf); err != nil {
+		return nil, eout(err, "decode peers file %s", path)
+	}
+
+	policies := make([]mksbot.PeerPolicy, len(pf.Peers))
+	for i, p := range pf.Peers {
+		ref, err := refs.ParseFeedRef(p.ID)
+		if err != nil {
+			return nil, eout(err, "invalid id of peer %d in %s", i+1, path)
+		}
+
+		policy := mksbot.ConnPolicy(p.Policy)
+		if policy == "" {
+			policy = mksbot.ConnPolicyDefault
+		}
+		switch policy {
+		case mksbot.ConnPolicyDefault, mksbot.ConnPolicyAlways, mksbot.ConnPolicyNever:
+		default:
+			return nil, fmt.Errorf("invalid policy %q for %s in %s (always, never or default)", p.Policy, p.ID, path)
+		}
+
+		policies[i] = mksbot.PeerPolicy{Peer: ref, Policy: policy, Addr: p.Address}
+	}
+	return policies, nil
+}
+
+// reloadPeerPolicies reads the peers file again and replaces the policies of bot. Without a peers file they are cleared.
+func reloadPeerPolicies(bot liveSettings) error {
+	var policies []mksbot.PeerPolicy
+	if flagPeersFile != "" {
+		var err error
+		policies, err = loadPeerPolicies(peersFilePath(flagPeersFile))
+		if err != nil {
+			return err
+		}
+	}
+	return bot.SetPeerPolicies(policies)
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// printConfig writes the effective settings as TOML to w, with a comment where each of them came from.
+// config is the merged file and environment configuration, the values are taken from the flag variables after applyConfig.
+func printConfig(w io.Writer, config SbotConfig) error {
+	// which of the values came from the environment
+	fromEnv := SbotConfig{presence: make(map[string]interface{})}
+	ReadEnvironmentVariables(&fromEnv)
+
+	source := func(name string) string {
+		switch {
+		case isFlagPassed(name):
+			return "flag"
+		case fromEnv.Has(name):
+			return "env"
+		case name == "debuglis" && fromEnv.Has("enable-metrics"):
+			return "env"
+		case config.Has(name):
+			return "file"
+		default:
+			return "default"
+		}
+	}
+
+	secret := func(v string) string {
+		if v == "" {
+			return strconv.Quote(v)
+		}
+		return strconv.Quote("<redacted>")
+	}
+
+	var settings = []struct {
+		name  string
+		value string
+	}{
+		{"repo", strconv.Quote(repoDir)},
+		{"debugdir", strconv.Quote(debugLogDir)},
+		{"network", strconv.Quote(flagNetwork)},
+		{"shscap", secret(appKey)},
+		{"hmac", secret(hmacSec)},
+		{"hops", strconv.FormatUint(uint64(flagHops), 10)},
+		{"numPeer", strconv.FormatUint(uint64(flagNumPeer), 10)},
+		{"numRepl", strconv.FormatUint(uint64(flagNumRepl), 10)},
+		{"lis", strconv.Quote(listenAddr)},
+		{"wslis", strconv.Quote(wsLisAddr)},
+		{"wstlscert", strconv.Quote(wsTLSCert)},
+		{"wstlskey", strconv.Quote(wsTLSKey)},
+		{"debuglis", strconv.Quote(debugAddr)},
+		{"localadv", strconv.FormatBool(flagEnAdv)},
+		{"localdiscov", strconv.FormatBool(flagEnDiscov)},
+		{"enable-ebt", strconv.FormatBool(flagEnableEBT)},
+		{"ebt-idle-timeout", strconv.Quote(flagEBTIdleTimeout.String())},
+		{"ebt-max-sessions", strconv.FormatUint(uint64(flagEBTMaxSessions), 10)},
+		{"promisc", strconv.FormatBool(flagPromisc)},
+		{"nounixsock", strconv.FormatBool(flagDisableUNIXSock)},
+		{"unixsock", strconv.Quote(flagUNIXSockPath)},
+		{"peers", strconv.Quote(flagPeersFile)},
+		{"repair", strconv.FormatBool(flagRepair)},
+	}
+
+	if _, err := fmt.Fprintf(w, "# effective configuration, read from %s\n", configPath); err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if _, err := fmt.Fprintf(w, "%s = %s # %s\n", s.name, s.value, source(s.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
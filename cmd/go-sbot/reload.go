@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2023 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.mindeco.de/log/level"
+
+	mksbot "github.com/ssbc/go-ssb/sbot"
+)
+
+// liveSettings are the settings of a running sbot that can be changed without a restart
+type liveSettings interface {
+	SetHops(uint)
+	SetPromisc(bool)
+	SetConcurrentReplications(perPeer, total uint)
+	SetPeerPolicies([]mksbot.PeerPolicy) error
+}
+
+// reloadOnHangup reads the config file and environment variables again every time the process receives SIGHUP
+// and applies the settings that can be changed while running.
+func reloadOnHangup(ctx context.Context, bot liveSettings) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+		}
+
+		config, err := reloadConfigAndEnv(configPath)
+		if err != nil {
+			level.Error(log).Log("event", "config reload failed", "path", configPath, "err", err)
+			continue
+		}
+
+		applied, ignored := applyLiveConfig(bot, config)
+		level.Info(log).Log("event", "config reloaded",
+			"path", configPath,
+			"applied", strings.Join(applied, ","),
+			"needs-restart", strings.Join(ignored, ","))
+	}
+}
+
+// applyLiveConfig compares config with the running settings and applies the changes that are safe to make while running.
+// The others are logged and ignored. Like on startup, flags that were passed take precedence and
+// settings that are missing from config keep their current value.
+func applyLiveConfig(bot liveSettings, config SbotConfig) (applied, ignored []string) {
+	useConfigValue := func(flagname string) bool {
+		return config.Has(flagname) && !isFlagPassed(flagname)
+	}
+
+	if useConfigValue("hops") && config.Hops != flagHops {
+		level.Info(log).Log("event", "config reload", "setting", "hops", "old", flagHops, "new", config.Hops)
+		flagHops = config.Hops
+		bot.SetHops(flagHops)
+		applied = append(applied, "hops")
+	}
+
+	if useConfigValue("promisc") && bool(config.EnableFirewall) != flagPromisc {
+		level.Info(log).Log("event", "config reload", "setting", "promisc", "old", flagPromisc, "new", config.EnableFirewall)
+		flagPromisc = bool(config.EnableFirewall)
+		bot.SetPromisc(flagPromisc)
+		applied = append(applied, "promisc")
+	}
+
+	var perPeer, total uint
+	if useConfigValue("numPeer") && config.NumPeer != flagNumPeer {
+		level.Info(log).Log("event", "config reload", "setting", "numPeer", "old", flagNumPeer, "new", config.NumPeer)
+		flagNumPeer = config.NumPeer
+		perPeer = flagNumPeer
+		applied = append(applied, "numPeer")
+	}
+	if useConfigValue("numRepl") && config.NumRepl != flagNumRepl {
+		level.Info(log).Log("event", "config reload", "setting", "numRepl", "old", flagNumRepl, "new", config.NumRepl)
+		flagNumRepl = config.NumRepl
+		total = flagNumRepl
+		applied = append(applied, "numRepl")
+	}
+	if perPeer > 0 || total > 0 {
+		bot.SetConcurrentReplications(perPeer, total)
+	}
+
+	// the peers file is read again, even if its path didn't change
+	peersChanged := useConfigValue("peers") && config.PeersFile != flagPeersFile
+	oldPeersFile := flagPeersFile
+	if peersChanged {
+		level.Info(log).Log("event", "config reload", "setting", "peers", "old", flagPeersFile, "new", config.PeersFile)
+		flagPeersFile = config.PeersFile
+	}
+	if flagPeersFile != "" || peersChanged {
+		if err := reloadPeerPolicies(bot); err != nil {
+			level.Error(log).Log("event", "config reload", "setting", "peers", "msg", "keeping the current policies", "err", err)
+			flagPeersFile = oldPeersFile
+			ignored = append(ignored, "peers")
+		} else {
+			applied = append(applied, "peers")
+		}
+	}
+
+	ebtIdleTimeout := config.EBTIdleTimeout
+	if d, err := time.ParseDuration(config.EBTIdleTimeout); err == nil {
+		ebtIdleTimeout = d.String()
+	}
+
+	var restartOnly = []struct {
+		name          string
+		current, next interface{}
+	}{
+		{"repo", repoDir, config.Repo},
+		{"lis", listenAddr, config.MuxRPCAddress},
+		{"wslis", wsLisAddr, config.WebsocketAddress},
+		{"wstlscert", wsTLSCert, config.WebsocketTLSCert},
+		{"wstlskey", wsTLSKey, config.WebsocketTLSKey},
+		{"debuglis", debugAddr, config.MetricsAddress},
+		{"debugdir", debugLogDir, config.DebugDir},
+		{"network", flagNetwork, config.Network},
+		{"shscap", appKey, config.ShsCap},
+		{"hmac", hmacSec, config.Hmac},
+		{"localadv", flagEnAdv, bool(config.EnableAdvertiseUDP)},
+		{"localdiscov", flagEnDiscov, bool(config.EnableDiscoveryUDP)},
+		{"enable-ebt", flagEnableEBT, bool(config.EnableEBT)},
+		{"ebt-idle-timeout", flagEBTIdleTimeout.String(), ebtIdleTimeout},
+		{"ebt-max-sessions", flagEBTMaxSessions, config.EBTMaxSessions},
+		{"nounixsock", flagDisableUNIXSock, bool(config.NoUnixSocket)},
+		{"unixsock", flagUNIXSockPath, config.UnixSocket},
+		{"repair", flagRepair, bool(config.RepairFSBeforeStart)},
+	}
+	for _, s := range restartOnly {
+		if !useConfigValue(s.name) || s.current == s.next {
+			continue
+		}
+
+		// don't put the secrets into the log
+		was, now := fmt.Sprint(s.current), fmt.Sprint(s.next)
+		if s.name == "shscap" || s.name == "hmac" {
+			was, now = "<redacted>", "<redacted>"
+		}
+		level.Warn(log).Log("event", "config reload", "setting", s.name, "old", was, "new", now, "msg", "ignored, changing this setting requires a restart")
+		ignored = append(ignored, s.name)
+	}
+
+	return applied, ignored
+}
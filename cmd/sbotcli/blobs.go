@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/urfave/cli/v2"
@@ -22,19 +21,16 @@ import (
 var blobsStore ssb.BlobStore
 
 var blobsCmd = &cli.Command{
-	Name:  "blobs",
-	Usage: "Add a blob to the local store or call MUXRPC methods: `has`, `get` and `wants`",
+	Name:    "blobs",
+	Aliases: []string{"blob"},
+	Usage:   "Add and get blobs or call MUXRPC methods: `has`, `get` and `wants`",
 	Flags: []cli.Flag{
-		&cli.StringFlag{Name: "path", Value: "", Usage: "Specify the path to the blobs folder of the sbot you want to query"},
+		&cli.StringFlag{Name: "path", Value: "", Usage: "Use the blobs folder at this path directly, instead of going through the sbot"},
 	},
 	Before: func(ctx *cli.Context) error {
 		var blobsDir = ctx.String("path")
 		if blobsDir == "" {
-			homedir, err := os.UserHomeDir()
-			if err != nil {
-				return fmt.Errorf("failed to get home directory (%w)", err)
-			}
-			blobsDir = filepath.Join(homedir, ".ssb-go", "blobs")
+			return nil
 		}
 		if _, err := os.Stat(blobsDir); os.IsNotExist(err) {
 			return fmt.Errorf("folder %s did not exist (%w)", blobsDir, err)
@@ -139,16 +135,13 @@ var blobsAddCmd = &cli.Command{
 	ArgsUsage: "[<filename> | - <stdin>]",
 	Description: `Add a file to the blobstore (pass - to open stdin).
 
-A blob reference will be returned (<&...sha256>) if the file is added successfully.
+The blob reference (<&...sha256>) is printed if the file is added successfully.
 
 Example:
 
     cat /home/glyph/Pictures/2022/cabin_computer_setup.jpeg | sbotcli blobs add -`,
 
 	Action: func(ctx *cli.Context) error {
-		if blobsStore == nil {
-			return fmt.Errorf("no blobstore use 'blobs --path $repo/blobs add -' for now")
-		}
 		fname := ctx.Args().Get(0)
 		if fname == "" {
 			return errors.New("blobs.add: need file to add (- for stdin)")
@@ -158,16 +151,34 @@ Example:
 		if fname == "-" {
 			reader = os.Stdin
 		} else {
-			var err error
-			reader, err = os.Open(fname)
+			f, err := os.Open(fname)
 			if err != nil {
 				return fmt.Errorf("blobs.add: failed to open input file: %w", err)
 			}
+			defer f.Close()
+			reader = f
+		}
+
+		var (
+			ref refs.BlobRef
+			err error
+		)
+		if blobsStore != nil {
+			ref, err = blobsStore.Put(reader)
+		} else {
+			client, cerr := newClient(ctx)
+			if cerr != nil {
+				return cerr
+			}
+			ref, err = client.BlobsAdd(reader)
+		}
+		if err != nil {
+			return fmt.Errorf("blobs.add: %w", err)
 		}
 
-		ref, err := blobsStore.Put(reader)
 		log.Log("blobs.add", ref.Sigil())
-		return err
+		fmt.Fprintln(os.Stdout, ref.Sigil())
+		return nil
 	},
 }
 
@@ -178,18 +189,20 @@ var blobsGetCmd = &cli.Command{
 	Description: `Streams the contents of the file.
 
 Contents are streamed to stdout by default. An alternative destination can be
-defined using the 'out' flag.
+defined using the 'out' flag. Downloads to a file are written to <out>.part
+first, and an interrupted download continues from there when it is started again.
+
+With the 'want' flag the blob is only added to the wants of the sbot, which
+fetches it from its peers in the background.
 
 Example:
 
     sbotcli blobs get "&grLTZFapgHZHXRYh1zgz2bTuDelottGZSfogKauo/fk=.sha256" > blob_file`,
 	Flags: []cli.Flag{
 		&cli.StringFlag{Name: "out", Value: "-", Usage: "Where to? (stdout by default)"},
+		&cli.BoolFlag{Name: "want", Usage: "Only ask the sbot to fetch the blob, don't download it"},
 	},
 	Action: func(ctx *cli.Context) error {
-		if blobsStore == nil {
-			return fmt.Errorf("no blobstore use 'blobs --path $repo/blobs get &...' for now")
-		}
 		ref := ctx.Args().Get(0)
 		if ref == "" {
 			return errors.New("blobs.get: need a blob ref")
@@ -198,25 +211,59 @@ Example:
 		if err != nil {
 			return fmt.Errorf("blobs: failed to parse argument ref: %w", err)
 		}
-		reader, err := blobsStore.Get(blobsRef)
+		outName := ctx.String("out")
+
+		if blobsStore != nil {
+			if ctx.Bool("want") {
+				return errors.New("blobs.get: want needs the sbot, don't use --path")
+			}
+			reader, err := blobsStore.Get(blobsRef)
+			if err != nil {
+				return fmt.Errorf("blobs: failed to retrieve blob from store: %w", err)
+			}
+			defer reader.Close()
+			return writeBlob(blobsRef, reader, outName)
+		}
+
+		client, err := newClient(ctx)
 		if err != nil {
-			return fmt.Errorf("blobs: failed to retrieve blob from store: %w", err)
+			return err
 		}
 
-		var out io.Writer
-		outName := ctx.String("out")
-		if outName == "-" {
-			out = os.Stdout
-		} else {
-			var err error
-			out, err = os.Create(outName)
-			if err != nil {
-				return fmt.Errorf("blobs.get: failed to open output file: %w", err)
+		if ctx.Bool("want") {
+			return client.BlobsWant(blobsRef)
+		}
+
+		if outName != "-" {
+			if err := client.BlobsDownload(blobsRef, outName); err != nil {
+				return fmt.Errorf("blobs.get: %w", err)
 			}
+			log.Log("blobs.get", blobsRef.Sigil(), "written", outName)
+			return nil
 		}
 
-		n, err := io.Copy(out, reader)
-		log.Log("blobs.get", blobsRef.Sigil(), "written", n)
-		return err
+		reader, err := client.BlobsGet(blobsRef)
+		if err != nil {
+			return err
+		}
+		return writeBlob(blobsRef, reader, outName)
 	},
 }
+
+func writeBlob(ref refs.BlobRef, reader io.Reader, outName string) error {
+	var out io.Writer
+	if outName == "-" {
+		out = os.Stdout
+	} else {
+		f, err := os.Create(outName)
+		if err != nil {
+			return fmt.Errorf("blobs.get: failed to open output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	n, err := io.Copy(out, reader)
+	log.Log("blobs.get", ref.Sigil(), "written", n)
+	return err
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	cli "github.com/urfave/cli/v2"
+
+	"github.com/ssbc/go-ssb/network"
+)
+
+var discoveredCmd = &cli.Command{
+	Name:  "discovered",
+	Usage: "List the peers that were recently found on the local network",
+	Description: `List the peers that were recently found on the local network.
+
+Each line has the multiserver address of the peer, which can be passed to
+connect, its name (if one is known) and when it was last seen. This needs
+local discovery to be enabled on the sbot (localdiscov).`,
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var peers []network.DiscoveredPeer
+		err = client.Async(longctx, &peers, muxrpc.TypeJSON, muxrpc.Method{"conn", "discovered"})
+		if err != nil {
+			return fmt.Errorf("discovered: call failed: %w", err)
+		}
+
+		for _, p := range peers {
+			fmt.Printf("%s\t%s\t(seen %s ago)\n", p.Address(), withName(client, p.ID), time.Since(p.LastSeen).Round(time.Second))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/urfave/cli/v2"
+)
+
+var diskUsageCmd = &cli.Command{
+	Name:  "diskusage",
+	Usage: "Show how much disk space the parts of the repo use",
+	Description: `Show how much disk space the parts of the repo use.
+
+It lists the offset log with all the messages, every index, the blob store and
+the ebt state matrix. Everything else in the repo, like the keys, is summed up
+as other.
+
+Example:
+
+    sbotcli diskusage
+    sbotcli diskusage --format json`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "Output format (text or json)"},
+	},
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		report, err := client.DiskUsage()
+		if err != nil {
+			return err
+		}
+
+		switch f := ctx.String("format"); f {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+
+		case "text":
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			line := func(name string, size int64) {
+				fmt.Fprintf(tw, "%s\t%s\n", name, humanize.Bytes(uint64(size)))
+			}
+
+			line("log", report.Log)
+			idxs := make([]string, 0, len(report.Indexes))
+			for name := range report.Indexes {
+				idxs = append(idxs, name)
+			}
+			sort.Strings(idxs)
+			for _, name := range idxs {
+				line(name, report.Indexes[name])
+			}
+			line("blobs", report.Blobs)
+			line("ebt-state-matrix", report.StateMatrix)
+			line("other", report.Other)
+			line("total", report.Total)
+			return tw.Flush()
+
+		default:
+			return fmt.Errorf("diskusage: unknown format %q", f)
+		}
+	},
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ssbc/go-ssb/plugins/ebt"
+)
+
+var ebtCmd = &cli.Command{
+	Name:  "ebt",
+	Usage: "Inspect epidemic-broadcast-trees (EBT) replication",
+	Subcommands: []*cli.Command{
+		ebtSessionsCmd,
+	},
+}
+
+var ebtSessionsCmd = &cli.Command{
+	Name:  "sessions",
+	Usage: "List the open EBT sessions and the peers we are waiting for",
+	Description: `List the open EBT sessions and the peers we are waiting for.
+
+For each peer it prints the negotiated version and feed format, the number of
+feeds that are streamed to it, when it last sent something and whether we are
+still waiting for it to start a session.
+
+Example:
+
+    sbotcli ebt sessions`,
+
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var sessions []ebt.SessionInfo
+		err = client.Async(longctx, &sessions, muxrpc.TypeJSON, muxrpc.Method{"ebt", "sessions"})
+		if err != nil {
+			return fmt.Errorf("ebt.sessions: async call failed: %w", err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sessions)
+	},
+}
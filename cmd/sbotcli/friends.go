@@ -5,6 +5,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -23,6 +24,7 @@ var friendsCmd = &cli.Command{
 		friendsIsFollowingCmd,
 		friendsBlocksCmd,
 		friendsHopsCmd,
+		friendsSubgraphCmd,
 	},
 }
 
@@ -124,6 +126,67 @@ Example:
 	},
 }
 
+var friendsSubgraphCmd = &cli.Command{
+	Name:      "subgraph",
+	Usage:     "Print the part of the social graph around the given feed ID",
+	ArgsUsage: "<@...ed25519>",
+	Description: `Print the part of the social graph around the given feed ID.
+
+It contains the feeds that can be reached within <hops> follows and all the
+follows, blocks and metafeed relations between them. The output is either JSON
+or a graphviz dot file. Without an argument the feed of the connected sbot is used.
+
+Example:
+
+    sbotcli friends subgraph --hops 2 --format dot @HEqy940T6uB+T+d9Jaa58aNfRzLx9eRWqkZljBmnkmk=.ed25519 | dot -Tsvg > graph.svg`,
+	Flags: []cli.Flag{
+		&cli.UintFlag{Name: "hops", Value: 1, Usage: "Number of follows to walk from the feed"},
+		&cli.StringFlag{Name: "format", Value: "json", Usage: "Output format (json or dot)"},
+	},
+	Action: func(ctx *cli.Context) error {
+		var arg friends.SubgraphArgs
+
+		arg.Hops = ctx.Uint("hops")
+		arg.Format = ctx.String("format")
+
+		if who := ctx.Args().Get(0); who != "" {
+			centerRef, err := refs.ParseFeedRef(who)
+			if err != nil {
+				return err
+			}
+			arg.Center = &centerRef
+		}
+
+		return printSubgraph(ctx, arg)
+	},
+}
+
+// printSubgraph calls friends.subgraph and prints the JSON or dot it returns, for friends subgraph and graph
+func printSubgraph(ctx *cli.Context, arg friends.SubgraphArgs) error {
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if arg.Format == "dot" {
+		var dot string
+		err = client.Async(longctx, &dot, muxrpc.TypeString, muxrpc.Method{"friends", "subgraph"}, arg)
+		if err != nil {
+			return fmt.Errorf("friends.subgraph: async call failed: %w", err)
+		}
+		_, err = fmt.Fprintln(os.Stdout, dot)
+		return err
+	}
+
+	var sub json.RawMessage
+	err = client.Async(longctx, &sub, muxrpc.TypeJSON, muxrpc.Method{"friends", "subgraph"}, arg)
+	if err != nil {
+		return fmt.Errorf("friends.subgraph: async call failed: %w", err)
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(sub))
+	return err
+}
+
 var friendsBlocksCmd = &cli.Command{
 	Name:      "blocks",
 	Usage:     "List all peers blocked by the given feed ID",
@@ -158,3 +221,39 @@ Example:
 		return err
 	},
 }
+
+var mutualsCmd = &cli.Command{
+	Name:      "mutuals",
+	Usage:     "List all peers that follow the given feed ID and are followed back",
+	ArgsUsage: "<@...ed25519>",
+	Description: `List all peers that follow the given feed ID and are followed back by it.
+Without an argument the feed of the connected sbot is used.
+
+Example:
+
+    sbotcli mutuals @fGWzOR/FXU3Acbn4P65CpMewJIynFyqocvfLAyJdDno=.ed25519`,
+
+	Action: func(ctx *cli.Context) error {
+		var args = []interface{}{}
+
+		if who := ctx.Args().Get(0); who != "" {
+			args = append(args, struct {
+				Who string
+			}{who})
+		}
+
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		src, err := client.Source(longctx, muxrpc.TypeJSON, muxrpc.Method{"friends", "mutuals"}, args...)
+		if err != nil {
+			return err
+		}
+
+		err = jsonDrain(os.Stdout, src)
+		log.Log("done", err)
+		return err
+	},
+}
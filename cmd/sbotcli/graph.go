@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/plugins/friends"
+)
+
+var graphCmd = &cli.Command{
+	Name:  "graph",
+	Usage: "Export the social graph around a feed as graphviz dot or JSON",
+	Description: `Export the social graph around a feed as graphviz dot or JSON.
+
+It contains the feeds that can be reached within <hops> follows from <center>
+and all the follows, blocks and metafeed relations between them. In the dot
+output follows are black, blocks red and metafeed relations green edges.
+The JSON output is an adjacency list, keyed by feed.
+
+<center> defaults to the feed of the connected sbot and <hops> to the feeds it
+replicates.
+
+Example:
+
+    sbotcli graph --hops 2 | dot -Tsvg > graph.svg
+    sbotcli graph --format json --center @HEqy940T6uB+T+d9Jaa58aNfRzLx9eRWqkZljBmnkmk=.ed25519`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "center", Usage: "Feed to start from (default: whoami)"},
+		&cli.UintFlag{Name: "hops", Usage: "Number of follows to walk from the center (default: replication hops)"},
+		&cli.StringFlag{Name: "format", Value: "dot", Usage: "Output format (dot or json)"},
+	},
+	Action: func(ctx *cli.Context) error {
+		var arg friends.SubgraphArgs
+
+		switch f := ctx.String("format"); f {
+		case "dot":
+			arg.Format = "dot"
+		case "json":
+			arg.Format = "adjacency"
+		default:
+			return fmt.Errorf("graph: unsupported format %q", f)
+		}
+
+		if ctx.IsSet("hops") {
+			arg.Hops = ctx.Uint("hops")
+		} else {
+			arg.ReplicationHops = true
+		}
+
+		if center := ctx.String("center"); center != "" {
+			centerRef, err := refs.ParseFeedRef(center)
+			if err != nil {
+				return err
+			}
+			arg.Center = &centerRef
+		}
+
+		return printSubgraph(ctx, arg)
+	},
+}
@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+	"go.cryptoscope.co/nocomment"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+const secretWarning = `WARNING: this is the secret key of your identity.
+Anyone who has it can publish as you and read your private messages.
+Store the backup somewhere safe and never share it. If you lose it, the identity can't be recovered.`
+
+var identityCmd = &cli.Command{
+	Name:  "identity",
+	Usage: "Back up and restore the keypair of an identity",
+	Subcommands: []*cli.Command{
+		identityExportCmd,
+		identityImportCmd,
+	},
+}
+
+var identityExportCmd = &cli.Command{
+	Name:  "export",
+	Usage: "Write the keypair of --key in the secret format of the JavaScript implementation",
+	Description: `Write the keypair of --key in the secret format of the JavaScript implementation.
+
+The output is the secret key of the identity, keep it safe!
+Without --out it is written to stdout.
+
+Example:
+
+    sbotcli identity export --out ~/ssb-backup.secret`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "out", Usage: "Write the keypair to this file, it must not exist yet"},
+	},
+	Action: func(ctx *cli.Context) error {
+		kp, err := ssb.LoadKeyPair(ctx.String("key"))
+		if err != nil {
+			return fmt.Errorf("identity: failed to load keypair: %w", err)
+		}
+		if kp.ID().Algo() != refs.RefAlgoFeedSSB1 {
+			return fmt.Errorf("identity: can only export %s keypairs, not %s", refs.RefAlgoFeedSSB1, kp.ID().Algo())
+		}
+
+		fmt.Fprintln(os.Stderr, secretWarning)
+
+		out := ctx.String("out")
+		if out == "" {
+			return writeSecret(os.Stdout, kp)
+		}
+		if err := createSecret(out, kp); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "exported %s to %s\n", kp.ID().String(), out)
+		return nil
+	},
+}
+
+var identityImportCmd = &cli.Command{
+	Name:      "import",
+	Usage:     "Install a keypair from a backup into a fresh repo",
+	ArgsUsage: "<secret file>",
+	Description: `Install a keypair from a backup into a fresh repo.
+
+It reads the secret format of the JavaScript implementation, as written by
+identity export. The keypair is installed as the secret of --repo, which
+defaults to the folder of --key. It refuses to install into a repo that is
+not empty, to not mix up the identity with the messages of another one.
+
+Example:
+
+    sbotcli identity import ~/ssb-backup.secret`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "repo", Usage: "The repo to install the keypair into (default: the folder of --key)"},
+	},
+	Action: func(ctx *cli.Context) error {
+		src := ctx.Args().First()
+		if src == "" {
+			return errors.New("identity: need a secret file to import")
+		}
+
+		f, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf("identity: failed to open backup: %w", err)
+		}
+		defer f.Close()
+
+		kp, err := ssb.ParseKeyPair(nocomment.NewReader(f))
+		if err != nil {
+			return fmt.Errorf("identity: failed to read backup: %w", err)
+		}
+
+		repo := ctx.String("repo")
+		if repo == "" {
+			repo = filepath.Dir(ctx.String("key"))
+		}
+		entries, err := os.ReadDir(repo)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("identity: failed to check repo: %w", err)
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("identity: repo %s is not empty, refusing to install a keypair into it", repo)
+		}
+
+		if err := os.MkdirAll(repo, 0700); err != nil {
+			return fmt.Errorf("identity: failed to create repo: %w", err)
+		}
+		dst := filepath.Join(repo, "secret")
+		if err := createSecret(dst, kp); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "installed %s to %s\n", kp.ID().String(), dst)
+		return nil
+	},
+}
+
+// createSecret writes kp to a new file at path, which only the owner can read
+func createSecret(path string, kp ssb.KeyPair) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, ssb.SecretPerms)
+	if err != nil {
+		return fmt.Errorf("identity: failed to create secret file: %w", err)
+	}
+	if err := writeSecret(f, kp); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("identity: failed to close secret file: %w", err)
+	}
+	return nil
+}
+
+// writeSecret encodes kp like the secret file of the JavaScript implementation, with its comments around the JSON
+func writeSecret(w io.Writer, kp ssb.KeyPair) error {
+	sec := struct {
+		Curve   string       `json:"curve"`
+		Public  string       `json:"public"`
+		Private string       `json:"private"`
+		ID      refs.FeedRef `json:"id"`
+	}{
+		Curve:   "ed25519",
+		Public:  base64.StdEncoding.EncodeToString(kp.ID().PubKey()) + ".ed25519",
+		Private: base64.StdEncoding.EncodeToString(kp.Secret()) + ".ed25519",
+		ID:      kp.ID(),
+	}
+	data, err := json.MarshalIndent(sec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("identity: failed to encode keypair: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, `# this is your SECRET name.
+# this name gives you magical powers.
+# with it you can mark your messages so that your friends can verify
+# that they really did come from you.
+#
+# if any one learns this name, they can use it to destroy your identity
+# NEVER show this to anyone!!!
+
+%s
+
+# WARNING! It's vital that you DO NOT edit OR share your secret name
+# instead, share your public name
+# your public name: %s
+`, data, kp.ID().String())
+	if err != nil {
+		return fmt.Errorf("identity: failed to write keypair: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/urfave/cli/v2"
+)
+
+var ignoreCmd = &cli.Command{
+	Name:      "ignore",
+	Usage:     "Stop replicating a feed, even if it is within the hop range",
+	ArgsUsage: "<@...ed25519>",
+	Description: `Stop replicating a feed, even if it is within the hop range.
+
+The messages of the feed that are already stored are kept. The ignored feeds
+are remembered by the bot across restarts. Use --undo to replicate the feed
+again and --list to show all the ignored feeds.
+
+Example:
+
+    sbotcli ignore @r6Lzb9OT3/dlVYNDTABmsF+HWnhBsA1twZaobYhjVUY=.ed25519
+    sbotcli ignore --undo @r6Lzb9OT3/dlVYNDTABmsF+HWnhBsA1twZaobYhjVUY=.ed25519
+    sbotcli ignore --list`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "undo", Usage: "replicate the feed again"},
+		&cli.BoolFlag{Name: "list", Usage: "list the ignored feeds"},
+	},
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool("list") {
+			feeds, err := client.IgnoredFeeds()
+			if err != nil {
+				return err
+			}
+			for _, f := range feeds {
+				fmt.Println(f.String())
+			}
+			return nil
+		}
+
+		if ctx.Args().Len() != 1 {
+			return errors.New("ignore: expected one feed reference")
+		}
+		feed, err := refs.ParseFeedRef(ctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("ignore: %w", err)
+		}
+
+		if ctx.Bool("undo") {
+			return client.UnignoreFeed(feed)
+		}
+		return client.IgnoreFeed(feed)
+	},
+}
@@ -7,6 +7,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -17,6 +18,7 @@ import (
 	"os/signal"
 	"os/user"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"syscall"
 	"time"
@@ -52,7 +54,7 @@ var (
 	log kitlog.Logger
 
 	keyFileFlag  = cli.StringFlag{Name: "key,k", Usage: "Secret key file", Value: "unset"}
-	unixSockFlag = cli.StringFlag{Name: "unixsock", Usage: "If set, Unix socket is used instead of TCP"}
+	unixSockFlag = cli.StringFlag{Name: "unixsock", Usage: "Unix socket of the server (default: $SSB_SOCKET_FILE, $SSB_DATA_DIR/socket, ~/.ssb-go/socket or ~/.ssb/socket)"}
 )
 
 func init() {
@@ -60,7 +62,6 @@ func init() {
 	check(err)
 
 	keyFileFlag.Value = filepath.Join(u.HomeDir, ".ssb-go", "secret")
-	unixSockFlag.Value = filepath.Join(u.HomeDir, ".ssb-go", "socket")
 
 	log = term.NewColorLogger(os.Stderr, kitlog.NewLogfmtLogger, colorFn)
 }
@@ -76,7 +77,7 @@ Please note, global options must be placed before sub-commands, e.g.
 
 	Flags: []cli.Flag{
 		&cli.StringFlag{Name: "shscap", Value: "1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=", Usage: "SHS key"},
-		&cli.StringFlag{Name: "addr", Value: "localhost:8008", Usage: "TCP address of the sbot to connect to (or listen on)"},
+		&cli.StringFlag{Name: "addr", Value: "localhost:8008", Usage: "TCP address of the sbot to connect to (or listen on), used instead of the unix socket if set or if no socket works"},
 		&cli.StringFlag{Name: "remoteKey", Value: "", Usage: "The remote pubkey you are connecting to (by default the local key)"},
 		&keyFileFlag,
 		&unixSockFlag,
@@ -90,21 +91,35 @@ Please note, global options must be placed before sub-commands, e.g.
 		aliasCmd,
 		blobsCmd,
 		blockCmd,
+		diskUsageCmd,
+		ebtCmd,
 		friendsCmd,
 		getCmd,
 		getSubsetCmd,
+		graphCmd,
+		ignoreCmd,
+		mutualsCmd,
 		inviteCmds,
 		logStreamCmd,
 		sortedStreamCmd,
 		typeStreamCmd,
 		historyStreamCmd,
+		replicateCmd,
 		replicateUptoCmd,
 		repliesStreamCmd,
 		callCmd,
 		sourceCmd,
 		connectCmd,
 		publishCmd,
+		rawCmd,
+		searchCmd,
 		groupsCmd,
+		identityCmd,
+		verifyCmd,
+		whoamiCmd,
+		versionCmd,
+		peersCmd,
+		discoveredCmd,
 	},
 }
 
@@ -127,14 +142,20 @@ func check(err error) {
 
 func main() {
 	cli.VersionPrinter = func(c *cli.Context) {
-		fmt.Printf("%s (rev: %s, built: %s)\n", c.App.Version, Version, Build)
+		fmt.Println(clientVersion(c))
 	}
 
 	if err := app.Run(os.Args); err != nil {
 		level.Error(log).Log("run-failure", err)
+		os.Exit(1)
 	}
 }
 
+// clientVersion describes the build of sbotcli
+func clientVersion(ctx *cli.Context) string {
+	return fmt.Sprintf("%s (rev: %s, built: %s, %s)", ctx.App.Version, Version, Build, runtime.Version())
+}
+
 func todo(ctx *cli.Context) error {
 	return fmt.Errorf("todo: %s", ctx.Command.Name)
 }
@@ -164,22 +185,44 @@ func initClient(ctx *cli.Context) error {
 }
 
 func newClient(ctx *cli.Context) (*ssbClient.Client, error) {
-	sockPath := ctx.String("unixsock")
-	if sockPath != "" {
-		client, err := ssbClient.NewUnix(sockPath, ssbClient.WithContext(longctx))
-		if err != nil {
-			level.Warn(log).Log("client", "unix-path based init failed", "err", err)
-			return newTCPClient(ctx)
-		}
-		level.Info(log).Log("client", "connected", "method", "unix sock")
-		return client, nil
+	return newClientWithContext(ctx, longctx)
+}
+
+// newClientWithContext is like newClient but the connection is only bound to rootCtx and not to the --timeout
+func newClientWithContext(ctx *cli.Context, rootCtx context.Context) (*ssbClient.Client, error) {
+	// an address without a socket means TCP
+	if ctx.IsSet("addr") && !ctx.IsSet("unixsock") {
+		return newTCPClient(ctx, rootCtx)
 	}
 
-	// Assume TCP connection
-	return newTCPClient(ctx)
+	sock, err := findSocket(ctx)
+	if err != nil {
+		return newTCPFallback(ctx, rootCtx, err)
+	}
+	if ctx.Bool("verbose") {
+		level.Info(log).Log("client", "using unix socket", "path", sock.path, "from", sock.source)
+	}
+
+	client, err := ssbClient.NewUnix(sock.path, ssbClient.WithContext(rootCtx))
+	if err != nil {
+		return newTCPFallback(ctx, rootCtx, fmt.Errorf("failed to connect to %s (from %s), is the server running? %w", sock.path, sock.source, err))
+	}
+	level.Info(log).Log("client", "connected", "method", "unix sock")
+	return client, nil
+}
+
+// newTCPFallback tries --addr after the unix socket didn't work, sockErr is why.
+// If that fails as well, both errors are returned.
+func newTCPFallback(ctx *cli.Context, rootCtx context.Context, sockErr error) (*ssbClient.Client, error) {
+	level.Warn(log).Log("client", "unix socket failed, trying TCP", "addr", ctx.String("addr"), "err", sockErr)
+	client, err := newTCPClient(ctx, rootCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%v (TCP fallback: %w)", sockErr, err)
+	}
+	return client, nil
 }
 
-func newTCPClient(ctx *cli.Context) (*ssbClient.Client, error) {
+func newTCPClient(ctx *cli.Context, rootCtx context.Context) (*ssbClient.Client, error) {
 	localKey, err := ssb.LoadKeyPair(ctx.String("key"))
 	if err != nil {
 		return nil, err
@@ -205,7 +248,7 @@ func newTCPClient(ctx *cli.Context) (*ssbClient.Client, error) {
 	shsAddr := netwrap.WrapAddr(plainAddr, secretstream.Addr{PubKey: remotePubKey})
 	client, err := ssbClient.NewTCP(localKey, shsAddr,
 		ssbClient.WithSHSAppKey(ctx.String("shscap")),
-		ssbClient.WithContext(longctx))
+		ssbClient.WithContext(rootCtx))
 	if err != nil {
 		return nil, fmt.Errorf("init: failed to connect to %s: %w", shsAddr.String(), err)
 	}
@@ -226,7 +269,7 @@ Supports common muxrpc async calls such as:
 * getLatest
 * gossip.(peers|add|connect)
 * latestSequence
-* whoami
+* whoami(Detailed)
 
 Example:
 
@@ -402,12 +445,20 @@ var getCmd = &cli.Command{
 	ArgsUsage: "<%...sha256>",
 	Description: `Get a single message from the local database by key (%...).
 
+--encoding selects the output:
+    pretty  indented JSON, for reading (default)
+    json    compact JSON on a single line, for other tools
+    raw     the value of the message with the exact bytes that were signed,
+            without the key and without decrypting it. Use this to verify
+            the signature again, re-encoding the JSON changes the bytes.
+
 Example:
 
-    sbotcli get %Dj/W4PYYZUWj/iWlyVuOg8pgv4b+BwP0qOF5OpD+o4I=.sha256`,
+    sbotcli get %Dj/W4PYYZUWj/iWlyVuOg8pgv4b+BwP0qOF5OpD+o4I=.sha256
+    sbotcli get --encoding raw %Dj/W4PYYZUWj/iWlyVuOg8pgv4b+BwP0qOF5OpD+o4I=.sha256`,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "private"},
-		&cli.StringFlag{Name: "format", Value: "json"},
+		&cli.StringFlag{Name: "encoding", Aliases: []string{"format"}, Value: "pretty", Usage: "pretty, json or raw"},
 	},
 	Action: func(ctx *cli.Context) error {
 		key, err := refs.ParseMessageRef(ctx.Args().First())
@@ -415,36 +466,59 @@ Example:
 			return fmt.Errorf("failed to validate message ref: %w", err)
 		}
 
+		encoding := strings.ToLower(ctx.String("encoding"))
+		switch encoding {
+		case "pretty", "json", "raw":
+		case "go": // the old debug output of --format
+		default:
+			return fmt.Errorf("unknown encoding %q (pretty, json or raw)", encoding)
+		}
+
 		client, err := newClient(ctx)
 		if err != nil {
 			return err
 		}
 
+		if encoding == "raw" {
+			raw, err := client.GetRaw(key)
+			if err != nil {
+				return err
+			}
+			os.Stdout.Write(raw)
+			return nil
+		}
+
 		arg := struct {
 			ID      refs.MessageRef `json:"id"`
 			Private bool            `json:"private"`
 		}{key, ctx.Bool("private")}
 
-		var val interface{}
+		// keep the reply as it was sent, so that the fields stay in their order
+		var val json.RawMessage
 		err = client.Async(longctx, &val, muxrpc.TypeJSON, muxrpc.Method{"get"}, arg)
 		if err != nil {
 			return err
 		}
-		format := strings.ToLower(ctx.String("format"))
-		log.Log("event", "get reply", "format", format)
-		switch format {
-		case "json":
-			indented, err := json.MarshalIndent(val, "", "  ")
-			if err != nil {
-				return err
-			}
-			os.Stdout.Write(indented)
+		log.Log("event", "get reply", "encoding", encoding)
 
+		var out bytes.Buffer
+		switch encoding {
+		case "pretty":
+			err = json.Indent(&out, val, "", "  ")
+		case "json":
+			err = json.Compact(&out, val)
 		default:
-			fmt.Printf("%+v\n", val)
+			var v interface{}
+			if err = json.Unmarshal(val, &v); err == nil {
+				fmt.Fprintf(&out, "%+v", v)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
 		}
+		out.WriteByte('\n')
+		os.Stdout.Write(out.Bytes())
 		return nil
-
 	},
 }
 
@@ -458,6 +532,10 @@ Example:
 
     sbotcli connect "net:192.168.8.136:8008~shs:HEqy940T6uB+T+d9Jaa58aNfRzLx9eRWqkZljBmnkmk="
 
+A peer behind a room is reached with a tunnel address, the room needs to be connected first:
+
+    sbotcli connect "tunnel:@7MG1hyfz8SsxlIgansud4LKM57IHIw2Okw/hvOdeJWw=.ed25519:@HEqy940T6uB+T+d9Jaa58aNfRzLx9eRWqkZljBmnkmk=.ed25519~shs:HEqy940T6uB+T+d9Jaa58aNfRzLx9eRWqkZljBmnkmk="
+
 See https://github.com/ssbc/multiserver#address-format for more information about multiserver addresses.`,
 
 	Action: func(ctx *cli.Context) error {
@@ -616,7 +694,7 @@ var groupsPublishToCmd = &cli.Command{
 	Usage:     "Publish a handcrafted JSON blob to a group",
 	ArgsUsage: "<%...sha256> <json>",
 	Action: func(ctx *cli.Context) error {
-		var content interface{}
+		var content json.RawMessage
 		err := json.NewDecoder(os.Stdin).Decode(&content)
 		if err != nil {
 			return fmt.Errorf("publish/raw: invalid json input from stdin: %w", err)
@@ -655,10 +733,12 @@ var groupsJoinCmd = &cli.Command{
 
 var inviteCmds = &cli.Command{
 	Name:  "invite",
-	Usage: "Create and accept invite codes",
+	Usage: "Create, accept and revoke invite codes",
 	Subcommands: []*cli.Command{
 		inviteCreateCmd,
 		inviteAcceptCmd,
+		inviteRevokeCmd,
+		inviteListCmd,
 	},
 }
 
@@ -692,6 +772,51 @@ Example:
 	},
 }
 
+var inviteRevokeCmd = &cli.Command{
+	Name:      "revoke",
+	Usage:     "Cancel an invite that was created by this bot",
+	ArgsUsage: "<invite>",
+	Action: func(ctx *cli.Context) error {
+		token := ctx.Args().First()
+		if token == "" {
+			return fmt.Errorf("missing invite?")
+		}
+
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var reply string
+		err = client.Async(longctx, &reply, muxrpc.TypeString, muxrpc.Method{"invite", "revoke"}, token)
+		if err != nil {
+			return fmt.Errorf("revoke call failed: %w", err)
+		}
+		fmt.Println("invite revoked")
+		return nil
+	},
+}
+
+var inviteListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List the outstanding invites with their remaining uses and expiry",
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var reply []legacyinvites.Info
+		err = client.Async(longctx, &reply, muxrpc.TypeJSON, muxrpc.Method{"invite", "list"})
+		if err != nil {
+			return fmt.Errorf("list call failed: %w", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reply)
+	},
+}
+
 var inviteAcceptCmd = &cli.Command{
 	Name:      "accept",
 	Usage:     "Use an invite code",
@@ -39,7 +39,7 @@ Example:
 
 	// TODO: add private
 	Action: func(ctx *cli.Context) error {
-		var content interface{}
+		var content json.RawMessage
 		err := json.NewDecoder(os.Stdin).Decode(&content)
 		if err != nil {
 			return fmt.Errorf("publish/raw: invalid json input from stdin: %w", err)
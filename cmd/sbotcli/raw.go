@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/urfave/cli/v2"
+	"go.mindeco.de/log/level"
+)
+
+var rawCmd = &cli.Command{
+	Name:      "raw",
+	Usage:     "Call any muxrpc method with JSON arguments",
+	ArgsUsage: "<method.name> [<json>...]",
+	Description: `Call any muxrpc method with JSON arguments.
+
+The method name is split at the dots, like the names in the manifest.
+Each argument is a JSON value, given with --arg or after the method name.
+--type picks the kind of call: async prints the result, source prints each
+value of the stream on a line of its own.
+
+Examples:
+
+    sbotcli raw whoami
+    sbotcli raw --arg '{"id":"@p13zSAiOpguI9nsawkGijsnMfWmFd5rlUNpzekEE+vI=.ed25519"}' --type source createHistoryStream
+    sbotcli raw --type source createHistoryStream '{"id":"@p13zSAiOpguI9nsawkGijsnMfWmFd5rlUNpzekEE+vI=.ed25519","limit":3}'`,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{Name: "arg", Usage: "JSON argument of the call, can be given more than once"},
+		&cli.StringFlag{Name: "type", Value: "async", Usage: "async or source"},
+	},
+	Action: func(ctx *cli.Context) error {
+		name := ctx.Args().First()
+		if name == "" {
+			return errors.New("raw: need a method name")
+		}
+		method := muxrpc.Method(strings.Split(name, "."))
+
+		var args []interface{}
+		for _, a := range append(ctx.StringSlice("arg"), ctx.Args().Tail()...) {
+			if !json.Valid([]byte(a)) {
+				return fmt.Errorf("raw: argument is not valid JSON: %s", a)
+			}
+			args = append(args, json.RawMessage(a))
+		}
+
+		tipe := strings.ToLower(ctx.String("type"))
+		if tipe != "async" && tipe != "source" {
+			return fmt.Errorf("raw: unknown call type %q (async or source)", tipe)
+		}
+
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		if tipe == "async" {
+			var reply json.RawMessage
+			err = client.Async(longctx, &reply, muxrpc.TypeJSON, method, args...)
+			if err != nil {
+				return fmt.Errorf("%s: call failed: %w", name, err)
+			}
+			level.Debug(log).Log("event", "call reply")
+
+			var out bytes.Buffer
+			if err := json.Indent(&out, reply, "", "  "); err != nil {
+				return fmt.Errorf("%s: indent failed: %w", name, err)
+			}
+			out.WriteByte('\n')
+			_, err = out.WriteTo(os.Stdout)
+			return err
+		}
+
+		src, err := client.Source(longctx, muxrpc.TypeJSON, method, args...)
+		if err != nil {
+			return fmt.Errorf("%s: call failed: %w", name, err)
+		}
+
+		var buf, line bytes.Buffer
+		for src.Next(longctx) {
+			buf.Reset()
+			err := src.Reader(func(r io.Reader) error {
+				_, err := buf.ReadFrom(r)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("%s: failed to read from the stream: %w", name, err)
+			}
+
+			// values like signed messages are indented, put each of them on a single line
+			line.Reset()
+			if err := json.Compact(&line, buf.Bytes()); err != nil {
+				line.Reset()
+				line.Write(buf.Bytes())
+			}
+			line.WriteByte('\n')
+			if _, err := line.WriteTo(os.Stdout); err != nil {
+				return err
+			}
+		}
+		if err := src.Err(); err != nil {
+			return fmt.Errorf("%s: stream failed: %w", name, err)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/urfave/cli/v2"
+)
+
+var replicateCmd = &cli.Command{
+	Name:  "replicate",
+	Usage: "Inspect the replication of feeds",
+	Subcommands: []*cli.Command{
+		replicateStatusCmd,
+		replicateAddCmd,
+		replicateRemoveCmd,
+		replicateListCmd,
+	},
+}
+
+var replicateAddCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "Replicate a feed without following it",
+	ArgsUsage: "<@...ed25519>",
+	Description: `Replicate a feed without following it.
+
+The feed is replicated even if it is not within the hop range, for instance to
+archive it. No contact message is published, and the bot remembers the feed
+across restarts. Ignored feeds can't be added.
+
+Example:
+
+    sbotcli replicate add @r6Lzb9OT3/dlVYNDTABmsF+HWnhBsA1twZaobYhjVUY=.ed25519`,
+	Action: func(ctx *cli.Context) error {
+		feed, err := replicateFeedArg(ctx)
+		if err != nil {
+			return err
+		}
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		return client.ReplicateFeed(feed)
+	},
+}
+
+var replicateRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Revert replicate add",
+	ArgsUsage: "<@...ed25519>",
+	Description: `Revert replicate add.
+
+The feed is still replicated if it is within the hop range.`,
+	Action: func(ctx *cli.Context) error {
+		feed, err := replicateFeedArg(ctx)
+		if err != nil {
+			return err
+		}
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		return client.UnreplicateFeed(feed)
+	},
+}
+
+var replicateListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List the feeds that were added with replicate add",
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		feeds, err := client.ReplicatedFeeds()
+		if err != nil {
+			return err
+		}
+		for _, f := range feeds {
+			fmt.Println(f.String())
+		}
+		return nil
+	},
+}
+
+func replicateFeedArg(ctx *cli.Context) (refs.FeedRef, error) {
+	if ctx.Args().Len() != 1 {
+		return refs.FeedRef{}, errors.New("replicate: expected one feed reference")
+	}
+	feed, err := refs.ParseFeedRef(ctx.Args().First())
+	if err != nil {
+		return refs.FeedRef{}, fmt.Errorf("replicate: %w", err)
+	}
+	return feed, nil
+}
+
+var replicateStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show the replication state of every replicated feed",
+	Description: `Show the replication state of every replicated feed.
+
+For each feed of the replication list it prints the sequence we have, the
+longest sequence a connected peer claimed to have, whether an EBT session
+replicates the feed right now and when the newest message was received.
+
+Example:
+
+    sbotcli replicate status
+    sbotcli replicate status --format json`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "Output format (text or json)"},
+	},
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		status, err := client.ReplicationStatus()
+		if err != nil {
+			return err
+		}
+
+		switch f := ctx.String("format"); f {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(status)
+
+		case "text":
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "feed\tlocal\tremote\tactive\tlast progress")
+			for _, st := range status {
+				remote, progress := "-", "never"
+				if st.RemotePeer != nil {
+					remote = fmt.Sprintf("%d (%s)", st.RemoteSeq, st.RemotePeer.ShortSigil())
+				}
+				if !st.LastProgress.IsZero() {
+					progress = humanize.Time(st.LastProgress)
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%t\t%s\n", st.Feed.String(), st.LocalSeq, remote, st.Active, progress)
+			}
+			return tw.Flush()
+
+		default:
+			return fmt.Errorf("replicate status: unknown format %q", f)
+		}
+	},
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/urfave/cli/v2"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/plugins/search"
+)
+
+var searchCmd = &cli.Command{
+	Name:      "search",
+	Usage:     "Find public posts by the words in their text",
+	ArgsUsage: "<words...>",
+	Description: `Find public posts by the words in their text.
+
+Prints the references of the posts that contain all the passed words, newest
+first. The sbot needs to run with the search index enabled.
+
+Example:
+
+    sbotcli search --limit 10 hello world`,
+	Flags: []cli.Flag{
+		&cli.IntFlag{Name: "limit", Value: 25, Usage: "Maximum number of results (0 for all)"},
+	},
+	Action: func(ctx *cli.Context) error {
+		query := strings.Join(ctx.Args().Slice(), " ")
+		if query == "" {
+			return fmt.Errorf("search: missing query")
+		}
+
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		arg := search.QueryArgs{
+			Query: query,
+			Limit: ctx.Int("limit"),
+		}
+
+		var found []refs.MessageRef
+		err = client.Async(longctx, &found, muxrpc.TypeJSON, muxrpc.Method{"search", "query"}, arg)
+		if err != nil {
+			return fmt.Errorf("search.query: async call failed: %w", err)
+		}
+
+		for _, ref := range found {
+			fmt.Println(ref.String())
+		}
+		return nil
+	},
+}
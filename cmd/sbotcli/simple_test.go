@@ -20,8 +20,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/invite"
+	"github.com/ssbc/go-ssb/message/legacy"
+	"github.com/ssbc/go-ssb/plugins/legacyinvites"
+	"github.com/ssbc/go-ssb/plugins/replicate"
 	"github.com/ssbc/go-ssb/sbot"
 )
 
@@ -60,6 +65,19 @@ func mkCommandRunner(t *testing.T, ctx context.Context, path string, sockPath st
 	}
 }
 
+// runFailing runs the CLI like the runner of mkCommandRunner but expects it to exit with an error. It returns stderr.
+func runFailing(t *testing.T, ctx context.Context, path string, sockPath string, args ...string) []byte {
+	var stderr bytes.Buffer
+
+	sbotcli := exec.CommandContext(ctx, path, append([]string{"--unixsock", sockPath}, args...)...)
+	sbotcli.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	if err := sbotcli.Run(); err == nil {
+		t.Errorf("expected %v to fail", args)
+	}
+	return stderr.Bytes()
+}
+
 func TestWhoami(t *testing.T) {
 	cliPath := buildCLI(t)
 
@@ -94,6 +112,143 @@ func TestWhoami(t *testing.T) {
 	has := bytes.Contains(out, []byte(srv.KeyPair.ID().String()))
 	a.True(has, "ID not found in output")
 
+	out, _ = sbotcli("whoami")
+	a.Equal(srv.KeyPair.ID().String(), strings.TrimSpace(string(out)))
+
+	_, err = srv.PublishLog.Publish(refs.NewAboutName(srv.KeyPair.ID(), "server"))
+	r.NoError(err)
+
+	out, _ = sbotcli("whoami")
+	a.Equal(srv.KeyPair.ID().String()+" (server)", strings.TrimSpace(string(out)))
+
+	// local discovery is off, so nothing was found
+	out, _ = sbotcli("discovered")
+	a.Empty(strings.TrimSpace(string(out)))
+
+	srv.Shutdown()
+	err = srv.Close()
+	r.NoError(err)
+	r.NoError(<-errc)
+}
+
+func TestSocketFileEnv(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	sockPath := filepath.Join("testrun", t.Name(), "elsewhere", "sock")
+
+	srv, err := sbot.New(
+		sbot.WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.WithUNIXSocketPath(sockPath),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	_, err = os.Stat(filepath.Join(srvRepo, "socket"))
+	a.True(os.IsNotExist(err), "socket in the repo: %v", err)
+
+	// no --unixsock, the environment variable picks the socket
+	var stdout bytes.Buffer
+	sbotcli := exec.CommandContext(ctx, cliPath, "whoami")
+	sbotcli.Env = append(os.Environ(), "SSB_SOCKET_FILE="+sockPath)
+	sbotcli.Stdout = io.MultiWriter(os.Stderr, &stdout)
+	sbotcli.Stderr = os.Stderr
+	r.NoError(sbotcli.Run())
+	a.Equal(srv.KeyPair.ID().String(), strings.TrimSpace(stdout.String()))
+
+	srv.Shutdown()
+	err = srv.Close()
+	r.NoError(err)
+	r.NoError(<-errc)
+}
+
+func TestSocketDiscovery(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	// an empty home, so that the defaults don't find a running server
+	home, err := filepath.Abs(filepath.Join("testrun", t.Name(), "home"))
+	r.NoError(err)
+	r.NoError(os.MkdirAll(home, 0700))
+
+	run := func(env []string, args ...string) (string, string, error) {
+		var stdout, stderr bytes.Buffer
+		sbotcli := exec.CommandContext(ctx, cliPath, args...)
+		sbotcli.Env = append(os.Environ(), "HOME="+home, "SSB_SOCKET_FILE=", "SSB_DATA_DIR=")
+		sbotcli.Env = append(sbotcli.Env, env...)
+		sbotcli.Stdout = io.MultiWriter(os.Stderr, &stdout)
+		sbotcli.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		err := sbotcli.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	// the socket in the data dir is found and reported with --verbose
+	out, stderr, err := run([]string{"SSB_DATA_DIR=" + srvRepo}, "--verbose", "whoami")
+	r.NoError(err)
+	a.Equal(srv.KeyPair.ID().String(), strings.TrimSpace(out))
+	a.Contains(stderr, filepath.Join(srvRepo, "socket"))
+	a.Contains(stderr, "SSB_DATA_DIR")
+
+	// the flag wins over the environment
+	out, _, err = run([]string{"SSB_SOCKET_FILE=/nowhere/socket"}, "--unixsock", filepath.Join(srvRepo, "socket"), "whoami")
+	r.NoError(err)
+	a.Equal(srv.KeyPair.ID().String(), strings.TrimSpace(out))
+
+	// without a socket it falls back to TCP, where nothing listens
+	_, stderr, err = run([]string{"SSB_SOCKET_FILE=/nowhere/socket"}, "whoami")
+	a.Error(err, "should exit with an error")
+	a.Contains(stderr, "no socket at /nowhere/socket (from SSB_SOCKET_FILE), is the server running?")
+	a.Contains(stderr, "TCP fallback")
+
+	_, stderr, err = run(nil, "whoami")
+	a.Error(err, "should exit with an error")
+	a.Contains(stderr, filepath.Join(home, ".ssb-go", "socket"))
+	a.Contains(stderr, "is the server running?")
+	a.Contains(stderr, "TCP fallback")
+
+	// with both, the socket is tried first
+	_, stderr, err = run(nil, "--unixsock", "/nowhere/socket", "--addr", "127.0.0.1:1", "whoami")
+	a.Error(err, "should exit with an error")
+	a.Contains(stderr, "no socket at /nowhere/socket (from --unixsock)")
+	a.Contains(stderr, "127.0.0.1:1")
+
 	srv.Shutdown()
 	err = srv.Close()
 	r.NoError(err)
@@ -261,6 +416,40 @@ func TestGetPublished(t *testing.T) {
 	var msg map[string]interface{}
 	err = json.Unmarshal(out, &msg)
 	r.NoError(err)
+	a.True(bytes.Contains(out, []byte("\n  ")), "pretty output should be indented")
+
+	out, _ = sbotcli("get", "--encoding", "json", testMsgRef.String())
+	a.Equal(1, bytes.Count(out, []byte("\n")), "json output should be a single line")
+	err = json.Unmarshal(out, &msg)
+	r.NoError(err)
+
+	out, _ = sbotcli("get", "--encoding", "raw", testMsgRef.String())
+	verifiedRef, _, err := legacy.Verify(out, nil)
+	r.NoError(err)
+	a.True(verifiedRef.Equal(testMsgRef), "raw output should hash to the same reference")
+
+	// large integers and the order of the fields survive publishing and getting the message
+	content := `{"type":"test","big":9223372036854775807,"odd":9007199254740993}`
+	publishRaw := exec.CommandContext(ctx, cliPath, "--unixsock", filepath.Join(srvRepo, "socket"), "publish", "raw")
+	publishRaw.Stdin = strings.NewReader(content)
+	publishRaw.Stderr = os.Stderr
+	out, err = publishRaw.Output()
+	r.NoError(err)
+	bigRef, err := refs.ParseMessageRef(strings.TrimSpace(string(out)))
+	r.NoError(err)
+
+	out, _ = sbotcli("get", "--encoding", "json", bigRef.String())
+	var bigMsg struct {
+		Value struct {
+			Content json.RawMessage
+		}
+	}
+	r.NoError(json.Unmarshal(out, &bigMsg))
+	a.Equal(content, string(bigMsg.Value.Content))
+
+	out, _ = sbotcli("get", "--encoding", "raw", bigRef.String())
+	_, _, err = legacy.Verify(out, nil)
+	r.NoError(err)
 
 	srv.Shutdown()
 	err = srv.Close()
@@ -319,8 +508,654 @@ func TestInviteCreate(t *testing.T) {
 	has = bytes.Contains(out, []byte("accepted"))
 	a.True(has, "should have been accepted")
 
+	// only the first one is left, the second is used up
+	listInvites := func() []legacyinvites.Info {
+		out, _ := sbotcli("invite", "list")
+		var list []legacyinvites.Info
+		r.NoError(json.Unmarshal(out, &list))
+		return list
+	}
+	list := listInvites()
+	r.Len(list, 1)
+	a.EqualValues(1, list[0].Remaining)
+
+	revokeOut, _ := sbotcli("invite", "create", "--uses", "3")
+	r.Len(listInvites(), 2)
+
+	out, _ = sbotcli("invite", "revoke", string(revokeOut))
+	a.True(bytes.Contains(out, []byte("revoked")), "should have been revoked")
+	r.Len(listInvites(), 1)
+
+	revoked, err := invite.ParseLegacyToken(string(bytes.TrimSpace(revokeOut)))
+	r.NoError(err)
+	err = invite.Redeem(ctx, revoked, feedAlice)
+	r.Error(err, "revoked invite should not be usable")
+
+	srv.Shutdown()
+	err = srv.Close()
+	r.NoError(err)
+	r.NoError(<-errc)
+}
+
+func TestGraph(t *testing.T) {
+	if os.Getenv("LIBRARIAN_WRITEALL") != "0" {
+		t.Fatal("please 'export LIBRARIAN_WRITEALL=0' for this test to pass")
+	}
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	friend, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	blocked, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	_, err = srv.PublishLog.Publish(refs.NewContactFollow(friend))
+	r.NoError(err)
+	_, err = srv.PublishLog.Publish(refs.NewContactBlock(blocked))
+	r.NoError(err)
+	srv.WaitUntilIndexesAreSynced()
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	out, _ := sbotcli("graph", "--format", "json")
+
+	var adjacency map[string]struct {
+		Follows []refs.FeedRef
+		Blocks  []refs.FeedRef
+	}
+	r.NoError(json.Unmarshal(out, &adjacency))
+
+	self, has := adjacency[srv.KeyPair.ID().String()]
+	r.True(has, "self not in graph")
+	r.Len(self.Follows, 1)
+	a.True(self.Follows[0].Equal(friend))
+
+	// only follows are walked
+	a.Len(self.Blocks, 0)
+	_, has = adjacency[blocked.String()]
+	a.False(has, "blocked feed in graph")
+
+	out, _ = sbotcli("graph", "--hops", "0")
+	a.True(bytes.Contains(out, []byte("digraph trust {")), "not a dot file: %s", out)
+	a.Equal(1, bytes.Count(out, []byte("[label=")), "only self with zero hops")
+
 	srv.Shutdown()
 	err = srv.Close()
 	r.NoError(err)
 	r.NoError(<-errc)
 }
+
+func TestSearch(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r := require.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.WithSearchIndex(),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	older, err := srv.PublishLog.Publish(refs.NewPost("hello world"))
+	r.NoError(err)
+	_, err = srv.PublishLog.Publish(refs.NewPost("goodbye world"))
+	r.NoError(err)
+	newer, err := srv.PublishLog.Publish(refs.NewPost("hello again"))
+	r.NoError(err)
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	out, _ := sbotcli("search", "hello")
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	r.Equal([]string{newer.Key().String(), older.Key().String()}, lines)
+
+	out, _ = sbotcli("search", "--limit", "1", "hello")
+	r.Equal(newer.Key().String(), strings.TrimSpace(string(out)))
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestVerify(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r := require.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, err = srv.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	want := srv.KeyPair.ID().String() + ": 3 messages ok"
+
+	out, _ := sbotcli("verify")
+	r.Equal(want, strings.TrimSpace(string(out)))
+
+	out, _ = sbotcli("verify", srv.KeyPair.ID().String())
+	r.Equal(want, strings.TrimSpace(string(out)))
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestDiskUsage(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r := require.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	_, err = srv.PublishLog.Publish(refs.NewPost("hello"))
+	r.NoError(err)
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	out, _ := sbotcli("diskusage", "--format", "json")
+	var report ssb.DiskReport
+	r.NoError(json.Unmarshal(out, &report))
+	r.NotZero(report.Log)
+	r.NotZero(report.Total)
+
+	out, _ = sbotcli("diskusage")
+	r.Contains(string(out), "sublogs/shared-badger")
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestReplicateStatus(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r := require.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	friend, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	srv.Replicate(friend)
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	out, _ := sbotcli("replicate", "status", "--format", "json")
+	var status []replicate.FeedStatus
+	r.NoError(json.Unmarshal(out, &status))
+
+	var found bool
+	for _, st := range status {
+		if st.Feed.Equal(friend) {
+			found = true
+			r.EqualValues(0, st.LocalSeq)
+			r.False(st.Active)
+		}
+	}
+	r.True(found, "replicated feed is missing")
+
+	out, _ = sbotcli("replicate", "status")
+	r.Contains(string(out), friend.String())
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestIgnore(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r := require.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	noisy, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	srv.Replicate(noisy)
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	sbotcli("ignore", noisy.String())
+	r.Equal([]refs.FeedRef{noisy}, srv.IgnoredFeeds())
+	r.False(srv.Lister().ReplicationList().Has(noisy))
+
+	out, _ := sbotcli("ignore", "--list")
+	r.Equal(noisy.String()+"\n", string(out))
+
+	sbotcli("ignore", "--undo", noisy.String())
+	r.Empty(srv.IgnoredFeeds())
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestReplicateAdd(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r := require.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	archived, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	sbotcli("replicate", "add", archived.String())
+	r.Equal([]refs.FeedRef{archived}, srv.ReplicatedFeeds())
+	r.True(srv.Lister().ReplicationList().Has(archived))
+
+	out, _ := sbotcli("replicate", "list")
+	r.Equal(archived.String()+"\n", string(out))
+
+	sbotcli("replicate", "remove", archived.String())
+	r.Empty(srv.ReplicatedFeeds())
+	r.False(srv.Lister().ReplicationList().Has(archived))
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestLog(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	var posts []refs.MessageRef
+	for i := 0; i < 3; i++ {
+		msg, err := srv.PublishLog.Publish(refs.NewPost(fmt.Sprintf("hello %d", i)))
+		r.NoError(err)
+		posts = append(posts, msg.Key())
+
+		_, err = srv.PublishLog.Publish(refs.NewContactFollow(srv.KeyPair.ID()))
+		r.NoError(err)
+	}
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	readLines := func(out []byte) []refs.KeyValueRaw {
+		var msgs []refs.KeyValueRaw
+		for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var msg refs.KeyValueRaw
+			r.NoError(json.Unmarshal(line, &msg), "not a JSON line: %q", line)
+			msgs = append(msgs, msg)
+		}
+		return msgs
+	}
+
+	out, _ := sbotcli("log")
+	a.Len(readLines(out), 6)
+
+	out, _ = sbotcli("log", "--gt", "3")
+	a.Len(readLines(out), 2)
+
+	out, _ = sbotcli("log", "--type", "post", "--limit", "2")
+	msgs := readLines(out)
+	r.Len(msgs, 2)
+	a.True(msgs[0].Key().Equal(posts[0]))
+	a.True(msgs[1].Key().Equal(posts[1]))
+
+	out, _ = sbotcli("log", "--type", "post", "--limit", "1", "--values=false")
+	var key refs.MessageRef
+	r.NoError(json.Unmarshal(bytes.TrimSpace(out), &key), "not a key: %q", out)
+	a.True(key.Equal(posts[0]))
+
+	out, _ = sbotcli("log", "--type", "post", "--limit", "1", "--keys=false")
+	var value refs.Value
+	r.NoError(json.Unmarshal(bytes.TrimSpace(out), &value), "not a value: %q", out)
+	a.EqualValues(1, value.Sequence)
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
+
+func TestBlobs(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	input := filepath.Join("testrun", t.Name(), "input")
+	r.NoError(os.WriteFile(input, []byte("hello blobs"), 0600))
+
+	out, _ := sbotcli("blob", "add", input)
+	ref, err := refs.ParseBlobRef(strings.TrimSpace(string(out)))
+	r.NoError(err, "expected a blob ref, got %q", out)
+
+	sz, err := srv.BlobStore.Size(ref)
+	r.NoError(err)
+	a.EqualValues(11, sz)
+
+	// adding it again doesn't wait for a change that doesn't come
+	out, _ = sbotcli("blob", "add", input)
+	a.Equal(ref.Sigil(), strings.TrimSpace(string(out)))
+
+	out, _ = sbotcli("blob", "get", ref.Sigil())
+	a.Equal("hello blobs", string(out))
+
+	dst := filepath.Join("testrun", t.Name(), "output")
+	sbotcli("blob", "get", "--out", dst, ref.Sigil())
+	got, err := os.ReadFile(dst)
+	r.NoError(err)
+	a.Equal("hello blobs", string(got))
+
+	missing, err := refs.NewBlobRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoBlobSSB1)
+	r.NoError(err)
+	sbotcli("blob", "get", "--want", missing.Sigil())
+	a.True(srv.WantManager.Wants(missing), "should want the blob")
+
+	srv.Shutdown()
+	err = srv.Close()
+	r.NoError(err)
+	r.NoError(<-errc)
+}
+
+func TestIdentityBackup(t *testing.T) {
+	testPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(testPath)
+
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	kp, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	keyPath := filepath.Join(testPath, "old", "secret")
+	r.NoError(ssb.SaveKeyPair(kp, keyPath))
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(testPath, "socket"))
+
+	out, stderr := sbotcli("--key", keyPath, "identity", "export")
+	a.Contains(string(stderr), "WARNING")
+	a.Contains(string(out), "# NEVER show this to anyone!!!")
+	a.Contains(string(out), `"id": "`+kp.ID().String()+`"`)
+
+	backup := filepath.Join(testPath, "backup.secret")
+	sbotcli("--key", keyPath, "identity", "export", "--out", backup)
+	info, err := os.Stat(backup)
+	r.NoError(err)
+	a.Equal(ssb.SecretPerms, info.Mode().Perm())
+
+	newRepo := filepath.Join(testPath, "new")
+	sbotcli("identity", "import", "--repo", newRepo, backup)
+	restored, err := ssb.LoadKeyPair(filepath.Join(newRepo, "secret"))
+	r.NoError(err)
+	a.True(restored.ID().Equal(kp.ID()))
+	a.Equal(kp.Secret(), restored.Secret())
+
+	// the repo isn't fresh anymore
+	other, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	otherBackup := filepath.Join(testPath, "other.secret")
+	r.NoError(ssb.SaveKeyPair(other, otherBackup))
+	stderr = runFailing(t, ctx, cliPath, filepath.Join(testPath, "socket"), "identity", "import", "--repo", newRepo, otherBackup)
+	a.Contains(string(stderr), "not empty")
+	restored, err = ssb.LoadKeyPair(filepath.Join(newRepo, "secret"))
+	r.NoError(err)
+	a.True(restored.ID().Equal(kp.ID()), "keypair was replaced")
+}
+
+func TestRaw(t *testing.T) {
+	cliPath := buildCLI(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	r, a := require.New(t), assert.New(t)
+
+	srvRepo := filepath.Join("testrun", t.Name(), "serv")
+	os.RemoveAll(srvRepo)
+	srvLog := testutils.NewRelativeTimeLogger(nil)
+
+	srv, err := sbot.New(
+		sbot.WithInfo(srvLog),
+		sbot.WithRepoPath(srvRepo),
+		sbot.WithContext(ctx),
+		sbot.WithListenAddr(":0"),
+		sbot.LateOption(sbot.WithUNIXSocket()),
+	)
+	r.NoError(err, "sbot srv init failed")
+
+	var errc = make(chan error)
+	go func() {
+		errc <- srv.Network.Serve(ctx)
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, err := srv.PublishLog.Publish(refs.NewPost(fmt.Sprintf("hello %d", i)))
+		r.NoError(err)
+	}
+
+	sbotcli := mkCommandRunner(t, ctx, cliPath, filepath.Join(srvRepo, "socket"))
+
+	out, _ := sbotcli("raw", "whoami")
+	var who struct {
+		ID refs.FeedRef `json:"id"`
+	}
+	r.NoError(json.Unmarshal(out, &who))
+	a.True(who.ID.Equal(srv.KeyPair.ID()))
+
+	countLines := func(out []byte) int {
+		var n int
+		for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+			var msg refs.KeyValueRaw
+			r.NoError(json.Unmarshal(line, &msg), "not a JSON line: %q", line)
+			n++
+		}
+		return n
+	}
+
+	arg := fmt.Sprintf(`{"id":%q}`, srv.KeyPair.ID().String())
+	out, _ = sbotcli("raw", "--type", "source", "--arg", arg, "createHistoryStream")
+	a.Equal(3, countLines(out))
+
+	arg = fmt.Sprintf(`{"id":%q,"limit":2}`, srv.KeyPair.ID().String())
+	out, _ = sbotcli("raw", "--type", "source", "createHistoryStream", arg)
+	a.Equal(2, countLines(out))
+
+	stderr := runFailing(t, ctx, cliPath, filepath.Join(srvRepo, "socket"), "raw", "whoami", "{not json")
+	a.Contains(string(stderr), "not valid JSON")
+
+	srv.Shutdown()
+	r.NoError(srv.Close())
+	r.NoError(<-errc)
+}
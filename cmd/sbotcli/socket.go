@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+// socketCandidate is a place where the unix socket of the server might be
+type socketCandidate struct {
+	path   string
+	source string
+
+	// explicit ones are used even if there is no socket, to report it
+	explicit bool
+}
+
+// socketCandidates lists the places to look for the socket, in order:
+// --unixsock, $SSB_SOCKET_FILE, $SSB_DATA_DIR/socket and then the default repos of go-sbot and ssb-server.
+func socketCandidates(ctx *cli.Context) []socketCandidate {
+	if p := ctx.String("unixsock"); p != "" {
+		return []socketCandidate{{path: p, source: "--unixsock", explicit: true}}
+	}
+	if p := os.Getenv("SSB_SOCKET_FILE"); p != "" {
+		return []socketCandidate{{path: p, source: "SSB_SOCKET_FILE", explicit: true}}
+	}
+	if dir := os.Getenv("SSB_DATA_DIR"); dir != "" {
+		return []socketCandidate{{path: filepath.Join(dir, "socket"), source: "SSB_DATA_DIR", explicit: true}}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []socketCandidate{
+		{path: filepath.Join(home, ".ssb-go", "socket"), source: "default"},
+		{path: filepath.Join(home, ".ssb", "socket"), source: "default"},
+	}
+}
+
+// findSocket returns the first candidate that exists
+func findSocket(ctx *cli.Context) (socketCandidate, error) {
+	candidates := socketCandidates(ctx)
+
+	var tried []string
+	for _, c := range candidates {
+		_, err := os.Stat(c.path)
+		if err == nil {
+			return c, nil
+		}
+		if c.explicit {
+			return c, fmt.Errorf("no socket at %s (from %s), is the server running?", c.path, c.source)
+		}
+		tried = append(tried, c.path)
+	}
+
+	return socketCandidate{}, fmt.Errorf("no socket found (tried %s), is the server running? Use --unixsock to pick one or --addr to connect over TCP", strings.Join(tried, ", "))
+}
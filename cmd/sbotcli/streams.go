@@ -7,14 +7,20 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/ssbc/go-luigi"
 	"github.com/ssbc/go-muxrpc/v2"
 	cli "github.com/urfave/cli/v2"
 
 	refs "github.com/ssbc/go-ssb-refs"
+	ssbClient "github.com/ssbc/go-ssb/client"
 	"github.com/ssbc/go-ssb/message"
 )
 
@@ -93,23 +99,104 @@ var historyStreamCmd = &cli.Command{
 var logStreamCmd = &cli.Command{
 	Name:  "log",
 	Usage: "Fetch all messages from the local database (ordered by received time)",
-	Flags: streamFlags,
+	Description: `Prints the messages of the receive log as JSON lines.
+
+The --seq, --gt and --lt flags take sequences of the receive log.
+Like createLogStream, --keys=false prints only the message values
+and --values=false prints only the message keys.
+With --type only messages of that type are printed, the filter is applied by sbotcli
+and --limit counts the matching messages.
+With --live new messages are printed as they arrive until sbotcli is interrupted,
+the --timeout option is ignored then.
+
+Example:
+
+    sbotcli log --live --type post`,
+	Flags: []cli.Flag{
+		&cli.IntFlag{Name: "limit", Value: -1},
+		&cli.IntFlag{Name: "seq", Value: 0},
+		&cli.IntFlag{Name: "gt"},
+		&cli.IntFlag{Name: "lt"},
+		&cli.BoolFlag{Name: "reverse"},
+		&cli.BoolFlag{Name: "live"},
+		&cli.BoolFlag{Name: "keys", Value: true},
+		&cli.BoolFlag{Name: "values", Value: true},
+		&cli.BoolFlag{Name: "private", Value: false},
+		&cli.StringFlag{Name: "type", Usage: "only print messages of this type"},
+	},
 	Action: func(ctx *cli.Context) error {
-		client, err := newClient(ctx)
+		live := ctx.Bool("live")
+
+		streamCtx := longctx
+		if live {
+			// keep tailing until we are interrupted
+			var cancel context.CancelFunc
+			streamCtx, cancel = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+		}
+
+		client, err := newClientWithContext(ctx, streamCtx)
 		if err != nil {
 			return err
 		}
 
-		var args = getStreamArgs(ctx)
-		src, err := client.Source(longctx, muxrpc.TypeJSON, muxrpc.Method{"createLogStream"}, args)
+		opts := ssbClient.LogStreamOpts{
+			Live:    live,
+			Gt:      ctx.Int64("gt"),
+			Lt:      ctx.Int64("lt"),
+			Reverse: ctx.Bool("reverse"),
+			Seq:     ctx.Int64("seq"),
+			Private: ctx.Bool("private"),
+		}
+		keys, values := ctx.Bool("keys"), ctx.Bool("values")
+
+		limit := ctx.Int("limit")
+		msgType := ctx.String("type")
+		if msgType == "" && limit > 0 {
+			opts.Limit = limit
+		}
+
+		src, err := client.LogStream(opts)
 		if err != nil {
 			return fmt.Errorf("source stream call failed: %w", err)
 		}
-		err = jsonDrain(os.Stdout, src)
-		if err != nil {
-			err = fmt.Errorf("message pump failed: %w", err)
+
+		enc := json.NewEncoder(os.Stdout)
+		for printed := 0; limit < 0 || printed < limit; {
+			v, err := src.Next(streamCtx)
+			if luigi.IsEOS(err) || errors.Is(err, context.Canceled) {
+				break
+			} else if err != nil {
+				return fmt.Errorf("message pump failed: %w", err)
+			}
+
+			msg, ok := v.(refs.KeyValueRaw)
+			if !ok {
+				return fmt.Errorf("unexpected message type: %T", v)
+			}
+
+			if msgType != "" {
+				var content struct {
+					Type string `json:"type"`
+				}
+				// private messages are strings and don't have a type
+				if json.Unmarshal(msg.Value.Content, &content) != nil || content.Type != msgType {
+					continue
+				}
+			}
+
+			var out interface{} = msg
+			if !keys {
+				out = msg.Value
+			} else if !values {
+				out = msg.Key()
+			}
+			if err := enc.Encode(out); err != nil {
+				return err
+			}
+			printed++
 		}
-		return err
+		return nil
 	},
 }
 
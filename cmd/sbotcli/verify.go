@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+var verifyCmd = &cli.Command{
+	Name:      "verify",
+	Usage:     "Check the signatures and hash chain of a stored feed",
+	ArgsUsage: "[@feed]",
+	Description: `Check the signatures and hash chain of a stored feed.
+
+Every stored message of the feed is verified again and has to point to the
+message before it. The check stops at the first broken message and prints the
+sequence of the last good one. Without an argument the feed of the sbot is
+checked.
+
+Example:
+
+    sbotcli verify @p13zSAiOpguI9nsawkGijsnMfWmFd5rlUNpzekEE+vI=.ed25519`,
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var feed refs.FeedRef
+		if arg := ctx.Args().First(); arg != "" {
+			feed, err = refs.ParseFeedRef(arg)
+			if err != nil {
+				return fmt.Errorf("verify: invalid feed reference: %w", err)
+			}
+		} else {
+			feed, err = client.Whoami()
+			if err != nil {
+				return err
+			}
+		}
+
+		res, err := client.VerifyFeed(feed)
+		if err != nil {
+			return err
+		}
+
+		if res.Broken {
+			fmt.Printf("%s: broken after %d: %s\n", feed.String(), res.LastGoodSeq, res.Error)
+			return fmt.Errorf("verify: feed %s is broken (%s)", feed.ShortSigil(), res.Reason)
+		}
+		fmt.Printf("%s: %d messages ok\n", feed.String(), res.LastGoodSeq)
+		return nil
+	},
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ssbc/go-ssb"
+	multiserver "github.com/ssbc/go-ssb-multiserver"
+	refs "github.com/ssbc/go-ssb-refs"
+	ssbClient "github.com/ssbc/go-ssb/client"
+)
+
+var whoamiCmd = &cli.Command{
+	Name:  "whoami",
+	Usage: "Print the feed of the sbot and its name",
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		self, err := client.Whoami()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(withName(client, self))
+		return nil
+	},
+}
+
+var versionCmd = &cli.Command{
+	Name:  "version",
+	Usage: "Print the version of sbotcli, or of the sbot with --server",
+	Description: `Print the version of sbotcli, or of the sbot with --server.
+
+For the sbot it prints its version, the commit and Go version it was built
+with and the optional features it has enabled, which helps when filing bugs.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "server", Usage: "print the version of the sbot instead"},
+	},
+	Action: func(ctx *cli.Context) error {
+		if !ctx.Bool("server") {
+			fmt.Println(clientVersion(ctx))
+			return nil
+		}
+
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		info, err := client.ServerInfo()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("version:", info.Version)
+		if info.Commit != "" {
+			fmt.Println("commit:", info.Commit)
+		}
+		if info.Build != "" {
+			fmt.Println("built:", info.Build)
+		}
+		fmt.Println("go:", info.GoVersion)
+		fmt.Println("features:", strings.Join(info.Features, ", "))
+		return nil
+	},
+}
+
+var peersCmd = &cli.Command{
+	Name:  "peers",
+	Usage: "List the connected peers with their names",
+	Description: `List the connected peers with their names.
+
+Each line has the address of the peer, its name (if one is known) and how long
+it is connected.`,
+	Action: func(ctx *cli.Context) error {
+		client, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var status ssb.Status
+		err = client.Async(longctx, &status, muxrpc.TypeJSON, muxrpc.Method{"status"})
+		if err != nil {
+			return fmt.Errorf("peers: status call failed: %w", err)
+		}
+
+		for _, p := range status.Peers {
+			addr, err := multiserver.ParseNetAddress([]byte(p.Addr))
+			if err != nil {
+				fmt.Printf("%s\t(connected %s)\n", p.Addr, p.Since)
+				continue
+			}
+			fmt.Printf("%s\t%s\t(connected %s)\n", addr.Addr.String(), withName(client, addr.Ref), p.Since)
+		}
+		return nil
+	},
+}
+
+// withName returns the reference followed by the name of the feed, if it has one
+func withName(client *ssbClient.Client, feed refs.FeedRef) string {
+	name, err := client.NamesSignifier(feed)
+	if err != nil || name == "" || name == feed.String() {
+		return feed.String()
+	}
+	return fmt.Sprintf("%s (%s)", feed.String(), name)
+}
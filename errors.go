@@ -14,6 +14,9 @@ import (
 
 var ErrShuttingDown = fmt.Errorf("ssb: shutting down now") // this is fine
 
+// ErrMessageNotFound is returned if a message isn't stored locally
+var ErrMessageNotFound = fmt.Errorf("ssb: message not found")
+
 type ErrOutOfReach struct {
 	Dist int
 	Max  int
@@ -23,6 +23,7 @@ require (
 	github.com/kylelemons/godebug v1.1.0
 	github.com/libp2p/go-reuseport v0.2.0
 	github.com/machinebox/progress v0.2.0
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/maxbrunsfeld/counterfeiter/v6 v6.5.0
 	github.com/prometheus/client_golang v1.14.0
 	github.com/rs/cors v1.8.3
@@ -43,6 +44,7 @@ require (
 	go.cryptoscope.co/nocomment v0.0.0-20210520094614-fb744e81f810
 	go.mindeco.de v1.12.0
 	golang.org/x/crypto v0.4.0
+	golang.org/x/net v0.3.0
 	golang.org/x/sync v0.1.0
 	golang.org/x/text v0.5.0
 	gonum.org/v1/gonum v0.12.0
@@ -85,7 +87,6 @@ require (
 	go.opencensus.io v0.23.0 // indirect
 	golang.org/x/exp v0.0.0-20221025133541-111beb427cde // indirect
 	golang.org/x/mod v0.6.0 // indirect
-	golang.org/x/net v0.3.0 // indirect
 	golang.org/x/sys v0.3.0 // indirect
 	golang.org/x/tools v0.2.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
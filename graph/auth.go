@@ -46,6 +46,11 @@ func (a *authorizer) Authorize(to refs.FeedRef) error {
 		return nil
 	}
 
+	// the shortest path ignores the block edge if friends follow the peer, so check it directly
+	if fg.Blocks(a.from, to) {
+		return &ssb.ErrOutOfReach{Dist: -1, Max: a.maxHops}
+	}
+
 	// TODO we need to check that `from` is in the graph, instead of checking if it's empty
 	// only important in the _resync existing feed_ case. should maybe not construct this authorizer then?
 	var distLookup *Lookup
@@ -7,6 +7,7 @@ package graph
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"sync"
@@ -65,8 +66,14 @@ type BadgerBuilder struct {
 
 	cacheLock   sync.Mutex
 	cachedGraph *Graph
+	// cachedShared is set once Build handed out the cached graph, the next patch copies it first
+	cachedShared bool
 
 	hmacSecret *[32]byte
+
+	// see Changes, guarded by the cacheLock
+	changeReceivers []chan GraphChange
+	changesClosed   bool
 }
 
 var (
@@ -131,10 +138,17 @@ func (b *BadgerBuilder) Build() (*Graph, error) {
 	defer b.cacheLock.Unlock()
 
 	if b.cachedGraph != nil {
+		b.cachedShared = true
 		return b.cachedGraph, nil
 	}
 
-	err := b.kv.View(func(txn *badger.Txn) error {
+	seq, err := b.idx.GetSeq()
+	if err != nil {
+		return nil, fmt.Errorf("builder: failed to get index sequence: %w", err)
+	}
+	dg.seq = seq
+
+	err = b.kv.View(func(txn *badger.Txn) error {
 		iter := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer iter.Close()
 
@@ -235,9 +249,56 @@ func (b *BadgerBuilder) Build() (*Graph, error) {
 	})
 
 	b.cachedGraph = dg
+	b.cachedShared = true
 	return dg, err
 }
 
+// SaveSnapshot writes the current graph and the sequence of the index to w, see LoadSnapshot
+func (b *BadgerBuilder) SaveSnapshot(w io.Writer) error {
+	g, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	// entries that aren't contacts move the sequence of the index but not the one of the graph
+	seq, err := b.idx.GetSeq()
+	if err != nil {
+		return fmt.Errorf("builder: failed to get index sequence: %w", err)
+	}
+
+	return g.save(w, seq)
+}
+
+// LoadSnapshot uses a graph written by SaveSnapshot instead of building it from the index.
+// Entries that are added to the index afterwards update the loaded graph.
+// If the snapshot was written for a different state of the index, ErrSnapshotOutdated is returned
+// and the graph will be built from the index, like without a snapshot.
+func (b *BadgerBuilder) LoadSnapshot(r io.Reader) error {
+	g, err := LoadGraph(r)
+	if err != nil {
+		return err
+	}
+
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	seq, err := b.idx.GetSeq()
+	if err != nil {
+		return fmt.Errorf("builder: failed to get index sequence: %w", err)
+	}
+
+	if g.seq != seq {
+		return fmt.Errorf("%w (snapshot: %d, index: %d)", ErrSnapshotOutdated, g.seq, seq)
+	}
+
+	b.cachedGraph = g
+	b.cachedShared = false
+	return nil
+}
+
 type Lookup struct {
 	dijk   path.Shortest
 	lookup key2node
@@ -49,6 +49,25 @@ func (b *BadgerBuilder) WaitUntilIndexesAreSynced() {
 	b.idxInSync.Wait()
 }
 
+// patchCachedGraph applies a changed relation of the index entry seq to the cached graph, so that it doesn't need to be rebuilt.
+// Graphs that were returned by Build are not changed, the patch goes to a copy of it.
+// The caller needs to hold the cacheLock.
+func (b *BadgerBuilder) patchCachedGraph(seq int64, from, to refs.FeedRef, rel idxRelationState) {
+	if b.cachedGraph == nil {
+		return
+	}
+
+	if b.cachedShared {
+		b.cachedGraph = b.cachedGraph.clone()
+		b.cachedShared = false
+	}
+
+	b.cachedGraph.Mutex.Lock()
+	b.cachedGraph.setRelation(from, to, rel)
+	b.cachedGraph.seq = seq
+	b.cachedGraph.Mutex.Unlock()
+}
+
 func (b *BadgerBuilder) updateAnnouncement(ctx context.Context, seq int64, val interface{}, idx librarian.SetterIndex) error {
 	b.cacheLock.Lock()
 	b.indexSyncStart()
@@ -69,7 +88,7 @@ func (b *BadgerBuilder) updateAnnouncement(ctx context.Context, seq int64, val i
 		return err
 	}
 
-	announceMsg, ok := legacy.VerifyMetafeedAnnounce(msg.ContentBytes(), msg.Author(), nil) // TODO: hmac support
+	announceMsg, ok := legacy.VerifyMetafeedAnnounce(msg.ContentBytes(), msg.Author(), b.hmacSecret)
 	if !ok {
 		return nil // skip invalid messages
 	}
@@ -130,24 +149,32 @@ func (b *BadgerBuilder) updateContacts(ctx context.Context, seq int64, val inter
 
 	addr := storedrefs.Feed(abs.Author())
 	addr += storedrefs.Feed(c.Contact)
+
+	var rel idxRelationState
 	switch {
 	case c.Following:
-		err = idx.Set(ctx, addr, idxRelValueFollowing)
+		rel = idxRelValueFollowing
 	case c.Blocking:
-		err = idx.Set(ctx, addr, idxRelValueBlocking)
+		rel = idxRelValueBlocking
 	default:
-		err = idx.Set(ctx, addr, idxRelValueNone)
+		rel = idxRelValueNone
 		// cryptix: not sure why this doesn't work
 		// it also removes the node if this is the only follow from that peer
 		// 3 state handling seems saner
 		// err = idx.Delete(ctx, librarian.Addr(addr))
 	}
+
+	err = b.emitChanges(addr, abs.Author(), c.Contact, rel)
+	if err != nil {
+		return err
+	}
+
+	err = idx.Set(ctx, addr, rel)
 	if err != nil {
 		return fmt.Errorf("db/idx contacts: failed to update index. %+v: %w", c, err)
 	}
 
-	b.cachedGraph = nil
-	// TODO: patch existing graph instead of invalidating
+	b.patchCachedGraph(seq, abs.Author(), c.Contact, rel)
 	return nil
 }
 
@@ -219,6 +246,10 @@ func (b *BadgerBuilder) updateMetafeeds(ctx context.Context, seq int64, val inte
 
 	addr := storedrefs.Feed(msg.Author())
 
+	var (
+		subfeed refs.FeedRef
+		rel     = idxRelValueNone
+	)
 	switch justTheType.Type {
 	case "metafeed/add/existing":
 		var addMsg metamngmt.AddExisting
@@ -236,7 +267,8 @@ func (b *BadgerBuilder) updateMetafeeds(ctx context.Context, seq int64, val inte
 		addr += storedrefs.Feed(addMsg.SubFeed)
 
 		level.Info(msgLogger).Log("adding", addMsg.SubFeed.String())
-		err = idx.Set(ctx, addr, idxRelValueMetafeed)
+		subfeed, rel = addMsg.SubFeed, idxRelValueMetafeed
+		err = idx.Set(ctx, addr, rel)
 
 	case "metafeed/add/derived":
 		var addMsg metamngmt.AddDerived
@@ -254,7 +286,8 @@ func (b *BadgerBuilder) updateMetafeeds(ctx context.Context, seq int64, val inte
 		addr += storedrefs.Feed(addMsg.SubFeed)
 
 		level.Info(msgLogger).Log("adding", addMsg.SubFeed.ShortSigil())
-		err = idx.Set(ctx, addr, idxRelValueMetafeed)
+		subfeed, rel = addMsg.SubFeed, idxRelValueMetafeed
+		err = idx.Set(ctx, addr, rel)
 
 	case "metafeed/tombstone":
 		var tMsg metamngmt.Tombstone
@@ -272,16 +305,19 @@ func (b *BadgerBuilder) updateMetafeeds(ctx context.Context, seq int64, val inte
 		addr += storedrefs.Feed(tMsg.SubFeed)
 
 		level.Info(msgLogger).Log("removing", tMsg.SubFeed.ShortSigil())
-		err = idx.Set(ctx, addr, idxRelValueNone)
+		subfeed = tMsg.SubFeed
+		err = idx.Set(ctx, addr, rel)
 
 	default:
 		level.Warn(msgLogger).Log("warning", "unhandeled message type", "type", justTheType.Type)
+		return nil
 	}
 
 	if err != nil {
 		return fmt.Errorf("failed to update metafeed index with message %s: %w", msg.Key().String(), err)
 	}
 
+	b.patchCachedGraph(seq, msg.Author(), subfeed, rel)
 	return nil
 
 }
@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	librarian "github.com/ssbc/margaret/indexes"
+)
+
+// ChangeKind says how the relation of one feed to another changed
+type ChangeKind string
+
+const (
+	ChangeFollow   ChangeKind = "follow"
+	ChangeUnfollow ChangeKind = "unfollow"
+	ChangeBlock    ChangeKind = "block"
+	ChangeUnblock  ChangeKind = "unblock"
+)
+
+// GraphChange is an edge of the graph that was added (follow, block) or removed (unfollow, unblock) by a contact message of From
+type GraphChange struct {
+	From, To refs.FeedRef
+	Kind     ChangeKind
+}
+
+// changesBuffer is how many changes are kept for each receiver of Changes
+const changesBuffer = 256
+
+// Changes returns a channel which receives the changes of the graph, as new contact messages are indexed.
+// Contact messages that don't change a relation, like following someone twice, are not sent.
+// Switching from following to blocking someone is sent as an unfollow and a block.
+// Every call returns a new channel. If a receiver doesn't keep up and its buffer is full, changes are dropped for it.
+// The channel is closed once ctx is done or by Close.
+func (b *BadgerBuilder) Changes(ctx context.Context) <-chan GraphChange {
+	ch := make(chan GraphChange, changesBuffer)
+
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	if b.changesClosed || ctx.Err() != nil {
+		close(ch)
+		return ch
+	}
+	b.changeReceivers = append(b.changeReceivers, ch)
+
+	go func() {
+		<-ctx.Done()
+		b.removeChangeReceiver(ch)
+	}()
+	return ch
+}
+
+// removeChangeReceiver closes ch and stops sending changes to it, unless Close did that already
+func (b *BadgerBuilder) removeChangeReceiver(ch chan GraphChange) {
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	for i, rcv := range b.changeReceivers {
+		if rcv == ch {
+			b.changeReceivers = append(b.changeReceivers[:i], b.changeReceivers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// Close closes the channels of Changes
+func (b *BadgerBuilder) Close() error {
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	if b.changesClosed {
+		return nil
+	}
+	b.changesClosed = true
+	for _, ch := range b.changeReceivers {
+		close(ch)
+	}
+	b.changeReceivers = nil
+	return nil
+}
+
+// emitChanges sends the changes of the relation at addr to the receivers of Changes.
+// It needs to be called before the new relation is written to the index and the caller needs to hold the cacheLock.
+func (b *BadgerBuilder) emitChanges(addr librarian.Addr, from, to refs.FeedRef, rel idxRelationState) error {
+	if len(b.changeReceivers) == 0 {
+		return nil
+	}
+
+	prev, err := b.storedRelation(addr)
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range relationChanges(prev, rel) {
+		chg := GraphChange{From: from, To: to, Kind: kind}
+		for _, ch := range b.changeReceivers {
+			select {
+			case ch <- chg:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// storedRelation reads the relation at addr from the index, including the writes it didn't flush yet
+func (b *BadgerBuilder) storedRelation(addr librarian.Addr) (idxRelationState, error) {
+	obv, err := b.idx.Get(context.Background(), addr)
+	if err != nil {
+		return idxRelValueNone, fmt.Errorf("graph/changes: failed to get previous relation: %w", err)
+	}
+	v, err := obv.Value()
+	if err != nil {
+		return idxRelValueNone, fmt.Errorf("graph/changes: failed to read previous relation: %w", err)
+	}
+
+	switch tv := v.(type) {
+	case idxRelationState:
+		return tv, nil
+	case int:
+		return idxRelationState(tv), nil
+	case librarian.UnsetValue:
+		return idxRelValueNone, nil
+	default:
+		return idxRelValueNone, fmt.Errorf("graph/changes: unexpected relation type %T", v)
+	}
+}
+
+// relationChanges returns the changes from relation prev to next, in the order they happened
+func relationChanges(prev, next idxRelationState) []ChangeKind {
+	if prev == next {
+		return nil
+	}
+
+	var changes []ChangeKind
+	switch prev {
+	case idxRelValueFollowing:
+		changes = append(changes, ChangeUnfollow)
+	case idxRelValueBlocking:
+		changes = append(changes, ChangeUnblock)
+	}
+	switch next {
+	case idxRelValueFollowing:
+		changes = append(changes, ChangeFollow)
+	case idxRelValueBlocking:
+		changes = append(changes, ChangeBlock)
+	}
+	return changes
+}
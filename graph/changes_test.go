@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanges(t *testing.T) {
+	r := require.New(t)
+
+	tc := makeBadger(t)
+	builder := tc.gbuilder.(*BadgerBuilder)
+
+	alice := newPublisher(t, tc.root, tc.userLogs)
+	bob := newPublisher(t, tc.root, tc.userLogs)
+	claire := newPublisher(t, tc.root, tc.userLogs)
+
+	// relations from before are known
+	alice.follow(bob.key.ID())
+	builder.WaitUntilIndexesAreSynced()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := builder.Changes(ctx)
+	unsubscribed, unsubscribe := context.WithCancel(context.Background())
+	other := builder.Changes(unsubscribed)
+
+	next := func(from, to refs.FeedRef, kind ChangeKind) {
+		select {
+		case chg := <-changes:
+			r.True(chg.From.Equal(from), "wrong from")
+			r.True(chg.To.Equal(to), "wrong to")
+			r.Equal(kind, chg.Kind)
+		case <-time.After(5 * time.Second):
+			r.FailNow("timeout waiting for change", kind)
+		}
+	}
+
+	alice.follow(bob.key.ID()) // no change
+	alice.follow(claire.key.ID())
+	next(alice.key.ID(), claire.key.ID(), ChangeFollow)
+
+	// the other receiver stops getting changes once its context is done
+	r.Equal(ChangeFollow, (<-other).Kind)
+	unsubscribe()
+	select {
+	case _, open := <-other:
+		r.False(open, "change after unsubscribing")
+	case <-time.After(5 * time.Second):
+		r.FailNow("timeout waiting for the channel to close")
+	}
+
+	alice.block(bob.key.ID())
+	next(alice.key.ID(), bob.key.ID(), ChangeUnfollow)
+	next(alice.key.ID(), bob.key.ID(), ChangeBlock)
+
+	bob.block(claire.key.ID())
+	next(bob.key.ID(), claire.key.ID(), ChangeBlock)
+
+	alice.unblock(bob.key.ID())
+	next(alice.key.ID(), bob.key.ID(), ChangeUnblock)
+
+	alice.unfollow(claire.key.ID())
+	next(alice.key.ID(), claire.key.ID(), ChangeUnfollow)
+
+	builder.WaitUntilIndexesAreSynced()
+	select {
+	case chg := <-changes:
+		r.FailNow("unexpected change", "%+v", chg)
+	default:
+	}
+
+	r.NoError(builder.Close())
+	_, open := <-changes
+	r.False(open)
+	_, open = <-builder.Changes(context.Background())
+	r.False(open)
+}
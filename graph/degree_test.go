@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import "fmt"
+
+var degreeScenarios = []PeopleTestCase{
+	{
+		name: "degree",
+		ops: []PeopleOp{
+			PeopleOpNewPeer{"alice"},
+			PeopleOpNewPeer{"bob"},
+			PeopleOpNewPeer{"claire"},
+			PeopleOpNewPeer{"debora"},
+			PeopleOpNewPeer{"egon"},
+			PeopleOpNewPeer{"frank"},
+
+			PeopleOpFollow{"bob", "alice"},
+			PeopleOpFollow{"alice", "bob"},
+
+			// claire changed her mind
+			PeopleOpFollow{"claire", "alice"},
+			PeopleOpUnfollow{"claire", "alice"},
+
+			PeopleOpBlock{"debora", "alice"},
+
+			// egon too
+			PeopleOpBlock{"egon", "alice"},
+			PeopleOpUnblock{"egon", "alice"},
+
+			PeopleOpBlock{"alice", "frank"},
+			PeopleOpFollow{"alice", "egon"},
+			PeopleOpUnfollow{"alice", "egon"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertDegree("alice", 1, 1, 1, 1),
+			PeopleAssertDegree("bob", 1, 1, 0, 0),
+			PeopleAssertDegree("claire", 0, 0, 0, 0),
+			PeopleAssertDegree("debora", 0, 0, 0, 1),
+			PeopleAssertDegree("frank", 0, 0, 1, 0),
+		},
+	},
+}
+
+func PeopleAssertDegree(who string, inFollows, outFollows, blockedBy, blocking int) PeopleAssertMaker {
+	return func(state *testState) PeopleAssert {
+		pWho, ok := state.peers[who]
+		if !ok {
+			state.t.Fatal("no such peer:", who)
+			return nil
+		}
+
+		return func(bld Builder) error {
+			g, err := bld.Build()
+			if err != nil {
+				return err
+			}
+
+			in, out, by, blocks := g.Degree(pWho.key.ID())
+			if in != inFollows || out != outFollows || by != blockedBy || blocks != blocking {
+				return fmt.Errorf("Degree() of %s: got %d %d %d %d, wanted %d %d %d %d",
+					who, in, out, by, blocks, inFollows, outFollows, blockedBy, blocking)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import refs "github.com/ssbc/go-ssb-refs"
+
+var formatsScenarios = []PeopleTestCase{
+	{
+		name: "follows across feed formats",
+		ops: []PeopleOp{
+			PeopleOpNewPeerWithAlgo{"alice", refs.RefAlgoFeedSSB1},
+			PeopleOpNewPeerWithAlgo{"bob", refs.RefAlgoFeedGabby},
+			PeopleOpNewPeerWithAlgo{"claire", refs.RefAlgoFeedSSB1},
+			PeopleOpNewPeerWithAlgo{"debora", refs.RefAlgoFeedGabby},
+
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"bob", "alice"},
+			PeopleOpFollow{"bob", "claire"},
+			PeopleOpFollow{"claire", "bob"},
+			PeopleOpFollow{"claire", "debora"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertFollows("alice", "bob", true),
+			PeopleAssertFollows("bob", "alice", true),
+			PeopleAssertFollows("bob", "claire", true),
+			PeopleAssertFollows("claire", "debora", true),
+			PeopleAssertFollows("debora", "claire", false),
+			PeopleAssertMutuals("alice", "bob"),
+			PeopleAssertMutuals("bob", "alice", "claire"),
+
+			// hops recurse over friends (mutual follows) of both formats
+			PeopleAssertHops("alice", 0, "bob"),
+			PeopleAssertHops("alice", 1, "bob", "claire"),
+			PeopleAssertHops("alice", 2, "bob", "claire", "debora"),
+			PeopleAssertHops("bob", 1, "alice", "claire", "debora"),
+			PeopleAssertAuthorize("alice", "claire", 1, true),
+			PeopleAssertAuthorize("alice", "debora", 1, false),
+			PeopleAssertAuthorize("alice", "debora", 2, true),
+		},
+	},
+	{
+		name: "blocks across feed formats",
+		ops: []PeopleOp{
+			PeopleOpNewPeerWithAlgo{"alice", refs.RefAlgoFeedSSB1},
+			PeopleOpNewPeerWithAlgo{"bob", refs.RefAlgoFeedGabby},
+			PeopleOpNewPeerWithAlgo{"claire", refs.RefAlgoFeedSSB1},
+			PeopleOpNewPeerWithAlgo{"debora", refs.RefAlgoFeedGabby},
+
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"bob", "claire"},
+			PeopleOpFollow{"claire", "debora"},
+			PeopleOpFollow{"debora", "alice"},
+
+			// gabby blocks classic
+			PeopleOpBlock{"bob", "alice"},
+			// classic blocks gabby
+			PeopleOpBlock{"alice", "debora"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertBlocks("bob", "alice", true),
+			PeopleAssertBlocks("alice", "debora", true),
+			PeopleAssertBlocks("alice", "bob", false),
+			PeopleAssertOnBlocklist("alice", "debora"),
+			PeopleAssertOnBlocklist("bob", "alice"),
+
+			// the blocks win, even though the blocked feeds can be reached over friends
+			PeopleAssertAuthorize("bob", "alice", 2, false),
+			PeopleAssertAuthorize("alice", "debora", 2, false),
+			PeopleAssertAuthorize("alice", "claire", 1, true),
+		},
+	},
+}
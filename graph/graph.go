@@ -24,12 +24,16 @@ type Graph struct {
 	sync.Mutex
 	*simple.WeightedDirectedGraph
 	lookup key2node
+
+	// the sequence of the index this graph represents, see Seq()
+	seq int64
 }
 
 func NewGraph() *Graph {
 	return &Graph{
 		WeightedDirectedGraph: simple.NewWeightedDirectedGraph(0, math.Inf(1)),
 		lookup:                make(key2node),
+		seq:                   -1,
 	}
 }
 
@@ -105,6 +109,72 @@ func (g *Graph) BlockedList(from refs.FeedRef) *ssb.StrFeedSet {
 	return blocked
 }
 
+// IsMutual returns true if a and b follow each other
+func (g *Graph) IsMutual(a, b refs.FeedRef) bool {
+	return g.Follows(a, b) && g.Follows(b, a)
+}
+
+// Mutuals returns the feeds that who follows and which follow who back
+func (g *Graph) Mutuals(who refs.FeedRef) []refs.FeedRef {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+	nWho, has := g.lookup[storedrefs.Feed(who)]
+	if !has {
+		return nil
+	}
+	whoID := nWho.ID()
+
+	var mutuals []refs.FeedRef
+	edgs := g.From(whoID)
+	for edgs.Next() {
+		nTo := edgs.Node()
+		if g.Edge(whoID, nTo.ID()).(graph.WeightedEdge).Weight() != 1 {
+			continue
+		}
+
+		if !g.HasEdgeFromTo(nTo.ID(), whoID) || g.Edge(nTo.ID(), whoID).(graph.WeightedEdge).Weight() != 1 {
+			continue
+		}
+
+		mutuals = append(mutuals, nTo.(*contactNode).feed)
+	}
+	return mutuals
+}
+
+// Degree counts the current follows and blocks of who, in both directions.
+// Unfollowed and unblocked feeds are not part of the graph, so they are not counted.
+// The edges between a metafeed and its subfeeds (weight 0.1) are neither follows nor blocks and are ignored.
+func (g *Graph) Degree(who refs.FeedRef) (inFollows, outFollows, blockedBy, blocking int) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+	nWho, has := g.lookup[storedrefs.Feed(who)]
+	if !has {
+		return 0, 0, 0, 0
+	}
+	whoID := nWho.ID()
+
+	edgs := g.From(whoID)
+	for edgs.Next() {
+		w := g.Edge(whoID, edgs.Node().ID()).(graph.WeightedEdge).Weight()
+		if w == 1 {
+			outFollows++
+		} else if math.IsInf(w, 1) {
+			blocking++
+		}
+	}
+
+	edgs = g.To(whoID)
+	for edgs.Next() {
+		w := g.Edge(edgs.Node().ID(), whoID).(graph.WeightedEdge).Weight()
+		if w == 1 {
+			inFollows++
+		} else if math.IsInf(w, 1) {
+			blockedBy++
+		}
+	}
+	return inFollows, outFollows, blockedBy, blocking
+}
+
 func (g *Graph) MakeDijkstra(from refs.FeedRef) (*Lookup, error) {
 	g.Mutex.Lock()
 	defer g.Mutex.Unlock()
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import "fmt"
+
+var mutualsScenarios = []PeopleTestCase{
+	{
+		name: "mutuals",
+		ops: []PeopleOp{
+			PeopleOpNewPeer{"alice"},
+			PeopleOpNewPeer{"bob"},
+			PeopleOpNewPeer{"claire"},
+			PeopleOpNewPeer{"debora"},
+			PeopleOpNewPeer{"egon"},
+
+			// bob follows back
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"bob", "alice"},
+
+			// claire doesn't
+			PeopleOpFollow{"alice", "claire"},
+
+			// debora follows alice but not the other way around
+			PeopleOpFollow{"debora", "alice"},
+
+			// egon blocks back
+			PeopleOpFollow{"alice", "egon"},
+			PeopleOpBlock{"egon", "alice"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertMutuals("alice", "bob"),
+			PeopleAssertMutuals("bob", "alice"),
+			PeopleAssertMutuals("claire"),
+			PeopleAssertMutuals("debora"),
+			PeopleAssertMutuals("egon"),
+		},
+	},
+}
+
+func PeopleAssertMutuals(who string, want ...string) PeopleAssertMaker {
+	return func(state *testState) PeopleAssert {
+		pWho, ok := state.peers[who]
+		if !ok {
+			state.t.Fatal("no such peer:", who)
+			return nil
+		}
+
+		return func(bld Builder) error {
+			g, err := bld.Build()
+			if err != nil {
+				return err
+			}
+
+			got := g.Mutuals(pWho.key.ID())
+			if len(got) != len(want) {
+				return fmt.Errorf("Mutuals() wrong length: %d (wanted %d)", len(got), len(want))
+			}
+
+			for _, name := range want {
+				p, ok := state.peers[name]
+				if !ok {
+					state.t.Fatal("no such wanted peer:", name)
+					return nil
+				}
+
+				var found bool
+				for _, m := range got {
+					if m.Equal(p.key.ID()) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return fmt.Errorf("Mutuals() of %s is missing %s", who, name)
+				}
+
+				if !g.IsMutual(pWho.key.ID(), p.key.ID()) {
+					return fmt.Errorf("IsMutual(%s, %s) is false", who, name)
+				}
+			}
+			return nil
+		}
+	}
+}
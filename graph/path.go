@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"math"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"gonum.org/v1/gonum/graph"
+
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+)
+
+// Path returns the shortest chain of follows from one feed to the other, including both of them.
+// For instance [alice, friend, bob] if alice follows friend who follows bob.
+// Feeds which from blocks are not part of any chain, so they can't be reached over a friend who follows them.
+// It returns false and an empty slice if there is no such chain.
+func (g *Graph) Path(from, to refs.FeedRef) ([]refs.FeedRef, bool) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	nFrom, has := g.lookup[storedrefs.Feed(from)]
+	if !has {
+		return []refs.FeedRef{}, false
+	}
+	nTo, has := g.lookup[storedrefs.Feed(to)]
+	if !has {
+		return []refs.FeedRef{}, false
+	}
+
+	blocked := func(id int64) bool {
+		return g.HasEdgeFromTo(nFrom.ID(), id) && math.IsInf(g.Edge(nFrom.ID(), id).(graph.WeightedEdge).Weight(), 1)
+	}
+	if blocked(nTo.ID()) {
+		return []refs.FeedRef{}, false
+	}
+
+	// breadth first over the follows, remembering how each feed was reached
+	var (
+		cameFrom = map[int64]int64{nFrom.ID(): nFrom.ID()}
+		queue    = []int64{nFrom.ID()}
+	)
+	for len(queue) > 0 && !hasKey(cameFrom, nTo.ID()) {
+		curr := queue[0]
+		queue = queue[1:]
+
+		edgs := g.From(curr)
+		for edgs.Next() {
+			next := edgs.Node().ID()
+			if hasKey(cameFrom, next) || blocked(next) {
+				continue
+			}
+			if g.Edge(curr, next).(graph.WeightedEdge).Weight() != 1 {
+				continue
+			}
+			cameFrom[next] = curr
+			queue = append(queue, next)
+		}
+	}
+
+	if !hasKey(cameFrom, nTo.ID()) {
+		return []refs.FeedRef{}, false
+	}
+
+	var path []refs.FeedRef
+	for id := nTo.ID(); ; id = cameFrom[id] {
+		path = append(path, g.Node(id).(*contactNode).feed)
+		if id == nFrom.ID() {
+			break
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true
+}
+
+func hasKey(m map[int64]int64, k int64) bool {
+	_, has := m[k]
+	return has
+}
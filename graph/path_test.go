@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import "fmt"
+
+var pathScenarios = []PeopleTestCase{
+	{
+		name: "follow paths",
+		ops: []PeopleOp{
+			PeopleOpNewPeer{"alice"},
+			PeopleOpNewPeer{"bob"},
+			PeopleOpNewPeer{"claire"},
+			PeopleOpNewPeer{"debora"},
+			PeopleOpNewPeer{"egon"},
+			PeopleOpNewPeer{"franz"},
+			PeopleOpNewPeer{"gerta"},
+			PeopleOpNewPeer{"heinz"},
+
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"bob", "claire"},
+			PeopleOpFollow{"claire", "debora"},
+
+			// a shorter way to egon over franz
+			PeopleOpFollow{"claire", "egon"},
+			PeopleOpFollow{"alice", "franz"},
+			PeopleOpFollow{"franz", "egon"},
+
+			// alice blocks gerta, even though bob follows her
+			PeopleOpFollow{"bob", "gerta"},
+			PeopleOpBlock{"alice", "gerta"},
+
+			// the only way to heinz leads over gerta
+			PeopleOpFollow{"gerta", "heinz"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertPath("alice", "bob", "alice", "bob"),
+			PeopleAssertPath("alice", "debora", "alice", "bob", "claire", "debora"),
+			PeopleAssertPath("alice", "egon", "alice", "franz", "egon"),
+			PeopleAssertPath("alice", "alice", "alice"),
+
+			// no paths
+			PeopleAssertPath("alice", "gerta"),
+			PeopleAssertPath("alice", "heinz"),
+			PeopleAssertPath("debora", "alice"),
+		},
+	},
+}
+
+// PeopleAssertPath checks the path between from and to, leave want empty to check that there is none
+func PeopleAssertPath(from, to string, want ...string) PeopleAssertMaker {
+	return func(state *testState) PeopleAssert {
+		pFrom, ok := state.peers[from]
+		if !ok {
+			state.t.Fatal("no such from peer:", from)
+			return nil
+		}
+		pTo, ok := state.peers[to]
+		if !ok {
+			state.t.Fatal("no such to peer:", to)
+			return nil
+		}
+
+		return func(bld Builder) error {
+			g, err := bld.Build()
+			if err != nil {
+				return err
+			}
+
+			got, has := g.Path(pFrom.key.ID(), pTo.key.ID())
+			if has != (len(want) > 0) {
+				return fmt.Errorf("Path(%s, %s): expected a path: %v, got %v", from, to, len(want) > 0, has)
+			}
+			if got == nil {
+				return fmt.Errorf("Path(%s, %s): returned a nil slice", from, to)
+			}
+			if len(got) != len(want) {
+				return fmt.Errorf("Path(%s, %s) wrong length: %d (wanted %d)", from, to, len(got), len(want))
+			}
+
+			for i, name := range want {
+				if state.refToName[got[i].String()] != name {
+					return fmt.Errorf("Path(%s, %s) #%d: expected %s but got %s", from, to, i, name, state.refToName[got[i].String()])
+				}
+			}
+			return nil
+		}
+	}
+}
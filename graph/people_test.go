@@ -505,6 +505,13 @@ func TestPeople(t *testing.T) {
 	tcs = append(tcs, hopsScenarios...)
 	tcs = append(tcs, metafeedsScenarios...)
 	tcs = append(tcs, deleteScenarios...)
+	tcs = append(tcs, suggestScenarios...)
+	tcs = append(tcs, trustScenarios...)
+	tcs = append(tcs, formatsScenarios...)
+	tcs = append(tcs, mutualsScenarios...)
+	tcs = append(tcs, subgraphScenarios...)
+	tcs = append(tcs, pathScenarios...)
+	tcs = append(tcs, degreeScenarios...)
 
 	for _, tc := range tcs {
 		t.Run(tc.name+"/badger", tc.run(makeBadger))
@@ -25,12 +25,21 @@ func (g *Graph) NodeCount() int {
 	return g.WeightedDirectedGraph.Nodes().Len()
 }
 
-func (g *Graph) RenderSVG(w io.Writer) error {
+// MarshalDOT returns the graph in the graphviz dot format
+func (g *Graph) MarshalDOT() ([]byte, error) {
 	g.Mutex.Lock()
 	defer g.Mutex.Unlock()
 	dotbytes, err := dot.Marshal(g, "trust", "", "")
 	if err != nil {
-		return fmt.Errorf("dot marshal failed: %w", err)
+		return nil, fmt.Errorf("dot marshal failed: %w", err)
+	}
+	return dotbytes, nil
+}
+
+func (g *Graph) RenderSVG(w io.Writer) error {
+	dotbytes, err := g.MarshalDOT()
+	if err != nil {
+		return err
 	}
 	dotR := bytes.NewReader(dotbytes)
 
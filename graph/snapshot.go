@@ -0,0 +1,198 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/simple"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+)
+
+// snapshotVersion needs to be increased if the format of the snapshot changes
+const snapshotVersion = 1
+
+// ErrSnapshotOutdated is returned by LoadSnapshot if the snapshot doesn't match the state of the index
+var ErrSnapshotOutdated = errors.New("graph: snapshot doesn't match the index")
+
+type snapshotEdge struct {
+	From refs.FeedRef     `json:"from"`
+	To   refs.FeedRef     `json:"to"`
+	Rel  idxRelationState `json:"rel"`
+}
+
+type snapshot struct {
+	Version int   `json:"version"`
+	Seq     int64 `json:"seq"`
+
+	// also contains the feeds without edges, so that the lookup is the same as after a fresh build
+	Nodes []refs.FeedRef `json:"nodes"`
+	Edges []snapshotEdge `json:"edges"`
+}
+
+// Seq returns the sequence of the contacts index the graph was built from.
+// It is -1 for graphs that didn't come from an index.
+func (g *Graph) Seq() int64 {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+	return g.seq
+}
+
+// Save writes the nodes and edges of the graph and the sequence they are valid for to w.
+func (g *Graph) Save(w io.Writer) error {
+	return g.save(w, g.Seq())
+}
+
+// save writes the graph as valid for the index sequence seq
+func (g *Graph) save(w io.Writer, seq int64) error {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	snap := snapshot{
+		Version: snapshotVersion,
+		Seq:     seq,
+	}
+
+	nodes := g.Nodes()
+	for nodes.Next() {
+		snap.Nodes = append(snap.Nodes, nodes.Node().(*contactNode).feed)
+	}
+
+	edges := g.WeightedEdges()
+	for edges.Next() {
+		edg := edges.WeightedEdge()
+
+		rel, ok := relationOf(edg.Weight())
+		if !ok {
+			return fmt.Errorf("graph: unexpected edge weight %f", edg.Weight())
+		}
+
+		snap.Edges = append(snap.Edges, snapshotEdge{
+			From: edg.From().(*contactNode).feed,
+			To:   edg.To().(*contactNode).feed,
+			Rel:  rel,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// LoadGraph reads a graph that was written with Save.
+func LoadGraph(r io.Reader) (*Graph, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("graph: failed to decode snapshot: %w", err)
+	}
+
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("graph: unsupported snapshot version %d", snap.Version)
+	}
+
+	g := NewGraph()
+	g.seq = snap.Seq
+
+	for _, feed := range snap.Nodes {
+		g.getOrAddNode(feed)
+	}
+
+	for i, e := range snap.Edges {
+		if e.Rel == idxRelValueNone || e.Rel > idxRelValueMetafeed {
+			return nil, fmt.Errorf("graph: invalid relation on snapshot edge %d: %d", i, e.Rel)
+		}
+		g.setRelation(e.From, e.To, e.Rel)
+	}
+
+	return g, nil
+}
+
+// relationOf returns the relation that is stored as an edge with weight w
+func relationOf(w float64) (idxRelationState, bool) {
+	switch {
+	case w == 1:
+		return idxRelValueFollowing, true
+	case math.IsInf(w, 1):
+		return idxRelValueBlocking, true
+	case w == 0.1:
+		return idxRelValueMetafeed, true
+	default:
+		return idxRelValueNone, false
+	}
+}
+
+// clone returns a copy of the graph, which can be changed without affecting g
+func (g *Graph) clone() *Graph {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	c := NewGraph()
+	c.seq = g.seq
+
+	nodes := g.Nodes()
+	for nodes.Next() {
+		n := nodes.Node().(*contactNode)
+		c.getOrAddNode(n.feed).name = n.name
+	}
+
+	edges := g.WeightedEdges()
+	for edges.Next() {
+		edg := edges.WeightedEdge()
+		if rel, ok := relationOf(edg.Weight()); ok {
+			c.setRelation(edg.From().(*contactNode).feed, edg.To().(*contactNode).feed, rel)
+		}
+	}
+	return c
+}
+
+func (g *Graph) getOrAddNode(feed refs.FeedRef) *contactNode {
+	addr := storedrefs.Feed(feed)
+	node, has := g.lookup[addr]
+	if !has {
+		node = &contactNode{g.NewNode(), feed, ""}
+		g.AddNode(node)
+		g.lookup[addr] = node
+	}
+	return node
+}
+
+// setRelation updates the edge between from and to, like Build does for an entry of the index.
+// The caller needs to hold the lock of the graph.
+func (g *Graph) setRelation(from, to refs.FeedRef, rel idxRelationState) {
+	if from.Equal(to) {
+		// contact self?!
+		return
+	}
+
+	nFrom := g.getOrAddNode(from)
+	nTo := g.getOrAddNode(to)
+
+	var edg graph.WeightedEdge
+	switch rel {
+	case idxRelValueFollowing:
+		edg = contactEdge{
+			WeightedEdge: simple.WeightedEdge{F: nFrom, T: nTo, W: 1},
+		}
+	case idxRelValueBlocking:
+		edg = contactEdge{
+			WeightedEdge: simple.WeightedEdge{F: nFrom, T: nTo, W: math.Inf(1)},
+			isBlock:      true,
+		}
+	case idxRelValueMetafeed:
+		edg = metafeedEdge{
+			WeightedEdge: simple.WeightedEdge{F: nFrom, T: nTo, W: 0.1},
+		}
+	default:
+		g.RemoveEdge(nFrom.ID(), nTo.ID())
+		return
+	}
+
+	g.SetWeightedEdge(edg)
+}
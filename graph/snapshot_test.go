@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	r := require.New(t)
+
+	tc := makeBadger(t)
+	bld := tc.gbuilder.(*BadgerBuilder)
+
+	alice := tc.newPublisher(t)
+	bob := tc.newPublisher(t)
+	claire := tc.newPublisher(t)
+
+	// Build only waits for messages which are already being indexed
+	// and only sees the writes of the index once they are flushed
+	waitForIndex := func() {
+		r.Eventually(func() bool {
+			seq, err := bld.idx.GetSeq()
+			return err == nil && seq == tc.root.Seq()
+		}, 5*time.Second, 10*time.Millisecond)
+		r.NoError(bld.idx.(interface{ Flush() error }).Flush())
+	}
+
+	alice.follow(bob.key.ID())
+	bob.block(claire.key.ID())
+	claire.follow(alice.key.ID())
+	claire.unfollow(alice.key.ID())
+	waitForIndex()
+
+	g, err := bld.Build()
+	r.NoError(err)
+	r.Equal(3, g.NodeCount())
+
+	var snap bytes.Buffer
+	r.NoError(bld.SaveSnapshot(&snap))
+	saved := snap.Bytes()
+
+	loaded, err := LoadGraph(bytes.NewReader(saved))
+	r.NoError(err)
+	r.Equal(g.Seq(), loaded.Seq())
+	r.NotEqual(int64(-1), loaded.Seq())
+	r.Equal(3, loaded.NodeCount())
+	r.True(loaded.Follows(alice.key.ID(), bob.key.ID()))
+	r.True(loaded.Blocks(bob.key.ID(), claire.key.ID()))
+	r.False(loaded.Follows(claire.key.ID(), alice.key.ID()))
+
+	// the builder uses the loaded graph and updates it with new messages
+	r.NoError(bld.LoadSnapshot(bytes.NewReader(saved)))
+	alice.follow(claire.key.ID())
+	waitForIndex()
+
+	g, err = bld.Build()
+	r.NoError(err)
+	r.True(g.Follows(alice.key.ID(), claire.key.ID()))
+	r.True(g.Blocks(bob.key.ID(), claire.key.ID()))
+	seq, err := bld.idx.GetSeq()
+	r.NoError(err)
+	r.Equal(seq, g.Seq(), "sequence wasn't patched")
+
+	// graphs that were handed out don't change, new ones get the patches
+	bob.follow(alice.key.ID())
+	waitForIndex()
+
+	r.False(g.Follows(bob.key.ID(), alice.key.ID()), "built graph was changed")
+	patched, err := bld.Build()
+	r.NoError(err)
+	r.True(patched.Follows(bob.key.ID(), alice.key.ID()))
+	r.True(patched.Follows(alice.key.ID(), claire.key.ID()))
+	r.Greater(patched.Seq(), g.Seq())
+
+	// now the old snapshot doesn't match the index anymore
+	err = bld.LoadSnapshot(bytes.NewReader(saved))
+	r.True(errors.Is(err, ErrSnapshotOutdated), "unexpected error: %v", err)
+
+	// broken snapshots
+	err = bld.LoadSnapshot(bytes.NewReader(saved[:len(saved)/2]))
+	r.Error(err)
+	r.False(errors.Is(err, ErrSnapshotOutdated))
+
+	wrongVersion := bytes.Replace(saved, []byte(`"version":1`), []byte(`"version":23`), 1)
+	_, err = LoadGraph(bytes.NewReader(wrongVersion))
+	r.Error(err)
+}
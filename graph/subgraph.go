@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/simple"
+	"gonum.org/v1/gonum/graph/traverse"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+)
+
+// Subgraph returns a new graph with the feeds that can be reached from center within hops follow edges
+// and all the edges (follows, blocks and metafeeds) between them.
+// Zero hops only returns center. If center isn't in the graph, the returned graph is empty.
+func (g *Graph) Subgraph(center refs.FeedRef, hops int) *Graph {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	sub := NewGraph()
+
+	nCenter, has := g.lookup[storedrefs.Feed(center)]
+	if !has {
+		return sub
+	}
+
+	var rg graphReducer
+	rg.wanted = make(wantedMap)
+	rg.graph = simple.NewWeightedDirectedGraph(0, math.Inf(1))
+
+	bf := traverse.BreadthFirst{
+		Traverse: func(e graph.Edge) bool {
+			return e.(graph.WeightedEdge).Weight() == 1
+		},
+		Visit: func(n graph.Node) {
+			rg.wanted[n.ID()] = struct{}{}
+		},
+	}
+	bf.Walk(g, nCenter, func(_ graph.Node, depth int) bool {
+		return depth >= hops
+	})
+
+	graph.CopyWeighted(rg, g)
+
+	sub.WeightedDirectedGraph = rg.graph
+	for id := range rg.wanted {
+		ctNode := g.Node(id).(*contactNode)
+		sub.lookup[storedrefs.Feed(ctNode.feed)] = ctNode
+	}
+	return sub
+}
+
+// Relations are the outgoing edges of a feed, as returned by AdjacencyList
+type Relations struct {
+	Follows  []refs.FeedRef `json:"follows"`
+	Blocks   []refs.FeedRef `json:"blocks"`
+	Subfeeds []refs.FeedRef `json:"subfeeds,omitempty"`
+}
+
+// AdjacencyList returns the relations of every feed in the graph, keyed by the feed reference
+func (g *Graph) AdjacencyList() map[string]Relations {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	list := make(map[string]Relations, len(g.lookup))
+	nodes := g.Nodes()
+	for nodes.Next() {
+		nFrom := nodes.Node().(*contactNode)
+
+		rels := Relations{
+			Follows: []refs.FeedRef{},
+			Blocks:  []refs.FeedRef{},
+		}
+		edgs := g.From(nFrom.ID())
+		for edgs.Next() {
+			nTo := edgs.Node().(*contactNode)
+			switch w := g.Edge(nFrom.ID(), nTo.ID()).(graph.WeightedEdge).Weight(); {
+			case w == 1:
+				rels.Follows = append(rels.Follows, nTo.feed)
+			case math.IsInf(w, 1):
+				rels.Blocks = append(rels.Blocks, nTo.feed)
+			case w == 0.1:
+				rels.Subfeeds = append(rels.Subfeeds, nTo.feed)
+			}
+		}
+		list[nFrom.feed.String()] = rels
+	}
+	return list
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import "fmt"
+
+var subgraphScenarios = []PeopleTestCase{
+	{
+		name: "subgraph",
+		ops: []PeopleOp{
+			PeopleOpNewPeer{"alice"},
+			PeopleOpNewPeer{"bob"},
+			PeopleOpNewPeer{"claire"},
+			PeopleOpNewPeer{"debora"},
+			PeopleOpNewPeer{"egon"},
+
+			// a chain of follows
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"bob", "claire"},
+			PeopleOpFollow{"claire", "debora"},
+
+			// blocks are kept between included feeds but not walked
+			PeopleOpBlock{"bob", "alice"},
+			PeopleOpBlock{"alice", "egon"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertSubgraph("alice", 0, "alice"),
+			PeopleAssertSubgraph("alice", 1, "alice", "bob"),
+			PeopleAssertSubgraph("alice", 2, "alice", "bob", "claire"),
+			PeopleAssertSubgraph("alice", 5, "alice", "bob", "claire", "debora"),
+			PeopleAssertSubgraph("claire", 1, "claire", "debora"),
+		},
+	},
+}
+
+func PeopleAssertSubgraph(center string, hops int, want ...string) PeopleAssertMaker {
+	return func(state *testState) PeopleAssert {
+		pCenter, ok := state.peers[center]
+		if !ok {
+			state.t.Fatal("no such peer:", center)
+			return nil
+		}
+
+		return func(bld Builder) error {
+			g, err := bld.Build()
+			if err != nil {
+				return err
+			}
+
+			sub := g.Subgraph(pCenter.key.ID(), hops)
+			if n := sub.NodeCount(); n != len(want) {
+				return fmt.Errorf("Subgraph(%s, %d) wrong number of nodes: %d (wanted %d)", center, hops, n, len(want))
+			}
+
+			for _, name := range want {
+				p, ok := state.peers[name]
+				if !ok {
+					state.t.Fatal("no such wanted peer:", name)
+					return nil
+				}
+				if _, has := sub.getNode(p.key.ID()); !has {
+					return fmt.Errorf("Subgraph(%s, %d) is missing %s", center, hops, name)
+				}
+			}
+
+			// the edges between the included feeds are the same as in the full graph
+			for _, from := range want {
+				for _, to := range want {
+					a, b := state.peers[from].key.ID(), state.peers[to].key.ID()
+					if g.Follows(a, b) != sub.Follows(a, b) || g.Blocks(a, b) != sub.Blocks(a, b) {
+						return fmt.Errorf("Subgraph(%s, %d) has a different relation between %s and %s", center, hops, from, to)
+					}
+				}
+			}
+			return nil
+		}
+	}
+}
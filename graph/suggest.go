@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"math"
+	"sort"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"gonum.org/v1/gonum/graph"
+)
+
+// FollowSuggestion is a feed that is followed by Score of the feeds one follows
+type FollowSuggestion struct {
+	Feed  refs.FeedRef
+	Score int
+}
+
+// CommonFollows returns feeds that are followed by the feeds that from follows but not by from itself.
+// They are ranked by how many of these follows follow them. Feeds which from blocks are left out.
+// A limit of zero or less returns all of them.
+func (g *Graph) CommonFollows(from refs.FeedRef, limit int) []FollowSuggestion {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	nFrom, has := g.lookup[storedrefs.Feed(from)]
+	if !has {
+		return nil
+	}
+	fromID := nFrom.ID()
+
+	// all the feeds we already have an opinion about (follow or block)
+	var (
+		follows []graph.Node
+		known   = map[int64]struct{}{fromID: {}}
+	)
+	edgs := g.From(fromID)
+	for edgs.Next() {
+		nTo := edgs.Node()
+		w := g.Edge(fromID, nTo.ID()).(graph.WeightedEdge).Weight()
+		switch {
+		case w == 1:
+			follows = append(follows, nTo)
+			known[nTo.ID()] = struct{}{}
+		case math.IsInf(w, 1):
+			known[nTo.ID()] = struct{}{}
+		}
+	}
+
+	scores := make(map[int64]int)
+	for _, friend := range follows {
+		friendID := friend.ID()
+		edgs := g.From(friendID)
+		for edgs.Next() {
+			nTo := edgs.Node()
+			if _, has := known[nTo.ID()]; has {
+				continue
+			}
+			if g.Edge(friendID, nTo.ID()).(graph.WeightedEdge).Weight() != 1 {
+				continue
+			}
+			scores[nTo.ID()]++
+		}
+	}
+
+	suggestions := make([]FollowSuggestion, 0, len(scores))
+	for id, score := range scores {
+		ctNode := g.Node(id).(*contactNode)
+		suggestions = append(suggestions, FollowSuggestion{Feed: ctNode.feed, Score: score})
+	}
+
+	// highest score first, the feed reference breaks ties to keep the order stable
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Feed.String() < suggestions[j].Feed.String()
+	})
+
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import "fmt"
+
+var suggestScenarios = []PeopleTestCase{
+	{
+		name: "common follows",
+		ops: []PeopleOp{
+			PeopleOpNewPeer{"alice"},
+			PeopleOpNewPeer{"bob"},
+			PeopleOpNewPeer{"claire"},
+			PeopleOpNewPeer{"debora"},
+			PeopleOpNewPeer{"egon"},
+			PeopleOpNewPeer{"franz"},
+			PeopleOpNewPeer{"gerta"},
+
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"alice", "claire"},
+			PeopleOpFollow{"alice", "debora"},
+
+			// egon is followed by all three
+			PeopleOpFollow{"bob", "egon"},
+			PeopleOpFollow{"claire", "egon"},
+			PeopleOpFollow{"debora", "egon"},
+
+			// franz by two
+			PeopleOpFollow{"bob", "franz"},
+			PeopleOpFollow{"claire", "franz"},
+
+			// alice already follows debora and blocks gerta
+			PeopleOpFollow{"bob", "debora"},
+			PeopleOpFollow{"bob", "gerta"},
+			PeopleOpFollow{"claire", "gerta"},
+			PeopleOpBlock{"alice", "gerta"},
+
+			// blocks don't count
+			PeopleOpBlock{"debora", "franz"},
+
+			// alice is never suggested to alice
+			PeopleOpFollow{"bob", "alice"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertCommonFollows("alice", 0, "egon", "franz"),
+			PeopleAssertCommonFollows("alice", 1, "egon"),
+			PeopleAssertCommonFollows("egon", 0),
+		},
+	},
+}
+
+func PeopleAssertCommonFollows(from string, limit int, want ...string) PeopleAssertMaker {
+	return func(state *testState) PeopleAssert {
+		pFrom, ok := state.peers[from]
+		if !ok {
+			state.t.Fatal("no such peer:", from)
+			return nil
+		}
+
+		return func(bld Builder) error {
+			g, err := bld.Build()
+			if err != nil {
+				return err
+			}
+
+			got := g.CommonFollows(pFrom.key.ID(), limit)
+			if len(got) != len(want) {
+				return fmt.Errorf("CommonFollows() wrong length: %d (wanted %d)", len(got), len(want))
+			}
+
+			for i, name := range want {
+				p, ok := state.peers[name]
+				if !ok {
+					state.t.Fatal("no such wanted peer:", name)
+					return nil
+				}
+				if !got[i].Feed.Equal(p.key.ID()) {
+					return fmt.Errorf("CommonFollows() #%d: expected %s but got %s", i, name, state.refToName[got[i].Feed.String()])
+				}
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"math"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"gonum.org/v1/gonum/graph"
+)
+
+const (
+	// trustDamping is the share of trust a feed passes on to the feeds it follows, the rest goes back to the seed.
+	// The lower it is, the faster trust decays with distance from the seed.
+	trustDamping = 0.85
+
+	trustIterations = 100
+	trustEpsilon    = 1e-10
+)
+
+// TrustScores computes a personalized PageRank over the follow graph, seeded from the passed feed.
+// Every feed splits its trust evenly between the feeds it follows or blocks.
+// Trust that arrives over follows is reduced by the share of trust that arrives over blocks,
+// so a feed that is blocked by as many trusted feeds as it is followed by ends up with half the score.
+// Feeds which from blocks itself get no trust at all.
+//
+// The scores sum up to one and are keyed by the string of the feed reference, including the one of from.
+// Feeds without any trust are left out.
+func (g *Graph) TrustScores(from refs.FeedRef) map[string]float64 {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	nFrom, has := g.lookup[storedrefs.Feed(from)]
+	if !has {
+		return nil
+	}
+	seed := nFrom.ID()
+
+	type edges struct {
+		follows, blocks []int64
+	}
+	out := make(map[int64]edges)
+	nodes := g.Nodes()
+	for nodes.Next() {
+		id := nodes.Node().ID()
+		var e edges
+		edgs := g.From(id)
+		for edgs.Next() {
+			to := edgs.Node().ID()
+			w := g.Edge(id, to).(graph.WeightedEdge).Weight()
+			switch {
+			case math.IsInf(w, 1):
+				e.blocks = append(e.blocks, to)
+			case to != id && (w == 1 || w == 0.1): // follows and subfeeds
+				e.follows = append(e.follows, to)
+			}
+		}
+		out[id] = e
+	}
+
+	blockedBySeed := make(map[int64]struct{})
+	for _, to := range out[seed].blocks {
+		blockedBySeed[to] = struct{}{}
+	}
+
+	scores := map[int64]float64{seed: 1}
+	for i := 0; i < trustIterations; i++ {
+		var (
+			followed = make(map[int64]float64)
+			blocked  = make(map[int64]float64)
+		)
+		for id, score := range scores {
+			e := out[id]
+			n := len(e.follows) + len(e.blocks)
+			if n == 0 {
+				continue
+			}
+			share := trustDamping * score / float64(n)
+			for _, to := range e.follows {
+				followed[to] += share
+			}
+			for _, to := range e.blocks {
+				blocked[to] += share
+			}
+		}
+
+		next := map[int64]float64{seed: 1 - trustDamping}
+		for id, f := range followed {
+			if _, isBlocked := blockedBySeed[id]; isBlocked {
+				continue
+			}
+			next[id] += f * f / (f + blocked[id])
+		}
+
+		var delta float64
+		for id, score := range next {
+			delta += math.Abs(score - scores[id])
+		}
+		for id, score := range scores {
+			if _, has := next[id]; !has {
+				delta += score
+			}
+		}
+
+		scores = next
+		if delta < trustEpsilon {
+			break
+		}
+	}
+
+	var total float64
+	for _, score := range scores {
+		total += score
+	}
+
+	normalized := make(map[string]float64, len(scores))
+	for id, score := range scores {
+		if score <= 0 {
+			continue
+		}
+		ctNode := g.Node(id).(*contactNode)
+		normalized[ctNode.feed.String()] = score / total
+	}
+	return normalized
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package graph
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+var trustScenarios = []PeopleTestCase{
+	{
+		name: "trust scores",
+		ops: []PeopleOp{
+			PeopleOpNewPeer{"alice"},
+			PeopleOpNewPeer{"bob"},
+			PeopleOpNewPeer{"claire"},
+			PeopleOpNewPeer{"debora"},
+			PeopleOpNewPeer{"egon"},
+			PeopleOpNewPeer{"franz"},
+			PeopleOpNewPeer{"gerta"},
+			PeopleOpNewPeer{"spammer"},
+
+			PeopleOpFollow{"alice", "bob"},
+			PeopleOpFollow{"alice", "claire"},
+
+			// debora is followed by both friends, egon only by one
+			PeopleOpFollow{"bob", "debora"},
+			PeopleOpFollow{"claire", "debora"},
+			PeopleOpFollow{"claire", "egon"},
+
+			// franz is three hops away
+			PeopleOpFollow{"debora", "franz"},
+
+			// claire gets more trust back than bob
+			PeopleOpFollow{"debora", "claire"},
+
+			// gerta is followed by bob but blocked by claire
+			PeopleOpFollow{"bob", "gerta"},
+			PeopleOpBlock{"claire", "gerta"},
+
+			// alice blocks the spammer, who is still followed by a friend
+			PeopleOpFollow{"bob", "spammer"},
+			PeopleOpBlock{"alice", "spammer"},
+		},
+		asserts: []PeopleAssertMaker{
+			PeopleAssertTrustOrder("alice", "alice", "claire", "bob", "debora", "egon", "franz", "gerta"),
+			PeopleAssertTrustOrder("franz", "franz"),
+		},
+	},
+}
+
+// PeopleAssertTrustOrder checks that TrustScores of from ranks the feeds in the wanted order and leaves out all others
+func PeopleAssertTrustOrder(from string, want ...string) PeopleAssertMaker {
+	return func(state *testState) PeopleAssert {
+		pFrom, ok := state.peers[from]
+		if !ok {
+			state.t.Fatal("no such peer:", from)
+			return nil
+		}
+
+		return func(bld Builder) error {
+			g, err := bld.Build()
+			if err != nil {
+				return err
+			}
+
+			scores := g.TrustScores(pFrom.key.ID())
+
+			var total float64
+			got := make([]string, 0, len(scores))
+			for ref, score := range scores {
+				total += score
+				got = append(got, ref)
+			}
+			if math.Abs(total-1) > 1e-9 {
+				return fmt.Errorf("TrustScores() not normalized: sum is %f", total)
+			}
+
+			sort.Slice(got, func(i, j int) bool {
+				return scores[got[i]] > scores[got[j]]
+			})
+
+			if len(got) != len(want) {
+				return fmt.Errorf("TrustScores() wrong length: %d (wanted %d)", len(got), len(want))
+			}
+			for i, name := range want {
+				p, ok := state.peers[name]
+				if !ok {
+					state.t.Fatal("no such wanted peer:", name)
+					return nil
+				}
+				if got[i] != p.key.ID().String() {
+					return fmt.Errorf("TrustScores() #%d: expected %s but got %s (%f)", i, name, state.refToName[got[i]], scores[got[i]])
+				}
+			}
+			return nil
+		}
+	}
+}
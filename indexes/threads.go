@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package indexes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ssbc/margaret"
+	librarian "github.com/ssbc/margaret/indexes"
+	libbadger "github.com/ssbc/margaret/indexes/badger"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+)
+
+// ThreadSummary sums up the replies to a thread root
+type ThreadSummary struct {
+	Replies int `json:"replies"`
+
+	// Participants are the authors of the replies, in the order of their first reply
+	Participants []refs.FeedRef `json:"participants"`
+
+	// LatestReply is the claimed timestamp of the newest reply
+	LatestReply time.Time `json:"latestReply"`
+}
+
+// Threads keeps a ThreadSummary for each message that public messages point to with their root field
+type Threads struct {
+	idx librarian.SeqSetterIndex
+}
+
+// OpenThreads supplies the thread(rootRef) -> ThreadSummary idx
+func OpenThreads(db *badger.DB) (*Threads, librarian.SinkIndex) {
+	th := &Threads{
+		idx: libbadger.NewIndexWithKeyPrefix(db, ThreadSummary{}, []byte("threadSummaries")),
+	}
+	return th, librarian.NewSinkIndex(th.update, th.idx)
+}
+
+// Get returns the summary of the thread started by root. A root without replies returns an empty summary.
+func (th *Threads) Get(root refs.MessageRef) (ThreadSummary, error) {
+	obv, err := th.idx.Get(context.TODO(), storedrefs.Message(root))
+	if err != nil {
+		return ThreadSummary{}, fmt.Errorf("index/threads: failed to get summary of %s: %w", root.ShortSigil(), err)
+	}
+
+	v, err := obv.Value()
+	if err != nil {
+		return ThreadSummary{}, fmt.Errorf("index/threads: failed to get summary value of %s: %w", root.ShortSigil(), err)
+	}
+
+	switch tv := v.(type) {
+	case ThreadSummary:
+		return tv, nil
+	case librarian.UnsetValue:
+		return ThreadSummary{}, nil
+	default:
+		return ThreadSummary{}, fmt.Errorf("index/threads: unexpected summary type: %T", v)
+	}
+}
+
+func (th *Threads) update(ctx context.Context, seq int64, val interface{}, idx librarian.SetterIndex) error {
+	msg, ok := val.(refs.Message)
+	if !ok {
+		err, ok := val.(error)
+		if ok && margaret.IsErrNulled(err) {
+			return nil
+		}
+		return fmt.Errorf("index/threads: unexpected message type: %T", val)
+	}
+
+	// boxed messages are strings and fail here, which keeps private replies out of the summaries
+	var content struct {
+		Root *refs.MessageRef `json:"root"`
+	}
+	if err := json.Unmarshal(msg.ContentBytes(), &content); err != nil || content.Root == nil {
+		return nil
+	}
+	root := *content.Root
+	if root.Equal(msg.Key()) {
+		return nil
+	}
+
+	summary, err := th.Get(root)
+	if err != nil {
+		return err
+	}
+
+	summary.Replies++
+
+	author := msg.Author()
+	var known bool
+	for _, p := range summary.Participants {
+		if p.Equal(author) {
+			known = true
+			break
+		}
+	}
+	if !known {
+		summary.Participants = append(summary.Participants, author)
+	}
+
+	if claimed := msg.Claimed(); claimed.After(summary.LatestReply) {
+		summary.LatestReply = claimed
+	}
+
+	err = idx.Set(ctx, storedrefs.Message(root), summary)
+	if err != nil {
+		return fmt.Errorf("index/threads: failed to update summary of %s (seq: %d): %w", root.ShortSigil(), seq, err)
+	}
+	return nil
+}
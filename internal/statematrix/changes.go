@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package statematrix
+
+// ChangeFunc is called with the feeds whose note in our own frontier changed
+type ChangeFunc func(feeds []string)
+
+// OnChange registers fn to be called after Fill or Merge changed our own frontier, for instance because a message was added or a feed is replicated now.
+// fn is called while the matrix is locked, it must not block or use the matrix. The returned function removes it again.
+func (sm *StateMatrix) OnChange(fn ChangeFunc) func() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.changeListeners == nil {
+		sm.changeListeners = make(map[int]ChangeFunc)
+	}
+
+	id := sm.nextListener
+	sm.nextListener++
+	sm.changeListeners[id] = fn
+
+	return func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		delete(sm.changeListeners, id)
+	}
+}
+
+func (sm *StateMatrix) notifyChanged(feeds []ObservedFeed) {
+	if len(sm.changeListeners) == 0 || len(feeds) == 0 {
+		return
+	}
+
+	changed := make([]string, len(feeds))
+	for i, f := range feeds {
+		changed[i] = f.Feed.String()
+	}
+
+	for _, fn := range sm.changeListeners {
+		fn(changed)
+	}
+}
@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package statematrix
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb-refs/tfk"
+)
+
+// Encoding is the format of the state files
+type Encoding uint
+
+const (
+	// EncodingJSON stores the frontiers like they are sent over EBT, as JSON objects keyed by the feed references.
+	EncodingJSON Encoding = iota
+
+	// EncodingCompact stores the frontiers as a list of length prefixed tfk encoded feeds with varint sequences and a byte for the flags.
+	// A frontier with 10.000 feeds takes about 386kB instead of 617kB in JSON (-37%), see TestCompactEncodingSize.
+	// Unlike JSON it also keeps the sequence of feeds that aren't replicated.
+	EncodingCompact
+)
+
+// WithEncoding sets the format of the state files that are written.
+// Files are read in either format, existing ones are converted the next time they are saved.
+func WithEncoding(enc Encoding) Option {
+	return func(sm *StateMatrix) {
+		sm.encoding = enc
+	}
+}
+
+// compactMagic starts the compact encoding, JSON can't start with it.
+// The second byte is the version. Version 1 had no length before the feeds, they were all compactFeedLenV1 long.
+var compactMagic = [2]byte{0xeb, 0x02}
+
+const compactVersion1 = 0x01
+
+const (
+	compactFlagReplicate = 1 << iota
+	compactFlagReceive
+)
+
+// the length of the tfk of ed25519 and the other feed formats with 32 byte keys, the only ones of version 1
+const compactFeedLenV1 = 2 + 32
+
+// compactFeedMaxLen limits the length of a feed, so that a broken file doesn't allocate a lot
+const compactFeedMaxLen = 1024
+
+func encodeFrontier(w io.Writer, nf ssb.NetworkFrontier, enc Encoding) error {
+	switch enc {
+	case EncodingJSON:
+		return json.NewEncoder(w).Encode(nf)
+	case EncodingCompact:
+		return encodeCompact(w, nf)
+	default:
+		return fmt.Errorf("statematrix: unknown encoding %d", enc)
+	}
+}
+
+// decodeFrontier reads a frontier in either encoding
+func decodeFrontier(r io.Reader) (ssb.NetworkFrontier, error) {
+	br := bufio.NewReader(r)
+
+	first, err := br.Peek(1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	nf := make(ssb.NetworkFrontier)
+	if len(first) == 1 && first[0] == compactMagic[0] {
+		err = decodeCompact(br, nf)
+	} else {
+		err = json.NewDecoder(br).Decode(&nf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nf, nil
+}
+
+func encodeCompact(w io.Writer, nf ssb.NetworkFrontier) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(compactMagic[:])
+
+	var varintBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varintBuf[:], uint64(len(nf)))
+	bw.Write(varintBuf[:n])
+
+	for feedStr, note := range nf {
+		feed, err := refs.ParseFeedRef(feedStr)
+		if err != nil {
+			return err
+		}
+		feedTfk, err := tfk.Encode(feed)
+		if err != nil {
+			return fmt.Errorf("statematrix: failed to encode feed %s: %w", feedStr, err)
+		}
+		if len(feedTfk) > compactFeedMaxLen {
+			return fmt.Errorf("statematrix: tfk of %s is too long (%d bytes)", feedStr, len(feedTfk))
+		}
+		n = binary.PutUvarint(varintBuf[:], uint64(len(feedTfk)))
+		bw.Write(varintBuf[:n])
+		bw.Write(feedTfk)
+
+		n = binary.PutVarint(varintBuf[:], note.Seq)
+		bw.Write(varintBuf[:n])
+
+		var flags byte
+		if note.Replicate {
+			flags |= compactFlagReplicate
+		}
+		if note.Receive {
+			flags |= compactFlagReceive
+		}
+		bw.WriteByte(flags)
+	}
+
+	return bw.Flush()
+}
+
+func decodeCompact(br *bufio.Reader, nf ssb.NetworkFrontier) error {
+	var magic [2]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return fmt.Errorf("statematrix: failed to read header: %w", err)
+	}
+	if magic[0] != compactMagic[0] || (magic[1] != compactMagic[1] && magic[1] != compactVersion1) {
+		return fmt.Errorf("statematrix: unsupported compact encoding version %d", magic[1])
+	}
+	prefixed := magic[1] != compactVersion1
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("statematrix: failed to read number of feeds: %w", err)
+	}
+
+	feedTfk := make([]byte, compactFeedMaxLen)
+	for i := uint64(0); i < count; i++ {
+		feedLen := uint64(compactFeedLenV1)
+		if prefixed {
+			feedLen, err = binary.ReadUvarint(br)
+			if err != nil {
+				return fmt.Errorf("statematrix: failed to read length of feed %d: %w", i, err)
+			}
+			if feedLen > compactFeedMaxLen {
+				return fmt.Errorf("statematrix: feed %d is too long (%d bytes)", i, feedLen)
+			}
+		}
+		if _, err := io.ReadFull(br, feedTfk[:feedLen]); err != nil {
+			return fmt.Errorf("statematrix: failed to read feed %d: %w", i, err)
+		}
+		var tf tfk.Feed
+		if err := tf.UnmarshalBinary(feedTfk[:feedLen]); err != nil {
+			return fmt.Errorf("statematrix: invalid feed %d: %w", i, err)
+		}
+		feed, err := tf.Feed()
+		if err != nil {
+			return fmt.Errorf("statematrix: invalid feed %d: %w", i, err)
+		}
+
+		var note ssb.Note
+		note.Seq, err = binary.ReadVarint(br)
+		if err != nil {
+			return fmt.Errorf("statematrix: failed to read sequence of %s: %w", feed.ShortSigil(), err)
+		}
+		flags, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("statematrix: failed to read flags of %s: %w", feed.ShortSigil(), err)
+		}
+		note.Replicate = flags&compactFlagReplicate != 0
+		note.Receive = flags&compactFlagReceive != 0
+
+		nf[feed.String()] = note
+	}
+
+	return nil
+}
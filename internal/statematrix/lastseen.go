@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package statematrix
+
+import (
+	"os"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// the timestamps are kept next to the frontier of each peer, so that the format of the frontier file stays the same
+const lastSeenSuffix = ".seen"
+
+// LastSeen returns when the frontier of peer was last updated.
+// It returns the zero time if there never was an update.
+func (sm *StateMatrix) LastSeen(peer refs.FeedRef) (time.Time, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	_, err := sm.loadFrontier(peer)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return sm.lastSeen[peer.String()], nil
+}
+
+func (sm *StateMatrix) touch(peer string) {
+	sm.lastSeen[peer] = time.Now()
+}
+
+func loadLastSeen(peerFileName string) (time.Time, error) {
+	var seen time.Time
+
+	b, err := os.ReadFile(peerFileName + lastSeenSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return seen, nil
+		}
+		return seen, err
+	}
+
+	err = seen.UnmarshalText(b)
+	return seen, err
+}
+
+func saveLastSeen(peerFileName string, seen time.Time) error {
+	if seen.IsZero() {
+		return nil
+	}
+
+	b, err := seen.MarshalText()
+	if err != nil {
+		return err
+	}
+
+	newFileName := peerFileName + lastSeenSuffix + ".new"
+	err = os.WriteFile(newFileName, b, onlyOwnerPerms)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(newFileName, peerFileName+lastSeenSuffix)
+}
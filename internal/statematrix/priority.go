@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package statematrix
+
+import (
+	"sort"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// PriorityDecay is how long a prioritized feed stays ahead of the others.
+// The priority decreases linearly over that time, so recently bumped feeds come first.
+const PriorityDecay = 15 * time.Minute
+
+// Prioritize bumps feed to the front of the want lists. The boost decays over PriorityDecay.
+func (sm *StateMatrix) Prioritize(feed refs.FeedRef) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.prioritized == nil {
+		sm.prioritized = make(map[string]time.Time)
+	}
+	sm.prioritized[feed.String()] = time.Now()
+}
+
+// Priority returns the current priority of feed, between 0 (none) and 1 (just bumped)
+func (sm *StateMatrix) Priority(feed refs.FeedRef) float64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.priority(feed.String(), time.Now())
+}
+
+func (sm *StateMatrix) priority(feed string, now time.Time) float64 {
+	bumped, has := sm.prioritized[feed]
+	if !has {
+		return 0
+	}
+
+	age := now.Sub(bumped)
+	if age >= PriorityDecay {
+		delete(sm.prioritized, feed)
+		return 0
+	}
+
+	return 1 - float64(age)/float64(PriorityDecay)
+}
+
+// SortByPriority sorts feeds so that the ones with the highest priority come first.
+// The order of feeds without priority is kept.
+func (sm *StateMatrix) SortByPriority(feeds []refs.FeedRef) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if len(sm.prioritized) == 0 {
+		return
+	}
+
+	now := time.Now()
+	prios := make(map[string]float64, len(sm.prioritized))
+	for _, f := range feeds {
+		fs := f.String()
+		if p := sm.priority(fs, now); p > 0 {
+			prios[fs] = p
+		}
+	}
+
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return prios[feeds[i].String()] > prios[feeds[j].String()]
+	})
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package statematrix
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// the file names are the hex encoded tfk of the peer, which starts with the type and format bytes.
+// Those are the same for most peers, so the shards use the bytes of the key after them.
+const tfkHeaderHexLen = 4
+
+// shardOf returns the subdirectory for the state files of the peer with the hex encoded tfk.
+// Without sharding or for names that are too short it returns the empty string, which means the base directory.
+func (sm *StateMatrix) shardOf(hexPeerTfk string) string {
+	end := tfkHeaderHexLen + 2*sm.shardBytes
+	if sm.shardBytes <= 0 || len(hexPeerTfk) < end {
+		return ""
+	}
+
+	shard := hexPeerTfk[tfkHeaderHexLen:end]
+	if _, err := hex.DecodeString(shard); err != nil {
+		return ""
+	}
+	return shard
+}
+
+// migrateToShards moves the state files that are still in the base directory into their shard.
+// Once all of them are moved it only has to list the shard directories.
+func (sm *StateMatrix) migrateToShards() error {
+	entries, err := os.ReadDir(sm.basePath)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+
+		// this also moves the last seen and unfinished .new files next to their frontier
+		shard := sm.shardOf(e.Name())
+		if shard == "" {
+			continue
+		}
+
+		shardPath := filepath.Join(sm.basePath, shard)
+		if err := os.MkdirAll(shardPath, onlyOwnerPerms); err != nil {
+			return err
+		}
+
+		oldPath := filepath.Join(sm.basePath, e.Name())
+		newPath := filepath.Join(shardPath, e.Name())
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return fmt.Errorf("failed to move %s: %w", e.Name(), err)
+		}
+	}
+
+	return nil
+}
@@ -16,11 +16,11 @@ Q:
 package statematrix
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
@@ -36,12 +36,40 @@ type StateMatrix struct {
 
 	mu   sync.Mutex
 	open currentFrontiers
+
+	// when feeds were prioritized, see Prioritize
+	prioritized map[string]time.Time
+
+	// when the frontier of a peer was last updated, see LastSeen
+	lastSeen map[string]time.Time
+
+	// how many bytes of the peer key are used as subdirectory, see WithSharding
+	shardBytes int
+
+	// the format of the state files that are written, see WithEncoding
+	encoding Encoding
+
+	// called when our own frontier changes, see OnChange
+	changeListeners map[int]ChangeFunc
+	nextListener    int
+}
+
+// Option changes the StateMatrix created by New
+type Option func(*StateMatrix)
+
+// WithSharding stores the state files in subdirectories named after the first n bytes of the peer key (ab/0000ab...),
+// instead of putting them all into the base directory.
+// Files of the flat layout are moved into the subdirectories when the matrix is opened.
+func WithSharding(n int) Option {
+	return func(sm *StateMatrix) {
+		sm.shardBytes = n
+	}
 }
 
 // map[peer reference]frontier
 type currentFrontiers map[string]ssb.NetworkFrontier
 
-func New(base string, self refs.FeedRef) (*StateMatrix, error) {
+func New(base string, self refs.FeedRef, opts ...Option) (*StateMatrix, error) {
 
 	os.MkdirAll(base, onlyOwnerPerms)
 
@@ -51,6 +79,18 @@ func New(base string, self refs.FeedRef) (*StateMatrix, error) {
 		self: self.String(),
 
 		open: make(currentFrontiers),
+
+		lastSeen: make(map[string]time.Time),
+	}
+
+	for _, opt := range opts {
+		opt(&sm)
+	}
+
+	if sm.shardBytes > 0 {
+		if err := sm.migrateToShards(); err != nil {
+			return nil, fmt.Errorf("statematrix: failed to move state files into shards: %w", err)
+		}
 	}
 
 	_, err := sm.loadFrontier(self)
@@ -75,7 +115,7 @@ func (sm *StateMatrix) StateFileName(peer refs.FeedRef) (string, error) {
 	}
 
 	hexPeerTfk := fmt.Sprintf("%x", peerTfk)
-	peerFileName := filepath.Join(sm.basePath, hexPeerTfk)
+	peerFileName := filepath.Join(sm.basePath, sm.shardOf(hexPeerTfk), hexPeerTfk)
 	return peerFileName, nil
 }
 
@@ -103,11 +143,19 @@ func (sm *StateMatrix) loadFrontier(peer refs.FeedRef) (ssb.NetworkFrontier, err
 	}
 	defer peerFile.Close()
 
-	curr = make(ssb.NetworkFrontier)
-	err = json.NewDecoder(peerFile).Decode(&curr)
+	curr, err = decodeFrontier(peerFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to load frontier of %s: %w", peer.ShortSigil(), err)
+	}
+
+	seen, err := loadLastSeen(peerFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last seen time of %s: %w", peer.ShortSigil(), err)
 	}
+	if !seen.IsZero() {
+		sm.lastSeen[peer.String()] = seen
+	}
+
 	sm.open[peer.String()] = curr
 	return curr, nil
 }
@@ -130,6 +178,7 @@ func (sm *StateMatrix) saveAndClose(peer string) error {
 	}
 
 	delete(sm.open, peer)
+	delete(sm.lastSeen, peer)
 	return nil
 }
 
@@ -140,6 +189,11 @@ func (sm *StateMatrix) save(peer refs.FeedRef) error {
 	}
 	newPeerFileName := peerFileName + ".new"
 
+	// shard directories are created on demand
+	if err := os.MkdirAll(filepath.Dir(peerFileName), onlyOwnerPerms); err != nil {
+		return err
+	}
+
 	// truncate the file for overwriting, create it if it doesnt exist
 	peerFile, err := os.OpenFile(newPeerFileName, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, onlyOwnerPerms)
 	if err != nil {
@@ -151,8 +205,9 @@ func (sm *StateMatrix) save(peer refs.FeedRef) error {
 		return nil
 	}
 
-	err = json.NewEncoder(peerFile).Encode(nf)
+	err = encodeFrontier(peerFile, nf, sm.encoding)
 	if err != nil {
+		peerFile.Close()
 		return err
 	}
 
@@ -166,7 +221,7 @@ func (sm *StateMatrix) save(peer refs.FeedRef) error {
 		return fmt.Errorf("failed to replace %s with %s: %w", peerFileName, newPeerFileName, err)
 	}
 
-	return nil
+	return saveLastSeen(peerFileName, sm.lastSeen[peer.String()])
 }
 
 type HasLongerResult struct {
@@ -179,21 +234,39 @@ func (hlr HasLongerResult) String() string {
 	return fmt.Sprintf("%s: %s:%d", hlr.Peer.ShortSigil(), hlr.Feed.ShortSigil(), hlr.Len)
 }
 
-// HasLonger returns all the feeds which have more messages then we have and who has them.
-func (sm *StateMatrix) HasLonger() ([]HasLongerResult, error) {
-	var err error
-
+// Snapshot returns a copy of all the currently open frontiers, keyed by peer.
+// The lock is only held while copying, callers can iterate and hold on to the result freely.
+func (sm *StateMatrix) Snapshot() (map[string]ssb.NetworkFrontier, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	selfNf, has := sm.open[sm.self]
+	snap := make(map[string]ssb.NetworkFrontier, len(sm.open))
+	for peer, nf := range sm.open {
+		cpy := make(ssb.NetworkFrontier, len(nf))
+		for feed, note := range nf {
+			cpy[feed] = note
+		}
+		snap[peer] = cpy
+	}
+	return snap, nil
+}
+
+// HasLonger returns all the feeds which have more messages then we have and who has them.
+// It works on a snapshot and doesn't block updates while comparing.
+func (sm *StateMatrix) HasLonger() ([]HasLongerResult, error) {
+	snap, err := sm.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	selfNf, has := snap[sm.self]
 	if !has {
 		return nil, nil
 	}
 
 	var res []HasLongerResult
 
-	for peer, theirNf := range sm.open {
+	for peer, theirNf := range snap {
 
 		for feed, note := range selfNf {
 
@@ -225,8 +298,17 @@ func (sm *StateMatrix) HasLonger() ([]HasLongerResult, error) {
 	return res, nil
 }
 
-// WantsList returns all the feeds a peer wants to recevie messages for
+// WantsList returns all the feeds a peer wants to recevie messages for, prioritized feeds first
 func (sm *StateMatrix) WantsList(peer refs.FeedRef) ([]refs.FeedRef, error) {
+	res, err := sm.wantsList(peer)
+	if err != nil {
+		return nil, err
+	}
+	sm.SortByPriority(res)
+	return res, nil
+}
+
+func (sm *StateMatrix) wantsList(peer refs.FeedRef) ([]refs.FeedRef, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -270,6 +352,12 @@ func (sm *StateMatrix) WantsFeed(peer, feed refs.FeedRef) (bool, error) {
 
 // Changed returns which feeds have newer messages since last update
 func (sm *StateMatrix) Changed(self, peer refs.FeedRef) (ssb.NetworkFrontier, error) {
+	return sm.Relevant(self, peer, nil)
+}
+
+// Relevant is like Changed but only includes the feeds that peer didn't tell us about yet if interested returns true for them.
+// A nil interested includes all of them.
+func (sm *StateMatrix) Relevant(self, peer refs.FeedRef, interested func(feed string, ours ssb.Note) bool) (ssb.NetworkFrontier, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -290,7 +378,9 @@ func (sm *StateMatrix) Changed(self, peer refs.FeedRef) (ssb.NetworkFrontier, er
 		theirNote, has := peerNf[wantedFeed]
 		if !has && myNote.Receive {
 			// they don't have it, but tell them we want it
-			relevant[wantedFeed] = myNote
+			if interested == nil || interested(wantedFeed, myNote) {
+				relevant[wantedFeed] = myNote
+			}
 			continue
 		}
 
@@ -332,9 +422,52 @@ func (sm *StateMatrix) Update(who refs.FeedRef, update ssb.NetworkFrontier) (ssb
 	}
 
 	sm.open[who.String()] = current
+	sm.touch(who.String())
 	return current, nil
 }
 
+// Merge adds the notes of an imported frontier, like the one of another node, to the current state of who.
+// Unlike Update it doesn't overwrite newer state: a feed only moves forward if the imported sequence is higher,
+// and the replicate and receive flags of feeds that are already known are kept.
+// Notes which don't replicate the feed are skipped.
+func (sm *StateMatrix) Merge(who refs.FeedRef, nf ssb.NetworkFrontier) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	current, err := sm.loadFrontier(who)
+	if err != nil {
+		return err
+	}
+
+	var changed []ObservedFeed
+	for feed, note := range nf {
+		if !note.Replicate {
+			continue
+		}
+
+		has, ok := current[feed]
+		if ok {
+			if has.Seq >= note.Seq {
+				continue
+			}
+			has.Seq = note.Seq
+			note = has
+		}
+		current[feed] = note
+
+		if ref, err := refs.ParseFeedRef(feed); err == nil {
+			changed = append(changed, ObservedFeed{Feed: ref, Note: note})
+		}
+	}
+
+	sm.open[who.String()] = current
+
+	if who.String() == sm.self && len(changed) > 0 {
+		sm.notifyChanged(changed)
+	}
+	return nil
+}
+
 // Fill might be deprecated. It just updates the current frontier state
 func (sm *StateMatrix) Fill(who refs.FeedRef, feeds []ObservedFeed) error {
 	sm.mu.Lock()
@@ -355,6 +488,11 @@ func (sm *StateMatrix) Fill(who refs.FeedRef, feeds []ObservedFeed) error {
 	}
 
 	sm.open[who.String()] = nf
+	sm.touch(who.String())
+
+	if who.String() == sm.self {
+		sm.notifyChanged(feeds)
+	}
 	return nil
 }
 
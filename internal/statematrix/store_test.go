@@ -6,7 +6,9 @@ package statematrix
 
 import (
 	"bytes"
+	"encoding/binary"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb-refs/tfk"
 )
 
 func TestNew(t *testing.T) {
@@ -123,6 +126,118 @@ func testFeed(i int) refs.FeedRef {
 	return ref
 }
 
+// benchFeed returns a distinct feed for every i, unlike testFeed which repeats for 1 and 11
+func benchFeed(i int) refs.FeedRef {
+	k := make([]byte, 32)
+	binary.BigEndian.PutUint64(k, uint64(i))
+
+	ref, err := refs.NewFeedRefFromBytes(k, refs.RefAlgoFeedSSB1)
+	if err != nil {
+		panic(err)
+	}
+
+	return ref
+}
+
+func TestSnapshot(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/snapshot", testFeed(0))
+	r.NoError(err)
+
+	feeds := []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 5}},
+	}
+	r.NoError(m.Fill(testFeed(2), feeds))
+
+	snap, err := m.Snapshot()
+	r.NoError(err)
+	r.Len(snap, 2) // self and feed(2)
+	r.Equal(int64(5), snap[testFeed(2).String()][testFeed(1).String()].Seq)
+
+	// changing the snapshot doesn't change the matrix
+	snap[testFeed(2).String()][testFeed(1).String()] = ssb.Note{Seq: 100}
+
+	feeds[0].Note.Seq = 6
+	r.NoError(m.Fill(testFeed(2), feeds))
+
+	nf, err := m.Inspect(testFeed(2))
+	r.NoError(err)
+	r.Equal(int64(6), nf[testFeed(1).String()].Seq)
+
+	// and the other way around
+	r.Equal(int64(100), snap[testFeed(2).String()][testFeed(1).String()].Seq)
+}
+
+// BenchmarkUpdateWhileHasLonger measures how long an update has to wait while HasLonger is computed concurrently.
+// Before Snapshot() it had to wait for the whole comparison, now only for the copy.
+func BenchmarkUpdateWhileHasLonger(b *testing.B) {
+	r := require.New(b)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/bench-haslonger", benchFeed(0))
+	r.NoError(err)
+
+	const (
+		peers = 50
+		feeds = 500
+	)
+
+	var selfFeeds = make([]ObservedFeed, feeds)
+	for i := range selfFeeds {
+		selfFeeds[i] = ObservedFeed{Feed: benchFeed(1000 + i), Note: ssb.Note{Replicate: true, Receive: true, Seq: 1}}
+	}
+	r.NoError(m.Fill(benchFeed(0), selfFeeds))
+
+	for p := 1; p <= peers; p++ {
+		var peerFeeds = make([]ObservedFeed, feeds)
+		for i := range peerFeeds {
+			peerFeeds[i] = ObservedFeed{Feed: benchFeed(1000 + i), Note: ssb.Note{Replicate: true, Receive: true, Seq: 2}}
+		}
+		r.NoError(m.Fill(benchFeed(p), peerFeeds))
+	}
+
+	hl, err := m.HasLonger()
+	r.NoError(err)
+	r.Len(hl, peers*feeds)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			m.HasLonger()
+		}
+	}()
+
+	update := ssb.NetworkFrontier{
+		benchFeed(1000).String(): ssb.Note{Replicate: true, Receive: true, Seq: 3},
+	}
+
+	var waited time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		_, err := m.Update(benchFeed(1), update)
+		waited += time.Since(start)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	close(done)
+	<-stopped
+
+	b.ReportMetric(float64(waited.Microseconds())/float64(b.N), "µs/update")
+}
+
 func TestChanged(t *testing.T) {
 	r := require.New(t)
 	os.RemoveAll("testrun")
@@ -152,3 +267,377 @@ func TestChanged(t *testing.T) {
 	r.True(note.Replicate)
 	r.True(note.Receive)
 }
+
+func TestMerge(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/new", testFeed(0))
+	r.NoError(err)
+
+	var notified []string
+	m.OnChange(func(feeds []string) { notified = append(notified, feeds...) })
+
+	feeds := []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 10}},
+		{Feed: testFeed(2), Note: ssb.Note{Replicate: true, Receive: false, Seq: 5}},
+	}
+	r.NoError(m.Fill(testFeed(0), feeds))
+	notified = nil
+
+	imported := ssb.NetworkFrontier{
+		testFeed(1).String(): {Replicate: true, Receive: true, Seq: 3},
+		testFeed(2).String(): {Replicate: true, Receive: true, Seq: 20},
+		testFeed(3).String(): {Replicate: true, Receive: true, Seq: 7},
+		testFeed(4).String(): {Replicate: false, Seq: -1},
+	}
+	r.NoError(m.Merge(testFeed(0), imported))
+
+	nf, err := m.Inspect(testFeed(0))
+	r.NoError(err)
+	r.Len(nf, 3)
+	r.Equal(ssb.Note{Replicate: true, Receive: true, Seq: 10}, nf[testFeed(1).String()], "newer state is kept")
+	r.Equal(ssb.Note{Replicate: true, Receive: false, Seq: 20}, nf[testFeed(2).String()], "the seq moves forward but the flags are kept")
+	r.Equal(ssb.Note{Replicate: true, Receive: true, Seq: 7}, nf[testFeed(3).String()])
+	r.ElementsMatch([]string{testFeed(2).String(), testFeed(3).String()}, notified)
+
+	// merging into the state of a peer
+	r.NoError(m.Merge(testFeed(9), imported))
+	nf, err = m.Inspect(testFeed(9))
+	r.NoError(err)
+	r.Len(nf, 3)
+	r.Len(notified, 2, "only changes to our own frontier are announced")
+
+	r.NoError(m.Close())
+}
+
+func TestRelevant(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/relevant", testFeed(0))
+	r.NoError(err)
+
+	// we want 2 and 3
+	r.NoError(m.Fill(testFeed(0), []ObservedFeed{
+		{Feed: testFeed(2), Note: ssb.Note{Replicate: true, Receive: true, Seq: 1}},
+		{Feed: testFeed(3), Note: ssb.Note{Replicate: true, Receive: true, Seq: 1}},
+	}))
+
+	onlyTwo := func(feed string, _ ssb.Note) bool { return feed == testFeed(2).String() }
+
+	relevant, err := m.Relevant(testFeed(0), testFeed(1), onlyTwo)
+	r.NoError(err)
+	r.Len(relevant, 1)
+	r.Contains(relevant, testFeed(2).String())
+
+	// once 1 told us it wants 3, it is relevant regardless
+	_, err = m.Update(testFeed(1), ssb.NetworkFrontier{
+		testFeed(3).String(): ssb.Note{Replicate: true, Receive: true, Seq: 0},
+	})
+	r.NoError(err)
+
+	relevant, err = m.Relevant(testFeed(0), testFeed(1), onlyTwo)
+	r.NoError(err)
+	r.Len(relevant, 2)
+
+	all, err := m.Changed(testFeed(0), testFeed(5))
+	r.NoError(err)
+	r.Len(all, 2)
+}
+
+func TestPriority(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/priority", testFeed(0))
+	r.NoError(err)
+
+	var feeds []ObservedFeed
+	for i := 1; i <= 5; i++ {
+		feeds = append(feeds, ObservedFeed{Feed: testFeed(i), Note: ssb.Note{Replicate: true, Receive: true, Seq: int64(i)}})
+	}
+	r.NoError(m.Fill(testFeed(23), feeds))
+
+	r.Equal(float64(0), m.Priority(testFeed(3)))
+
+	m.Prioritize(testFeed(3))
+	m.Prioritize(testFeed(5))
+	r.InDelta(1, m.Priority(testFeed(5)), 0.01)
+
+	// pretend feed(3) was bumped earlier, so that it is behind feed(5)
+	m.mu.Lock()
+	m.prioritized[testFeed(3).String()] = time.Now().Add(-PriorityDecay / 2)
+	m.prioritized[testFeed(4).String()] = time.Now().Add(-PriorityDecay)
+	m.mu.Unlock()
+	r.InDelta(0.5, m.Priority(testFeed(3)), 0.01)
+	r.Equal(float64(0), m.Priority(testFeed(4)), "should have decayed")
+
+	wants, err := m.WantsList(testFeed(23))
+	r.NoError(err)
+	r.Len(wants, 5)
+	r.True(wants[0].Equal(testFeed(5)), "first: %s", wants[0].ShortSigil())
+	r.True(wants[1].Equal(testFeed(3)), "second: %s", wants[1].ShortSigil())
+
+	// the order of the rest is kept
+	rest := []refs.FeedRef{testFeed(1), testFeed(3), testFeed(2), testFeed(4)}
+	m.SortByPriority(rest)
+	r.True(rest[0].Equal(testFeed(3)))
+	r.True(rest[1].Equal(testFeed(1)))
+	r.True(rest[2].Equal(testFeed(2)))
+	r.True(rest[3].Equal(testFeed(4)))
+}
+
+func TestLastSeen(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/lastseen", testFeed(0))
+	r.NoError(err)
+
+	seen, err := m.LastSeen(testFeed(2))
+	r.NoError(err)
+	r.True(seen.IsZero(), "never updated")
+
+	before := time.Now()
+	feeds := []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 5}},
+	}
+	r.NoError(m.Fill(testFeed(2), feeds))
+
+	seen, err = m.LastSeen(testFeed(2))
+	r.NoError(err)
+	r.False(seen.Before(before))
+
+	// survives a restart
+	r.NoError(m.Close())
+
+	m, err = New("testrun/lastseen", testFeed(0))
+	r.NoError(err)
+
+	reloaded, err := m.LastSeen(testFeed(2))
+	r.NoError(err)
+	r.True(seen.Equal(reloaded), "%s != %s", seen, reloaded)
+
+	// updates move it forward
+	_, err = m.Update(testFeed(2), ssb.NetworkFrontier{
+		testFeed(1).String(): ssb.Note{Replicate: true, Receive: true, Seq: 6},
+	})
+	r.NoError(err)
+
+	updated, err := m.LastSeen(testFeed(2))
+	r.NoError(err)
+	r.True(updated.After(reloaded))
+	r.NoError(m.Close())
+}
+
+func TestOnChange(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+	m, err := New("testrun/onchange", testFeed(0))
+	r.NoError(err)
+
+	var changed []string
+	remove := m.OnChange(func(feeds []string) {
+		changed = append(changed, feeds...)
+	})
+
+	r.NoError(m.Fill(testFeed(0), []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 5}},
+		{Feed: testFeed(2), Note: ssb.Note{Replicate: true, Receive: true, Seq: 1}},
+	}))
+	r.Equal([]string{testFeed(1).String(), testFeed(2).String()}, changed)
+
+	// the frontiers of peers are not ours
+	changed = nil
+	r.NoError(m.Fill(testFeed(3), []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 6}},
+	}))
+	_, err = m.Update(testFeed(3), ssb.NetworkFrontier{
+		testFeed(1).String(): ssb.Note{Replicate: true, Receive: true, Seq: 7},
+	})
+	r.NoError(err)
+	r.Len(changed, 0)
+
+	remove()
+	r.NoError(m.Fill(testFeed(0), []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 6}},
+	}))
+	r.Len(changed, 0)
+	r.NoError(m.Close())
+}
+
+func TestSharding(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+
+	// start with the flat layout
+	m, err := New("testrun/shards", testFeed(0))
+	r.NoError(err)
+
+	feeds := []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 5}},
+	}
+	r.NoError(m.Fill(testFeed(2), feeds))
+
+	flatName, err := m.StateFileName(testFeed(2))
+	r.NoError(err)
+	r.Equal("testrun/shards", filepath.Dir(flatName))
+	r.NoError(m.Close())
+
+	r.FileExists(flatName)
+	r.FileExists(flatName + lastSeenSuffix)
+
+	// opening it with sharding moves the files
+	m, err = New("testrun/shards", testFeed(0), WithSharding(1))
+	r.NoError(err)
+
+	shardedName, err := m.StateFileName(testFeed(2))
+	r.NoError(err)
+	r.Equal(filepath.Join("testrun/shards", "32", filepath.Base(flatName)), shardedName)
+
+	r.NoFileExists(flatName)
+	r.FileExists(shardedName)
+	r.FileExists(shardedName + lastSeenSuffix)
+
+	nf, err := m.Inspect(testFeed(2))
+	r.NoError(err)
+	r.EqualValues(5, nf[testFeed(1).String()].Seq)
+
+	seen, err := m.LastSeen(testFeed(2))
+	r.NoError(err)
+	r.False(seen.IsZero())
+
+	// new peers get their shard when they are saved
+	r.NoError(m.Fill(testFeed(3), feeds))
+	r.NoDirExists("testrun/shards/33")
+	r.NoError(m.SaveAndClose(testFeed(3)))
+	r.DirExists("testrun/shards/33")
+
+	r.NoError(m.Close())
+
+	entries, err := os.ReadDir("testrun/shards")
+	r.NoError(err)
+	for _, e := range entries {
+		r.True(e.IsDir(), "unexpected file %s", e.Name())
+	}
+}
+
+func TestCompactEncoding(t *testing.T) {
+	r := require.New(t)
+	os.RemoveAll("testrun")
+	os.Mkdir("testrun", 0700)
+
+	feeds := []ObservedFeed{
+		{Feed: testFeed(1), Note: ssb.Note{Replicate: true, Receive: true, Seq: 5}},
+		{Feed: testFeed(2), Note: ssb.Note{Replicate: true, Receive: false, Seq: 1 << 40}},
+		{Feed: testFeed(3), Note: ssb.Note{Replicate: true, Receive: true, Seq: -1}},
+	}
+
+	// start with JSON files
+	m, err := New("testrun/compact", testFeed(0))
+	r.NoError(err)
+	r.NoError(m.Fill(testFeed(4), feeds))
+	r.NoError(m.Close())
+
+	fname, err := m.StateFileName(testFeed(4))
+	r.NoError(err)
+	jsonState, err := os.ReadFile(fname)
+	r.NoError(err)
+	r.Equal(byte('{'), jsonState[0])
+
+	// they are still read and written in the compact encoding when they are saved
+	m, err = New("testrun/compact", testFeed(0), WithEncoding(EncodingCompact))
+	r.NoError(err)
+	nf, err := m.Inspect(testFeed(4))
+	r.NoError(err)
+	r.Len(nf, 3)
+	r.EqualValues(5, nf[testFeed(1).String()].Seq)
+	r.EqualValues(1<<40, nf[testFeed(2).String()].Seq)
+	r.False(nf[testFeed(2).String()].Receive)
+	r.NoError(m.Fill(testFeed(4), feeds))
+	r.NoError(m.Close())
+
+	compactState, err := os.ReadFile(fname)
+	r.NoError(err)
+	r.Equal(compactMagic[:], compactState[:2])
+	r.Less(len(compactState), len(jsonState))
+
+	// unlike JSON the compact encoding keeps the notes as they are
+	m, err = New("testrun/compact", testFeed(0))
+	r.NoError(err)
+	nf, err = m.Inspect(testFeed(4))
+	r.NoError(err)
+	for _, f := range feeds {
+		r.Equal(f.Note, nf[f.Feed.String()], "wrong note for %s", f.Feed.ShortSigil())
+	}
+
+	// and the files are JSON again when they are saved without the option
+	r.NoError(m.Fill(testFeed(4), feeds[:1]))
+	r.NoError(m.Close())
+	jsonState, err = os.ReadFile(fname)
+	r.NoError(err)
+	r.Equal(byte('{'), jsonState[0])
+
+	// broken files are an error, not an empty frontier
+	r.NoError(os.WriteFile(fname, compactState[:len(compactState)-1], 0600))
+	m, err = New("testrun/compact", testFeed(0))
+	r.NoError(err)
+	_, err = m.Inspect(testFeed(4))
+	r.Error(err)
+	r.NoError(m.Close())
+}
+
+func TestCompactEncodingSize(t *testing.T) {
+	r := require.New(t)
+
+	const n = 10000
+	nf := make(ssb.NetworkFrontier, n)
+	for i := 0; i < n; i++ {
+		var k [32]byte
+		binary.BigEndian.PutUint64(k[:], uint64(i))
+		feed, err := refs.NewFeedRefFromBytes(k[:], refs.RefAlgoFeedSSB1)
+		r.NoError(err)
+		// most feeds are short, some are long
+		nf[feed.String()] = ssb.Note{Replicate: true, Receive: i%10 != 0, Seq: int64((i * 7919) % 20000)}
+	}
+
+	var jsonBuf, compactBuf bytes.Buffer
+	r.NoError(encodeFrontier(&jsonBuf, nf, EncodingJSON))
+	r.NoError(encodeFrontier(&compactBuf, nf, EncodingCompact))
+	t.Logf("%d feeds: json %d bytes, compact %d bytes (%.0f%%)", n, jsonBuf.Len(), compactBuf.Len(),
+		100*float64(compactBuf.Len()-jsonBuf.Len())/float64(jsonBuf.Len()))
+	r.Less(compactBuf.Len(), jsonBuf.Len()*2/3)
+
+	decoded, err := decodeFrontier(&compactBuf)
+	r.NoError(err)
+	r.Equal(nf, decoded)
+}
+
+func TestCompactEncodingVersion1(t *testing.T) {
+	r := require.New(t)
+
+	// version 1 had no length before the feeds
+	feedTfk, err := tfk.Encode(testFeed(1))
+	r.NoError(err)
+	v1 := []byte{compactMagic[0], compactVersion1, 1}
+	v1 = append(v1, feedTfk...)
+	var seq [binary.MaxVarintLen64]byte
+	v1 = append(v1, seq[:binary.PutVarint(seq[:], 23)]...)
+	v1 = append(v1, compactFlagReplicate)
+
+	nf, err := decodeFrontier(bytes.NewReader(v1))
+	r.NoError(err)
+	r.Equal(ssb.NetworkFrontier{testFeed(1).String(): {Seq: 23, Replicate: true}}, nf)
+
+	// a wrong length is an error
+	var buf bytes.Buffer
+	r.NoError(encodeFrontier(&buf, nf, EncodingCompact))
+	broken := buf.Bytes()
+	r.EqualValues(len(feedTfk), broken[3])
+	broken[3]--
+	_, err = decodeFrontier(bytes.NewReader(broken))
+	r.Error(err)
+}
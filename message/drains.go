@@ -37,37 +37,26 @@ type SaveMessager interface {
 // TODO: needs configuration for hmac and what not..
 // => maybe construct those from a (global) ref register where all the suffixes live with their corresponding network configuration?
 func NewVerifySink(who refs.FeedRef, latest refs.Message, saver SaveMessager, hmacKey *[32]byte) (SequencedVerificationSink, error) {
+	return newVerifySink(builtinFormats, who, latest, saver, hmacKey)
+}
+
+func newVerifySink(formats *FeedFormats, who refs.FeedRef, latest refs.Message, saver SaveMessager, hmacKey *[32]byte) (SequencedVerificationSink, error) {
+	format, has := formats.Get(who.Algo())
+	if !has {
+		return nil, fmt.Errorf("NewVerifySink: unsupported feed algorithm %s", who.Algo())
+	}
+
 	drain := &generalVerifyDrain{
 		who:       who,
 		latestSeq: int64(latest.Seq()),
 		latestMsg: latest,
 		storage:   saver,
-	}
-	switch who.Algo() {
-	case refs.RefAlgoFeedSSB1:
-		drain.verify = &legacyVerify{
-			hmacKey: hmacKey,
-			buf:     new(bytes.Buffer),
-		}
-
-	case refs.RefAlgoFeedGabby:
-		drain.verify = &gabbyVerify{hmacKey: hmacKey}
-
-	case refs.RefAlgoFeedBendyButt:
-		drain.verify = &metafeedVerify{hmacKey: hmacKey}
-
-	default:
-		return nil, fmt.Errorf("NewVerifySink: unsupported feed algorithm %s", who.Algo())
 
+		verify: format.NewVerifier(hmacKey),
 	}
 	return drain, nil
 }
 
-type verifier interface {
-	// Verify checks if a message is valid and returns it or an error if it isn't
-	Verify([]byte) (refs.Message, error)
-}
-
 type legacyVerify struct {
 	hmacKey *[32]byte
 
@@ -140,7 +129,7 @@ func (mv metafeedVerify) Verify(trBytes []byte) (refs.Message, error) {
 
 type generalVerifyDrain struct {
 	// gets the input from the screen and returns the next decoded message, if it is valid
-	verify verifier
+	verify Verifier
 
 	who refs.FeedRef // which feed is pulled
 
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ssbc/go-metafeed"
+
+	gabbygrove "github.com/ssbc/go-gabbygrove"
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// Verifier checks if a message is valid and returns it or an error if it isn't
+type Verifier interface {
+	Verify([]byte) (refs.Message, error)
+}
+
+// Creator constructs and signs the next message of a feed
+type Creator interface {
+	Create(val interface{}, prev refs.MessageRef, seq int64) (refs.Message, error)
+}
+
+// FeedFormat bundles what is needed to verify, sign and construct the messages of one feed format.
+type FeedFormat interface {
+	// Algo is the feed reference algorithm that is handled by this format
+	Algo() refs.RefAlgo
+
+	// NewVerifier returns a verifier for incoming messages of this format
+	NewVerifier(hmacKey *[32]byte) Verifier
+
+	// NewCreator returns a constructor for new messages, signed by kp
+	NewCreator(kp ssb.KeyPair, hmacKey *[32]byte, nowTimestamps bool) (Creator, error)
+}
+
+// FeedFormats is a registry of feed formats, keyed by their feed reference algorithm
+type FeedFormats struct {
+	mu      sync.RWMutex
+	formats map[refs.RefAlgo]FeedFormat
+}
+
+// NewFeedFormats returns a registry with the builtin formats (classic, gabbygrove and bendy-butt) and the passed formats
+func NewFeedFormats(formats ...FeedFormat) (*FeedFormats, error) {
+	ff := &FeedFormats{
+		formats: map[refs.RefAlgo]FeedFormat{
+			refs.RefAlgoFeedSSB1:      legacyFormat{},
+			refs.RefAlgoFeedGabby:     gabbyFormat{},
+			refs.RefAlgoFeedBendyButt: metafeedFormat{},
+		},
+	}
+
+	for _, f := range formats {
+		if err := ff.Register(f); err != nil {
+			return nil, err
+		}
+	}
+	return ff, nil
+}
+
+// builtinFormats is used if no other registry is passed
+var builtinFormats, _ = NewFeedFormats()
+
+// Register adds a new format. It fails if there already is one for the same algorithm.
+func (ff *FeedFormats) Register(f FeedFormat) error {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	algo := f.Algo()
+	if algo == "" {
+		return fmt.Errorf("feed formats: empty algorithm for %T", f)
+	}
+
+	if _, has := ff.formats[algo]; has {
+		return fmt.Errorf("feed formats: %s is already registered", algo)
+	}
+
+	ff.formats[algo] = f
+	return nil
+}
+
+// Get returns the format for the passed algorithm
+func (ff *FeedFormats) Get(algo refs.RefAlgo) (FeedFormat, bool) {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
+	f, has := ff.formats[algo]
+	return f, has
+}
+
+// Algos returns the sorted list of all registered algorithms
+func (ff *FeedFormats) Algos() []refs.RefAlgo {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
+	algos := make([]refs.RefAlgo, 0, len(ff.formats))
+	for algo := range ff.formats {
+		algos = append(algos, algo)
+	}
+	sort.Slice(algos, func(i, j int) bool { return algos[i] < algos[j] })
+	return algos
+}
+
+// builtin formats
+
+type legacyFormat struct{}
+
+func (legacyFormat) Algo() refs.RefAlgo { return refs.RefAlgoFeedSSB1 }
+
+func (legacyFormat) NewVerifier(hmacKey *[32]byte) Verifier {
+	return &legacyVerify{
+		hmacKey: hmacKey,
+		buf:     new(bytes.Buffer),
+	}
+}
+
+func (legacyFormat) NewCreator(kp ssb.KeyPair, hmacKey *[32]byte, nowTimestamps bool) (Creator, error) {
+	return &legacyCreate{
+		key:          kp,
+		hmac:         hmacKey,
+		setTimestamp: nowTimestamps,
+	}, nil
+}
+
+type gabbyFormat struct{}
+
+func (gabbyFormat) Algo() refs.RefAlgo { return refs.RefAlgoFeedGabby }
+
+func (gabbyFormat) NewVerifier(hmacKey *[32]byte) Verifier {
+	return &gabbyVerify{hmacKey: hmacKey}
+}
+
+func (gabbyFormat) NewCreator(kp ssb.KeyPair, hmacKey *[32]byte, nowTimestamps bool) (Creator, error) {
+	enc := gabbygrove.NewEncoder(kp.Secret())
+	if hmacKey != nil {
+		if err := enc.WithHMAC(hmacKey[:]); err != nil {
+			return nil, err
+		}
+	}
+	enc.WithNowTimestamps(nowTimestamps)
+	return &gabbyCreate{enc: enc}, nil
+}
+
+type metafeedFormat struct{}
+
+func (metafeedFormat) Algo() refs.RefAlgo { return refs.RefAlgoFeedBendyButt }
+
+func (metafeedFormat) NewVerifier(hmacKey *[32]byte) Verifier {
+	return &metafeedVerify{hmacKey: hmacKey}
+}
+
+func (metafeedFormat) NewCreator(kp ssb.KeyPair, hmacKey *[32]byte, nowTimestamps bool) (Creator, error) {
+	enc := metafeed.NewEncoder(kp.Secret())
+	if hmacKey != nil {
+		if err := enc.WithHMAC(hmacKey[:]); err != nil {
+			return nil, err
+		}
+	}
+	enc.WithNowTimestamps(nowTimestamps)
+	return &metafeedCreate{enc: enc}, nil
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/message/legacy"
+)
+
+const testAlgo refs.RefAlgo = "test-v1"
+
+// testFormat accepts every message and returns it as the first message of author
+type testFormat struct {
+	author refs.FeedRef
+}
+
+func (testFormat) Algo() refs.RefAlgo { return testAlgo }
+
+func (tf testFormat) NewVerifier(*[32]byte) Verifier { return tf }
+
+func (tf testFormat) NewCreator(ssb.KeyPair, *[32]byte, bool) (Creator, error) { return nil, nil }
+
+func (tf testFormat) Verify(b []byte) (refs.Message, error) {
+	key, err := refs.NewMessageRefFromBytes(make([]byte, 32), refs.RefAlgoMessageSSB1)
+	if err != nil {
+		return nil, err
+	}
+	return &legacy.StoredMessage{
+		Key_:      storedrefs.SerialzedMessage{MessageRef: key},
+		Author_:   storedrefs.SerialzedFeed{FeedRef: tf.author},
+		Sequence_: 1,
+		Raw_:      b,
+	}, nil
+}
+
+type collectSaver []refs.Message
+
+func (cs *collectSaver) Save(msg refs.Message) error {
+	*cs = append(*cs, msg)
+	return nil
+}
+
+func TestFeedFormatsRegistry(t *testing.T) {
+	r := require.New(t)
+
+	author, err := refs.NewFeedRefFromBytes(make([]byte, 32), testAlgo)
+	r.NoError(err)
+
+	ff, err := NewFeedFormats(testFormat{author: author})
+	r.NoError(err)
+	r.Contains(ff.Algos(), testAlgo)
+	r.Contains(ff.Algos(), refs.RefAlgoFeedSSB1)
+
+	r.Error(ff.Register(testFormat{}), "duplicate format")
+	r.Error(ff.Register(legacyFormat{}), "builtin formats can't be replaced")
+
+	_, has := builtinFormats.Get(testAlgo)
+	r.False(has, "builtin registry should not be changed")
+
+	// the builtin formats don't know the new feed
+	var saver collectSaver
+	_, err = NewVerifySink(author, firstMessage(author), &saver, nil)
+	r.Error(err)
+
+	snk, err := newVerifySink(ff, author, firstMessage(author), &saver, nil)
+	r.NoError(err)
+	r.NoError(snk.Verify([]byte("hello")))
+	r.Len(saver, 1)
+	r.EqualValues(1, snk.Seq())
+	r.EqualValues("hello", saver[0].ValueContentJSON())
+}
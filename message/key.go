@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"fmt"
+
+	gabbygrove "github.com/ssbc/go-gabbygrove"
+	"github.com/ssbc/go-metafeed"
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/message/legacy"
+)
+
+// ComputeMessageKey returns the key of a signed message, without verifying its signature.
+// algo can be the feed or the message algorithm of the format.
+//
+// Classic (ssb1) messages are pretty printed first, the way the javascript implementation does it,
+// so that compact and indented JSON result in the same key.
+// Gabbygrove messages are expected as the CBOR encoded transfer and bendy-butt messages as their bencode encoding.
+func ComputeMessageKey(signedBytes []byte, algo refs.RefAlgo) (refs.MessageRef, error) {
+	switch algo {
+	case refs.RefAlgoFeedSSB1, refs.RefAlgoMessageSSB1:
+		enc, err := legacy.PrettyPrint(signedBytes)
+		if err != nil {
+			return refs.MessageRef{}, fmt.Errorf("message key: failed to encode classic message: %w", err)
+		}
+		mr, err := legacy.MessageKey(enc)
+		if err != nil {
+			return refs.MessageRef{}, fmt.Errorf("message key: failed to hash classic message: %w", err)
+		}
+		return mr, nil
+
+	case refs.RefAlgoFeedGabby:
+		var tr gabbygrove.Transfer
+		if err := tr.UnmarshalCBOR(signedBytes); err != nil {
+			return refs.MessageRef{}, fmt.Errorf("message key: failed to decode gabbygrove message: %w", err)
+		}
+		return tr.Key(), nil
+
+	case refs.RefAlgoFeedBendyButt:
+		var msg metafeed.Message
+		if err := msg.UnmarshalBencode(signedBytes); err != nil {
+			return refs.MessageRef{}, fmt.Errorf("message key: failed to decode bendy-butt message: %w", err)
+		}
+		return msg.Key(), nil
+	}
+	return refs.MessageRef{}, fmt.Errorf("message key: unsupported algorithm %q", algo)
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"bytes"
+	"testing"
+
+	gabbygrove "github.com/ssbc/go-gabbygrove"
+	"github.com/ssbc/go-metafeed"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/message/legacy"
+)
+
+func TestComputeMessageKey(t *testing.T) {
+	r := require.New(t)
+
+	// keys as computed by the javascript stack
+	type vector struct {
+		key string
+		msg string
+	}
+	var vectors = []vector{
+		{
+			// a non-latin1 character, which is truncated by v8 before hashing
+			key: "%hB4euYhbMQ7MMbs6ADwSgDmhwreYVy0pDqnQ5h6FmfY=.sha256",
+			msg: `{"previous":"%wnU7ZsmM2sfzPG2vJDZhiQNV/mZYMPjFof7JPhHQkj0=.sha256","sequence":182,"author":"@uOReuhnb9+mPi5RnTbKMKRr3r87cK+aOg8lFXV/SBPU=.ed25519","timestamp":1523639272202,"hash":"sha256","content":{"type":"post","text":"I’ll just leave this here: https://thebaffler.com/salvos/blame-the-computer-pein\n\nQuite amazed after reading the first half of it but sadly need to go now.."},"signature":"3rxpwIGR5DqkG/s1roIvMzw27r0lNoCQ4rmn4TT6S8WrAjJnT+SFCXegTnXWL00ul37WuiC28qwMH/2PbibgAA==.sig.ed25519"}`,
+		},
+		{
+			// nested arrays with null and false
+			key: "%2wLn/3F00bsMSbrbtDmMQR3AFyBTVLszC3bkJ3p+MnY=.sha256",
+			msg: `{"previous":"%Ym5QnkNCtIHgZG8yk0NBU/ZibTc6qNk1QQov5k5JTl4=.sha256","author":"@f/6sQ6d2CMxRUhLpspgGIulDxDCwYD7DzFzPNr7u5AU=.ed25519","sequence":7836,"timestamp":1508190205432,"hash":"sha256","content":{"type":"npm-packages","mentions":[[null,false]]},"signature":"+uX4y2HwatiR4pvwqIzJL30x4XfTA/MeusQAMI6gT9rawbT5Y7uU40Y8JLgKXKYJtwQ9E5zR70kDYqefbHYVCw==.sig.ed25519"}`,
+		},
+	}
+	for i, v := range vectors {
+		key, err := ComputeMessageKey([]byte(v.msg), refs.RefAlgoFeedSSB1)
+		r.NoError(err, "vector %d", i)
+		r.Equal(v.key, key.String(), "vector %d", i)
+
+		// the message algorithm and the pretty printed form give the same key
+		enc, err := legacy.PrettyPrint([]byte(v.msg))
+		r.NoError(err)
+		key, err = ComputeMessageKey(enc, refs.RefAlgoMessageSSB1)
+		r.NoError(err, "vector %d", i)
+		r.Equal(v.key, key.String(), "vector %d", i)
+	}
+
+	kp, err := ssb.NewKeyPair(bytes.NewReader(bytes.Repeat([]byte{1}, 32)), refs.RefAlgoFeedGabby)
+	r.NoError(err)
+	tr, ggKey, err := gabbygrove.NewEncoder(kp.Secret()).Encode(1, gabbygrove.BinaryRef{}, "hello, world")
+	r.NoError(err)
+	ggBytes, err := tr.MarshalCBOR()
+	r.NoError(err)
+	key, err := ComputeMessageKey(ggBytes, refs.RefAlgoFeedGabby)
+	r.NoError(err)
+	r.True(ggKey.Equal(key))
+
+	mfMsg, mfKey, err := metafeed.NewEncoder(kp.Secret()).Encode(1, refs.MessageRef{}, map[string]interface{}{"type": "test"})
+	r.NoError(err)
+	mfBytes, err := mfMsg.MarshalBencode()
+	r.NoError(err)
+	key, err = ComputeMessageKey(mfBytes, refs.RefAlgoFeedBendyButt)
+	r.NoError(err)
+	r.True(mfKey.Equal(key))
+
+	_, err = ComputeMessageKey([]byte("{}"), refs.RefAlgoFeedBamboo)
+	r.Error(err)
+	_, err = ComputeMessageKey([]byte("nope"), refs.RefAlgoFeedSSB1)
+	r.Error(err)
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package legacy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"golang.org/x/crypto/ed25519"
+)
+
+// MetafeedRekey is published as the last message of a classic feed whose owner moved to a new key.
+// It points followers of the old feed to the metafeed of the new identity.
+// The old key signs the message as the author and the metafeed key signs the content,
+// so both sides agree to the move. The metafeed links back with a metafeed/add/existing message for the old feed.
+// Nothing should be published on the old feed after it, it acts as the tombstone of the old feed.
+type MetafeedRekey struct {
+	Type     string       `json:"type"`
+	Old      refs.FeedRef `json:"old"`
+	Metafeed refs.FeedRef `json:"metafeed"`
+}
+
+const metafeedRekeyType = "metafeed/rekey"
+
+// NewMetafeedRekey creates a fresh MetafeedRekey value, moving theOld feed to theMeta.
+func NewMetafeedRekey(theMeta, theOld refs.FeedRef) MetafeedRekey {
+	return MetafeedRekey{
+		Type:     metafeedRekeyType,
+		Old:      theOld,
+		Metafeed: theMeta,
+	}
+}
+
+// Sign takes the private key of the new metafeed and returns the signed JSON message, ready to be published on the old feed.
+// it also takes an optional HMAC secret, if the network is using that signature mode.
+func (mr MetafeedRekey) Sign(priv ed25519.PrivateKey, hmacSecret *[32]byte) (json.RawMessage, error) {
+	rekeyV8Format, err := jsonAndPreserve(mr)
+	if err != nil {
+		return nil, fmt.Errorf("legacySign: error during sign prepare: %w", err)
+	}
+
+	rekeyV8Format = maybeHMAC(rekeyV8Format, hmacSecret)
+
+	var signedMsg signedMetafeedRekey
+	signedMsg.MetafeedRekey = mr
+	signedMsg.Signature = ed25519.Sign(priv, rekeyV8Format)
+
+	return json.Marshal(signedMsg)
+}
+
+// signedMetafeedRekey wrapps a MetafeedRekey with a Signature
+type signedMetafeedRekey struct {
+	MetafeedRekey
+
+	Signature Signature `json:"signature"`
+}
+
+// VerifyMetafeedRekey takes a raw json body and asserts the validity of the signature and that it was published on the old feed.
+func VerifyMetafeedRekey(data []byte, oldAuthor refs.FeedRef, hmacSecret *[32]byte) (MetafeedRekey, bool) {
+	var signedRekey signedMetafeedRekey
+	err := json.Unmarshal(data, &signedRekey)
+	if err != nil {
+		return MetafeedRekey{}, false
+	}
+
+	if signedRekey.Type != metafeedRekeyType {
+		return MetafeedRekey{}, false
+	}
+
+	// a rekey can only be published by the feed that is moved
+	if !signedRekey.Old.Equal(oldAuthor) {
+		return MetafeedRekey{}, false
+	}
+
+	if signedRekey.Metafeed.Algo() != refs.RefAlgoFeedBendyButt {
+		return MetafeedRekey{}, false
+	}
+
+	v8indented, err := PrettyPrint(data)
+	if err != nil {
+		return MetafeedRekey{}, false
+	}
+
+	msg, _, err := ExtractSignature(v8indented)
+	if err != nil {
+		return MetafeedRekey{}, false
+	}
+
+	msg = maybeHMAC(msg, hmacSecret)
+
+	err = signedRekey.Signature.Verify(msg, signedRekey.Metafeed)
+	if err != nil {
+		return MetafeedRekey{}, false
+	}
+
+	return signedRekey.MetafeedRekey, true
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package legacy_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/message/legacy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignMetafeedRekey(t *testing.T) {
+	r := require.New(t)
+
+	var hmacSecret [32]byte
+	rand.Read(hmacSecret[:])
+
+	theOld, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	theMeta, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedBendyButt)
+	r.NoError(err)
+
+	signedMsg, err := legacy.NewMetafeedRekey(theMeta.ID(), theOld.ID()).Sign(theMeta.Secret(), &hmacSecret)
+	r.NoError(err)
+
+	mr, ok := legacy.VerifyMetafeedRekey(signedMsg, theOld.ID(), &hmacSecret)
+	r.True(ok, "verify failed")
+	r.True(mr.Metafeed.Equal(theMeta.ID()))
+
+	// published on another feed
+	_, ok = legacy.VerifyMetafeedRekey(signedMsg, theMeta.ID(), &hmacSecret)
+	r.False(ok, "verified on the wrong feed")
+
+	// without the hmac
+	_, ok = legacy.VerifyMetafeedRekey(signedMsg, theOld.ID(), nil)
+	r.False(ok, "verified without hmac")
+
+	// signed by someone else
+	signedMsg, err = legacy.NewMetafeedRekey(theMeta.ID(), theOld.ID()).Sign(theOld.Secret(), &hmacSecret)
+	r.NoError(err)
+	_, ok = legacy.VerifyMetafeedRekey(signedMsg, theOld.ID(), &hmacSecret)
+	r.False(ok, "verified with the wrong signature")
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package legacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewDecoder returns a json.Decoder that decodes numbers into interface{} values as json.Number, instead of float64.
+// The number keeps its original text, so that content which is encoded again is byte-identical to the input.
+// Use it for message content that is published or signed again, a float64 can't hold integers above 2^53.
+func NewDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// Unmarshal is json.Unmarshal with the number handling of NewDecoder.
+func Unmarshal(data []byte, v interface{}) error {
+	dec := NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("ssb/legacy: unexpected data after the JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package legacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+)
+
+func TestLargeIntegerRoundtrip(t *testing.T) {
+	r := require.New(t)
+
+	kp, err := ssb.NewKeyPair(bytes.NewReader(makeRandBytes(t, 32)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	input := []byte(`{"type":"test","big":9223372036854775807,"odd":9007199254740993,"small":-42,"float":1.5}`)
+
+	sign := func(content interface{}) []byte {
+		var msg LegacyMessage
+		msg.Author = kp.ID().String()
+		msg.Sequence = 1
+		msg.Timestamp = 1
+		msg.Hash = "sha256"
+		msg.Content = content
+
+		_, signed, err := msg.Sign(kp.Secret(), nil)
+		r.NoError(err)
+
+		_, dmsg, err := Verify(signed, nil)
+		r.NoError(err)
+
+		var compact bytes.Buffer
+		r.NoError(json.Compact(&compact, dmsg.Content))
+		return compact.Bytes()
+	}
+
+	// float64 rounds the integers
+	var lossy interface{}
+	r.NoError(json.Unmarshal(input, &lossy))
+	r.NotEqual(string(input), string(sign(lossy)))
+
+	// raw content is signed as it is
+	r.Equal(string(input), string(sign(json.RawMessage(input))))
+
+	// decoded content keeps the numbers
+	var content, signed interface{}
+	r.NoError(Unmarshal(input, &content))
+	r.NoError(Unmarshal(sign(content), &signed))
+	r.Equal(content, signed)
+	r.Equal(json.Number("9007199254740993"), signed.(map[string]interface{})["odd"])
+
+	r.Error(Unmarshal([]byte(`{"type":"test"} {}`), &content), "trailing data")
+}
@@ -7,12 +7,10 @@ package legacy
 import (
 	"bytes"
 	"crypto/ed25519"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"regexp"
 
 	refs "github.com/ssbc/go-ssb-refs"
@@ -160,16 +158,11 @@ func (msg LegacyMessage) Sign(priv ed25519.PrivateKey, hmacSecret *[32]byte) (re
 		return refs.MessageRef{}, nil, fmt.Errorf("legacySign: error re-encoding signed message: %w", err)
 	}
 
-	v8warp, err := InternalV8Binary(ppWithSig)
+	mr, err := MessageKey(ppWithSig)
 	if err != nil {
 		return refs.MessageRef{}, nil, fmt.Errorf("legacySign: could not v8 escape message: %w", err)
 	}
-
-	h := sha256.New()
-	io.Copy(h, bytes.NewReader(v8warp))
-
-	mr, err := refs.NewMessageRefFromBytes(h.Sum(nil), refs.RefAlgoMessageSSB1)
-	return mr, ppWithSig, err
+	return mr, ppWithSig, nil
 }
 
 func jsonAndPreserve(msg interface{}) ([]byte, error) {
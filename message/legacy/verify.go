@@ -123,16 +123,24 @@ func VerifyWithBuffer(raw []byte, hmacSecret *[32]byte, buf *bytes.Buffer) (refs
 		return emptyMsgRef, emptyDMsg, fmt.Errorf("ssb Verify(%s:%d): %w", dmsg.Author.String(), dmsg.Sequence, err)
 	}
 
-	// hash the message - it's sadly the internal string rep of v8 that get's hashed, not the json string
-	v8warp, err := InternalV8Binary(enc)
+	mr, err := MessageKey(enc)
 	if err != nil {
 		return emptyMsgRef, emptyDMsg, fmt.Errorf("ssb Verify(%s:%d): could hash convert message: %w", dmsg.Author.String(), dmsg.Sequence, err)
 	}
+	return mr, dmsg, nil
+}
+
+// MessageKey returns the key of a signed message, which already has to be pretty printed like PrettyPrint does.
+// It's sadly the internal string representation of v8 that gets hashed, not the json string.
+func MessageKey(enc []byte) (refs.MessageRef, error) {
+	v8warp, err := InternalV8Binary(enc)
+	if err != nil {
+		return refs.MessageRef{}, err
+	}
 	h := sha256.New()
 	io.Copy(h, bytes.NewReader(v8warp))
 
-	mr, err := refs.NewMessageRefFromBytes(h.Sum(nil), refs.RefAlgoMessageSSB1)
-	return mr, dmsg, err
+	return refs.NewMessageRefFromBytes(h.Sum(nil), refs.RefAlgoMessageSSB1)
 }
 
 // if HMAC mode is enabled for the network, we hash the message using nacl.Auth
@@ -7,6 +7,7 @@ package message
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"sync"
@@ -31,7 +32,15 @@ type publishLog struct {
 	receiveLog margaret.Log
 	waitForIndexesCallback func()
 
-	create creater
+	validate  ContentValidator
+	gate      func(n int) error
+	published func(rxSeq int64)
+
+	create Creator
+
+	// the message of the last dry run and its marshaled content, see createNext
+	dryRun        refs.Message
+	dryRunContent []byte
 }
 
 func (pl *publishLog) Publish(content interface{}) (refs.Message, error) {
@@ -53,11 +62,11 @@ func (pl *publishLog) Publish(content interface{}) (refs.Message, error) {
 	return kv, nil
 }
 
-func (pl publishLog) Changes() luigi.Observable {
+func (pl *publishLog) Changes() luigi.Observable {
 	return pl.byAuthor.Changes()
 }
 
-func (pl publishLog) Seq() int64 {
+func (pl *publishLog) Seq() int64 {
 	if pl.waitForIndexesCallback != nil {
 		pl.waitForIndexesCallback()
 	}
@@ -66,7 +75,7 @@ func (pl publishLog) Seq() int64 {
 }
 
 // Get retreives the message object by traversing the authors sublog to the root log
-func (pl publishLog) Get(s int64) (interface{}, error) {
+func (pl *publishLog) Get(s int64) (interface{}, error) {
 	idxv, err := pl.byAuthor.Get(s)
 	if err != nil {
 		return nil, fmt.Errorf("publish get: failed to retreive sequence for the root log: %w", err)
@@ -79,57 +88,217 @@ func (pl publishLog) Get(s int64) (interface{}, error) {
 	return msgv, nil
 }
 
-func (pl publishLog) Query(qry ...margaret.QuerySpec) (luigi.Source, error) {
+func (pl *publishLog) Query(qry ...margaret.QuerySpec) (luigi.Source, error) {
 	return mutil.Indirect(pl.receiveLog, pl.byAuthor).Query(qry...)
 }
 
 func (pl *publishLog) Append(val interface{}) (int64, error) {
+	if pl.gate != nil {
+		if err := pl.gate(1); err != nil {
+			return -2, err
+		}
+	}
+
 	// wait for indexes to catch up before pulling a mutex so we're not locking unnecessarily
 	if pl.waitForIndexesCallback != nil {
 		pl.waitForIndexesCallback()
 	}
 
+	if pl.validate != nil {
+		if err := validateContent(pl.validate, val); err != nil {
+			return -2, err
+		}
+	}
+
 	pl.mu.Lock()
 	defer pl.mu.Unlock()
 
-	// current state of the local sig-chain
-	var (
-		nextPrevious refs.MessageRef
-		nextSequence = int64(-1)
-	)
+	nextPrevious, nextSequence, err := pl.next()
+	if err != nil {
+		return -2, err
+	}
 
-	seq := pl.byAuthor.Seq()
+	nextMsg, err := pl.createNext(val, nextPrevious, nextSequence)
+	if err != nil {
+		return -2, fmt.Errorf("failed to create next msg: %w", err)
+	}
 
-	currRootSeq, err := pl.byAuthor.Get(seq)
-	if err != nil && !luigi.IsEOS(err) {
-		return -2, fmt.Errorf("publishLog: failed to retreive current msg: %w", err)
+	rlSeq, err := pl.receiveLog.Append(nextMsg)
+	if err != nil {
+		return -2, fmt.Errorf("failed to append new msg: %w", err)
 	}
-	if luigi.IsEOS(err) { // new feed
-		nextSequence = 1
-	} else {
-		currMM, err := pl.receiveLog.Get(currRootSeq.(int64))
+	pl.dryRun, pl.dryRunContent = nil, nil
+
+	if pl.published != nil {
+		pl.published(rlSeq)
+	}
+	return rlSeq, nil
+}
+
+// PublishBatch signs all the contents as a contiguous run of messages and appends them afterwards.
+// The indexes are only waited for once, before the batch, instead of for every message.
+//
+// The batch is all-or-nothing: all the contents are validated and signed before the first one is written,
+// so that an invalid entry aborts the whole batch without changing the feed.
+// If storing fails half way through, the entries that were already written are nulled again
+// and the published callback is only called once the whole batch is stored.
+// The next message then continues the feed after the last entry that wasn't nulled, see next.
+func (pl *publishLog) PublishBatch(contents []interface{}) ([]refs.MessageRef, error) {
+	if pl.gate != nil {
+		if err := pl.gate(len(contents)); err != nil {
+			return nil, err
+		}
+	}
+
+	if pl.waitForIndexesCallback != nil {
+		pl.waitForIndexesCallback()
+	}
+
+	if pl.validate != nil {
+		for i, val := range contents {
+			if err := validateContent(pl.validate, val); err != nil {
+				return nil, fmt.Errorf("publish batch: entry %d: %w", i, err)
+			}
+		}
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	nextPrevious, nextSequence, err := pl.next()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]refs.Message, len(contents))
+	for i, val := range contents {
+		msgs[i], err = pl.createNext(val, nextPrevious, nextSequence)
 		if err != nil {
-			return -2, fmt.Errorf("publishLog: failed to establish current seq: %w", err)
+			return nil, fmt.Errorf("publish batch: failed to create entry %d: %w", i, err)
 		}
-		mm, ok := currMM.(refs.Message)
-		if !ok {
-			return -2, fmt.Errorf("publishLog: invalid value at sequence %v: %T", seq, currMM)
+		nextPrevious = msgs[i].Key()
+		nextSequence++
+	}
+
+	rxSeqs := make([]int64, 0, len(msgs))
+	for i, msg := range msgs {
+		rlSeq, err := pl.receiveLog.Append(msg)
+		if err != nil {
+			err = fmt.Errorf("publish batch: failed to append entry %d of %d: %w", i, len(msgs), err)
+			if rbErr := pl.rollback(rxSeqs); rbErr != nil {
+				return nil, fmt.Errorf("%s (rollback failed: %v)", err, rbErr)
+			}
+			return nil, err
 		}
-		nextPrevious = mm.Key()
-		nextSequence = mm.Seq() + 1
+		rxSeqs = append(rxSeqs, rlSeq)
 	}
+	pl.dryRun, pl.dryRunContent = nil, nil
 
-	nextMsg, err := pl.create.Create(val, nextPrevious, nextSequence)
+	written := make([]refs.MessageRef, len(msgs))
+	for i, msg := range msgs {
+		written[i] = msg.Key()
+		if pl.published != nil {
+			pl.published(rxSeqs[i])
+		}
+	}
+	return written, nil
+}
+
+// rollback nulls the passed entries of the receive log, newest first
+func (pl *publishLog) rollback(rxSeqs []int64) error {
+	if len(rxSeqs) == 0 {
+		return nil
+	}
+
+	alterer, ok := pl.receiveLog.(margaret.Alterer)
+	if !ok {
+		return fmt.Errorf("receive log can't null entries (%T)", pl.receiveLog)
+	}
+
+	for i := len(rxSeqs) - 1; i >= 0; i-- {
+		if err := alterer.Null(rxSeqs[i]); err != nil {
+			return fmt.Errorf("failed to null entry %d: %w", rxSeqs[i], err)
+		}
+	}
+	return nil
+}
+
+// DryRunPublish validates and signs content as the next message of the feed, like Publish would, but doesn't store it.
+// The feed doesn't advance, so another dry run or Publish afterwards signs against the same previous and sequence.
+// The message is kept until the feed advances: a dry run or Publish of the same content returns it again,
+// so its timestamp, bytes and key stay the same.
+func (pl *publishLog) DryRunPublish(content interface{}) (refs.Message, error) {
+	if pl.waitForIndexesCallback != nil {
+		pl.waitForIndexesCallback()
+	}
+
+	if pl.validate != nil {
+		if err := validateContent(pl.validate, content); err != nil {
+			return nil, err
+		}
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	nextPrevious, nextSequence, err := pl.next()
 	if err != nil {
-		return -2, fmt.Errorf("failed to create next msg: %w", err)
+		return nil, err
 	}
 
-	rlSeq, err := pl.receiveLog.Append(nextMsg)
+	msg, err := pl.createNext(content, nextPrevious, nextSequence)
 	if err != nil {
-		return -2, fmt.Errorf("failed to append new msg: %w", err)
+		return nil, fmt.Errorf("dry run: failed to create next msg: %w", err)
 	}
 
-	return rlSeq, nil
+	pl.dryRun = msg
+	pl.dryRunContent, err = json.Marshal(content)
+	if err != nil {
+		pl.dryRun = nil
+	}
+	return msg, nil
+}
+
+// createNext signs val as the message after previous, unless the last dry run signed the same content there.
+// Then that message is returned, so that it is the same as the one the dry run returned.
+func (pl *publishLog) createNext(val interface{}, previous refs.MessageRef, seq int64) (refs.Message, error) {
+	if pl.dryRun != nil && pl.dryRun.Seq() == seq {
+		dryPrev := pl.dryRun.Previous()
+		samePrev := (dryPrev == nil && seq == 1) || (dryPrev != nil && dryPrev.Equal(previous))
+
+		content, err := json.Marshal(val)
+		if samePrev && err == nil && bytes.Equal(content, pl.dryRunContent) {
+			return pl.dryRun, nil
+		}
+	}
+	return pl.create.Create(val, previous, seq)
+}
+
+// next returns the previous and sequence for the next message of the local sig-chain.
+// Entries at the end of the feed that were nulled by the rollback of a failed batch are skipped.
+func (pl *publishLog) next() (refs.MessageRef, int64, error) {
+	for seq := pl.byAuthor.Seq(); seq >= 0; seq-- {
+		currRootSeq, err := pl.byAuthor.Get(seq)
+		if err != nil {
+			return refs.MessageRef{}, -1, fmt.Errorf("publishLog: failed to retreive current msg: %w", err)
+		}
+
+		currMM, err := pl.receiveLog.Get(currRootSeq.(int64))
+		if margaret.IsErrNulled(err) {
+			continue
+		}
+		if err != nil {
+			return refs.MessageRef{}, -1, fmt.Errorf("publishLog: failed to establish current seq: %w", err)
+		}
+		mm, ok := currMM.(refs.Message)
+		if !ok {
+			return refs.MessageRef{}, -1, fmt.Errorf("publishLog: invalid value at sequence %v: %T", seq, currMM)
+		}
+		return mm.Key(), mm.Seq() + 1, nil
+	}
+
+	// new feed
+	return refs.MessageRef{}, 1, nil
 }
 
 // OpenPublishLog needs the base datastore (root or receive log - offset2)
@@ -149,79 +318,122 @@ func OpenPublishLog(receiveLog margaret.Log, authorLogs multilog.MultiLog, kp ss
 		receiveLog: receiveLog,
 	}
 
-	switch kp.ID().Algo() {
-	case refs.RefAlgoFeedSSB1:
-		pl.create = &legacyCreate{
-			key: kp,
-		}
-	case refs.RefAlgoFeedGabby:
-		pl.create = &gabbyCreate{
-			enc: gabbygrove.NewEncoder(kp.Secret()),
-		}
-	case refs.RefAlgoFeedBendyButt:
-		pl.create = &metafeedCreate{
-			enc: metafeed.NewEncoder(kp.Secret()),
+	var cfg = publishConfig{formats: builtinFormats}
+	for i, o := range opts {
+		if err := o(&cfg); err != nil {
+			return nil, fmt.Errorf("publish: option %d failed: %w", i, err)
 		}
-	default:
+	}
+	pl.waitForIndexesCallback = cfg.waitForIndexesCallback
+	pl.validate = cfg.validate
+	pl.gate = cfg.gate
+	pl.published = cfg.published
+
+	format, has := cfg.formats.Get(kp.ID().Algo())
+	if !has {
 		return nil, fmt.Errorf("publish: unsupported feed algorithm: %s", kp.ID().Algo())
 	}
 
-	for i, o := range opts {
-		if err := o(pl); err != nil {
-			return nil, fmt.Errorf("publish: option %d failed: %w", i, err)
-		}
+	pl.create, err = format.NewCreator(kp, cfg.hmacKey, cfg.nowTimestamps)
+	if err != nil {
+		return nil, fmt.Errorf("publish: failed to create %s message constructor: %w", kp.ID().Algo(), err)
 	}
 
 	return pl, nil
 }
 
-type PublishOption func(*publishLog) error
+// publishConfig collects the options, the message constructor is created after all of them are applied
+type publishConfig struct {
+	hmacKey       *[32]byte
+	nowTimestamps bool
+
+	formats *FeedFormats
+
+	waitForIndexesCallback func()
+
+	validate  ContentValidator
+	gate      func(n int) error
+	published func(rxSeq int64)
+}
+
+type PublishOption func(*publishConfig) error
 
 func SetHMACKey(hmackey *[32]byte) PublishOption {
-	return func(pl *publishLog) error {
-		if hmackey == nil {
-			return nil
-		}
-		var err error
-		switch cv := pl.create.(type) {
-		case *legacyCreate:
-			cv.hmac = hmackey
-		case *gabbyCreate:
-			err = cv.enc.WithHMAC(hmackey[:])
-		case *metafeedCreate:
-			err = cv.enc.WithHMAC(hmackey[:])
-		default:
-			err = fmt.Errorf("hmac: unknown creater: %T", cv)
-		}
-		return err
+	return func(cfg *publishConfig) error {
+		cfg.hmacKey = hmackey
+		return nil
 	}
 }
 
 func UseNowTimestamps(yes bool) PublishOption {
-	return func(pl *publishLog) error {
-		switch cv := pl.create.(type) {
-		case *legacyCreate:
-			cv.setTimestamp = yes
-		case *gabbyCreate:
-			cv.enc.WithNowTimestamps(yes)
-		case *metafeedCreate:
-			cv.enc.WithNowTimestamps(yes)
-		default:
-			return fmt.Errorf("setTimestamp: unknown creater: %T", cv)
+	return func(cfg *publishConfig) error {
+		cfg.nowTimestamps = yes
+		return nil
+	}
+}
+
+// UseFeedFormats looks up the message constructor for the keypair in the passed registry instead of the builtin formats
+func UseFeedFormats(ff *FeedFormats) PublishOption {
+	return func(cfg *publishConfig) error {
+		if ff == nil {
+			return fmt.Errorf("feed formats: registry is nil")
 		}
+		cfg.formats = ff
 		return nil
 	}
 }
 
 func UseWaitForIndexesCallback(cb func()) PublishOption {
-	return func(pl *publishLog) error {
-		pl.waitForIndexesCallback = cb
+	return func(cfg *publishConfig) error {
+		cfg.waitForIndexesCallback = cb
 		return nil
 	}
 }
 
-type creater interface {
-	Create(val interface{}, prev refs.MessageRef, seq int64) (refs.Message, error)
+// UsePublishGate calls gate with the number of messages before a message or batch is published.
+// It can block until publishing should go on or return an error, which aborts the publish. Dry runs are not gated.
+func UsePublishGate(gate func(n int) error) PublishOption {
+	return func(cfg *publishConfig) error {
+		cfg.gate = gate
+		return nil
+	}
+}
+
+// UsePublishedCallback calls cb with the receive log sequence of every message, once it is stored.
+// It is called while the feed is locked for the next publish, so it needs to return quickly.
+func UsePublishedCallback(cb func(rxSeq int64)) PublishOption {
+	return func(cfg *publishConfig) error {
+		cfg.published = cb
+		return nil
+	}
+}
+
+// ContentValidator checks the content of a new message before it is signed.
+// raw is the JSON encoding of the content and contentType its type field, which is empty for encrypted or untyped content.
+// A non-nil error aborts the publish.
+type ContentValidator func(contentType string, raw json.RawMessage) error
+
+// UseContentValidator runs v on the content of every new message before it is signed
+func UseContentValidator(v ContentValidator) PublishOption {
+	return func(cfg *publishConfig) error {
+		cfg.validate = v
+		return nil
+	}
+}
+
+func validateContent(v ContentValidator, val interface{}) error {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("publish: failed to encode content for validation: %w", err)
+	}
+
+	var typed struct {
+		Type string `json:"type"`
+	}
+	// not an object (like boxed strings), leaves the type empty
+	_ = json.Unmarshal(raw, &typed)
+
+	return v(typed.Type, raw)
 }
 
 type legacyCreate struct {
@@ -6,18 +6,22 @@ package message
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ssbc/margaret"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/internal/asynctesting"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
 	"github.com/ssbc/go-ssb/multilogs"
 	"github.com/ssbc/go-ssb/repo"
 )
@@ -108,3 +112,256 @@ func TestSignMessages(t *testing.T) {
 	cancel()
 	r.NoError(<-errc, "serveLog failed")
 }
+
+func TestPublishValidator(t *testing.T) {
+	r := require.New(t)
+	a := assert.New(t)
+
+	rpath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(rpath)
+
+	testRepo := repo.New(rpath)
+	rl, err := repo.OpenLog(testRepo)
+	r.NoError(err, "failed to open root log")
+	t.Cleanup(func() {
+		rl.Close()
+	})
+
+	userFeeds, userFeedsSnk, err := repo.OpenStandaloneMultiLog(testRepo, "testUsers", multilogs.UserFeedsUpdate)
+	r.NoError(err, "failed to get user feeds multilog")
+	t.Cleanup(func() {
+		userFeeds.Close()
+		userFeedsSnk.Close()
+	})
+
+	killServe, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	errc := asynctesting.ServeLog(killServe, t.Name(), rl, userFeedsSnk, true)
+
+	testAuthor, err := ssb.NewKeyPair(rand.New(rand.NewSource(42)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	errTooLong := errors.New("post too long")
+
+	var seen []string
+	validate := func(contentType string, raw json.RawMessage) error {
+		seen = append(seen, contentType+":"+string(raw))
+		if contentType == "post" && len(raw) > 64 {
+			return errTooLong
+		}
+		return nil
+	}
+
+	w, err := OpenPublishLog(rl, userFeeds, testAuthor, UseContentValidator(validate))
+	r.NoError(err)
+
+	_, err = w.Append(map[string]interface{}{"type": "post", "text": "short"})
+	r.NoError(err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = w.Append(map[string]interface{}{"type": "post", "text": "this one is way too long to be accepted by the validator"})
+	r.ErrorIs(err, errTooLong)
+	a.EqualValues(0, rl.Seq(), "rejected message was stored")
+
+	_, err = w.Append("c2VjcmV0.box")
+	r.NoError(err)
+
+	r.Len(seen, 3)
+	a.Equal(`post:{"text":"short","type":"post"}`, seen[0])
+	a.Equal(`:"c2VjcmV0.box"`, seen[2])
+
+	cancel()
+	r.NoError(<-errc, "serveLog failed")
+}
+
+func TestPublishBatch(t *testing.T) {
+	r := require.New(t)
+	a := assert.New(t)
+
+	rpath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(rpath)
+
+	testRepo := repo.New(rpath)
+	rl, err := repo.OpenLog(testRepo)
+	r.NoError(err, "failed to open root log")
+	t.Cleanup(func() {
+		rl.Close()
+	})
+
+	userFeeds, userFeedsSnk, err := repo.OpenStandaloneMultiLog(testRepo, "testUsers", multilogs.UserFeedsUpdate)
+	r.NoError(err, "failed to get user feeds multilog")
+	t.Cleanup(func() {
+		userFeeds.Close()
+		userFeedsSnk.Close()
+	})
+
+	killServe, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	errc := asynctesting.ServeLog(killServe, t.Name(), rl, userFeedsSnk, true)
+
+	testAuthor, err := ssb.NewKeyPair(rand.New(rand.NewSource(42)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	errRejected := errors.New("rejected")
+	validate := func(contentType string, raw json.RawMessage) error {
+		if contentType == "reject" {
+			return errRejected
+		}
+		return nil
+	}
+
+	w, err := OpenPublishLog(rl, userFeeds, testAuthor, UseContentValidator(validate))
+	r.NoError(err)
+	bp, ok := w.(ssb.BatchPublisher)
+	r.True(ok, "publish log should support batches")
+
+	// one invalid entry aborts the whole batch
+	_, err = bp.PublishBatch([]interface{}{
+		map[string]interface{}{"type": "post", "text": "one"},
+		map[string]interface{}{"type": "reject"},
+	})
+	r.ErrorIs(err, errRejected)
+	a.EqualValues(-1, rl.Seq(), "nothing should be stored")
+
+	var batch []interface{}
+	for i := 0; i < 5; i++ {
+		batch = append(batch, map[string]interface{}{"type": "post", "text": i})
+	}
+	written, err := bp.PublishBatch(batch)
+	r.NoError(err)
+	r.Len(written, 5)
+	r.EqualValues(4, rl.Seq())
+
+	// the batch forms a chain
+	for i, ref := range written {
+		v, err := rl.Get(int64(i))
+		r.NoError(err)
+		msg := v.(refs.Message)
+		a.True(msg.Key().Equal(ref), "msg:%d - key", i)
+		a.EqualValues(i+1, msg.Seq(), "msg:%d - sequence", i)
+		if i == 0 {
+			a.Nil(msg.Previous(), "msg:%d - expected nil previous", i)
+		} else {
+			a.True(msg.Previous().Equal(written[i-1]), "msg:%d - previous", i)
+		}
+	}
+
+	// the next batch continues the feed, once the author index has the first one
+	r.Eventually(func() bool { return w.Seq() == 4 }, time.Second, 10*time.Millisecond)
+	more, err := bp.PublishBatch(batch[:1])
+	r.NoError(err)
+	r.Len(more, 1)
+	v, err := rl.Get(5)
+	r.NoError(err)
+	a.EqualValues(6, v.(refs.Message).Seq())
+	a.True(v.(refs.Message).Previous().Equal(written[4]))
+
+	cancel()
+	r.NoError(<-errc, "serveLog failed")
+}
+
+// failingAppendLog fails to append the entry at failAt, once. beforeFail is called before it does.
+type failingAppendLog struct {
+	margaret.Log
+
+	failAt     int64
+	beforeFail func()
+}
+
+var errAppendFailed = errors.New("append failed")
+
+func (fl *failingAppendLog) Append(v interface{}) (int64, error) {
+	if fl.Log.Seq()+1 == fl.failAt {
+		fl.failAt = -1
+		if fl.beforeFail != nil {
+			fl.beforeFail()
+		}
+		return -2, errAppendFailed
+	}
+	return fl.Log.Append(v)
+}
+
+func (fl *failingAppendLog) Null(seq int64) error {
+	return fl.Log.(margaret.Alterer).Null(seq)
+}
+
+func (fl *failingAppendLog) Replace(seq int64, data []byte) error {
+	return fl.Log.(margaret.Alterer).Replace(seq, data)
+}
+
+func TestPublishBatchRollback(t *testing.T) {
+	r := require.New(t)
+
+	rpath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(rpath)
+
+	testRepo := repo.New(rpath)
+	rl, err := repo.OpenLog(testRepo)
+	r.NoError(err, "failed to open root log")
+	t.Cleanup(func() {
+		rl.Close()
+	})
+
+	userFeeds, userFeedsSnk, err := repo.OpenStandaloneMultiLog(testRepo, "testUsers", multilogs.UserFeedsUpdate)
+	r.NoError(err, "failed to get user feeds multilog")
+	t.Cleanup(func() {
+		userFeeds.Close()
+		userFeedsSnk.Close()
+	})
+
+	killServe, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	errc := asynctesting.ServeLog(killServe, t.Name(), rl, userFeedsSnk, true)
+
+	testAuthor, err := ssb.NewKeyPair(rand.New(rand.NewSource(42)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	authorLog, err := userFeeds.Get(storedrefs.Feed(testAuthor.ID()))
+	r.NoError(err)
+
+	var published []int64
+	failing := &failingAppendLog{
+		Log:    rl,
+		failAt: 2,
+		// the author index points at the entries that are rolled back
+		beforeFail: func() {
+			r.Eventually(func() bool { return authorLog.Seq() == 1 }, time.Second, 10*time.Millisecond)
+		},
+	}
+	w, err := OpenPublishLog(failing, userFeeds, testAuthor,
+		UsePublishedCallback(func(rxSeq int64) { published = append(published, rxSeq) }))
+	r.NoError(err)
+
+	var batch []interface{}
+	for i := 0; i < 5; i++ {
+		batch = append(batch, map[string]interface{}{"type": "post", "text": i})
+	}
+	written, err := w.(ssb.BatchPublisher).PublishBatch(batch)
+	r.ErrorIs(err, errAppendFailed)
+	r.Nil(written)
+	r.Empty(published, "a failed batch shouldn't be announced")
+
+	// the entries before the failed one are rolled back
+	r.EqualValues(1, rl.Seq())
+	for seq := int64(0); seq <= 1; seq++ {
+		_, err := rl.Get(seq)
+		r.True(margaret.IsErrNulled(err), "entry %d should be nulled: %v", seq, err)
+	}
+
+	// the feed starts over, as if the batch never happened
+	msg, err := w.Publish(map[string]interface{}{"type": "post", "text": "after"})
+	r.NoError(err)
+	r.EqualValues(1, msg.Seq())
+	r.Nil(msg.Previous())
+	r.Equal([]int64{2}, published)
+
+	r.Eventually(func() bool { return authorLog.Seq() == 2 }, time.Second, 10*time.Millisecond)
+	next, err := w.Publish(map[string]interface{}{"type": "post", "text": "next"})
+	r.NoError(err)
+	r.EqualValues(2, next.Seq())
+	r.True(next.Previous().Equal(msg.Key()))
+
+	cancel()
+	r.NoError(<-errc, "serveLog failed")
+}
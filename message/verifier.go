@@ -14,10 +14,16 @@ import (
 	"github.com/ssbc/margaret/multilog"
 )
 
-// NewVerificationRouter supplies a unique drain per author that skip duplicate messages
-func NewVerificationRouter(rxlog margaret.Log, feeds multilog.MultiLog, hmacSec *[32]byte) (*VerificationRouter, error) {
+// NewVerificationRouter supplies a unique drain per author that skip duplicate messages.
+// The verifier for each author is picked from formats by the algorithm of the feed reference.
+// If formats is nil, only the builtin formats are supported.
+func NewVerificationRouter(rxlog margaret.Log, feeds multilog.MultiLog, hmacSec *[32]byte, formats *FeedFormats) (*VerificationRouter, error) {
+	if formats == nil {
+		formats = builtinFormats
+	}
 	return &VerificationRouter{
 		hmacSec: hmacSec,
+		formats: formats,
 
 		rxlog: rxlog,
 		feeds: feeds,
@@ -48,6 +54,7 @@ type VerificationRouter struct {
 	saver SaveMessager
 
 	hmacSec *[32]byte
+	formats *FeedFormats
 
 	mu    *sync.Mutex
 	sinks verifyFanIn
@@ -71,7 +78,7 @@ func (vs *VerificationRouter) GetSink(ref refs.FeedRef, complete bool) (Sequence
 		return nil, err
 	}
 
-	snk, err = NewVerifySink(ref, msg, vs.saver, vs.hmacSec)
+	snk, err = newVerifySink(vs.formats, ref, msg, vs.saver, vs.hmacSec)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	gabbygrove "github.com/ssbc/go-gabbygrove"
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-metafeed"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+
+	"github.com/ssbc/go-ssb/message/legacy"
+	"github.com/ssbc/go-ssb/message/multimsg"
+)
+
+// FeedBreak is the reason why VerifyFeed stopped
+type FeedBreak uint
+
+const (
+	_ FeedBreak = iota
+
+	// FeedBreakMissing means a message of the feed is not stored (for instance because it was deleted)
+	FeedBreakMissing
+
+	// FeedBreakSignature means the signature of the message is invalid or the message couldn't be decoded
+	FeedBreakSignature
+
+	// FeedBreakKey means the stored key of the message is not the hash of its content
+	FeedBreakKey
+
+	// FeedBreakAuthor means the message belongs to another feed
+	FeedBreakAuthor
+
+	// FeedBreakSequence means the sequence of the message doesn't follow the one of the message before it
+	FeedBreakSequence
+
+	// FeedBreakPrevious means the previous field of the message doesn't point to the message before it
+	FeedBreakPrevious
+)
+
+func (fb FeedBreak) String() string {
+	switch fb {
+	case FeedBreakMissing:
+		return "missing message"
+	case FeedBreakSignature:
+		return "invalid signature"
+	case FeedBreakKey:
+		return "wrong key"
+	case FeedBreakAuthor:
+		return "wrong author"
+	case FeedBreakSequence:
+		return "wrong sequence"
+	case FeedBreakPrevious:
+		return "broken previous link"
+	default:
+		return fmt.Sprintf("FeedBreak(%d)", uint(fb))
+	}
+}
+
+// ErrFeedBroken is returned by VerifyFeed for the first message that doesn't continue the feed correctly
+type ErrFeedBroken struct {
+	Feed refs.FeedRef
+
+	// Seq is the sequence the message should have had
+	Seq int64
+
+	Reason FeedBreak
+	Err    error
+}
+
+func (e ErrFeedBroken) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("message: feed %s is broken at %d: %s", e.Feed.ShortSigil(), e.Seq, e.Reason)
+	}
+	return fmt.Sprintf("message: feed %s is broken at %d: %s: %s", e.Feed.ShortSigil(), e.Seq, e.Reason, e.Err)
+}
+
+func (e ErrFeedBroken) Unwrap() error { return e.Err }
+
+// IsFeedBroken returns the break if err is an ErrFeedBroken
+func IsFeedBroken(err error) (ErrFeedBroken, bool) {
+	var broken ErrFeedBroken
+	ok := errors.As(err, &broken)
+	return broken, ok
+}
+
+// VerifyFeed goes through the messages of feed in log, which has to hold them in order, like the sublog of the feed.
+// It checks the signature of each message again and that its previous field points to the message before it.
+// It stops at the first break and returns the sequence of the last good message together with an ErrFeedBroken.
+// If the whole feed is fine, lastGoodSeq is its length. The signatures are checked without an HMAC key.
+func VerifyFeed(log margaret.Log, feed refs.FeedRef) (lastGoodSeq int64, err error) {
+	return builtinFormats.VerifyFeed(log, feed, nil)
+}
+
+// VerifyFeed is like the VerifyFeed function but uses the formats of the registry and checks the signatures with hmacKey
+func (ff *FeedFormats) VerifyFeed(log margaret.Log, feed refs.FeedRef, hmacKey *[32]byte) (int64, error) {
+	format, has := ff.Get(feed.Algo())
+	if !has {
+		return 0, fmt.Errorf("message: unsupported feed algorithm %s", feed.Algo())
+	}
+	verifier := format.NewVerifier(hmacKey)
+
+	src, err := log.Query()
+	if err != nil {
+		return 0, fmt.Errorf("message: failed to query feed %s: %w", feed.ShortSigil(), err)
+	}
+
+	var (
+		ctx      = context.Background()
+		lastGood int64
+		lastKey  refs.MessageRef
+	)
+
+	broken := func(reason FeedBreak, err error) (int64, error) {
+		return lastGood, ErrFeedBroken{
+			Feed:   feed,
+			Seq:    lastGood + 1,
+			Reason: reason,
+			Err:    err,
+		}
+	}
+
+	for {
+		v, err := src.Next(ctx)
+		if err != nil {
+			if luigi.IsEOS(err) {
+				return lastGood, nil
+			}
+			return lastGood, fmt.Errorf("message: failed to read feed %s: %w", feed.ShortSigil(), err)
+		}
+
+		if errv, ok := v.(error); ok {
+			if margaret.IsErrNulled(errv) {
+				return broken(FeedBreakMissing, nil)
+			}
+			return lastGood, fmt.Errorf("message: failed to read feed %s: %w", feed.ShortSigil(), errv)
+		}
+
+		stored, ok := v.(refs.Message)
+		if !ok {
+			return lastGood, fmt.Errorf("message: unexpected value in feed %s: %T", feed.ShortSigil(), v)
+		}
+
+		signed, err := signedBytes(stored)
+		if err != nil {
+			return lastGood, err
+		}
+
+		msg, err := verifier.Verify(signed)
+		if err != nil {
+			return broken(FeedBreakSignature, err)
+		}
+
+		if !msg.Key().Equal(stored.Key()) {
+			return broken(FeedBreakKey, fmt.Errorf("stored as %s but hashes to %s", stored.Key().ShortSigil(), msg.Key().ShortSigil()))
+		}
+
+		if !msg.Author().Equal(feed) {
+			return broken(FeedBreakAuthor, fmt.Errorf("authored by %s", msg.Author().ShortSigil()))
+		}
+
+		if msg.Seq() != lastGood+1 {
+			return broken(FeedBreakSequence, fmt.Errorf("got %d", msg.Seq()))
+		}
+
+		prev := msg.Previous()
+		if lastGood == 0 {
+			if prev != nil {
+				return broken(FeedBreakPrevious, fmt.Errorf("first message points to %s", prev.ShortSigil()))
+			}
+		} else {
+			if prev == nil {
+				return broken(FeedBreakPrevious, fmt.Errorf("expected %s got nil", lastKey.ShortSigil()))
+			}
+			if !prev.Equal(lastKey) {
+				return broken(FeedBreakPrevious, fmt.Errorf("expected %s got %s", lastKey.ShortSigil(), prev.ShortSigil()))
+			}
+		}
+
+		lastGood = msg.Seq()
+		lastKey = msg.Key()
+	}
+}
+
+// signedBytes returns the encoded message as it was signed by its author
+func signedBytes(msg refs.Message) ([]byte, error) {
+	switch tv := msg.(type) {
+	case multimsg.MultiMessage:
+		return signedBytesMulti(&tv)
+	case *multimsg.MultiMessage:
+		return signedBytesMulti(tv)
+	case *legacy.StoredMessage:
+		return tv.Raw_, nil
+	case *gabbygrove.Transfer:
+		return tv.MarshalCBOR()
+	case *metafeed.Message:
+		return tv.MarshalBencode()
+	default:
+		return nil, fmt.Errorf("message: unsupported message type: %T", msg)
+	}
+}
+
+func signedBytesMulti(mm *multimsg.MultiMessage) ([]byte, error) {
+	if msg, ok := mm.AsLegacy(); ok {
+		return signedBytes(msg)
+	}
+	if msg, ok := mm.AsGabby(); ok {
+		return signedBytes(msg)
+	}
+	if msg, ok := mm.AsMetaFeed(); ok {
+		return signedBytes(msg)
+	}
+	return nil, fmt.Errorf("message: unsupported format of %s", mm.Key().ShortSigil())
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ssbc/margaret/mem"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/message/legacy"
+	"github.com/ssbc/go-ssb/message/multimsg"
+)
+
+func TestVerifyFeed(t *testing.T) {
+	r := require.New(t)
+
+	staticRand := rand.New(rand.NewSource(42))
+	alice, err := ssb.NewKeyPair(staticRand, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	bob, err := ssb.NewKeyPair(staticRand, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	create := func(kp ssb.KeyPair, prev refs.MessageRef, seq int64) refs.Message {
+		c, err := legacyFormat{}.NewCreator(kp, nil, true)
+		r.NoError(err)
+		msg, err := c.Create(refs.NewPost("hello"), prev, seq)
+		r.NoError(err)
+		return msg
+	}
+
+	// a correct feed of three messages
+	var good []refs.Message
+	var prev refs.MessageRef
+	for seq := int64(1); seq <= 3; seq++ {
+		msg := create(alice, prev, seq)
+		good = append(good, msg)
+		prev = msg.Key()
+	}
+
+	verify := func(msgs ...refs.Message) (int64, error) {
+		log := mem.New()
+		for _, msg := range msgs {
+			_, err := log.Append(msg)
+			r.NoError(err)
+		}
+		return VerifyFeed(log, alice.ID())
+	}
+
+	last, err := verify()
+	r.NoError(err)
+	r.EqualValues(0, last)
+
+	last, err = verify(good...)
+	r.NoError(err)
+	r.EqualValues(3, last)
+
+	// the messages of the receive log are wrapped
+	wrapped := make([]refs.Message, len(good))
+	for i, msg := range good {
+		wrapped[i] = multimsg.NewMultiMessageFromLegacy(msg.(*legacy.StoredMessage))
+	}
+	last, err = verify(wrapped...)
+	r.NoError(err)
+	r.EqualValues(3, last)
+
+	tampered := *good[1].(*legacy.StoredMessage)
+	tampered.Raw_ = bytes.Replace(tampered.Raw_, []byte("hello"), []byte("h3llo"), 1)
+
+	wrongKey := *good[1].(*legacy.StoredMessage)
+	wrongKey.Key_ = good[0].(*legacy.StoredMessage).Key_
+
+	for _, tc := range []struct {
+		name   string
+		msgs   []refs.Message
+		last   int64
+		reason FeedBreak
+	}{
+		{"signature", []refs.Message{good[0], &tampered, good[2]}, 1, FeedBreakSignature},
+		{"key", []refs.Message{good[0], &wrongKey, good[2]}, 1, FeedBreakKey},
+		{"author", []refs.Message{good[0], create(bob, good[0].Key(), 2)}, 1, FeedBreakAuthor},
+		{"sequence", []refs.Message{good[0], good[2]}, 1, FeedBreakSequence},
+		{"first", []refs.Message{good[1]}, 0, FeedBreakSequence},
+		{"previous", []refs.Message{good[0], good[1], create(alice, good[0].Key(), 3)}, 2, FeedBreakPrevious},
+	} {
+		last, err := verify(tc.msgs...)
+		r.Error(err, tc.name)
+		r.Equal(tc.last, last, tc.name)
+
+		broken, ok := IsFeedBroken(err)
+		r.True(ok, "%s: %s", tc.name, err)
+		r.Equal(tc.reason, broken.Reason, "%s: %s", tc.name, err)
+		r.Equal(tc.last+1, broken.Seq, tc.name)
+		r.True(broken.Feed.Equal(alice.ID()), tc.name)
+	}
+}
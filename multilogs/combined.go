@@ -17,6 +17,7 @@ import (
 	"sync"
 
 	"github.com/dgraph-io/sroar"
+	"github.com/go-kit/kit/metrics"
 	"github.com/keks/persist"
 	"github.com/ssbc/margaret"
 	"github.com/ssbc/margaret/indexes"
@@ -97,10 +98,45 @@ type CombinedIndex struct {
 
 	ebtState *statematrix.StateMatrix
 
+	unboxCounter metrics.Counter
+	onUnboxErr   UnboxErrorFunc
+
 	file *os.File
 	l    *sync.Mutex
 }
 
+// UnboxErrorFunc is called with a boxed message that couldn't be decrypted and the reason why.
+type UnboxErrorFunc func(msg refs.Message, err error)
+
+// SetUnboxObserver counts attempted and successful decryptions on ctr
+// and calls onErr for boxed messages that we published ourselves but can't decrypt.
+// Those should be readable unless we left ourselves out of the recipients,
+// so failing on them usually means a key went missing.
+// Both arguments can be nil.
+func (idx *CombinedIndex) SetUnboxObserver(ctr metrics.Counter, onErr UnboxErrorFunc) {
+	idx.l.Lock()
+	defer idx.l.Unlock()
+	idx.unboxCounter = ctr
+	idx.onUnboxErr = onErr
+}
+
+func (idx *CombinedIndex) countUnbox(event string) {
+	if idx.unboxCounter != nil {
+		idx.unboxCounter.With("event", event).Add(1)
+	}
+}
+
+// unboxFailed records that msg couldn't be decrypted and returns errSkip
+func (idx *CombinedIndex) unboxFailed(msg refs.Message, boxType string, err error) error {
+	if msg.Author().Equal(idx.self) {
+		idx.countUnbox("unbox-own-failed")
+		if idx.onUnboxErr != nil {
+			idx.onUnboxErr(msg, fmt.Errorf("combined/private: failed to decrypt own %s message: %w", boxType, err))
+		}
+	}
+	return errSkip
+}
+
 // Box2Reindex takes advantage of the other bitmap indexes to reindex just the messages from the passed author that are box2 but not yet readable by us.
 //	1) taking private:meta:box2
 //	3) ANDing it with the one of the author (intersection)
@@ -394,10 +430,12 @@ func (idx *CombinedIndex) tryDecrypt(msg refs.Message, rxSeq int64) ([]byte, err
 
 	// try decrypt and pass on the clear text
 	if box1 != nil {
+		idx.countUnbox("unbox-box1-attempted")
 		content, err := idx.boxer.DecryptBox1(box1)
 		if err != nil {
-			return nil, errSkip
+			return nil, idx.unboxFailed(msg, "box1", err)
 		}
+		idx.countUnbox("unbox-box1-success")
 
 		idxAddr = indexes.Addr("box1:") + storedrefs.Feed(idx.self)
 		cleartext = content
@@ -406,10 +444,12 @@ func (idx *CombinedIndex) tryDecrypt(msg refs.Message, rxSeq int64) ([]byte, err
 		if p := msg.Previous(); p != nil {
 			prev = *p
 		}
+		idx.countUnbox("unbox-box2-attempted")
 		content, err := idx.boxer.DecryptBox2(box2, msg.Author(), prev)
 		if err != nil {
-			return nil, errSkip
+			return nil, idx.unboxFailed(msg, "box2", err)
 		}
+		idx.countUnbox("unbox-box2-success")
 
 		// instead by group root? could be PM... hmm
 		// would be nice to keep multi-keypair support here
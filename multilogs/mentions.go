@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package multilogs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	librarian "github.com/ssbc/margaret/indexes"
+	"github.com/ssbc/margaret/multilog"
+)
+
+const IndexNameMentions = "mentions"
+
+// MentionAddr returns the address of the sublog for messages that mention ref
+func MentionAddr(ref refs.Ref) librarian.Addr {
+	return librarian.Addr("mention:" + ref.Sigil())
+}
+
+// MentionedRefs returns the valid references in the mentions field of content.
+// Entries can either be objects with a link field or plain reference strings.
+func MentionedRefs(content []byte) []refs.Ref {
+	var msg struct {
+		Mentions json.RawMessage `json:"mentions"`
+	}
+	if err := json.Unmarshal(content, &msg); err != nil || len(msg.Mentions) == 0 {
+		return nil
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(msg.Mentions, &entries); err != nil {
+		// some clients publish a single mention without the array around it
+		entries = []json.RawMessage{msg.Mentions}
+	}
+
+	var mentioned []refs.Ref
+	for _, e := range entries {
+		var link string
+		if err := json.Unmarshal(e, &link); err != nil {
+			var obj struct {
+				Link string `json:"link"`
+			}
+			if err := json.Unmarshal(e, &obj); err != nil {
+				continue
+			}
+			link = obj.Link
+		}
+
+		ref, err := refs.ParseRef(link)
+		if err != nil || ref == nil {
+			continue
+		}
+		mentioned = append(mentioned, ref)
+	}
+	return mentioned
+}
+
+// MentionsUpdate adds the receive log sequence of public messages to the sublogs of the references they mention.
+// Private messages are not indexed.
+func MentionsUpdate(ctx context.Context, seq int64, value interface{}, mlog multilog.MultiLog) error {
+	if nulled, ok := value.(error); ok {
+		if margaret.IsErrNulled(nulled) {
+			return nil
+		}
+		return nulled
+	}
+
+	msg, ok := value.(refs.Message)
+	if !ok {
+		return fmt.Errorf("error casting message. got type %T", value)
+	}
+
+	for _, ref := range MentionedRefs(msg.ContentBytes()) {
+		mentionLog, err := mlog.Get(MentionAddr(ref))
+		if err != nil {
+			return fmt.Errorf("error opening sublog: %w", err)
+		}
+
+		if _, err := mentionLog.Append(seq); err != nil {
+			return fmt.Errorf("error appending to sublog: %w", err)
+		}
+	}
+	return nil
+}
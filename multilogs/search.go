@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package multilogs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	librarian "github.com/ssbc/margaret/indexes"
+	"github.com/ssbc/margaret/multilog"
+)
+
+const IndexNameSearch = "search"
+
+// SearchTermAddr returns the address of the sublog for messages that contain term
+func SearchTermAddr(term string) librarian.Addr {
+	return librarian.Addr("term:" + term)
+}
+
+// SearchTerms splits text into the lowercased words that are used as keys of the search index.
+// Every term is only returned once and single characters are dropped.
+func SearchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := make(map[string]struct{}, len(words))
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if len([]rune(w)) < 2 {
+			continue
+		}
+		if _, has := seen[w]; has {
+			continue
+		}
+		seen[w] = struct{}{}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// SearchUpdate adds the receive log sequence of public post messages to the sublogs of the terms in their text.
+// Private messages are not indexed.
+func SearchUpdate(ctx context.Context, seq int64, value interface{}, mlog multilog.MultiLog) error {
+	if nulled, ok := value.(error); ok {
+		if margaret.IsErrNulled(nulled) {
+			return nil
+		}
+		return nulled
+	}
+
+	msg, ok := value.(refs.Message)
+	if !ok {
+		return fmt.Errorf("error casting message. got type %T", value)
+	}
+
+	var post struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(msg.ContentBytes(), &post); err != nil || post.Type != "post" {
+		return nil
+	}
+
+	for _, term := range SearchTerms(post.Text) {
+		termLog, err := mlog.Get(SearchTermAddr(term))
+		if err != nil {
+			return fmt.Errorf("error opening sublog: %w", err)
+		}
+
+		if _, err := termLog.Append(seq); err != nil {
+			return fmt.Errorf("error appending to sublog: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package network
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-netwrap"
+)
+
+// ConnLimits caps the number of inbound connections per remote IP and per remote public key.
+// A limit applies to both, the number of concurrent connections and the attempts within Window.
+// Zero disables the respective limit.
+type ConnLimits struct {
+	PerIP  int
+	PerKey int
+
+	Window time.Duration
+}
+
+// ErrConnLimited is returned by the listener if a connection was rejected because of the ConnLimits
+var ErrConnLimited = errors.New("network: connection limit reached")
+
+type connCounter struct {
+	active   int
+	attempts []time.Time
+}
+
+type connLimiter struct {
+	limits ConnLimits
+
+	mu   sync.Mutex
+	ips  map[string]*connCounter
+	keys map[string]*connCounter
+
+	lastPrune time.Time
+	nowFn     func() time.Time
+}
+
+func newConnLimiter(l ConnLimits) *connLimiter {
+	return &connLimiter{
+		limits: l,
+		ips:    make(map[string]*connCounter),
+		keys:   make(map[string]*connCounter),
+		nowFn:  time.Now,
+	}
+}
+
+func (cl *connLimiter) counter(counters map[string]*connCounter, who string) *connCounter {
+	c, has := counters[who]
+	if !has {
+		c = &connCounter{}
+		counters[who] = c
+	}
+	return c
+}
+
+// attempt records a connection attempt and reports if it is within the limit of the window
+func (cl *connLimiter) attempt(counters map[string]*connCounter, who string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	now := cl.nowFn()
+	cutoff := now.Add(-cl.limits.Window)
+	if cl.lastPrune.Before(cutoff) {
+		cl.prune(cutoff)
+		cl.lastPrune = now
+	}
+
+	c := cl.counter(counters, who)
+
+	// drop the attempts that left the window
+	i := 0
+	for i < len(c.attempts) && !c.attempts[i].After(cutoff) {
+		i++
+	}
+	c.attempts = c.attempts[i:]
+
+	if len(c.attempts) >= limit {
+		return false
+	}
+	c.attempts = append(c.attempts, now)
+	return true
+}
+
+// acquire takes one of the concurrent connection slots of who
+func (cl *connLimiter) acquire(counters map[string]*connCounter, who string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	c := cl.counter(counters, who)
+	if c.active >= limit {
+		return false
+	}
+	c.active++
+	return true
+}
+
+// prune forgets the remotes without active connections and attempts after cutoff
+func (cl *connLimiter) prune(cutoff time.Time) {
+	for _, counters := range []map[string]*connCounter{cl.ips, cl.keys} {
+		for who, c := range counters {
+			if c.active > 0 {
+				continue
+			}
+			if n := len(c.attempts); n > 0 && c.attempts[n-1].After(cutoff) {
+				continue
+			}
+			delete(counters, who)
+		}
+	}
+}
+
+func (cl *connLimiter) release(counters map[string]*connCounter, who string, limit int) {
+	if limit <= 0 {
+		return
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	c, has := counters[who]
+	if !has {
+		return
+	}
+	if c.active > 0 {
+		c.active--
+	}
+	if c.active == 0 && len(c.attempts) == 0 {
+		delete(counters, who)
+	}
+}
+
+// ipWrapper is applied to accepted connections before the secret-handshake, so that floods are dropped early
+func (cl *connLimiter) ipWrapper(onLimit func(kind string)) netwrap.ConnWrapper {
+	return func(conn net.Conn) (net.Conn, error) {
+		if !cl.attempt(cl.ips, remoteIP(conn.RemoteAddr()), cl.limits.PerIP) {
+			onLimit("ip")
+			return nil, ErrConnLimited
+		}
+		return conn, nil
+	}
+}
+
+// accept is called after the handshake, once the public key of the remote is known.
+// If the connection is within the limits, the returned function has to be called when it is closed.
+// Otherwise kind says which limit was reached.
+func (cl *connLimiter) accept(ip, key string) (release func(), kind string) {
+	if !cl.attempt(cl.keys, key, cl.limits.PerKey) {
+		return nil, "key"
+	}
+
+	if !cl.acquire(cl.ips, ip, cl.limits.PerIP) {
+		return nil, "ip"
+	}
+
+	if !cl.acquire(cl.keys, key, cl.limits.PerKey) {
+		cl.release(cl.ips, ip, cl.limits.PerIP)
+		return nil, "key"
+	}
+
+	return func() {
+		cl.release(cl.ips, ip, cl.limits.PerIP)
+		cl.release(cl.keys, key, cl.limits.PerKey)
+	}, ""
+}
+
+func remoteIP(addr net.Addr) string {
+	if tcpAddr, ok := netwrap.GetAddr(addr, "tcp").(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnLimiter(t *testing.T) {
+	r := require.New(t)
+
+	cl := newConnLimiter(ConnLimits{PerIP: 2, PerKey: 1, Window: time.Minute})
+	now := time.Unix(1000, 0)
+	cl.nowFn = func() time.Time { return now }
+
+	var limited []string
+	wrap := cl.ipWrapper(func(kind string) { limited = append(limited, kind) })
+
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+	other := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4242}
+
+	// two attempts per ip within the window
+	for i := 0; i < 2; i++ {
+		_, err := wrap(fakeConn{remote: remote})
+		r.NoError(err, "attempt %d", i)
+	}
+	_, err := wrap(fakeConn{remote: remote})
+	r.ErrorIs(err, ErrConnLimited)
+	r.Equal([]string{"ip"}, limited)
+
+	_, err = wrap(fakeConn{remote: other})
+	r.NoError(err, "other ip should not be limited")
+
+	// one concurrent connection per key
+	release, kind := cl.accept("10.0.0.1", "@alice")
+	r.NotNil(release)
+	r.Equal("", kind)
+
+	_, kind = cl.accept("10.0.0.2", "@alice")
+	r.Equal("key", kind)
+
+	// the key is also limited after the connection closed, until the window passed
+	release()
+	_, kind = cl.accept("10.0.0.1", "@alice")
+	r.Equal("key", kind)
+
+	now = now.Add(2 * time.Minute)
+	release, kind = cl.accept("10.0.0.1", "@alice")
+	r.NotNil(release, "limited by %s", kind)
+	release()
+
+	_, err = wrap(fakeConn{remote: remote})
+	r.NoError(err, "window should have passed")
+
+	// concurrent connections per ip
+	rel1, _ := cl.accept("10.0.0.3", "@bob")
+	r.NotNil(rel1)
+	rel2, _ := cl.accept("10.0.0.3", "@carol")
+	r.NotNil(rel2)
+	_, kind = cl.accept("10.0.0.3", "@dave")
+	r.Equal("ip", kind)
+	rel1()
+	rel3, _ := cl.accept("10.0.0.3", "@erin")
+	r.NotNil(rel3)
+	rel2()
+	rel3()
+
+	// idle remotes are forgotten
+	now = now.Add(2 * time.Minute)
+	_, err = wrap(fakeConn{remote: other})
+	r.NoError(err)
+	r.Len(cl.ips, 1)
+	r.Len(cl.keys, 0)
+}
+
+type fakeConn struct {
+	net.Conn
+
+	remote net.Addr
+}
+
+func (fc fakeConn) RemoteAddr() net.Addr { return fc.remote }
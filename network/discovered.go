@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-netwrap"
+	"github.com/ssbc/go-secretstream"
+	multiserver "github.com/ssbc/go-ssb-multiserver"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// discoveredPeerTTL is how long a peer is kept after its last advertisement.
+// Advertisers send one every 15 seconds, so this leaves room for a couple of lost packets.
+const discoveredPeerTTL = 2 * time.Minute
+
+// DiscoveredPeer is a peer that advertised itself on the local network.
+// It is also the reply of conn.discovered, there Addr is encoded as a multiserver address.
+type DiscoveredPeer struct {
+	ID       refs.FeedRef
+	Addr     net.Addr
+	LastSeen time.Time
+}
+
+// Address returns the multiserver address of the peer, which can be passed to conn.connect
+func (p DiscoveredPeer) Address() string {
+	if tcpAddr, ok := netwrap.GetAddr(p.Addr, "tcp").(*net.TCPAddr); ok {
+		return multiserver.NetAddress{Addr: *tcpAddr, Ref: p.ID}.String()
+	}
+	if p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+type discoveredPeerJSON struct {
+	ID       refs.FeedRef `json:"id"`
+	Address  string       `json:"address"`
+	LastSeen time.Time    `json:"lastSeen"`
+}
+
+func (p DiscoveredPeer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(discoveredPeerJSON{
+		ID:       p.ID,
+		Address:  p.Address(),
+		LastSeen: p.LastSeen,
+	})
+}
+
+func (p *DiscoveredPeer) UnmarshalJSON(input []byte) error {
+	var dp discoveredPeerJSON
+	if err := json.Unmarshal(input, &dp); err != nil {
+		return err
+	}
+
+	na, err := multiserver.ParseNetAddress([]byte(dp.Address))
+	if err != nil {
+		return fmt.Errorf("discovered peer: invalid address %q: %w", dp.Address, err)
+	}
+
+	p.ID = dp.ID
+	p.Addr = netwrap.WrapAddr(&na.Addr, secretstream.Addr{PubKey: na.Ref.PubKey()})
+	p.LastSeen = dp.LastSeen
+	return nil
+}
+
+// discoveredPeers remembers the peers that advertised themselves on the local network, one entry per key
+type discoveredPeers struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	peers map[string]DiscoveredPeer
+
+	nowFn func() time.Time
+}
+
+func newDiscoveredPeers(ttl time.Duration) *discoveredPeers {
+	return &discoveredPeers{
+		ttl:   ttl,
+		peers: make(map[string]DiscoveredPeer),
+		nowFn: time.Now,
+	}
+}
+
+// seen adds the peer or updates its address and the time it was last seen
+func (dp *discoveredPeers) seen(id refs.FeedRef, addr net.Addr) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	dp.peers[id.String()] = DiscoveredPeer{
+		ID:       id,
+		Addr:     addr,
+		LastSeen: dp.nowFn(),
+	}
+}
+
+// list drops the expired peers and returns the others, the most recently seen first
+func (dp *discoveredPeers) list() []DiscoveredPeer {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+
+	now := dp.nowFn()
+	lst := make([]DiscoveredPeer, 0, len(dp.peers))
+	for key, p := range dp.peers {
+		if now.Sub(p.LastSeen) > dp.ttl {
+			delete(dp.peers, key)
+			continue
+		}
+		lst = append(lst, p)
+	}
+
+	sort.Slice(lst, func(i, j int) bool {
+		if !lst[i].LastSeen.Equal(lst[j].LastSeen) {
+			return lst[i].LastSeen.After(lst[j].LastSeen)
+		}
+		return lst[i].ID.String() < lst[j].ID.String()
+	})
+	return lst
+}
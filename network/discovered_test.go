@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-netwrap"
+	"github.com/ssbc/go-secretstream"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveredPeers(t *testing.T) {
+	r := require.New(t)
+
+	dp := newDiscoveredPeers(time.Minute)
+	now := time.Unix(1000, 0)
+	dp.nowFn = func() time.Time { return now }
+
+	alice, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	bob, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	addr := func(last byte) net.Addr {
+		return &net.TCPAddr{IP: net.IPv4(192, 168, 1, last), Port: DefaultPort}
+	}
+
+	r.Len(dp.list(), 0)
+
+	dp.seen(alice, addr(10))
+	now = now.Add(10 * time.Second)
+	dp.seen(bob, addr(20))
+
+	lst := dp.list()
+	r.Len(lst, 2)
+	r.True(lst[0].ID.Equal(bob), "most recently seen first")
+	r.True(lst[1].ID.Equal(alice))
+
+	// seeing alice again updates her entry instead of adding another one
+	now = now.Add(10 * time.Second)
+	dp.seen(alice, addr(11))
+	lst = dp.list()
+	r.Len(lst, 2)
+	r.True(lst[0].ID.Equal(alice))
+	r.Equal(addr(11).String(), lst[0].Addr.String(), "should have the latest address")
+	r.Equal(now, lst[0].LastSeen)
+
+	// bob expires first
+	now = now.Add(55 * time.Second)
+	lst = dp.list()
+	r.Len(lst, 1)
+	r.True(lst[0].ID.Equal(alice))
+
+	now = now.Add(10 * time.Second)
+	r.Len(dp.list(), 0)
+}
+
+func TestDiscoveredPeerJSON(t *testing.T) {
+	r := require.New(t)
+
+	alice, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	tcpAddr := &net.TCPAddr{IP: net.IPv4(192, 168, 1, 10), Port: DefaultPort}
+	peer := DiscoveredPeer{
+		ID:       alice,
+		Addr:     netwrap.WrapAddr(tcpAddr, secretstream.Addr{PubKey: alice.PubKey()}),
+		LastSeen: time.Unix(1000, 0).UTC(),
+	}
+
+	encoded, err := json.Marshal(peer)
+	r.NoError(err)
+	r.Contains(string(encoded), `"address":"net:192.168.1.10:8008~shs:`)
+
+	var decoded DiscoveredPeer
+	r.NoError(json.Unmarshal(encoded, &decoded))
+	r.True(decoded.ID.Equal(alice))
+	r.Equal(peer.Address(), decoded.Address())
+	r.Equal(tcpAddr.String(), netwrap.GetAddr(decoded.Addr, "tcp").String())
+	r.True(decoded.LastSeen.Equal(peer.LastSeen))
+}
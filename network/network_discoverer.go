@@ -26,12 +26,15 @@ type Discoverer struct {
 
 	brLock    sync.Mutex
 	brodcasts map[int]chan net.Addr
+
+	peers *discoveredPeers
 }
 
 func NewDiscoverer(local ssb.KeyPair) (*Discoverer, error) {
 	d := &Discoverer{
 		local:     local,
 		brodcasts: make(map[int]chan net.Addr),
+		peers:     newDiscoveredPeers(discoveredPeerTTL),
 	}
 	return d, d.start()
 }
@@ -107,6 +110,7 @@ func (d *Discoverer) work(rx net.PacketConn) {
 		// fmt.Printf("[localadv debug] %s (claimed:%s) %s\n", addr.String(), na.Addr.String(), na.Ref.Ref())
 
 		wrappedAddr := netwrap.WrapAddr(&na.Addr, secretstream.Addr{PubKey: na.Ref.PubKey()})
+		d.peers.seen(na.Ref, wrappedAddr)
 
 		d.brLock.Lock()
 		for _, ch := range d.brodcasts {
@@ -134,6 +138,11 @@ func (d *Discoverer) Stop() {
 	return
 }
 
+// Peers returns the peers that advertised themselves recently, the most recently seen first
+func (d *Discoverer) Peers() []DiscoveredPeer {
+	return d.peers.list()
+}
+
 func (d *Discoverer) Notify() (<-chan net.Addr, func()) {
 	ch := make(chan net.Addr)
 	d.brLock.Lock()
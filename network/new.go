@@ -35,6 +35,10 @@ type Options struct {
 	Dialer     netwrap.Dialer
 	ListenAddr net.Addr
 
+	// ExtraListenAddrs are accepted on as well, through the same handshake and connection limits.
+	// Only ListenAddr is advertised.
+	ExtraListenAddrs []net.Addr
+
 	AdvertsSend      bool
 	AdvertsConnectTo bool
 
@@ -44,6 +48,9 @@ type Options struct {
 
 	ConnTracker ssb.ConnTracker
 
+	// ConnLimits restricts the inbound connections per remote IP and key, if set
+	ConnLimits *ConnLimits
+
 	// PreSecureWrappers are applied before the shs+boxstream wrapping takes place
 	// usefull for accessing the sycall.Conn to apply control options on the socket
 	BefreCryptoWrappers []netwrap.ConnWrapper
@@ -70,7 +77,7 @@ type Node struct {
 
 	listenerLock sync.Mutex
 	lisClose     sync.Once
-	lis          net.Listener
+	lis          []net.Listener // the one on ListenAddr comes first
 
 	dialer        netwrap.Dialer
 	localDiscovRx *Discoverer
@@ -78,6 +85,7 @@ type Node struct {
 	secretServer  *secretstream.Server
 	secretClient  *secretstream.Client
 	connTracker   ssb.ConnTracker
+	connLimiter   *connLimiter
 
 	beforeCryptoConnWrappers []netwrap.ConnWrapper
 	afterSecureConnWrappers  []netwrap.ConnWrapper
@@ -106,6 +114,10 @@ func New(opts Options) (*Node, error) {
 	}
 	n.connTracker = opts.ConnTracker
 
+	if opts.ConnLimits != nil {
+		n.connLimiter = newConnLimiter(*opts.ConnLimits)
+	}
+
 	var err error
 
 	if opts.Dialer != nil {
@@ -193,10 +205,27 @@ func New(opts Options) (*Node, error) {
 	return n, nil
 }
 
+// GetWebsocketAddr returns the address of the websocket listener, or nil if there is none
+func (n *Node) GetWebsocketAddr() net.Addr {
+	if n.httpLis == nil {
+		return nil
+	}
+	return n.httpLis.Addr()
+}
+
 func (n *Node) HandleHTTP(h http.Handler) {
 	n.httpHandler = h
 }
 
+// DiscoveredPeers returns the peers that were recently found through local discovery.
+// It is empty if local discovery is not enabled.
+func (n *Node) DiscoveredPeers() []DiscoveredPeer {
+	if n.localDiscovRx == nil {
+		return nil
+	}
+	return n.localDiscovRx.Peers()
+}
+
 func (n *Node) GetConnTracker() ssb.ConnTracker {
 	return n.connTracker
 }
@@ -287,6 +316,17 @@ func (n *Node) handleConnection(ctx context.Context, origConn net.Conn, isServer
 	}
 	rLogger := log.With(n.log, "peer", remoteRef.ShortSigil())
 
+	if isServer && n.connLimiter != nil {
+		release, kind := n.connLimiter.accept(remoteIP(conn.RemoteAddr()), remoteRef.String())
+		if release == nil {
+			n.countLimited(kind)
+			conn.Close()
+			level.Debug(rLogger).Log("conn", "limited", "by", kind)
+			return
+		}
+		defer release()
+	}
+
 	ok, ctx := n.connTracker.OnAccept(ctx, conn)
 	if !ok {
 		err := conn.Close()
@@ -352,16 +392,29 @@ func (n *Node) handleConnection(ctx context.Context, origConn net.Conn, isServer
 // Canceling the passed context makes the function return. Defers take care of stopping these resources.
 func (n *Node) Serve(ctx context.Context, wrappers ...muxrpc.HandlerWrapper) error {
 	evtLog := log.With(n.log, "event", "network.Serve")
-	// TODO: make multiple listeners (localhost:8008 should not restrict or kill connections)
-	lisWrap := netwrap.NewListenerWrapper(n.secretServer.Addr(), append(n.opts.BefreCryptoWrappers, n.secretServer.ConnWrapper())...)
+	var lisWrappers []netwrap.ConnWrapper
+	if n.connLimiter != nil {
+		lisWrappers = append(lisWrappers, n.connLimiter.ipWrapper(n.countLimited))
+	}
+	lisWrappers = append(lisWrappers, n.opts.BefreCryptoWrappers...)
+	lisWrap := netwrap.NewListenerWrapper(n.secretServer.Addr(), append(lisWrappers, n.secretServer.ConnWrapper())...)
 	var err error
 
 	n.listenerLock.Lock()
-	n.lis, err = netwrap.Listen(n.opts.ListenAddr, lisWrap)
-	if err != nil {
-		n.listenerLock.Unlock()
-		return fmt.Errorf("error creating listener: %w", err)
+	for _, addr := range append([]net.Addr{n.opts.ListenAddr}, n.opts.ExtraListenAddrs...) {
+		var lis net.Listener
+		lis, err = netwrap.Listen(addr, lisWrap)
+		if err != nil {
+			for _, opened := range n.lis {
+				opened.Close()
+			}
+			n.lis = nil
+			n.listenerLock.Unlock()
+			return fmt.Errorf("error creating listener on %s: %w", addr, err)
+		}
+		n.lis = append(n.lis, lis)
 	}
+	listeners := n.lis
 	n.lisClose = sync.Once{} // reset once
 	close(n.listening)
 	n.listenerLock.Unlock()
@@ -369,7 +422,9 @@ func (n *Node) Serve(ctx context.Context, wrappers ...muxrpc.HandlerWrapper) err
 	defer func() { // refresh listener to re-call
 		n.lisClose.Do(func() {
 			n.listenerLock.Lock()
-			n.lis.Close()
+			for _, lis := range n.lis {
+				lis.Close()
+			}
 			n.lis = nil
 			n.listenerLock.Unlock()
 		})
@@ -400,30 +455,38 @@ func (n *Node) Serve(ctx context.Context, wrappers ...muxrpc.HandlerWrapper) err
 		}()
 	}
 
-	// accept in a goroutine so that we can react to context cancel and close the listener
+	// accept in goroutines so that we can react to context cancel and close the listeners
 	newConn := make(chan net.Conn)
-	go func() {
-		defer close(newConn)
-		for {
-			n.listenerLock.Lock()
-			if n.lis == nil {
-				n.listenerLock.Unlock()
-				return
-			}
-			n.listenerLock.Unlock()
-			conn, err := n.lis.Accept()
-			if err != nil {
-				if strings.Contains(err.Error(), "use of closed network connection") {
-					// yikes way of handling this
-					// but means this needs to be restarted anyway
+	var accepting sync.WaitGroup
+	for _, lis := range listeners {
+		accepting.Add(1)
+		go func(lis net.Listener) {
+			defer accepting.Done()
+			for {
+				n.listenerLock.Lock()
+				if n.lis == nil {
+					n.listenerLock.Unlock()
 					return
 				}
+				n.listenerLock.Unlock()
+				conn, err := lis.Accept()
+				if err != nil {
+					if strings.Contains(err.Error(), "use of closed network connection") {
+						// yikes way of handling this
+						// but means this needs to be restarted anyway
+						return
+					}
 
-				continue
-			}
+					continue
+				}
 
-			newConn <- conn
-		}
+				newConn <- conn
+			}
+		}(lis)
+	}
+	go func() {
+		accepting.Wait()
+		close(newConn)
 	}()
 
 	defer level.Debug(n.log).Log("event", "network listen loop exited")
@@ -440,12 +503,25 @@ func (n *Node) Serve(ctx context.Context, wrappers ...muxrpc.HandlerWrapper) err
 	}
 }
 
+// countLimited emits the metric for connections that were rejected by the connection limits
+func (n *Node) countLimited(kind string) {
+	if n.evtCtr != nil {
+		n.evtCtr.With("event", "conn-limited-"+kind).Add(1)
+	}
+}
+
+// Connect dials addr and serves the connection. Tunnel addresses, see ParseDialAddress, are dialed through their room.
 func (n *Node) Connect(ctx context.Context, addr net.Addr) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
+
+	if ta, ok := netwrap.GetAddr(addr, "ssb-tunnel").(TunnelAddr); ok {
+		return n.dialViaRoom(ctx, ta.Portal, ta.Target)
+	}
+
 	shsAddr := netwrap.GetAddr(addr, "shs-bs")
 	if shsAddr == nil {
 		return errors.New("node/connect: expected an address containing an shs-bs addr")
@@ -474,13 +550,30 @@ func (n *Node) Connect(ctx context.Context, addr net.Addr) error {
 }
 
 // GetListenAddr waits for Serve() to be called!
+// It returns the address of the listener on ListenAddr.
 func (n *Node) GetListenAddr() net.Addr {
+	addrs := n.GetListenAddrs()
+	if len(addrs) == 0 {
+		return nil
+	}
+	return addrs[0]
+}
+
+// GetListenAddrs is like GetListenAddr but returns the addresses of all the listeners,
+// the one on ListenAddr first and then those on ExtraListenAddrs.
+func (n *Node) GetListenAddrs() []net.Addr {
 	_, ok := <-n.listening
-	if !ok {
-		return n.lis.Addr()
+	if ok {
+		level.Error(n.log).Log("msg", "listener not ready")
+		return nil
 	}
-	level.Error(n.log).Log("msg", "listener not ready")
-	return nil
+	n.listenerLock.Lock()
+	defer n.listenerLock.Unlock()
+	addrs := make([]net.Addr, len(n.lis))
+	for i, lis := range n.lis {
+		addrs[i] = lis.Addr()
+	}
+	return addrs
 }
 
 func (n *Node) applyConnWrappers(conn net.Conn) (net.Conn, error) {
@@ -510,7 +603,11 @@ func (n *Node) Close() error {
 	if n.lis != nil {
 		var closeErr error
 		n.lisClose.Do(func() {
-			closeErr = n.lis.Close()
+			for _, lis := range n.lis {
+				if err := lis.Close(); err != nil && closeErr == nil {
+					closeErr = err
+				}
+			}
 		})
 		if closeErr != nil && !strings.Contains(closeErr.Error(), "use of closed network connection") {
 			return fmt.Errorf("ssb: network node failed to close it's listener: %w", closeErr)
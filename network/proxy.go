@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ssbc/go-netwrap"
+	"github.com/ssbc/go-secretstream"
+	multiserver "github.com/ssbc/go-ssb-multiserver"
+	refs "github.com/ssbc/go-ssb-refs"
+	"golang.org/x/net/proxy"
+)
+
+// ErrNoDialAddr is returned by ParseDialAddress if the input has neither a net:, an onion: nor a tunnel: address
+var ErrNoDialAddr = errors.New("network: no net~shs, onion~shs or tunnel~shs combination")
+
+// OnionAddr is the address of a tor onion service.
+// It keeps the hostname as it is, since only the proxy can resolve it.
+type OnionAddr struct {
+	Host string
+	Port int
+}
+
+// Network returns tcp, so that the address is picked up by Node.Connect like a regular one
+func (oa OnionAddr) Network() string { return "tcp" }
+
+func (oa OnionAddr) String() string { return net.JoinHostPort(oa.Host, strconv.Itoa(oa.Port)) }
+
+// ParseDialAddress parses a multiserver address with a net:, onion: or tunnel: part, like
+// onion:abcdef.onion:8008~shs:<base64 public key> or tunnel:<room>:<target>~shs:<base64 public key of target>.
+// The first of these parts is used.
+// The returned address is wrapped with the secret-handshake key and can be passed to Node.Connect.
+// Tunnel addresses are dialed through the room, which needs to be connected already.
+func ParseDialAddress(input string) (net.Addr, refs.FeedRef, error) {
+	for _, part := range strings.Split(input, ";") {
+		switch {
+		case strings.HasPrefix(part, "net:"):
+			na, err := multiserver.ParseNetAddress([]byte(part))
+			if err != nil {
+				return nil, refs.FeedRef{}, err
+			}
+			return na.WrappedAddr(), na.Ref, nil
+
+		case strings.HasPrefix(part, "onion:"):
+			return parseOnionAddress(strings.TrimPrefix(part, "onion:"))
+
+		case strings.HasPrefix(part, "tunnel:"):
+			ta, err := multiserver.ParseTunnelAddress(part)
+			if err != nil {
+				return nil, refs.FeedRef{}, err
+			}
+			addr := TunnelAddr{Portal: ta.Intermediary, Target: ta.Target}
+			return netwrap.WrapAddr(addr, secretstream.Addr{PubKey: ta.Target.PubKey()}), ta.Target, nil
+		}
+	}
+	return nil, refs.FeedRef{}, ErrNoDialAddr
+}
+
+func parseOnionAddress(part string) (net.Addr, refs.FeedRef, error) {
+	keyStart := strings.Index(part, "~shs:")
+	if keyStart == -1 {
+		return nil, refs.FeedRef{}, multiserver.ErrNoSHSKey
+	}
+
+	host, portStr, err := net.SplitHostPort(part[:keyStart])
+	if err != nil {
+		return nil, refs.FeedRef{}, fmt.Errorf("network: invalid onion host and port: %w", err)
+	}
+	if !strings.HasSuffix(host, ".onion") {
+		return nil, refs.FeedRef{}, fmt.Errorf("network: not an onion host: %q", host)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, refs.FeedRef{}, fmt.Errorf("network: invalid onion port: %w", err)
+	}
+
+	ref, err := refs.ParseFeedRef("@" + part[keyStart+5:] + ".ed25519")
+	if err != nil {
+		return nil, refs.FeedRef{}, fmt.Errorf("%w: %s", multiserver.ErrNoSHSKey, err)
+	}
+
+	oa := OnionAddr{Host: host, Port: port}
+	return netwrap.WrapAddr(oa, secretstream.Addr{PubKey: ref.PubKey()}), ref, nil
+}
+
+// NewProxyDialer returns a dialer that opens its connections through the SOCKS5 proxy at proxyURL, like socks5://127.0.0.1:9050,
+// and then applies the connection wrappers, like the secret-handshake, to the proxied stream.
+// Hostnames, like those of onion addresses, are resolved by the proxy.
+func NewProxyDialer(proxyURL string) (netwrap.Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("network: invalid proxy url: %w", err)
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("network: unsupported proxy scheme %q, only socks5 is supported", u.Scheme)
+	}
+
+	pd, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("network: failed to create proxy dialer: %w", err)
+	}
+
+	return func(addr net.Addr, wrappers ...netwrap.ConnWrapper) (net.Conn, error) {
+		origConn, err := pd.Dial(addr.Network(), addr.String())
+		if err != nil {
+			return nil, fmt.Errorf("network: failed to dial %s through proxy: %w", addr, err)
+		}
+
+		conn := origConn
+		for _, cw := range wrappers {
+			conn, err = cw(conn)
+			if err != nil {
+				origConn.Close()
+				return nil, fmt.Errorf("network: failed to wrap proxied connection: %w", err)
+			}
+		}
+		return conn, nil
+	}, nil
+}
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package network
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/ssbc/go-netwrap"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDialAddress(t *testing.T) {
+	r := require.New(t)
+
+	key := bytes.Repeat([]byte{1}, 32)
+	b64Key := base64.StdEncoding.EncodeToString(key)
+
+	addr, ref, err := ParseDialAddress("onion:abcdefghijklmnop.onion:8008~shs:" + b64Key)
+	r.NoError(err)
+	r.Equal(key, []byte(ref.PubKey()))
+	r.Equal("tcp|shs-bs", addr.Network())
+	r.Equal(OnionAddr{Host: "abcdefghijklmnop.onion", Port: 8008}, netwrap.GetAddr(addr, "tcp"))
+
+	// the first usable part wins
+	addr, _, err = ParseDialAddress("ws://example.com;net:127.0.0.1:8008~shs:" + b64Key + ";onion:abcdefghijklmnop.onion:8008~shs:" + b64Key)
+	r.NoError(err)
+	r.Equal("127.0.0.1:8008", netwrap.GetAddr(addr, "tcp").String())
+
+	room, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	tunnel := "tunnel:" + room.String() + ":@" + b64Key + ".ed25519~shs:" + b64Key
+	addr, ref, err = ParseDialAddress(tunnel)
+	r.NoError(err)
+	r.Equal(key, []byte(ref.PubKey()))
+	r.Equal("ssb-tunnel|shs-bs", addr.Network())
+	r.Equal(TunnelAddr{Portal: room, Target: ref}, netwrap.GetAddr(addr, "ssb-tunnel"))
+	r.Equal(tunnel, netwrap.GetAddr(addr, "ssb-tunnel").String())
+
+	for _, bad := range []string{
+		"",
+		"ws://example.com",
+		"onion:abcdefghijklmnop.onion:8008",
+		"onion:example.com:8008~shs:" + b64Key,
+		"onion:abcdefghijklmnop.onion:port~shs:" + b64Key,
+		"onion:abcdefghijklmnop.onion:8008~shs:short",
+		"tunnel:" + room.String() + ":@" + b64Key + ".ed25519~shs:" + base64.StdEncoding.EncodeToString(room.PubKey()),
+	} {
+		_, _, err := ParseDialAddress(bad)
+		r.Error(err, bad)
+	}
+}
+
+func TestProxyDialer(t *testing.T) {
+	r := require.New(t)
+
+	_, err := NewProxyDialer("http://127.0.0.1:8080")
+	r.Error(err, "only socks5 is supported")
+
+	// the target only echos what it receives
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	defer target.Close()
+	go func() {
+		for {
+			c, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	// the proxy connects every hostname to the target
+	socks, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	defer socks.Close()
+	requested := make(chan string, 1)
+	go func() {
+		for {
+			c, err := socks.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSOCKS5(c, target.Addr().String(), requested)
+		}
+	}()
+
+	dial, err := NewProxyDialer("socks5://" + socks.Addr().String())
+	r.NoError(err)
+
+	var wrapped bool
+	wrapper := func(c net.Conn) (net.Conn, error) {
+		wrapped = true
+		return c, nil
+	}
+
+	conn, err := dial(OnionAddr{Host: "abcdefghijklmnop.onion", Port: 8008}, wrapper)
+	r.NoError(err)
+	r.True(wrapped, "the wrappers should be applied to the proxied connection")
+	r.Equal("abcdefghijklmnop.onion:8008", <-requested, "the hostname should be resolved by the proxy")
+
+	_, err = conn.Write([]byte("hello"))
+	r.NoError(err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	r.NoError(err)
+	r.Equal("hello", string(buf))
+	r.NoError(conn.Close())
+}
+
+// serveTestSOCKS5 speaks just enough SOCKS5 for a CONNECT without authentication
+func serveTestSOCKS5(c net.Conn, target string, requested chan<- string) {
+	defer c.Close()
+
+	// greeting: version, number of methods, methods
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(c, hdr); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(c, make([]byte, hdr[1])); err != nil {
+		return
+	}
+	c.Write([]byte{5, 0})
+
+	// request: version, command, reserved, address type
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(c, req); err != nil || req[1] != 1 || req[3] != 3 {
+		return
+	}
+	n := make([]byte, 1)
+	if _, err := io.ReadFull(c, n); err != nil {
+		return
+	}
+	host := make([]byte, n[0])
+	if _, err := io.ReadFull(c, host); err != nil {
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(c, port); err != nil {
+		return
+	}
+	requested <- net.JoinHostPort(string(host), strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	out, err := net.Dial("tcp", target)
+	if err != nil {
+		c.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer out.Close()
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(out, c)
+	io.Copy(c, out)
+}
@@ -80,9 +80,7 @@ func (h connectHandler) HandleDuplex(ctx context.Context, req *muxrpc.Request, p
 	tc.Reader = muxrpc.NewSourceReader(peerSrc)
 	tc.WriteCloser = muxrpc.NewSinkWriter(peerSnk)
 	tc.local = h.network.opts.ListenAddr
-	tc.remote = tunnelHost{
-		Host: portal,
-	}
+	tc.remote = TunnelAddr{Portal: portal}
 	ctx, tc.cancel = context.WithCancel(ctx)
 
 	authWrapper := h.network.secretServer.ConnWrapper()
@@ -21,7 +21,13 @@ type connectArg struct {
 	Target refs.FeedRef `json:"target"`
 }
 
+// DialViaRoom opens a tunneled connection to target through the room portal, which needs to be connected already.
+// Like Connect with a tunnel: address but without a context.
 func (n *Node) DialViaRoom(portal, target refs.FeedRef) error {
+	return n.dialViaRoom(context.TODO(), portal, target) // TODO: get serveCtx from sbot
+}
+
+func (n *Node) dialViaRoom(ctx context.Context, portal, target refs.FeedRef) error {
 	portalLogger := kitlog.With(n.log, "portal", portal.ShortSigil())
 
 	edp, has := n.GetEndpointFor(portal)
@@ -33,8 +39,6 @@ func (n *Node) DialViaRoom(portal, target refs.FeedRef) error {
 	arg.Portal = portal
 	arg.Target = target
 
-	ctx := context.TODO() // TODO: get serveCtx from sbot
-
 	ctx, cancel := context.WithCancel(ctx)
 	r, w, err := edp.Duplex(ctx, muxrpc.TypeBinary, muxrpc.Method{"tunnel", "connect"}, arg)
 	if err != nil {
@@ -49,9 +53,7 @@ func (n *Node) DialViaRoom(portal, target refs.FeedRef) error {
 	tc.cancel = cancel
 
 	tc.local = n.opts.ListenAddr
-	tc.remote = tunnelHost{
-		Host: portal,
-	}
+	tc.remote = TunnelAddr{Portal: portal, Target: target}
 
 	authWrapper := n.secretClient.ConnWrapper(target.PubKey())
 
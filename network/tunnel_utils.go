@@ -11,23 +11,31 @@ import (
 	"net"
 	"time"
 
+	multiserver "github.com/ssbc/go-ssb-multiserver"
 	refs "github.com/ssbc/go-ssb-refs"
 )
 
-// tunnelHost is a net.Addr for a tunnel server
-type tunnelHost struct {
-	Host refs.FeedRef
+// TunnelAddr is the address of a peer that is reached through a room, the portal.
+// ParseDialAddress returns it for tunnel: addresses and it is the remote address of tunneled connections.
+// The Target of incoming tunneled connections is only known after the handshake and is left empty.
+type TunnelAddr struct {
+	Portal refs.FeedRef
+	Target refs.FeedRef
 }
 
-func (ta tunnelHost) Network() string {
+func (ta TunnelAddr) Network() string {
 	return "ssb-tunnel"
 }
 
-func (ta tunnelHost) String() string {
-	return ta.Network() + ":" + ta.Host.String()
+// String returns the multiserver tunnel address, or ssb-tunnel:<portal> if the target is unknown
+func (ta TunnelAddr) String() string {
+	if ta.Target.Algo() == "" {
+		return ta.Network() + ":" + ta.Portal.String()
+	}
+	return multiserver.TunnelAddress{Intermediary: ta.Portal, Target: ta.Target}.String()
 }
 
-var _ net.Addr = tunnelHost{}
+var _ net.Addr = TunnelAddr{}
 
 // tunnelConn wrapps a reader and writer with two hardcoded net address to behave like a net.Conn
 type tunnelConn struct {
@@ -6,6 +6,8 @@ package network
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net"
@@ -14,16 +16,26 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-netwrap"
 	"go.mindeco.de/log/level"
 )
 
+// WebsocketSubprotocol returns the Sec-WebSocket-Protocol for the network with the passed secret-handshake app key.
+// It is ssb-net- followed by the app key in unpadded, url-safe base64.
+// Clients that offer subprotocols have to offer this one, clients that don't offer any are accepted as well.
+func WebsocketSubprotocol(appKey []byte) string {
+	return "ssb-net-" + base64.RawURLEncoding.EncodeToString(appKey)
+}
+
 func websockHandler(n *Node) http.HandlerFunc {
+	subprotocol := WebsocketSubprotocol(n.opts.AppKey)
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024 * 4,
 		WriteBufferSize: 1024 * 4,
 		CheckOrigin: func(_ *http.Request) bool {
 			return true
 		},
+		Subprotocols:      []string{subprotocol},
 		EnableCompression: false,
 	}
 	return func(w http.ResponseWriter, req *http.Request) {
@@ -32,20 +44,29 @@ func websockHandler(n *Node) http.HandlerFunc {
 			n.log.Log("warning", "failed wrap", "err", err, "remote", remoteAddr)
 			return
 		}
+
+		if offered := websocket.Subprotocols(req); len(offered) > 0 && !containsString(offered, subprotocol) {
+			n.log.Log("warning", "unsupported subprotocols", "offered", fmt.Sprint(offered), "remote", remoteAddr)
+			http.Error(w, "websocket: unsupported subprotocol, expected "+subprotocol, http.StatusBadRequest)
+			return
+		}
+
 		wsConn, err := upgrader.Upgrade(w, req, nil)
 		if err != nil {
 			n.log.Log("warning", "failed wrap", "err", err, "remote", remoteAddr)
 			return
 		}
 
+		local, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		if !ok {
+			local = wsConn.LocalAddr()
+		}
+
 		var wc net.Conn
 		wc = &wrappedConn{
 			remote: remoteAddr,
-			local: &net.TCPAddr{
-				IP:   nil,
-				Port: 8989,
-			},
-			wsc: wsConn,
+			local:  local,
+			wsc:    wsConn,
 		}
 
 		// comment out this block to get `noauth` instead of `shs`
@@ -93,6 +114,61 @@ func websockHandler(n *Node) http.HandlerFunc {
 	}
 }
 
+// NewWebsocketDialer returns a dialer that connects to the websocket listener of a peer,
+// offering the WebsocketSubprotocol of appKey, and runs the connection wrappers, like the secret-handshake, over the websocket frames.
+// The tcp part of the address is the host and port of the listener. If tlsConf is not nil, it dials wss:// instead of ws://.
+func NewWebsocketDialer(appKey []byte, tlsConf *tls.Config) netwrap.Dialer {
+	dialer := websocket.Dialer{
+		ReadBufferSize:   1024 * 4,
+		WriteBufferSize:  1024 * 4,
+		HandshakeTimeout: 30 * time.Second,
+		Subprotocols:     []string{WebsocketSubprotocol(appKey)},
+		TLSClientConfig:  tlsConf,
+	}
+	scheme := "ws"
+	if tlsConf != nil {
+		scheme = "wss"
+	}
+
+	return func(addr net.Addr, wrappers ...netwrap.ConnWrapper) (net.Conn, error) {
+		tcpAddr := netwrap.GetAddr(addr, "tcp")
+		if tcpAddr == nil {
+			return nil, fmt.Errorf("wsConn: expected an address containing a tcp addr")
+		}
+
+		wsConn, resp, err := dialer.Dial(scheme+"://"+tcpAddr.String()+"/", nil)
+		if err != nil {
+			if resp != nil {
+				err = fmt.Errorf("%w (status: %s)", err, resp.Status)
+			}
+			return nil, fmt.Errorf("wsConn: failed to dial %s: %w", tcpAddr, err)
+		}
+
+		var conn net.Conn = &wrappedConn{
+			remote: wsConn.RemoteAddr(),
+			local:  wsConn.LocalAddr(),
+			wsc:    wsConn,
+		}
+		for _, cw := range wrappers {
+			conn, err = cw(conn)
+			if err != nil {
+				wsConn.Close()
+				return nil, fmt.Errorf("wsConn: failed to wrap connection: %w", err)
+			}
+		}
+		return conn, nil
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 type wrappedConn struct {
 	remote net.Addr
 	local  net.Addr
@@ -104,14 +180,14 @@ type wrappedConn struct {
 func (conn *wrappedConn) Read(data []byte) (int, error) {
 	if conn.r == nil {
 		if err := conn.renewReader(); err != nil {
-			return -1, err
+			return 0, err
 		}
 
 	}
 	n, err := conn.r.Read(data)
 	if err == io.EOF {
 		if err := conn.renewReader(); err != nil {
-			return -1, err
+			return 0, err
 		}
 		return conn.Read(data)
 	}
@@ -136,12 +212,12 @@ func (wc *wrappedConn) renewReader() error {
 func (conn wrappedConn) Write(data []byte) (int, error) {
 	writeCloser, err := conn.wsc.NextWriter(websocket.BinaryMessage)
 	if err != nil {
-		return -1, fmt.Errorf("wsConn: failed to create Reader: %w", err)
+		return 0, fmt.Errorf("wsConn: failed to create Reader: %w", err)
 	}
 
 	n, err := io.Copy(writeCloser, bytes.NewReader(data))
 	if err != nil {
-		return -1, fmt.Errorf("wsConn: failed to copy data: %w", err)
+		return int(n), fmt.Errorf("wsConn: failed to copy data: %w", err)
 	}
 	return int(n), writeCloser.Close()
 }
@@ -153,11 +229,14 @@ func (conn wrappedConn) Close() error {
 func (c wrappedConn) LocalAddr() net.Addr  { return c.local }
 func (c wrappedConn) RemoteAddr() net.Addr { return c.remote }
 func (c wrappedConn) SetDeadline(t time.Time) error {
-	return nil // c.conn.SetDeadline(t)
+	if err := c.wsc.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.wsc.SetWriteDeadline(t)
 }
 func (c wrappedConn) SetReadDeadline(t time.Time) error {
-	return nil // c.conn.SetReadDeadline(t)
+	return c.wsc.SetReadDeadline(t)
 }
 func (c wrappedConn) SetWriteDeadline(t time.Time) error {
-	return nil // c.conn.SetWriteDeadline(t)
+	return c.wsc.SetWriteDeadline(t)
 }
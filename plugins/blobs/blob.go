@@ -28,13 +28,13 @@ blobs manifest.json except:
 "ls": "source",
 "has": "async",
 "want": "async",
-"createWants": "source"
+"createWants": "source",
+"changes": "source"
 
 "size": "async",
 "getSlice": "source",
 "meta": "async",
 "push": "async",
-"changes": "source",
 */
 
 var (
@@ -65,6 +65,12 @@ func New(log logging.Interface, self refs.FeedRef, bs ssb.BlobStore, wm ssb.Want
 	// 	bs:  bs,
 	// })
 
+	mux.RegisterSource(muxrpc.Method{"blobs", "changes"}, changesHandler{
+		self: self,
+		log:  log,
+		bs:   bs,
+	})
+
 	mux.RegisterSource(muxrpc.Method{"blobs", "get"}, getHandler{
 		log: log,
 		bs:  bs,
@@ -85,6 +91,18 @@ func New(log logging.Interface, self refs.FeedRef, bs ssb.BlobStore, wm ssb.Want
 		wm:  wm,
 	})
 
+	mux.RegisterAsync(muxrpc.Method{"blobs", "unwant"}, unwantHandler{
+		self: self,
+		log:  log,
+		wm:   wm,
+	})
+
+	mux.RegisterAsync(muxrpc.Method{"blobs", "wants"}, wantsHandler{
+		self: self,
+		log:  log,
+		wm:   wm,
+	})
+
 	mux.RegisterSource(muxrpc.Method{"blobs", "createWants"}, &createWantsHandler{
 		log:     log,
 		self:    self,
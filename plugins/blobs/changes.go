@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package blobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/broadcasts"
+)
+
+// changesHandler streams the reference of every blob that is added to the store, until the call is closed.
+// Like blobs.add it is only available to the bot itself.
+type changesHandler struct {
+	self refs.FeedRef
+	bs   ssb.BlobStore
+	log  logging.Interface
+}
+
+func (h changesHandler) HandleSource(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+	requester, err := ssb.GetFeedRefFromAddr(req.RemoteAddr())
+	if err != nil {
+		return fmt.Errorf("unauthorized")
+	}
+
+	if !requester.Equal(h.self) {
+		return fmt.Errorf("unauthorized")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	snk.SetEncoding(muxrpc.TypeJSON)
+	enc := json.NewEncoder(snk)
+
+	var (
+		mu      sync.Mutex
+		sendErr error
+	)
+	done := h.bs.Register(broadcasts.BlobStoreFuncEmitter(func(n ssb.BlobStoreNotification) error {
+		if n.Op != ssb.BlobStoreOpPut {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr != nil {
+			return sendErr
+		}
+		if err := enc.Encode(n.Ref.Sigil()); err != nil {
+			sendErr = fmt.Errorf("blobs.changes: failed to send %s: %w", n.Ref.ShortSigil(), err)
+			cancel()
+			return sendErr
+		}
+		return nil
+	}))
+	<-ctx.Done()
+	done()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sendErr != nil {
+		return sendErr
+	}
+	return snk.Close()
+}
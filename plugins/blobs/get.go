@@ -38,6 +38,7 @@ func (h getHandler) HandleSource(ctx context.Context, req *muxrpc.Request, snk *
 
 	var wantedRef refs.BlobRef
 	var maxSize uint = blobstore.DefaultMaxSize
+	var offset int64
 
 	var justTheRef []refs.BlobRef
 	if err := json.Unmarshal(req.RawArgs, &justTheRef); err != nil {
@@ -50,6 +51,7 @@ func (h getHandler) HandleSource(ctx context.Context, req *muxrpc.Request, snk *
 		}
 		wantedRef = withSize[0].Key
 		maxSize = withSize[0].Max
+		offset = withSize[0].Offset
 	} else {
 		if len(justTheRef) != 1 {
 			return errors.New("bad request")
@@ -68,10 +70,21 @@ func (h getHandler) HandleSource(ctx context.Context, req *muxrpc.Request, snk *
 
 	logger = log.With(logger, "blob", wantedRef.ShortSigil())
 
+	if offset < 0 || offset > sz {
+		return fmt.Errorf("bad request - offset %d outside of blob", offset)
+	}
+
 	r, err := h.bs.Get(wantedRef)
 	if err != nil {
 		return errors.New("do not have blob")
 	}
+	defer r.Close()
+
+	if offset > 0 {
+		if _, err := skipBytes(r, offset); err != nil {
+			return fmt.Errorf("error skipping to offset: %w", err)
+		}
+	}
 
 	w := muxrpc.NewSinkWriter(snk)
 
@@ -89,3 +102,11 @@ func (h getHandler) HandleSource(ctx context.Context, req *muxrpc.Request, snk *
 	// }
 	return nil
 }
+
+// skipBytes moves r forward by n bytes, seeking if possible
+func skipBytes(r io.Reader, n int64) (int64, error) {
+	if seeker, ok := r.(io.Seeker); ok {
+		return seeker.Seek(n, io.SeekStart)
+	}
+	return io.CopyN(io.Discard, r, n)
+}
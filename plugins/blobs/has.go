@@ -31,7 +31,12 @@ func (h hasHandler) HandleAsync(ctx context.Context, req *muxrpc.Request) (inter
 		var blobRefs []refs.BlobRef
 		err := json.Unmarshal(req.RawArgs, &blobRefs)
 		if err != nil {
-			return nil, fmt.Errorf("bad request - unhandled type %s", err)
+			// or a single array of refs, like the javascript stack sends for has([ref1, ref2])
+			var batch [][]refs.BlobRef
+			if batchErr := json.Unmarshal(req.RawArgs, &batch); batchErr != nil || len(batch) != 1 {
+				return nil, fmt.Errorf("bad request - unhandled type %s", err)
+			}
+			blobRefs = batch[0]
 		}
 		var has = make([]bool, len(blobRefs))
 
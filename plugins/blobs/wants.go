@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package blobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// Want is an entry of the reply of blobs.wants
+type Want struct {
+	Ref refs.BlobRef `json:"ref"`
+
+	// the hop count to the original wanter, as a negative number. -1 are our own wants.
+	Dist int64 `json:"dist"`
+}
+
+// only the bot itself may look at and change its wants
+func isSelf(self refs.FeedRef, req *muxrpc.Request) error {
+	requester, err := ssb.GetFeedRefFromAddr(req.RemoteAddr())
+	if err != nil || !requester.Equal(self) {
+		return fmt.Errorf("unauthorized")
+	}
+	return nil
+}
+
+type wantsHandler struct {
+	self refs.FeedRef
+	wm   ssb.WantManager
+	log  logging.Interface
+}
+
+func (h wantsHandler) HandleAsync(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	if err := isSelf(h.self, req); err != nil {
+		return nil, err
+	}
+
+	all := h.wm.AllWants()
+	wants := make([]Want, len(all))
+	for i, w := range all {
+		wants[i] = Want{Ref: w.Ref, Dist: w.Dist}
+	}
+	return wants, nil
+}
+
+type unwantHandler struct {
+	self refs.FeedRef
+	wm   ssb.WantManager
+	log  logging.Interface
+}
+
+func (h unwantHandler) HandleAsync(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	if err := isSelf(h.self, req); err != nil {
+		return nil, err
+	}
+
+	var unwants []refs.BlobRef
+	if err := json.Unmarshal(req.RawArgs, &unwants); err != nil {
+		return nil, fmt.Errorf("error parsing blob reference: %w", err)
+	}
+	if len(unwants) < 1 {
+		return nil, fmt.Errorf("bad request - no args %d", len(unwants))
+	}
+
+	for _, ref := range unwants {
+		if err := h.wm.Unwant(ref); err != nil {
+			return nil, err
+		}
+	}
+	return true, nil
+}
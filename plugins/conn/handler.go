@@ -15,7 +15,6 @@ import (
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/ssbc/go-muxrpc/v2/typemux"
 	"github.com/ssbc/go-netwrap"
-	"github.com/ssbc/go-secretstream"
 	"go.mindeco.de/log/level"
 	"go.mindeco.de/logging"
 
@@ -23,16 +22,17 @@ import (
 	refs "github.com/ssbc/go-ssb-refs"
 
 	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/network"
 )
 
 type handler struct {
-	node ssb.Network
+	node *network.Node
 	repl ssb.Replicator
 
 	info logging.Interface
 }
 
-func New(i logging.Interface, n ssb.Network, r ssb.Replicator) muxrpc.Handler {
+func New(i logging.Interface, n *network.Node, r ssb.Replicator) muxrpc.Handler {
 	h := &handler{
 		info: i,
 		node: n,
@@ -45,6 +45,7 @@ func New(i logging.Interface, n ssb.Network, r ssb.Replicator) muxrpc.Handler {
 
 	mux.RegisterAsync(muxrpc.Method{"conn", "connect"}, typemux.AsyncFunc(h.connect))
 	mux.RegisterAsync(muxrpc.Method{"conn", "disconnect"}, typemux.AsyncFunc(h.disconnect))
+	mux.RegisterAsync(muxrpc.Method{"conn", "discovered"}, typemux.AsyncFunc(h.discovered))
 
 	mux.RegisterAsync(muxrpc.Method{"conn", "replicate"}, unmarshalActionMap(h.replicate))
 	mux.RegisterAsync(muxrpc.Method{"conn", "block"}, unmarshalActionMap(h.block))
@@ -169,21 +170,28 @@ func (h *handler) connect(ctx context.Context, req *muxrpc.Request) (interface{}
 	}
 	dest := args[0]
 
-	msaddr, err := multiserver.ParseNetAddress([]byte(dest))
+	wrappedAddr, remote, err := network.ParseDialAddress(dest)
 	if err != nil {
 		return nil, fmt.Errorf("ctrl.connect call: failed to parse input %q: %w", dest, err)
 	}
 
-	wrappedAddr := netwrap.WrapAddr(&msaddr.Addr, secretstream.Addr{PubKey: msaddr.Ref.PubKey()})
-	level.Info(h.info).Log("event", "connecting to peer", "remote", msaddr.Ref.ShortSigil())
+	level.Info(h.info).Log("event", "connecting to peer", "remote", remote.ShortSigil())
 	// TODO: add context to tracker to cancel connections
 	err = h.node.Connect(context.Background(), wrappedAddr)
 	if err != nil {
-		return nil, fmt.Errorf("ctrl.connect call: error connecting to %q: %w", msaddr.Addr, err)
+		return nil, fmt.Errorf("ctrl.connect call: error connecting to %q: %w", netwrap.GetAddr(wrappedAddr, "tcp"), err)
 	}
 	return reply{"connected"}, nil
 }
 
+func (h *handler) discovered(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	peers := h.node.DiscoveredPeers()
+	if peers == nil {
+		peers = []network.DiscoveredPeer{}
+	}
+	return peers, nil
+}
+
 func (h *handler) dialViaRoom(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
 	var args []string
 	err := json.Unmarshal(req.RawArgs, &args)
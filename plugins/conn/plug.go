@@ -7,6 +7,7 @@ package conn
 import (
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/network"
 	"go.mindeco.de/logging"
 )
 
@@ -14,7 +15,7 @@ type connectPlug struct {
 	h muxrpc.Handler
 }
 
-func NewPlug(i logging.Interface, n ssb.Network, r ssb.Replicator) ssb.Plugin {
+func NewPlug(i logging.Interface, n *network.Node, r ssb.Replicator) ssb.Plugin {
 	return &connectPlug{h: New(i, n, r)}
 }
 
@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package diskusage exposes the disk usage of the repo as repo.diskUsage
+package diskusage
+
+import (
+	"context"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+)
+
+type plugin struct {
+	h muxrpc.Handler
+}
+
+// New returns the plugin for repo.diskUsage, backed by du
+func New(i logging.Interface, du ssb.DiskUsager) ssb.Plugin {
+	mux := typemux.New(i)
+
+	mux.RegisterAsync(muxrpc.Method{"repo", "diskUsage"}, typemux.AsyncFunc(func(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+		return du.DiskUsage()
+	}))
+
+	return plugin{h: &mux}
+}
+
+func (p plugin) Name() string            { return "repo" }
+func (p plugin) Method() muxrpc.Method   { return muxrpc.Method{"repo"} }
+func (p plugin) Handler() muxrpc.Handler { return p.h }
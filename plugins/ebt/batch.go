@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ebt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultBatchWindow is how long changes of our frontier are collected before they are sent to a peer as one update
+const DefaultBatchWindow = 200 * time.Millisecond
+
+// noteBatcher collects the feeds whose notes changed during a session until they are sent
+type noteBatcher struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+
+	wake chan struct{}
+}
+
+func newNoteBatcher() *noteBatcher {
+	return &noteBatcher{
+		pending: make(map[string]struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Add marks the feeds as changed. It doesn't block.
+func (nb *noteBatcher) Add(feeds []string) {
+	nb.mu.Lock()
+	for _, f := range feeds {
+		nb.pending[f] = struct{}{}
+	}
+	nb.mu.Unlock()
+
+	select {
+	case nb.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the changed feeds and starts a new batch
+func (nb *noteBatcher) take() map[string]struct{} {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	feeds := nb.pending
+	nb.pending = make(map[string]struct{})
+	return feeds
+}
+
+// Run calls send with the changed feeds until ctx is canceled or send fails.
+// After the first change it waits for window, so that the changes which follow are sent together.
+func (nb *noteBatcher) Run(ctx context.Context, window time.Duration, send func(feeds map[string]struct{}) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-nb.wake:
+		}
+
+		if window > 0 {
+			timer := time.NewTimer(window)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		}
+
+		feeds := nb.take()
+		if len(feeds) == 0 {
+			continue
+		}
+
+		if err := send(feeds); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ebt
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoteBatcher(t *testing.T) {
+	type run struct {
+		name   string
+		window time.Duration
+		pause  time.Duration // between the changes
+
+		maxSends int
+	}
+
+	for _, tc := range []run{
+		// a burst within the window is sent as one update
+		{"burst", 100 * time.Millisecond, 0, 1},
+		// without a window every change can be its own update
+		{"no window", 0, 2 * time.Millisecond, 50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var (
+				mu    sync.Mutex
+				sends int
+				seen  = make(map[string]struct{})
+			)
+			send := func(feeds map[string]struct{}) error {
+				mu.Lock()
+				defer mu.Unlock()
+				sends++
+				for f := range feeds {
+					seen[f] = struct{}{}
+				}
+				return nil
+			}
+
+			nb := newNoteBatcher()
+			done := make(chan error)
+			go func() { done <- nb.Run(ctx, tc.window, send) }()
+
+			const changes = 50
+			for i := 0; i < changes; i++ {
+				nb.Add([]string{strconv.Itoa(i % 10)})
+				time.Sleep(tc.pause)
+			}
+
+			// wait for the last batch
+			time.Sleep(tc.window + 50*time.Millisecond)
+			cancel()
+			r.NoError(<-done)
+
+			mu.Lock()
+			defer mu.Unlock()
+			r.Len(seen, 10, "all changed feeds should be sent")
+			r.True(sends >= 1 && sends <= tc.maxSends, "sent %d updates", sends)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ebt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// SupportedVersions lists the ebt versions we can speak, in order of preference.
+// Version 1 encodes notes as plain sequence numbers, version 2 and 3 use the receive bit.
+// Version 3 also allows to negotiate the feed format of the session.
+var SupportedVersions = []int{3, 2, 1}
+
+// FormatClassic is the default feed format, legacy ssb feeds
+const FormatClassic = "classic"
+
+// ErrVersionRejected is returned by LoopWithFormat if the remote closed the session before sending anything,
+// which is what older peers do when they don't understand the requested version.
+var ErrVersionRejected = errors.New("ebt: remote rejected the session version")
+
+var formatAlgos = map[string]refs.RefAlgo{
+	FormatClassic:                     refs.RefAlgoFeedSSB1,
+	string(refs.RefAlgoFeedBendyButt): refs.RefAlgoFeedBendyButt,
+	string(refs.RefAlgoFeedGabby):     refs.RefAlgoFeedGabby,
+}
+
+// SessionFormat is the negotiated version and feed format of an ebt session
+type SessionFormat struct {
+	Version int    `json:"version"`
+	Format  string `json:"format,omitempty"`
+}
+
+// DefaultFormat is what we use if nothing else was negotiated
+var DefaultFormat = SessionFormat{Version: 3, Format: FormatClassic}
+
+// NewSessionFormat checks that version and format are supported and fills in the default format if needed.
+func NewSessionFormat(version int, format string) (SessionFormat, error) {
+	supported := false
+	for _, v := range SupportedVersions {
+		if v == version {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return SessionFormat{}, fmt.Errorf("ebt: unsupported version %d (supported: %v)", version, SupportedVersions)
+	}
+
+	if format == "" {
+		format = FormatClassic
+	}
+
+	if version < 3 && format != FormatClassic {
+		return SessionFormat{}, fmt.Errorf("ebt: version %d only supports the %s format", version, FormatClassic)
+	}
+
+	if _, has := formatAlgos[format]; !has {
+		return SessionFormat{}, fmt.Errorf("ebt: unsupported feed format %q", format)
+	}
+
+	return SessionFormat{Version: version, Format: format}, nil
+}
+
+// Algo returns the feed reference algorithm that is replicated with this format
+func (sf SessionFormat) Algo() refs.RefAlgo {
+	if algo, has := formatAlgos[sf.Format]; has {
+		return algo
+	}
+	return refs.RefAlgoFeedSSB1
+}
+
+// Args returns the arguments for an ebt.replicate call
+func (sf SessionFormat) Args() map[string]interface{} {
+	args := map[string]interface{}{"version": sf.Version}
+	if sf.Version >= 3 {
+		args["format"] = sf.Format
+	}
+	return args
+}
+
+// EncodeFrontier encodes the notes for feeds of the session's format
+func (sf SessionFormat) EncodeFrontier(nf ssb.NetworkFrontier) ([]byte, error) {
+	algo := sf.Algo()
+
+	var notes = make(map[string]json.RawMessage, len(nf))
+	for feedStr, note := range nf {
+		feed, err := refs.ParseFeedRef(feedStr)
+		if err != nil || feed.Algo() != algo {
+			continue
+		}
+
+		if sf.Version == 1 {
+			notes[feedStr] = encodeNoteV1(note)
+			continue
+		}
+
+		encoded, err := note.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		notes[feedStr] = encoded
+	}
+
+	return json.Marshal(notes)
+}
+
+// DecodeFrontier decodes notes and skips the feeds that don't match the format of the session
+func (sf SessionFormat) DecodeFrontier(b []byte) (ssb.NetworkFrontier, error) {
+	var dummy map[string]int64
+	if err := json.Unmarshal(b, &dummy); err != nil {
+		return nil, err
+	}
+
+	algo := sf.Algo()
+
+	var nf = make(ssb.NetworkFrontier, len(dummy))
+	for fstr, i := range dummy {
+		feed, err := refs.ParseFeedRef(fstr)
+		if err != nil {
+			// just skip invalid feeds
+			continue
+		}
+
+		if feed.Algo() != algo {
+			continue
+		}
+
+		var n ssb.Note
+		n.Replicate = i != -1
+		if sf.Version == 1 {
+			n.Receive = n.Replicate
+			n.Seq = i
+		} else {
+			n.Receive = !(i&1 == 1)
+			n.Seq = int64(i >> 1)
+		}
+		nf[fstr] = n
+	}
+
+	return nf, nil
+}
+
+func encodeNoteV1(n ssb.Note) json.RawMessage {
+	if !n.Replicate {
+		return json.RawMessage("-1")
+	}
+	seq := n.Seq
+	if seq == -1 {
+		seq = 0
+	}
+	return json.RawMessage(fmt.Sprint(seq))
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ebt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+func TestSessionFormatNegotiation(t *testing.T) {
+	r := require.New(t)
+
+	sf, err := NewSessionFormat(3, "")
+	r.NoError(err)
+	r.Equal(FormatClassic, sf.Format)
+	r.Equal(refs.RefAlgoFeedSSB1, sf.Algo())
+
+	sf, err = NewSessionFormat(3, "bendybutt-v1")
+	r.NoError(err)
+	r.Equal(refs.RefAlgoFeedBendyButt, sf.Algo())
+
+	_, err = NewSessionFormat(1, "bendybutt-v1")
+	r.Error(err, "v1 only knows classic feeds")
+
+	_, err = NewSessionFormat(4, "")
+	r.Error(err)
+
+	_, err = NewSessionFormat(3, "nope-v9")
+	r.Error(err)
+
+	r.NotContains(SessionFormat{Version: 1, Format: FormatClassic}.Args(), "format")
+}
+
+func TestSessionFormatFrontierEncoding(t *testing.T) {
+	r := require.New(t)
+
+	classic, err := refs.NewFeedRefFromBytes(make([]byte, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	bendy, err := refs.NewFeedRefFromBytes(make([]byte, 32), refs.RefAlgoFeedBendyButt)
+	r.NoError(err)
+	notReplicated, err := refs.NewFeedRefFromBytes(append(make([]byte, 31), 1), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	nf := ssb.NetworkFrontier{
+		classic.String():       {Seq: 23, Replicate: true, Receive: true},
+		bendy.String():         {Seq: 5, Replicate: true, Receive: false},
+		notReplicated.String(): {Seq: 0, Replicate: false},
+	}
+
+	type tcase struct {
+		sf      SessionFormat
+		encoded string
+		feed    refs.FeedRef
+		note    ssb.Note
+	}
+	cases := []tcase{
+		{
+			sf:      SessionFormat{Version: 3, Format: FormatClassic},
+			encoded: `"` + classic.String() + `":46`,
+			feed:    classic,
+			note:    ssb.Note{Seq: 23, Replicate: true, Receive: true},
+		},
+		{
+			sf:      SessionFormat{Version: 1, Format: FormatClassic},
+			encoded: `"` + classic.String() + `":23`,
+			feed:    classic,
+			note:    ssb.Note{Seq: 23, Replicate: true, Receive: true},
+		},
+		{
+			sf:      SessionFormat{Version: 3, Format: "bendybutt-v1"},
+			encoded: `"` + bendy.String() + `":11`,
+			feed:    bendy,
+			note:    ssb.Note{Seq: 5, Replicate: true, Receive: false},
+		},
+	}
+
+	for i, tc := range cases {
+		encoded, err := tc.sf.EncodeFrontier(nf)
+		r.NoError(err, "case %d", i)
+		r.Contains(string(encoded), tc.encoded, "case %d", i)
+
+		decoded, err := tc.sf.DecodeFrontier(encoded)
+		r.NoError(err, "case %d", i)
+
+		note, has := decoded[tc.feed.String()]
+		r.True(has, "case %d", i)
+		r.Equal(tc.note, note, "case %d", i)
+
+		for feed := range decoded {
+			parsed, err := refs.ParseFeedRef(feed)
+			r.NoError(err)
+			r.Equal(tc.sf.Algo(), parsed.Algo(), "case %d: feed of other format", i)
+		}
+
+		if tc.sf.Format == FormatClassic {
+			note, has = decoded[notReplicated.String()]
+			r.True(has, "case %d", i)
+			r.False(note.Replicate, "case %d", i)
+		}
+	}
+}
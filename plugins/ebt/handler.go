@@ -12,9 +12,12 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
+	"time"
 
 	"go.mindeco.de/log"
 
+	"github.com/go-kit/kit/metrics"
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/ssbc/margaret"
 	"github.com/ssbc/margaret/multilog"
@@ -28,6 +31,9 @@ import (
 	"github.com/ssbc/go-ssb/plugins/gossip"
 )
 
+// ErrSessionStalled is returned by LoopWithFormat if the remote didn't send anything for longer then the idle timeout.
+var ErrSessionStalled = errors.New("ebt: session stalled")
+
 type MUXRPCHandler struct {
 	info logging.Interface
 
@@ -43,6 +49,11 @@ type MUXRPCHandler struct {
 
 	verify *message.VerificationRouter
 
+	idleTimeout  time.Duration
+	batchWindow  time.Duration
+	interest     InterestFunc
+	eventCounter metrics.Counter
+
 	Sessions Sessions
 }
 
@@ -72,7 +83,7 @@ func (h *MUXRPCHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 		return
 	}
 
-	var args []struct{ Version int }
+	var args []SessionFormat
 	err := json.Unmarshal(req.RawArgs, &args)
 	if err != nil {
 		checkAndClose(err)
@@ -84,11 +95,26 @@ func (h *MUXRPCHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 		return
 	}
 
-	if args[0].Version != 3 {
-		checkAndClose(errors.New("go-ssb only support ebt v3"))
+	sf, err := NewSessionFormat(args[0].Version, args[0].Format)
+	if err != nil {
+		checkAndClose(err)
 		return
 	}
-	level.Debug(h.info).Log("event", "replicating", "version", args[0].Version)
+	level.Debug(h.info).Log("event", "replicating", "version", sf.Version, "format", sf.Format)
+
+	// the remote speaks ebt, forget an earlier fallback decision
+	if remote, err := ssb.GetFeedRefFromAddr(req.RemoteAddr()); err == nil {
+		h.Sessions.ClearLegacy(remote)
+	}
+
+	if !h.Sessions.Acquire(ctx) {
+		// let the remote and our own negotiation fall back to legacy gossip right away
+		level.Debug(h.info).Log("event", "no free session slot")
+		h.Sessions.rejected(req.RemoteAddr())
+		req.CloseWithError(ErrSessionLimit)
+		return
+	}
+	defer h.Sessions.Release()
 
 	// get writer and reader from duplex call
 	snk, err := req.ResponseSink()
@@ -103,44 +129,149 @@ func (h *MUXRPCHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 		return
 	}
 
-	h.Loop(ctx, snk, src, req.RemoteAddr())
+	h.check(h.LoopWithFormat(ctx, snk, src, req.RemoteAddr(), sf))
 }
 
-func (h *MUXRPCHandler) sendState(ctx context.Context, tx *muxrpc.ByteSink, remote refs.FeedRef) error {
-	currState, err := h.stateMatrix.Changed(h.self, remote)
+func (h *MUXRPCHandler) sendState(ctx context.Context, tx *muxrpc.ByteSink, remote refs.FeedRef, sf SessionFormat, sess *session) error {
+	currState, err := h.frontierFor(remote, sess)
 	if err != nil {
-		return fmt.Errorf("failed to get changed frontier: %w", err)
+		return err
 	}
 
-	selfRef := h.self.String()
-
-	// don't receive your own feed
-	if myNote, has := currState[selfRef]; has {
-		myNote.Receive = false
-		currState[selfRef] = myNote
+	encoded, err := sf.EncodeFrontier(currState)
+	if err != nil {
+		return fmt.Errorf("failed to encode currState: %w", err)
 	}
 
 	tx.SetEncoding(muxrpc.TypeJSON)
-	err = json.NewEncoder(tx).Encode(currState)
+	_, err = tx.Write(encoded)
 	if err != nil {
 		return fmt.Errorf("failed to send currState: %d: %w", len(currState), err)
 	}
+	sess.Told(currState)
+
+	return nil
+}
+
+// sendNotes sends the notes of the changed feeds that are relevant to remote and were not sent like this before
+func (h *MUXRPCHandler) sendNotes(tx *muxrpc.ByteSink, remote refs.FeedRef, sf SessionFormat, sess *session, changed map[string]struct{}) error {
+	// the hops of remote might have changed since the session started
+	if h.interest != nil && sess.InterestAge() > interestRefresh {
+		added, err := h.updateInterest(remote, sess)
+		if err != nil {
+			return err
+		}
+		for _, feed := range added {
+			changed[feed] = struct{}{}
+		}
+	}
+
+	currState, err := h.frontierFor(remote, sess)
+	if err != nil {
+		return err
+	}
 
+	for feed := range currState {
+		if _, has := changed[feed]; !has {
+			delete(currState, feed)
+		}
+	}
+	sess.Untold(currState)
+	if len(currState) == 0 {
+		return nil
+	}
+
+	encoded, err := sf.EncodeFrontier(currState)
+	if err != nil {
+		return fmt.Errorf("failed to encode notes: %w", err)
+	}
+
+	tx.SetEncoding(muxrpc.TypeJSON)
+	_, err = tx.Write(encoded)
+	if err != nil {
+		return fmt.Errorf("failed to send notes: %d: %w", len(currState), err)
+	}
+	sess.Told(currState)
+
+	if h.eventCounter != nil {
+		h.eventCounter.With("event", "ebt-notes-sent").Add(1)
+	}
 	return nil
 }
 
-// Loop executes the ebt logic loop, reading from the peer and sending state and messages as requests
+// interestRefresh is how often the feeds a peer is interested in are updated while notes are sent to it
+const interestRefresh = 10 * time.Second
+
+// updateInterest sets the feeds remote is interested in, if there is a way to know them.
+// It returns the ones that were added.
+func (h *MUXRPCHandler) updateInterest(remote refs.FeedRef, sess *session) ([]string, error) {
+	if h.interest == nil {
+		return nil, nil
+	}
+
+	feeds, err := h.interest(remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the feeds the peer is interested in: %w", err)
+	}
+	return sess.SetInterest(feeds), nil
+}
+
+// frontierFor returns the notes of our frontier that are relevant to remote.
+// Feeds remote didn't tell us about are only included if it is interested in them, see WithPeerInterest,
+// or if we don't have any of their messages yet. Those notes only ask for the feed.
+func (h *MUXRPCHandler) frontierFor(remote refs.FeedRef, sess *session) (ssb.NetworkFrontier, error) {
+	var (
+		selfRef   = h.self.String()
+		remoteRef = remote.String()
+	)
+
+	currState, err := h.stateMatrix.Relevant(h.self, remote, func(feed string, ours ssb.Note) bool {
+		if feed == selfRef || feed == remoteRef || ours.Seq < 1 {
+			return true
+		}
+		return sess.Interested(feed)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed frontier: %w", err)
+	}
+
+	// don't receive your own feed
+	if myNote, has := currState[selfRef]; has {
+		myNote.Receive = false
+		currState[selfRef] = myNote
+	}
+
+	return currState, nil
+}
+
+// Loop executes the ebt logic loop, reading from the peer and sending state and messages as requests.
+// It uses the DefaultFormat, see LoopWithFormat for other versions and formats.
 func (h *MUXRPCHandler) Loop(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrpc.ByteSource, remoteAddr net.Addr) {
-	session := h.Sessions.Started(remoteAddr)
+	h.check(h.LoopWithFormat(ctx, tx, rx, remoteAddr, DefaultFormat))
+}
 
+// LoopWithFormat is like Loop but encodes notes and filters feeds according to the negotiated format.
+// It returns ErrVersionRejected if the remote closed the session with an error before sending anything
+// and ErrSessionLimit if the remote turned it down because it had no free session slot.
+// If the number of sessions is limited, the caller needs to hold a slot, see Sessions.Acquire.
+func (h *MUXRPCHandler) LoopWithFormat(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrpc.ByteSource, remoteAddr net.Addr, sf SessionFormat) error {
 	peer, err := ssb.GetFeedRefFromAddr(remoteAddr)
 	if err != nil {
-		h.check(err)
-		return
+		return err
 	}
 
+	session := h.Sessions.Started(remoteAddr, sf)
+
 	peerLogger := log.With(h.info, "r", peer.ShortSigil())
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stalled = make(chan struct{})
+	if h.idleTimeout > 0 {
+		go h.watchIdle(ctx, cancel, session, stalled)
+	}
+
 	defer func() {
 		h.Sessions.Ended(remoteAddr)
 
@@ -151,12 +282,39 @@ func (h *MUXRPCHandler) Loop(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrp
 		}
 	}()
 
-	if err := h.sendState(ctx, tx, peer); err != nil {
-		h.check(err)
-		return
+	// collect changes from the start, so that none are missed between the state and the first update
+	notes := newNoteBatcher()
+	removeListener := h.stateMatrix.OnChange(notes.Add)
+	defer removeListener()
+
+	if _, err := h.updateInterest(peer, session); err != nil {
+		return err
 	}
 
-	var buf = &bytes.Buffer{}
+	if err := h.sendState(ctx, tx, peer, sf, session); err != nil {
+		return err
+	}
+
+	notesDone := make(chan struct{})
+	go func() {
+		defer close(notesDone)
+		err := notes.Run(ctx, h.batchWindow, func(changed map[string]struct{}) error {
+			return h.sendNotes(tx, peer, sf, session, changed)
+		})
+		if err != nil && !muxrpc.IsSinkClosed(err) {
+			level.Warn(peerLogger).Log("event", "failed to send notes", "err", err)
+		}
+	}()
+	// don't send notes after the session ended and its state was saved
+	defer func() {
+		cancel()
+		<-notesDone
+	}()
+
+	var (
+		buf      = &bytes.Buffer{}
+		received uint
+	)
 	for rx.Next(ctx) { // read/write loop for messages
 
 		buf.Reset()
@@ -165,14 +323,14 @@ func (h *MUXRPCHandler) Loop(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrp
 			return err
 		})
 		if err != nil {
-			h.check(err)
-			return
+			return err
 		}
+		received++
+		session.Touch()
 
 		jsonBody := buf.Bytes()
 
-		var frontierUpdate ssb.NetworkFrontier
-		err = json.Unmarshal(jsonBody, &frontierUpdate)
+		frontierUpdate, err := sf.DecodeFrontier(jsonBody)
 		if err != nil { // assume it's a message
 
 			// redundant pass of finding out the author
@@ -197,6 +355,9 @@ func (h *MUXRPCHandler) Loop(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrp
 			err = vsnk.Verify(jsonBody)
 			if err != nil {
 				// TODO: mark feed as bad
+				if h.eventCounter != nil {
+					h.eventCounter.With("event", "ebt-verify-failed").Add(1)
+				}
 				h.check(err)
 			}
 
@@ -206,23 +367,37 @@ func (h *MUXRPCHandler) Loop(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrp
 		// update our network perception
 		wants, err := h.stateMatrix.Update(peer, frontierUpdate)
 		if err != nil {
-			h.check(err)
-			return
+			return err
+		}
+
+		// answer with our notes for the feeds the peer declared, if we didn't already
+		declared := make([]string, 0, len(frontierUpdate))
+		for feed := range frontierUpdate {
+			declared = append(declared, feed)
 		}
+		notes.Add(declared)
 
 		// TODO: partition wants across the open connections
 		// one peer might be closer to a feed
 		// for this we also need timing and other heuristics
 
-		// ad-hoc send where we have newer messages
-		for feedStr, their := range wants {
-			// these were already validated by the .UnmarshalJSON() method
-			// but we need the refs.Feed for the createHistArgs
+		// these were already validated by the .UnmarshalJSON() method
+		// but we need the refs.Feed for the createHistArgs
+		wantedFeeds := make([]refs.FeedRef, 0, len(wants))
+		for feedStr := range wants {
 			feed, err := refs.ParseFeedRef(feedStr)
 			if err != nil {
-				h.check(err)
-				return
+				return err
 			}
+			wantedFeeds = append(wantedFeeds, feed)
+		}
+
+		// start with the prioritized feeds
+		h.stateMatrix.SortByPriority(wantedFeeds)
+
+		// ad-hoc send where we have newer messages
+		for _, feed := range wantedFeeds {
+			their := wants[feed.String()]
 
 			if !their.Replicate {
 				continue
@@ -247,12 +422,51 @@ func (h *MUXRPCHandler) Loop(ctx context.Context, tx *muxrpc.ByteSink, rx *muxrp
 			err = h.livefeeds.CreateStreamHistory(ctx, tx, arg)
 			if err != nil {
 				cancel()
-				h.check(err)
-				return
+				return err
 			}
 			session.Subscribed(feed, cancel)
 		}
 	}
 
-	h.check(rx.Err())
+	select {
+	case <-stalled:
+		level.Warn(peerLogger).Log("event", "session stalled", "timeout", h.idleTimeout)
+		if h.eventCounter != nil {
+			h.eventCounter.With("event", "ebt-session-stalled").Add(1)
+		}
+		tx.CloseWithError(ErrSessionStalled)
+		return ErrSessionStalled
+	default:
+	}
+
+	err = rx.Err()
+	var callErr *muxrpc.CallError
+	if received == 0 && errors.As(err, &callErr) {
+		if strings.Contains(callErr.Message, ErrSessionLimit.Error()) {
+			return fmt.Errorf("remote is full: %w", ErrSessionLimit)
+		}
+		return fmt.Errorf("%w (version %d): %s", ErrVersionRejected, sf.Version, callErr.Message)
+	}
+	return err
+}
+
+// watchIdle cancels the session if the remote didn't send anything for longer then the idle timeout.
+// stalled is closed before the cancelation.
+func (h *MUXRPCHandler) watchIdle(ctx context.Context, cancel context.CancelFunc, sess *session, stalled chan<- struct{}) {
+	tick := time.NewTicker(h.idleTimeout / 4)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		if sess.IdleFor() > h.idleTimeout {
+			close(stalled)
+			cancel()
+			return
+		}
+	}
 }
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ebt
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// SessionInfo describes the state of a session with one peer, as returned by ebt.sessions
+type SessionInfo struct {
+	Peer *refs.FeedRef `json:"peer,omitempty"`
+	Addr string        `json:"addr"`
+
+	Version int    `json:"version,omitempty"`
+	Format  string `json:"format,omitempty"`
+
+	// Feeds is the number of feeds we are currently streaming to the peer
+	Feeds int `json:"feeds"`
+
+	LastActivity time.Time `json:"lastActivity,omitempty"`
+
+	// Waiting is true if we wait for the peer to start a session with us, since WaitingSince
+	Waiting      bool      `json:"waiting"`
+	WaitingSince time.Time `json:"waitingSince,omitempty"`
+}
+
+// List returns the open sessions and the addresses we are waiting for, sorted by address.
+func (s *Sessions) List() []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]SessionInfo, 0, len(s.open)+len(s.waitingFor))
+	for addr, sess := range s.open {
+		sess.mu.Lock()
+		info := SessionInfo{
+			Addr:         addr,
+			Version:      sess.format.Version,
+			Format:       sess.format.Format,
+			Feeds:        len(sess.subscribed),
+			LastActivity: sess.lastActivity,
+		}
+		sess.mu.Unlock()
+
+		if peer, err := ssb.GetFeedRefFromAddr(sess.remote); err == nil {
+			info.Peer = &peer
+		}
+		list = append(list, info)
+	}
+
+	for addr, w := range s.waitingFor {
+		list = append(list, SessionInfo{Addr: addr, Waiting: true, WaitingSince: w.since})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+	return list
+}
+
+type sessionsPlug struct {
+	h muxrpc.Handler
+}
+
+// NewSessionsPlug returns a plugin for ebt.sessions, which lists the state of the sessions of h.
+// It is meant for the local (master) interface, not for remote peers, so it isn't part of the manifest either.
+func NewSessionsPlug(i logging.Interface, h *MUXRPCHandler) ssb.Plugin {
+	mux := typemux.New(i)
+
+	mux.RegisterAsync(muxrpc.Method{"ebt", "sessions"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return h.Sessions.List(), nil
+	}))
+
+	return &sessionsPlug{h: &mux}
+}
+
+func (p sessionsPlug) Name() string            { return "ebt" }
+func (p sessionsPlug) Method() muxrpc.Method   { return muxrpc.Method{"ebt"} }
+func (p sessionsPlug) Handler() muxrpc.Handler { return p.h }
@@ -6,7 +6,9 @@ package ebt
 
 import (
 	"sync"
+	"time"
 
+	"github.com/go-kit/kit/metrics"
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/ssbc/margaret"
 	"github.com/ssbc/margaret/multilog"
@@ -28,9 +30,10 @@ func NewPlug(
 	fm *gossip.FeedManager,
 	sm *statematrix.StateMatrix,
 	v *message.VerificationRouter,
+	opts ...Option,
 ) *Plugin {
 
-	return &Plugin{&MUXRPCHandler{
+	h := &MUXRPCHandler{
 		info:      i,
 		self:      self,
 		rootLog:   rootLog,
@@ -42,13 +45,118 @@ func NewPlug(
 
 		verify: v,
 
+		batchWindow: DefaultBatchWindow,
+
 		Sessions: Sessions{
 			mu:   new(sync.Mutex),
 			open: make(map[string]*session),
 
-			waitingFor: make(map[string]chan<- struct{}),
+			waitingFor: make(map[string]*sessionWait),
+			logger:     i,
+
+			legacy:    make(map[string]time.Time),
+			legacyTTL: DefaultLegacyFallbackTTL,
+
+			slowWait: DefaultSlowSessionWait,
 		},
-	},
+	}
+
+	for _, o := range opts {
+		o(h)
+	}
+
+	return &Plugin{h}
+}
+
+// DefaultLegacyFallbackTTL is how long a peer stays on legacy gossip after ebt failed with it
+const DefaultLegacyFallbackTTL = 1 * time.Hour
+
+// Option changes the behavior of the ebt handler
+type Option func(*MUXRPCHandler)
+
+// WithIdleTimeout closes sessions where the remote didn't send a note or message for the passed duration.
+// This makes room for other peers and allows falling back to legacy gossip. Zero disables it.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(h *MUXRPCHandler) {
+		h.idleTimeout = d
+	}
+}
+
+// WithBatchWindow sets how long changes of our frontier are collected before they are sent to a peer as one update.
+// A longer window means fewer and larger writes, zero sends every change right away. The default is DefaultBatchWindow.
+func WithBatchWindow(d time.Duration) Option {
+	return func(h *MUXRPCHandler) {
+		h.batchWindow = d
+	}
+}
+
+// InterestFunc returns the feeds peer is interested in, for instance the ones within its hops in our follow graph
+type InterestFunc func(peer refs.FeedRef) ([]refs.FeedRef, error)
+
+// WithPeerInterest limits the notes sent to a peer to the feeds it told us about and the ones fn returns for it.
+// Feeds we don't have any messages of are still asked for. fn is called when the session starts and again while notes are sent, at most every few seconds.
+// Our notes for feeds the peer tells us about later are sent in reply. Without it, notes for all the feeds we replicate are sent.
+func WithPeerInterest(fn InterestFunc) Option {
+	return func(h *MUXRPCHandler) {
+		h.interest = fn
+	}
+}
+
+// WithLegacyFallbackTTL sets how long a peer is replicated with legacy gossip after ebt failed with it,
+// before ebt is tried again. Zero remembers the decision for the lifetime of the handler.
+func WithLegacyFallbackTTL(d time.Duration) Option {
+	return func(h *MUXRPCHandler) {
+		h.Sessions.legacyTTL = d
+	}
+}
+
+// WithEventCounter sets a counter for session events, like stalled sessions
+func WithEventCounter(ctr metrics.Counter) Option {
+	return func(h *MUXRPCHandler) {
+		h.eventCounter = ctr
+		h.Sessions.eventCounter = ctr
+	}
+}
+
+// WithSystemGauge sets a gauge for the number of peers we wait for to start a session with us
+func WithSystemGauge(g metrics.Gauge) Option {
+	return func(h *MUXRPCHandler) {
+		h.Sessions.gauge = g
+	}
+}
+
+// DefaultSlowSessionWait is how long we wait for a peer to start a session before it is logged
+const DefaultSlowSessionWait = 20 * time.Second
+
+// WithSessionWaits changes how long we wait for peers to start a session with us.
+// Waits that take longer than slow are logged and counted, zero disables that.
+// max caps the waits of the replication negotiation, zero leaves them as they are.
+func WithSessionWaits(slow, max time.Duration) Option {
+	return func(h *MUXRPCHandler) {
+		h.Sessions.slowWait = slow
+		h.Sessions.maxWait = max
+	}
+}
+
+// DefaultSessionQueueWait is how long a session waits for a free slot, if the number of sessions is limited
+const DefaultSessionQueueWait = 30 * time.Second
+
+// WithMaxSessions limits the number of concurrent sessions to max, zero means no limit.
+// New sessions over the limit wait for queueWait (DefaultSessionQueueWait if it is zero) for a slot to free up.
+// If there is none, the session is turned down with ErrSessionLimit and the peer can be replicated with legacy gossip instead.
+// A peer that waits for us to start the session only notices once its own wait times out.
+// The system gauge reports the open and the waiting sessions as ebt-active and ebt-queued.
+func WithMaxSessions(max int, queueWait time.Duration) Option {
+	return func(h *MUXRPCHandler) {
+		if max <= 0 {
+			h.Sessions.slots = nil
+			return
+		}
+		if queueWait <= 0 {
+			queueWait = DefaultSessionQueueWait
+		}
+		h.Sessions.slots = make(chan struct{}, max)
+		h.Sessions.queueWait = queueWait
 	}
 }
 
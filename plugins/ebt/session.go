@@ -6,29 +6,52 @@ package ebt
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/go-kit/kit/metrics"
+	"go.mindeco.de/log/level"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
 )
 
 type session struct {
 	remote net.Addr // netwrap'ed shs address
 
+	format SessionFormat // negotiated version and feed format
+
 	// tx *muxrpc.ByteSink // the muxrpc writer to send updates
 
 	// which feeds this session is currently subscribed to
 	mu         sync.Mutex // since the session is only used inside the ebt handler loop, we might not even need this lock
 	subscribed map[string]context.CancelFunc
+
+	// when the remote last sent a note or message
+	lastActivity time.Time
+
+	// the feeds the remote is interested in, nil if it is not known
+	interest    map[string]struct{}
+	interestSet time.Time
+
+	// the last note we sent for each feed
+	told ssb.NetworkFrontier
 }
 
-func newSession(remote net.Addr) *session {
+func newSession(remote net.Addr, sf SessionFormat) *session {
 	return &session{
 		remote: remote,
+		format: sf,
+
+		lastActivity: time.Now(),
 
 		subscribed: make(map[string]context.CancelFunc),
+
+		told: make(ssb.NetworkFrontier),
 	}
 }
 
@@ -46,6 +69,80 @@ func (s *session) Subscribed(feed refs.FeedRef, cancelFn context.CancelFunc) {
 	s.subscribed[fr] = cancelFn
 }
 
+// SetInterest sets the feeds the remote is interested in and returns the ones that were not in the set before
+func (s *session) SetInterest(feeds []refs.FeedRef) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []string
+	interest := make(map[string]struct{}, len(feeds))
+	for _, f := range feeds {
+		fr := f.String()
+		interest[fr] = struct{}{}
+		if _, had := s.interest[fr]; !had {
+			added = append(added, fr)
+		}
+	}
+	s.interest = interest
+	s.interestSet = time.Now()
+	return added
+}
+
+// InterestAge returns how long ago the interest of the remote was set
+func (s *session) InterestAge() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.interestSet)
+}
+
+// Interested returns true if the remote is interested in feed or if its interest is unknown
+func (s *session) Interested(feed string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.interest == nil {
+		return true
+	}
+	_, has := s.interest[feed]
+	return has
+}
+
+// Untold removes the notes from nf that were already sent to the remote
+func (s *session) Untold(nf ssb.NetworkFrontier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for feed, note := range nf {
+		if told, has := s.told[feed]; has && told == note {
+			delete(nf, feed)
+		}
+	}
+}
+
+// Told records the notes that were sent to the remote
+func (s *session) Told(nf ssb.NetworkFrontier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for feed, note := range nf {
+		s.told[feed] = note
+	}
+}
+
+// Touch marks the session as active
+func (s *session) Touch() {
+	s.mu.Lock()
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+}
+
+// IdleFor returns how long ago the remote sent something
+func (s *session) IdleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastActivity)
+}
+
 // Unubscribe checks to see if there is one and cancels it
 func (s *session) Unubscribe(feed refs.FeedRef) {
 	s.mu.Lock()
@@ -62,30 +159,133 @@ type Sessions struct {
 	mu   *sync.Mutex
 	open map[string]*session
 	// to be able to correctly trigger fallback on the server we need to be able to wait for incoming sessions
-	waitingFor map[string]chan<- struct{}
+	waitingFor map[string]*sessionWait
+
+	// waits that take longer than slowWait are logged, maxWait caps how long WaitFor blocks
+	slowWait time.Duration
+	maxWait  time.Duration
+
+	logger       logging.Interface
+	gauge        metrics.Gauge
+	eventCounter metrics.Counter
+
+	// peers that didn't manage to start a session and are replicated with legacy gossip instead.
+	// keyed by feed reference since the port of the address changes between connections.
+	legacy    map[string]time.Time
+	legacyTTL time.Duration
+
+	// slots caps the number of concurrent sessions, it is nil if there is no limit.
+	// sessions over the limit wait up to queueWait for a free slot.
+	slots     chan struct{}
+	queueWait time.Duration
+	queued    int
+}
+
+// ErrSessionLimit is used to turn down sessions if there is no free session slot, see WithMaxSessions
+var ErrSessionLimit = errors.New("ebt: too many concurrent sessions")
+
+// Acquire blocks until there is a free session slot and returns false if there was none within the queue wait.
+// It needs to be called before a session is started with LoopWithFormat and the slot has to be given back with Release.
+func (s *Sessions) Acquire(ctx context.Context) bool {
+	if s.slots == nil {
+		return true
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	s.mu.Lock()
+	s.queued++
+	s.updateSlotGauges()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.queued--
+		s.updateSlotGauges()
+		s.mu.Unlock()
+	}()
+
+	timeout := time.NewTimer(s.queueWait)
+	defer timeout.Stop()
+
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timeout.C:
+	case <-ctx.Done():
+	}
+	s.countEvent("ebt-session-limit")
+	return false
+}
+
+// Release frees the slot of a session that ended
+func (s *Sessions) Release() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// Full returns true if all the session slots are taken
+func (s *Sessions) Full() bool {
+	return s.slots != nil && len(s.slots) == cap(s.slots)
+}
+
+// Counts returns the number of open sessions and of the ones that wait for a free slot
+func (s *Sessions) Counts() (active, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.open), s.queued
+}
+
+// updateSlotGauges needs to be called with the lock held
+func (s *Sessions) updateSlotGauges() {
+	if s.gauge != nil {
+		s.gauge.With("part", "ebt-active").Set(float64(len(s.open)))
+		s.gauge.With("part", "ebt-queued").Set(float64(s.queued))
+	}
 }
 
 // Started registers a new session for the network address and returns it.
 // It also closes open channels in waitingFor if they exist and thus makes WaitFor() calls return.
-func (s *Sessions) Started(addr net.Addr) *session {
+func (s *Sessions) Started(addr net.Addr, sf SessionFormat) *session {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// we are using the full ip:port~pubkey notation as the map key
 	mk := addr.String()
 
-	session := newSession(addr)
+	session := newSession(addr, sf)
 
 	s.open[mk] = session
+	s.updateSlotGauges()
 
-	if c, has := s.waitingFor[mk]; has {
-		close(c)
+	if w, has := s.waitingFor[mk]; has {
+		close(w.done)
 		delete(s.waitingFor, mk)
+		s.updateWaitGauge()
 	}
 
 	return session
 }
 
+// rejected makes WaitFor calls for addr return right away, since its session was turned down with ErrSessionLimit
+func (s *Sessions) rejected(addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mk := addr.String()
+	if w, has := s.waitingFor[mk]; has {
+		w.err = ErrSessionLimit
+		close(w.done)
+		delete(s.waitingFor, mk)
+		s.updateWaitGauge()
+	}
+}
+
 // Ended notifies the session store that a session has ended.
 func (s *Sessions) Ended(addr net.Addr) {
 	s.mu.Lock()
@@ -95,10 +295,99 @@ func (s *Sessions) Ended(addr net.Addr) {
 	mk := addr.String()
 
 	delete(s.open, mk)
+	s.updateSlotGauges()
+}
+
+// MarkLegacy records that peer should be replicated using legacy gossip.
+// The decision is remembered for some time so that reconnects don't try ebt again and again.
+func (s *Sessions) MarkLegacy(peer refs.FeedRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.legacy[peer.String()] = time.Now()
+}
+
+// ClearLegacy forgets the legacy gossip decision for peer, for instance because it started an ebt session itself.
+func (s *Sessions) ClearLegacy(peer refs.FeedRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.legacy, peer.String())
 }
 
-// WaitFor returns true if addr manages to start a session before durration passes
+// UsesLegacy returns true if peer was recently marked to use legacy gossip
+func (s *Sessions) UsesLegacy(peer refs.FeedRef) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mk := peer.String()
+	when, has := s.legacy[mk]
+	if !has {
+		return false
+	}
+
+	if s.legacyTTL > 0 && time.Since(when) > s.legacyTTL {
+		delete(s.legacy, mk)
+		return false
+	}
+	return true
+}
+
+// Format returns the negotiated version and feed format of the session with addr
+func (s *Sessions) Format(addr net.Addr) (SessionFormat, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, has := s.open[addr.String()]
+	if !has {
+		return SessionFormat{}, false
+	}
+	return sess.format, true
+}
+
+// sessionWait is closed once the peer starts a session, err is set before if the session was turned down
+type sessionWait struct {
+	done  chan struct{}
+	err   error
+	since time.Time
+
+	// how many WaitForSession calls share it, guarded by the lock of Sessions
+	waiters int
+}
+
+// SessionWait describes a peer we are waiting for to start a session with us
+type SessionWait struct {
+	Addr  string
+	Since time.Time
+}
+
+// Waiting returns the addresses WaitFor is currently waiting for, the longest waiting first
+func (s *Sessions) Waiting() []SessionWait {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	waits := make([]SessionWait, 0, len(s.waitingFor))
+	for addr, w := range s.waitingFor {
+		waits = append(waits, SessionWait{Addr: addr, Since: w.since})
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i].Since.Before(waits[j].Since) })
+	return waits
+}
+
+// ErrSessionWaitTimeout is returned by WaitForSession if the peer didn't start a session in time
+var ErrSessionWaitTimeout = errors.New("ebt: peer didn't start a session")
+
+// WaitFor returns true if addr manages to start a session before durration passes.
+// Concurrent calls for the same address share the wait.
 func (s *Sessions) WaitFor(ctx context.Context, addr net.Addr, durr time.Duration) bool {
+	return s.WaitForSession(ctx, addr, durr) == nil
+}
+
+// WaitForSession is like WaitFor but says why there is no session.
+// It returns ErrSessionLimit early if the session was turned down because either side had no free slot,
+// ErrSessionWaitTimeout after durr and the error of ctx if it is canceled.
+func (s *Sessions) WaitForSession(ctx context.Context, addr net.Addr, durr time.Duration) error {
+	if s.maxWait > 0 && durr > s.maxWait {
+		durr = s.maxWait
+	}
 
 	// we are using the full ip:port~pubkey notation as the map key
 	mk := addr.String()
@@ -108,30 +397,84 @@ func (s *Sessions) WaitFor(ctx context.Context, addr net.Addr, durr time.Duratio
 	// is there already an open session?
 	if _, has := s.open[mk]; has {
 		s.mu.Unlock()
-		return true
+		return nil
 	}
 
-	if _, has := s.waitingFor[mk]; has {
-		// hm... not sure this case is realistic but
-		fmt.Printf("[warning] ebt waiting for session: already waiting for %s\n", mk)
-		return true
+	w, has := s.waitingFor[mk]
+	if !has {
+		w = &sessionWait{
+			done:  make(chan struct{}),
+			since: time.Now(),
+		}
+		s.waitingFor[mk] = w
+		s.updateWaitGauge()
 	}
-
-	c := make(chan struct{})
-	s.waitingFor[mk] = c
+	w.waiters++
 	s.mu.Unlock()
 
-	select {
+	timeout := time.NewTimer(durr)
+	defer timeout.Stop()
 
-	// we DID get a session
-	case <-c:
-		return true
+	var slow <-chan time.Time
+	if s.slowWait > 0 && s.slowWait < durr {
+		slowTimer := time.NewTimer(s.slowWait)
+		defer slowTimer.Stop()
+		slow = slowTimer.C
+	}
 
-	// we didn't get a session
-	case <-ctx.Done():
-		return false
-	case <-time.After(durr):
-		return false
+	for {
+		select {
+
+		// we DID get a session, unless it was turned down
+		case <-w.done:
+			return w.err
+
+		case <-slow:
+			slow = nil
+			if s.logger != nil {
+				level.Warn(s.logger).Log("event", "still waiting for ebt session", "addr", mk, "waited", time.Since(w.since))
+			}
+			s.countEvent("ebt-wait-slow")
+
+		// we didn't get a session
+		case <-ctx.Done():
+			s.stopWaiting(mk, w)
+			return ctx.Err()
+		case <-timeout.C:
+			s.stopWaiting(mk, w)
+			s.countEvent("ebt-wait-timeout")
+			return ErrSessionWaitTimeout
+
+		}
+	}
+}
+
+// stopWaiting removes w once the last call that shares it stopped waiting,
+// unless a session was started or another wait took its place in the meantime
+func (s *Sessions) stopWaiting(mk string, w *sessionWait) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.waiters--
+	if w.waiters > 0 {
+		return
+	}
+
+	if curr, has := s.waitingFor[mk]; has && curr == w {
+		delete(s.waitingFor, mk)
+		s.updateWaitGauge()
+	}
+}
+
+// updateWaitGauge needs to be called with the lock held
+func (s *Sessions) updateWaitGauge() {
+	if s.gauge != nil {
+		s.gauge.With("part", "ebt-waiting").Set(float64(len(s.waitingFor)))
+	}
+}
 
+func (s *Sessions) countEvent(name string) {
+	if s.eventCounter != nil {
+		s.eventCounter.With("event", name).Add(1)
 	}
 }
@@ -0,0 +1,363 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ebt
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/ssbc/go-netwrap"
+	"github.com/ssbc/go-secretstream"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+func TestWatchIdle(t *testing.T) {
+	r := require.New(t)
+
+	h := &MUXRPCHandler{idleTimeout: 40 * time.Millisecond}
+	sess := newSession(nil, DefaultFormat)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stalled := make(chan struct{})
+	go h.watchIdle(ctx, cancel, sess, stalled)
+
+	// keep the session busy for a while
+	for i := 0; i < 5; i++ {
+		time.Sleep(15 * time.Millisecond)
+		sess.Touch()
+	}
+	select {
+	case <-stalled:
+		r.FailNow("active session marked as stalled")
+	default:
+	}
+
+	select {
+	case <-stalled:
+	case <-time.After(time.Second):
+		r.FailNow("idle session not marked as stalled")
+	}
+	r.Error(ctx.Err(), "expected the session context to be canceled")
+}
+
+func TestLegacyFallbackRecord(t *testing.T) {
+	r := require.New(t)
+
+	peer, err := refs.NewFeedRefFromBytes(make([]byte, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	var s = Sessions{
+		mu:        new(sync.Mutex),
+		legacy:    make(map[string]time.Time),
+		legacyTTL: 50 * time.Millisecond,
+	}
+
+	r.False(s.UsesLegacy(peer))
+
+	s.MarkLegacy(peer)
+	r.True(s.UsesLegacy(peer))
+
+	s.ClearLegacy(peer)
+	r.False(s.UsesLegacy(peer))
+
+	s.MarkLegacy(peer)
+	time.Sleep(60 * time.Millisecond)
+	r.False(s.UsesLegacy(peer), "decision should expire")
+}
+
+func TestSessionsList(t *testing.T) {
+	r := require.New(t)
+
+	var s = Sessions{
+		mu:         new(sync.Mutex),
+		open:       make(map[string]*session),
+		waitingFor: make(map[string]*sessionWait),
+	}
+
+	peer, err := refs.NewFeedRefFromBytes(make([]byte, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	addr := netwrap.WrapAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8008}, secretstream.Addr{PubKey: peer.PubKey()})
+	sess := s.Started(addr, SessionFormat{Version: 2, Format: FormatClassic})
+	sess.Subscribed(peer, func() {})
+
+	since := time.Now()
+	s.waitingFor["waiting"] = &sessionWait{done: make(chan struct{}), since: since}
+
+	list := s.List()
+	r.Len(list, 2)
+
+	r.Equal(addr.String(), list[0].Addr)
+	r.NotNil(list[0].Peer)
+	r.True(list[0].Peer.Equal(peer))
+	r.Equal(2, list[0].Version)
+	r.Equal(1, list[0].Feeds)
+	r.False(list[0].Waiting)
+
+	r.Equal("waiting", list[1].Addr)
+	r.True(list[1].Waiting)
+	r.Equal(since, list[1].WaitingSince)
+
+	s.Ended(addr)
+	r.Len(s.List(), 1)
+}
+
+func TestSessionWait(t *testing.T) {
+	r := require.New(t)
+
+	gauge := newPartGauge()
+	var s = Sessions{
+		mu:         new(sync.Mutex),
+		open:       make(map[string]*session),
+		waitingFor: make(map[string]*sessionWait),
+		gauge:      gauge,
+		maxWait:    time.Second,
+	}
+
+	peer, err := refs.NewFeedRefFromBytes(make([]byte, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	addr := netwrap.WrapAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8008}, secretstream.Addr{PubKey: peer.PubKey()})
+
+	// a peer that never starts a session
+	r.False(s.WaitFor(context.Background(), addr, 20*time.Millisecond))
+	r.Len(s.Waiting(), 0, "the wait should be cleaned up after the timeout")
+	r.EqualValues(0, gauge.get("ebt-waiting"))
+
+	// waits for the same peer are shared and all return once the session starts
+	var (
+		wg      sync.WaitGroup
+		started = make(chan bool, 2)
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- s.WaitFor(context.Background(), addr, time.Hour)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return len(s.Waiting()) == 1 }, time.Second, 5*time.Millisecond)
+	r.Equal(addr.String(), s.Waiting()[0].Addr)
+	r.EqualValues(1, gauge.get("ebt-waiting"))
+
+	s.Started(addr, DefaultFormat)
+	wg.Wait()
+	r.True(<-started)
+	r.True(<-started)
+	r.Len(s.Waiting(), 0)
+	r.EqualValues(0, gauge.get("ebt-waiting"))
+
+	// waits are capped by maxWait
+	s.Ended(addr)
+	s.maxWait = 20 * time.Millisecond
+	start := time.Now()
+	r.False(s.WaitFor(context.Background(), addr, time.Hour))
+	r.Less(time.Since(start), time.Second)
+}
+
+func TestSessionWaitCancel(t *testing.T) {
+	r := require.New(t)
+
+	gauge := newPartGauge()
+	var s = Sessions{
+		mu:         new(sync.Mutex),
+		open:       make(map[string]*session),
+		waitingFor: make(map[string]*sessionWait),
+		gauge:      gauge,
+		maxWait:    time.Minute,
+	}
+
+	peer, err := refs.NewFeedRefFromBytes(make([]byte, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	addr := netwrap.WrapAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8008}, secretstream.Addr{PubKey: peer.PubKey()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	canceled := make(chan error, 1)
+	go func() {
+		canceled <- s.WaitForSession(ctx, addr, time.Hour)
+	}()
+	waited := make(chan error, 1)
+	go func() {
+		waited <- s.WaitForSession(context.Background(), addr, time.Hour)
+	}()
+
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w, has := s.waitingFor[addr.String()]
+		return has && w.waiters == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// one of them gives up, the other one still waits
+	cancel()
+	r.ErrorIs(<-canceled, context.Canceled)
+	r.Len(s.Waiting(), 1)
+	r.EqualValues(1, gauge.get("ebt-waiting"))
+
+	s.Started(addr, DefaultFormat)
+	select {
+	case err := <-waited:
+		r.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("the remaining wait didn't return once the session started")
+	}
+	r.Len(s.Waiting(), 0)
+	r.EqualValues(0, gauge.get("ebt-waiting"))
+}
+
+func TestSessionLimit(t *testing.T) {
+	r := require.New(t)
+
+	gauge := newPartGauge()
+	h := &MUXRPCHandler{
+		Sessions: Sessions{
+			mu:         new(sync.Mutex),
+			open:       make(map[string]*session),
+			waitingFor: make(map[string]*sessionWait),
+			gauge:      gauge,
+		},
+	}
+	WithMaxSessions(1, 50*time.Millisecond)(h)
+	s := &h.Sessions
+
+	mkAddr := func(b byte) net.Addr {
+		peer, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{b}, 32), refs.RefAlgoFeedSSB1)
+		r.NoError(err)
+		return netwrap.WrapAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8008}, secretstream.Addr{PubKey: peer.PubKey()})
+	}
+	first, second := mkAddr(1), mkAddr(2)
+
+	ctx := context.Background()
+	r.True(s.Acquire(ctx))
+	s.Started(first, DefaultFormat)
+	r.True(s.Full())
+	r.EqualValues(1, gauge.get("ebt-active"))
+
+	// no slot frees up in time
+	r.False(s.Acquire(ctx))
+	active, queued := s.Counts()
+	r.Equal(1, active)
+	r.Equal(0, queued)
+
+	// a queued session gets the slot once the other one ends
+	got := make(chan bool)
+	go func() { got <- s.Acquire(ctx) }()
+	r.Eventually(func() bool {
+		_, queued := s.Counts()
+		return queued == 1
+	}, time.Second, time.Millisecond)
+	r.EqualValues(1, gauge.get("ebt-queued"))
+
+	s.Ended(first)
+	s.Release()
+	r.True(<-got)
+	r.EqualValues(0, gauge.get("ebt-queued"))
+	r.EqualValues(0, gauge.get("ebt-active"))
+
+	// a rejected session ends the wait of the negotiation right away
+	go func() {
+		for len(s.Waiting()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		s.rejected(second)
+	}()
+	start := time.Now()
+	r.False(s.WaitFor(ctx, second, time.Hour))
+	r.Less(time.Since(start), time.Second)
+
+	s.Release()
+	r.False(s.Full())
+}
+
+// partGauge keeps the values per part label
+type partGauge struct {
+	mu   *sync.Mutex
+	part string
+	vals map[string]float64
+}
+
+func newPartGauge() *partGauge {
+	return &partGauge{mu: new(sync.Mutex), vals: make(map[string]float64)}
+}
+
+func (g *partGauge) With(lvs ...string) metrics.Gauge {
+	for i := 0; i+1 < len(lvs); i += 2 {
+		if lvs[i] == "part" {
+			return &partGauge{mu: g.mu, part: lvs[i+1], vals: g.vals}
+		}
+	}
+	return g
+}
+
+func (g *partGauge) Set(v float64) {
+	g.mu.Lock()
+	g.vals[g.part] = v
+	g.mu.Unlock()
+}
+
+func (g *partGauge) Add(v float64) {
+	g.mu.Lock()
+	g.vals[g.part] += v
+	g.mu.Unlock()
+}
+
+func (g *partGauge) get(part string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.vals[part]
+}
+
+func TestSessionNotes(t *testing.T) {
+	r := require.New(t)
+
+	feedA, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	feedB, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	sess := newSession(nil, DefaultFormat)
+
+	// unknown interest doesn't filter
+	r.True(sess.Interested(feedA.String()))
+
+	added := sess.SetInterest([]refs.FeedRef{feedA})
+	r.Equal([]string{feedA.String()}, added)
+	r.True(sess.Interested(feedA.String()))
+	r.False(sess.Interested(feedB.String()))
+
+	added = sess.SetInterest([]refs.FeedRef{feedA, feedB})
+	r.Equal([]string{feedB.String()}, added)
+
+	// notes are only sent again if they changed
+	sess.Told(ssb.NetworkFrontier{
+		feedA.String(): ssb.Note{Seq: 3, Replicate: true, Receive: true},
+	})
+
+	nf := ssb.NetworkFrontier{
+		feedA.String(): ssb.Note{Seq: 3, Replicate: true, Receive: true},
+		feedB.String(): ssb.Note{Seq: 1, Replicate: true, Receive: true},
+	}
+	sess.Untold(nf)
+	r.Len(nf, 1)
+	r.Contains(nf, feedB.String())
+
+	nf = ssb.NetworkFrontier{
+		feedA.String(): ssb.Note{Seq: 4, Replicate: true, Receive: true},
+	}
+	sess.Untold(nf)
+	r.Len(nf, 1)
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package feed exposes the live stream of the messages of a single feed as feed.tail
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/transform"
+)
+
+// Tailer streams the messages of a feed, as they are received
+type Tailer interface {
+	FeedTail(feed refs.FeedRef, seq int64) (luigi.Source, error)
+}
+
+// TailArgs are the arguments of feed.tail
+type TailArgs struct {
+	ID string `json:"id"`
+
+	// Seq is the sequence of the first message, zero only streams the messages that are received from now on
+	Seq int64 `json:"seq"`
+
+	// Keys wraps the messages in {key, value, timestamp} like createLogStream does
+	Keys bool `json:"keys"`
+}
+
+type plugin struct {
+	h muxrpc.Handler
+}
+
+// New returns the plugin for feed.tail, backed by t
+func New(i logging.Interface, t Tailer) ssb.Plugin {
+	mux := typemux.New(i)
+
+	mux.RegisterSource(muxrpc.Method{"feed", "tail"}, typemux.SourceFunc(func(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+		var args []TailArgs
+		if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+			return fmt.Errorf("feed: invalid arguments: %w", err)
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("feed: expected one argument got %d", len(args))
+		}
+
+		feed, err := refs.ParseFeedRef(args[0].ID)
+		if err != nil {
+			return fmt.Errorf("feed: invalid feed reference: %w", err)
+		}
+
+		src, err := t.FeedTail(feed, args[0].Seq)
+		if err != nil {
+			return err
+		}
+
+		if err := luigi.Pump(ctx, transform.NewKeyValueWrapper(snk, args[0].Keys), src); err != nil {
+			return fmt.Errorf("feed: failed to pump messages: %w", err)
+		}
+		return snk.Close()
+	}))
+
+	return plugin{h: &mux}
+}
+
+func (p plugin) Name() string            { return "feed" }
+func (p plugin) Method() muxrpc.Method   { return muxrpc.Method{"feed"} }
+func (p plugin) Handler() muxrpc.Handler { return p.h }
@@ -22,6 +22,8 @@ import (
   isBlocking: 'async',
   hops: 'source',
   blocks: 'source',
+  mutuals: 'source', (go-ssb only)
+  subgraph: 'async', (go-ssb only)
 
 */
 
@@ -36,7 +38,29 @@ func checkAndLog(log logging.Interface, err error) {
 	}
 }
 
-func New(log logging.Interface, self refs.FeedRef, b graph.Builder) ssb.Plugin {
+// Option changes the defaults of the plugin
+type Option func(*options)
+
+type options struct {
+	replicationHops func() uint
+}
+
+// WithReplicationHops passes the hops setting of the bot (0 means only direct follows).
+// It is read on every friends.subgraph call that asks for the replicated range, so it can change while running.
+func WithReplicationHops(hops func() uint) Option {
+	return func(o *options) {
+		o.replicationHops = hops
+	}
+}
+
+func New(log logging.Interface, self refs.FeedRef, b graph.Builder, opts ...Option) ssb.Plugin {
+	o := options{
+		replicationHops: func() uint { return 0 },
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	rootHdlr := typemux.New(log)
 
 	rootHdlr.RegisterAsync(muxrpc.Method{"friends", "isFollowing"}, isFollowingH{
@@ -57,12 +81,26 @@ func New(log logging.Interface, self refs.FeedRef, b graph.Builder) ssb.Plugin {
 		self:    self,
 	})
 
+	rootHdlr.RegisterSource(muxrpc.Method{"friends", "mutuals"}, mutualsSrc{
+		log:     log,
+		builder: b,
+		self:    self,
+	})
+
 	rootHdlr.RegisterSource(muxrpc.Method{"friends", "hops"}, hopsSrc{
 		log:     log,
 		builder: b,
 		self:    self,
 	})
 
+	rootHdlr.RegisterAsync(muxrpc.Method{"friends", "subgraph"}, subgraphH{
+		log:     log,
+		builder: b,
+		self:    self,
+
+		replicationHops: o.replicationHops,
+	})
+
 	rootHdlr.RegisterAsync(muxrpc.Method{"friends", "plotsvg"}, plotSVGHandler{
 		log:     log,
 		builder: b,
@@ -112,3 +112,44 @@ func (h hopsSrc) HandleSource(ctx context.Context, req *muxrpc.Request, snk *mux
 
 	return snk.Close()
 }
+
+type mutualsSrc struct {
+	self refs.FeedRef
+
+	log log.Logger
+
+	builder graph.Builder
+}
+
+func (h mutualsSrc) HandleSource(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+	type argT struct {
+		Who refs.FeedRef
+	}
+	var args []argT
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+		return fmt.Errorf("invalid argument on mutuals call: %w", err)
+	}
+
+	var who refs.FeedRef
+	if len(args) != 1 {
+		who = h.self
+	} else {
+		who = args[0].Who
+	}
+
+	g, err := h.builder.Build()
+	if err != nil {
+		return err
+	}
+
+	snk.SetEncoding(muxrpc.TypeJSON)
+	enc := json.NewEncoder(snk)
+
+	for i, v := range g.Mutuals(who) {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("mutuals: failed to send item %d: %w", i, err)
+		}
+	}
+
+	return snk.Close()
+}
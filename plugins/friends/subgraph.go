@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package friends
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/graph"
+	"go.mindeco.de/log"
+)
+
+// SubgraphArgs are the arguments for friends.subgraph
+type SubgraphArgs struct {
+	// Center defaults to the feed of the bot
+	Center *refs.FeedRef `json:"center,omitempty"`
+	Hops   uint          `json:"hops"`
+
+	// ReplicationHops walks as far as the bot replicates instead of Hops, see WithReplicationHops.
+	ReplicationHops bool `json:"replicationHops,omitempty"`
+
+	// Format is either json (the default), adjacency (a list of the relations of each feed) or dot
+	Format string `json:"format,omitempty"`
+}
+
+type subgraphH struct {
+	self refs.FeedRef
+
+	replicationHops func() uint
+
+	log log.Logger
+
+	builder graph.Builder
+}
+
+func (h subgraphH) HandleAsync(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	var args []SubgraphArgs
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+		return nil, fmt.Errorf("invalid argument on subgraph call: %w", err)
+	}
+
+	var arg SubgraphArgs
+	if len(args) == 1 {
+		arg = args[0]
+	}
+
+	center := h.self
+	if arg.Center != nil {
+		center = *arg.Center
+	}
+
+	g, err := h.builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	hops := arg.Hops
+	if arg.ReplicationHops {
+		// hops 0 are the direct follows, which is one follow edge away
+		hops = h.replicationHops() + 1
+	}
+
+	sub := g.Subgraph(center, int(hops))
+
+	switch arg.Format {
+	case "", "json":
+		var buf bytes.Buffer
+		if err := sub.Save(&buf); err != nil {
+			return nil, err
+		}
+		return json.RawMessage(buf.Bytes()), nil
+
+	case "adjacency":
+		return sub.AdjacencyList(), nil
+
+	case "dot":
+		dot, err := sub.MarshalDOT()
+		if err != nil {
+			return nil, err
+		}
+		return string(dot), nil
+
+	default:
+		return nil, fmt.Errorf("subgraph: unsupported format %q", arg.Format)
+	}
+}
@@ -49,6 +49,10 @@ func (h handler) HandleConnect(ctx context.Context, e muxrpc.Endpoint) {}
 type Option struct {
 	ID      refs.MessageRef `json:"id"`
 	Private bool            `json:"private"`
+
+	// Raw returns the value of the message as a string, with the exact bytes that were signed.
+	// Private is ignored then.
+	Raw bool `json:"raw"`
 }
 
 func (h handler) HandleCall(ctx context.Context, req *muxrpc.Request) {
@@ -83,25 +87,44 @@ func (h handler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 		req.CloseWithError(fmt.Errorf("failed to load message: %w", err))
 		return
 	}
+
+	if o.Raw {
+		// a string is written as it is, json would be re-encoded
+		err = req.Return(ctx, string(msg.ValueContentJSON()))
+		if err != nil {
+			log.Printf("get(%s): failed? to return raw message: %s", o.ID.String(), err)
+		}
+		return
+	}
+
+	kv, err := keyValue(h.unboxer, msg, o.Private)
+	if err != nil {
+		req.CloseWithError(err)
+		return
+	}
+
+	err = req.Return(ctx, kv)
+	if err != nil {
+		log.Printf("get(%s): failed? to return message: %s", o.ID.String(), err)
+	}
+}
+
+// keyValue returns the message as it is returned by get, decrypted if decrypt is set and it is for us
+func keyValue(unboxer *private.Manager, msg refs.Message, decrypt bool) (refs.KeyValueRaw, error) {
 	var kv refs.KeyValueRaw
 	kv.Key_ = msg.Key()
 	kv.Value = *msg.ValueContent()
 
-	if o.Private {
-		cleartext, err := h.unboxer.DecryptMessage(msg)
+	if decrypt {
+		cleartext, err := unboxer.DecryptMessage(msg)
 		if err == nil {
 			kv.Value.Meta = make(map[string]interface{}, 1)
 			kv.Value.Meta["private"] = true
 
 			kv.Value.Content = cleartext
 		} else if err != private.ErrNotBoxed {
-			req.CloseWithError(fmt.Errorf("failed to decrypt message: %w", err))
-			return
+			return kv, fmt.Errorf("failed to decrypt message: %w", err)
 		}
 	}
-
-	err = req.Return(ctx, kv)
-	if err != nil {
-		log.Printf("get(%s): failed? to return message: %s", o.ID.String(), err)
-	}
+	return kv, nil
 }
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package get
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/private"
+)
+
+type manyPlugin struct {
+	h muxrpc.Handler
+}
+
+func (p manyPlugin) Name() string            { return "getMany" }
+func (p manyPlugin) Method() muxrpc.Method   { return muxrpc.Method{"getMany"} }
+func (p manyPlugin) Handler() muxrpc.Handler { return p.h }
+
+// NewMany returns the getMany plugin, which returns multiple messages in one call.
+// They are in the order of the requested ids, with null for the ones that aren't stored.
+func NewMany(g ssb.Getter, unboxer *private.Manager) ssb.Plugin {
+	return manyPlugin{
+		h: manyHandler{
+			get:     g,
+			unboxer: unboxer,
+		},
+	}
+}
+
+type manyHandler struct {
+	get     ssb.Getter
+	unboxer *private.Manager
+}
+
+func (manyHandler) Handled(m muxrpc.Method) bool { return m.String() == "getMany" }
+
+func (manyHandler) HandleConnect(ctx context.Context, e muxrpc.Endpoint) {}
+
+// ManyOption are the arguments of getMany. A plain list of ids can be passed instead.
+type ManyOption struct {
+	IDs     []refs.MessageRef `json:"ids"`
+	Private bool              `json:"private"`
+}
+
+func (h manyHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
+	var args []json.RawMessage
+	err := json.Unmarshal(req.RawArgs, &args)
+	if err != nil {
+		req.CloseWithError(err)
+		return
+	}
+
+	if n := len(args); n != 1 {
+		req.CloseWithError(fmt.Errorf("invalid argument count. Wanted 1 got %d", n))
+		return
+	}
+
+	var o ManyOption
+	optErr := json.Unmarshal(args[0], &o)
+	if optErr != nil {
+		listErr := json.Unmarshal(args[0], &o.IDs)
+		if listErr != nil {
+			req.CloseWithError(fmt.Errorf("failed to parse argument as object (%s) and as list (%s)", optErr, listErr))
+			return
+		}
+	}
+
+	msgs := make([]*refs.KeyValueRaw, len(o.IDs))
+	for i, ref := range o.IDs {
+		msg, err := h.get.Get(ref)
+		if err != nil {
+			if errors.Is(err, ssb.ErrMessageNotFound) {
+				continue
+			}
+			req.CloseWithError(fmt.Errorf("failed to load message %s: %w", ref.ShortSigil(), err))
+			return
+		}
+
+		kv, err := keyValue(h.unboxer, msg, o.Private)
+		if err != nil {
+			req.CloseWithError(err)
+			return
+		}
+		msgs[i] = &kv
+	}
+
+	err = req.Return(ctx, msgs)
+	if err != nil {
+		log.Printf("getMany(%d): failed? to return messages: %s", len(o.IDs), err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/ssbc/go-muxrpc/v2"
@@ -39,6 +40,11 @@ func (h *LegacyGossip) FetchAll(
 		feeds[i], feeds[j] = feeds[j], feeds[i]
 	})
 
+	// prioritized feeds go first, the rest stays shuffled
+	if h.prioritizer != nil {
+		h.prioritizer.SortByPriority(feeds)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -65,7 +71,7 @@ func (h *LegacyGossip) FetchAll(
 func (h *LegacyGossip) startWorkers(ctx context.Context, feedCh <-chan refs.FeedRef, edp muxrpc.Endpoint) *errgroup.Group {
 	errGroup, ctx := errgroup.WithContext(ctx)
 
-	for i := 0; i < h.numberOfConcurrentReplicationsPerPeer; i++ {
+	for i := 0; i < h.concurrentReplicationsPerPeer(); i++ {
 		errGroup.Go(
 			func() error {
 				for {
@@ -90,10 +96,8 @@ func (h *LegacyGossip) startWorkers(ctx context.Context, feedCh <-chan refs.Feed
 }
 
 func (h *LegacyGossip) workFeed(ctx context.Context, edp muxrpc.Endpoint, ref refs.FeedRef, withLive bool) error {
-	select {
-	case <-h.tokenPool.GetToken():
-	case <-ctx.Done():
-		return ctx.Err()
+	if err := h.tokenPool.GetToken(ctx); err != nil {
+		return err
 	}
 	defer h.tokenPool.ReturnToken()
 
@@ -191,6 +195,9 @@ func (h *LegacyGossip) fetchFeed(
 
 		err = snk.Verify(buf.Bytes())
 		if err != nil {
+			if h.sysCtr != nil {
+				h.sysCtr.With("event", "gossip-verify-failed").Add(1)
+			}
 			return err
 		}
 		buf.Reset()
@@ -204,25 +211,60 @@ func (h *LegacyGossip) fetchFeed(
 	return nil
 }
 
+// TokenPool limits how many feeds are fetched at the same time
 type TokenPool struct {
-	ch chan struct{}
+	mu    sync.Mutex
+	limit int
+	used  int
+
+	// closed and replaced when a token is returned or the limit changes
+	changed chan struct{}
 }
 
 func NewTokenPool(n int) *TokenPool {
-	ch := make(chan struct{}, n)
-	for i := 0; i < n; i++ {
-		ch <- struct{}{}
-	}
-
 	return &TokenPool{
-		ch: ch,
+		limit:   n,
+		changed: make(chan struct{}),
 	}
 }
 
-func (p *TokenPool) GetToken() <-chan struct{} {
-	return p.ch
+// GetToken blocks until a token is free or ctx is canceled
+func (p *TokenPool) GetToken(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		if p.used < p.limit {
+			p.used++
+			p.mu.Unlock()
+			return nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
 func (p *TokenPool) ReturnToken() {
-	p.ch <- struct{}{}
+	p.mu.Lock()
+	p.used--
+	p.notify()
+	p.mu.Unlock()
+}
+
+// Resize changes the number of tokens. When it shrinks, tokens that are in use are not taken back
+// but no new ones are handed out until enough of them are returned.
+func (p *TokenPool) Resize(n int) {
+	p.mu.Lock()
+	p.limit = n
+	p.notify()
+	p.mu.Unlock()
+}
+
+func (p *TokenPool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
 }
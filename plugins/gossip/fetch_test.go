@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package gossip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenPoolResize(t *testing.T) {
+	r := require.New(t)
+
+	ctx := context.Background()
+	pool := NewTokenPool(1)
+	r.NoError(pool.GetToken(ctx))
+
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	r.ErrorIs(pool.GetToken(short), context.DeadlineExceeded, "pool should be empty")
+
+	// growing the pool wakes up waiting fetches
+	got := make(chan error)
+	go func() { got <- pool.GetToken(ctx) }()
+	pool.Resize(2)
+	r.NoError(<-got)
+
+	// shrinking doesn't take back tokens that are in use
+	pool.Resize(1)
+	pool.ReturnToken()
+
+	short, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	r.ErrorIs(pool.GetToken(short), context.DeadlineExceeded, "one token is still in use")
+
+	pool.ReturnToken()
+	r.NoError(pool.GetToken(ctx))
+}
@@ -47,7 +47,8 @@ type LegacyGossip struct {
 
 	hmacSec HMACSecret
 
-	promisc bool // ask for remote feed even if it's not on owns fetch list
+	settingsMu *sync.Mutex
+	promisc    bool // ask for remote feed even if it's not on owns fetch list
 
 	enableLiveStreaming bool
 
@@ -65,6 +66,42 @@ type LegacyGossip struct {
 
 	numberOfConcurrentReplicationsPerPeer int
 	tokenPool                             *TokenPool
+
+	prioritizer Prioritizer
+}
+
+// SetPromisc changes if feeds are exchanged with peers that aren't on the replication list.
+// It takes effect for the next connection.
+func (g *LegacyGossip) SetPromisc(yes bool) {
+	g.settingsMu.Lock()
+	g.promisc = yes
+	g.settingsMu.Unlock()
+}
+
+// SetConcurrentReplications changes how many feeds are fetched from one peer and from all peers at the same time.
+// Zero keeps the current value. The per peer number applies to the next connection, the total right away.
+func (g *LegacyGossip) SetConcurrentReplications(perPeer, total int) {
+	g.settingsMu.Lock()
+	if perPeer > 0 {
+		g.numberOfConcurrentReplicationsPerPeer = perPeer
+	}
+	g.settingsMu.Unlock()
+
+	if total > 0 {
+		g.tokenPool.Resize(total)
+	}
+}
+
+func (g *LegacyGossip) isPromisc() bool {
+	g.settingsMu.Lock()
+	defer g.settingsMu.Unlock()
+	return g.promisc
+}
+
+func (g *LegacyGossip) concurrentReplicationsPerPeer() int {
+	g.settingsMu.Lock()
+	defer g.settingsMu.Unlock()
+	return g.numberOfConcurrentReplicationsPerPeer
 }
 
 func (LegacyGossip) Handled(m muxrpc.Method) bool { return m.String() == "createHistoryStream" }
@@ -88,7 +125,7 @@ func (g *LegacyGossip) StartLegacyFetching(ctx context.Context, e muxrpc.Endpoin
 
 	info := log.With(g.Info, "remote", remoteRef.ShortSigil(), "event", "gossiprx", "live", g.enableLiveStreaming)
 
-	if g.promisc {
+	if g.isPromisc() {
 		hasCallee, err := multilog.Has(g.UserFeeds, storedrefs.Feed(remoteRef))
 		if err != nil {
 			info.Log("handleConnect", "multilog.Has(callee)", "err", err)
@@ -193,7 +230,7 @@ func (g *LegacyGossip) HandleCall(
 		// dbgLog = level.Warn(hlog)
 
 		// skip this check for self/master or in promisc mode (talk to everyone)
-		if !(g.Id.Equal(remote) || g.promisc) {
+		if !(g.Id.Equal(remote) || g.isPromisc()) {
 			blocks := g.WantList.BlockList()
 
 			if blocks.Has(query.ID) {
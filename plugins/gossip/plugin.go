@@ -7,6 +7,7 @@ package gossip
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/go-kit/kit/metrics"
 	"github.com/ssbc/go-muxrpc/v2"
@@ -31,6 +32,11 @@ type WithLive bool
 type NumberOfConcurrentReplicationsPerPeer int
 type NumberOfConcurrentReplications int
 
+// Prioritizer orders the feeds that are fetched from a peer, the first ones are requested first
+type Prioritizer interface {
+	SortByPriority([]refs.FeedRef)
+}
+
 const defaultNumberOfConcurrentReplicationsPerPeer = 5
 const defaultNumberOfConcurrentReplications = 10
 
@@ -65,6 +71,7 @@ func NewFetcher(
 
 		enableLiveStreaming: true,
 
+		settingsMu:                            new(sync.Mutex),
 		numberOfConcurrentReplicationsPerPeer: defaultNumberOfConcurrentReplicationsPerPeer,
 		tokenPool:                             NewTokenPool(defaultNumberOfConcurrentReplications),
 	}
@@ -85,6 +92,8 @@ func NewFetcher(
 			h.numberOfConcurrentReplicationsPerPeer = int(v)
 		case NumberOfConcurrentReplications:
 			h.tokenPool = NewTokenPool(int(v))
+		case Prioritizer:
+			h.prioritizer = v
 		default:
 			level.Warn(log).Log("event", "unhandled gossip option", "i", i, "type", fmt.Sprintf("%T", o))
 		}
@@ -115,6 +124,7 @@ func NewServer(
 		Info:    log,
 		rootCtx: ctx,
 
+		settingsMu:                            new(sync.Mutex),
 		numberOfConcurrentReplicationsPerPeer: defaultNumberOfConcurrentReplicationsPerPeer,
 		tokenPool:                             NewTokenPool(defaultNumberOfConcurrentReplications),
 	}
@@ -135,6 +145,8 @@ func NewServer(
 			h.numberOfConcurrentReplicationsPerPeer = int(v)
 		case NumberOfConcurrentReplications:
 			h.tokenPool = NewTokenPool(int(v))
+		case Prioritizer:
+			// only used for fetching
 		default:
 			level.Warn(log).Log("event", "unhandled gossip option", "i", i, "type", fmt.Sprintf("%T", o))
 		}
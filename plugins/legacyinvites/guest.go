@@ -50,21 +50,9 @@ func (h acceptHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 	var st inviteState
 	kvKey := append(dbKeyPrefix, guestRef.PubKey()...)
 	err = h.service.kv.Update(func(txn *badger.Txn) error {
-		has, err := txn.Get(kvKey)
+		st, err = h.service.loadUsable(txn, kvKey)
 		if err != nil {
-			return fmt.Errorf("invite/kv: failed get guest remote from KV (%w)", err)
-		}
-
-		err = has.Value(func(val []byte) error {
-			return json.Unmarshal(val, &st)
-		})
-		if err != nil {
-			return fmt.Errorf("invite/kv: failed to probe new key (%w)", err)
-		}
-
-		if st.Used >= st.Uses {
-			txn.Delete(kvKey)
-			return fmt.Errorf("invite/kv: invite depleted")
+			return fmt.Errorf("invite/kv: %w", err)
 		}
 
 		// count uses
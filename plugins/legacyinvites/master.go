@@ -10,9 +10,12 @@ import (
 	"fmt"
 
 	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+
+	"github.com/ssbc/go-ssb/invite"
 )
 
-// supplies create, use and other managment calls (maybe list and delete?)
+// supplies create, revoke and list
 type masterPlug struct {
 	service *Service
 }
@@ -26,9 +29,15 @@ func (p masterPlug) Method() muxrpc.Method {
 }
 
 func (p masterPlug) Handler() muxrpc.Handler {
-	return createHandler{
-		service: p.service,
-	}
+	mux := typemux.New(p.service.logger)
+
+	mux.RegisterAsync(muxrpc.Method{"invite", "create"}, createHandler{service: p.service})
+	mux.RegisterAsync(muxrpc.Method{"invite", "revoke"}, revokeHandler{service: p.service})
+	mux.RegisterAsync(muxrpc.Method{"invite", "list"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return p.service.List()
+	}))
+
+	return &mux
 }
 
 type createHandler struct {
@@ -43,36 +52,54 @@ type CreateArguments struct {
 	Note string `json:"note,omitempty"`
 }
 
-func (createHandler) Handled(m muxrpc.Method) bool { return m.String() == "invite.create" }
-
-func (h createHandler) HandleConnect(ctx context.Context, e muxrpc.Endpoint) {}
-
-func (h createHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
+func (h createHandler) HandleAsync(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
 	// parse passed arguments
 	var args []CreateArguments
 
 	if err := json.Unmarshal(req.RawArgs, &args); err != nil {
-		req.CloseWithError(fmt.Errorf("unable to receive invite create payload: %w", err))
-		return
+		return nil, fmt.Errorf("unable to receive invite create payload: %w", err)
 	}
 	if len(args) == 0 {
-		req.CloseWithError(fmt.Errorf("missing invite create payload?"))
-		return
+		return nil, fmt.Errorf("missing invite create payload?")
 	}
 
 	a := args[0]
 
 	if a.Uses == 0 {
-		req.CloseWithError(fmt.Errorf("cant create invite with zero uses"))
-		return
+		return nil, fmt.Errorf("cant create invite with zero uses")
 	}
 
 	inv, err := h.service.Create(a.Uses, a.Note)
 	if err != nil {
-		req.CloseWithError(fmt.Errorf("failed to create invite"))
-		return
+		return nil, fmt.Errorf("failed to create invite")
 	}
 
-	req.Return(ctx, inv.String())
 	h.service.logger.Log("invite", "created", "uses", a.Uses)
+	return inv.String(), nil
+}
+
+type revokeHandler struct {
+	service *Service
+}
+
+func (h revokeHandler) HandleAsync(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	var args []string
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments (%w)", err)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected one invite as argument")
+	}
+
+	tok, err := invite.ParseLegacyToken(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse invite: %w", err)
+	}
+
+	if err := h.service.Revoke(tok); err != nil {
+		return nil, err
+	}
+
+	h.service.logger.Log("invite", "revoked")
+	return "revoked", nil
 }
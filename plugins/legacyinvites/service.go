@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ssbc/go-muxrpc/v2"
@@ -35,8 +36,32 @@ type Service struct {
 	replicator ssb.Replicator
 
 	kv *badger.DB
+
+	// expiry is how long new invites are valid, zero means forever
+	expiry time.Duration
+}
+
+// Option changes the behavior of the service
+type Option func(*Service)
+
+// WithExpiry makes new invites expire after d. Invites that were created before keep their expiry.
+func WithExpiry(d time.Duration) Option {
+	return func(s *Service) {
+		s.expiry = d
+	}
 }
 
+var (
+	// ErrInviteExpired is returned if an invite is used after its expiry
+	ErrInviteExpired = errors.New("invite: expired")
+
+	// ErrInviteDepleted is returned if an invite was used as often as it was created for
+	ErrInviteDepleted = errors.New("invite: depleted")
+
+	// ErrInviteRevoked is returned if an invite is used after it was revoked
+	ErrInviteRevoked = errors.New("invite: revoked")
+)
+
 // GuestHandler returns the handler to accept invites
 func (s *Service) GuestHandler() muxrpc.Handler {
 	return acceptHandler{service: s}
@@ -51,24 +76,10 @@ func (s *Service) MasterPlugin() ssb.Plugin {
 func (s *Service) Authorize(to refs.FeedRef) error {
 	kvKey := append(dbKeyPrefix, to.PubKey()...)
 	err := s.kv.Update(func(txn *badger.Txn) error {
-		has, err := txn.Get(kvKey)
-		if err != nil {
-			return fmt.Errorf("invite/auth: failed get guest remote from KV (%w)", err)
-		}
-
-		var st inviteState
-		err = has.Value(func(val []byte) error {
-			return json.Unmarshal(val, &st)
-		})
+		_, err := s.loadUsable(txn, kvKey)
 		if err != nil {
-			return fmt.Errorf("invite/auth: failed to probe new key (%w)", err)
-		}
-
-		if st.Used >= st.Uses {
-			txn.Delete(kvKey)
-			return fmt.Errorf("invite/auth: invite depleeted")
+			return fmt.Errorf("invite/auth: %w", err)
 		}
-
 		return nil
 	})
 	if err != nil {
@@ -78,6 +89,40 @@ func (s *Service) Authorize(to refs.FeedRef) error {
 	return nil
 }
 
+// loadUsable returns the state of the invite stored under kvKey.
+// Expired and depleted invites are deleted and ErrInviteExpired or ErrInviteDepleted is returned.
+func (s *Service) loadUsable(txn *badger.Txn, kvKey []byte) (inviteState, error) {
+	var st inviteState
+
+	has, err := txn.Get(kvKey)
+	if err != nil {
+		return st, fmt.Errorf("failed get guest remote from KV (%w)", err)
+	}
+
+	err = has.Value(func(val []byte) error {
+		return json.Unmarshal(val, &st)
+	})
+	if err != nil {
+		return st, fmt.Errorf("failed to probe new key (%w)", err)
+	}
+
+	if st.Revoked {
+		return st, ErrInviteRevoked
+	}
+
+	if st.Expires != 0 && time.Now().Unix() >= st.Expires {
+		txn.Delete(kvKey)
+		return st, ErrInviteExpired
+	}
+
+	if st.Used >= st.Uses {
+		txn.Delete(kvKey)
+		return st, ErrInviteDepleted
+	}
+
+	return st, nil
+}
+
 var _ ssb.Authorizer = (*Service)(nil)
 
 var dbKeyPrefix = []byte("invites:")
@@ -92,9 +137,10 @@ func New(
 	rlog margaret.Log,
 	rep ssb.Replicator,
 	db *badger.DB,
+	opts ...Option,
 ) (*Service, error) {
 
-	return &Service{
+	s := &Service{
 		logger: logger,
 
 		self:    self,
@@ -106,7 +152,13 @@ func New(
 		replicator: rep,
 
 		kv: db,
-	}, nil
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s, nil
 }
 
 // Create creates a new invite with a note attached and a number of uses before it expires.
@@ -119,11 +171,11 @@ func (s *Service) Create(uses uint, note string) (*invite.Token, error) {
 		for {
 			rand.Read(inv.Seed[:])
 
-			inviteKeyPair, err := ssb.NewKeyPair(bytes.NewReader(inv.Seed[:]), refs.RefAlgoFeedSSB1)
+			var err error
+			dbKey, err = guestKey(inv)
 			if err != nil {
-				return fmt.Errorf("invite/create: generate seeded keypair (%w)", err)
+				return fmt.Errorf("invite/create: %w", err)
 			}
-			dbKey = append(dbKeyPrefix, inviteKeyPair.ID().PubKey()...)
 			_, err = txn.Get(dbKey)
 			if err != nil {
 				if errors.Is(err, badger.ErrKeyNotFound) {
@@ -137,6 +189,9 @@ func (s *Service) Create(uses uint, note string) (*invite.Token, error) {
 		st := inviteState{Used: 0}
 		st.Uses = uses
 		st.Note = note
+		if s.expiry > 0 {
+			st.Expires = time.Now().Add(s.expiry).Unix()
+		}
 
 		data, err := json.Marshal(st)
 		if err != nil {
@@ -160,8 +215,117 @@ func (s *Service) Create(uses uint, note string) (*invite.Token, error) {
 	return &inv, nil
 }
 
+// Revoke marks the invite as revoked, using it afterwards fails with ErrInviteRevoked
+func (s *Service) Revoke(inv invite.Token) error {
+	kvKey, err := guestKey(inv)
+	if err != nil {
+		return fmt.Errorf("invite/revoke: %w", err)
+	}
+
+	return s.kv.Update(func(txn *badger.Txn) error {
+		has, err := txn.Get(kvKey)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return fmt.Errorf("invite/revoke: no such invite")
+			}
+			return fmt.Errorf("invite/revoke: failed get guest remote from KV (%w)", err)
+		}
+
+		var st inviteState
+		err = has.Value(func(val []byte) error {
+			return json.Unmarshal(val, &st)
+		})
+		if err != nil {
+			return fmt.Errorf("invite/revoke: failed to decode state (%w)", err)
+		}
+
+		st.Revoked = true
+
+		data, err := json.Marshal(st)
+		if err != nil {
+			return fmt.Errorf("invite/revoke: failed to marshal state data (%w)", err)
+		}
+		return txn.Set(kvKey, data)
+	})
+}
+
+// Info describes an outstanding invite, as returned by invite.list
+type Info struct {
+	Guest refs.FeedRef `json:"guest"`
+	Note  string       `json:"note,omitempty"`
+
+	// Remaining is the number of uses that are left
+	Remaining uint `json:"remaining"`
+
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// List returns the invites that can still be used
+func (s *Service) List() ([]Info, error) {
+	now := time.Now().Unix()
+
+	list := []Info{}
+	err := s.kv.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.IteratorOptions{Prefix: dbKeyPrefix})
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			it := iter.Item()
+
+			var st inviteState
+			err := it.Value(func(val []byte) error {
+				return json.Unmarshal(val, &st)
+			})
+			if err != nil {
+				return fmt.Errorf("invite/list: failed to decode state (%w)", err)
+			}
+
+			if st.Revoked || st.Used >= st.Uses || (st.Expires != 0 && now >= st.Expires) {
+				continue
+			}
+
+			guest, err := refs.NewFeedRefFromBytes(bytes.TrimPrefix(it.Key(), dbKeyPrefix), refs.RefAlgoFeedSSB1)
+			if err != nil {
+				return fmt.Errorf("invite/list: invalid guest key (%w)", err)
+			}
+
+			info := Info{
+				Guest:     guest,
+				Note:      st.Note,
+				Remaining: st.Uses - st.Used,
+			}
+			if st.Expires != 0 {
+				exp := time.Unix(st.Expires, 0)
+				info.Expires = &exp
+			}
+			list = append(list, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// guestKey returns the database key of the invite, which is derived from the guest keypair
+func guestKey(inv invite.Token) ([]byte, error) {
+	kp, err := ssb.NewKeyPair(bytes.NewReader(inv.Seed[:]), refs.RefAlgoFeedSSB1)
+	if err != nil {
+		return nil, fmt.Errorf("generate seeded keypair (%w)", err)
+	}
+
+	var key []byte
+	key = append(key, dbKeyPrefix...)
+	return append(key, kp.ID().PubKey()...), nil
+}
+
 type inviteState struct {
 	CreateArguments
 
 	Used uint // how many times this invite was used already
+
+	Expires int64 `json:"expires,omitempty"` // unix timestamp after which the invite can't be used anymore, zero if it doesn't expire
+
+	Revoked bool `json:"revoked,omitempty"`
 }
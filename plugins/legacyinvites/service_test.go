@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package legacyinvites
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	kitlog "go.mindeco.de/log"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/invite"
+	"github.com/ssbc/go-ssb/repo"
+)
+
+type fakeNetwork struct {
+	ssb.Network
+}
+
+func (fakeNetwork) GetListenAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8008}
+}
+
+func TestInviteExpiry(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	db, err := repo.OpenBadgerDB(tRepoPath)
+	r.NoError(err)
+	defer db.Close()
+
+	self, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	open := func(opts ...Option) *Service {
+		s, err := New(kitlog.NewNopLogger(), nil, self.ID(), fakeNetwork{}, nil, nil, nil, db, opts...)
+		r.NoError(err)
+		return s
+	}
+
+	guest := func(tok *invite.Token) refs.FeedRef {
+		kp, err := ssb.NewKeyPair(bytes.NewReader(tok.Seed[:]), refs.RefAlgoFeedSSB1)
+		r.NoError(err)
+		return kp.ID()
+	}
+
+	forever := open()
+	tok, err := forever.Create(2, "no expiry")
+	r.NoError(err)
+	r.NoError(forever.Authorize(guest(tok)))
+
+	depleted, err := forever.Create(0, "zero uses")
+	r.NoError(err)
+	r.ErrorIs(forever.Authorize(guest(depleted)), ErrInviteDepleted)
+
+	expiring := open(WithExpiry(time.Nanosecond))
+	expired, err := expiring.Create(2, "expires right away")
+	r.NoError(err)
+	r.ErrorIs(expiring.Authorize(guest(expired)), ErrInviteExpired)
+
+	// the expiry is stored with the invite, so it survives a restart
+	r.ErrorIs(open().Authorize(guest(expired)), ErrInviteExpired)
+
+	valid, err := open(WithExpiry(time.Hour)).Create(1, "expires later")
+	r.NoError(err)
+	r.NoError(open().Authorize(guest(valid)))
+}
+
+func TestInviteRevoke(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	db, err := repo.OpenBadgerDB(tRepoPath)
+	r.NoError(err)
+	defer db.Close()
+
+	self, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	s, err := New(kitlog.NewNopLogger(), nil, self.ID(), fakeNetwork{}, nil, nil, nil, db, WithExpiry(time.Hour))
+	r.NoError(err)
+
+	keep, err := s.Create(3, "keep")
+	r.NoError(err)
+
+	revoke, err := s.Create(1, "revoke")
+	r.NoError(err)
+
+	list, err := s.List()
+	r.NoError(err)
+	r.Len(list, 2)
+
+	r.NoError(s.Revoke(*revoke))
+
+	revokedKp, err := ssb.NewKeyPair(bytes.NewReader(revoke.Seed[:]), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	r.ErrorIs(s.Authorize(revokedKp.ID()), ErrInviteRevoked)
+
+	list, err = s.List()
+	r.NoError(err)
+	r.Len(list, 1)
+	r.Equal("keep", list[0].Note)
+	r.EqualValues(3, list[0].Remaining)
+	r.NotNil(list[0].Expires)
+
+	keepKp, err := ssb.NewKeyPair(bytes.NewReader(keep.Seed[:]), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	r.True(list[0].Guest.Equal(keepKp.ID()))
+
+	var unknown invite.Token
+	r.Error(s.Revoke(unknown))
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package mentions exposes the messages which mention a feed, message or blob as mentions.of
+package mentions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/transform"
+)
+
+// Lookup returns the messages which mention ref
+type Lookup interface {
+	MentionsOf(ref refs.Ref) (luigi.Source, error)
+}
+
+// Args are the arguments of mentions.of
+type Args struct {
+	Ref string `json:"ref"`
+
+	// Keys wraps the messages in {key, value, timestamp} like createLogStream does
+	Keys bool `json:"keys"`
+}
+
+type plugin struct {
+	h muxrpc.Handler
+}
+
+// New returns the plugin for mentions.of, backed by l
+func New(i logging.Interface, l Lookup) ssb.Plugin {
+	mux := typemux.New(i)
+
+	mux.RegisterSource(muxrpc.Method{"mentions", "of"}, typemux.SourceFunc(func(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+		var args []Args
+		if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+			return fmt.Errorf("mentions: invalid arguments: %w", err)
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("mentions: expected one argument got %d", len(args))
+		}
+
+		ref, err := refs.ParseRef(args[0].Ref)
+		if err != nil {
+			return fmt.Errorf("mentions: invalid reference: %w", err)
+		}
+
+		src, err := l.MentionsOf(ref)
+		if err != nil {
+			return err
+		}
+
+		if err := luigi.Pump(ctx, transform.NewKeyValueWrapper(snk, args[0].Keys), src); err != nil {
+			return fmt.Errorf("mentions: failed to pump messages: %w", err)
+		}
+		return snk.Close()
+	}))
+
+	return plugin{h: &mux}
+}
+
+func (p plugin) Name() string            { return "mentions" }
+func (p plugin) Method() muxrpc.Method   { return muxrpc.Method{"mentions"} }
+func (p plugin) Handler() muxrpc.Handler { return p.h }
@@ -14,6 +14,7 @@ import (
 	"go.mindeco.de/logging"
 
 	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/plugins/gossip"
 	"github.com/ssbc/go-ssb/query"
 )
@@ -36,11 +37,16 @@ func (p plugin) Handler() muxrpc.Handler {
 	return p.h
 }
 
+// New returns the partialReplication plugin.
+// indexed returns the last sequence of rxlog that is included in the feeds and bytype indexes.
+// Live subsets are only served to self.
 func New(log logging.Interface,
 	fm *gossip.FeedManager,
 	feeds, bytype, roots *roaring.MultiLog,
 	rxlog margaret.Log,
+	indexed func() int64,
 	get ssb.Getter,
+	self refs.FeedRef,
 ) ssb.Plugin {
 	rootHdlr := typemux.New(log)
 
@@ -53,6 +59,8 @@ func New(log logging.Interface,
 	rootHdlr.RegisterSource(muxrpc.Method{name, "getSubset"}, getSubsetHandler{
 		queryPlaner: query.NewSubsetPlaner(feeds, bytype),
 		rxLog:       rxlog,
+		self:        self,
+		indexed:     indexed,
 	})
 
 	// TODO:
@@ -8,9 +8,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/ssbc/go-luigi"
 	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/query"
 	"github.com/ssbc/margaret"
@@ -20,6 +23,12 @@ type getSubsetHandler struct {
 	queryPlaner *query.SubsetPlaner
 
 	rxLog margaret.Log
+
+	// self is the only one allowed to make live queries, which keep the call open
+	self refs.FeedRef
+
+	// indexed returns up to which sequence of rxLog the bitmaps of the query planer are complete
+	indexed func() int64
 }
 
 func (h getSubsetHandler) HandleSource(ctx context.Context, req *muxrpc.Request, sink *muxrpc.ByteSink) error {
@@ -54,42 +63,28 @@ func (h getSubsetHandler) HandleSource(ctx context.Context, req *muxrpc.Request,
 		opts.Keys = true
 	}
 
-	resulting, err := h.queryPlaner.QuerySubsetBitmap(arg)
-	if err != nil {
-		return fmt.Errorf("failed to send query result to peer: %w", err)
+	if opts.Live || opts.LiveOnly {
+		requester, err := ssb.GetFeedRefFromAddr(req.RemoteAddr())
+		if err != nil || !requester.Equal(h.self) {
+			return fmt.Errorf("getSubset: live queries are only available to the bot itself")
+		}
 	}
 
-	if resulting == nil {
-		sink.Close()
-		return nil
+	// the bitmaps have all the messages up to this one, later ones are sent by the live part,
+	// even if they are already in the bitmaps by the time they are queried
+	startSeq := h.indexed()
+	if opts.LiveOnly {
+		startSeq = h.rxLog.Seq()
 	}
 
-	sink.SetEncoding(muxrpc.TypeJSON)
-
-	// iterate over the combined set of bitmaps
 	var (
 		buf bytes.Buffer
 		enc = json.NewEncoder(&buf)
 	)
+	sink.SetEncoding(muxrpc.TypeJSON)
 
-	vals := resulting.ToArray()
-	if opts.Descending {
-		for i, j := 0, len(vals)-1; i < j; i, j = i+1, j-1 {
-			vals[i], vals[j] = vals[j], vals[i]
-		}
-	}
-
-	for _, v := range vals {
-		msgv, err := h.rxLog.Get(int64(v))
-		if err != nil {
-			break
-		}
-
-		msg, ok := msgv.(refs.Message)
-		if !ok {
-			return fmt.Errorf("invalid msg type %T", msgv)
-		}
-
+	// send writes msg to the sink and returns false once the page limit is reached
+	send := func(msg refs.Message) (bool, error) {
 		if opts.Keys {
 			buf.Reset()
 
@@ -98,22 +93,64 @@ func (h getSubsetHandler) HandleSource(ctx context.Context, req *muxrpc.Request,
 			kv.Value = *msg.ValueContent()
 
 			if err := enc.Encode(kv); err != nil {
-				return fmt.Errorf("failed to encode json: %w", err)
+				return false, fmt.Errorf("failed to encode json: %w", err)
 			}
 
-			if _, err = buf.WriteTo(sink); err != nil {
-				return fmt.Errorf("failed to send json data: %w", err)
+			if _, err := buf.WriteTo(sink); err != nil {
+				return false, fmt.Errorf("failed to send json data: %w", err)
 			}
 		} else {
-			_, err = sink.Write(msg.ValueContentJSON())
+			_, err := sink.Write(msg.ValueContentJSON())
 			if err != nil {
-				return fmt.Errorf("failed to send json data: %w", err)
+				return false, fmt.Errorf("failed to send json data: %w", err)
 			}
 		}
 
 		if opts.PageLimit >= 0 {
 			opts.PageLimit--
 			if opts.PageLimit == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	if !opts.LiveOnly {
+		more, err := h.sendStored(arg, opts, startSeq, send)
+		if err != nil {
+			return err
+		}
+		if !more {
+			sink.Close()
+			return nil
+		}
+	}
+
+	if opts.Live || opts.LiveOnly {
+		src, err := h.rxLog.Query(margaret.Gt(startSeq), margaret.Live(true))
+		if err != nil {
+			return fmt.Errorf("failed to query new messages: %w", err)
+		}
+
+		for {
+			v, err := src.Next(ctx)
+			if err != nil {
+				if luigi.IsEOS(err) || errors.Is(err, context.Canceled) {
+					break
+				}
+				return err
+			}
+
+			msg, ok := v.(refs.Message)
+			if !ok || !arg.Matches(msg) {
+				continue
+			}
+
+			more, err := send(msg)
+			if err != nil {
+				return err
+			}
+			if !more {
 				break
 			}
 		}
@@ -122,3 +159,45 @@ func (h getSubsetHandler) HandleSource(ctx context.Context, req *muxrpc.Request,
 	sink.Close()
 	return nil
 }
+
+// sendStored sends the stored messages up to startSeq which match the query
+func (h getSubsetHandler) sendStored(arg query.SubsetOperation, opts query.SubsetOptions, startSeq int64, send func(refs.Message) (bool, error)) (bool, error) {
+	resulting, err := h.queryPlaner.QuerySubsetBitmap(arg)
+	if err != nil {
+		return false, fmt.Errorf("failed to send query result to peer: %w", err)
+	}
+
+	if resulting == nil {
+		return true, nil
+	}
+
+	// iterate over the combined set of bitmaps
+	vals := resulting.ToArray()
+	if opts.Descending {
+		for i, j := 0, len(vals)-1; i < j; i, j = i+1, j-1 {
+			vals[i], vals[j] = vals[j], vals[i]
+		}
+	}
+
+	for _, v := range vals {
+		if int64(v) > startSeq {
+			continue
+		}
+
+		msgv, err := h.rxLog.Get(int64(v))
+		if err != nil {
+			break
+		}
+
+		msg, ok := msgv.(refs.Message)
+		if !ok {
+			return false, fmt.Errorf("invalid msg type %T", msgv)
+		}
+
+		more, err := send(msg)
+		if err != nil || !more {
+			return false, err
+		}
+	}
+	return true, nil
+}
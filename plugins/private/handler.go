@@ -29,6 +29,10 @@ type handler struct {
 	publish ssb.Publisher
 	read    margaret.Log
 
+	// privIdx holds the receive log sequences of the messages in read
+	privIdx margaret.Log
+	rxSeq   SeqLookup
+
 	mngr *private.Manager
 }
 
@@ -164,3 +168,121 @@ func (h handler) handleRead(ctx context.Context, req *muxrpc.Request, snk *muxrp
 	req.Close()
 	return nil
 }
+
+// ReadPageArgs are the arguments for private.readPage
+type ReadPageArgs struct {
+	// Before is the cursor of the previous page. If it is not set, the page starts with the newest message.
+	Before *refs.MessageRef `json:"before,omitempty"`
+
+	Limit int `json:"limit"`
+}
+
+// ReadPage is the result of private.readPage, the messages are ordered newest first.
+// Next is the cursor for the following page and is not set if there are no older messages.
+type ReadPage struct {
+	Messages []refs.KeyValueRaw `json:"messages"`
+	Next     *refs.MessageRef   `json:"next,omitempty"`
+}
+
+func (h handler) handleReadPage(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	var args []ReadPageArgs
+	err := json.Unmarshal(req.RawArgs, &args)
+	if err != nil {
+		return nil, fmt.Errorf("private/readPage: failed to decode call arguments: %w", err)
+	}
+
+	if len(args) != 1 {
+		return nil, fmt.Errorf("private/readPage: expected one argument object")
+	}
+	qry := args[0]
+
+	if qry.Limit < 1 {
+		return nil, fmt.Errorf("private/readPage: limit needs to be positive, got %d", qry.Limit)
+	}
+
+	upper := h.privIdx.Seq() + 1
+	if qry.Before != nil {
+		upper, err = h.position(*qry.Before)
+		if err != nil {
+			return nil, fmt.Errorf("private/readPage: failed to find cursor: %w", err)
+		}
+	}
+
+	var page ReadPage
+	page.Messages = []refs.KeyValueRaw{}
+	if upper < 1 {
+		return page, nil
+	}
+
+	// the positions in the index are dense, so the page can be read forwards.
+	// not all backends support reverse queries with an upper bound.
+	lower := upper - int64(qry.Limit)
+	if lower < 0 {
+		lower = 0
+	}
+
+	src, err := h.read.Query(
+		margaret.Gte(lower),
+		margaret.Lt(upper))
+	if err != nil {
+		return nil, fmt.Errorf("private/readPage: failed to create query: %w", err)
+	}
+
+	for {
+		v, err := src.Next(ctx)
+		if luigi.IsEOS(err) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("private/readPage: failed to read message: %w", err)
+		}
+
+		msg, ok := v.(refs.KeyValueRaw)
+		if !ok {
+			return nil, fmt.Errorf("private/readPage: unexpected message type: %T", v)
+		}
+		page.Messages = append(page.Messages, msg)
+	}
+
+	// newest first
+	for i, j := 0, len(page.Messages)-1; i < j; i, j = i+1, j-1 {
+		page.Messages[i], page.Messages[j] = page.Messages[j], page.Messages[i]
+	}
+
+	if lower > 0 && len(page.Messages) > 0 {
+		next := page.Messages[len(page.Messages)-1].Key()
+		page.Next = &next
+	}
+
+	return page, nil
+}
+
+// position returns the index of the first entry in the private index which was received at or after the passed message.
+// The index holds receive log sequences in ascending order so it can be searched without unboxing anything.
+func (h handler) position(before refs.MessageRef) (int64, error) {
+	rxSeq, err := h.rxSeq(before)
+	if err != nil {
+		return -1, err
+	}
+
+	var lo, hi = int64(0), h.privIdx.Seq() + 1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		v, err := h.privIdx.Get(mid)
+		if err != nil {
+			return -1, fmt.Errorf("failed to get entry %d from the private index: %w", mid, err)
+		}
+
+		seq, ok := v.(int64)
+		if !ok {
+			return -1, fmt.Errorf("unexpected private index value: %T", v)
+		}
+
+		if seq < rxSeq {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
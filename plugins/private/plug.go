@@ -21,12 +21,19 @@ type privatePlug struct {
 	h muxrpc.Handler
 }
 
-func NewPlug(i logging.Interface, author refs.FeedRef, mngr *private.Manager, publish ssb.Publisher, readIdx margaret.Log) ssb.Plugin {
+// SeqLookup returns the receive log sequence of a message
+type SeqLookup func(refs.MessageRef) (int64, error)
+
+// NewPlug creates the private plugin. readIdx is the unboxing view of privIdx,
+// which holds the receive log sequences of the private messages for author.
+func NewPlug(i logging.Interface, author refs.FeedRef, mngr *private.Manager, publish ssb.Publisher, readIdx, privIdx margaret.Log, rxSeq SeqLookup) ssb.Plugin {
 	handler := handler{
 		author:  author,
 		mngr:    mngr,
 		publish: publish,
 		read:    readIdx,
+		privIdx: privIdx,
+		rxSeq:   rxSeq,
 		info:    i,
 	}
 
@@ -34,6 +41,7 @@ func NewPlug(i logging.Interface, author refs.FeedRef, mngr *private.Manager, pu
 
 	tm.RegisterAsync(append(methodName, "publish"), typemux.AsyncFunc(handler.handlePublish))
 	tm.RegisterSource(append(methodName, "read"), typemux.SourceFunc(handler.handleRead))
+	tm.RegisterAsync(append(methodName, "readPage"), typemux.AsyncFunc(handler.handleReadPage))
 
 	return &privatePlug{h: &tm}
 }
@@ -103,10 +103,8 @@ func (h *handler) HandleAsync(ctx context.Context, req *muxrpc.Request) (interfa
 			return nil, fmt.Errorf("publish: failed to encrypt message (box2:%v recps:%d): %w", useBox2, len(recps), err)
 		}
 	} else {
-		err = json.Unmarshal(args[0], &content)
-		if err != nil {
-			return nil, err
-		}
+		// pass it on as it is, decoding it would change the order of the fields and round large integers
+		content = args[0]
 	}
 
 	msg, err := h.publish.Publish(content)
@@ -81,14 +81,29 @@ func (g rxLogHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 		qry.Seq = int64(g.root.Seq()) - 1
 	}
 
-	// start := time.Now()
-	src, err := g.root.Query(
-		margaret.SeqWrap(false),
-		margaret.Gte(int64(qry.Seq)),
-		margaret.Limit(int(qry.Limit)),
-		margaret.Live(qry.Live),
-		margaret.Reverse(qry.Reverse),
-	)
+	lower := qry.Seq
+	if qry.Gt > 0 && int64(qry.Gt)+1 > lower {
+		lower = int64(qry.Gt) + 1
+	}
+
+	var src luigi.Source
+	if qry.Reverse && (qry.Lt > 0 || lower > 0) {
+		// the log can't apply bounds to reverse queries, so the window is read forward and flipped
+		src, err = g.reverseWindow(ctx, lower, int64(qry.Lt), int(qry.Limit))
+	} else {
+		specs := []margaret.QuerySpec{
+			margaret.SeqWrap(false),
+			margaret.Gte(lower),
+			margaret.Limit(int(qry.Limit)),
+			margaret.Live(qry.Live),
+			margaret.Reverse(qry.Reverse),
+		}
+		if qry.Lt > 0 {
+			specs = append(specs, margaret.Lt(int64(qry.Lt)))
+		}
+		// start := time.Now()
+		src, err = g.root.Query(specs...)
+	}
 	if err != nil {
 		req.CloseWithError(fmt.Errorf("logStream: failed to qry tipe: %w", err))
 		return
@@ -108,3 +123,43 @@ func (g rxLogHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 	snk.Close()
 	// fmt.Fprintln(os.Stderr, "createLogStream closed:", err, "after:", time.Since(start))
 }
+
+// reverseWindow returns the entries from lower up to (but not including) lt, newest first.
+// If limit is positive, only the newest limit entries are returned.
+func (g rxLogHandler) reverseWindow(ctx context.Context, lower, lt int64, limit int) (luigi.Source, error) {
+	upper := g.root.Seq() + 1
+	if lt > 0 && lt < upper {
+		upper = lt
+	}
+	if limit > 0 && upper-int64(limit) > lower {
+		lower = upper - int64(limit)
+	}
+	if lower >= upper {
+		return (*luigi.SliceSource)(&[]interface{}{}), nil
+	}
+
+	src, err := g.root.Query(
+		margaret.SeqWrap(false),
+		margaret.Gte(lower),
+		margaret.Lt(upper),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var window []interface{}
+	for {
+		v, err := src.Next(ctx)
+		if luigi.IsEOS(err) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		window = append(window, v)
+	}
+
+	for i, j := 0, len(window)-1; i < j; i, j = i+1, j-1 {
+		window[i], window[j] = window[j], window[i]
+	}
+	return (*luigi.SliceSource)(&window), nil
+}
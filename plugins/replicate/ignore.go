@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// FeedIgnorer keeps feeds from being replicated, even if they are within the hop range
+type FeedIgnorer interface {
+	IgnoreFeed(refs.FeedRef) error
+	UnignoreFeed(refs.FeedRef) error
+	IgnoredFeeds() []refs.FeedRef
+}
+
+// registerIgnore adds replicate.ignore and replicate.unignore, which take a feed reference, and replicate.ignored, which lists them
+func registerIgnore(tm *typemux.HandlerMux, fi FeedIgnorer) {
+	tm.RegisterAsync(muxrpc.Method{"replicate", "ignore"}, typemux.AsyncFunc(func(_ context.Context, req *muxrpc.Request) (interface{}, error) {
+		feed, err := feedArg(req)
+		if err != nil {
+			return nil, err
+		}
+		return true, fi.IgnoreFeed(feed)
+	}))
+
+	tm.RegisterAsync(muxrpc.Method{"replicate", "unignore"}, typemux.AsyncFunc(func(_ context.Context, req *muxrpc.Request) (interface{}, error) {
+		feed, err := feedArg(req)
+		if err != nil {
+			return nil, err
+		}
+		return true, fi.UnignoreFeed(feed)
+	}))
+
+	tm.RegisterAsync(muxrpc.Method{"replicate", "ignored"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return fi.IgnoredFeeds(), nil
+	}))
+}
+
+// FeedAdder replicates feeds that are not within the hop range
+type FeedAdder interface {
+	ReplicateFeed(refs.FeedRef) error
+	UnreplicateFeed(refs.FeedRef) error
+	ReplicatedFeeds() []refs.FeedRef
+}
+
+// registerAdd adds replicate.add and replicate.remove, which take a feed reference, and replicate.added, which lists them
+func registerAdd(tm *typemux.HandlerMux, fa FeedAdder) {
+	tm.RegisterAsync(muxrpc.Method{"replicate", "add"}, typemux.AsyncFunc(func(_ context.Context, req *muxrpc.Request) (interface{}, error) {
+		feed, err := feedArg(req)
+		if err != nil {
+			return nil, err
+		}
+		return true, fa.ReplicateFeed(feed)
+	}))
+
+	tm.RegisterAsync(muxrpc.Method{"replicate", "remove"}, typemux.AsyncFunc(func(_ context.Context, req *muxrpc.Request) (interface{}, error) {
+		feed, err := feedArg(req)
+		if err != nil {
+			return nil, err
+		}
+		return true, fa.UnreplicateFeed(feed)
+	}))
+
+	tm.RegisterAsync(muxrpc.Method{"replicate", "added"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return fa.ReplicatedFeeds(), nil
+	}))
+}
+
+func feedArg(req *muxrpc.Request) (refs.FeedRef, error) {
+	var args []string
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+		return refs.FeedRef{}, fmt.Errorf("replicate: invalid arguments: %w", err)
+	}
+	if len(args) != 1 {
+		return refs.FeedRef{}, fmt.Errorf("replicate: expected one feed reference got %d arguments", len(args))
+	}
+	feed, err := refs.ParseFeedRef(args[0])
+	if err != nil {
+		return refs.FeedRef{}, fmt.Errorf("replicate: invalid feed reference: %w", err)
+	}
+	return feed, nil
+}
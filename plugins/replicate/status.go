@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package replicate
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// FeedStatus is the replication state of one feed of the replication list, see replicate.status
+type FeedStatus struct {
+	Feed refs.FeedRef `json:"feed"`
+
+	// LocalSeq is the sequence of the newest stored message, zero if there is none
+	LocalSeq int64 `json:"localSeq"`
+
+	// RemoteSeq is the longest sequence a connected peer claimed to have and RemotePeer is that peer
+	RemoteSeq  int64         `json:"remoteSeq"`
+	RemotePeer *refs.FeedRef `json:"remotePeer,omitempty"`
+
+	// Active is true if an open EBT session replicates the feed
+	Active bool `json:"active"`
+
+	// LastProgress is when the newest stored message was received
+	LastProgress time.Time `json:"lastProgress,omitempty"`
+}
+
+// StatusReporter returns the replication state of the feeds that are replicated
+type StatusReporter interface {
+	ReplicationStatus() ([]FeedStatus, error)
+}
+
+func registerStatus(tm *typemux.HandlerMux, sr StatusReporter) {
+	tm.RegisterAsync(muxrpc.Method{"replicate", "status"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return sr.ReplicationStatus()
+	}))
+}
@@ -25,8 +25,10 @@ type replicatePlug struct {
 	h muxrpc.Handler
 }
 
+// NewPlug returns the plugin for replicate.upto, replicate.status if status isn't nil
+// replicate.ignore, unignore and ignored if ignorer isn't nil and replicate.add, remove and added if adder isn't nil.
 // TODO: add request, block, changes
-func NewPlug(users multilog.MultiLog, self refs.FeedRef, lister ssb.ReplicationLister) ssb.Plugin {
+func NewPlug(users multilog.MultiLog, self refs.FeedRef, lister ssb.ReplicationLister, status StatusReporter, ignorer FeedIgnorer, adder FeedAdder) ssb.Plugin {
 	plug := &replicatePlug{}
 
 	tm := typemux.New(log.NewNopLogger())
@@ -37,6 +39,18 @@ func NewPlug(users multilog.MultiLog, self refs.FeedRef, lister ssb.ReplicationL
 		self:   self,
 	})
 
+	if status != nil {
+		registerStatus(&tm, status)
+	}
+
+	if ignorer != nil {
+		registerIgnore(&tm, ignorer)
+	}
+
+	if adder != nil {
+		registerAdd(&tm, adder)
+	}
+
 	plug.h = &tm
 	return plug
 }
@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package room lets a bot act as a room server.
+// The connected peers, the attendants, find each other with room.attendants or tunnel.endpoints
+// and open connections to each other through the room with tunnel.connect.
+// The room only forwards the streams, the secret-handshake runs between the two peers.
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/log"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// EndpointGetter returns the connection to a peer, like ssb.Network does
+type EndpointGetter interface {
+	GetEndpointFor(refs.FeedRef) (muxrpc.Endpoint, bool)
+}
+
+// Metadata is the reply of room.metadata and tunnel.isRoom
+type Metadata struct {
+	Name       string   `json:"name"`
+	Membership bool     `json:"membership"`
+	Features   []string `json:"features"`
+}
+
+// AttendantsEvent is a value of the room.attendants stream.
+// The first one is the state with all the current attendants, the ones after it are joined and left events.
+type AttendantsEvent struct {
+	Type string         `json:"type"`
+	IDs  []refs.FeedRef `json:"ids,omitempty"`
+	ID   *refs.FeedRef  `json:"id,omitempty"`
+}
+
+// watchers that can't keep up are dropped, see notify
+const watcherBuffer = 64
+
+// Server keeps track of the attendants and forwards the tunnel.connect calls between them
+type Server struct {
+	self    refs.FeedRef
+	network EndpointGetter
+	logger  log.Logger
+
+	// tunnelFallback handles tunnel.connect calls where this bot is the target, behind another room
+	tunnelFallback muxrpc.Handler
+
+	mu         sync.Mutex
+	attendants map[string]refs.FeedRef
+	conns      map[string]int // open connections per attendant
+	watchers   map[chan AttendantsEvent]struct{}
+}
+
+// NewServer returns a room server for the bot self.
+// tunnelFallback, if not nil, handles the tunnel calls of this bot as a client of other rooms,
+// like the plugin of network.Node.TunnelPlugin.
+func NewServer(logger log.Logger, self refs.FeedRef, network EndpointGetter, tunnelFallback ssb.Plugin) *Server {
+	srv := &Server{
+		self:    self,
+		network: network,
+		logger:  logger,
+
+		attendants: make(map[string]refs.FeedRef),
+		conns:      make(map[string]int),
+		watchers:   make(map[chan AttendantsEvent]struct{}),
+	}
+	if tunnelFallback != nil {
+		srv.tunnelFallback = tunnelFallback.Handler()
+	}
+	return srv
+}
+
+// Attendants returns the peers that are connected to the room, sorted by their reference
+func (srv *Server) Attendants() []refs.FeedRef {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.attendantList()
+}
+
+func (srv *Server) attendantList() []refs.FeedRef {
+	lst := make([]refs.FeedRef, 0, len(srv.attendants))
+	for _, a := range srv.attendants {
+		lst = append(lst, a)
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].String() < lst[j].String() })
+	return lst
+}
+
+// Metadata describes the room
+func (srv *Server) Metadata() Metadata {
+	return Metadata{
+		Name:       "go-ssb",
+		Membership: false,
+		Features:   []string{"tunnel", "room1", "room2"},
+	}
+}
+
+// attend adds the peer of edp to the attendants until its connection closes
+func (srv *Server) attend(ctx context.Context, edp muxrpc.Endpoint) {
+	peer, err := ssb.GetFeedRefFromAddr(edp.Remote())
+	if err != nil || peer.Equal(srv.self) {
+		return
+	}
+
+	srv.mu.Lock()
+	// the same peer might be connected twice for a moment, the connection tracker closes one of them
+	srv.conns[peer.String()]++
+	if srv.conns[peer.String()] == 1 {
+		srv.attendants[peer.String()] = peer
+		srv.notify(AttendantsEvent{Type: "joined", ID: &peer})
+		level.Debug(srv.logger).Log("event", "attendant joined", "peer", peer.ShortSigil())
+	}
+	srv.mu.Unlock()
+
+	<-ctx.Done()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.conns[peer.String()]--
+	if srv.conns[peer.String()] > 0 {
+		return
+	}
+	delete(srv.conns, peer.String())
+	delete(srv.attendants, peer.String())
+	srv.notify(AttendantsEvent{Type: "left", ID: &peer})
+	level.Debug(srv.logger).Log("event", "attendant left", "peer", peer.ShortSigil())
+}
+
+// notify passes evt to the watchers, srv.mu needs to be held
+func (srv *Server) notify(evt AttendantsEvent) {
+	for w := range srv.watchers {
+		select {
+		case w <- evt:
+		default:
+			// the stream can't keep up, end it so that the peer can start again with a new state
+			delete(srv.watchers, w)
+			close(w)
+		}
+	}
+}
+
+// watch returns the current attendants and a channel with the changes after that
+func (srv *Server) watch() ([]refs.FeedRef, chan AttendantsEvent, func()) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	w := make(chan AttendantsEvent, watcherBuffer)
+	srv.watchers[w] = struct{}{}
+	cancel := func() {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		if _, has := srv.watchers[w]; has {
+			delete(srv.watchers, w)
+			close(w)
+		}
+	}
+	return srv.attendantList(), w, cancel
+}
+
+// Plugins returns the room and tunnel plugins, to be registered for the public connections
+func (srv *Server) Plugins() []ssb.Plugin {
+	return []ssb.Plugin{
+		plugin{name: "room", h: srv.roomHandler()},
+		plugin{name: "tunnel", h: srv.tunnelHandler()},
+	}
+}
+
+// Handler returns only the room and tunnel methods, for peers that are not allowed to use the other ones
+func (srv *Server) Handler() muxrpc.Handler {
+	var mux muxrpc.HandlerMux
+	for _, p := range srv.Plugins() {
+		mux.Register(p.Method(), p.Handler())
+	}
+	return &mux
+}
+
+type plugin struct {
+	name string
+	h    muxrpc.Handler
+}
+
+func (p plugin) Name() string            { return p.name }
+func (p plugin) Method() muxrpc.Method   { return muxrpc.Method{p.name} }
+func (p plugin) Handler() muxrpc.Handler { return p.h }
+
+// roomHandler adds room.metadata and room.attendants and keeps track of the attendants
+func (srv *Server) roomHandler() muxrpc.Handler {
+	tm := typemux.New(srv.logger)
+
+	tm.RegisterAsync(muxrpc.Method{"room", "metadata"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return srv.Metadata(), nil
+	}))
+
+	tm.RegisterSource(muxrpc.Method{"room", "attendants"}, typemux.SourceFunc(srv.streamAttendants))
+
+	return attendingHandler{Handler: &tm, srv: srv}
+}
+
+type attendingHandler struct {
+	muxrpc.Handler
+	srv *Server
+}
+
+func (ah attendingHandler) HandleConnect(ctx context.Context, edp muxrpc.Endpoint) {
+	ah.srv.attend(ctx, edp)
+}
+
+func (srv *Server) streamAttendants(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+	state, changes, cancel := srv.watch()
+	defer cancel()
+
+	snk.SetEncoding(muxrpc.TypeJSON)
+	enc := json.NewEncoder(snk)
+
+	if err := enc.Encode(AttendantsEvent{Type: "state", IDs: state}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return snk.Close()
+		case evt, ok := <-changes:
+			if !ok {
+				return snk.CloseWithError(errWatcherDropped)
+			}
+			if err := enc.Encode(evt); err != nil {
+				return err
+			}
+		}
+	}
+}
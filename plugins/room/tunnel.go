@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/log"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+)
+
+var (
+	errWatcherDropped = errors.New("room: attendants stream too slow")
+
+	// ErrNotAttending is returned by tunnel.connect if the target isn't connected to the room
+	ErrNotAttending = errors.New("room: target is not connected")
+)
+
+// tunnelHandler adds the tunnel methods of a room server.
+// Calls that are meant for this bot as a client of another room are passed to the fallback.
+func (srv *Server) tunnelHandler() muxrpc.Handler {
+	tm := typemux.New(srv.logger)
+
+	tm.RegisterAsync(muxrpc.Method{"tunnel", "isRoom"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return srv.Metadata(), nil
+	}))
+
+	tm.RegisterAsync(muxrpc.Method{"tunnel", "ping"}, typemux.AsyncFunc(func(context.Context, *muxrpc.Request) (interface{}, error) {
+		return time.Now().UTC().UnixNano() / 1000000, nil
+	}))
+
+	tm.RegisterSource(muxrpc.Method{"tunnel", "endpoints"}, typemux.SourceFunc(srv.streamEndpoints))
+
+	tm.RegisterDuplex(muxrpc.Method{"tunnel", "connect"}, typemux.DuplexFunc(srv.connect))
+
+	return tunnelHandler{Handler: &tm, fallback: srv.tunnelFallback}
+}
+
+type tunnelHandler struct {
+	muxrpc.Handler
+	fallback muxrpc.Handler
+}
+
+// HandleCall passes tunnel.connect calls with an origin to the fallback,
+// this bot is their target, behind another room.
+func (th tunnelHandler) HandleCall(ctx context.Context, req *muxrpc.Request) {
+	if th.fallback != nil && req.Method.String() == "tunnel.connect" {
+		var args []connectArgs
+		if err := json.Unmarshal(req.RawArgs, &args); err == nil && len(args) == 1 && args[0].Origin != nil {
+			th.fallback.HandleCall(ctx, req)
+			return
+		}
+	}
+	th.Handler.HandleCall(ctx, req)
+}
+
+// HandleConnect lets the fallback look for rooms on the new connection
+func (th tunnelHandler) HandleConnect(ctx context.Context, edp muxrpc.Endpoint) {
+	if th.fallback != nil {
+		th.fallback.HandleConnect(ctx, edp)
+	}
+}
+
+// streamEndpoints sends the list of attendants, every time it changes
+func (srv *Server) streamEndpoints(ctx context.Context, req *muxrpc.Request, snk *muxrpc.ByteSink) error {
+	state, changes, cancel := srv.watch()
+	defer cancel()
+
+	snk.SetEncoding(muxrpc.TypeJSON)
+	enc := json.NewEncoder(snk)
+
+	current := make(map[string]refs.FeedRef, len(state))
+	for _, a := range state {
+		current[a.String()] = a
+	}
+	sendList := func() error {
+		lst := make([]refs.FeedRef, 0, len(current))
+		for _, a := range current {
+			lst = append(lst, a)
+		}
+		return enc.Encode(lst)
+	}
+
+	if err := enc.Encode(state); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return snk.Close()
+		case evt, ok := <-changes:
+			if !ok {
+				return snk.CloseWithError(errWatcherDropped)
+			}
+			if evt.ID == nil {
+				continue
+			}
+			if evt.Type == "left" {
+				delete(current, evt.ID.String())
+			} else {
+				current[evt.ID.String()] = *evt.ID
+			}
+			if err := sendList(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type connectArgs struct {
+	Portal refs.FeedRef  `json:"portal"`
+	Target refs.FeedRef  `json:"target"`
+	Origin *refs.FeedRef `json:"origin,omitempty"`
+}
+
+// connect forwards the stream of the caller to the target, which gets a tunnel.connect call with the origin set
+func (srv *Server) connect(ctx context.Context, req *muxrpc.Request, src *muxrpc.ByteSource, snk *muxrpc.ByteSink) error {
+	var args []connectArgs
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil || len(args) != 1 {
+		return fmt.Errorf("room: invalid tunnel.connect arguments: %s", req.RawArgs)
+	}
+	arg := args[0]
+
+	origin, err := ssb.GetFeedRefFromAddr(req.Endpoint().Remote())
+	if err != nil {
+		return err
+	}
+	if !arg.Portal.Equal(srv.self) {
+		return fmt.Errorf("room: tunnel.connect for a different portal: %s", arg.Portal.ShortSigil())
+	}
+	if arg.Target.Equal(origin) {
+		return fmt.Errorf("room: can't tunnel to yourself")
+	}
+
+	srv.mu.Lock()
+	_, attending := srv.attendants[arg.Target.String()]
+	srv.mu.Unlock()
+	edp, has := srv.network.GetEndpointFor(arg.Target)
+	if !attending || !has {
+		return ErrNotAttending
+	}
+
+	tunnelLogger := log.With(srv.logger, "origin", origin.ShortSigil(), "target", arg.Target.ShortSigil())
+	level.Debug(tunnelLogger).Log("event", "forwarding tunnel.connect")
+
+	arg.Origin = &origin
+	targetSrc, targetSnk, err := edp.Duplex(ctx, muxrpc.TypeBinary, muxrpc.Method{"tunnel", "connect"}, arg)
+	if err != nil {
+		return fmt.Errorf("room: failed to call the target: %w", err)
+	}
+
+	go func() {
+		_, err := io.Copy(muxrpc.NewSinkWriter(targetSnk), muxrpc.NewSourceReader(src))
+		if err != nil {
+			level.Debug(tunnelLogger).Log("event", "origin to target copy ended", "err", err)
+		}
+		targetSnk.Close()
+	}()
+	_, err = io.Copy(muxrpc.NewSinkWriter(snk), muxrpc.NewSourceReader(targetSrc))
+	snk.Close()
+	level.Debug(tunnelLogger).Log("event", "tunnel closed", "err", err)
+	return err
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package search exposes the full-text search over public posts as search.query
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// Searcher returns the posts that contain all the words of query
+type Searcher interface {
+	Search(query string, limit int) ([]refs.MessageRef, error)
+}
+
+// QueryArgs are the arguments of search.query
+type QueryArgs struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type plugin struct {
+	h muxrpc.Handler
+}
+
+// New returns the plugin for search.query, backed by s
+func New(i logging.Interface, s Searcher) ssb.Plugin {
+	mux := typemux.New(i)
+
+	mux.RegisterAsync(muxrpc.Method{"search", "query"}, typemux.AsyncFunc(func(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+		var args []QueryArgs
+		if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+			return nil, fmt.Errorf("search: invalid arguments: %w", err)
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("search: expected one argument got %d", len(args))
+		}
+
+		return s.Search(args[0].Query, args[0].Limit)
+	}))
+
+	return plugin{h: &mux}
+}
+
+func (p plugin) Name() string            { return "search" }
+func (p plugin) Method() muxrpc.Method   { return muxrpc.Method{"search"} }
+func (p plugin) Handler() muxrpc.Handler { return p.h }
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package server exposes information about the build of the server as server.version
+package server
+
+import (
+	"context"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+)
+
+var (
+	_ ssb.Plugin = plugin{} // compile-time type check
+
+	versionMethod = muxrpc.Method{"server", "version"}
+)
+
+// VersionInfo is the reply of server.version
+type VersionInfo struct {
+	// Version of go-ssb, or of the program that embeds it if it set one
+	Version string `json:"version"`
+
+	// Commit is the revision the server was built from, if it is known
+	Commit string `json:"commit,omitempty"`
+
+	// Build is when the server was built, if it is known
+	Build string `json:"build,omitempty"`
+
+	// GoVersion is the version of the Go toolchain that built the server
+	GoVersion string `json:"goVersion"`
+
+	// Features are the optional parts of the server which are enabled, like ebt or metafeeds
+	Features []string `json:"features"`
+}
+
+// VersionFunc returns the current version info, since some features can be toggled while the server runs
+type VersionFunc func() VersionInfo
+
+// New returns the plugin for server.version
+func New(log logging.Interface, fn VersionFunc) ssb.Plugin {
+	return plugin{log: log, fn: fn}
+}
+
+type plugin struct {
+	log logging.Interface
+	fn  VersionFunc
+}
+
+func (plugin) Name() string { return "server" }
+
+func (plugin) Method() muxrpc.Method { return muxrpc.Method{"server"} }
+
+func (p plugin) Handler() muxrpc.Handler { return p }
+
+func (plugin) Handled(m muxrpc.Method) bool { return m.String() == versionMethod.String() }
+
+func (plugin) HandleConnect(ctx context.Context, edp muxrpc.Endpoint) {}
+
+func (p plugin) HandleCall(ctx context.Context, req *muxrpc.Request) {
+	err := req.Return(ctx, p.fn())
+	if err != nil {
+		p.log.Log("event", "error", "msg", "failed to return server version", "err", err)
+	}
+}
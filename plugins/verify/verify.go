@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package verify exposes the verification of stored feeds as verify.feed
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/message"
+)
+
+// Verifier checks the stored messages of a feed, like message.VerifyFeed
+type Verifier interface {
+	VerifyFeed(feed refs.FeedRef) (int64, error)
+}
+
+// FeedArgs are the arguments of verify.feed
+type FeedArgs struct {
+	ID refs.FeedRef `json:"id"`
+}
+
+// Result is the reply of verify.feed
+type Result struct {
+	Feed refs.FeedRef `json:"feed"`
+
+	// LastGoodSeq is the sequence of the last message that is fine
+	LastGoodSeq int64 `json:"lastGoodSeq"`
+
+	// Broken is true if the feed has a message after LastGoodSeq which doesn't continue it correctly
+	Broken bool   `json:"broken"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type plugin struct {
+	h muxrpc.Handler
+}
+
+// New returns the plugin for verify.feed, backed by v
+func New(i logging.Interface, v Verifier) ssb.Plugin {
+	mux := typemux.New(i)
+
+	mux.RegisterAsync(muxrpc.Method{"verify", "feed"}, typemux.AsyncFunc(func(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+		var args []FeedArgs
+		if err := json.Unmarshal(req.RawArgs, &args); err != nil {
+			return nil, fmt.Errorf("verify: invalid arguments: %w", err)
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("verify: expected one argument got %d", len(args))
+		}
+
+		feed := args[0].ID
+		lastGood, err := v.VerifyFeed(feed)
+		res := Result{
+			Feed:        feed,
+			LastGoodSeq: lastGood,
+		}
+		if err != nil {
+			broken, ok := message.IsFeedBroken(err)
+			if !ok {
+				return nil, err
+			}
+			res.Broken = true
+			res.Reason = broken.Reason.String()
+			res.Error = err.Error()
+		}
+		return res, nil
+	}))
+
+	return plugin{h: &mux}
+}
+
+func (p plugin) Name() string            { return "verify" }
+func (p plugin) Method() muxrpc.Method   { return muxrpc.Method{"verify"} }
+func (p plugin) Handler() muxrpc.Handler { return p.h }
@@ -17,7 +17,10 @@ import (
 
 var (
 	_      ssb.Plugin = plugin{} // compile-time type check
+	_      ssb.Plugin = detailsPlugin{}
 	method            = muxrpc.Method{"whoami"}
+
+	detailsMethod = muxrpc.Method{"whoamiDetailed"}
 )
 
 func checkAndLog(log logging.Interface, err error) {
@@ -67,6 +70,58 @@ func (h handler) HandleCall(ctx context.Context, req *muxrpc.Request) {
 	checkAndLog(h.log, err)
 }
 
+// Details is the reply of whoamiDetailed
+type Details struct {
+	ID refs.FeedRef `json:"id"`
+
+	// Algo is the format of the feed, like ed25519 for classic feeds
+	Algo refs.RefAlgo `json:"algo"`
+
+	// Hops is how far the server replicates in the follow graph
+	Hops uint `json:"hops"`
+
+	// Plugins are the replication and blob plugins that are enabled, like ebt, gossip and blobs
+	Plugins []string `json:"plugins"`
+}
+
+// HasPlugin returns true if the named plugin is enabled
+func (d Details) HasPlugin(name string) bool {
+	for _, p := range d.Plugins {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DetailsFunc returns the current details, since the hops can change while the server runs
+type DetailsFunc func() Details
+
+// NewDetails returns the plugin for whoamiDetailed, which returns more than just the feed of the server
+func NewDetails(log logging.Interface, fn DetailsFunc) ssb.Plugin {
+	return detailsPlugin{log: log, fn: fn}
+}
+
+type detailsPlugin struct {
+	log logging.Interface
+	fn  DetailsFunc
+}
+
+func (detailsPlugin) Name() string { return "whoamiDetailed" }
+
+func (detailsPlugin) Method() muxrpc.Method { return detailsMethod }
+
+func (p detailsPlugin) Handler() muxrpc.Handler { return p }
+
+func (detailsPlugin) Handled(m muxrpc.Method) bool { return m.String() == detailsMethod.String() }
+
+func (detailsPlugin) HandleConnect(ctx context.Context, edp muxrpc.Endpoint) {}
+
+func (p detailsPlugin) HandleCall(ctx context.Context, req *muxrpc.Request) {
+	err := req.Return(ctx, p.fn())
+	checkAndLog(p.log, err)
+}
+
 type endpoint struct {
 	edp muxrpc.Endpoint
 }
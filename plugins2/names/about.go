@@ -20,7 +20,6 @@ import (
 	libbadger "github.com/ssbc/margaret/indexes/badger"
 
 	refs "github.com/ssbc/go-ssb-refs"
-	"github.com/ssbc/go-ssb/client"
 )
 
 type aboutStore struct {
@@ -36,6 +35,26 @@ type AboutAttribute struct {
 	Prescribed map[string]int
 }
 
+// Value resolves the attribute: what the feed chose for itself wins over what others prescribed.
+// Otherwise it is the value most others agree on, ties are broken by picking the smallest one so that the result is stable.
+// Only the latest value of each author counts, older ones are overwritten in the index.
+func (attr AboutAttribute) Value() string {
+	if attr.Chosen != "" {
+		return attr.Chosen
+	}
+
+	var (
+		best  string
+		count int
+	)
+	for val, cnt := range attr.Prescribed {
+		if cnt > count || (cnt == count && val < best) {
+			best, count = val, cnt
+		}
+	}
+	return best
+}
+
 var idxKeyPrefix = []byte("idx-abouts")
 var idxInSync sync.WaitGroup
 
@@ -86,10 +105,10 @@ func (ab aboutStore) ImageFor(ref *refs.FeedRef) (*refs.BlobRef, error) {
 	return &br, err
 }
 
-func (ab aboutStore) All() (client.NamesGetResult, error) {
+func (ab aboutStore) All() (map[string]map[string]string, error) {
 	ab.waitForIndexes()
 
-	var ngr = make(client.NamesGetResult)
+	var ngr = make(map[string]map[string]string)
 	err := ab.kv.View(func(txn *badger.Txn) error {
 		iter := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer iter.Close()
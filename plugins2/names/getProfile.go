@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package names
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb/graph"
+)
+
+// Profile is the display info of a feed with its follow and block counts, as returned by names.getProfile
+type Profile struct {
+	ID          refs.FeedRef `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Image       string       `json:"image"`
+
+	Followers int `json:"followers"`
+	Following int `json:"following"`
+	BlockedBy int `json:"blockedBy"`
+	Blocking  int `json:"blocking"`
+}
+
+type hGetProfile struct {
+	as    aboutStore
+	graph graph.Builder
+	log   logging.Interface
+}
+
+// HandleAsync returns the display info of a feed together with its follow and block counts.
+// Without a graph the counts are zero.
+func (h hGetProfile) HandleAsync(ctx context.Context, req *muxrpc.Request) (interface{}, error) {
+	ref, err := parseFeedRefFromArgs(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ai, err := h.as.CollectedFor(ref)
+	if err != nil {
+		return nil, fmt.Errorf("do not have about for: %s: %w", ref.String(), err)
+	}
+
+	profile := Profile{
+		ID:          ref,
+		Name:        ai.Name.Value(),
+		Description: ai.Description.Value(),
+		Image:       ai.Image.Value(),
+	}
+
+	if h.graph != nil {
+		g, err := h.graph.Build()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build the graph: %w", err)
+		}
+		profile.Followers, profile.Following, profile.BlockedBy, profile.Blocking = g.Degree(ref)
+	}
+
+	return profile, nil
+}
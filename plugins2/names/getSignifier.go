@@ -28,15 +28,9 @@ func (h hGetSignifier) HandleAsync(ctx context.Context, req *muxrpc.Request) (in
 		return nil, fmt.Errorf("do not have about for: %s: %w", ref.String(), err)
 
 	}
-	var name = ai.Name.Chosen
+	var name = ai.Name.Value()
 	if name == "" {
-		for n := range ai.Name.Prescribed { // pick random name
-			name = n
-			break
-		}
-		if name == "" {
-			name = ref.String()
-		}
+		name = ref.String()
 	}
 
 	return name, nil
@@ -13,17 +13,37 @@ import (
 
 	"github.com/ssbc/go-muxrpc/v2"
 	"go.mindeco.de/logging"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/graph"
 )
 
 type Plugin struct {
 	about aboutStore
+
+	// for the counts of names.getProfile, see UseGraph
+	graph graph.Builder
+}
+
+// UseGraph adds the follow and block counts of the graph to names.getProfile
+func (plug *Plugin) UseGraph(b graph.Builder) {
+	plug.graph = b
+}
+
+// About returns what feed and others said about it
+func (plug Plugin) About(feed refs.FeedRef) (AboutInfo, error) {
+	ai, err := plug.about.CollectedFor(feed)
+	if err != nil {
+		return AboutInfo{}, err
+	}
+	return *ai, nil
 }
 
 func (lt Plugin) Name() string            { return "names" }
 func (Plugin) Method() muxrpc.Method      { return muxrpc.Method{"names"} }
-func (lt Plugin) Handler() muxrpc.Handler { return newNamesHandler(nil, lt.about) }
+func (lt Plugin) Handler() muxrpc.Handler { return newNamesHandler(nil, lt.about, lt.graph) }
 
-func newNamesHandler(l log.Logger, as aboutStore) muxrpc.Handler {
+func newNamesHandler(l log.Logger, as aboutStore, gb graph.Builder) muxrpc.Handler {
 
 	if l == nil {
 		l = log.NewLogfmtLogger(os.Stderr)
@@ -44,6 +64,11 @@ func newNamesHandler(l log.Logger, as aboutStore) muxrpc.Handler {
 		log: l,
 		as:  as,
 	})
+	mux.RegisterAsync(muxrpc.Method{"names", "getProfile"}, hGetProfile{
+		log:   l,
+		as:    as,
+		graph: gb,
+	})
 
 	return &mux
 }
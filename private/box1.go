@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package private
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/private/box"
+)
+
+// Box1 encrypts plaintext for the recipients, without the need for a running bot or a key store.
+// The result is the same base64 string with a .box suffix that is used as the content of private messages,
+// like ssb-keys.box() returns it. To be readable by the javascript stack, plaintext should be JSON.
+func Box1(plaintext []byte, recipients []refs.FeedRef) ([]byte, error) {
+	ctxt, err := box.NewBoxer(nil).Encrypt(plaintext, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("private: box1 failed: %w", err)
+	}
+
+	boxed := make([]byte, base64.StdEncoding.EncodedLen(len(ctxt)), base64.StdEncoding.EncodedLen(len(ctxt))+4)
+	base64.StdEncoding.Encode(boxed, ctxt)
+	return append(boxed, ".box"...), nil
+}
+
+// Unbox1 decrypts the result of Box1, or the content of a private message, which may still be quoted as a JSON string.
+// The returned bool is false, without an error, if kp is not one of the recipients.
+func Unbox1(boxed []byte, kp ssb.KeyPair) ([]byte, bool, error) {
+	boxed = bytes.TrimSuffix(bytes.TrimPrefix(boxed, []byte(`"`)), []byte(`"`))
+	if !bytes.HasSuffix(boxed, []byte(".box")) {
+		return nil, false, ErrNotBoxed
+	}
+	b64data := bytes.TrimSuffix(boxed, []byte(".box"))
+
+	ctxt := make([]byte, base64.StdEncoding.DecodedLen(len(b64data)))
+	n, err := base64.StdEncoding.Decode(ctxt, b64data)
+	if err != nil {
+		return nil, false, fmt.Errorf("private: invalid box1 encoding: %w", err)
+	}
+
+	plain, err := box.NewBoxer(nil).Decrypt(kp, ctxt[:n])
+	if errors.Is(err, box.ErrPrivateMessageDecryptFailed) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("private: unbox1 failed: %w", err)
+	}
+	return plain, true, nil
+}
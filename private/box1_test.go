@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package private
+
+import (
+	"bytes"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+)
+
+func TestBox1Standalone(t *testing.T) {
+	r := require.New(t)
+
+	alice, err := ssb.NewKeyPair(bytes.NewReader(bytes.Repeat([]byte{1}, 32)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	bob, err := ssb.NewKeyPair(bytes.NewReader(bytes.Repeat([]byte{2}, 32)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	eve, err := ssb.NewKeyPair(bytes.NewReader(bytes.Repeat([]byte{3}, 32)), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	plain := []byte(`{"type":"post","text":"hello"}`)
+
+	// a fixed box for alice and bob, with nonce, one-time key and content key set to 0x03.
+	// it was made by this package and guards against regressions of the wire format,
+	// the compatibility with the javascript stack is checked by the private message tests in tests/.
+	const vector = "AwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDXf7dO2vUf2+ijuFdlp1bsOpTd01Ii9r53xxuASSz7yIvsj8baxTP7wNCPSEsuEVlUCPRjZvwfpMk9jW1+NAncSqC++EbAn7sPvw+q7ohsnwWNS+bIeRog4zqZCINpi0p6/dpezzb9eLWQPV50wSlrPgLF1Ct+0YIubZNHjj1RfTxu1vc4v2aErYQUTqi6UCjSuTgNqVhNHToaqXaALMoXvp/G5OELlzwp7lOauv4H3o=.box"
+
+	boxed, err := Box1(plain, []refs.FeedRef{alice.ID(), bob.ID()})
+	r.NoError(err)
+	r.True(bytes.HasSuffix(boxed, []byte(".box")))
+
+	for _, b := range [][]byte{boxed, []byte(vector), []byte(`"` + vector + `"`)} {
+		for _, kp := range []ssb.KeyPair{alice, bob} {
+			got, ok, err := Unbox1(b, kp)
+			r.NoError(err)
+			r.True(ok, "%s should be a recipient", kp.ID().ShortSigil())
+			r.Equal(plain, got)
+		}
+
+		got, ok, err := Unbox1(b, eve)
+		r.NoError(err)
+		r.False(ok, "eve is not a recipient")
+		r.Nil(got)
+	}
+
+	_, err = Box1(plain, nil)
+	r.Error(err, "needs recipients")
+
+	_, _, err = Unbox1(plain, alice)
+	r.ErrorIs(err, ErrNotBoxed)
+
+	_, _, err = Unbox1([]byte("not base64!.box"), alice)
+	r.Error(err)
+}
@@ -15,6 +15,7 @@ import (
 
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb-refs/tfk"
+	"github.com/ssbc/go-ssb/message/legacy"
 	"github.com/ssbc/go-ssb/private/box2"
 	"github.com/ssbc/go-ssb/private/keys"
 )
@@ -243,7 +244,7 @@ func (mgr *Manager) PublishTo(groupID refs.MessageRef, content []byte) (refs.Mes
 
 	// assign group tangle
 	var decodedContent map[string]interface{}
-	err = json.Unmarshal(content, &decodedContent)
+	err = legacy.Unmarshal(content, &decodedContent)
 	if err != nil {
 		return refs.MessageRef{}, err
 	}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package subset is a builder for the subset queries of the query package.
+//
+//	qry, err := subset.And(subset.Type("post"), subset.Not(subset.Author(feed))).Build()
+//
+// Invalid arguments are collected while the query is build and returned by Build (or when it is marshaled to JSON),
+// so that they don't have to be checked for every part of the query.
+package subset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/query"
+)
+
+// Query is a part of a subset query
+type Query struct {
+	op  query.SubsetOperation
+	err error
+
+	negated bool
+}
+
+// Type matches messages with the content type t
+func Type(t string) Query {
+	if t == "" {
+		return Query{err: errors.New("subset: type can't be empty")}
+	}
+	return Query{op: query.NewSubsetOpByType(t)}
+}
+
+// Author matches the messages of feed
+func Author(feed refs.FeedRef) Query {
+	if err := ssb.IsValidFeedFormat(feed); err != nil {
+		return Query{err: fmt.Errorf("subset: invalid author: %w", err)}
+	}
+	return Query{op: query.NewSubsetOpByAuthor(feed)}
+}
+
+// AuthorString is like Author but parses the feed reference first
+func AuthorString(feed string) Query {
+	ref, err := refs.ParseFeedRef(feed)
+	if err != nil {
+		return Query{err: fmt.Errorf("subset: invalid author %q: %w", feed, err)}
+	}
+	return Author(ref)
+}
+
+// And matches the messages that match all of qs. At least one of them must not be negated.
+func And(qs ...Query) Query {
+	ops, err := collect("and", qs)
+	if err != nil {
+		return Query{err: err}
+	}
+
+	positive := false
+	for _, q := range qs {
+		if !q.negated {
+			positive = true
+			break
+		}
+	}
+	if !positive {
+		return Query{err: errors.New("subset: and needs at least one argument that isn't negated")}
+	}
+
+	return Query{op: query.NewSubsetAndCombination(ops...)}
+}
+
+// Or matches the messages that match any of qs. The arguments can't be negated.
+func Or(qs ...Query) Query {
+	ops, err := collect("or", qs)
+	if err != nil {
+		return Query{err: err}
+	}
+
+	for i, q := range qs {
+		if q.negated {
+			return Query{err: fmt.Errorf("subset: argument %d of or is negated, which is only supported in and", i)}
+		}
+	}
+
+	return Query{op: query.NewSubsetOrCombination(ops...)}
+}
+
+// Not excludes the messages that match q. It can only be used as an argument of And.
+func Not(q Query) Query {
+	if q.err != nil {
+		return q
+	}
+	if q.negated {
+		return Query{err: errors.New("subset: double negation")}
+	}
+	return Query{op: query.NewSubsetNegation(q.op), negated: true}
+}
+
+func collect(name string, qs []Query) ([]query.SubsetOperation, error) {
+	if len(qs) == 0 {
+		return nil, fmt.Errorf("subset: %s needs at least one argument", name)
+	}
+
+	ops := make([]query.SubsetOperation, len(qs))
+	for i, q := range qs {
+		if q.err != nil {
+			return nil, q.err
+		}
+		ops[i] = q.op
+	}
+	return ops, nil
+}
+
+// Build returns the operation for the query or the first error that happened while it was build.
+func (q Query) Build() (query.SubsetOperation, error) {
+	if q.err != nil {
+		return query.SubsetOperation{}, q.err
+	}
+	if q.negated {
+		return query.SubsetOperation{}, errors.New("subset: a negation can only be used as argument of and")
+	}
+	return q.op, nil
+}
+
+// MarshalJSON returns the query in the format the getSubset call expects
+func (q Query) MarshalJSON() ([]byte, error) {
+	op, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(op)
+}
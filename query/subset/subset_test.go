@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package subset_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/query"
+	"github.com/ssbc/go-ssb/query/subset"
+)
+
+func TestBuilder(t *testing.T) {
+	r := require.New(t)
+
+	feed, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	const feedJSON = `"@AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=.ed25519"`
+
+	type tcase struct {
+		name string
+		qry  subset.Query
+		json string
+	}
+
+	cases := []tcase{
+		{"type", subset.Type("post"), `{"op":"type","string":"post"}`},
+		{"author", subset.Author(feed), `{"op":"author","feed":` + feedJSON + `}`},
+		{"author string", subset.AuthorString(feed.String()), `{"op":"author","feed":` + feedJSON + `}`},
+		{
+			"and",
+			subset.And(subset.Type("post"), subset.Author(feed)),
+			`{"op":"and","args":[{"op":"type","string":"post"},{"op":"author","feed":` + feedJSON + `}]}`,
+		},
+		{
+			"and not",
+			subset.And(subset.Type("post"), subset.Not(subset.Author(feed))),
+			`{"op":"and","args":[{"op":"type","string":"post"},{"op":"not","args":[{"op":"author","feed":` + feedJSON + `}]}]}`,
+		},
+		{
+			"nested or",
+			subset.Or(subset.Type("post"), subset.And(subset.Type("vote"), subset.Author(feed))),
+			`{"op":"or","args":[{"op":"type","string":"post"},{"op":"and","args":[{"op":"type","string":"vote"},{"op":"author","feed":` + feedJSON + `}]}]}`,
+		},
+	}
+
+	for _, tc := range cases {
+		out, err := json.Marshal(tc.qry)
+		r.NoError(err, tc.name)
+		r.Equal(tc.json, string(out), tc.name)
+
+		// the server needs to be able to parse it
+		var parsed query.SubsetOperation
+		r.NoError(json.Unmarshal(out, &parsed), tc.name)
+
+		built, err := tc.qry.Build()
+		r.NoError(err, tc.name)
+		r.Equal(built, parsed, tc.name)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	cases := map[string]subset.Query{
+		"empty type":       subset.Type(""),
+		"invalid author":   subset.AuthorString("@not-a-feed"),
+		"message as feed":  subset.AuthorString("%AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=.sha256"),
+		"nested error":     subset.Or(subset.Type("post"), subset.And(subset.Type("vote"), subset.AuthorString("nope"))),
+		"empty and":        subset.And(),
+		"only negated":     subset.And(subset.Not(subset.Type("post"))),
+		"negation in or":   subset.Or(subset.Type("post"), subset.Not(subset.Type("vote"))),
+		"double negation":  subset.And(subset.Type("post"), subset.Not(subset.Not(subset.Type("vote")))),
+		"toplevel negated": subset.Not(subset.Type("post")),
+	}
+
+	for name, qry := range cases {
+		_, err := qry.Build()
+		require.Error(t, err, name)
+
+		_, err = json.Marshal(qry)
+		require.Error(t, err, name)
+	}
+}
@@ -4,6 +4,7 @@
 
 // Package query holds the first version of a generic query engine for go-ssb.
 // The Subset operations are able to combine arbitrary boolen combinations of type:xzy and author:@foo filters into one result.
+// The query/subset package has a more convenient builder for these.
 package query
 
 import (
@@ -19,6 +20,11 @@ type SubsetOptions struct {
 	Keys       bool `json:"keys"` // can't omit this falsy value, the JS-stack stack assumes true if it's not there
 	Descending bool `json:"descending,omitempty"`
 	PageLimit  int  `json:"pageLimit,omitempty"`
+
+	// Live keeps the stream open and sends new messages that match the query after the stored ones.
+	// LiveOnly skips the stored messages and only sends the new ones.
+	Live     bool `json:"live,omitempty"`
+	LiveOnly bool `json:"liveOnly,omitempty"`
 }
 
 // SubsetOperation encapsulates the recursive structure of operations for the QuerySubset*() methods
@@ -50,6 +56,55 @@ func NewSubsetOrCombination(ops ...SubsetOperation) SubsetOperation {
 	return SubsetOperation{operation: "or", args: ops}
 }
 
+// NewSubsetNegation excludes the messages matched by op.
+// It can only be used as an argument of an and combination, which also has a positive argument.
+func NewSubsetNegation(op SubsetOperation) SubsetOperation {
+	return SubsetOperation{operation: "not", args: []SubsetOperation{op}}
+}
+
+// Matches reports whether a single message passes the operation. It is used to filter the live part of a subset stream.
+// Encrypted messages have no type, so they only match author operations.
+func (so SubsetOperation) Matches(msg refs.Message) bool {
+	switch so.operation {
+	case "author":
+		return so.feed != nil && msg.Author().Equal(*so.feed)
+
+	case "type":
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(msg.ContentBytes(), &typed); err != nil {
+			return false
+		}
+		return typed.Type == so.string
+
+	case "or":
+		for _, op := range so.args {
+			if op.Matches(msg) {
+				return true
+			}
+		}
+		return false
+
+	case "and":
+		if len(so.args) == 0 {
+			return false
+		}
+		for _, op := range so.args {
+			if !op.Matches(msg) {
+				return false
+			}
+		}
+		return true
+
+	case "not":
+		return len(so.args) == 1 && !so.args[0].Matches(msg)
+
+	default:
+		return false
+	}
+}
+
 // MarshalJSON turns a SubsetOperation into JSON for remote calls.
 func (so SubsetOperation) MarshalJSON() ([]byte, error) {
 	var m subsetOperationJSONMarshaler
@@ -78,6 +133,11 @@ func (so *SubsetOperation) UnmarshalJSON(input []byte) error {
 	switch m.Operation {
 	case "and", "or":
 		so.args = m.Args
+	case "not":
+		if len(m.Args) != 1 {
+			return fmt.Errorf("subset: not needs exactly one argument")
+		}
+		so.args = m.Args
 	case "type":
 		so.string = m.String
 	case "author":
@@ -80,10 +80,27 @@ func combineBitmaps(sp *SubsetPlaner, qry SubsetOperation) (*sroar.Bitmap, error
 			return nil, nil
 		}
 
+		// negations are subtracted from the result of the other arguments
+		args := qry.args
+		var negated []SubsetOperation
+		if qry.operation == "and" {
+			args = nil
+			for _, op := range qry.args {
+				if op.operation == "not" {
+					negated = append(negated, op.args[0])
+					continue
+				}
+				args = append(args, op)
+			}
+			if len(args) == 0 {
+				return nil, fmt.Errorf("boolean (and) operation needs at least one argument that isn't negated")
+			}
+		}
+
 		// run the first operation and use it's result as the workBitmap the rest will be applied to
-		workBitmap, err := combineBitmaps(sp, qry.args[0])
+		workBitmap, err := combineBitmaps(sp, args[0])
 		if err != nil {
-			return nil, fmt.Errorf("boolean (%s) operation %d of %d failed: %w", qry.operation, 1, len(qry.args), err)
+			return nil, fmt.Errorf("boolean (%s) operation %d of %d failed: %w", qry.operation, 1, len(args), err)
 		}
 
 		// choose the boolean operation that all arguments will use
@@ -92,19 +109,30 @@ func combineBitmaps(sp *SubsetPlaner, qry SubsetOperation) (*sroar.Bitmap, error
 			boolOp = workBitmap.And
 		}
 
-		for i, op := range qry.args[1:] {
+		for i, op := range args[1:] {
 
 			// get the bitmap for the current operation
 			opsBitmap, err := combineBitmaps(sp, op)
 			if err != nil {
-				return nil, fmt.Errorf("boolean (%s) operation %d of %d failed: %w", qry.operation, i+1, len(qry.args)-1, err)
+				return nil, fmt.Errorf("boolean (%s) operation %d of %d failed: %w", qry.operation, i+1, len(args)-1, err)
 			}
 
 			// apply the result to the workBitmap
 			boolOp(opsBitmap)
 		}
+
+		for i, op := range negated {
+			opsBitmap, err := combineBitmaps(sp, op)
+			if err != nil {
+				return nil, fmt.Errorf("boolean (not) operation %d of %d failed: %w", i+1, len(negated), err)
+			}
+			workBitmap.AndNot(opsBitmap)
+		}
 		return workBitmap, nil
 
+	case "not":
+		return nil, fmt.Errorf("sbot: invalid subset query: not is only supported as argument of and")
+
 	default:
 		return nil, fmt.Errorf("sbot: invalid subset query: %s", qry.operation)
 	}
@@ -73,6 +73,21 @@ func TestSubsetQuerySerializing(t *testing.T) {
 			jsonInput: `{"op":"and","args":[{"op":"author","feed":"@AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=.ed25519"},{"op":"or","args":[{"op":"type","string":"foo"},{"op":"type","string":"bar"}]}]}`,
 		},
 
+		{
+			name: "and not",
+			query: query.NewSubsetAndCombination(
+				query.NewSubsetOpByType("foo"),
+				query.NewSubsetNegation(query.NewSubsetOpByAuthor(testRef)),
+			),
+			jsonInput: `{"op":"and","args":[{"op":"type","string":"foo"},{"op":"not","args":[{"op":"author","feed":"@AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=.ed25519"}]}]}`,
+		},
+
+		{
+			name:      "not without argument",
+			jsonInput: `{"op":"not","args":[]}`,
+			invalid:   true,
+		},
+
 		{
 			name:      "invalid operation",
 			jsonInput: `{"op":"stuff","times":"over 9000"}`,
@@ -127,6 +142,44 @@ func (tc tcaseSerialized) run(t *testing.T) {
 	a.Equal(tc.jsonInput, string(out), "failed to create the wanted output")
 }
 
+func TestSubsetMatches(t *testing.T) {
+	a := assert.New(t)
+
+	alice, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	require.NoError(t, err)
+	bob, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	require.NoError(t, err)
+
+	msg := func(author refs.FeedRef, content string) refs.Message {
+		var kv refs.KeyValueRaw
+		kv.Value.Author = author
+		kv.Value.Content = json.RawMessage(content)
+		return kv
+	}
+	alicePost := msg(alice, `{"type":"post","text":"hi"}`)
+	bobVote := msg(bob, `{"type":"vote"}`)
+	boxed := msg(alice, `"c2VjcmV0.box"`)
+
+	posts := query.NewSubsetOpByType("post")
+	a.True(posts.Matches(alicePost))
+	a.False(posts.Matches(bobVote))
+	a.False(posts.Matches(boxed), "encrypted messages have no type")
+
+	byAlice := query.NewSubsetOpByAuthor(alice)
+	a.True(byAlice.Matches(alicePost))
+	a.True(byAlice.Matches(boxed))
+	a.False(byAlice.Matches(bobVote))
+
+	either := query.NewSubsetOrCombination(posts, query.NewSubsetOpByType("vote"))
+	a.True(either.Matches(alicePost))
+	a.True(either.Matches(bobVote))
+	a.False(either.Matches(boxed))
+
+	notAlice := query.NewSubsetAndCombination(either, query.NewSubsetNegation(byAlice))
+	a.False(notAlice.Matches(alicePost))
+	a.True(notAlice.Matches(bobVote))
+}
+
 func TestSubsetQueryPlanExecution(t *testing.T) {
 	r := require.New(t)
 
@@ -230,6 +283,24 @@ func TestSubsetQueryPlanExecution(t *testing.T) {
 		r.Equal(testRefs[6], res[2])
 	})
 
+	t.Run("about AND NOT by cloe", func(t *testing.T) {
+		r := require.New(t)
+
+		qry := query.NewSubsetAndCombination(
+			query.NewSubsetNegation(query.NewSubsetOpByAuthor(kpCloe.ID())),
+			query.NewSubsetOpByType("about"),
+		)
+		res, err := sp.QuerySubsetMessages(mainbot.ReceiveLog, qry)
+		r.NoError(err)
+		r.Len(res, 3, "wrong number of resulting messages")
+		r.Equal(testRefs[0], res[0])
+		r.Equal(testRefs[2], res[1])
+		r.Equal(testRefs[4], res[2])
+
+		_, err = sp.QuerySubsetMessages(mainbot.ReceiveLog, query.NewSubsetNegation(query.NewSubsetOpByType("about")))
+		r.Error(err, "a negation needs something to be subtracted from")
+	})
+
 	// shutdown bot
 	mainbot.Shutdown()
 	r.NoError(mainbot.Close())
@@ -0,0 +1,280 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package archive
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/margaret"
+
+	"github.com/ssbc/go-ssb/message/multimsg"
+)
+
+// Log wraps a receive log and reads nulled entries from the archive, if they are stored there.
+type Log struct {
+	multimsg.AlterableLog
+
+	store Store
+	codec margaret.Codec
+}
+
+// NewLog returns the wrapped log. The archived entries are decoded with cdc, which should be the codec of the log.
+func NewLog(log multimsg.AlterableLog, store Store, cdc margaret.Codec) *Log {
+	return &Log{
+		AlterableLog: log,
+		store:        store,
+		codec:        cdc,
+	}
+}
+
+// Store returns the archive of the log
+func (l *Log) Store() Store {
+	return l.store
+}
+
+// Get returns the entry from the log or from the archive, if it was nulled in the log.
+func (l *Log) Get(seq int64) (interface{}, error) {
+	v, err := l.AlterableLog.Get(seq)
+	if !margaret.IsErrNulled(err) {
+		return v, err
+	}
+
+	av, aerr := l.getArchived(seq)
+	if errors.Is(aerr, ErrNotFound) {
+		return nil, err
+	} else if aerr != nil {
+		return nil, aerr
+	}
+	return av, nil
+}
+
+func (l *Log) getArchived(seq int64) (interface{}, error) {
+	data, err := l.store.Get(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := l.codec.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to decode entry %d: %w", seq, err)
+	}
+	return v, nil
+}
+
+// Archive moves the entry seq from the log into the archive.
+// It returns the size of the entry and how much space it takes in the archive, both are zero if it was already nulled.
+func (l *Log) Archive(seq int64) (int, int, error) {
+	v, err := l.AlterableLog.Get(seq)
+	if margaret.IsErrNulled(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, fmt.Errorf("archive: failed to get entry %d: %w", seq, err)
+	}
+
+	var data []byte
+	if bm, ok := v.(encoding.BinaryMarshaler); ok {
+		data, err = bm.MarshalBinary()
+	} else {
+		data, err = l.codec.Marshal(v)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("archive: failed to encode entry %d: %w", seq, err)
+	}
+
+	// store it first, so that it's never gone if this is interrupted
+	compressed, err := l.store.Put(seq, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := l.AlterableLog.Null(seq); err != nil {
+		return 0, 0, fmt.Errorf("archive: failed to null entry %d: %w", seq, err)
+	}
+	return len(data), compressed, nil
+}
+
+// Null nulls the entry in the log and removes it from the archive, so that it is really gone.
+func (l *Log) Null(seq int64) error {
+	if err := l.AlterableLog.Null(seq); err != nil {
+		return err
+	}
+	return l.store.Delete(seq)
+}
+
+// Query returns the entries of the log, with archived ones in place of nulled entries.
+func (l *Log) Query(specs ...margaret.QuerySpec) (luigi.Source, error) {
+	probe := specProbe{limit: -1}
+	for _, spec := range specs {
+		spec(&probe)
+	}
+
+	// offset2 doesn't get past nulled entries in reverse, so these are read one by one
+	if probe.reverse && !probe.live {
+		next := l.AlterableLog.Seq()
+		if probe.hasTo && probe.to < next {
+			next = probe.to
+		}
+		var lower int64
+		if probe.hasFrom {
+			lower = probe.from
+		}
+		return &reverseQuery{
+			log:     l,
+			seqWrap: probe.seqWrap,
+			next:    next,
+			lower:   lower,
+			limit:   probe.limit,
+		}, nil
+	}
+
+	// the sequences are needed to look up the archived entries
+	src, err := l.AlterableLog.Query(append(specs, margaret.SeqWrap(true))...)
+	if err != nil {
+		return nil, err
+	}
+
+	// not all logs wrap nulled entries with their sequence, so we count along from the start
+	next := probe.start()
+	if probe.reverse {
+		cur := l.AlterableLog.Seq()
+		if next == margaret.SeqEmpty || cur < next {
+			next = cur
+		}
+	}
+
+	return &query{
+		src:     src,
+		log:     l,
+		seqWrap: probe.seqWrap,
+		reverse: probe.reverse,
+		next:    next,
+	}, nil
+}
+
+type query struct {
+	src luigi.Source
+	log *Log
+
+	seqWrap bool
+	reverse bool
+
+	// the sequence of the next entry
+	next int64
+}
+
+func (qry *query) Next(ctx context.Context) (interface{}, error) {
+	v, err := qry.src.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := qry.next
+	if sw, ok := v.(margaret.SeqWrapper); ok {
+		seq = sw.Seq()
+		v = sw.Value()
+	}
+
+	if qry.reverse {
+		qry.next = seq - 1
+	} else {
+		qry.next = seq + 1
+	}
+
+	if errv, ok := v.(error); ok && margaret.IsErrNulled(errv) {
+		av, err := qry.log.getArchived(seq)
+		if errors.Is(err, ErrNotFound) {
+			return margaret.ErrNulled, nil
+		} else if err != nil {
+			return nil, err
+		}
+		v = av
+	}
+
+	if qry.seqWrap {
+		return margaret.WrapWithSeq(v, seq), nil
+	}
+	return v, nil
+}
+
+// reverseQuery reads the entries from next down to lower with Get, which also returns archived entries
+type reverseQuery struct {
+	log *Log
+
+	seqWrap bool
+
+	next, lower int64
+
+	// how many entries are left to return, negative for no limit
+	limit int
+}
+
+func (qry *reverseQuery) Next(ctx context.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if qry.next < qry.lower || qry.limit == 0 {
+		return nil, luigi.EOS{}
+	}
+
+	seq := qry.next
+	qry.next--
+	if qry.limit > 0 {
+		qry.limit--
+	}
+
+	v, err := qry.log.Get(seq)
+	if margaret.IsErrNulled(err) {
+		v = margaret.ErrNulled
+	} else if err != nil {
+		return nil, err
+	}
+
+	if qry.seqWrap {
+		return margaret.WrapWithSeq(v, seq), nil
+	}
+	return v, nil
+}
+
+// specProbe records the query specs that are needed to know the sequence of each entry
+type specProbe struct {
+	// the first and last sequence that were asked for
+	from, to       int64
+	hasFrom, hasTo bool
+
+	reverse bool
+	seqWrap bool
+	live    bool
+
+	limit int
+}
+
+// start returns the first sequence of a forward query, or the upper bound of a reverse one (SeqEmpty if not set)
+func (p *specProbe) start() int64 {
+	if p.reverse {
+		if p.hasTo {
+			return p.to
+		}
+		return margaret.SeqEmpty
+	}
+
+	if p.hasFrom && p.from > 0 {
+		return p.from
+	}
+	return 0
+}
+
+func (p *specProbe) Gt(s int64) error  { p.from, p.hasFrom = s+1, true; return nil }
+func (p *specProbe) Gte(s int64) error { p.from, p.hasFrom = s, true; return nil }
+func (p *specProbe) Lt(s int64) error  { p.to, p.hasTo = s-1, true; return nil }
+func (p *specProbe) Lte(s int64) error { p.to, p.hasTo = s, true; return nil }
+
+func (p *specProbe) Limit(n int) error       { p.limit = n; return nil }
+func (p *specProbe) Live(yes bool) error     { p.live = yes; return nil }
+func (p *specProbe) Reverse(yes bool) error  { p.reverse = yes; return nil }
+func (p *specProbe) SeqWrap(wrap bool) error { p.seqWrap = wrap; return nil }
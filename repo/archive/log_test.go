@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/margaret"
+	mjson "github.com/ssbc/margaret/codec/json"
+	"github.com/ssbc/margaret/offset2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/message/multimsg"
+	"github.com/ssbc/go-ssb/repo/sqlitelog"
+)
+
+type testEvent struct {
+	Foo string
+	Bar int
+}
+
+func TestLog(t *testing.T) {
+	t.Run("offset2", testLog(func(path string, cdc margaret.Codec) (multimsg.AlterableLog, error) {
+		return offset2.Open(path, cdc)
+	}))
+
+	t.Run("sqlite", testLog(func(path string, cdc margaret.Codec) (multimsg.AlterableLog, error) {
+		return sqlitelog.Open(filepath.Join(path, "log.sqlite"), cdc)
+	}))
+}
+
+func testLog(open func(string, margaret.Codec) (multimsg.AlterableLog, error)) func(t *testing.T) {
+	return func(t *testing.T) {
+		testLogWith(t, open)
+	}
+}
+
+func testLogWith(t *testing.T, open func(string, margaret.Codec) (multimsg.AlterableLog, error)) {
+	r := require.New(t)
+
+	base := filepath.Join("testrun", t.Name())
+	os.RemoveAll(base)
+
+	cdc := mjson.New(&testEvent{})
+	inner, err := open(filepath.Join(base, "log"), cdc)
+	r.NoError(err)
+	defer inner.Close()
+
+	store := newMemStore()
+	log := NewLog(inner, store, cdc)
+
+	for i := 0; i < 6; i++ {
+		_, err := log.Append(testEvent{"hello", i})
+		r.NoError(err)
+	}
+
+	// move 1, 2 and 4 into the archive
+	for _, seq := range []int64{1, 2, 4} {
+		n, _, err := log.Archive(seq)
+		r.NoError(err)
+		r.True(n > 0)
+
+		_, err = inner.Get(seq)
+		r.True(margaret.IsErrNulled(err), "not nulled in the log: %v", err)
+	}
+
+	// moving it twice does nothing
+	n, _, err := log.Archive(1)
+	r.NoError(err)
+	r.Equal(0, n)
+
+	for i := int64(0); i < 6; i++ {
+		v, err := log.Get(i)
+		r.NoError(err, "entry %d", i)
+		r.EqualValues(i, v.(*testEvent).Bar)
+	}
+
+	// nulled entries that are not in the archive are returned as -1
+	readAll := func(specs ...margaret.QuerySpec) []int {
+		src, err := log.Query(specs...)
+		r.NoError(err)
+
+		// a query that gets stuck fails instead of hanging
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var bars []int
+		for {
+			v, err := src.Next(ctx)
+			if luigi.IsEOS(err) {
+				return bars
+			}
+			r.NoError(err)
+
+			if sw, ok := v.(margaret.SeqWrapper); ok {
+				if sw.Value() == margaret.ErrNulled {
+					bars = append(bars, -1)
+					continue
+				}
+				ev := sw.Value().(*testEvent)
+				r.EqualValues(sw.Seq(), ev.Bar, "wrong sequence")
+				v = ev
+			}
+			if v == margaret.ErrNulled {
+				bars = append(bars, -1)
+				continue
+			}
+			bars = append(bars, v.(*testEvent).Bar)
+		}
+	}
+
+	r.Equal([]int{0, 1, 2, 3, 4, 5}, readAll())
+	r.Equal([]int{0, 1, 2, 3, 4, 5}, readAll(margaret.SeqWrap(true)))
+	r.Equal([]int{2, 3, 4}, readAll(margaret.Gt(1), margaret.Limit(3)))
+	r.Equal([]int{1, 2}, readAll(margaret.Gte(1), margaret.Lt(3)))
+	r.Equal([]int{5, 4, 3, 2, 1, 0}, readAll(margaret.Reverse(true)))
+	r.Equal([]int{5, 4, 3, 2, 1, 0}, readAll(margaret.Reverse(true), margaret.SeqWrap(true)))
+	r.Equal([]int{3, 2}, readAll(margaret.Reverse(true), margaret.Lt(4), margaret.Limit(2)))
+	r.Equal([]int{4, 3, 2}, readAll(margaret.Reverse(true), margaret.Gte(2), margaret.Lte(4)))
+
+	// nulling removes it from the archive, too
+	r.NoError(log.Null(2))
+	_, err = store.Get(2)
+	r.ErrorIs(err, ErrNotFound)
+	_, err = log.Get(2)
+	r.True(margaret.IsErrNulled(err))
+
+	src, err := log.Query(margaret.Gte(2), margaret.Limit(1))
+	r.NoError(err)
+	v, err := src.Next(context.TODO())
+	r.NoError(err)
+	r.Equal(margaret.ErrNulled, v)
+
+	r.Equal([]int{3, -1, 1}, readAll(margaret.Reverse(true), margaret.Gte(1), margaret.Lte(3)))
+}
+
+// memStore keeps the archive in memory, without compression
+type memStore struct {
+	entries  map[int64][]byte
+	archived map[string]int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		entries:  make(map[int64][]byte),
+		archived: make(map[string]int64),
+	}
+}
+
+func (ms *memStore) Put(seq int64, data []byte) (int, error) {
+	ms.entries[seq] = append([]byte(nil), data...)
+	return len(data), nil
+}
+
+func (ms *memStore) Get(seq int64) ([]byte, error) {
+	data, has := ms.entries[seq]
+	if !has {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (ms *memStore) Delete(seq int64) error {
+	delete(ms.entries, seq)
+	return nil
+}
+
+func (ms *memStore) Archived(feed []byte) (int64, error) {
+	return ms.archived[string(feed)], nil
+}
+
+func (ms *memStore) SetArchived(feed []byte, n int64) error {
+	ms.archived[string(feed)] = n
+	return nil
+}
+
+func (ms *memStore) Close() error { return nil }
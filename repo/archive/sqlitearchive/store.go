@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+/*
+Package sqlitearchive implements archive.Store with a single sqlite database file.
+
+The entries are compressed and keyed by their receive log sequence.
+It uses the cgo sqlite3 driver, which is why it is not part of the archive package.
+*/
+package sqlitearchive
+
+import (
+	"bytes"
+	"compress/flate"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/ssbc/go-ssb/repo/archive"
+)
+
+var _ archive.Store = (*Store)(nil)
+
+// Store holds compressed entries and how far each feed was archived
+type Store struct {
+	path string
+	db   *sql.DB
+}
+
+// Open opens the archive in the database file at path, which is created if it doesn't exist.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("archive: error making directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to open sqlite file %s: %w", path, err)
+	}
+
+	var version int
+	err = db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("archive: schema version lookup failed %s: %w", path, err)
+	}
+	switch version {
+	case 0: // new file
+		if _, err := db.Exec(schemaVersion1); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("archive: failed to init schema v1: %w", err)
+		}
+	case 1:
+	default:
+		db.Close()
+		return nil, fmt.Errorf("archive: unsupported schema version %d", version)
+	}
+
+	return &Store{path: path, db: db}, nil
+}
+
+const schemaVersion1 = `
+CREATE TABLE entries (
+	seq INTEGER PRIMARY KEY,
+	data BLOB NOT NULL
+);
+
+CREATE TABLE feeds (
+	feed BLOB PRIMARY KEY,
+	archived INTEGER NOT NULL
+);
+
+PRAGMA user_version = 1;
+`
+
+// Exists returns true if there is an archive at path
+func Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Close closes the database
+func (st *Store) Close() error {
+	if err := st.db.Close(); err != nil {
+		return fmt.Errorf("archive: failed to close database: %w", err)
+	}
+	return nil
+}
+
+// FileName returns the path of the database file
+func (st *Store) FileName() string {
+	return st.path
+}
+
+// Put compresses data and stores it as the entry seq. It returns the compressed size.
+// Putting the same entry again overwrites it.
+func (st *Store) Put(seq int64, data []byte) (int, error) {
+	var buf bytes.Buffer
+	zw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return 0, fmt.Errorf("archive: failed to make compressor: %w", err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		return 0, fmt.Errorf("archive: failed to compress entry %d: %w", seq, err)
+	}
+	if err := zw.Close(); err != nil {
+		return 0, fmt.Errorf("archive: failed to compress entry %d: %w", seq, err)
+	}
+
+	_, err = st.db.Exec(`INSERT OR REPLACE INTO entries (seq, data) VALUES (?, ?)`, seq, buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("archive: failed to store entry %d: %w", seq, err)
+	}
+	return buf.Len(), nil
+}
+
+// Get returns the uncompressed data of the entry seq or archive.ErrNotFound
+func (st *Store) Get(seq int64) ([]byte, error) {
+	var compressed []byte
+	err := st.db.QueryRow(`SELECT data FROM entries WHERE seq = ?`, seq).Scan(&compressed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, archive.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("archive: failed to get entry %d: %w", seq, err)
+	}
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to decompress entry %d: %w", seq, err)
+	}
+	return data, nil
+}
+
+// Delete removes the entry seq, it is not an error if it doesn't exist.
+func (st *Store) Delete(seq int64) error {
+	_, err := st.db.Exec(`DELETE FROM entries WHERE seq = ?`, seq)
+	if err != nil {
+		return fmt.Errorf("archive: failed to delete entry %d: %w", seq, err)
+	}
+	return nil
+}
+
+// Archived returns how many of the oldest messages of feed were archived
+func (st *Store) Archived(feed []byte) (int64, error) {
+	var n int64
+	err := st.db.QueryRow(`SELECT archived FROM feeds WHERE feed = ?`, feed).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("archive: failed to get feed progress: %w", err)
+	}
+	return n, nil
+}
+
+// SetArchived records that the n oldest messages of feed were archived
+func (st *Store) SetArchived(feed []byte, n int64) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO feeds (feed, archived) VALUES (?, ?)`, feed, n)
+	if err != nil {
+		return fmt.Errorf("archive: failed to set feed progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sqlitearchive
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/repo/archive"
+)
+
+func TestStoreEntries(t *testing.T) {
+	r := require.New(t)
+
+	base := filepath.Join("testrun", t.Name())
+	os.RemoveAll(base)
+
+	store, err := Open(filepath.Join(base, "archive.sqlite"))
+	r.NoError(err)
+	defer store.Close()
+
+	data := bytes.Repeat([]byte("compress me "), 100)
+	n, err := store.Put(1, data)
+	r.NoError(err)
+	r.True(n < len(data), "should be compressed: %d", n)
+
+	got, err := store.Get(1)
+	r.NoError(err)
+	r.Equal(data, got)
+
+	r.NoError(store.Delete(1))
+	_, err = store.Get(1)
+	r.ErrorIs(err, archive.ErrNotFound)
+
+	// deleting it again is fine
+	r.NoError(store.Delete(1))
+}
+
+func TestStoreProgress(t *testing.T) {
+	r := require.New(t)
+
+	base := filepath.Join("testrun", t.Name())
+	os.RemoveAll(base)
+
+	path := filepath.Join(base, "archive.sqlite")
+	r.False(Exists(path))
+
+	store, err := Open(path)
+	r.NoError(err)
+	r.True(Exists(path))
+
+	feed := []byte("some feed")
+	n, err := store.Archived(feed)
+	r.NoError(err)
+	r.EqualValues(0, n)
+
+	r.NoError(store.SetArchived(feed, 23))
+
+	// reopen to make sure it's stored
+	r.NoError(store.Close())
+	store, err = Open(path)
+	r.NoError(err)
+	defer store.Close()
+
+	n, err = store.Archived(feed)
+	r.NoError(err)
+	r.EqualValues(23, n)
+}
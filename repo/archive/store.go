@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+/*
+Package archive keeps receive log entries that were moved out of the receive log to save space.
+
+The entries are kept in a Store, keyed by their receive log sequence. The sqlitearchive package has one
+that compresses them into a single sqlite database file.
+Wrapping the receive log with NewLog makes them readable again, so that moving them is transparent to the rest of the sbot.
+See sbot.WithFeedRetention for how entries end up in the archive.
+*/
+package archive
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Get if the entry is not in the archive
+var ErrNotFound = errors.New("archive: entry not found")
+
+// Store holds the archived entries and how far each feed was archived
+type Store interface {
+	// Put stores data as the entry seq and returns how much space it takes.
+	// Putting the same entry again overwrites it.
+	Put(seq int64, data []byte) (int, error)
+
+	// Get returns the data of the entry seq or ErrNotFound
+	Get(seq int64) ([]byte, error)
+
+	// Delete removes the entry seq, it is not an error if it doesn't exist.
+	Delete(seq int64) error
+
+	// Archived returns how many of the oldest messages of feed were archived
+	Archived(feed []byte) (int64, error)
+
+	// SetArchived records that the n oldest messages of feed were archived
+	SetArchived(feed []byte, n int64) error
+
+	io.Closer
+}
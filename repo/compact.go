@@ -0,0 +1,423 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ssbc/margaret/offset2"
+
+	"github.com/ssbc/go-ssb/message/multimsg"
+)
+
+// The compacted copy of an offset log is written to <log>.compacting and renamed to <log>.compacted once it is complete.
+// Opening the log replaces it with the compacted copy, the old log is moved to <log>.old during the swap.
+const (
+	suffixCompacting = ".compacting"
+	suffixCompacted  = ".compacted"
+	suffixOld        = ".old"
+)
+
+// nulledFrame is how a nulled entry is stored in the compacted log.
+// offset2 marks nulled entries with a negative size, followed by that many zeros.
+var nulledFrame = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}
+
+// CompactLog writes a copy of the offset log in dir which only keeps a marker for the nulled entries instead of their zeroed bytes.
+// The sequences of the entries stay the same, so the indexes and the archive are still valid for the copy.
+// It returns the number of bytes the copy is smaller than the log.
+//
+// The log can be in use while the copy is made. The copy only replaces it the next time it is opened with OpenLog,
+// which also brings over the entries that were appended, nulled or replaced in the meantime.
+// If that fails, the copy is dropped and the log is opened as it is.
+func CompactLog(dir string) (int64, error) {
+	tmpDir := dir + suffixCompacting
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to remove old temporary log: %w", err)
+	}
+	if err := os.RemoveAll(dir + suffixCompacted); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to remove previous compacted log: %w", err)
+	}
+
+	from, err := openLogFiles(dir, os.O_RDONLY)
+	if err != nil {
+		return 0, err
+	}
+	defer from.Close()
+
+	// entries appended after this are copied when the log is opened again
+	n, err := from.count()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to create temporary log: %w", err)
+	}
+	to, err := openLogFiles(tmpDir, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return 0, err
+	}
+	defer to.Close()
+
+	dataW := bufio.NewWriter(to.data)
+	ofstW := bufio.NewWriter(to.ofst)
+	var (
+		pos       int64
+		reclaimed int64
+	)
+	for seq := int64(0); seq < n; seq++ {
+		frame, err := from.frame(seq)
+		if err != nil {
+			return 0, err
+		}
+		if sz := frameSize(frame); sz < 0 {
+			reclaimed += int64(len(frame) - len(nulledFrame))
+			frame = nulledFrame
+		}
+
+		if err := binary.Write(ofstW, binary.BigEndian, pos); err != nil {
+			return 0, fmt.Errorf("repo/compact: failed to write offset: %w", err)
+		}
+		if _, err := dataW.Write(frame); err != nil {
+			return 0, fmt.Errorf("repo/compact: failed to write entry %d: %w", seq, err)
+		}
+		pos += int64(len(frame))
+	}
+	if err := dataW.Flush(); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to write data: %w", err)
+	}
+	if err := ofstW.Flush(); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to write offsets: %w", err)
+	}
+	if err := to.setJournal(n - 1); err != nil {
+		return 0, err
+	}
+	if err := to.Sync(); err != nil {
+		return 0, err
+	}
+	if err := to.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmpDir, dir+suffixCompacted); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to mark the log as compacted: %w", err)
+	}
+	return reclaimed, syncDir(filepath.Dir(dir))
+}
+
+// finishCompaction replaces the log in dir with its compacted copy, if CompactLog made one.
+// It is safe to call it again if it was interrupted.
+func finishCompaction(dir string) error {
+	oldDir := dir + suffixOld
+	compacted := dir + suffixCompacted
+
+	if err := os.RemoveAll(dir + suffixCompacting); err != nil {
+		return fmt.Errorf("repo/compact: failed to remove incomplete compacted log: %w", err)
+	}
+
+	// interrupted while swapping
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if _, err := os.Stat(oldDir); err == nil {
+			if err := os.Rename(oldDir, dir); err != nil {
+				return fmt.Errorf("repo/compact: failed to restore log: %w", err)
+			}
+		}
+	}
+	if err := os.RemoveAll(oldDir); err != nil {
+		return fmt.Errorf("repo/compact: failed to remove old log: %w", err)
+	}
+
+	if _, err := os.Stat(compacted); os.IsNotExist(err) {
+		return nil
+	}
+
+	// the log is still complete, so a copy that can't be caught up is dropped instead of failing to open
+	if err := catchUp(dir, compacted); err != nil {
+		log.Printf("repo/compact: discarding compacted copy of %s: %v", dir, err)
+		if err := os.RemoveAll(compacted); err != nil {
+			return fmt.Errorf("repo/compact: failed to remove compacted log: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.Rename(dir, oldDir); err != nil {
+		return fmt.Errorf("repo/compact: failed to move old log: %w", err)
+	}
+	if err := os.Rename(compacted, dir); err != nil {
+		return fmt.Errorf("repo/compact: failed to move compacted log: %w", err)
+	}
+	if err := syncDir(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(oldDir); err != nil {
+		return fmt.Errorf("repo/compact: failed to remove old log: %w", err)
+	}
+	return nil
+}
+
+// catchUp brings the changes to the log in dir since the compacted copy was made over to the copy
+func catchUp(dir, compacted string) error {
+	from, err := openLogFiles(dir, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := openLogFiles(compacted, os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	n, err := from.count()
+	if err != nil {
+		return err
+	}
+	copied, err := to.count()
+	if err != nil {
+		return err
+	}
+	if copied > n {
+		return fmt.Errorf("repo/compact: compacted log has more entries than the log (%d > %d)", copied, n)
+	}
+
+	// entries that were nulled or replaced in the meantime
+	for seq := int64(0); seq < copied; seq++ {
+		want, err := from.frame(seq)
+		if err != nil {
+			return err
+		}
+		has, err := to.frame(seq)
+		if err != nil {
+			return err
+		}
+
+		wantSz, hasSz := frameSize(want), frameSize(has)
+		switch {
+		case wantSz < 0 && hasSz < 0:
+			continue
+		case wantSz < 0:
+			// nulled afterwards, the copy keeps the space of it until the next compaction
+			want = make([]byte, len(has))
+			binary.BigEndian.PutUint64(want, uint64(-hasSz))
+		case hasSz < 0 || len(want) != len(has):
+			return fmt.Errorf("repo/compact: entry %d of the compacted log doesn't match the log", seq)
+		}
+		if bytes.Equal(want, has) {
+			continue
+		}
+
+		ofst, err := to.offset(seq)
+		if err != nil {
+			return err
+		}
+		if _, err := to.data.WriteAt(want, ofst); err != nil {
+			return fmt.Errorf("repo/compact: failed to update entry %d: %w", seq, err)
+		}
+	}
+
+	// entries that were appended in the meantime, after the end of the last complete one in case this was interrupted
+	var pos int64
+	if copied > 0 {
+		last, err := to.frame(copied - 1)
+		if err != nil {
+			return err
+		}
+		ofst, err := to.offset(copied - 1)
+		if err != nil {
+			return err
+		}
+		pos = ofst + int64(len(last))
+	}
+	if err := to.data.Truncate(pos); err != nil {
+		return fmt.Errorf("repo/compact: failed to truncate data: %w", err)
+	}
+	if err := to.ofst.Truncate(copied * 8); err != nil {
+		return fmt.Errorf("repo/compact: failed to truncate offsets: %w", err)
+	}
+	for seq := copied; seq < n; seq++ {
+		frame, err := from.frame(seq)
+		if err != nil {
+			return err
+		}
+		if frameSize(frame) < 0 {
+			frame = nulledFrame
+		}
+
+		// the data first, so that an offset never points past the end of it
+		if _, err := to.data.WriteAt(frame, pos); err != nil {
+			return fmt.Errorf("repo/compact: failed to write entry %d: %w", seq, err)
+		}
+		var ofst [8]byte
+		binary.BigEndian.PutUint64(ofst[:], uint64(pos))
+		if _, err := to.ofst.WriteAt(ofst[:], seq*8); err != nil {
+			return fmt.Errorf("repo/compact: failed to write offset: %w", err)
+		}
+		pos += int64(len(frame))
+	}
+
+	if err := to.setJournal(n - 1); err != nil {
+		return err
+	}
+	if err := to.Sync(); err != nil {
+		return err
+	}
+	if err := to.Close(); err != nil {
+		return err
+	}
+
+	// the copy is written by hand, make sure offset2 agrees with it before it replaces the log
+	return checkCompacted(compacted, n)
+}
+
+// checkCompacted opens the log in dir with offset2 and checks that it is consistent and has n entries
+func checkCompacted(dir string, n int64) error {
+	l, err := offset2.Open(dir, multimsg.MargaretCodec{})
+	if err != nil {
+		return fmt.Errorf("repo/compact: failed to open compacted log: %w", err)
+	}
+	defer l.Close()
+
+	if err := l.CheckConsistency(); err != nil {
+		return fmt.Errorf("repo/compact: compacted log is inconsistent: %w", err)
+	}
+	if seq := l.Seq(); seq != n-1 {
+		return fmt.Errorf("repo/compact: compacted log ends at %d instead of %d", seq, n-1)
+	}
+	return nil
+}
+
+// logFiles are the data and offset files of an offset2 log, see github.com/ssbc/margaret/offset2
+type logFiles struct {
+	dir              string
+	data, ofst, jrnl *os.File
+}
+
+func openLogFiles(dir string, flag int) (*logFiles, error) {
+	var (
+		lf    = &logFiles{dir: dir}
+		files = []struct {
+			name string
+			f    **os.File
+		}{
+			{"data", &lf.data},
+			{"ofst", &lf.ofst},
+			{"jrnl", &lf.jrnl},
+		}
+	)
+	for _, file := range files {
+		f, err := os.OpenFile(filepath.Join(dir, file.name), flag, 0600)
+		if err != nil {
+			lf.Close()
+			return nil, fmt.Errorf("repo/compact: failed to open log file: %w", err)
+		}
+		*file.f = f
+	}
+	return lf, nil
+}
+
+// count returns the number of entries in the offset file
+func (lf *logFiles) count() (int64, error) {
+	fi, err := lf.ofst.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to stat offset file: %w", err)
+	}
+	return fi.Size() / 8, nil
+}
+
+func (lf *logFiles) offset(seq int64) (int64, error) {
+	var buf [8]byte
+	if _, err := lf.ofst.ReadAt(buf[:], seq*8); err != nil {
+		return 0, fmt.Errorf("repo/compact: failed to read offset of entry %d in %s: %w", seq, lf.dir, err)
+	}
+	return int64(binary.BigEndian.Uint64(buf[:])), nil
+}
+
+// frame returns the size prefix and the data of the entry seq
+func (lf *logFiles) frame(seq int64) ([]byte, error) {
+	ofst, err := lf.offset(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf [8]byte
+	if _, err := lf.data.ReadAt(buf[:], ofst); err != nil {
+		return nil, fmt.Errorf("repo/compact: failed to read size of entry %d in %s: %w", seq, lf.dir, err)
+	}
+	sz := int64(binary.BigEndian.Uint64(buf[:]))
+	if sz < 0 {
+		sz = -sz
+	}
+
+	frame := make([]byte, 8+sz)
+	if _, err := lf.data.ReadAt(frame, ofst); err != nil {
+		return nil, fmt.Errorf("repo/compact: failed to read entry %d in %s: %w", seq, lf.dir, err)
+	}
+	return frame, nil
+}
+
+func (lf *logFiles) setJournal(seq int64) error {
+	if err := lf.jrnl.Truncate(0); err != nil {
+		return fmt.Errorf("repo/compact: failed to truncate journal: %w", err)
+	}
+	if seq < 0 {
+		return nil
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seq))
+	if _, err := lf.jrnl.WriteAt(buf[:], 0); err != nil {
+		return fmt.Errorf("repo/compact: failed to write journal: %w", err)
+	}
+	return nil
+}
+
+func (lf *logFiles) Sync() error {
+	for _, f := range []*os.File{lf.data, lf.ofst, lf.jrnl} {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("repo/compact: failed to sync %s: %w", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (lf *logFiles) Close() error {
+	var errs []error
+	for _, f := range []*os.File{lf.data, lf.ofst, lf.jrnl} {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("repo/compact: failed to close log files: %v", errs)
+	}
+	return nil
+}
+
+// frameSize returns the size prefix of a frame, it is negative for nulled entries
+func frameSize(frame []byte) int64 {
+	return int64(binary.BigEndian.Uint64(frame[:8]))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("repo/compact: failed to open directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("repo/compact: failed to sync directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ssbc/margaret"
+	"github.com/ssbc/margaret/codec/json"
+	"github.com/ssbc/margaret/offset2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactLog(t *testing.T) {
+	r := require.New(t)
+
+	dir := filepath.Join("testrun", t.Name(), "log")
+	os.RemoveAll(filepath.Dir(dir))
+
+	open := func() *offset2.OffsetLog {
+		// like OpenLog does
+		r.NoError(finishCompaction(dir))
+		l, err := offset2.Open(dir, json.New(""))
+		r.NoError(err)
+		return l
+	}
+
+	l := open()
+	big := strings.Repeat("x", 1000)
+	for i := 0; i < 10; i++ {
+		_, err := l.Append(big)
+		r.NoError(err)
+	}
+	r.NoError(l.Null(2))
+	r.NoError(l.Null(5))
+
+	dataSize := func() int64 {
+		fi, err := os.Stat(filepath.Join(dir, "data"))
+		r.NoError(err)
+		return fi.Size()
+	}
+	before := dataSize()
+
+	reclaimed, err := CompactLog(dir)
+	r.NoError(err)
+	r.True(reclaimed > 1900, "reclaimed %d", reclaimed)
+
+	// the log is still in use
+	_, err = l.Append("new")
+	r.NoError(err)
+	r.NoError(l.Null(7))
+	r.NoError(l.Replace(8, []byte(`"replaced"`)))
+	r.NoError(l.Close())
+
+	// an interrupted swap is picked up again
+	r.NoError(os.Rename(dir, dir+suffixOld))
+
+	l = open()
+	defer l.Close()
+	r.NoError(l.CheckConsistency())
+	r.EqualValues(10, l.Seq())
+
+	for seq := int64(0); seq <= l.Seq(); seq++ {
+		v, err := l.Get(seq)
+		switch seq {
+		case 2, 5, 7:
+			r.True(margaret.IsErrNulled(err), "entry %d: %v", seq, err)
+		case 8:
+			r.NoError(err)
+			r.Equal("replaced", v)
+		case 10:
+			r.NoError(err)
+			r.Equal("new", v)
+		default:
+			r.NoError(err, "entry %d", seq)
+			r.Equal(big, v)
+		}
+	}
+
+	// 7 was nulled after the copy was made, it keeps its space until the next compaction
+	r.Equal(before+8+int64(len(`"new"`))-reclaimed, dataSize())
+	_, err = os.Stat(dir + suffixCompacted)
+	r.True(os.IsNotExist(err))
+	_, err = os.Stat(dir + suffixOld)
+	r.True(os.IsNotExist(err))
+
+	// appending to the compacted log
+	seq, err := l.Append("after")
+	r.NoError(err)
+	r.EqualValues(11, seq)
+	r.NoError(l.Null(6))
+	r.NoError(l.CheckConsistency())
+}
+
+func TestCompactLogCatchUpFails(t *testing.T) {
+	r := require.New(t)
+
+	dir := filepath.Join("testrun", t.Name(), "log")
+	os.RemoveAll(filepath.Dir(dir))
+
+	r.NoError(finishCompaction(dir))
+	l, err := offset2.Open(dir, json.New(""))
+	r.NoError(err)
+	for i := 0; i < 5; i++ {
+		_, err := l.Append(strings.Repeat("x", 100))
+		r.NoError(err)
+	}
+	r.NoError(l.Null(1))
+
+	_, err = CompactLog(dir)
+	r.NoError(err)
+
+	_, err = l.Append("new")
+	r.NoError(err)
+	r.NoError(l.Close())
+
+	// a copy with more entries than the log can't be caught up
+	ofst, err := os.OpenFile(filepath.Join(dir+suffixCompacted, "ofst"), os.O_WRONLY|os.O_APPEND, 0600)
+	r.NoError(err)
+	_, err = ofst.Write(make([]byte, 8*10))
+	r.NoError(err)
+	r.NoError(ofst.Close())
+
+	// the copy is dropped and the log is used as it is
+	r.NoError(finishCompaction(dir))
+	_, err = os.Stat(dir + suffixCompacted)
+	r.True(os.IsNotExist(err))
+
+	l, err = offset2.Open(dir, json.New(""))
+	r.NoError(err)
+	defer l.Close()
+	r.NoError(l.CheckConsistency())
+	r.EqualValues(5, l.Seq())
+	_, err = l.Get(1)
+	r.True(margaret.IsErrNulled(err))
+	v, err := l.Get(5)
+	r.NoError(err)
+	r.Equal("new", v)
+}
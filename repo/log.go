@@ -18,8 +18,13 @@ func OpenLog(r Interface, path ...string) (multimsg.AlterableLog, error) {
 		path[0] = "logs"
 	}
 
+	logPath := r.GetPath(path...)
+	if err := finishCompaction(logPath); err != nil {
+		return nil, fmt.Errorf("failed to swap in compacted log: %w", err)
+	}
+
 	// TODO use proper log message type here
-	log, err := offset2.Open(r.GetPath(path...), multimsg.MargaretCodec{})
+	log, err := offset2.Open(logPath, multimsg.MargaretCodec{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log: %w", err)
 	}
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+/*
+Package sqlitelog implements a margaret log that is stored in a single sqlite database file.
+
+It can be used as the receive log of an sbot instead of the default offset log, see sbot.WithLogStore.
+The database runs in WAL mode, which makes it possible to back it up while the sbot is running, for instance with the sqlite3 .backup command.
+
+Every entry is a row of the entries table, keyed by its sequence. Nulled entries keep their row but have no data.
+*/
+package sqlitelog
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/margaret"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Log is a margaret log backed by sqlite. It also supports nulling and replacing entries.
+type Log struct {
+	l    sync.Mutex
+	path string
+	db   *sql.DB
+
+	seqCurrent int64
+	seqChanges luigi.Observable
+
+	codec margaret.Codec
+}
+
+var (
+	_ margaret.Log     = (*Log)(nil)
+	_ margaret.Alterer = (*Log)(nil)
+)
+
+// Open opens the log in the database file at path, which is created if it doesn't exist.
+// The entries are encoded with cdc.
+func Open(path string, cdc margaret.Codec) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("sqlitelog: error making log directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("sqlitelog: failed to open sqlite file %s: %w", path, err)
+	}
+
+	var version int
+	err = db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlitelog: schema version lookup failed %s: %w", path, err)
+	}
+	switch version {
+	case 0: // new file
+		if _, err := db.Exec(schemaVersion1); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("sqlitelog: failed to init schema v1: %w", err)
+		}
+	case 1:
+	default:
+		db.Close()
+		return nil, fmt.Errorf("sqlitelog: unsupported schema version %d", version)
+	}
+
+	var seq int64
+	err = db.QueryRow(`SELECT COALESCE(MAX(seq), -1) FROM entries`).Scan(&seq)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlitelog: failed to get current sequence: %w", err)
+	}
+
+	return &Log{
+		path: path,
+		db:   db,
+
+		seqCurrent: seq,
+		seqChanges: luigi.NewObservable(seq),
+
+		codec: cdc,
+	}, nil
+}
+
+const schemaVersion1 = `
+CREATE TABLE entries (
+	seq INTEGER PRIMARY KEY,
+	data BLOB
+);
+
+PRAGMA user_version = 1;
+`
+
+// Close closes the database
+func (log *Log) Close() error {
+	log.l.Lock()
+	defer log.l.Unlock()
+
+	if err := log.db.Close(); err != nil {
+		return fmt.Errorf("sqlitelog: failed to close database: %w", err)
+	}
+	return nil
+}
+
+// FileName returns the path of the database file
+func (log *Log) FileName() string {
+	return log.path
+}
+
+func (log *Log) Seq() int64 {
+	log.l.Lock()
+	defer log.l.Unlock()
+	return log.seqCurrent
+}
+
+func (log *Log) Changes() luigi.Observable {
+	return log.seqChanges
+}
+
+func (log *Log) Get(seq int64) (interface{}, error) {
+	log.l.Lock()
+	defer log.l.Unlock()
+
+	return log.get(seq)
+}
+
+func (log *Log) get(seq int64) (interface{}, error) {
+	if seq < 0 || seq > log.seqCurrent {
+		return nil, luigi.EOS{}
+	}
+
+	var data []byte
+	err := log.db.QueryRow(`SELECT data FROM entries WHERE seq = ?`, seq).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, luigi.EOS{}
+		}
+		return nil, fmt.Errorf("sqlitelog: failed to read entry %d: %w", seq, err)
+	}
+	return log.decode(seq, data)
+}
+
+func (log *Log) decode(seq int64, data []byte) (interface{}, error) {
+	if data == nil {
+		return nil, margaret.ErrNulled
+	}
+
+	v, err := log.codec.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("sqlitelog: error decoding data for seq(%d): %w", seq, err)
+	}
+	return v, nil
+}
+
+func (log *Log) Append(v interface{}) (int64, error) {
+	data, err := log.codec.Marshal(v)
+	if err != nil {
+		return margaret.SeqEmpty, fmt.Errorf("sqlitelog: error marshaling value: %w", err)
+	}
+	if data == nil { // NULL marks nulled entries
+		data = []byte{}
+	}
+
+	log.l.Lock()
+	defer log.l.Unlock()
+
+	seq := log.seqCurrent + 1
+	_, err = log.db.Exec(`INSERT INTO entries (seq, data) VALUES (?, ?)`, seq, data)
+	if err != nil {
+		return margaret.SeqEmpty, fmt.Errorf("sqlitelog: error appending entry %d: %w", seq, err)
+	}
+
+	log.seqCurrent = seq
+	log.seqChanges.Set(seq)
+	return seq, nil
+}
+
+// Null removes the data of the entry at seq. Reading it returns margaret.ErrNulled afterwards.
+func (log *Log) Null(seq int64) error {
+	log.l.Lock()
+	defer log.l.Unlock()
+
+	return log.update(seq, nil)
+}
+
+// Replace overwrites the data of the entry at seq
+func (log *Log) Replace(seq int64, data []byte) error {
+	log.l.Lock()
+	defer log.l.Unlock()
+
+	if data == nil {
+		data = []byte{}
+	}
+	return log.update(seq, data)
+}
+
+func (log *Log) update(seq int64, data []byte) error {
+	res, err := log.db.Exec(`UPDATE entries SET data = ? WHERE seq = ?`, data, seq)
+	if err != nil {
+		return fmt.Errorf("sqlitelog: failed to update entry %d: %w", seq, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlitelog: failed to update entry %d: %w", seq, err)
+	}
+	if n != 1 {
+		return fmt.Errorf("sqlitelog: no entry %d", seq)
+	}
+	return nil
+}
+
+func (log *Log) Query(specs ...margaret.QuerySpec) (luigi.Source, error) {
+	log.l.Lock()
+	defer log.l.Unlock()
+
+	qry := &query{
+		log: log,
+
+		gte: margaret.SeqEmpty,
+		lt:  margaret.SeqEmpty,
+
+		limit: -1, // i.e. no limit
+	}
+
+	for _, spec := range specs {
+		err := spec(qry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if qry.reverse && qry.live {
+		return nil, fmt.Errorf("sqlitelog: can't do reverse and live")
+	}
+
+	return qry, nil
+}
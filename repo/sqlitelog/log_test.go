@@ -0,0 +1,218 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sqlitelog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/margaret"
+	mjson "github.com/ssbc/margaret/codec/json"
+	"github.com/ssbc/margaret/offset2"
+	mtest "github.com/ssbc/margaret/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	os.RemoveAll("testrun")
+
+	newLog := func(name string, tipe interface{}) (margaret.Log, error) {
+		return Open(filepath.Join("testrun", name, "log.sqlite"), mjson.New(tipe))
+	}
+	t.Run("margaret", mtest.LogTest(newLog))
+}
+
+type testEvent struct {
+	Foo string
+	Bar int
+}
+
+func TestNullReplace(t *testing.T) {
+	r := require.New(t)
+
+	name := filepath.Join("testrun", t.Name(), "log.sqlite")
+	os.RemoveAll(filepath.Dir(name))
+
+	log, err := Open(name, mjson.New(&testEvent{}))
+	r.NoError(err)
+
+	for i := 0; i < 5; i++ {
+		seq, err := log.Append(testEvent{"hello", i})
+		r.NoError(err)
+		r.EqualValues(i, seq)
+	}
+
+	r.NoError(log.Null(1))
+	r.NoError(log.Null(1), "nulling twice should be fine")
+	r.NoError(log.Replace(3, []byte(`{"Foo":"replaced","Bar":3}`)))
+	r.Error(log.Null(5), "can't null past the end")
+
+	// reopen to make sure the changes are stored
+	r.NoError(log.Close())
+	log, err = Open(name, mjson.New(&testEvent{}))
+	r.NoError(err)
+	defer log.Close()
+
+	r.EqualValues(4, log.Seq())
+
+	_, err = log.Get(1)
+	r.True(margaret.IsErrNulled(err), "expected nulled entry, got %v", err)
+
+	v, err := log.Get(3)
+	r.NoError(err)
+	r.Equal("replaced", v.(*testEvent).Foo)
+
+	_, err = log.Get(5)
+	r.True(luigi.IsEOS(err))
+
+	src, err := log.Query(margaret.SeqWrap(true))
+	r.NoError(err)
+
+	var nulled int
+	for i := 0; ; i++ {
+		v, err := src.Next(context.TODO())
+		if luigi.IsEOS(err) {
+			r.Equal(5, i)
+			break
+		}
+		r.NoError(err)
+
+		if errv, ok := v.(error); ok && margaret.IsErrNulled(errv) {
+			nulled++
+			continue
+		}
+		r.EqualValues(i, v.(margaret.SeqWrapper).Seq())
+	}
+	r.Equal(1, nulled)
+
+	// a reverse query with bounds
+	src, err = log.Query(margaret.Gt(0), margaret.Lte(3), margaret.Reverse(true), margaret.Limit(2))
+	r.NoError(err)
+
+	v, err = src.Next(context.TODO())
+	r.NoError(err)
+	r.Equal("replaced", v.(*testEvent).Foo)
+
+	v, err = src.Next(context.TODO())
+	r.NoError(err)
+	r.Equal(2, v.(*testEvent).Bar)
+
+	_, err = src.Next(context.TODO())
+	r.True(luigi.IsEOS(err))
+}
+
+// the benchmarks compare the sqlite log with the offset log that is used by default
+
+type openFunc func(path string) (margaret.Log, error)
+
+var backends = []struct {
+	name string
+	open openFunc
+}{
+	{"offset2", func(path string) (margaret.Log, error) {
+		return offset2.Open(path, mjson.New(&testEvent{}))
+	}},
+	{"sqlite", func(path string) (margaret.Log, error) {
+		return Open(filepath.Join(path, "log.sqlite"), mjson.New(&testEvent{}))
+	}},
+}
+
+func BenchmarkAppend(b *testing.B) {
+	for _, be := range backends {
+		b.Run(be.name, func(b *testing.B) {
+			r := require.New(b)
+
+			path := filepath.Join("testrun", b.Name())
+			os.RemoveAll(path)
+			defer os.RemoveAll(path)
+
+			log, err := be.open(path)
+			r.NoError(err)
+			defer log.(interface{ Close() error }).Close()
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, err := log.Append(testEvent{"hello", n})
+				r.NoError(err)
+			}
+		})
+	}
+}
+
+func BenchmarkReadSequential(b *testing.B) {
+	for _, be := range backends {
+		for _, size := range []int{100, 5000} {
+			b.Run(fmt.Sprintf("%s/%d", be.name, size), benchSequential(be.open, size))
+		}
+	}
+}
+
+func benchSequential(open openFunc, size int) func(b *testing.B) {
+	return func(b *testing.B) {
+		r := require.New(b)
+
+		path := filepath.Join("testrun", b.Name())
+		os.RemoveAll(path)
+		defer os.RemoveAll(path)
+
+		log, err := open(path)
+		r.NoError(err)
+		defer log.(interface{ Close() error }).Close()
+
+		for i := 0; i < size; i++ {
+			_, err := log.Append(testEvent{"hello", i})
+			r.NoError(err)
+		}
+
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			src, err := log.Query()
+			r.NoError(err)
+
+			var i int
+			for {
+				_, err := src.Next(context.TODO())
+				if luigi.IsEOS(err) {
+					break
+				}
+				r.NoError(err)
+				i++
+			}
+			r.Equal(size, i)
+		}
+	}
+}
+
+func BenchmarkReadRandom(b *testing.B) {
+	for _, be := range backends {
+		b.Run(be.name, func(b *testing.B) {
+			r := require.New(b)
+
+			path := filepath.Join("testrun", b.Name())
+			os.RemoveAll(path)
+			defer os.RemoveAll(path)
+
+			log, err := be.open(path)
+			r.NoError(err)
+			defer log.(interface{ Close() error }).Close()
+
+			const size = 5000
+			for i := 0; i < size; i++ {
+				_, err := log.Append(testEvent{"hello", i})
+				r.NoError(err)
+			}
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, err := log.Get(int64((n * 7919) % size))
+				r.NoError(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sqlitelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/margaret"
+)
+
+// how many entries are read from the database at once
+const batchSize = 256
+
+type entry struct {
+	seq  int64
+	data []byte
+}
+
+type query struct {
+	l   sync.Mutex
+	log *Log
+
+	// gte is the lower bound and lt the upper bound, both are SeqEmpty if not set
+	gte, lt int64
+
+	limit   int
+	live    bool
+	seqWrap bool
+	reverse bool
+
+	started bool
+	next    int64
+	buf     []entry
+}
+
+func (qry *query) Gt(s int64) error {
+	if qry.gte != margaret.SeqEmpty {
+		return fmt.Errorf("lower bound already set")
+	}
+	qry.gte = s + 1
+	return nil
+}
+
+func (qry *query) Gte(s int64) error {
+	if qry.gte != margaret.SeqEmpty {
+		return fmt.Errorf("lower bound already set")
+	}
+	qry.gte = s
+	return nil
+}
+
+func (qry *query) Lt(s int64) error {
+	if qry.lt != margaret.SeqEmpty {
+		return fmt.Errorf("upper bound already set")
+	}
+	qry.lt = s
+	return nil
+}
+
+func (qry *query) Lte(s int64) error {
+	if qry.lt != margaret.SeqEmpty {
+		return fmt.Errorf("upper bound already set")
+	}
+	qry.lt = s + 1
+	return nil
+}
+
+func (qry *query) Limit(n int) error {
+	qry.limit = n
+	return nil
+}
+
+func (qry *query) Live(live bool) error {
+	qry.live = live
+	return nil
+}
+
+func (qry *query) SeqWrap(wrap bool) error {
+	qry.seqWrap = wrap
+	return nil
+}
+
+func (qry *query) Reverse(yes bool) error {
+	qry.reverse = yes
+	return nil
+}
+
+func (qry *query) Next(ctx context.Context) (interface{}, error) {
+	qry.l.Lock()
+	defer qry.l.Unlock()
+
+	if qry.limit == 0 {
+		return nil, luigi.EOS{}
+	}
+
+	if len(qry.buf) == 0 {
+		if err := qry.fill(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	e := qry.buf[0]
+	qry.buf = qry.buf[1:]
+	qry.limit--
+
+	v, err := qry.log.decode(e.seq, e.data)
+	if errors.Is(err, margaret.ErrNulled) {
+		return margaret.ErrNulled, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if qry.seqWrap {
+		return margaret.WrapWithSeq(v, e.seq), nil
+	}
+	return v, nil
+}
+
+// fill reads the next batch of entries into buf.
+// It returns luigi.EOS if there are none left or waits for new ones if the query is live.
+func (qry *query) fill(ctx context.Context) error {
+	lower := qry.gte
+	if lower < 0 {
+		lower = 0
+	}
+
+	for {
+		qry.log.l.Lock()
+
+		// where to start is decided when the first value is read, like the other margaret logs
+		if !qry.started {
+			qry.started = true
+			qry.next = lower
+			if qry.reverse {
+				qry.next = qry.log.seqCurrent
+				if qry.lt != margaret.SeqEmpty && qry.lt-1 < qry.next {
+					qry.next = qry.lt - 1
+				}
+			}
+		}
+
+		if qry.reverse {
+			if qry.next < lower {
+				qry.log.l.Unlock()
+				return luigi.EOS{}
+			}
+			err := qry.read(`SELECT seq, data FROM entries WHERE seq <= ? AND seq >= ? ORDER BY seq DESC LIMIT ?`,
+				qry.next, lower)
+			qry.log.l.Unlock()
+			return err
+		}
+
+		if qry.lt != margaret.SeqEmpty && qry.next >= qry.lt {
+			qry.log.l.Unlock()
+			return luigi.EOS{}
+		}
+
+		if qry.next <= qry.log.seqCurrent {
+			upper := qry.log.seqCurrent + 1
+			if qry.lt != margaret.SeqEmpty && qry.lt < upper {
+				upper = qry.lt
+			}
+			err := qry.read(`SELECT seq, data FROM entries WHERE seq >= ? AND seq < ? ORDER BY seq ASC LIMIT ?`,
+				qry.next, upper)
+			qry.log.l.Unlock()
+			return err
+		}
+
+		if !qry.live {
+			qry.log.l.Unlock()
+			return luigi.EOS{}
+		}
+
+		// wait until the next entry is appended
+		var (
+			wait = make(chan struct{})
+			once sync.Once
+			next = qry.next
+		)
+		cancel := qry.log.seqChanges.Register(luigi.FuncSink(func(ctx context.Context, v interface{}, err error) error {
+			if err != nil {
+				return nil
+			}
+			if seq, ok := v.(int64); ok && seq >= next {
+				once.Do(func() { close(wait) })
+			}
+			return nil
+		}))
+		qry.log.l.Unlock()
+
+		select {
+		case <-wait:
+			cancel()
+		case <-ctx.Done():
+			cancel()
+			return ctx.Err()
+		}
+	}
+}
+
+// read runs the passed query with the two bounds and the batch size and puts the entries into buf.
+// It expects log.l to be locked.
+func (qry *query) read(stmt string, a, b int64) error {
+	n := batchSize
+	if qry.limit > 0 && qry.limit < n {
+		n = qry.limit
+	}
+
+	rows, err := qry.log.db.Query(stmt, a, b, n)
+	if err != nil {
+		return fmt.Errorf("sqlitelog: failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.seq, &e.data); err != nil {
+			return fmt.Errorf("sqlitelog: failed to read entry: %w", err)
+		}
+		qry.buf = append(qry.buf, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sqlitelog: failed to read entries: %w", err)
+	}
+
+	if len(qry.buf) == 0 {
+		return luigi.EOS{}
+	}
+
+	last := qry.buf[len(qry.buf)-1].seq
+	if qry.reverse {
+		qry.next = last - 1
+	} else {
+		qry.next = last + 1
+	}
+	return nil
+}
@@ -22,6 +22,16 @@ type Publisher interface {
 	Publish(content interface{}) (refs.Message, error)
 }
 
+// BatchPublisher publishes multiple messages as a contiguous run of the feed
+type BatchPublisher interface {
+	PublishBatch(contents []interface{}) ([]refs.MessageRef, error)
+}
+
+// DryRunPublisher signs the next message of the feed without storing it
+type DryRunPublisher interface {
+	DryRunPublish(content interface{}) (refs.Message, error)
+}
+
 type Getter interface {
 	Get(refs.MessageRef) (refs.Message, error)
 }
@@ -87,6 +97,21 @@ type IndexState struct {
 	State string
 }
 
+// DiskUsager reports how many bytes the parts of the repo take up on disk
+type DiskUsager interface {
+	DiskUsage() (DiskReport, error)
+}
+
+// DiskReport is the disk usage of a repo in bytes
+type DiskReport struct {
+	Log         int64            `json:"log"`         // the offset log with all the messages
+	Indexes     map[string]int64 `json:"indexes"`     // by their path in the repo, like sublogs/userFeeds
+	Blobs       int64            `json:"blobs"`       // the blob store
+	StateMatrix int64            `json:"stateMatrix"` // the ebt state matrix
+	Other       int64            `json:"other"`       // everything else, like keys and the archive
+	Total       int64            `json:"total"`
+}
+
 type ContentNuller interface {
 	NullContent(feed refs.FeedRef, seq uint) error
 }
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb/graph"
+)
+
+// the reasons in the audit log, why a connection was accepted or refused
+const (
+	auditReasonSelf         = "self"
+	auditReasonPolicyNever  = "conn-policy-never"
+	auditReasonPolicyAlways = "conn-policy-always"
+	auditReasonInvite       = "invite"
+	auditReasonAuthorizer   = "authorizer"
+	auditReasonPromiscuous  = "promiscuous"
+	auditReasonInReach      = "in-reach"
+	auditReasonOutOfReach   = "out-of-reach"
+	auditReasonBlocked      = "blocked"
+	auditReasonTOFU         = "trust-on-first-use"
+	auditReasonTOFUDisabled = "trust-on-first-use-disabled"
+	auditReasonRoomGuest    = "room-guest"
+)
+
+// AuditEntry is one line of the audit log, see WithAuditLog
+type AuditEntry struct {
+	Time     time.Time    `json:"time"`
+	Remote   refs.FeedRef `json:"remote"`
+	Accepted bool         `json:"accepted"`
+	Reason   string       `json:"reason"`
+
+	// Hops is the distance to the remote in the follow graph.
+	// It is only set if the decision was made by the graph and the remote is connected to it.
+	Hops *int `json:"hops,omitempty"`
+
+	// Error is why the remote was refused
+	Error string `json:"error,omitempty"`
+}
+
+type auditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+
+	// the shortest paths from self are kept until the graph changes, see auditHops
+	lookupMu    sync.Mutex
+	lookupGraph *graph.Graph
+	lookupSeq   int64
+	lookup      *graph.Lookup
+}
+
+func newAuditLog(w io.Writer) *auditLog {
+	return &auditLog{enc: json.NewEncoder(w)}
+}
+
+func (al *auditLog) write(e AuditEntry) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	return al.enc.Encode(e)
+}
+
+// auditAuthorization writes the decision about the connection with remote to the audit log, if there is one
+func (s *Sbot) auditAuthorization(remote refs.FeedRef, reason string, authErr error) {
+	if s.audit == nil {
+		return
+	}
+
+	e := AuditEntry{
+		Time:     time.Now(),
+		Remote:   remote,
+		Accepted: authErr == nil,
+		Reason:   reason,
+	}
+	if authErr != nil {
+		e.Error = authErr.Error()
+	}
+
+	if reason == auditReasonInReach || reason == auditReasonOutOfReach {
+		hops, blocked := s.auditHops(remote)
+		if blocked {
+			e.Reason = auditReasonBlocked
+		} else if hops >= 0 {
+			e.Hops = &hops
+		}
+	}
+
+	if err := s.audit.write(e); err != nil {
+		level.Warn(s.info).Log("event", "failed to write audit log", "err", err)
+	}
+}
+
+// auditHops returns the distance to remote in the follow graph, -1 if it isn't connected to it, and if we block it.
+// The error of the authorizer can't be used for this since it's about the last feed format that was tried.
+func (s *Sbot) auditHops(remote refs.FeedRef) (int, bool) {
+	if s.GraphBuilder == nil {
+		return -1, false
+	}
+	fg, err := s.GraphBuilder.Build()
+	if err != nil {
+		return -1, false
+	}
+
+	self := s.KeyPair.ID()
+	if fg.Blocks(self, remote) {
+		return -1, true
+	}
+
+	al := s.audit
+	al.lookupMu.Lock()
+	defer al.lookupMu.Unlock()
+
+	// patched graphs keep their pointer but not their sequence, rebuilt ones get a new pointer
+	if fg != al.lookupGraph || fg.Seq() != al.lookupSeq || al.lookup == nil {
+		al.lookup, err = fg.MakeDijkstra(self)
+		if err != nil {
+			al.lookup = nil
+			return -1, false
+		}
+		al.lookupGraph, al.lookupSeq = fg, fg.Seq()
+	}
+
+	p, d := al.lookup.Dist(remote)
+	if math.IsInf(d, 0) || len(p) < 2 {
+		return -1, false
+	}
+	return len(p) - 2, false
+}
@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	"go.mindeco.de/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ssbc/go-ssb"
+)
+
+func TestWithAuthorizer(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	botgroup, ctx := errgroup.WithContext(ctx)
+	bs := newBotServer(ctx, log.NewNopLogger())
+
+	alice := makeNamedTestBot(t, "alice", nil)
+	botgroup.Go(bs.Serve(alice))
+
+	bob := makeNamedTestBot(t, "bob", nil)
+	botgroup.Go(bs.Serve(bob))
+
+	var (
+		mu    sync.Mutex
+		asked []refs.FeedRef
+	)
+	allowlist := ssb.AuthorizerFunc(func(remote refs.FeedRef) error {
+		mu.Lock()
+		asked = append(asked, remote)
+		mu.Unlock()
+
+		if remote.Equal(alice.KeyPair.ID()) {
+			return nil
+		}
+		return fmt.Errorf("not on the list")
+	})
+
+	// even a promiscuous pub only lets alice in
+	pub := makeNamedTestBot(t, "pub", []Option{
+		WithPromisc(true),
+		WithAuthorizer(allowlist),
+	})
+	botgroup.Go(bs.Serve(pub))
+
+	r.NoError(alice.Network.Connect(ctx, pub.Network.GetListenAddr()))
+	r.Eventually(func() bool {
+		return pub.Network.GetConnTracker().Count() == 1
+	}, 5*time.Second, 50*time.Millisecond, "alice didn't get in")
+
+	bob.Network.Connect(ctx, pub.Network.GetListenAddr())
+	r.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(asked) == 2
+	}, 5*time.Second, 50*time.Millisecond, "bob wasn't checked")
+
+	// the connection is tracked before it is authorized, the refused one is closed right after
+	r.Eventually(func() bool {
+		active, _ := pub.Network.GetConnTracker().Active(bob.Network.GetListenAddr())
+		return !active
+	}, 5*time.Second, 50*time.Millisecond, "bob's connection wasn't closed")
+	r.EqualValues(1, pub.Network.GetConnTracker().Count(), "alice should still be connected")
+
+	mu.Lock()
+	r.True(asked[1].Equal(bob.KeyPair.ID()))
+	mu.Unlock()
+
+	pub.Shutdown()
+	alice.Shutdown()
+	bob.Shutdown()
+
+	r.NoError(pub.Close())
+	r.NoError(alice.Close())
+	r.NoError(bob.Close())
+
+	cancel()
+	r.NoError(botgroup.Wait())
+}
+
+func TestAuditLog(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	alice := tn.newBot("alice")
+	bob := tn.newBot("bob")
+	claire := tn.newBot("claire")
+
+	auditPath := filepath.Join("testrun", t.Name(), "audit.log")
+	auditFile, err := os.Create(auditPath)
+	r.NoError(err)
+	defer auditFile.Close()
+
+	pub := tn.newBot("pub", WithAuditLog(auditFile))
+
+	_, err = pub.PublishLog.Publish(refs.NewContactFollow(alice.KeyPair.ID()))
+	r.NoError(err)
+	_, err = pub.PublishLog.Publish(refs.NewContactBlock(bob.KeyPair.ID()))
+	r.NoError(err)
+	r.Eventually(func() bool {
+		return pub.Replicator.Lister().ReplicationList().Has(alice.KeyPair.ID())
+	}, 10*time.Second, 50*time.Millisecond, "alice should be replicated")
+
+	for i, bot := range []*Sbot{alice, bob, claire} {
+		bot.Network.Connect(tn.ctx, pub.Network.GetListenAddr())
+		r.Eventually(func() bool {
+			return len(readAuditLog(t, auditPath)) == i+1
+		}, 5*time.Second, 50*time.Millisecond, "no entry for connection %d", i)
+	}
+
+	entries := readAuditLog(t, auditPath)
+
+	r.True(entries[0].Remote.Equal(alice.KeyPair.ID()))
+	r.True(entries[0].Accepted)
+	r.Equal(auditReasonInReach, entries[0].Reason)
+	r.NotNil(entries[0].Hops)
+	r.Equal(0, *entries[0].Hops)
+	r.Empty(entries[0].Error)
+	r.False(entries[0].Time.IsZero())
+
+	r.True(entries[1].Remote.Equal(bob.KeyPair.ID()))
+	r.False(entries[1].Accepted)
+	r.Equal(auditReasonBlocked, entries[1].Reason)
+	r.Nil(entries[1].Hops)
+	r.NotEmpty(entries[1].Error)
+
+	r.True(entries[2].Remote.Equal(claire.KeyPair.ID()))
+	r.False(entries[2].Accepted)
+	r.Equal(auditReasonOutOfReach, entries[2].Reason)
+	r.Nil(entries[2].Hops, "claire isn't in the graph")
+
+	tn.close()
+}
+
+func TestDisableTOFU(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	alice := tn.newBot("alice")
+	bob := tn.newBot("bob")
+
+	auditPath := filepath.Join("testrun", t.Name(), "audit.log")
+	auditFile, err := os.Create(auditPath)
+	r.NoError(err)
+	defer auditFile.Close()
+
+	// the pub has no stored feeds, only alice is allowed explicitly
+	pub := tn.newBot("pub",
+		WithDisableTOFU(),
+		WithAuditLog(auditFile),
+		WithPeerPolicies(PeerPolicy{Peer: alice.KeyPair.ID(), Policy: ConnPolicyAlways}),
+	)
+
+	for i, bot := range []*Sbot{alice, bob} {
+		bot.Network.Connect(tn.ctx, pub.Network.GetListenAddr())
+		r.Eventually(func() bool {
+			return len(readAuditLog(t, auditPath)) == i+1
+		}, 5*time.Second, 50*time.Millisecond, "no entry for connection %d", i)
+	}
+
+	entries := readAuditLog(t, auditPath)
+
+	r.True(entries[0].Remote.Equal(alice.KeyPair.ID()))
+	r.True(entries[0].Accepted)
+	r.Equal(auditReasonPolicyAlways, entries[0].Reason)
+
+	r.True(entries[1].Remote.Equal(bob.KeyPair.ID()))
+	r.False(entries[1].Accepted)
+	r.Equal(auditReasonTOFUDisabled, entries[1].Reason)
+	r.NotEmpty(entries[1].Error)
+
+	r.Eventually(func() bool {
+		active, _ := pub.Network.GetConnTracker().Active(bob.Network.GetListenAddr())
+		return !active
+	}, 5*time.Second, 50*time.Millisecond, "bob's connection wasn't closed")
+	r.EqualValues(1, pub.Network.GetConnTracker().Count(), "alice should still be connected")
+
+	tn.close()
+}
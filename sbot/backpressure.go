@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-ssb"
+)
+
+// ErrPublishBusy is returned by publishing when the indexes are too far behind, see WithPublishBackpressure
+var ErrPublishBusy = errors.New("sbot: publish busy, the indexes are behind")
+
+// backpressurePoll is how often a blocked publish checks the backlog again
+const backpressurePoll = 10 * time.Millisecond
+
+// WithPublishBackpressure makes publishing wait while more than max messages of the receive log are not indexed yet,
+// see IndexBacklog. This keeps the memory of bulk imports in check, if messages are appended quicker than they are indexed.
+// It needs DisableLiveIndexMode: live indexes process every message while it is appended to the receive log,
+// so appending already waits for them and there is no backlog to hold back.
+// With WithPublishBusyError it returns ErrPublishBusy instead of waiting.
+func WithPublishBackpressure(max int) Option {
+	return func(s *Sbot) error {
+		if max < 1 {
+			return fmt.Errorf("WithPublishBackpressure: max needs to be at least one")
+		}
+		s.publishBacklogMax = max
+		return nil
+	}
+}
+
+// WithPublishBusyError makes publishing fail with ErrPublishBusy instead of waiting, see WithPublishBackpressure
+func WithPublishBusyError() Option {
+	return func(s *Sbot) error {
+		s.publishBusyError = true
+		return nil
+	}
+}
+
+// IndexBacklog returns how many messages of the receive log the slowest index still needs to process.
+// Indexes which only process some of the messages, like the contacts, are not included.
+func (s *Sbot) IndexBacklog() int64 {
+	total := atomic.LoadInt64(&s.receiveLogHead) + 1
+
+	s.indexStateMu.Lock()
+	defer s.indexStateMu.Unlock()
+
+	var backlog int64
+	for _, ps := range s.indexProgressSinks {
+		if behind := total - ps.N(); behind > backlog {
+			backlog = behind
+		}
+	}
+	return backlog
+}
+
+// trackReceiveLogHead keeps receiveLogHead up to date.
+// The log is locked while the indexes catch up and its changes are sent to the live indexes with the observable locked,
+// so IndexBacklog can't ask the log while it is called from the progress of an index.
+func (s *Sbot) trackReceiveLogHead() {
+	atomic.StoreInt64(&s.receiveLogHead, s.ReceiveLog.Seq())
+	done := s.ReceiveLog.Changes().Register(luigi.FuncSink(func(ctx context.Context, v interface{}, err error) error {
+		if err != nil {
+			return nil
+		}
+		if seq, ok := v.(int64); ok {
+			atomic.StoreInt64(&s.receiveLogHead, seq)
+		}
+		return nil
+	}))
+	go func() {
+		<-s.rootCtx.Done()
+		done()
+	}()
+}
+
+// updateIndexBacklogGauge sets the "index-backlog" gauge, it is called by the progress of the indexes
+func (s *Sbot) updateIndexBacklogGauge() {
+	if s.systemGauge != nil {
+		s.systemGauge.With("part", "index-backlog").Set(float64(s.IndexBacklog()))
+	}
+}
+
+// publishBackpressure is the part of the publish gate for WithPublishBackpressure, it also updates the "index-backlog" gauge
+func (s *Sbot) publishBackpressure() error {
+	for {
+		backlog := s.IndexBacklog()
+		if s.systemGauge != nil {
+			s.systemGauge.With("part", "index-backlog").Set(float64(backlog))
+		}
+		if backlog <= int64(s.publishBacklogMax) {
+			return nil
+		}
+
+		if s.publishBusyError {
+			if s.eventCounter != nil {
+				s.eventCounter.With("event", "publish-busy").Add(1)
+			}
+			return fmt.Errorf("%w (%d messages not indexed)", ErrPublishBusy, backlog)
+		}
+
+		select {
+		case <-s.rootCtx.Done():
+			return ssb.ErrShuttingDown
+		case <-time.After(backpressurePoll):
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestPublishBackpressure(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	open := func(opts ...Option) *Sbot {
+		bot, err := New(append([]Option{
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+			// without live updates the backlog grows with every message
+			DisableLiveIndexMode(),
+			WithPublishBackpressure(2),
+		}, opts...)...)
+		r.NoError(err)
+		return bot
+	}
+
+	// live indexes hold back appending already
+	_, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithPublishBackpressure(2),
+	)
+	r.Error(err)
+
+	bot := open(WithPublishBusyError())
+	bot.WaitUntilIndexesAreSynced()
+	r.EqualValues(0, bot.IndexBacklog())
+
+	for i := 0; i < 3; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("filling the backlog"))
+		r.NoError(err, "publish %d", i)
+	}
+	r.EqualValues(3, bot.IndexBacklog())
+
+	_, err = bot.PublishLog.Publish(refs.NewPost("one too many"))
+	r.True(errors.Is(err, ErrPublishBusy), "unexpected error: %v", err)
+	_, err = bot.PublishBatch([]interface{}{refs.NewPost("batched")})
+	r.True(errors.Is(err, ErrPublishBusy), "unexpected error: %v", err)
+	r.EqualValues(2, bot.ReceiveLog.Seq(), "nothing was published")
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// the indexes catch up after a restart, then the backlog fills up again and publishing waits
+	bot = open()
+	bot.WaitUntilIndexesAreSynced()
+	r.EqualValues(0, bot.IndexBacklog())
+
+	for i := 0; i < 3; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("filling the backlog"))
+		r.NoError(err, "publish %d", i)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := bot.PublishLog.Publish(refs.NewPost("waiting"))
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		r.FailNow("publish should have waited", "err: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	bot.Shutdown()
+	select {
+	case err := <-errc:
+		r.True(errors.Is(err, ssb.ErrShuttingDown), "unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		r.FailNow("publish still waiting after shutdown")
+	}
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,28 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// WantedBlobs returns the blobs the bot is trying to fetch from its peers.
+// The closest wants come first: -1 are the bot's own wants, -2 the wants of its peers and so on.
+func (s *Sbot) WantedBlobs() []ssb.BlobWant {
+	return s.WantManager.AllWants()
+}
+
+// Want asks the peers of the bot for the blob, which is then fetched in the background.
+// Nothing happens if the blob is already stored.
+func (s *Sbot) Want(ref refs.BlobRef) error {
+	return s.WantManager.Want(ref)
+}
+
+// Unwant stops fetching the blob
+func (s *Sbot) Unwant(ref refs.BlobRef) error {
+	return s.WantManager.Unwant(ref)
+}
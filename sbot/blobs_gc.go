@@ -0,0 +1,354 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/blobstore"
+)
+
+// blobRefRegexp matches blob references inside (decrypted) message content
+var blobRefRegexp = regexp.MustCompile(`&[A-Za-z0-9+/]{43}=\.sha256`)
+
+// DefaultBlobGCGracePeriod is how long new blobs are kept without a message that references them, see WithBlobGCGracePeriod
+const DefaultBlobGCGracePeriod = time.Hour
+
+// ErrBlobGCDisabled is returned by PruneBlobs if the bot wasn't opened with WithBlobGC
+var ErrBlobGCDisabled = errors.New("sbot: blob gc is not enabled")
+
+// blobCollector keeps track of when blobs were added.
+// They are not pruned within the grace period, since the messages that reference them might not have arrived yet.
+// The same goes for blobs from before the start, until the grace period passed since then.
+type blobCollector struct {
+	running sync.Mutex // only one run at a time
+
+	grace   time.Duration
+	started time.Time
+	now     func() time.Time
+
+	mu     sync.Mutex
+	recent map[string]time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+type gcTrackingStore struct {
+	ssb.BlobStore
+
+	gc *blobCollector
+}
+
+func (ts gcTrackingStore) Put(blob io.Reader) (refs.BlobRef, error) {
+	ref, err := ts.BlobStore.Put(blob)
+	if err != nil {
+		return ref, err
+	}
+	ts.gc.added(ref)
+	return ref, nil
+}
+
+func (ts gcTrackingStore) PartialSize(ref refs.BlobRef) (int64, error) {
+	if ps, ok := ts.BlobStore.(blobstore.PartialStore); ok {
+		return ps.PartialSize(ref)
+	}
+	return 0, nil
+}
+
+func (ts gcTrackingStore) DiscardPartial(ref refs.BlobRef) error {
+	if ps, ok := ts.BlobStore.(blobstore.PartialStore); ok {
+		return ps.DiscardPartial(ref)
+	}
+	return nil
+}
+
+func (ts gcTrackingStore) PutPartial(ref refs.BlobRef, offset int64, r io.Reader) error {
+	ps, ok := ts.BlobStore.(blobstore.PartialStore)
+	if !ok {
+		if offset != 0 {
+			return fmt.Errorf("sbot: blob store can't resume downloads")
+		}
+		got, err := ts.Put(r)
+		if err != nil {
+			return err
+		}
+		if !got.Equal(ref) {
+			ts.BlobStore.Delete(got)
+			return blobstore.ErrHashMismatch
+		}
+		return nil
+	}
+
+	if err := ps.PutPartial(ref, offset, r); err != nil {
+		return err
+	}
+	ts.gc.added(ref)
+	return nil
+}
+
+var _ blobstore.PartialStore = gcTrackingStore{}
+
+// initBlobGC wraps the blob store to track new blobs, if WithBlobGC is set.
+// It has to be called before the blob store is handed out to the want manager and plugins.
+func (s *Sbot) initBlobGC() {
+	if s.blobGCInterval <= 0 {
+		return
+	}
+
+	grace := s.blobGCGrace
+	if grace <= 0 {
+		grace = DefaultBlobGCGracePeriod
+	}
+	gc := &blobCollector{
+		grace:   grace,
+		started: time.Now(),
+		now:     time.Now,
+		recent:  make(map[string]time.Time),
+	}
+
+	// the change notifications of the store are asynchronous, so we track puts directly
+	s.BlobStore = gcTrackingStore{BlobStore: s.BlobStore, gc: gc}
+
+	s.blobGC = gc
+}
+
+// startBlobGC starts the periodic runs, if configured
+func (s *Sbot) startBlobGC() {
+	gc := s.blobGC
+	if gc == nil {
+		return
+	}
+
+	var ctx context.Context
+	ctx, gc.stop = context.WithCancel(s.rootCtx)
+	gc.done = make(chan struct{})
+	go func() {
+		defer close(gc.done)
+
+		tick := time.NewTicker(s.blobGCInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+
+			start := time.Now()
+			freed, err := s.pruneBlobs(ctx)
+			if err != nil {
+				level.Warn(s.info).Log("event", "blob gc failed", "err", err)
+				continue
+			}
+			level.Info(s.info).Log("event", "blob gc", "freed", freed, "took", time.Since(start))
+		}
+	}()
+}
+
+// Close stops the periodic runs and waits for a running one to finish.
+// It is called by Sbot.Close before the logs are closed.
+func (gc *blobCollector) Close() error {
+	if gc.stop != nil {
+		gc.stop()
+		<-gc.done
+	}
+	return nil
+}
+
+func (gc *blobCollector) added(ref refs.BlobRef) {
+	gc.mu.Lock()
+	gc.recent[ref.Sigil()] = gc.now()
+	gc.mu.Unlock()
+}
+
+// inGracePeriod returns true until nothing can be pruned, because the grace period since the start didn't pass yet
+func (gc *blobCollector) inGracePeriod() bool {
+	return gc.now().Sub(gc.started) < gc.grace
+}
+
+// expire forgets the blobs whose grace period is over
+func (gc *blobCollector) expire() {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	now := gc.now()
+	for sigil, added := range gc.recent {
+		if now.Sub(added) >= gc.grace {
+			delete(gc.recent, sigil)
+		}
+	}
+}
+
+func (gc *blobCollector) isRecent(ref refs.BlobRef) bool {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	added, has := gc.recent[ref.Sigil()]
+	return has && gc.now().Sub(added) < gc.grace
+}
+
+// PruneBlobs deletes the blobs from the store that are not referenced by messages of feeds within hops (or our own).
+// Messages that were moved into the archive (see WithFeedRetention) count as well.
+// Blobs that are currently wanted or were added within the grace period are kept, see WithBlobGCGracePeriod.
+// It returns the number of bytes that were freed, or ErrBlobGCDisabled without WithBlobGC.
+func (s *Sbot) PruneBlobs() (int64, error) {
+	if s.blobGC == nil {
+		return 0, ErrBlobGCDisabled
+	}
+	return s.pruneBlobs(s.rootCtx)
+}
+
+func (s *Sbot) pruneBlobs(ctx context.Context) (int64, error) {
+	gc := s.blobGC
+	gc.running.Lock()
+	defer gc.running.Unlock()
+
+	if gc.inGracePeriod() {
+		return 0, nil
+	}
+	gc.expire()
+
+	referenced, err := s.referencedBlobs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("blob gc: failed to collect blob references: %w", err)
+	}
+
+	// first collect the candidates so that we don't delete while walking the store
+	var unreferenced []refs.BlobRef
+	src := s.BlobStore.List()
+	for {
+		v, err := src.Next(ctx)
+		if err != nil {
+			if luigi.IsEOS(err) {
+				break
+			}
+			return 0, fmt.Errorf("blob gc: failed to list blobs: %w", err)
+		}
+
+		ref, ok := v.(refs.BlobRef)
+		if !ok {
+			return 0, fmt.Errorf("blob gc: unexpected list value: %T", v)
+		}
+
+		sigil := ref.Sigil()
+		if _, has := referenced[sigil]; has {
+			continue
+		}
+		unreferenced = append(unreferenced, ref)
+	}
+
+	var (
+		freed   int64
+		deleted int
+	)
+	for _, ref := range unreferenced {
+		// these might have changed while we were scanning
+		if s.WantManager.Wants(ref) || gc.isRecent(ref) {
+			continue
+		}
+
+		sz, err := s.BlobStore.Size(ref)
+		if err != nil {
+			continue
+		}
+
+		if err := s.BlobStore.Delete(ref); err != nil {
+			return freed, fmt.Errorf("blob gc: failed to delete %s: %w", ref.ShortSigil(), err)
+		}
+		freed += sz
+		deleted++
+	}
+
+	if s.eventCounter != nil {
+		s.eventCounter.With("event", "blob-gc-deleted").Add(float64(deleted))
+	}
+	return freed, nil
+}
+
+// referencedBlobs returns the set of blob references in the messages of our own feed and feeds within hops.
+// With WithFeedRetention the receive log returns the archived messages in place of the nulled ones.
+func (s *Sbot) referencedBlobs(ctx context.Context) (map[string]struct{}, error) {
+	authors := ssb.NewFeedSet(0)
+	if s.Replicator != nil {
+		list, err := s.Replicator.Lister().ReplicationList().List()
+		if err != nil {
+			return nil, err
+		}
+		for _, fr := range list {
+			authors.AddRef(fr)
+		}
+	}
+	authors.AddRef(s.KeyPair.ID())
+
+	src, err := s.ReceiveLog.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]struct{})
+	for {
+		v, err := src.Next(ctx)
+		if err != nil {
+			if luigi.IsEOS(err) {
+				break
+			}
+			return nil, err
+		}
+
+		if errv, ok := v.(error); ok {
+			if margaret.IsErrNulled(errv) {
+				continue
+			}
+			return nil, errv
+		}
+
+		msg, ok := v.(refs.Message)
+		if !ok {
+			continue
+		}
+
+		if !authors.Has(msg.Author()) {
+			continue
+		}
+
+		for _, br := range s.blobReferences(msg) {
+			referenced[br.Sigil()] = struct{}{}
+		}
+	}
+
+	return referenced, nil
+}
+
+// blobReferences returns the blobs that are mentioned in the content of msg, which is decrypted first if we can read it
+func (s *Sbot) blobReferences(msg refs.Message) []refs.BlobRef {
+	content := msg.ValueContentJSON()
+	if s.Groups != nil {
+		if cleartext, err := s.Groups.DecryptMessage(msg); err == nil {
+			content = cleartext
+		}
+	}
+
+	var found []refs.BlobRef
+	for _, match := range blobRefRegexp.FindAll(content, -1) {
+		br, err := refs.ParseBlobRef(string(match))
+		if err != nil {
+			continue
+		}
+		found = append(found, br)
+	}
+	return found
+}
@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	"go.mindeco.de/log"
+
+	"github.com/ssbc/go-ssb/repo/archive"
+)
+
+func TestPruneBlobs(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(log.NewNopLogger()),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithBlobGC(time.Hour),
+		WithBlobGCGracePeriod(time.Minute),
+	)
+	r.NoError(err)
+
+	now := time.Now()
+	bot.blobGC.now = func() time.Time { return now }
+	bot.blobGC.started = now
+
+	putRandom := func(size int) refs.BlobRef {
+		data := make([]byte, size)
+		rand.Read(data)
+		ref, err := bot.BlobStore.Put(bytes.NewReader(data))
+		r.NoError(err)
+		return ref
+	}
+
+	referenced := putRandom(1024)
+	unreferenced := putRandom(2048)
+
+	_, err = bot.PublishLog.Publish(map[string]interface{}{
+		"type": "post",
+		"text": "look at this: " + referenced.Sigil(),
+		"mentions": []interface{}{
+			map[string]string{"link": referenced.Sigil()},
+		},
+	})
+	r.NoError(err)
+
+	// both were just added and are kept for the grace period
+	freed, err := bot.PruneBlobs()
+	r.NoError(err)
+	r.EqualValues(0, freed)
+
+	// later runs within the grace period don't delete them either
+	now = now.Add(30 * time.Second)
+	freed, err = bot.PruneBlobs()
+	r.NoError(err)
+	r.EqualValues(0, freed)
+
+	// now it's gone, the new one is kept
+	now = now.Add(time.Minute)
+	fresh := putRandom(512)
+	freed, err = bot.PruneBlobs()
+	r.NoError(err)
+	r.EqualValues(2048, freed)
+
+	_, err = bot.BlobStore.Size(referenced)
+	r.NoError(err, "referenced blob was deleted")
+
+	_, err = bot.BlobStore.Size(unreferenced)
+	r.Error(err, "unreferenced blob still there")
+
+	_, err = bot.BlobStore.Size(fresh)
+	r.NoError(err, "new blob was deleted")
+
+	now = now.Add(time.Minute)
+	freed, err = bot.PruneBlobs()
+	r.NoError(err)
+	r.EqualValues(512, freed)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
+
+func TestPruneBlobsArchived(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(log.NewNopLogger()),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithBlobGC(time.Hour),
+		WithArchiveStore(openTestArchive(t, filepath.Join(tRepoPath, "archive", "archive.sqlite"))),
+		WithFeedRetention(1),
+	)
+	r.NoError(err)
+
+	now := time.Now()
+	bot.blobGC.now = func() time.Time { return now }
+	bot.blobGC.started = now
+
+	data := make([]byte, 1024)
+	rand.Read(data)
+	ref, err := bot.BlobStore.Put(bytes.NewReader(data))
+	r.NoError(err)
+
+	_, err = bot.PublishLog.Publish(refs.NewPost("archived " + ref.Sigil()))
+	r.NoError(err)
+
+	bot.WaitUntilIndexesAreSynced()
+
+	// only other feeds are archived by the retention, so move it by hand
+	rxLog, ok := bot.ReceiveLog.(*archive.Log)
+	r.True(ok, "receive log is %T", bot.ReceiveLog)
+	moved, _, err := rxLog.Archive(0)
+	r.NoError(err)
+	r.NotZero(moved)
+
+	now = now.Add(2 * DefaultBlobGCGracePeriod)
+	freed, err := bot.PruneBlobs()
+	r.NoError(err)
+	r.EqualValues(0, freed, "blob of the archived message was deleted")
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
+
+func TestPruneBlobsDisabled(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(log.NewNopLogger()),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	_, isWrapped := bot.BlobStore.(gcTrackingStore)
+	r.False(isWrapped, "blob store is tracked without gc")
+
+	_, err = bot.PruneBlobs()
+	r.ErrorIs(err, ErrBlobGCDisabled)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -613,3 +613,50 @@ func TestBlobsTooBig(t *testing.T) {
 	r.NoError(ali.Close())
 	r.NoError(bob.Close())
 }
+
+func TestWantedBlobs(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	mkRef := func(b byte) refs.BlobRef {
+		ref, err := refs.NewBlobRefFromBytes(bytes.Repeat([]byte{b}, 32), refs.RefAlgoBlobSSB1)
+		r.NoError(err)
+		return ref
+	}
+	own, forwarded, other := mkRef(1), mkRef(2), mkRef(3)
+
+	r.NoError(bot.WantManager.WantWithDist(forwarded, -2))
+	r.NoError(bot.Want(own))
+	r.NoError(bot.Want(other))
+
+	wants := bot.WantedBlobs()
+	r.Len(wants, 3)
+	r.EqualValues(-1, wants[0].Dist)
+	r.EqualValues(-1, wants[1].Dist)
+	r.True(wants[2].Ref.Equal(forwarded), "the forwarded want should come last")
+	r.EqualValues(-2, wants[2].Dist)
+
+	r.NoError(bot.Unwant(other))
+	r.False(bot.WantManager.Wants(other))
+	r.Len(bot.WantedBlobs(), 2)
+	r.Error(bot.Unwant(other), "not wanted anymore")
+
+	// blobs we have are not wanted
+	stored, err := bot.BlobStore.Put(bytes.NewReader([]byte("stored")))
+	r.NoError(err)
+	r.NoError(bot.Want(stored))
+	r.Len(bot.WantedBlobs(), 2)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,334 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb-refs/tfk"
+	"github.com/ssbc/margaret"
+	kitlog "go.mindeco.de/log"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/message"
+)
+
+// RepoReport is the result of CheckRepo
+type RepoReport struct {
+	// Messages is the number of entries in the receive log
+	Messages int64
+
+	// TruncatedEntries are the receive log entries that can't be read back, for instance because a write was cut short
+	TruncatedEntries []int64
+
+	// IndexMismatches are the indexes that didn't process all the messages they should have
+	IndexMismatches []IndexMismatch
+
+	// FeedMismatches are the feeds where the length of their sublog doesn't match the sequence of the latest message in it
+	FeedMismatches []ssb.ErrWrongSequence
+
+	// BrokenFeeds are the feeds with a message that has an invalid signature or doesn't link to the one before it
+	BrokenFeeds []message.ErrFeedBroken
+
+	// OrphanedBlobs are the stored blobs that no message references and that are not wanted
+	OrphanedBlobs []refs.BlobRef
+
+	// Sequences are the receive log entries that RepairRepo drops:
+	// the truncated entries and all the messages of mismatched and broken feeds
+	Sequences *roaring.Bitmap
+}
+
+// IndexMismatch is an index that is behind the log it is build from
+type IndexMismatch struct {
+	Index string
+
+	Processed, Total int64
+}
+
+// OK is true if CheckRepo found no problems
+func (r RepoReport) OK() bool {
+	return len(r.TruncatedEntries) == 0 &&
+		len(r.IndexMismatches) == 0 &&
+		len(r.FeedMismatches) == 0 &&
+		len(r.BrokenFeeds) == 0 &&
+		len(r.OrphanedBlobs) == 0
+}
+
+// CheckRepo goes through the whole repository and reports what is wrong with it, without changing anything.
+// Unlike FSCK it doesn't stop at the first problem and also verifies the hash chain of every feed.
+// Feeds with deleted messages (see NullMessage) are only checked up to the first deleted one.
+// RepairRepo can be used to act on the report.
+func (s *Sbot) CheckRepo() (RepoReport, error) {
+	s.WaitUntilIndexesAreSynced()
+
+	ctx := s.rootCtx
+	report := RepoReport{
+		Sequences: roaring.New(),
+	}
+
+	referenced := s.checkReceiveLog(&report)
+
+	if err := s.checkIndexes(&report); err != nil {
+		return RepoReport{}, err
+	}
+
+	if err := s.checkFeeds(&report); err != nil {
+		return RepoReport{}, err
+	}
+
+	if err := s.checkBlobs(ctx, &report, referenced); err != nil {
+		return RepoReport{}, err
+	}
+
+	return report, nil
+}
+
+// checkReceiveLog reads every entry of the receive log and returns the blobs that are referenced by the messages
+func (s *Sbot) checkReceiveLog(report *RepoReport) map[string]struct{} {
+	referenced := make(map[string]struct{})
+
+	last := s.ReceiveLog.Seq()
+	report.Messages = last + 1
+	for seq := int64(0); seq <= last; seq++ {
+		v, err := s.ReceiveLog.Get(seq)
+		if err != nil {
+			if margaret.IsErrNulled(err) {
+				continue
+			}
+			// the entry is listed in the offsets but it's data is missing or cut short
+			report.TruncatedEntries = append(report.TruncatedEntries, seq)
+			report.Sequences.Add(uint32(seq))
+			continue
+		}
+
+		msg, ok := v.(refs.Message)
+		if !ok {
+			report.TruncatedEntries = append(report.TruncatedEntries, seq)
+			report.Sequences.Add(uint32(seq))
+			continue
+		}
+
+		for _, br := range s.blobReferences(msg) {
+			referenced[br.Sigil()] = struct{}{}
+		}
+	}
+	return referenced
+}
+
+// checkIndexes compares how far each index got with the log it is build from
+func (s *Sbot) checkIndexes(report *RepoReport) error {
+	s.indexStateMu.Lock()
+	served := make(map[string]servedIndex, len(s.indexSinks))
+	for name, idx := range s.indexSinks {
+		served[name] = idx
+	}
+	s.indexStateMu.Unlock()
+
+	names := make([]string, 0, len(served))
+	for name := range served {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		idx := served[name]
+
+		total := idx.msgs.Seq() + 1
+		processed, err := indexResumeSeq(idx.msgs, idx.snk)
+		if err != nil {
+			return fmt.Errorf("sbot/checkrepo: failed to check index %s: %w", name, err)
+		}
+
+		if processed != total {
+			report.IndexMismatches = append(report.IndexMismatches, IndexMismatch{
+				Index:     name,
+				Processed: processed,
+				Total:     total,
+			})
+		}
+	}
+	return nil
+}
+
+// checkFeeds compares the sublog of each feed with its messages and verifies them
+func (s *Sbot) checkFeeds(report *RepoReport) error {
+	feeds, err := s.Users.List()
+	if err != nil {
+		return fmt.Errorf("sbot/checkrepo: failed to list feeds: %w", err)
+	}
+
+	for _, addr := range feeds {
+		var sr tfk.Feed
+		if err := sr.UnmarshalBinary([]byte(addr)); err != nil {
+			return fmt.Errorf("sbot/checkrepo: failed to unpack feed %q: %w", addr, err)
+		}
+		feed, err := sr.Feed()
+		if err != nil {
+			return fmt.Errorf("sbot/checkrepo: failed to get feed reference of %q: %w", addr, err)
+		}
+
+		subLog, err := s.Users.Get(addr)
+		if err != nil {
+			return fmt.Errorf("sbot/checkrepo: failed to open sublog of %s: %w", feed.ShortSigil(), err)
+		}
+
+		length := subLog.Seq() + 1
+		if length == 0 {
+			continue
+		}
+
+		feedLog := mutil.Indirect(s.ReceiveLog, subLog)
+
+		broken := false
+		latest, err := feedLog.Get(length - 1)
+		if err == nil {
+			// margaret indexes are 0-based, therefore +1
+			if msg, ok := latest.(refs.Message); ok && msg.Seq() != length {
+				report.FeedMismatches = append(report.FeedMismatches, ssb.ErrWrongSequence{
+					Ref:     feed,
+					Stored:  length,
+					Logical: msg.Seq(),
+				})
+				broken = true
+			}
+		} else if !margaret.IsErrNulled(err) {
+			return fmt.Errorf("sbot/checkrepo: failed to get latest message of %s: %w", feed.ShortSigil(), err)
+		}
+
+		_, err = s.feedFormats.VerifyFeed(feedLog, feed, s.signHMACsecret)
+		if err != nil {
+			feedBroken, ok := message.IsFeedBroken(err)
+			if !ok {
+				return fmt.Errorf("sbot/checkrepo: failed to verify %s: %w", feed.ShortSigil(), err)
+			}
+
+			// deleted messages are on purpose, the rest of the chain can't be checked without them
+			if feedBroken.Reason != message.FeedBreakMissing {
+				report.BrokenFeeds = append(report.BrokenFeeds, feedBroken)
+				broken = true
+			}
+		}
+
+		if broken {
+			if err := addSublogSequences(report.Sequences, subLog); err != nil {
+				return fmt.Errorf("sbot/checkrepo: failed to collect messages of %s: %w", feed.ShortSigil(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// addSublogSequences adds the receive log sequences that are stored in subLog to bmap
+func addSublogSequences(bmap *roaring.Bitmap, subLog margaret.Log) error {
+	src, err := subLog.Query()
+	if err != nil {
+		return err
+	}
+
+	for {
+		v, err := src.Next(context.Background())
+		if err != nil {
+			if luigi.IsEOS(err) {
+				return nil
+			}
+			return err
+		}
+
+		rxSeq, ok := v.(int64)
+		if !ok {
+			continue
+		}
+		bmap.Add(uint32(rxSeq))
+	}
+}
+
+// checkBlobs lists the stored blobs which are neither referenced nor wanted
+func (s *Sbot) checkBlobs(ctx context.Context, report *RepoReport, referenced map[string]struct{}) error {
+	src := s.BlobStore.List()
+	for {
+		v, err := src.Next(ctx)
+		if err != nil {
+			if luigi.IsEOS(err) {
+				return nil
+			}
+			return fmt.Errorf("sbot/checkrepo: failed to list blobs: %w", err)
+		}
+
+		ref, ok := v.(refs.BlobRef)
+		if !ok {
+			return fmt.Errorf("sbot/checkrepo: unexpected blob list value: %T", v)
+		}
+
+		if _, has := referenced[ref.Sigil()]; has {
+			continue
+		}
+		if s.WantManager.Wants(ref) {
+			continue
+		}
+		report.OrphanedBlobs = append(report.OrphanedBlobs, ref)
+	}
+}
+
+// RepairRepo drops what CheckRepo found broken: the truncated entries of the receive log
+// and the mismatched and broken feeds, like HealRepo does.
+// Index mismatches are fixed by rebuilding the indexes and orphaned blobs can be removed with PruneBlobs.
+func (s *Sbot) RepairRepo(report RepoReport) error {
+	funcLog := kitlog.With(s.info, "event", "repair repo")
+
+	// a feed can be mismatched and broken at the same time
+	var (
+		feeds []refs.FeedRef
+		seen  = ssb.NewFeedSet(0)
+	)
+	addFeed := func(feed refs.FeedRef) {
+		if seen.Has(feed) {
+			return
+		}
+		seen.AddRef(feed)
+		feeds = append(feeds, feed)
+	}
+	for _, mismatch := range report.FeedMismatches {
+		addFeed(mismatch.Ref)
+	}
+	for _, broken := range report.BrokenFeeds {
+		addFeed(broken.Feed)
+	}
+
+	if report.Sequences == nil || (report.Sequences.IsEmpty() && len(feeds) == 0) {
+		level.Warn(funcLog).Log("msg", "nothing to repair, run CheckRepo first.")
+		return nil
+	}
+
+	level.Info(funcLog).Log("msg", "dropping truncated entries and broken feeds",
+		"feeds", len(feeds),
+		"messages", report.Sequences.GetCardinality(),
+	)
+
+	it := report.Sequences.Iterator()
+	for it.HasNext() {
+		seq := it.Next()
+		err := s.ReceiveLog.Null(int64(seq))
+		if err != nil {
+			return fmt.Errorf("failed to null message (%d) in receive log: %w", seq, err)
+		}
+	}
+
+	for i, feed := range feeds {
+		err := s.NullFeed(feed)
+		if err != nil {
+			return fmt.Errorf("repair(%d): failed to null broken feed: %w", i, err)
+		}
+		level.Debug(funcLog).Log("feed", feed.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/message"
+)
+
+func TestCheckRepo(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	used, err := bot.BlobStore.Put(strings.NewReader("a picture"))
+	r.NoError(err)
+	orphan, err := bot.BlobStore.Put(strings.NewReader("nobody mentions me"))
+	r.NoError(err)
+
+	_, err = bot.PublishLog.Publish(refs.NewPost("look at " + used.String()))
+	r.NoError(err)
+	for i := 0; i < 2; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+
+	report, err := bot.CheckRepo()
+	r.NoError(err)
+	r.False(report.OK())
+	r.EqualValues(3, report.Messages)
+	r.Empty(report.TruncatedEntries)
+	r.Empty(report.IndexMismatches)
+	r.Empty(report.FeedMismatches)
+	r.Empty(report.BrokenFeeds)
+	r.Len(report.OrphanedBlobs, 1)
+	r.True(report.OrphanedBlobs[0].Equal(orphan))
+	r.True(report.Sequences.IsEmpty())
+
+	// store the last message a second time, which breaks the feed
+	last, err := bot.ReceiveLog.Get(bot.ReceiveLog.Seq())
+	r.NoError(err)
+	_, err = bot.ReceiveLog.Append(last)
+	r.NoError(err)
+
+	report, err = bot.CheckRepo()
+	r.NoError(err)
+	r.EqualValues(4, report.Messages)
+
+	r.Len(report.FeedMismatches, 1)
+	r.True(report.FeedMismatches[0].Ref.Equal(bot.KeyPair.ID()))
+	r.EqualValues(4, report.FeedMismatches[0].Stored)
+	r.EqualValues(3, report.FeedMismatches[0].Logical)
+
+	r.Len(report.BrokenFeeds, 1)
+	r.Equal(message.FeedBreakSequence, report.BrokenFeeds[0].Reason)
+	r.EqualValues(4, report.BrokenFeeds[0].Seq)
+
+	// all of the messages of the broken feed are dropped by a repair
+	r.EqualValues(4, report.Sequences.GetCardinality())
+
+	// checking doesn't change anything
+	r.EqualValues(3, bot.ReceiveLog.Seq())
+	_, err = bot.ReceiveLog.Get(0)
+	r.NoError(err)
+
+	r.NoError(bot.RepairRepo(report))
+
+	report, err = bot.CheckRepo()
+	r.NoError(err)
+	r.Empty(report.FeedMismatches)
+	r.Empty(report.BrokenFeeds)
+	r.Len(report.OrphanedBlobs, 2, "the message that referenced the blob is gone")
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
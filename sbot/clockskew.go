@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/statematrix"
+)
+
+// WithTimestampTolerance flags received messages that claim a timestamp more than d after the time they were received.
+// They are counted as the "clock-skew" event metric and sent as EventClockSkew, see Events.
+// The messages are stored like all others, since the protocol doesn't forbid it. See WithClockSkewDeferral to act on it.
+func WithTimestampTolerance(d time.Duration) Option {
+	return func(s *Sbot) error {
+		if d <= 0 {
+			return fmt.Errorf("WithTimestampTolerance: tolerance needs to be positive")
+		}
+		s.skewTolerance = d
+		return nil
+	}
+}
+
+// WithClockSkewDeferral stops replicating a feed for pause, once violations of its messages exceeded the tolerance of
+// WithTimestampTolerance. Afterwards it is replicated again, if it is still within the hop range, and the count starts over.
+// Deferred feeds are sent as EventFeedDeferred, see also DeferredFeeds. It has no effect without WithTimestampTolerance.
+func WithClockSkewDeferral(violations int, pause time.Duration) Option {
+	return func(s *Sbot) error {
+		if violations < 1 {
+			return fmt.Errorf("WithClockSkewDeferral: need at least one violation")
+		}
+		if pause <= 0 {
+			return fmt.Errorf("WithClockSkewDeferral: pause needs to be positive")
+		}
+		s.skewDeferAfter = violations
+		s.skewDeferFor = pause
+		return nil
+	}
+}
+
+// clockSkew counts the skewed messages per feed and keeps the feeds which are deferred because of them
+type clockSkew struct {
+	tolerance  time.Duration
+	deferAfter int
+	deferFor   time.Duration
+
+	mu         sync.Mutex
+	violations map[string]int
+	deferred   *ssb.StrFeedSet
+}
+
+// isDeferred is safe to call without WithTimestampTolerance
+func (cs *clockSkew) isDeferred(feed refs.FeedRef) bool {
+	if cs == nil {
+		return false
+	}
+	return cs.deferred.Has(feed)
+}
+
+// DeferredFeeds returns the feeds which are currently not replicated because of WithClockSkewDeferral
+func (s *Sbot) DeferredFeeds() []refs.FeedRef {
+	if s.clockSkew == nil {
+		return nil
+	}
+	lst, _ := s.clockSkew.deferred.List()
+	sort.Slice(lst, func(i, j int) bool { return lst[i].String() < lst[j].String() })
+	return lst
+}
+
+func (s *Sbot) startClockSkewCheck() {
+	if s.skewTolerance <= 0 {
+		return
+	}
+	s.clockSkew = &clockSkew{
+		tolerance:  s.skewTolerance,
+		deferAfter: s.skewDeferAfter,
+		deferFor:   s.skewDeferFor,
+
+		violations: make(map[string]int),
+		deferred:   ssb.NewFeedSet(0),
+	}
+	s.watchMessagesOnce.Do(s.watchMessages)
+}
+
+// checkClockSkew is called by watchMessages for each new message
+func (s *Sbot) checkClockSkew(msg refs.Message) {
+	cs := s.clockSkew
+	if cs == nil || msg.Author().Equal(s.KeyPair.ID()) {
+		return
+	}
+
+	received := msg.Received()
+	if received.IsZero() {
+		received = time.Now()
+	}
+	skew := msg.Claimed().Sub(received)
+	if skew <= cs.tolerance {
+		return
+	}
+
+	if s.eventCounter != nil {
+		s.eventCounter.With("event", "clock-skew").Add(1)
+	}
+	s.events.emit(Event{
+		Type:    EventClockSkew,
+		Feed:    msg.Author(),
+		Seq:     msg.Seq(),
+		Message: msg.Key(),
+		Reason:  fmt.Sprintf("claimed timestamp is %s ahead", skew.Round(time.Second)),
+	})
+
+	if cs.deferAfter < 1 {
+		return
+	}
+
+	author := msg.Author()
+	cs.mu.Lock()
+	if cs.deferred.Has(author) {
+		cs.mu.Unlock()
+		return
+	}
+	cs.violations[author.String()]++
+	n := cs.violations[author.String()]
+	if n < cs.deferAfter {
+		cs.mu.Unlock()
+		return
+	}
+	delete(cs.violations, author.String())
+	cs.deferred.AddRef(author)
+	cs.mu.Unlock()
+
+	s.deferFeed(author, n)
+}
+
+// deferFeed stops replicating feed and schedules its return
+func (s *Sbot) deferFeed(feed refs.FeedRef, violations int) {
+	cs := s.clockSkew
+
+	note, err := s.CurrentSequence(feed)
+	if err == nil {
+		err = s.ebtState.Fill(s.KeyPair.ID(), []statematrix.ObservedFeed{
+			{Feed: feed, Note: ssb.Note{Seq: note.Seq, Receive: false, Replicate: true}},
+		})
+	}
+	if err != nil {
+		level.Warn(s.info).Log("event", "failed to defer skewed feed", "feed", feed.ShortSigil(), "err", err)
+	}
+	s.Replicator.DontReplicate(feed)
+
+	level.Info(s.info).Log("event", "deferred feed with clock skew", "feed", feed.ShortSigil(), "violations", violations, "pause", cs.deferFor)
+	if s.eventCounter != nil {
+		s.eventCounter.With("event", "feed-deferred").Add(1)
+	}
+	s.events.emit(Event{
+		Type:   EventFeedDeferred,
+		Feed:   feed,
+		Reason: fmt.Sprintf("%d messages with clock skew, paused for %s", violations, cs.deferFor),
+	})
+
+	time.AfterFunc(cs.deferFor, func() { s.resumeFeed(feed) })
+}
+
+// resumeFeed reverts deferFeed, if feed is still wanted
+func (s *Sbot) resumeFeed(feed refs.FeedRef) {
+	if s.rootCtx.Err() != nil {
+		return
+	}
+	defer s.clockSkew.deferred.Delete(feed)
+
+	if s.ignored.Has(feed) || !s.wantsFeed(feed) {
+		return
+	}
+
+	note, err := s.CurrentSequence(feed)
+	if err == nil {
+		err = s.ebtState.Fill(s.KeyPair.ID(), []statematrix.ObservedFeed{
+			{Feed: feed, Note: ssb.Note{Seq: note.Seq, Receive: true, Replicate: true}},
+		})
+	}
+	if err != nil {
+		level.Warn(s.info).Log("event", "failed to resume deferred feed", "feed", feed.ShortSigil(), "err", err)
+	}
+	s.Replicator.Replicate(feed)
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestClockSkew(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithTimestampTolerance(time.Hour),
+		WithClockSkewDeferral(2, time.Second),
+	)
+	r.NoError(err)
+	events := bot.Events()
+
+	skewed, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	_, err = bot.PublishLog.Publish(refs.NewContactFollow(skewed))
+	r.NoError(err)
+	bot.WaitUntilIndexesAreSynced()
+
+	update := func() {
+		bot.Replicator.(*graphReplicator).update()
+	}
+	update()
+	wants := bot.Lister().ReplicationList()
+	r.True(wants.Has(skewed))
+
+	msgKey, err := refs.NewMessageRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoMessageSSB1)
+	r.NoError(err)
+	received := func(seq int64, ahead time.Duration) {
+		now := time.Now()
+		bot.checkClockSkew(refs.KeyValueRaw{
+			Key_:      msgKey,
+			Value:     refs.Value{Author: skewed, Sequence: seq, Timestamp: refs.Millisecs(now.Add(ahead))},
+			Timestamp: refs.Millisecs(now),
+		})
+	}
+
+	waitFor := func(typ EventType) Event {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case evt := <-events:
+				if evt.Type == typ {
+					return evt
+				}
+			case <-timeout:
+				r.FailNow("timeout waiting for event", typ)
+			}
+		}
+	}
+
+	// within the tolerance
+	received(1, 30*time.Minute)
+	received(2, 3*time.Hour)
+	evt := waitFor(EventClockSkew)
+	r.True(evt.Feed.Equal(skewed))
+	r.EqualValues(2, evt.Seq)
+	r.Contains(evt.Reason, "3h0m0s")
+	r.Empty(bot.DeferredFeeds(), "one violation is not enough")
+	r.True(wants.Has(skewed))
+
+	received(3, 2*time.Hour)
+	evt = waitFor(EventFeedDeferred)
+	r.True(evt.Feed.Equal(skewed))
+	r.Equal([]refs.FeedRef{skewed}, bot.DeferredFeeds())
+	r.False(wants.Has(skewed))
+
+	front, err := bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.False(front[skewed.String()].Receive)
+
+	// the next hop walk doesn't add it again
+	update()
+	r.False(wants.Has(skewed))
+
+	// until the pause is over
+	r.Eventually(func() bool { return len(bot.DeferredFeeds()) == 0 }, 5*time.Second, 50*time.Millisecond)
+	r.True(wants.Has(skewed))
+
+	front, err = bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.True(front[skewed.String()].Receive)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+
+	"github.com/ssbc/go-ssb/repo"
+)
+
+// Compact writes a copy of the receive log without the space of the messages that were nulled, dropped or archived,
+// see NullMessage, DropFeed and WithFeedRetention. It returns the number of bytes that will be reclaimed.
+//
+// The receive log is in use while the bot runs, so the copy only replaces it the next time the bot is started.
+// Messages that are received or nulled until then are brought over to the copy.
+// The sequences of the messages don't change, so the indexes don't need to be rebuilt.
+// Until the swap the copy takes up extra space, up to the size of the receive log.
+func (s *Sbot) Compact() (int64, error) {
+	if s.receiveLogPath == "" {
+		return 0, fmt.Errorf("sbot: compact only works with the default receive log")
+	}
+
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	reclaimed, err := repo.CompactLog(s.receiveLogPath)
+	if err != nil {
+		return 0, fmt.Errorf("sbot: failed to compact the receive log: %w", err)
+	}
+
+	if s.eventCounter != nil {
+		s.eventCounter.With("event", "log-compacted").Add(1)
+	}
+	return reclaimed, nil
+}
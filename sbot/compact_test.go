@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestCompact(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	open := func() *Sbot {
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+		)
+		r.NoError(err)
+		return bot
+	}
+	bot := open()
+
+	var msgs []refs.Message
+	for i := 0; i < 5; i++ {
+		msg, err := bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": i, "filler": strings.Repeat("x", 1000)})
+		r.NoError(err)
+		msgs = append(msgs, msg)
+	}
+	r.NoError(bot.NullMessage(msgs[1].Key()))
+	r.NoError(bot.NullMessage(msgs[2].Key()))
+
+	reclaimed, err := bot.Compact()
+	r.NoError(err)
+	r.True(reclaimed > 2000, "reclaimed %d", reclaimed)
+
+	// keeps working until the next start
+	r.NoError(bot.NullMessage(msgs[3].Key()))
+	msg, err := bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": 5})
+	r.NoError(err)
+	msgs = append(msgs, msg)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	bot = open()
+	bot.WaitUntilIndexesAreSynced()
+
+	for i, msg := range msgs {
+		got, err := bot.Get(msg.Key())
+		switch i {
+		case 1, 2, 3:
+			r.True(margaret.IsErrNulled(errors.Unwrap(err)), "message %d not nulled: %v", i, err)
+		default:
+			r.NoError(err, "message %d", i)
+			r.True(got.Key().Equal(msg.Key()))
+		}
+	}
+
+	// the sequences stayed the same
+	r.EqualValues(5, bot.ReceiveLog.Seq())
+	msg, err = bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": 6})
+	r.NoError(err)
+	r.EqualValues(7, msg.Seq())
+	r.True(msg.Previous().Equal(msgs[5].Key()))
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/repo"
+)
+
+// DiskUsage reports the bytes used by the offset log, each index, the blob store and the ebt state matrix.
+// Every entry of the sublogs and indexes folders of the repo counts as one index. Most indexes share one badger database.
+func (s *Sbot) DiskUsage() (ssb.DiskReport, error) {
+	r := repo.New(s.repoPath)
+
+	var (
+		report = ssb.DiskReport{Indexes: make(map[string]int64)}
+		err    error
+	)
+
+	report.Total, err = dirSize(r.GetPath())
+	if err != nil {
+		return ssb.DiskReport{}, fmt.Errorf("sbot/diskusage: failed to measure repo: %w", err)
+	}
+
+	report.Log, err = dirSize(r.GetPath("log"))
+	if err != nil {
+		return ssb.DiskReport{}, fmt.Errorf("sbot/diskusage: failed to measure log: %w", err)
+	}
+
+	report.Blobs, err = dirSize(r.GetPath("blobs"))
+	if err != nil {
+		return ssb.DiskReport{}, fmt.Errorf("sbot/diskusage: failed to measure blobs: %w", err)
+	}
+
+	report.StateMatrix, err = dirSize(r.GetPath("ebt-state-matrix"))
+	if err != nil {
+		return ssb.DiskReport{}, fmt.Errorf("sbot/diskusage: failed to measure state matrix: %w", err)
+	}
+
+	accounted := report.Log + report.Blobs + report.StateMatrix
+	for _, prefix := range []string{repo.PrefixMultiLog, repo.PrefixIndex} {
+		entries, err := os.ReadDir(r.GetPath(prefix))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return ssb.DiskReport{}, fmt.Errorf("sbot/diskusage: failed to list %s: %w", prefix, err)
+		}
+
+		for _, e := range entries {
+			size, err := dirSize(r.GetPath(prefix, e.Name()))
+			if err != nil {
+				return ssb.DiskReport{}, fmt.Errorf("sbot/diskusage: failed to measure index %s: %w", e.Name(), err)
+			}
+			report.Indexes[prefix+"/"+e.Name()] = size
+			accounted += size
+		}
+	}
+
+	report.Other = report.Total - accounted
+	if report.Other < 0 {
+		// files changed while walking the repo
+		report.Other = 0
+	}
+	return report, nil
+}
+
+// dirSize returns the summed disk usage of all files below path, or of path itself if it is a file.
+// A missing path has a size of zero and files which vanish during the walk are skipped.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += allocatedSize(info)
+		return nil
+	})
+	return size, err
+}
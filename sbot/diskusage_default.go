@@ -0,0 +1,22 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+// +build !windows
+
+package sbot
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// allocatedSize returns the bytes the file takes up on disk, like du does.
+// The badger files are sparse, so their apparent size is a lot bigger than what they use.
+func allocatedSize(info fs.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return info.Size()
+}
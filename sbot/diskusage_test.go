@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestDiskUsage(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	for i := 0; i < 10; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+
+	_, err = bot.BlobStore.Put(bytes.NewReader(bytes.Repeat([]byte("blob"), 1024)))
+	r.NoError(err)
+
+	bot.WaitUntilIndexesAreSynced()
+
+	report, err := bot.DiskUsage()
+	r.NoError(err)
+
+	r.NotZero(report.Log)
+	r.GreaterOrEqual(report.Blobs, int64(4096))
+	r.Contains(report.Indexes, "sublogs/shared-badger")
+
+	sum := report.Log + report.Blobs + report.StateMatrix + report.Other
+	for _, size := range report.Indexes {
+		sum += size
+	}
+	r.Equal(report.Total, sum)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
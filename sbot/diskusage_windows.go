@@ -0,0 +1,15 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+//go:build windows
+// +build windows
+
+package sbot
+
+import "io/fs"
+
+// allocatedSize returns the apparent size of the file, windows doesn't report the allocated blocks
+func allocatedSize(info fs.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/repo"
+)
+
+// B starts to replicate C while it is connected to A, which has C's messages.
+// The changed note has to reach A during the session so that A sends them.
+func TestEBTNotesDuringSession(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t, WithHMACSigning(randomKey()), WithEBTBatchWindow(50*time.Millisecond))
+	botA := tn.newBot("A")
+	botB := tn.newBot("B")
+	botC := tn.newBot("C")
+
+	for i := 0; i < 3; i++ {
+		_, err := botC.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+
+	botA.Replicate(botB.KeyPair.ID())
+	botA.Replicate(botC.KeyPair.ID())
+	botB.Replicate(botA.KeyPair.ID())
+	botC.Replicate(botA.KeyPair.ID())
+
+	r.NoError(botA.Network.Connect(tn.ctx, botC.Network.GetListenAddr()))
+	r.Eventually(hasFeedLength(botA, botC.KeyPair.ID(), 3), 10*time.Second, 50*time.Millisecond, "A didn't get C's feed")
+
+	r.NoError(botB.Network.Connect(tn.ctx, botA.Network.GetListenAddr()))
+	r.Eventually(func() bool {
+		nf, err := botB.ebtState.Inspect(botA.KeyPair.ID())
+		return err == nil && len(nf) > 0
+	}, 10*time.Second, 50*time.Millisecond, "no ebt session between B and A")
+
+	// without reconnecting
+	botB.Replicate(botC.KeyPair.ID())
+	r.Eventually(hasFeedLength(botB, botC.KeyPair.ID(), 3), 10*time.Second, 50*time.Millisecond, "B didn't get C's feed")
+
+	tn.close()
+}
+
+// A replicates X and Y, B only X. A has messages of both but must never tell B about Y.
+func TestEBTPeerInterest(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t, WithHMACSigning(randomKey()), WithEBTBatchWindow(50*time.Millisecond))
+	botA := tn.newBot("A")
+	botB := tn.newBot("B")
+
+	// A holds the feeds of X and Y
+	repoA := repo.New(filepath.Join("testrun", t.Name(), "bot-A"))
+	kpX, err := repo.NewKeyPair(repoA, "x", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	kpY, err := repo.NewKeyPair(repoA, "y", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	for i := 0; i < 2; i++ {
+		_, err = botA.PublishAs("x", refs.NewPost("from x"))
+		r.NoError(err)
+		_, err = botA.PublishAs("y", refs.NewPost("from y"))
+		r.NoError(err)
+	}
+
+	botA.Replicate(botB.KeyPair.ID())
+	botA.Replicate(kpX.ID())
+	botA.Replicate(kpY.ID())
+	botB.Replicate(botA.KeyPair.ID())
+	botB.Replicate(kpX.ID())
+
+	r.NoError(botB.Network.Connect(tn.ctx, botA.Network.GetListenAddr()))
+	r.Eventually(hasFeedLength(botB, kpX.ID(), 2), 10*time.Second, 50*time.Millisecond, "B didn't get X's feed")
+
+	// give A time to send more notes
+	time.Sleep(500 * time.Millisecond)
+
+	// everything A told B
+	told, err := botB.ebtState.Inspect(botA.KeyPair.ID())
+	r.NoError(err)
+	r.Contains(told, kpX.ID().String())
+	r.NotContains(told, kpY.ID().String(), "A sent a note about Y")
+
+	tn.close()
+}
+
+// A only has room for one EBT session. C, the second peer, is replicated with legacy gossip instead,
+// without being marked as a peer that can't do EBT.
+func TestEBTMaxSessions(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t, WithEBTBatchWindow(50*time.Millisecond))
+	botA := tn.newBot("A", WithEBTMaxSessions(1, 100*time.Millisecond))
+	botB := tn.newBot("B")
+	botC := tn.newBot("C")
+
+	for _, bot := range []*Sbot{botB, botC} {
+		for i := 0; i < 3; i++ {
+			_, err := bot.PublishLog.Publish(refs.NewPost("hello"))
+			r.NoError(err)
+		}
+		botA.Replicate(bot.KeyPair.ID())
+		bot.Replicate(botA.KeyPair.ID())
+	}
+
+	r.NoError(botA.Network.Connect(tn.ctx, botB.Network.GetListenAddr()))
+	r.Eventually(hasFeedLength(botA, botB.KeyPair.ID(), 3), 10*time.Second, 50*time.Millisecond, "A didn't get B's feed")
+	r.True(botA.ebtSessions.Full())
+
+	r.NoError(botA.Network.Connect(tn.ctx, botC.Network.GetListenAddr()))
+	r.Eventually(hasFeedLength(botA, botC.KeyPair.ID(), 3), 10*time.Second, 50*time.Millisecond, "A didn't get C's feed")
+
+	active, _ := botA.ebtSessions.Counts()
+	r.Equal(1, active)
+	r.False(botA.ebtSessions.UsesLegacy(botC.KeyPair.ID()), "C should get an ebt session once there is room")
+
+	tn.close()
+}
+
+// the interest of a peer is only walked again after the graph or the hop count changed
+func TestPeerInterestCache(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	bot := tn.newBot("A", WithHops(1))
+	self := bot.KeyPair.ID()
+
+	followed := func(seed byte) refs.FeedRef {
+		feed, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{seed}, 32), refs.RefAlgoFeedSSB1)
+		r.NoError(err)
+		_, err = bot.PublishLog.Publish(refs.NewContactFollow(feed))
+		r.NoError(err)
+		bot.WaitUntilIndexesAreSynced()
+		return feed
+	}
+
+	interest := bot.makePeerInterest()
+	lookup := func() []refs.FeedRef {
+		feeds, err := interest(self)
+		r.NoError(err)
+		return feeds
+	}
+
+	alice := followed(1)
+	first := lookup()
+	r.Len(first, 1)
+	r.True(first[0].Equal(alice))
+
+	second := lookup()
+	r.True(&first[0] == &second[0], "the list wasn't reused")
+
+	followed(2)
+	r.Len(lookup(), 2, "the follow wasn't picked up")
+
+	third := lookup()
+	bot.SetHops(2)
+	fourth := lookup()
+	r.Len(fourth, 2)
+	r.False(&third[0] == &fourth[0], "the list wasn't walked again for the new hop count")
+
+	tn.close()
+}
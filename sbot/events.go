@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// EventType says what happend in an Event
+type EventType string
+
+const (
+	// EventPeerConnected is sent when a secure connection to Peer was established
+	EventPeerConnected EventType = "peer-connected"
+
+	// EventPeerDisconnected is sent when the connection to Peer was closed
+	EventPeerDisconnected EventType = "peer-disconnected"
+
+	// EventFeedReplicated is sent when message Seq of Feed was received from someone else
+	EventFeedReplicated EventType = "feed-replicated"
+
+	// EventPublished is sent when Message was published by the bot, as Seq of its Feed
+	EventPublished EventType = "published"
+
+	// EventIndexProgress is sent while the backlog of Index is processed, when Done of its Total messages are indexed.
+	// Done includes the messages that were indexed before the bot was started.
+	EventIndexProgress EventType = "index-progress"
+
+	// EventConnScheduled is sent when the connection scheduler decided to dial Peer, see WithConnScheduler. Reason says why.
+	EventConnScheduled EventType = "conn-scheduled"
+
+	// EventConnSkipped is sent when the connection scheduler decided not to dial Peer, for the Reason given.
+	EventConnSkipped EventType = "conn-skipped"
+
+	// EventConnFailed is sent when a dial of the connection scheduler failed, Reason holds the error.
+	EventConnFailed EventType = "conn-failed"
+
+	// EventClockSkew is sent when Message of Feed claims a timestamp too far after it was received, see WithTimestampTolerance.
+	// Reason says by how much.
+	EventClockSkew EventType = "clock-skew"
+
+	// EventFeedDeferred is sent when the replication of Feed is paused because of its clock skew, see WithClockSkewDeferral.
+	EventFeedDeferred EventType = "feed-deferred"
+)
+
+// Event is a lifecycle event of the bot, see Events. Which fields are set depends on the Type.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	Peer refs.FeedRef
+
+	Feed    refs.FeedRef
+	Seq     int64
+	Message refs.MessageRef
+
+	Index string
+	Done  int64
+	Total int64
+
+	Reason string
+}
+
+// DefaultEventBuffer is how many events are kept for each receiver of Events by default
+const DefaultEventBuffer = 256
+
+// Events returns a channel which receives the lifecycle events of the bot.
+// Every call returns a new channel which receives all events. If a receiver is too slow and its buffer is full
+// (see WithEventBuffer), events are dropped for it instead of blocking the bot, see DroppedEvents.
+// The channels are closed when the bot is closed.
+func (s *Sbot) Events() <-chan Event {
+	s.watchMessagesOnce.Do(s.watchMessages)
+	return s.events.subscribe()
+}
+
+// DroppedEvents returns how many events were dropped because a receiver of Events didn't keep up
+func (s *Sbot) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&s.events.dropped)
+}
+
+// watchMessages sends the events for new messages in the receive log
+func (s *Sbot) watchMessages() {
+	src, err := s.ReceiveLog.Query(margaret.Gt(s.ReceiveLog.Seq()), margaret.Live(true))
+	if err != nil {
+		level.Warn(s.info).Log("event", "failed to watch new messages for events", "err", err)
+		return
+	}
+
+	self := s.KeyPair.ID()
+	snk := luigi.FuncSink(func(ctx context.Context, v interface{}, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		msg, ok := v.(refs.Message)
+		if !ok {
+			return nil // nulled or broken, nothing to tell
+		}
+
+		evt := Event{
+			Type:    EventFeedReplicated,
+			Feed:    msg.Author(),
+			Seq:     msg.Seq(),
+			Message: msg.Key(),
+		}
+		if msg.Author().Equal(self) {
+			evt.Type = EventPublished
+		}
+		s.events.emit(evt)
+		s.checkClockSkew(msg)
+		return nil
+	})
+
+	s.idxDone.Go(func() error {
+		err := luigi.Pump(s.rootCtx, snk, src)
+		if err != nil && !errors.Is(err, ssb.ErrShuttingDown) && !errors.Is(err, context.Canceled) {
+			level.Warn(s.info).Log("event", "watching new messages for events stopped", "err", err)
+		}
+		return nil
+	})
+}
+
+type eventBus struct {
+	size    int
+	dropped uint64
+	counter metrics.Counter
+
+	mu        sync.Mutex
+	closed    bool
+	receivers []chan Event
+}
+
+func newEventBus(size int, counter metrics.Counter) *eventBus {
+	if size <= 0 {
+		size = DefaultEventBuffer
+	}
+	return &eventBus{size: size, counter: counter}
+}
+
+func (eb *eventBus) subscribe() <-chan Event {
+	ch := make(chan Event, eb.size)
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if eb.closed {
+		close(ch)
+		return ch
+	}
+	eb.receivers = append(eb.receivers, ch)
+	return ch
+}
+
+func (eb *eventBus) emit(evt Event) {
+	if eb == nil {
+		return
+	}
+	evt.Time = time.Now()
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if eb.closed {
+		return
+	}
+
+	for _, ch := range eb.receivers {
+		select {
+		case ch <- evt:
+		default:
+			atomic.AddUint64(&eb.dropped, 1)
+			if eb.counter != nil {
+				eb.counter.With("event", "events-dropped").Add(1)
+			}
+		}
+	}
+}
+
+func (eb *eventBus) Close() error {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if eb.closed {
+		return nil
+	}
+	eb.closed = true
+	for _, ch := range eb.receivers {
+		close(ch)
+	}
+	eb.receivers = nil
+	return nil
+}
+
+// eventConnTracker sends the peer events for the connections that are accepted by the wrapped tracker
+type eventConnTracker struct {
+	ssb.ConnTracker
+
+	events *eventBus
+}
+
+func (ect eventConnTracker) OnAccept(ctx context.Context, conn net.Conn) (bool, context.Context) {
+	ok, ctx := ect.ConnTracker.OnAccept(ctx, conn)
+	if ok {
+		ect.emitPeer(EventPeerConnected, conn)
+	}
+	return ok, ctx
+}
+
+func (ect eventConnTracker) OnClose(conn net.Conn) time.Duration {
+	durr := ect.ConnTracker.OnClose(conn)
+	if durr > 0 {
+		ect.emitPeer(EventPeerDisconnected, conn)
+	}
+	return durr
+}
+
+func (ect eventConnTracker) emitPeer(t EventType, conn net.Conn) {
+	peer, err := ssb.GetFeedRefFromAddr(conn.RemoteAddr())
+	if err != nil {
+		return
+	}
+	ect.events.emit(Event{Type: t, Peer: peer})
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	"go.mindeco.de/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestEvents(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	botgroup, ctx := errgroup.WithContext(ctx)
+
+	info := testutils.NewRelativeTimeLogger(nil)
+	bs := newBotServer(ctx, info)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	ali, err := New(
+		WithContext(ctx),
+		WithInfo(log.With(info, "peer", "ali")),
+		WithRepoPath(filepath.Join(tRepoPath, "ali")),
+		WithListenAddr(":0"),
+	)
+	r.NoError(err)
+	botgroup.Go(bs.Serve(ali))
+
+	bob, err := New(
+		WithContext(ctx),
+		WithInfo(log.With(info, "peer", "bob")),
+		WithRepoPath(filepath.Join(tRepoPath, "bob")),
+		WithListenAddr(":0"),
+	)
+	r.NoError(err)
+	botgroup.Go(bs.Serve(bob))
+
+	ali.Replicate(bob.KeyPair.ID())
+	bob.Replicate(ali.KeyPair.ID())
+
+	aliEvents := ali.Events()
+	bobEvents := bob.Events()
+
+	waitFor := func(ch <-chan Event, match func(Event) bool) Event {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case evt, ok := <-ch:
+				r.True(ok, "channel closed")
+				if match(evt) {
+					r.False(evt.Time.IsZero())
+					return evt
+				}
+			case <-timeout:
+				r.FailNow("timeout waiting for event")
+			}
+		}
+	}
+
+	msg, err := bob.PublishLog.Publish(refs.NewPost("hello events"))
+	r.NoError(err)
+
+	evt := waitFor(bobEvents, func(e Event) bool { return e.Type == EventPublished })
+	r.True(evt.Feed.Equal(bob.KeyPair.ID()))
+	r.EqualValues(1, evt.Seq)
+	r.True(evt.Message.Equal(msg.Key()))
+
+	err = bob.Network.Connect(ctx, ali.Network.GetListenAddr())
+	r.NoError(err)
+
+	evt = waitFor(aliEvents, func(e Event) bool { return e.Type == EventPeerConnected })
+	r.True(evt.Peer.Equal(bob.KeyPair.ID()))
+
+	evt = waitFor(bobEvents, func(e Event) bool { return e.Type == EventPeerConnected })
+	r.True(evt.Peer.Equal(ali.KeyPair.ID()))
+
+	evt = waitFor(aliEvents, func(e Event) bool { return e.Type == EventFeedReplicated })
+	r.True(evt.Feed.Equal(bob.KeyPair.ID()))
+	r.EqualValues(1, evt.Seq)
+	r.True(evt.Message.Equal(msg.Key()))
+
+	bob.Network.GetConnTracker().CloseAll()
+	evt = waitFor(bobEvents, func(e Event) bool { return e.Type == EventPeerDisconnected })
+	r.True(evt.Peer.Equal(ali.KeyPair.ID()))
+
+	r.EqualValues(0, ali.DroppedEvents())
+
+	ali.Shutdown()
+	bob.Shutdown()
+	r.NoError(ali.Close())
+	r.NoError(bob.Close())
+
+	// the channels are closed with the bot
+	for range aliEvents {
+	}
+	_, open := <-ali.Events()
+	r.False(open)
+
+	cancel()
+	r.NoError(botgroup.Wait())
+}
+
+func TestEventsDropWhenFull(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithEventBuffer(1),
+	)
+	r.NoError(err)
+
+	slow := bot.Events()
+
+	for i := 0; i < 3; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("nobody is listening"))
+		r.NoError(err)
+	}
+
+	// only one of them fits, the index events from the startup might have taken that spot already
+	r.Eventually(func() bool { return bot.DroppedEvents() >= 2 }, 5*time.Second, 10*time.Millisecond)
+	r.Len(slow, 1)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/message"
+)
+
+// maxDumpLine is the longest line ImportFeed accepts, which leaves plenty of room for the 8k message limit and escaping
+const maxDumpLine = 1024 * 1024
+
+// ImportFeed reads newline-delimited signed messages of classic feeds from r, like the ones written by ExportFeed, and stores them.
+// Each line is either the signed message value or an object with it under "value", like the key-value-timestamp form of the JS implementation.
+//
+// The messages are checked the same way as replicated ones: the signature, the sequence and the link to the previous message have to be valid.
+// Messages we already have are skipped. The import stops at the first one that doesn't verify, without storing it,
+// and returns how many messages were imported up to that point.
+func (s *Sbot) ImportFeed(r io.Reader) (imported int, err error) {
+	router := s.verifyRouter
+	if router == nil { // network disabled
+		router, err = message.NewVerificationRouter(s.ReceiveLog, s.Users, s.signHMACsecret, s.feedFormats)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// the sinks depend on the user feeds index to find the latest message of a feed
+	s.WaitUntilIndexesAreSynced()
+	defer s.WaitUntilIndexesAreSynced()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxDumpLine)
+
+	var lineNo = 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		raw, author, err := decodeDumpLine(line)
+		if err != nil {
+			return imported, fmt.Errorf("sbot/import: line %d: %w", lineNo, err)
+		}
+
+		if author.Algo() != refs.RefAlgoFeedSSB1 {
+			return imported, fmt.Errorf("sbot/import: line %d: only classic feeds can be imported, got %s", lineNo, author.Algo())
+		}
+
+		snk, err := router.GetSink(author, false)
+		if err != nil {
+			return imported, fmt.Errorf("sbot/import: line %d: failed to get verification sink for %s: %w", lineNo, author.ShortSigil(), err)
+		}
+
+		before := snk.Seq()
+		err = snk.Verify(raw)
+		if err != nil {
+			return imported, fmt.Errorf("sbot/import: line %d: %w", lineNo, err)
+		}
+		if snk.Seq() > before {
+			imported++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, fmt.Errorf("sbot/import: failed to read line %d: %w", lineNo+1, err)
+	}
+
+	return imported, nil
+}
+
+// decodeDumpLine returns the signed message of a line and its author
+func decodeDumpLine(line []byte) (json.RawMessage, refs.FeedRef, error) {
+	var msg struct {
+		Author string          `json:"author"`
+		Value  json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, refs.FeedRef{}, fmt.Errorf("not a JSON message: %w", err)
+	}
+
+	raw := json.RawMessage(line)
+	if msg.Author == "" && len(msg.Value) > 0 { // key-value-timestamp
+		raw = msg.Value
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, refs.FeedRef{}, fmt.Errorf("invalid message value: %w", err)
+		}
+	}
+
+	author, err := refs.ParseFeedRef(msg.Author)
+	if err != nil {
+		return nil, refs.FeedRef{}, fmt.Errorf("invalid author: %w", err)
+	}
+	return raw, author, nil
+}
+
+// ExportFeed writes all the stored messages of a classic feed to w, one signed message per line, which can be read again by ImportFeed.
+// The messages are written as they were signed, only the whitespace of the pretty printed form is dropped so each fits on one line.
+// The signatures and hashes are computed over the pretty printed form which ImportFeed restores when verifying.
+// It returns how many messages were written.
+func (s *Sbot) ExportFeed(feed refs.FeedRef, w io.Writer) (exported int, err error) {
+	return s.ExportFeedFrom(feed, 1, w)
+}
+
+// ExportFeedFrom is like ExportFeed but starts at the message with sequence fromSeq, for instance to extend an earlier export.
+// The receiving side needs to have the messages before fromSeq to import it.
+func (s *Sbot) ExportFeedFrom(feed refs.FeedRef, fromSeq int64, w io.Writer) (exported int, err error) {
+	if feed.Algo() != refs.RefAlgoFeedSSB1 {
+		return 0, fmt.Errorf("sbot/export: only classic feeds can be exported, got %s", feed.Algo())
+	}
+	if fromSeq < 1 {
+		return 0, fmt.Errorf("sbot/export: invalid start sequence %d, feeds start at 1", fromSeq)
+	}
+
+	s.WaitUntilIndexesAreSynced()
+
+	userLog, err := s.Users.Get(storedrefs.Feed(feed))
+	if err != nil {
+		return 0, fmt.Errorf("sbot/export: failed to open sublog for %s: %w", feed.ShortSigil(), err)
+	}
+
+	// the sublog is 0-indexed
+	src, err := mutil.Indirect(s.ReceiveLog, userLog).Query(margaret.Gte(fromSeq - 1))
+	if err != nil {
+		return 0, fmt.Errorf("sbot/export: failed to query %s: %w", feed.ShortSigil(), err)
+	}
+
+	var (
+		ctx = context.Background()
+		buf bytes.Buffer
+	)
+	for {
+		v, err := src.Next(ctx)
+		if err != nil {
+			if luigi.IsEOS(err) {
+				break
+			}
+			return exported, fmt.Errorf("sbot/export: failed to read message %d: %w", fromSeq+int64(exported), err)
+		}
+
+		msg, ok := v.(refs.Message)
+		if !ok {
+			// nulled messages come back as errors, a dump without them wouldn't verify
+			if errv, ok := v.(error); ok {
+				return exported, fmt.Errorf("sbot/export: message %d of %s is not available: %w", fromSeq+int64(exported), feed.ShortSigil(), errv)
+			}
+			return exported, fmt.Errorf("sbot/export: unexpected value %T", v)
+		}
+
+		// the stored form is pretty printed over multiple lines
+		buf.Reset()
+		if err := json.Compact(&buf, msg.ValueContentJSON()); err != nil {
+			return exported, fmt.Errorf("sbot/export: failed to encode message %d: %w", msg.Seq(), err)
+		}
+		buf.WriteByte('\n')
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return exported, fmt.Errorf("sbot/export: failed to write message %d: %w", msg.Seq(), err)
+		}
+		exported++
+	}
+
+	return exported, nil
+}
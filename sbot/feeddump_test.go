@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestImportExportFeed(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	repoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(repoPath)
+
+	ali, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(filepath.Join(repoPath, "ali")),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	bob, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(filepath.Join(repoPath, "bob")),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	for i := 0; i < 5; i++ {
+		_, err := ali.PublishLog.Publish(map[string]interface{}{"type": "test", "text": fmt.Sprintf("hello ö %d", i)})
+		r.NoError(err)
+	}
+
+	var dump bytes.Buffer
+	n, err := ali.ExportFeed(ali.KeyPair.ID(), &dump)
+	r.NoError(err)
+	a.Equal(5, n)
+	lines := strings.Split(strings.TrimSpace(dump.String()), "\n")
+	r.Len(lines, 5, "one message per line")
+
+	// a tampered message is rejected and nothing after it is stored
+	tampered := strings.Join(lines[:2], "\n") + "\n" + strings.Replace(lines[2], "hello", "hallo", 1) + "\n" + strings.Join(lines[3:], "\n")
+	n, err = bob.ImportFeed(strings.NewReader(tampered))
+	r.Error(err)
+	a.Contains(err.Error(), "line 3")
+	a.Equal(2, n)
+
+	aliOnBob, err := bob.Users.Get(storedrefs.Feed(ali.KeyPair.ID()))
+	r.NoError(err)
+	a.EqualValues(1, aliOnBob.Seq())
+
+	// importing the whole dump skips the two we already have
+	n, err = bob.ImportFeed(bytes.NewReader(dump.Bytes()))
+	r.NoError(err)
+	a.Equal(3, n)
+	a.EqualValues(4, aliOnBob.Seq())
+
+	n, err = bob.ImportFeed(bytes.NewReader(dump.Bytes()))
+	r.NoError(err)
+	a.Equal(0, n, "nothing new")
+
+	carl, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(filepath.Join(repoPath, "carl")),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	// messages have to continue the feed
+	_, err = carl.ImportFeed(strings.NewReader(lines[4]))
+	r.Error(err, "gap in the feed")
+
+	// the key-value-timestamp form is accepted, too
+	var kvts bytes.Buffer
+	for i, line := range lines {
+		msg, err := ali.PublishLog.Get(int64(i))
+		r.NoError(err)
+		fmt.Fprintf(&kvts, `{"key":%q,"value":%s,"timestamp":1}`+"\n", msg.(refs.Message).Key().String(), line)
+	}
+	n, err = carl.ImportFeed(&kvts)
+	r.NoError(err)
+	a.Equal(5, n)
+
+	// a range of the feed
+	var tail bytes.Buffer
+	n, err = ali.ExportFeedFrom(ali.KeyPair.ID(), 4, &tail)
+	r.NoError(err)
+	a.Equal(2, n)
+	a.Equal(strings.Join(lines[3:], "\n")+"\n", tail.String())
+
+	n, err = ali.ExportFeedFrom(ali.KeyPair.ID(), 6, &tail)
+	r.NoError(err)
+	a.Equal(0, n, "past the end")
+
+	_, err = ali.ExportFeedFrom(ali.KeyPair.ID(), 0, &tail)
+	r.Error(err)
+
+	// the dumps are the same
+	var again bytes.Buffer
+	_, err = carl.ExportFeed(ali.KeyPair.ID(), &again)
+	r.NoError(err)
+	a.Equal(dump.String(), again.String())
+
+	for _, bot := range []*Sbot{ali, bob, carl} {
+		bot.Shutdown()
+		r.NoError(bot.Close())
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+)
+
+// feedList is a set of feeds, like the ignored or the manually replicated ones.
+// It is stored as a JSON list of feed references, so that it survives restarts.
+type feedList struct {
+	name string // for error messages
+
+	mu    sync.Mutex
+	path  string
+	feeds *ssb.StrFeedSet
+}
+
+func loadFeedList(name, path string) (*feedList, error) {
+	fl := &feedList{
+		name:  name,
+		path:  path,
+		feeds: ssb.NewFeedSet(0),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read %s: %w", name, path, err)
+	}
+
+	var feeds []refs.FeedRef
+	if err := json.Unmarshal(data, &feeds); err != nil {
+		return nil, fmt.Errorf("%s: failed to decode %s: %w", name, path, err)
+	}
+	for _, f := range feeds {
+		fl.feeds.AddRef(f)
+	}
+	return fl, nil
+}
+
+func (fl *feedList) Has(feed refs.FeedRef) bool { return fl.feeds.Has(feed) }
+
+func (fl *feedList) List() []refs.FeedRef {
+	lst, _ := fl.feeds.List()
+	sort.Slice(lst, func(i, j int) bool { return lst[i].String() < lst[j].String() })
+	return lst
+}
+
+// set adds or removes feed and writes the new list. It returns false if nothing changed.
+func (fl *feedList) set(feed refs.FeedRef, add bool) (bool, error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if fl.feeds.Has(feed) == add {
+		return false, nil
+	}
+	if add {
+		fl.feeds.AddRef(feed)
+	} else {
+		fl.feeds.Delete(feed)
+	}
+
+	if err := fl.store(); err != nil {
+		// keep memory and disk in line
+		if add {
+			fl.feeds.Delete(feed)
+		} else {
+			fl.feeds.AddRef(feed)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (fl *feedList) store() error {
+	data, err := json.MarshalIndent(fl.List(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: failed to encode list: %w", fl.name, err)
+	}
+
+	newPath := fl.path + ".new"
+	if err := os.WriteFile(newPath, data, 0600); err != nil {
+		return fmt.Errorf("%s: failed to write list: %w", fl.name, err)
+	}
+	if err := os.Rename(newPath, fl.path); err != nil {
+		return fmt.Errorf("%s: failed to replace list: %w", fl.name, err)
+	}
+	return nil
+}
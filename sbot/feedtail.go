@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+)
+
+// FeedTail streams the messages of feed from sequence seq on and then the new ones, as they are received.
+// With seq zero only the messages that are received from now on are streamed. Nulled messages are skipped.
+// The source only ends once ctx of the consumer is canceled.
+func (s *Sbot) FeedTail(feed refs.FeedRef, seq int64) (luigi.Source, error) {
+	if seq < 0 {
+		return nil, fmt.Errorf("sbot/feed tail: invalid sequence %d", seq)
+	}
+
+	userLog, err := s.Users.Get(storedrefs.Feed(feed))
+	if err != nil {
+		return nil, fmt.Errorf("sbot/feed tail: failed to open sublog for %s: %w", feed.ShortSigil(), err)
+	}
+
+	// the stored and the live part of the query are split at this one snapshot of the sublog,
+	// so with seq zero the messages that are already stored are not delivered again by the live part
+	current := userLog.Seq()
+
+	// the sublog is 0-indexed
+	start := margaret.Gt(current)
+	if seq > 0 {
+		start = margaret.Gte(seq - 1)
+	}
+
+	src, err := mutil.Indirect(s.ReceiveLog, userLog).Query(start, margaret.Live(true))
+	if err != nil {
+		return nil, fmt.Errorf("sbot/feed tail: failed to create query: %w", err)
+	}
+	return skipNulled{src}, nil
+}
+
+// skipNulled drops the nulled messages of an indirect query, which are returned as errors
+type skipNulled struct {
+	luigi.Source
+}
+
+func (sn skipNulled) Next(ctx context.Context) (interface{}, error) {
+	for {
+		v, err := sn.Source.Next(ctx)
+		if err != nil && margaret.IsErrNulled(err) {
+			continue
+		}
+		return v, err
+	}
+}
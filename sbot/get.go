@@ -7,49 +7,60 @@ package sbot
 import (
 	"fmt"
 
+	librarian "github.com/ssbc/margaret/indexes"
+
 	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/internal/storedrefs"
 )
 
 func (s *Sbot) Get(ref refs.MessageRef) (refs.Message, error) {
+	seq, err := s.receiveLogSeq(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	storedV, err := s.ReceiveLog.Get(seq)
+	if err != nil {
+		return nil, fmt.Errorf("sbot/get: failed to load message: %w", err)
+	}
+
+	msg, ok := storedV.(refs.Message)
+	if !ok {
+		return nil, fmt.Errorf("sbot/get: wrong message type in storeage: %T", storedV)
+	}
+
+	return msg, nil
+}
+
+// receiveLogSeq looks up the sequence of ref in the receive log
+func (s *Sbot) receiveLogSeq(ref refs.MessageRef) (int64, error) {
 	getIdx, ok := s.simpleIndex["get"]
 	if !ok {
-		return nil, fmt.Errorf("sbot: get index disabled")
+		return -1, fmt.Errorf("sbot: get index disabled")
 	}
 
 	obs, err := getIdx.Get(s.rootCtx, storedrefs.Message(ref))
 	if err != nil {
-		return nil, fmt.Errorf("sbot/get: failed to get seq val from index: %w", err)
+		return -1, fmt.Errorf("sbot/get: failed to get seq val from index: %w", err)
 	}
 
 	v, err := obs.Value()
 	if err != nil {
-		return nil, fmt.Errorf("sbot/get: failed to get current value from obs: %w", err)
+		return -1, fmt.Errorf("sbot/get: failed to get current value from obs: %w", err)
 	}
 
-	var seq int64
 	switch tv := v.(type) {
 	case int64:
 		if tv < 0 {
-			return nil, fmt.Errorf("invalid sequence stored in index")
+			return -1, fmt.Errorf("invalid sequence stored in index")
 		}
-		seq = int64(tv)
+		return tv, nil
+	case librarian.UnsetValue:
+		return -1, fmt.Errorf("sbot/get: %s: %w", ref.ShortSigil(), ssb.ErrMessageNotFound)
 	default:
-		return nil, fmt.Errorf("sbot/get: wrong sequence type in index: %T", v)
-	}
-
-	storedV, err := s.ReceiveLog.Get(seq)
-	if err != nil {
-		return nil, fmt.Errorf("sbot/get: failed to load message: %w", err)
+		return -1, fmt.Errorf("sbot/get: wrong sequence type in index: %T", v)
 	}
-
-	msg, ok := storedV.(refs.Message)
-	if !ok {
-		return nil, fmt.Errorf("sbot/get: wrong message type in storeage: %T", storedV)
-	}
-
-	return msg, nil
 }
 
 func (s *Sbot) CurrentSequence(feed refs.FeedRef) (ssb.Note, error) {
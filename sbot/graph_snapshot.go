@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.mindeco.de/log/level"
+	"go.mindeco.de/logging"
+
+	"github.com/ssbc/go-ssb/graph"
+)
+
+// graphSnapshotter loads the contact graph from disk on startup and saves it again when the bot is closed,
+// so that it doesn't need to be rebuilt from the whole index every time.
+type graphSnapshotter struct {
+	info logging.Interface
+
+	path string
+	gb   *graph.BadgerBuilder
+}
+
+// load uses the snapshot, if there is one that matches the index.
+// Broken or outdated snapshots are removed and the graph is built from the index instead.
+func (gs graphSnapshotter) load() {
+	f, err := os.Open(gs.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(gs.info).Log("event", "failed to open graph snapshot", "err", err)
+		}
+		return
+	}
+
+	err = gs.gb.LoadSnapshot(f)
+	f.Close()
+	if err == nil {
+		level.Debug(gs.info).Log("event", "loaded graph snapshot")
+		return
+	}
+
+	if errors.Is(err, graph.ErrSnapshotOutdated) {
+		level.Debug(gs.info).Log("event", "graph snapshot outdated", "err", err)
+	} else {
+		level.Warn(gs.info).Log("event", "graph snapshot corrupted, rebuilding", "err", err)
+	}
+	os.Remove(gs.path)
+}
+
+// Close writes the current graph to disk. It needs to be called after the indexing stopped.
+func (gs graphSnapshotter) Close() error {
+	newPath := gs.path + ".new"
+	f, err := os.Create(newPath)
+	if err != nil {
+		return fmt.Errorf("graph snapshot: failed to create file: %w", err)
+	}
+
+	err = gs.gb.SaveSnapshot(f)
+	if err != nil {
+		f.Close()
+		os.Remove(newPath)
+		return fmt.Errorf("graph snapshot: failed to save: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("graph snapshot: failed to close file: %w", err)
+	}
+
+	return os.Rename(newPath, gs.path)
+}
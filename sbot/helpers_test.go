@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+// testNetwork serves a group of test bots which share a random shscap
+type testNetwork struct {
+	t testing.TB
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	group  *errgroup.Group
+	bs     botServer
+
+	// opts are used for every bot
+	opts []Option
+	bots []*Sbot
+}
+
+// newTestNetwork removes the testrun folder of t and prepares a network for the bots of the test.
+// opts are added to the options of every bot.
+func newTestNetwork(t testing.TB, opts ...Option) *testNetwork {
+	os.RemoveAll(filepath.Join("testrun", t.Name()))
+
+	ctx, cancel := ShutdownContext(context.Background())
+	group, ctx := errgroup.WithContext(ctx)
+
+	tn := &testNetwork{
+		t: t,
+
+		ctx:    ctx,
+		cancel: cancel,
+		group:  group,
+		bs:     newBotServer(ctx, testutils.NewRelativeTimeLogger(nil)),
+	}
+	tn.opts = append([]Option{WithAppKey(randomKey()), WithContext(ctx)}, opts...)
+	return tn
+}
+
+// newBot creates a bot with the options of the network and extra and starts to serve it
+func (tn *testNetwork) newBot(name string, extra ...Option) *Sbot {
+	opts := append(append([]Option{}, tn.opts...), extra...)
+
+	bot := makeNamedTestBot(tn.t, name, opts)
+	tn.group.Go(tn.bs.Serve(bot))
+	tn.bots = append(tn.bots, bot)
+	return bot
+}
+
+// close stops serving and closes all the bots of the network
+func (tn *testNetwork) close() {
+	r := require.New(tn.t)
+
+	tn.cancel()
+	for _, bot := range tn.bots {
+		bot.Shutdown()
+		r.NoError(bot.Close())
+	}
+	r.NoError(tn.group.Wait())
+}
+
+// randomKey returns 32 random bytes, for a shscap or an hmac key
+func randomKey() []byte {
+	k := make([]byte, 32)
+	rand.Read(k)
+	return k
+}
+
+// hasFeedLength returns a condition for require.Eventually which is true once bot stored length messages of feed
+func hasFeedLength(bot *Sbot, feed refs.FeedRef, length int64) func() bool {
+	return func() bool {
+		subLog, err := bot.Users.Get(storedrefs.Feed(feed))
+		return err == nil && subLog.Seq()+1 == length
+	}
+}
+
+// readAuditLog decodes the entries of the audit log at path, see WithAuditLog
+func readAuditLog(t testing.TB, path string) []AuditEntry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []AuditEntry
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var e AuditEntry
+		require.NoError(t, dec.Decode(&e))
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/message/legacy"
+)
+
+// Bots on the same shscap can connect, but the messages of a bot with a different hmac key don't verify and aren't stored.
+func TestHMACSeparatesNetworks(t *testing.T) {
+	t.Run("ebt", func(t *testing.T) { testHMACSeparatesNetworks(t, false) })
+	t.Run("legacy", func(t *testing.T) { testHMACSeparatesNetworks(t, true) })
+}
+
+func testHMACSeparatesNetworks(t *testing.T, disableEBT bool) {
+	r := require.New(t)
+	a := assert.New(t)
+
+	hmacKey, otherKey := randomKey(), randomKey()
+
+	// ali and bob share the hmac key, eve only the shscap
+	tn := newTestNetwork(t, DisableEBT(disableEBT))
+	aliMetrics, eveMetrics := stdprometheus.NewRegistry(), stdprometheus.NewRegistry()
+	ali := tn.newBot("ali", WithHMACSigning(hmacKey), WithMetricsRegistry(aliMetrics))
+	bob := tn.newBot("bob", WithHMACSigning(hmacKey))
+	eve := tn.newBot("eve", WithHMACSigning(otherKey), WithMetricsRegistry(eveMetrics))
+
+	const n = 3
+	for i := 0; i < n; i++ {
+		_, err := ali.PublishLog.Publish(refs.NewPost(fmt.Sprintf("ali %d", i)))
+		r.NoError(err)
+		_, err = eve.PublishLog.Publish(refs.NewPost(fmt.Sprintf("eve %d", i)))
+		r.NoError(err)
+	}
+
+	latest, err := ali.ReceiveLog.Get(ali.ReceiveLog.Seq())
+	r.NoError(err)
+	raw := latest.(refs.Message).ValueContentJSON()
+	var hk, hkOther [32]byte
+	copy(hk[:], hmacKey)
+	copy(hkOther[:], otherKey)
+	_, _, err = legacy.Verify(raw, &hk)
+	r.NoError(err, "ali's message doesn't verify with her hmac key")
+	_, _, err = legacy.Verify(raw, &hkOther)
+	r.Error(err, "ali's message verified with another hmac key")
+	_, _, err = legacy.Verify(raw, nil)
+	r.Error(err, "ali's message verified without an hmac key")
+
+	for _, bot := range []*Sbot{ali, bob, eve} {
+		for _, other := range []*Sbot{ali, bob, eve} {
+			if bot != other {
+				bot.Replicate(other.KeyPair.ID())
+			}
+		}
+	}
+
+	// the shscap is the same, so the connections work
+	r.NoError(bob.Network.Connect(tn.ctx, ali.Network.GetListenAddr()))
+	r.NoError(eve.Network.Connect(tn.ctx, ali.Network.GetListenAddr()))
+
+	r.Eventually(func() bool {
+		note, err := bob.CurrentSequence(ali.KeyPair.ID())
+		return err == nil && note.Seq == n
+	}, 10*time.Second, 50*time.Millisecond, "bob didn't get ali's messages")
+
+	// eve and ali got each other's messages but they didn't verify
+	verifyFailed := "ebt-verify-failed"
+	if disableEBT {
+		verifyFailed = "gossip-verify-failed"
+	}
+	r.Eventually(func() bool {
+		return eventCount(t, eveMetrics, verifyFailed) > 0 && eventCount(t, aliMetrics, verifyFailed) > 0
+	}, 10*time.Second, 50*time.Millisecond, "eve and ali didn't try to verify each other's messages")
+
+	note, err := eve.CurrentSequence(ali.KeyPair.ID())
+	r.NoError(err)
+	a.EqualValues(-1, note.Seq, "eve stored messages of ali")
+	note, err = ali.CurrentSequence(eve.KeyPair.ID())
+	r.NoError(err)
+	a.EqualValues(-1, note.Seq, "ali stored messages of eve")
+
+	tn.close()
+}
+
+// eventCount returns the count of the named event in reg, see WithMetricsRegistry
+func eventCount(t *testing.T, reg *stdprometheus.Registry, name string) float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "event" && l.GetValue() == name && m.Counter != nil {
+					return m.Counter.GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
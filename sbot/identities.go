@@ -23,6 +23,16 @@ func (sbot *Sbot) PublishAs(nick string, val interface{}) (refs.Message, error)
 	var pubopts = []message.PublishOption{
 		message.UseNowTimestamps(true),
 		message.UseWaitForIndexesCallback(sbot.WaitUntilIndexesAreSynced),
+		message.UseFeedFormats(sbot.feedFormats),
+	}
+	if sbot.publishBacklogMax > 0 || sbot.publishLimiter != nil {
+		pubopts = append(pubopts, message.UsePublishGate(sbot.publishGate))
+	}
+	if sbot.publishValidator != nil {
+		pubopts = append(pubopts, message.UseContentValidator(sbot.publishValidator))
+	}
+	if len(sbot.publishHooks) > 0 {
+		pubopts = append(pubopts, message.UsePublishedCallback(sbot.publishedCallback))
 	}
 	if sbot.signHMACsecret != nil { // all feeds use the same settings right now
 		pubopts = append(pubopts, message.SetHMACKey(sbot.signHMACsecret))
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/internal/statematrix"
+)
+
+// IgnoreFeed stops the replication of feed, even if it is within the hop range.
+// The stored messages are kept but no new ones are requested from other peers.
+// Like the other feeds outside of the hop range, it is also not allowed to connect, unless the bot is promiscuous.
+func (s *Sbot) IgnoreFeed(feed refs.FeedRef) error {
+	if feed.Equal(s.KeyPair.ID()) {
+		return errors.New("ignore feed: can't ignore our own feed")
+	}
+
+	changed, err := s.ignored.set(feed, true)
+	if err != nil || !changed {
+		return err
+	}
+
+	note, err := s.CurrentSequence(feed)
+	if err != nil {
+		return fmt.Errorf("ignore feed: %w", err)
+	}
+	err = s.ebtState.Fill(s.KeyPair.ID(), []statematrix.ObservedFeed{
+		{Feed: feed, Note: ssb.Note{Seq: note.Seq, Receive: false, Replicate: false}},
+	})
+	if err != nil {
+		return fmt.Errorf("ignore feed: failed to update the state matrix: %w", err)
+	}
+
+	s.Replicator.DontReplicate(feed)
+	return nil
+}
+
+// UnignoreFeed reverts IgnoreFeed. If feed is within the hop range or added with ReplicateFeed, it is replicated again right away.
+func (s *Sbot) UnignoreFeed(feed refs.FeedRef) error {
+	changed, err := s.ignored.set(feed, false)
+	if err != nil || !changed {
+		return err
+	}
+
+	if !s.wantsFeed(feed) {
+		return nil
+	}
+
+	note, err := s.CurrentSequence(feed)
+	if err != nil {
+		return fmt.Errorf("unignore feed: %w", err)
+	}
+	err = s.ebtState.Fill(s.KeyPair.ID(), []statematrix.ObservedFeed{
+		{Feed: feed, Note: ssb.Note{Seq: note.Seq, Receive: true, Replicate: true}},
+	})
+	if err != nil {
+		return fmt.Errorf("unignore feed: failed to update the state matrix: %w", err)
+	}
+
+	s.Replicator.Replicate(feed)
+	return nil
+}
+
+// IgnoredFeeds returns the feeds that are not replicated because of IgnoreFeed
+func (s *Sbot) IgnoredFeeds() []refs.FeedRef {
+	return s.ignored.List()
+}
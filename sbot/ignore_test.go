@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestIgnoreFeed(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	open := func() *Sbot {
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+		)
+		r.NoError(err)
+		return bot
+	}
+	bot := open()
+
+	noisy, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	other, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	for _, f := range []refs.FeedRef{noisy, other} {
+		_, err = bot.PublishLog.Publish(refs.NewContactFollow(f))
+		r.NoError(err)
+	}
+	bot.WaitUntilIndexesAreSynced()
+
+	update := func() {
+		bot.Replicator.(*graphReplicator).update()
+	}
+	update()
+
+	wants := bot.Lister().ReplicationList()
+	r.True(wants.Has(noisy))
+	r.True(wants.Has(other))
+
+	r.Error(bot.IgnoreFeed(bot.KeyPair.ID()), "can't ignore ourselves")
+
+	r.NoError(bot.IgnoreFeed(noisy))
+	r.NoError(bot.IgnoreFeed(noisy), "ignoring twice is fine")
+	r.Equal([]refs.FeedRef{noisy}, bot.IgnoredFeeds())
+	r.False(wants.Has(noisy))
+	r.True(wants.Has(other))
+
+	front, err := bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.False(front[noisy.String()].Replicate, "the state matrix should drop the feed")
+	r.False(front[noisy.String()].Receive)
+
+	// the next hop walk doesn't add it again
+	update()
+	r.False(wants.Has(noisy))
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// the ignore list survives a restart
+	bot = open()
+	r.Equal([]refs.FeedRef{noisy}, bot.IgnoredFeeds())
+	bot.WaitUntilIndexesAreSynced()
+	update()
+	wants = bot.Lister().ReplicationList()
+	r.False(wants.Has(noisy))
+	r.True(wants.Has(other))
+
+	r.NoError(bot.UnignoreFeed(noisy))
+	r.Empty(bot.IgnoredFeeds())
+	r.True(wants.Has(noisy), "a feed within hops is replicated right away")
+
+	front, err = bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.True(front[noisy.String()].Replicate)
+	r.True(front[noisy.String()].Receive)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
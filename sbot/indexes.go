@@ -12,7 +12,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/machinebox/progress"
 	"github.com/ssbc/go-luigi"
 	"github.com/ssbc/go-ssb"
 	"github.com/ssbc/go-ssb/multilogs"
@@ -179,6 +178,7 @@ func (s *Sbot) serveIndexFrom(name string, snk librarian.SinkIndex, msgs margare
 
 	s.indexStateMu.Lock()
 	s.indexStates[name] = "pending"
+	s.indexSinks[name] = servedIndex{snk: snk, msgs: msgs}
 	s.indexStateMu.Unlock()
 
 	s.idxDone.Go(func() error {
@@ -190,29 +190,64 @@ func (s *Sbot) serveIndexFrom(name string, snk librarian.SinkIndex, msgs margare
 
 		logger := log.With(s.info, "index", name)
 
-		var ps progressSink
-		ps.backing = snk
+		// the sinks keep the sequence they reached, so an interrupted build continues where it stopped
+		total := msgs.Seq() + 1
+		resumed, err := indexResumeSeq(msgs, snk)
+		if err != nil {
+			return fmt.Errorf("sbot index(%s) failed to find resume point: %w", name, err)
+		}
+		if resumed > 0 && resumed < total {
+			level.Info(logger).Log("event", "index-resume", "from", resumed, "total", total)
+		}
 
-		totalMessages := msgs.Seq()
+		ps := &progressSink{
+			backing: snk,
+			n:       resumed,
+		}
+		if msgs == s.ReceiveLog {
+			ps.progressed = s.updateIndexBacklogGauge
+			s.indexStateMu.Lock()
+			s.indexProgressSinks[name] = ps
+			s.indexStateMu.Unlock()
+		}
+		s.reportIndexProgress(name, resumed, total)
 
 		ctx, cancel := context.WithCancel(s.rootCtx)
 		go func() {
-			p := progress.NewTicker(ctx, &ps, int64(totalMessages), 7*time.Second)
+			tick := time.NewTicker(7 * time.Second)
+			defer tick.Stop()
 			pinfo := log.With(level.Info(logger), "event", "index-progress")
-			for remaining := range p {
-				// how much time until it's done?
-				estDone := remaining.Estimated()
-				timeLeft := estDone.Sub(time.Now()).Round(time.Second)
-
-				pinfo.Log("done", remaining.Percent(), "time-left", timeLeft)
+			started := time.Now()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-tick.C:
+				}
+
+				done := ps.N()
+				if done >= total {
+					continue
+				}
+
+				// how much time until it's done, at the rate of this run?
+				var timeLeft time.Duration
+				if processed := done - resumed; processed > 0 {
+					perMsg := time.Since(started) / time.Duration(processed)
+					timeLeft = (perMsg * time.Duration(total-done)).Round(time.Second)
+				}
+				percent := float64(done) / float64(total) * 100
+
+				pinfo.Log("done", percent, "seq", done, "total", total, "time-left", timeLeft)
+				s.reportIndexProgress(name, done, total)
 
 				s.indexStateMu.Lock()
-				s.indexStates[name] = fmt.Sprintf("%.2f%% (time left:%s)", remaining.Percent(), timeLeft)
+				s.indexStates[name] = fmt.Sprintf("%.2f%% (time left:%s)", percent, timeLeft)
 				s.indexStateMu.Unlock()
 			}
 		}()
 
-		err = luigi.Pump(s.rootCtx, &ps, src)
+		err = luigi.Pump(s.rootCtx, ps, src)
 		cancel()
 		s.indexSyncDone() // this needs to be before we can return for errors or idxInSync will not be updated correctly
 		if errors.Is(err, ssb.ErrShuttingDown) || errors.Is(err, context.Canceled) {
@@ -225,6 +260,9 @@ func (s *Sbot) serveIndexFrom(name string, snk librarian.SinkIndex, msgs margare
 			level.Warn(logger).Log("event", "index stopped", "err", err)
 			return fmt.Errorf("sbot index(%s) update of backlog failed: %w", name, err)
 		}
+		if resumed < total {
+			s.reportIndexProgress(name, total, total)
+		}
 
 		if !s.liveIndexUpdates {
 			return nil
@@ -256,7 +294,8 @@ func (s *Sbot) serveIndexFrom(name string, snk librarian.SinkIndex, msgs margare
 			})
 		}
 
-		err = luigi.PumpWithStatus(s.rootCtx, snk, src, startWaiting, doneWaiting, startProcessing, doneProcessing)
+		// keep counting, for IndexBacklog
+		err = luigi.PumpWithStatus(s.rootCtx, ps, src, startWaiting, doneWaiting, startProcessing, doneProcessing)
 		if errors.Is(err, ssb.ErrShuttingDown) || errors.Is(err, context.Canceled) {
 			return nil
 		}
@@ -271,24 +310,38 @@ func (s *Sbot) serveIndexFrom(name string, snk librarian.SinkIndex, msgs margare
 	})
 }
 
+// indexedSeq returns a function that tells up to which sequence of the receive log the named index is complete.
+// It is -1 until the index started.
+func (s *Sbot) indexedSeq(name string) func() int64 {
+	return func() int64 {
+		s.indexStateMu.Lock()
+		ps, has := s.indexProgressSinks[name]
+		s.indexStateMu.Unlock()
+		if !has {
+			return -1
+		}
+		return ps.N() - 1
+	}
+}
+
+// progressSink counts how many messages of the log are indexed, including the ones of earlier runs
 type progressSink struct {
-	erred error
+	// n is read without the lock, so that IndexBacklog doesn't wait for a message that is being indexed
+	n int64
 
-	mu sync.Mutex
-	n  uint
+	mu    sync.Mutex
+	erred error
 
 	backing luigi.Sink
+
+	// progressed is called after every indexed message, if it is set
+	progressed func()
 }
 
-var (
-	_ luigi.Sink       = &progressSink{}
-	_ progress.Counter = &progressSink{}
-)
+var _ luigi.Sink = &progressSink{}
 
 func (p *progressSink) N() int64 {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	return int64(p.n)
+	return atomic.LoadInt64(&p.n)
 }
 
 func (p *progressSink) Err() error {
@@ -310,8 +363,64 @@ func (ps *progressSink) Pour(ctx context.Context, v interface{}) error {
 		return err
 	}
 
-	ps.n++
+	if sw, ok := v.(margaret.SeqWrapper); ok {
+		atomic.StoreInt64(&ps.n, sw.Seq()+1)
+	} else {
+		atomic.AddInt64(&ps.n, 1)
+	}
+	if ps.progressed != nil {
+		ps.progressed()
+	}
 	return nil
 }
 
-func (ps progressSink) Close() error { return nil }
+func (ps *progressSink) Close() error { return nil }
+
+// IndexProgressFunc is called with the name of an index and how many of the total messages it processed.
+// done includes the messages that were indexed before the bot was started.
+type IndexProgressFunc func(index string, done, total int64)
+
+func (s *Sbot) reportIndexProgress(name string, done, total int64) {
+	if s.indexProgress != nil {
+		s.indexProgress(name, done, total)
+	}
+	s.events.emit(Event{Type: EventIndexProgress, Index: name, Done: done, Total: total})
+}
+
+// servedIndex is an index that is kept up to date with msgs, so that CheckRepo can compare them
+type servedIndex struct {
+	snk  librarian.SinkIndex
+	msgs margaret.Log
+}
+
+// indexResumeSeq returns how many messages of msgs the sink already processed, by looking at the first message it still needs
+func indexResumeSeq(msgs margaret.Log, snk librarian.SinkIndex) (int64, error) {
+	src, err := msgs.Query(snk.QuerySpec(), margaret.SeqWrap(true))
+	if err != nil {
+		return 0, err
+	}
+
+	// nulled messages are not wrapped with their sequence
+	var nulled int64
+	for {
+		v, err := src.Next(context.Background())
+		if err != nil {
+			if luigi.IsEOS(err) {
+				return msgs.Seq() + 1, nil
+			}
+			return 0, err
+		}
+
+		switch tv := v.(type) {
+		case margaret.SeqWrapper:
+			return tv.Seq() - nulled, nil
+		case error:
+			if !margaret.IsErrNulled(tv) {
+				return 0, tv
+			}
+			nulled++
+		default:
+			return 0, fmt.Errorf("expected a seq wrapper, got %T", v)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	"github.com/ssbc/margaret/mem"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+type resumingSink struct {
+	luigi.Sink
+
+	seq int64
+}
+
+func (s resumingSink) QuerySpec() margaret.QuerySpec {
+	return margaret.Gt(s.seq)
+}
+
+func TestIndexResumeSeq(t *testing.T) {
+	r := require.New(t)
+
+	msgs := mem.New()
+
+	done, err := indexResumeSeq(msgs, resumingSink{seq: margaret.SeqEmpty})
+	r.NoError(err)
+	r.EqualValues(0, done)
+
+	for i := 0; i < 10; i++ {
+		_, err := msgs.Append(i)
+		r.NoError(err)
+	}
+
+	for _, tc := range []struct {
+		seq  int64
+		done int64
+	}{
+		{margaret.SeqEmpty, 0},
+		{0, 1},
+		{4, 5},
+		{9, 10},
+	} {
+		done, err := indexResumeSeq(msgs, resumingSink{seq: tc.seq})
+		r.NoError(err)
+		r.Equal(tc.done, done, "seq %d", tc.seq)
+	}
+}
+
+func TestIndexProgressAfterRestart(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	type report struct{ done, total int64 }
+	var (
+		mu      sync.Mutex
+		reports = make(map[string][]report)
+	)
+	open := func() *Sbot {
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+			WithIndexProgress(func(index string, done, total int64) {
+				mu.Lock()
+				reports[index] = append(reports[index], report{done, total})
+				mu.Unlock()
+			}),
+		)
+		r.NoError(err)
+		return bot
+	}
+
+	bot := open()
+	const n = 5
+	for i := 0; i < n; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+	bot.WaitUntilIndexesAreSynced()
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	mu.Lock()
+	r.Equal([]report{{0, 0}}, reports["get"], "the first start has nothing to index")
+	reports = make(map[string][]report)
+	mu.Unlock()
+
+	// the indexes continue where they stopped instead of starting over
+	bot = open()
+	bot.WaitUntilIndexesAreSynced()
+
+	mu.Lock()
+	r.Equal([]report{{n, n}}, reports["get"])
+	r.Equal([]report{{n, n}}, reports["combined"])
+	mu.Unlock()
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
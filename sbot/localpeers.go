@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"github.com/ssbc/go-ssb/network"
+)
+
+// LocalPeers returns the peers that recently advertised themselves on the local network, the most recently seen first.
+// Each peer is listed once, with the address it advertised last. Peers that weren't seen for a while are dropped.
+// It is empty unless local discovery is enabled with EnableAdvertismentDialing.
+func (s *Sbot) LocalPeers() []network.DiscoveredPeer {
+	if s.Network == nil {
+		return nil
+	}
+	return s.Network.DiscoveredPeers()
+}
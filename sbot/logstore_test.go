@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/message/multimsg"
+	"github.com/ssbc/go-ssb/repo/sqlitelog"
+)
+
+func TestSqliteLogStore(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	start := func() *Sbot {
+		store, err := sqlitelog.Open(filepath.Join(tRepoPath, "log.sqlite"), multimsg.MargaretCodec{})
+		r.NoError(err)
+
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			WithLogStore(store),
+			DisableNetworkNode(),
+		)
+		r.NoError(err)
+		return bot
+	}
+
+	bot := start()
+	for i := 0; i < 3; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+	r.EqualValues(2, bot.ReceiveLog.Seq())
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// the default log was not used
+	_, err := os.Stat(filepath.Join(tRepoPath, "log"))
+	r.True(os.IsNotExist(err), "offset log exists: %v", err)
+
+	// the messages are read back after a restart
+	bot = start()
+	r.EqualValues(2, bot.ReceiveLog.Seq())
+
+	lastGood, err := bot.VerifyFeed(bot.KeyPair.ID())
+	r.NoError(err)
+	r.EqualValues(3, lastGood)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -61,16 +61,20 @@ var manifestBlob manifestHandler = `
 {
 	"blobs": {
 		"add": "sink",
+		"changes": "source",
 		"createWants": "source",
 		"get": "source",
 		"has": "async",
 		"size": "async",
-		"want": "async"
+		"unwant": "async",
+		"want": "async",
+		"wants": "async"
 	},
 	"conn": {
 		"connect": "async",
 		"dialViaRoom": "async",
 		"disconnect": "async",
+		"discovered": "async",
 		"replicate": "async"
 	},
 	"createFeedStream": "source",
@@ -79,13 +83,19 @@ var manifestBlob manifestHandler = `
 	"ebt": {
 		"replicate": "duplex"
 	},
+	"feed": {
+		"tail": "source"
+	},
 	"friends": {
 		"blocks": "source",
 		"hops": "source",
 		"isBlocking": "async",
-		"isFollowing": "async"
+		"isFollowing": "async",
+		"mutuals": "source",
+		"subgraph": "async"
 	},
 	"get": "async",
+	"getMany": "async",
 	"gossip": {
 		"connect": "async",
 		"ping": "duplex"
@@ -96,13 +106,19 @@ var manifestBlob manifestHandler = `
   },
 	"invite": {
 		"create": "async",
-		"use": "async"
+		"use": "async",
+		"revoke": "async",
+		"list": "async"
 	},
 	"manifest": "sync",
+	"mentions": {
+		"of": "source"
+	},
 	"messagesByType": "source",
 	"names": {
 		"get": "async",
 		"getImageFor": "async",
+		"getProfile": "async",
 		"getSignifier": "async"
 	},
 	"partialReplication": {
@@ -112,20 +128,46 @@ var manifestBlob manifestHandler = `
 	"publish": "async",
 	"private": {
 		"publish": "async",
-		"read":"source"
+		"read":"source",
+		"readPage": "async"
 	},
 	"replicate": {
+		"add": "async",
+		"added": "async",
+		"ignore": "async",
+		"ignored": "async",
+		"remove": "async",
+		"status": "async",
+		"unignore": "async",
 		"upto": "source"
 	},
+	"repo": {
+		"diskUsage": "async"
+	},
+	"room": {
+		"attendants": "source",
+		"metadata": "async"
+	},
+	"search": {
+		"query": "async"
+	},
+	"server": {
+		"version": "async"
+	},
 	"status": "sync",
 	"tangles": {
 		"thread": "source"
 	},
 	"tunnel": {
 		"connect": "duplex",
+		"endpoints": "source",
 		"isRoom": "async",
 		"ping": "async"
 	},
-	"whoami": "sync"
+	"verify": {
+		"feed": "async"
+	},
+	"whoami": "sync",
+	"whoamiDetailed": "async"
 }
 `
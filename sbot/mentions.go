@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/multilogs"
+)
+
+// MentionsOf returns the public messages which mention ref, oldest first.
+func (s *Sbot) MentionsOf(ref refs.Ref) (luigi.Source, error) {
+	s.WaitUntilIndexesAreSynced()
+
+	mentionLog, err := s.Mentions.Get(multilogs.MentionAddr(ref))
+	if err != nil {
+		return nil, fmt.Errorf("sbot/mentions: failed to open sublog for %s: %w", ref.ShortSigil(), err)
+	}
+
+	src, err := mutil.Indirect(s.ReceiveLog, mentionLog).Query()
+	if err != nil {
+		return nil, fmt.Errorf("sbot/mentions: failed to create query: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestMentionsOf(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	alice := bot.KeyPair.ID()
+
+	first, err := bot.PublishLog.Publish(refs.NewPost("first"))
+	r.NoError(err)
+
+	objMention, err := bot.PublishLog.Publish(map[string]interface{}{
+		"type":     "post",
+		"text":     "hi alice",
+		"mentions": []interface{}{map[string]interface{}{"link": alice.String(), "name": "alice"}},
+	})
+	r.NoError(err)
+
+	strMention, err := bot.PublishLog.Publish(map[string]interface{}{
+		"type":     "post",
+		"text":     "see first and alice",
+		"mentions": []string{first.Key().String(), alice.String(), "not a ref"},
+	})
+	r.NoError(err)
+
+	_, err = bot.PublishLog.Publish(map[string]interface{}{
+		"type":     "post",
+		"text":     "broken",
+		"mentions": "nope",
+	})
+	r.NoError(err)
+
+	keysOf := func(ref refs.Ref) []refs.MessageRef {
+		src, err := bot.MentionsOf(ref)
+		r.NoError(err)
+
+		var keys []refs.MessageRef
+		for {
+			v, err := src.Next(context.TODO())
+			if luigi.IsEOS(err) {
+				break
+			}
+			r.NoError(err)
+			msg, ok := v.(refs.Message)
+			r.True(ok, "got %T", v)
+			keys = append(keys, msg.Key())
+		}
+		return keys
+	}
+
+	r.Equal([]refs.MessageRef{objMention.Key(), strMention.Key()}, keysOf(alice))
+	r.Equal([]refs.MessageRef{strMention.Key()}, keysOf(first.Key()))
+	r.Len(keysOf(strMention.Key()), 0)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -44,6 +44,9 @@ type metaFeedsService struct {
 	keys         *keys.Store
 
 	hmacSecret *[32]byte
+
+	// published is passed to the publish logs, see WithPublishHook
+	published func(rxSeq int64)
 }
 
 func newMetaFeedService(rxLog margaret.Log, indexManager ssb.IndexFeedManager, users multilog.MultiLog, keyStore *keys.Store, keypair ssb.KeyPair, hmacSecret *[32]byte) (*metaFeedsService, error) {
@@ -261,7 +264,7 @@ func (s metaFeedsService) CreateSubFeed(mount refs.FeedRef, purpose string, form
 		return refs.FeedRef{}, err
 	}
 
-	metaPublisher, err := message.OpenPublishLog(s.rxLog, s.users, mountKeyPair, message.SetHMACKey(s.hmacSecret))
+	metaPublisher, err := message.OpenPublishLog(s.rxLog, s.users, mountKeyPair, s.publishOptions()...)
 	if err != nil {
 		return refs.FeedRef{}, err
 	}
@@ -307,6 +310,7 @@ func (s metaFeedsService) CreateSubFeed(mount refs.FeedRef, purpose string, form
 		}
 	}
 
+	// the sub signature isn't keyed with the hmac, go-metafeed doesn't take one. The message around it is.
 	addMsg, err := metafeed.SubSignContent(newSubfeedKeyPair.PrivateKey, addContent)
 	if err != nil {
 		return refs.FeedRef{}, err
@@ -410,7 +414,7 @@ func (s metaFeedsService) TombstoneSubFeed(mount, subfeed refs.FeedRef) error {
 		return err
 	}
 
-	metaPublisher, err := message.OpenPublishLog(s.rxLog, s.users, mountKeyPair, message.SetHMACKey(s.hmacSecret))
+	metaPublisher, err := message.OpenPublishLog(s.rxLog, s.users, mountKeyPair, s.publishOptions()...)
 	if err != nil {
 		return err
 	}
@@ -480,13 +484,22 @@ func (s metaFeedsService) ListSubFeeds(mount refs.FeedRef) ([]ssb.SubfeedListEnt
 	return lst, nil
 }
 
+// publishOptions are the options for the publish logs of the metafeeds
+func (s metaFeedsService) publishOptions() []message.PublishOption {
+	opts := []message.PublishOption{message.SetHMACKey(s.hmacSecret)}
+	if s.published != nil {
+		opts = append(opts, message.UsePublishedCallback(s.published))
+	}
+	return opts
+}
+
 func (s metaFeedsService) getPublisher(as refs.FeedRef) (ssb.Publisher, error) {
 	kp, err := loadMetafeedKeyPairFromStore(s.keys, as)
 	if err != nil {
 		return nil, fmt.Errorf("metafeeds.Publish failed to load signing keypair: %w", err)
 	}
 
-	publisher, err := message.OpenPublishLog(s.rxLog, s.users, kp, message.SetHMACKey(s.hmacSecret))
+	publisher, err := message.OpenPublishLog(s.rxLog, s.users, kp, s.publishOptions()...)
 	if err != nil {
 		return nil, fmt.Errorf("metafeeds.Publish failed to open publish log (%w)", err)
 	}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the prometheus metrics of the bot, see WithMetricsRegistry
+type Metrics struct {
+	// Events counts what happened, by the "event" label. For instance "clock-skew" or "publish-busy".
+	Events *prometheus.Counter
+
+	// Repo are the current levels of the bot, by the "part" label. For instance "index-backlog" or the number of wanted blobs.
+	Repo *prometheus.Gauge
+
+	// Durations are the timings of the bot, by the "part" label. For instance "graph_auth".
+	Durations *prometheus.Summary
+}
+
+// NewMetrics defines the metrics of the bot on reg.
+// Metrics that are already registered there, for instance by an earlier call, are used as they are.
+func NewMetrics(reg stdprometheus.Registerer) (*Metrics, error) {
+	events := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
+		Namespace: "gossb",
+		Subsystem: "events",
+		Name:      "ssb_sysevents",
+	}, []string{"event"})
+	c, err := register(reg, events)
+	if err != nil {
+		return nil, err
+	}
+	events, ok := c.(*stdprometheus.CounterVec)
+	if !ok {
+		return nil, fmt.Errorf("sbot: events metric is registered as %T", c)
+	}
+
+	repoStats := stdprometheus.NewGaugeVec(stdprometheus.GaugeOpts{
+		Namespace: "gossb",
+		Subsystem: "repo",
+		Name:      "ssb_repostats",
+	}, []string{"part"})
+	c, err = register(reg, repoStats)
+	if err != nil {
+		return nil, err
+	}
+	repoStats, ok = c.(*stdprometheus.GaugeVec)
+	if !ok {
+		return nil, fmt.Errorf("sbot: repo metric is registered as %T", c)
+	}
+
+	durations := stdprometheus.NewSummaryVec(stdprometheus.SummaryOpts{
+		Namespace: "gossb",
+		Subsystem: "sbot",
+		Name:      "general_durrations",
+	}, []string{"part"})
+	c, err = register(reg, durations)
+	if err != nil {
+		return nil, err
+	}
+	durations, ok = c.(*stdprometheus.SummaryVec)
+	if !ok {
+		return nil, fmt.Errorf("sbot: durations metric is registered as %T", c)
+	}
+
+	return &Metrics{
+		Events:    prometheus.NewCounter(events),
+		Repo:      prometheus.NewGauge(repoStats),
+		Durations: prometheus.NewSummary(durations),
+	}, nil
+}
+
+// register adds c to reg and returns it, or the collector that is already registered in its place
+func register(reg stdprometheus.Registerer, c stdprometheus.Collector) (stdprometheus.Collector, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var are stdprometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+	return nil, fmt.Errorf("sbot: failed to register metric: %w", err)
+}
+
+// WithMetricsRegistry registers the metrics of the bot on reg, instead of leaving them out.
+// This way a bot that is embedded in a larger service can share one registry with the rest of it.
+// The metrics are also available as the Metrics field of the bot. It replaces WithEventMetrics.
+func WithMetricsRegistry(reg *stdprometheus.Registry) Option {
+	return func(s *Sbot) error {
+		if reg == nil {
+			return fmt.Errorf("WithMetricsRegistry: registry is nil")
+		}
+		m, err := NewMetrics(reg)
+		if err != nil {
+			return err
+		}
+		s.Metrics = m
+		s.eventCounter = m.Events
+		s.systemGauge = m.Repo
+		s.latency = m.Durations
+		return nil
+	}
+}
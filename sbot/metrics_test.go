@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	reg := stdprometheus.NewRegistry()
+	other := stdprometheus.NewCounter(stdprometheus.CounterOpts{Name: "other_subsystem_total"})
+	reg.MustRegister(other)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithMetricsRegistry(reg),
+	)
+	r.NoError(err)
+	r.NotNil(bot.Metrics)
+
+	_, err = bot.PublishLog.Publish(refs.NewPost("counted"))
+	r.NoError(err)
+	bot.Metrics.Events.With("event", "test").Add(1)
+	other.Inc()
+
+	// a second set on the same registry uses the same metrics
+	again, err := NewMetrics(reg)
+	r.NoError(err)
+	again.Events.With("event", "test").Add(1)
+
+	families, err := reg.Gather()
+	r.NoError(err)
+	byName := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			name := f.GetName()
+			for _, l := range m.GetLabel() {
+				name += "/" + l.GetValue()
+			}
+			switch {
+			case m.Counter != nil:
+				byName[name] = m.Counter.GetValue()
+			case m.Gauge != nil:
+				byName[name] = m.Gauge.GetValue()
+			}
+		}
+	}
+	r.EqualValues(1, byName["other_subsystem_total"])
+	r.EqualValues(2, byName["gossb_events_ssb_sysevents/test"])
+	// set by the indexes, without backpressure
+	r.Contains(byName, "gossb_repo_ssb_repostats/index-backlog")
+	r.EqualValues(0, byName["gossb_repo_ssb_repostats/index-backlog"])
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/plugins2/names"
+)
+
+// GetAbout returns the latest name, description and image that feed chose for itself and the ones others prescribed for it.
+// Use the Value() of each attribute to resolve them, what the feed chose for itself wins.
+func (s *Sbot) GetAbout(feed refs.FeedRef) (names.AboutInfo, error) {
+	s.WaitUntilIndexesAreSynced()
+
+	ai, err := s.names.About(feed)
+	if err != nil {
+		return names.AboutInfo{}, fmt.Errorf("sbot/names: failed to get abouts of %s: %w", feed.ShortSigil(), err)
+	}
+	return ai, nil
+}
@@ -18,6 +18,7 @@ import (
 	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/client"
 	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
 	"github.com/ssbc/go-ssb/repo"
 )
 
@@ -128,3 +129,78 @@ func TestNames(t *testing.T) {
 	mainbot.Shutdown()
 	r.NoError(mainbot.Close())
 }
+
+func TestGetAbout(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	tRepo := repo.New(tRepoPath)
+	kpAlice, err := repo.NewKeyPair(tRepo, "alice", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	_, err = repo.NewKeyPair(tRepo, "bob", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	kpClaire, err := repo.NewKeyPair(tRepo, "claire", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	name := func(as string, about refs.FeedRef, name string) {
+		msg := refs.NewAboutName(about, name)
+		if as == "" {
+			_, err := bot.PublishLog.Publish(msg)
+			r.NoError(err)
+			return
+		}
+		_, err := bot.PublishAs(as, msg)
+		r.NoError(err)
+	}
+
+	// nothing known yet
+	ai, err := bot.GetAbout(kpAlice.ID())
+	r.NoError(err)
+	r.Equal("", ai.Name.Value())
+
+	// others agree on a name for alice, but she renamed herself
+	name("alice", kpAlice.ID(), "alice")
+	name("", kpAlice.ID(), "ally")
+	name("bob", kpAlice.ID(), "ally")
+	name("alice", kpAlice.ID(), "Alice")
+
+	ai, err = bot.GetAbout(kpAlice.ID())
+	r.NoError(err)
+	r.Equal("Alice", ai.Name.Chosen)
+	r.Equal(2, ai.Name.Prescribed["ally"])
+	r.Equal("Alice", ai.Name.Value())
+
+	// claire didn't name herself, the tie is broken by the smaller name
+	name("", kpClaire.ID(), "cc")
+	name("bob", kpClaire.ID(), "claire")
+
+	ai, err = bot.GetAbout(kpClaire.ID())
+	r.NoError(err)
+	r.Equal("", ai.Name.Chosen)
+	r.Equal("cc", ai.Name.Value())
+
+	// until more agree on the other one
+	name("alice", kpClaire.ID(), "claire")
+	ai, err = bot.GetAbout(kpClaire.ID())
+	r.NoError(err)
+	r.Equal("claire", ai.Name.Value())
+
+	// only the latest name of each author counts
+	name("", kpClaire.ID(), "claire")
+	ai, err = bot.GetAbout(kpClaire.ID())
+	r.NoError(err)
+	r.Len(ai.Name.Prescribed, 1)
+	r.Equal(3, ai.Name.Prescribed["claire"])
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
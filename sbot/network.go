@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MainNetwork is the name of the main scuttlebutt network, which is used by default
+const MainNetwork = "mainnet"
+
+// Network holds the keys that set a scuttlebutt network apart from others, see WithNetwork
+type Network struct {
+	// AppKey is the secret-handshake capability, see WithAppKey
+	AppKey []byte
+
+	// HMACKey is optional, if it is set messages are signed with it, see WithHMACSigning
+	HMACKey []byte
+}
+
+var (
+	networksMu sync.Mutex
+	networks   = map[string]Network{
+		MainNetwork: {AppKey: mustDecodeBase64("1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=")},
+	}
+)
+
+func mustDecodeBase64(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// RegisterNetwork makes a network known under name, so that it can be picked with WithNetwork.
+// Names can't be registered twice.
+func RegisterNetwork(name string, n Network) error {
+	if name == "" {
+		return fmt.Errorf("sbot: network name can't be empty")
+	}
+	if l := len(n.AppKey); l != 32 {
+		return fmt.Errorf("sbot: network %q: app key needs 32 bytes got %d", name, l)
+	}
+	if l := len(n.HMACKey); l != 0 && l != 32 {
+		return fmt.Errorf("sbot: network %q: hmac key needs 32 bytes got %d", name, l)
+	}
+
+	networksMu.Lock()
+	defer networksMu.Unlock()
+	if _, has := networks[name]; has {
+		return fmt.Errorf("sbot: network %q is already registered", name)
+	}
+	networks[name] = n
+	return nil
+}
+
+// LookupNetwork returns the network registered under name
+func LookupNetwork(name string) (Network, bool) {
+	networksMu.Lock()
+	defer networksMu.Unlock()
+	n, has := networks[name]
+	return n, has
+}
+
+// NetworkNames returns the names of all known networks, sorted
+func NetworkNames() []string {
+	networksMu.Lock()
+	defer networksMu.Unlock()
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithNetwork picks the network by name and sets its app key and hmac key, see RegisterNetwork.
+// It can't be combined with WithAppKey or WithHMACSigning.
+func WithNetwork(name string) Option {
+	return func(s *Sbot) error {
+		n, has := LookupNetwork(name)
+		if !has {
+			return fmt.Errorf("sbot: unknown network %q (known: %v)", name, NetworkNames())
+		}
+		if s.appKey != nil || s.signHMACsecret != nil {
+			return fmt.Errorf("sbot: network %q conflicts with an explicit app key or hmac key", name)
+		}
+
+		s.networkName = name
+		s.appKey = n.AppKey
+		if n.HMACKey != nil {
+			var k [32]byte
+			copy(k[:], n.HMACKey)
+			s.signHMACsecret = &k
+		}
+		return nil
+	}
+}
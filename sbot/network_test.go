@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestWithNetwork(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	appKey := bytes.Repeat([]byte{1}, 32)
+	hmacKey := bytes.Repeat([]byte{2}, 32)
+
+	r.Error(RegisterNetwork("", Network{AppKey: appKey}))
+	r.Error(RegisterNetwork("short", Network{AppKey: appKey[:16]}))
+	r.Error(RegisterNetwork("badhmac", Network{AppKey: appKey, HMACKey: hmacKey[:3]}))
+	r.Error(RegisterNetwork(MainNetwork, Network{AppKey: appKey}), "can't replace mainnet")
+
+	name := "test-" + t.Name()
+	r.NoError(RegisterNetwork(name, Network{AppKey: appKey, HMACKey: hmacKey}))
+	r.Error(RegisterNetwork(name, Network{AppKey: appKey}), "registered twice")
+	r.Contains(NetworkNames(), name)
+	r.Contains(NetworkNames(), MainNetwork)
+
+	_, err := New(WithNetwork("no-such-net"), WithRepoPath(tRepoPath), DisableNetworkNode())
+	r.Error(err)
+	_, err = New(WithNetwork(name), WithAppKey(appKey), WithRepoPath(tRepoPath), DisableNetworkNode())
+	r.Error(err)
+	_, err = New(WithHMACSigning(hmacKey), WithNetwork(name), WithRepoPath(tRepoPath), DisableNetworkNode())
+	r.Error(err)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithNetwork(name),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+	r.Equal(appKey, bot.appKey)
+	r.NotNil(bot.signHMACsecret)
+	r.Equal(hmacKey, bot.signHMACsecret[:])
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
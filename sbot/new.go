@@ -6,7 +6,6 @@ package sbot
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"io"
 	"net"
@@ -49,24 +48,32 @@ import (
 	"github.com/ssbc/go-ssb/network"
 	"github.com/ssbc/go-ssb/plugins/blobs"
 	"github.com/ssbc/go-ssb/plugins/conn"
+	"github.com/ssbc/go-ssb/plugins/diskusage"
 	"github.com/ssbc/go-ssb/plugins/ebt"
+	"github.com/ssbc/go-ssb/plugins/feed"
 	"github.com/ssbc/go-ssb/plugins/friends"
 	"github.com/ssbc/go-ssb/plugins/get"
 	"github.com/ssbc/go-ssb/plugins/gossip"
 	"github.com/ssbc/go-ssb/plugins/groups"
 	"github.com/ssbc/go-ssb/plugins/legacyinvites"
+	"github.com/ssbc/go-ssb/plugins/mentions"
 	"github.com/ssbc/go-ssb/plugins/partial"
 	privplug "github.com/ssbc/go-ssb/plugins/private"
 	"github.com/ssbc/go-ssb/plugins/publish"
 	"github.com/ssbc/go-ssb/plugins/rawread"
 	"github.com/ssbc/go-ssb/plugins/replicate"
+	"github.com/ssbc/go-ssb/plugins/room"
+	"github.com/ssbc/go-ssb/plugins/search"
+	"github.com/ssbc/go-ssb/plugins/server"
 	"github.com/ssbc/go-ssb/plugins/status"
 	"github.com/ssbc/go-ssb/plugins/tangles"
+	"github.com/ssbc/go-ssb/plugins/verify"
 	"github.com/ssbc/go-ssb/plugins/whoami"
 	"github.com/ssbc/go-ssb/plugins2/names"
 	"github.com/ssbc/go-ssb/private"
 	"github.com/ssbc/go-ssb/private/keys"
 	"github.com/ssbc/go-ssb/repo"
+	"github.com/ssbc/go-ssb/repo/archive"
 )
 
 // Sbot is the database and replication server
@@ -91,20 +98,33 @@ type Sbot struct {
 	closedMu sync.Mutex
 	closeErr error
 
-	promisc  bool
-	hopCount uint
+	// promisc, hopCount and peerPolicies can be changed while running, see SetPromisc, SetHops and SetPeerPolicies
+	settingsMu   sync.Mutex
+	promisc      bool
+	hopCount     uint
+	peerPolicies map[string]peerPolicy // by public key
 
 	disableEBT                   bool
+	ebtIdleTimeout               time.Duration
+	ebtBatchWindow               time.Duration
+	ebtSlowWait                  time.Duration
+	ebtMaxWait                   time.Duration
+	ebtMaxSessions               int
+	ebtQueueWait                 time.Duration
+	ebtStateShards               int
+	ebtStateCompact              bool
 	disableLegacyLiveReplication bool
 
 	Network *network.Node
 	// TODO: these should all be options that are applied on the network construction...
 	disableNetwork     bool
 	appKey             []byte
-	listenAddr         net.Addr
+	networkName        string
+	listenAddrs        []net.Addr
 	dialer             netwrap.Dialer
 	edpWrapper         MuxrpcEndpointWrapper
 	networkConnTracker ssb.ConnTracker
+	connLimits         *network.ConnLimits
 	preSecureWrappers  []netwrap.ConnWrapper
 	postSecureWrappers []netwrap.ConnWrapper
 
@@ -112,10 +132,24 @@ type Sbot struct {
 	master ssb.PluginManager
 
 	authorizer ssb.Authorizer
+	// extraAuth are checked for every public connection, on top of authorizer
+	extraAuth []ssb.Authorizer
+
+	// audit records the authorization decisions, see WithAuditLog
+	audit *auditLog
+
+	// see WithDisableTOFU
+	disableTOFU bool
 
 	enableAdverts   bool
 	enableDiscovery bool
 
+	// see WithRoomServer
+	enableRoomServer bool
+
+	unixSock     *unixSockServer
+	unixSockPath string
+
 	websocketAddr    string
 	websocketTLSCert string
 	websocketTLSKey  string
@@ -123,6 +157,8 @@ type Sbot struct {
 	numberOfConcurrentReplicationsPerPeer uint
 	numberOfConcurrentReplications        uint
 
+	gossipFetcher, gossipServer *gossip.LegacyGossip
+
 	repoPath string
 	KeyPair  ssb.KeyPair
 
@@ -134,6 +170,22 @@ type Sbot struct {
 
 	PublishLog     ssb.Publisher
 	signHMACsecret *[32]byte
+	feedFormats    *message.FeedFormats
+
+	publishValidator message.ContentValidator
+
+	// see WithPublishBackpressure
+	publishBacklogMax int
+	publishBusyError  bool
+
+	// see WithPublishRateLimit
+	publishLimiter        *tokenBucket
+	publishRateLimitError bool
+
+	inviteExpiry time.Duration
+
+	enableSearch bool
+	searchIdx    *roaring.MultiLog
 
 	// hardcoded default indexes
 	Users   *roaring.MultiLog // one sublog per feed
@@ -141,6 +193,11 @@ type Sbot struct {
 	ByType  *roaring.MultiLog // one sublog per type: ... (special cases for private messages by suffix)
 	Tangles *roaring.MultiLog // one sublog per root:%ref (actual root is in the get index)
 
+	Mentions *roaring.MultiLog // one sublog per mention:ref, for public messages only
+
+	threads *indexes.Threads
+	names   names.Plugin
+
 	indexStore *badger.DB
 
 	// plugin indexes
@@ -150,8 +207,17 @@ type Sbot struct {
 	liveIndexUpdates bool
 	indexStateMu     sync.Mutex
 	indexStates      map[string]string
+	indexSinks       map[string]servedIndex
+
+	// how far the indexes that are fed by the receive log got, see IndexBacklog
+	indexProgressSinks map[string]*progressSink
+	// the sequence of the receive log, read without locking the log, see IndexBacklog
+	receiveLogHead int64
 
-	ebtState *statematrix.StateMatrix
+	ebtState    *statematrix.StateMatrix
+	ignored     *feedList
+	replicated  *feedList // see ReplicateFeed
+	ebtSessions *ebt.Sessions
 
 	verifyRouter *message.VerificationRouter
 
@@ -160,11 +226,54 @@ type Sbot struct {
 	BlobStore   ssb.BlobStore
 	WantManager ssb.WantManager
 
+	blobGC         *blobCollector
+	blobGCInterval time.Duration
+	blobGCGrace    time.Duration
+
+	archive       archive.Store
+	retention     *retentionJob
+	retentionKeep int
+
+	connScheduler        ConnScheduler
+	connScheduleInterval time.Duration
+	connPeers            []ConnCandidate
+	connSchedule         *connScheduleJob
+
+	// reported by server.version, see WithVersion
+	version string
+	build   string
+
+	// see WithTimestampTolerance and WithClockSkewDeferral
+	skewTolerance  time.Duration
+	skewDeferAfter int
+	skewDeferFor   time.Duration
+	clockSkew      *clockSkew
+
+	// receiveLogPath is only set for the default receive log, see Compact
+	receiveLogPath string
+	compactMu      sync.Mutex
+
+	onUnboxErr multilogs.UnboxErrorFunc
+
+	// see WithPublishHook
+	publishHooks      []func(refs.Message)
+	publishHookQueue  chan int64
+	publishHookRunner *publishHookJob
+
+	// Metrics is only set with WithMetricsRegistry
+	Metrics *Metrics
+
 	// TODO: wrap better
 	eventCounter metrics.Counter
 	systemGauge  metrics.Gauge
 	latency      metrics.Histogram
 
+	// lifecycle events, see Events
+	events            *eventBus
+	eventBuffer       int
+	watchMessagesOnce sync.Once
+	indexProgress     IndexProgressFunc
+
 	enableMetafeeds bool
 	MetaFeeds       ssb.MetaFeeds
 	IndexFeeds      ssb.IndexFeedManager
@@ -183,8 +292,13 @@ func New(fopts ...Option) (*Sbot, error) {
 	s.mlogIndicies = make(map[string]multilog.MultiLog)
 	s.simpleIndex = make(map[string]librarian.Index)
 	s.indexStates = make(map[string]string)
+	s.indexSinks = make(map[string]servedIndex)
+	s.indexProgressSinks = make(map[string]*progressSink)
 
 	s.disableLegacyLiveReplication = true
+	s.ebtBatchWindow = ebt.DefaultBatchWindow
+	s.ebtSlowWait = ebt.DefaultSlowSessionWait
+	s.connScheduleInterval = DefaultConnScheduleInterval
 
 	for i, opt := range fopts {
 		err := opt(s)
@@ -193,6 +307,14 @@ func New(fopts ...Option) (*Sbot, error) {
 		}
 	}
 
+	if s.publishBacklogMax > 0 && s.liveIndexUpdates {
+		return nil, fmt.Errorf("sbot: WithPublishBackpressure needs DisableLiveIndexMode, live indexes hold back appending already")
+	}
+
+	if s.retentionKeep > 0 && s.archive == nil {
+		return nil, fmt.Errorf("sbot: WithFeedRetention needs an archive, see WithArchiveStore")
+	}
+
 	if s.repoPath == "" {
 		u, err := user.Current()
 		if err != nil {
@@ -203,19 +325,27 @@ func New(fopts ...Option) (*Sbot, error) {
 	}
 
 	if s.appKey == nil {
-		ak, err := base64.StdEncoding.DecodeString("1KHLiKZvAvjbY1ziZEHMXawbCEIM6qwjCDm3VYRan/s=")
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode default appkey: %w", err)
-		}
-		s.appKey = ak
+		mainnet, _ := LookupNetwork(MainNetwork)
+		s.appKey = mainnet.AppKey
 	}
 
 	if s.dialer == nil {
 		s.dialer = netwrap.Dial
 	}
 
-	if s.listenAddr == nil {
-		s.listenAddr = &net.TCPAddr{Port: network.DefaultPort}
+	s.events = newEventBus(s.eventBuffer, s.eventCounter)
+	s.closers.AddCloser(s.events)
+
+	if s.feedFormats == nil {
+		ff, err := message.NewFeedFormats()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create feed formats: %w", err)
+		}
+		s.feedFormats = ff
+	}
+
+	if len(s.listenAddrs) == 0 {
+		s.listenAddrs = []net.Addr{&net.TCPAddr{Port: network.DefaultPort}}
 	}
 
 	if s.info == nil {
@@ -243,13 +373,24 @@ func New(fopts ...Option) (*Sbot, error) {
 		}
 	}
 
-	// TODO: optionize
-	s.ReceiveLog, err = repo.OpenLog(storageRepo)
-	if err != nil {
-		return nil, fmt.Errorf("sbot: failed to open rootlog: %w", err)
+	// if not configured
+	if s.ReceiveLog == nil {
+		// load default, offset log in the repo
+		s.ReceiveLog, err = repo.OpenLog(storageRepo)
+		if err != nil {
+			return nil, fmt.Errorf("sbot: failed to open rootlog: %w", err)
+		}
+		s.receiveLogPath = storageRepo.GetPath("log")
 	}
 	s.closers.AddCloser(s.ReceiveLog.(io.Closer))
 
+	// archived messages need to stay readable, even if retention was turned off again
+	if s.archive != nil {
+		s.closers.AddCloser(s.archive)
+		s.ReceiveLog = archive.NewLog(s.ReceiveLog, s.archive, multimsg.MargaretCodec{})
+	}
+	s.trackReceiveLogHead()
+
 	// if not configured
 	if s.BlobStore == nil {
 		// load default, local file blob store
@@ -259,6 +400,8 @@ func New(fopts ...Option) (*Sbot, error) {
 		}
 	}
 
+	s.initBlobGC()
+
 	wantsLog := log.With(s.info, "module", "WantManager")
 	wm := blobstore.NewWantManager(s.BlobStore,
 		blobstore.WantWithLogger(wantsLog),
@@ -275,9 +418,15 @@ func New(fopts ...Option) (*Sbot, error) {
 		}
 	}
 
+	smEncoding := statematrix.EncodingJSON
+	if s.ebtStateCompact {
+		smEncoding = statematrix.EncodingCompact
+	}
 	sm, err := statematrix.New(
 		storageRepo.GetPath("ebt-state-matrix"),
 		s.KeyPair.ID(),
+		statematrix.WithSharding(s.ebtStateShards),
+		statematrix.WithEncoding(smEncoding),
 	)
 	if err != nil {
 		return nil, err
@@ -309,7 +458,6 @@ func New(fopts ...Option) (*Sbot, error) {
 		{"msgTypes", &s.ByType},
 		{"tangles", &s.Tangles},
 		// TODO: channels
-		// TODO: mentions
 	}
 	for _, index := range mlogs {
 		mlog, err := multibadger.NewShared(s.indexStore, []byte("mlog-"+index.Name))
@@ -322,10 +470,44 @@ func New(fopts ...Option) (*Sbot, error) {
 		*index.Mlog = mlog
 	}
 
+	// messages by the refs they mention
+	mentionsIdx, mentionsSnk, err := repo.OpenFileSystemMultiLog(storageRepo, multilogs.IndexNameMentions, multilogs.MentionsUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("sbot: failed to open mentions index: %w", err)
+	}
+	s.closers.AddCloser(mentionsSnk)
+	s.closers.AddCloser(mentionsIdx)
+	s.serveIndex(multilogs.IndexNameMentions, mentionsSnk)
+	s.mlogIndicies[multilogs.IndexNameMentions] = mentionsIdx
+	s.Mentions = mentionsIdx
+
+	// full-text search over public posts
+	if s.enableSearch {
+		searchIdx, searchSnk, err := repo.OpenFileSystemMultiLog(storageRepo, multilogs.IndexNameSearch, multilogs.SearchUpdate)
+		if err != nil {
+			return nil, fmt.Errorf("sbot: failed to open search index: %w", err)
+		}
+		s.closers.AddCloser(searchSnk)
+		s.closers.AddCloser(searchIdx)
+		s.serveIndex(multilogs.IndexNameSearch, searchSnk)
+		s.mlogIndicies[multilogs.IndexNameSearch] = searchIdx
+		s.searchIdx = searchIdx
+	}
+
 	// publish
 	var pubopts = []message.PublishOption{
 		message.UseNowTimestamps(true),
 		message.UseWaitForIndexesCallback(s.WaitUntilIndexesAreSynced),
+		message.UseFeedFormats(s.feedFormats),
+	}
+	if s.publishBacklogMax > 0 || s.publishLimiter != nil {
+		pubopts = append(pubopts, message.UsePublishGate(s.publishGate))
+	}
+	if s.publishValidator != nil {
+		pubopts = append(pubopts, message.UseContentValidator(s.publishValidator))
+	}
+	if len(s.publishHooks) > 0 {
+		pubopts = append(pubopts, message.UsePublishedCallback(s.publishedCallback))
 	}
 	if s.signHMACsecret != nil {
 		pubopts = append(pubopts, message.SetHMACKey(s.signHMACsecret))
@@ -341,6 +523,12 @@ func New(fopts ...Option) (*Sbot, error) {
 	s.serveIndex("get", updateSink)
 	s.simpleIndex["get"] = getIdx
 
+	// thread(rootRef) -> reply summary
+	threadsIdx, threadsSink := indexes.OpenThreads(s.indexStore)
+	s.closers.AddCloser(threadsSink)
+	s.serveIndex("threads", threadsSink)
+	s.threads = threadsIdx
+
 	// groups2
 	idxKeys := libbadger.NewIndexWithKeyPrefix(s.indexStore, keys.Recipients{}, []byte("group-and-signing"))
 	keysStore := &keys.Store{
@@ -372,6 +560,7 @@ func New(fopts ...Option) (*Sbot, error) {
 	if err != nil {
 		return nil, fmt.Errorf("sbot: failed to open combined application index: %w", err)
 	}
+	combIdx.SetUnboxObserver(s.eventCounter, s.unboxFailed)
 	s.serveIndex("combined", combIdx)
 	s.closers.AddCloser(combIdx)
 
@@ -420,9 +609,19 @@ func New(fopts ...Option) (*Sbot, error) {
 	}
 	justContacts := mutil.Indirect(s.ReceiveLog, contactLog)
 
+	// use the graph from the last run, if the index didn't change since
+	snapshotter := graphSnapshotter{
+		info: log.With(s.info, "module", "graph"),
+		path: storageRepo.GetPath(repo.PrefixMultiLog, "graph-snapshot.json"),
+		gb:   gb,
+	}
+	snapshotter.load()
+
 	// fill the index
 	s.serveIndexFrom("contacts", updateContactsSink, justContacts)
+	s.closers.AddCloser(snapshotter)
 	s.closers.AddCloser(seqSetter)
+	s.closers.AddCloser(gb)
 	s.GraphBuilder = gb
 
 	// abouts
@@ -434,14 +633,18 @@ func New(fopts ...Option) (*Sbot, error) {
 	}
 	aboutsOnly := mutil.Indirect(s.ReceiveLog, aboutSeqs)
 
-	var namesPlug names.Plugin
-	_, aboutSnk := namesPlug.OpenSharedIndex(s.indexStore)
+	_, aboutSnk := s.names.OpenSharedIndex(s.indexStore)
 	s.closers.AddCloser(aboutSnk)
 	s.serveIndexFrom("abouts", aboutSnk, aboutsOnly)
 
 	// need to close s.indexStore _after_ the all the indexes closed and flushed
 	s.closers.AddCloser(s.indexStore)
 
+	s.ignored, err = loadFeedList("ignored feeds", storageRepo.GetPath("ignored-feeds.json"))
+	if err != nil {
+		return nil, err
+	}
+
 	// which feeds to replicate
 	if s.Replicator == nil {
 		s.Replicator, err = s.newGraphReplicator()
@@ -486,6 +689,20 @@ func New(fopts ...Option) (*Sbot, error) {
 		}
 	}
 
+	// the feeds that were added with ReplicateFeed, independent of the graph
+	s.replicated, err = loadFeedList("replicated feeds", storageRepo.GetPath("replicated-feeds.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, feed := range s.replicated.List() {
+		if s.ignored.Has(feed) {
+			continue
+		}
+		if err := s.wantFeed(feed); err != nil {
+			return nil, fmt.Errorf("failed to replicate stored feed %s: %w", feed.ShortSigil(), err)
+		}
+	}
+
 	s.MetaFeeds = disabledMetaFeeds{}
 	if s.enableMetafeeds {
 		// a user might want to be able to read/replicate metafeeds without using bendybutt themselves
@@ -495,10 +712,14 @@ func New(fopts ...Option) (*Sbot, error) {
 				return nil, fmt.Errorf("failed to initialize index feed manager: %w", err)
 			}
 
-			s.MetaFeeds, err = newMetaFeedService(s.ReceiveLog, s.IndexFeeds, s.Users, keysStore, s.KeyPair, s.signHMACsecret)
+			mfs, err := newMetaFeedService(s.ReceiveLog, s.IndexFeeds, s.Users, keysStore, s.KeyPair, s.signHMACsecret)
 			if err != nil {
 				return nil, fmt.Errorf("failed to initialize metafeed service: %w", err)
 			}
+			if len(s.publishHooks) > 0 {
+				mfs.published = s.publishedCallback
+			}
+			s.MetaFeeds = mfs
 		}
 
 		// setup indexing
@@ -545,13 +766,20 @@ func New(fopts ...Option) (*Sbot, error) {
 
 	// from here on just network related stuff
 	if s.disableNetwork {
+		s.startBlobGC()
+		s.startRetention()
+		s.startClockSkewCheck()
+		s.startPublishHooks()
+		s.startPublishRateGauge()
+		s.startUnixSock()
 		return s, nil
 	}
 
 	var inviteService *legacyinvites.Service
+	var roomServer *room.Server
 
 	// muxrpc handler creation and authoratization decider
-	mkHandler := func(conn net.Conn) (muxrpc.Handler, error) {
+	mkHandler := func(conn net.Conn) (h muxrpc.Handler, err error) {
 		// bypassing badger-close bug to go through with an accept (or not) before closing the bot
 		s.closedMu.Lock()
 		defer s.closedMu.Unlock()
@@ -561,20 +789,46 @@ func New(fopts ...Option) (*Sbot, error) {
 			return nil, fmt.Errorf("sbot: expected an address containing an shs-bs addr: %w", err)
 		}
 
+		// every return below sets the reason for the audit log
+		var reason string
+		defer func() {
+			s.auditAuthorization(remote, reason, err)
+		}()
+
 		// TODO: we still can't see the feed format type from this
 
 		if s.KeyPair.ID().PubKey().Equal(remote.PubKey()) {
+			reason = auditReasonSelf
 			return s.master.MakeHandler(conn)
 		}
 
+		policy := s.connPolicy(remote)
+		if policy == ConnPolicyNever {
+			reason = auditReasonPolicyNever
+			return nil, fmt.Errorf("sbot: peer %s is refused by its connection policy", remote.ShortSigil())
+		}
+
 		if inviteService != nil {
 			err := inviteService.Authorize(remote)
 			if err == nil {
+				reason = auditReasonInvite
 				return inviteService.GuestHandler(), nil
 			}
 		}
 
-		if s.promisc {
+		for _, extra := range s.extraAuth {
+			if err := extra.Authorize(remote); err != nil {
+				reason = auditReasonAuthorizer
+				return nil, err
+			}
+		}
+
+		if s.isPromisc() {
+			reason = auditReasonPromiscuous
+			return s.public.MakeHandler(conn)
+		}
+		if policy == ConnPolicyAlways {
+			reason = auditReasonPolicyAlways
 			return s.public.MakeHandler(conn)
 		}
 
@@ -589,6 +843,7 @@ func New(fopts ...Option) (*Sbot, error) {
 				s.latency.With("part", "graph_auth").Observe(time.Since(start).Seconds())
 			}()
 		}
+		reason = auditReasonInReach
 		err = auth.Authorize(remote)
 		if err == nil {
 			return s.public.MakeHandler(conn)
@@ -612,12 +867,44 @@ func New(fopts ...Option) (*Sbot, error) {
 			return s.public.MakeHandler(conn)
 		}
 
+		// blocked peers don't get the room either
+		if s.blocks(remote) {
+			reason = auditReasonBlocked
+			return nil, fmt.Errorf("sbot: peer %s is blocked", remote.ShortSigil())
+		}
+
 		// TOFU restore/resync
-		if lst, err := s.Users.List(); err == nil && len(lst) == 0 {
+		// not with a custom policy, that would let anyone in while the repo is empty
+		customAuth := s.authorizer != nil || len(s.extraAuth) > 0
+		tofu := false
+		if lst, err := s.Users.List(); !customAuth && err == nil && len(lst) == 0 {
+			tofu = true
+		}
+
+		// disabling TOFU also keeps strangers out of the room while the repo is empty
+		if tofu && s.disableTOFU {
+			level.Warn(s.info).Log("event", "refusing connection - no stored feeds and trust-on-first-use is disabled", "peer", remote.ShortSigil())
+			reason = auditReasonTOFUDisabled
+			return nil, fmt.Errorf("sbot: peer %s refused, trust-on-first-use is disabled", remote.ShortSigil())
+		}
+
+		// everyone else can still use the room, but nothing else.
+		// this comes before TOFU, a room would let every guest in while the repo is empty
+		if s.enableRoomServer {
+			reason = auditReasonRoomGuest
+			if roomServer == nil {
+				return nil, fmt.Errorf("sbot: peer %s refused, the room is not started yet", remote.ShortSigil())
+			}
+			return roomServer.Handler(), nil
+		}
+
+		if tofu {
 			level.Warn(s.info).Log("event", "no stored feeds - attempting re-sync with trust-on-first-use")
 			s.Replicate(s.KeyPair.ID())
+			reason = auditReasonTOFU
 			return s.public.MakeHandler(conn)
 		}
+		reason = auditReasonOutOfReach
 		return nil, err
 	}
 
@@ -639,9 +926,13 @@ func New(fopts ...Option) (*Sbot, error) {
 		s.KeyPair.ID(),
 		s.Groups,
 		s.PublishLog,
-		private.NewUnboxerLog(s.ReceiveLog, userPrivs, s.KeyPair)))
+		private.NewUnboxerLog(s.ReceiveLog, userPrivs, s.KeyPair),
+		userPrivs,
+		s.receiveLogSeq))
 
 	// whoami
+	s.master.Register(whoami.NewDetails(log.With(s.info, "unit", "whoami"), s.whoamiDetails))
+	s.master.Register(server.New(log.With(s.info, "unit", "server"), s.VersionInfo))
 	whoami := whoami.New(log.With(s.info, "unit", "whoami"), s.KeyPair.ID())
 	s.public.Register(whoami)
 	s.master.Register(whoami)
@@ -686,7 +977,7 @@ func New(fopts ...Option) (*Sbot, error) {
 		histOpts = append(histOpts, gossip.NumberOfConcurrentReplications(s.numberOfConcurrentReplications))
 	}
 
-	s.verifyRouter, err = message.NewVerificationRouter(s.ReceiveLog, s.Users, s.signHMACsecret)
+	s.verifyRouter, err = message.NewVerificationRouter(s.ReceiveLog, s.Users, s.signHMACsecret, s.feedFormats)
 	if err != nil {
 		return nil, err
 	}
@@ -695,6 +986,8 @@ func New(fopts ...Option) (*Sbot, error) {
 		histOpts = append(histOpts, gossip.WithLive(!s.disableLegacyLiveReplication))
 	}
 
+	histOpts = append(histOpts, gossip.Prioritizer(s.ebtState))
+
 	gossipPlug := gossip.NewFetcher(ctx,
 		log.With(s.info, "plugin", "gossip"),
 		storageRepo,
@@ -703,6 +996,7 @@ func New(fopts ...Option) (*Sbot, error) {
 		fm, s.Replicator.Lister(),
 		s.verifyRouter,
 		histOpts...)
+	s.gossipFetcher = gossipPlug.LegacyGossip
 
 	if s.disableEBT {
 		s.public.Register(gossipPlug)
@@ -715,8 +1009,17 @@ func New(fopts ...Option) (*Sbot, error) {
 			fm,
 			sm,
 			s.verifyRouter,
+			ebt.WithIdleTimeout(s.ebtIdleTimeout),
+			ebt.WithBatchWindow(s.ebtBatchWindow),
+			ebt.WithSessionWaits(s.ebtSlowWait, s.ebtMaxWait),
+			ebt.WithMaxSessions(s.ebtMaxSessions, s.ebtQueueWait),
+			ebt.WithPeerInterest(s.makePeerInterest()),
+			ebt.WithEventCounter(s.eventCounter),
+			ebt.WithSystemGauge(s.systemGauge),
 		)
 		s.public.Register(ebtPlug)
+		s.master.Register(ebt.NewSessionsPlug(s.info, ebtPlug.MUXRPCHandler))
+		s.ebtSessions = &ebtPlug.MUXRPCHandler.Sessions
 
 		rn := negPlugin{replicateNegotiator{
 			logger: log.With(s.info, "module", "replicate-negotiator"),
@@ -736,12 +1039,15 @@ func New(fopts ...Option) (*Sbot, error) {
 		fm,
 		histOpts...)
 	s.public.Register(hist)
+	s.gossipServer = hist.LegacyGossip
 
 	// get idx muxrpc handler
 	s.master.Register(get.New(s, s.ReceiveLog, s.Groups))
+	s.master.Register(get.NewMany(s, s.Groups))
 
 	// about information
-	s.master.Register(namesPlug)
+	s.names.UseGraph(s.GraphBuilder)
+	s.master.Register(s.names)
 
 	// (insecure) partial proof-of-concept for browser-core/demo
 	plug := partial.New(s.info,
@@ -749,13 +1055,24 @@ func New(fopts ...Option) (*Sbot, error) {
 		s.Users,
 		s.ByType,
 		s.Tangles,
-		s.ReceiveLog, s)
+		s.ReceiveLog, s.indexedSeq("combined"),
+		s, s.KeyPair.ID())
 	s.public.Register(plug)
 	s.master.Register(plug)
 
 	// group managment
 	s.master.Register(groups.New(s.info, s.Groups))
 
+	s.master.Register(mentions.New(s.info, s))
+	s.master.Register(feed.New(s.info, s))
+
+	if s.searchIdx != nil {
+		s.master.Register(search.New(s.info, s))
+	}
+
+	s.master.Register(verify.New(s.info, s))
+	s.master.Register(diskusage.New(s.info, s))
+
 	// raw log plugins
 
 	sc := selfChecker{s.KeyPair.ID()}
@@ -772,9 +1089,9 @@ func New(fopts ...Option) (*Sbot, error) {
 	s.master.Register(rawread.NewSortedStream(s.info, s.ReceiveLog, s.SeqResolver))
 	s.master.Register(hist) // createHistoryStream
 
-	s.master.Register(replicate.NewPlug(s.Users, s.KeyPair.ID(), s.Lister()))
+	s.master.Register(replicate.NewPlug(s.Users, s.KeyPair.ID(), s.Lister(), s, s, s))
 
-	s.master.Register(friends.New(s.info, s.KeyPair.ID(), s.GraphBuilder))
+	s.master.Register(friends.New(s.info, s.KeyPair.ID(), s.GraphBuilder, friends.WithReplicationHops(s.hops)))
 
 	mh := namedPlugin{
 		h:    manifestBlob,
@@ -792,17 +1109,26 @@ func New(fopts ...Option) (*Sbot, error) {
 		sc)
 	s.master.Register(tplug)
 
+	// send peer events for the tracked connections
+	connTracker := s.networkConnTracker
+	if connTracker == nil {
+		connTracker = network.NewLastWinsTracker()
+	}
+	connTracker = eventConnTracker{ConnTracker: connTracker, events: s.events}
+
 	// tcp+shs
 	opts := network.Options{
 		Logger:              s.info,
 		Dialer:              s.dialer,
-		ListenAddr:          s.listenAddr,
+		ListenAddr:          s.listenAddrs[0],
+		ExtraListenAddrs:    s.listenAddrs[1:],
 		AdvertsSend:         s.enableAdverts,
 		AdvertsConnectTo:    s.enableDiscovery,
 		KeyPair:             s.KeyPair,
 		AppKey:              s.appKey[:],
 		MakeHandler:         mkHandler,
-		ConnTracker:         s.networkConnTracker,
+		ConnTracker:         connTracker,
+		ConnLimits:          s.connLimits,
 		BefreCryptoWrappers: s.preSecureWrappers,
 		AfterSecureWrappers: s.postSecureWrappers,
 
@@ -820,6 +1146,13 @@ func New(fopts ...Option) (*Sbot, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create network node: %w", err)
 	}
+
+	// mkHandler can be called as soon as the node exists, it holds closedMu while it looks at the room
+	if s.enableRoomServer {
+		s.closedMu.Lock()
+		roomServer = room.NewServer(log.With(s.info, "unit", "room"), s.KeyPair.ID(), networkNode, networkNode.TunnelPlugin())
+		s.closedMu.Unlock()
+	}
 	blobsGetPathPrefix := "/blobs/get/"
 	httpBlogsGet := func(w http.ResponseWriter, req *http.Request) {
 		hlog := log.With(s.info, "http-handler", "blobs/get")
@@ -874,6 +1207,7 @@ func New(fopts ...Option) (*Sbot, error) {
 		s.ReceiveLog,
 		s.Replicator,
 		s.indexStore,
+		legacyinvites.WithExpiry(s.inviteExpiry),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("sbot: failed to open legacy invites plugin: %w", err)
@@ -887,9 +1221,30 @@ func New(fopts ...Option) (*Sbot, error) {
 	s.public.Register(networkNode.TunnelPlugin())
 	s.Network = networkNode
 
+	if roomServer != nil {
+		// replaces the tunnel plugin above, the room passes the calls for this bot as a client on to it
+		for _, p := range roomServer.Plugins() {
+			s.public.Register(p)
+		}
+	}
+
+	s.startBlobGC()
+	s.startRetention()
+	s.startClockSkewCheck()
+	s.startPublishHooks()
+	s.startPublishRateGauge()
+	s.startConnScheduler()
+	s.startUnixSock()
 	return s, nil
 }
 
+// startUnixSock starts accepting on the unix socket, if one was configured by WithUNIXSocket
+func (s *Sbot) startUnixSock() {
+	if s.unixSock != nil {
+		go s.unixSock.accept()
+	}
+}
+
 // Close closes the bot by stopping network connections and closing the internal databases
 func (s *Sbot) Close() error {
 	s.closedMu.Lock()
@@ -902,6 +1257,22 @@ func (s *Sbot) Close() error {
 	closeEvt := log.With(s.info, "event", "sbot closing")
 	s.closed = true
 
+	if s.blobGC != nil {
+		s.blobGC.Close()
+	}
+
+	if s.retention != nil {
+		s.retention.Close()
+	}
+
+	if s.connSchedule != nil {
+		s.connSchedule.Close()
+	}
+
+	if s.publishHookRunner != nil {
+		s.publishHookRunner.Close()
+	}
+
 	if s.Network != nil {
 		if err := s.Network.Close(); err != nil {
 			s.closeErr = fmt.Errorf("sbot: failed to close own network node: %w", err)
@@ -936,3 +1307,11 @@ func (sc selfChecker) Authorize(remote refs.FeedRef) error {
 	}
 	return fmt.Errorf("not authorized")
 }
+
+// unboxFailed is called by the combined index for our own boxed messages that it can't decrypt
+func (s *Sbot) unboxFailed(msg refs.Message, err error) {
+	level.Warn(s.info).Log("event", "failed to decrypt own message", "msg", msg.Key().ShortSigil(), "err", err)
+	if s.onUnboxErr != nil {
+		s.onUnboxErr(msg, err)
+	}
+}
@@ -21,6 +21,48 @@ import (
 	"github.com/ssbc/go-ssb/repo"
 )
 
+// NullMessage overwrites the stored message ref in the receive log with a tombstone.
+// The sequences of the receive log and the feed stay the same, reading the entry returns a margaret nulled error.
+// The latest message of a feed can't be nulled since it is needed to verify the next one against the hash chain.
+//
+// This only affects the local storage. Other peers that have the message keep it.
+func (s *Sbot) NullMessage(ref refs.MessageRef) error {
+	seq, err := s.receiveLogSeq(ref)
+	if err != nil {
+		return fmt.Errorf("NullMessage: failed to find message: %w", err)
+	}
+
+	v, err := s.ReceiveLog.Get(seq)
+	if err != nil {
+		return fmt.Errorf("NullMessage: failed to load message: %w", err)
+	}
+	msg, ok := v.(refs.Message)
+	if !ok {
+		return fmt.Errorf("NullMessage: wrong message type in storage: %T", v)
+	}
+
+	userLog, err := s.Users.Get(storedrefs.Feed(msg.Author()))
+	if err != nil {
+		return fmt.Errorf("NullMessage: failed to open log of author: %w", err)
+	}
+	// the sublog is 0-indexed
+	if msg.Seq() >= userLog.Seq()+1 {
+		return fmt.Errorf("NullMessage: %s is the latest message of %s", ref.ShortSigil(), msg.Author().ShortSigil())
+	}
+
+	err = s.ReceiveLog.Null(seq)
+	if err != nil {
+		return fmt.Errorf("NullMessage: failed to null entry %d: %w", seq, err)
+	}
+	return nil
+}
+
+// DropFeed purges all the messages of feed from the local storage and its indexes, see NullFeed.
+// Like NullMessage it only affects this node, the feed might be fetched again if it is still replicated.
+func (s *Sbot) DropFeed(feed refs.FeedRef) error {
+	return s.NullFeed(feed)
+}
+
 // NullFeed overwrites all the entries from ref in repo with zeros
 func (s *Sbot) NullFeed(ref refs.FeedRef) error {
 	ctx := context.Background()
@@ -7,6 +7,7 @@ package sbot
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -348,3 +349,51 @@ func TestNullFetched(t *testing.T) {
 
 	r.NoError(botgroup.Wait())
 }
+
+func TestNullMessage(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	var msgs []refs.Message
+	for i := 0; i < 3; i++ {
+		msg, err := bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": i})
+		r.NoError(err)
+		msgs = append(msgs, msg)
+	}
+
+	// the latest is needed to verify the next message
+	err = bot.NullMessage(msgs[2].Key())
+	r.Error(err)
+
+	r.NoError(bot.NullMessage(msgs[1].Key()))
+
+	_, err = bot.Get(msgs[1].Key())
+	r.Error(err)
+	r.True(margaret.IsErrNulled(errors.Unwrap(err)), "not nulled: %v", err)
+
+	for _, i := range []int{0, 2} {
+		got, err := bot.Get(msgs[i].Key())
+		r.NoError(err)
+		r.True(got.Key().Equal(msgs[i].Key()))
+	}
+
+	// the sequence continues
+	r.EqualValues(2, bot.ReceiveLog.Seq())
+	msg, err := bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": 3})
+	r.NoError(err)
+	r.EqualValues(4, msg.Seq())
+	r.True(msg.Previous().Equal(msgs[2].Key()))
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -8,15 +8,19 @@ package sbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-kit/kit/metrics"
 	"github.com/ssbc/go-muxrpc/v2"
 	"github.com/ssbc/go-netwrap"
+	"github.com/ssbc/margaret"
 	kitlog "go.mindeco.de/log"
 	"go.mindeco.de/log/level"
 	"go.mindeco.de/logging"
@@ -24,7 +28,12 @@ import (
 	"github.com/ssbc/go-ssb"
 	"github.com/ssbc/go-ssb/internal/ctxutils"
 	"github.com/ssbc/go-ssb/internal/netwraputil"
+	"github.com/ssbc/go-ssb/message"
+	"github.com/ssbc/go-ssb/message/multimsg"
+	"github.com/ssbc/go-ssb/multilogs"
+	"github.com/ssbc/go-ssb/network"
 	"github.com/ssbc/go-ssb/repo"
+	"github.com/ssbc/go-ssb/repo/archive"
 )
 
 // MuxrpcEndpointWrapper can be used to wrap ever call a endpoint makes
@@ -41,6 +50,29 @@ func WithBlobStore(bs ssb.BlobStore) Option {
 	}
 }
 
+// LogStore is a storage backend for the receive log.
+// The entries are encoded with multimsg.MargaretCodec.
+type LogStore interface {
+	margaret.Log
+	margaret.Alterer
+	io.Closer
+}
+
+// WithLogStore can be used to use a different storage backend for the receive log instead of the offset log in the repo.
+// The sqlitelog package has one that keeps the whole log in a single sqlite file:
+//
+//	store, err := sqlitelog.Open(filepath.Join(repoPath, "log.sqlite"), multimsg.MargaretCodec{})
+//	...
+//	sbot.New(sbot.WithRepoPath(repoPath), sbot.WithLogStore(store))
+//
+// The sbot closes the store when it shuts down. Switching the backend of an existing repo needs a re-sync, the messages are not copied.
+func WithLogStore(store LogStore) Option {
+	return func(s *Sbot) error {
+		s.ReceiveLog = multimsg.NewWrappedLog(store)
+		return nil
+	}
+}
+
 // DisableLiveIndexMode makes the update processing halt once it reaches the end of the rootLog
 // makes it easier to rebuild indicies.
 func DisableLiveIndexMode() Option {
@@ -74,6 +106,78 @@ func DisableEBT(yes bool) Option {
 	}
 }
 
+// WithEBTIdleTimeout closes EBT sessions where the remote didn't send anything for the passed duration.
+// The peer is then replicated using legacy gossip instead. Zero, the default, disables the timeout.
+func WithEBTIdleTimeout(d time.Duration) Option {
+	return func(s *Sbot) error {
+		s.ebtIdleTimeout = d
+		return nil
+	}
+}
+
+// WithEBTBatchWindow sets how long changes of our frontier are collected before they are sent to EBT peers as one update.
+// This cuts down the number of small writes while feeds are synced in bursts. Zero sends every change right away.
+// The default is ebt.DefaultBatchWindow.
+func WithEBTBatchWindow(d time.Duration) Option {
+	return func(s *Sbot) error {
+		if d < 0 {
+			return fmt.Errorf("ebt batch window can't be negative: %s", d)
+		}
+		s.ebtBatchWindow = d
+		return nil
+	}
+}
+
+// WithEBTSessionWaits changes how long a server waits for a client to start an EBT session before falling back to legacy gossip.
+// Waits that take longer than slow are logged, max caps the wait of one minute. Zero disables either.
+// The default logs after ebt.DefaultSlowSessionWait and doesn't cap the wait.
+func WithEBTSessionWaits(slow, max time.Duration) Option {
+	return func(s *Sbot) error {
+		if slow < 0 || max < 0 {
+			return fmt.Errorf("ebt session waits can't be negative: %s, %s", slow, max)
+		}
+		s.ebtSlowWait = slow
+		s.ebtMaxWait = max
+		return nil
+	}
+}
+
+// WithEBTMaxSessions limits the number of concurrent EBT sessions, to bound the memory and goroutines used on busy pubs.
+// New peers over the limit wait up to queueWait for a session to end and are replicated with legacy gossip otherwise.
+// Zero, the default, means no limit. A zero queueWait uses ebt.DefaultSessionQueueWait.
+func WithEBTMaxSessions(max int, queueWait time.Duration) Option {
+	return func(s *Sbot) error {
+		if max < 0 || queueWait < 0 {
+			return fmt.Errorf("ebt session limit can't be negative: %d, %s", max, queueWait)
+		}
+		s.ebtMaxSessions = max
+		s.ebtQueueWait = queueWait
+		return nil
+	}
+}
+
+// WithShardedEBTState stores the EBT state of each peer in subdirectories named after the first n bytes of its key.
+// Existing state files are moved on the next start. Busy pubs should use this to keep the state directory small.
+func WithShardedEBTState(n int) Option {
+	return func(s *Sbot) error {
+		if n < 0 {
+			return fmt.Errorf("sbot: invalid number of bytes for EBT state shards: %d", n)
+		}
+		s.ebtStateShards = n
+		return nil
+	}
+}
+
+// WithCompactEBTState stores the EBT state of each peer in a binary encoding instead of JSON, which is about 40% smaller.
+// Existing state files are still read and converted the next time they are saved, also when switching back.
+// Useful on embedded devices, where the state directory takes a good part of the storage.
+func WithCompactEBTState(yes bool) Option {
+	return func(s *Sbot) error {
+		s.ebtStateCompact = yes
+		return nil
+	}
+}
+
 // DisableLegacyLiveReplication controls wether createHistoryStreams are created with live:true flag.
 // This code is functional but might not scale to a lot of feeds. Therefore this flag can be used to force
 // the old non-live polling mode.
@@ -85,13 +189,14 @@ func DisableLegacyLiveReplication(yes bool) Option {
 }
 
 // WithListenAddr changes the muxrpc listener address. By default it listens to ':8008'.
+// Passing it multiple times listens on all of the addresses, the first one is the one that is advertised.
 func WithListenAddr(addr string) Option {
 	return func(s *Sbot) error {
-		var err error
-		s.listenAddr, err = net.ResolveTCPAddr("tcp", addr)
+		tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
 		if err != nil {
 			return fmt.Errorf("failed to parse tcp listen addr: %w", err)
 		}
+		s.listenAddrs = append(s.listenAddrs, tcpAddr)
 		return nil
 	}
 }
@@ -105,6 +210,40 @@ func WithDialer(dial netwrap.Dialer) Option {
 	}
 }
 
+// WithDialProxy routes the connections to remote peers through the SOCKS5 proxy at proxyURL, like socks5://127.0.0.1:9050 for tor.
+// With it, onion: multiserver addresses can be dialed as well. It replaces the dialer set by WithDialer.
+func WithDialProxy(proxyURL string) Option {
+	return func(s *Sbot) error {
+		dial, err := network.NewProxyDialer(proxyURL)
+		if err != nil {
+			return fmt.Errorf("failed to create dial proxy: %w", err)
+		}
+		s.dialer = dial
+		return nil
+	}
+}
+
+// WithEventBuffer sets how many events are buffered for each receiver of Events before they are dropped.
+// It defaults to DefaultEventBuffer.
+func WithEventBuffer(n int) Option {
+	return func(s *Sbot) error {
+		s.eventBuffer = n
+		return nil
+	}
+}
+
+// WithIndexProgress sets a function that is called while the indexes process their backlog, see IndexProgressFunc.
+// The same progress is also sent as EventIndexProgress to the receivers of Events.
+func WithIndexProgress(fn IndexProgressFunc) Option {
+	return func(s *Sbot) error {
+		if fn == nil {
+			return fmt.Errorf("sbot: nil index progress func")
+		}
+		s.indexProgress = fn
+		return nil
+	}
+}
+
 // WithNetworkConnTracker changes the connection tracker. See network.NewLastWinsTracker and network.NewAcceptAllTracker.
 func WithNetworkConnTracker(ct ssb.ConnTracker) Option {
 	return func(s *Sbot) error {
@@ -115,13 +254,16 @@ func WithNetworkConnTracker(ct ssb.ConnTracker) Option {
 
 // WithUNIXSocket enables listening for muxrpc connections on a unix socket files ($repo/socket).
 // This socket is not encrypted or authenticated since access to it is mediated by filesystem ownership.
+// See WithUNIXSocketPath to put it somewhere else.
 func WithUNIXSocket() Option {
 	return func(s *Sbot) error {
-		// this races because sbot might not be done with init yet
+		// accepting only starts once New() is done, otherwise clients might get a handler without all plugins
 		// TODO: refactor network peer code and make unixsock implement that (those will be inited late anyway)
 
-		r := repo.New(s.repoPath)
-		sockPath := r.GetPath("socket")
+		sockPath := s.unixSockPath
+		if sockPath == "" {
+			sockPath = repo.New(s.repoPath).GetPath("socket")
+		}
 
 		// local clients (not using network package because we don't want conn limiting or advertising)
 		c, err := net.Dial("unix", sockPath)
@@ -138,7 +280,7 @@ func WithUNIXSocket() Option {
 		}
 		s.closers.AddCloser(uxLis)
 
-		var unixsrv = unixSockServer{
+		s.unixSock = &unixSockServer{
 			ctx:    s.rootCtx,
 			logger: s.info,
 
@@ -150,12 +292,21 @@ func WithUNIXSocket() Option {
 
 			handler: s.master,
 		}
-		go unixsrv.accept()
 
 		return nil
 	}
 }
 
+// WithUNIXSocketPath sets the file of the unix socket, instead of $repo/socket.
+// This is useful if the repo is on a filesystem that doesn't support unix sockets, like some network filesystems.
+// It needs to come before WithUNIXSocket.
+func WithUNIXSocketPath(path string) Option {
+	return func(s *Sbot) error {
+		s.unixSockPath = path
+		return nil
+	}
+}
+
 type unixSockServer struct {
 	ctx    context.Context
 	logger kitlog.Logger
@@ -238,6 +389,9 @@ func WithAppKey(k []byte) Option {
 		if n := len(k); n != 32 {
 			return fmt.Errorf("appKey: need 32 bytes got %d", n)
 		}
+		if s.networkName != "" {
+			return fmt.Errorf("appKey: conflicts with network %q", s.networkName)
+		}
 		s.appKey = k
 		return nil
 	}
@@ -321,7 +475,7 @@ func WithPostSecureConnWrapper(cw netwrap.ConnWrapper) Option {
 	}
 }
 
-// WithEventMetrics sets up latency and counter metrics
+// WithEventMetrics sets up latency and counter metrics, see WithMetricsRegistry to define them on a prometheus registry
 func WithEventMetrics(ctr metrics.Counter, lvls metrics.Gauge, lat metrics.Histogram) Option {
 	return func(s *Sbot) error {
 		s.eventCounter = ctr
@@ -331,6 +485,16 @@ func WithEventMetrics(ctr metrics.Counter, lvls metrics.Gauge, lat metrics.Histo
 	}
 }
 
+// WithUnboxErrorHandler sets a function that is called when a boxed message we published ourselves can't be decrypted.
+// This is a sign that our keys changed and we can't read our own private messages anymore.
+// These errors are also logged and counted as unbox-own-failed on the event counter.
+func WithUnboxErrorHandler(fn multilogs.UnboxErrorFunc) Option {
+	return func(s *Sbot) error {
+		s.onUnboxErr = fn
+		return nil
+	}
+}
+
 // WithEndpointWrapper sets a MuxrpcEndpointWrapper for new connections.
 func WithEndpointWrapper(mw MuxrpcEndpointWrapper) Option {
 	return func(s *Sbot) error {
@@ -362,6 +526,9 @@ func WithHMACSigning(key []byte) Option {
 		if n := len(key); n != 32 {
 			return fmt.Errorf("WithHMACSigning: wrong key length (%d)", n)
 		}
+		if s.networkName != "" {
+			return fmt.Errorf("WithHMACSigning: conflicts with network %q", s.networkName)
+		}
 		var k [32]byte
 		copy(k[:], key)
 		s.signHMACsecret = &k
@@ -369,6 +536,128 @@ func WithHMACSigning(key []byte) Option {
 	}
 }
 
+// WithBlobGC periodically deletes blobs that are not referenced by messages of feeds within hops, see Sbot.PruneBlobs.
+// Zero (the default) disables it, along with PruneBlobs.
+func WithBlobGC(interval time.Duration) Option {
+	return func(s *Sbot) error {
+		if interval < 0 {
+			return fmt.Errorf("WithBlobGC: negative interval")
+		}
+		s.blobGCInterval = interval
+		return nil
+	}
+}
+
+// WithBlobGCGracePeriod sets how long new blobs are kept by WithBlobGC without a message that references them,
+// the default is DefaultBlobGCGracePeriod.
+func WithBlobGCGracePeriod(d time.Duration) Option {
+	return func(s *Sbot) error {
+		if d <= 0 {
+			return fmt.Errorf("WithBlobGCGracePeriod: the grace period needs to be positive")
+		}
+		s.blobGCGrace = d
+		return nil
+	}
+}
+
+// WithFeedRetention keeps only the newest keepPerFeed messages of each feed in the receive log and moves older ones into the archive,
+// which has to be set with WithArchiveStore.
+// This runs once an hour, see Sbot.ArchiveOldMessages. Archived messages can still be read, just slower, and are served to peers like the others.
+// Our own feed is not archived. The default offset log keeps the space of archived messages until it is compacted,
+// so the receive log is compacted after each run that archived something. The space is given back the next time the bot starts, see Sbot.Compact.
+func WithFeedRetention(keepPerFeed int) Option {
+	return func(s *Sbot) error {
+		if keepPerFeed < 1 {
+			return fmt.Errorf("WithFeedRetention: need to keep at least one message per feed")
+		}
+		s.retentionKeep = keepPerFeed
+		return nil
+	}
+}
+
+// WithArchiveStore sets where WithFeedRetention moves old messages to. The receive log reads them from there again.
+// The sqlitearchive package has one that keeps them compressed in a single sqlite file:
+//
+//	store, err := sqlitearchive.Open(filepath.Join(repoPath, "archive", "archive.sqlite"))
+//	...
+//	sbot.New(sbot.WithRepoPath(repoPath), sbot.WithArchiveStore(store), sbot.WithFeedRetention(1000))
+//
+// Keep passing the store after turning retention off again, otherwise the archived messages read as nulled.
+// The sbot closes the store when it shuts down.
+func WithArchiveStore(store archive.Store) Option {
+	return func(s *Sbot) error {
+		if store == nil {
+			return fmt.Errorf("WithArchiveStore: store can't be nil")
+		}
+		s.archive = store
+		return nil
+	}
+}
+
+// WithFeedFormat registers an additional feed format, which is used to verify incoming messages and to publish
+// if the keypair of the bot uses its feed algorithm. It fails if the algorithm is already known.
+func WithFeedFormat(f message.FeedFormat) Option {
+	return func(s *Sbot) error {
+		if s.feedFormats == nil {
+			var err error
+			s.feedFormats, err = message.NewFeedFormats()
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.feedFormats.Register(f); err != nil {
+			return fmt.Errorf("WithFeedFormat: %w", err)
+		}
+		return nil
+	}
+}
+
+// WithConnLimits restricts inbound connections per remote IP and per remote public key.
+// Each limit caps the number of concurrent connections as well as the attempts within window, zero disables it.
+// Excess connections from an IP are dropped before the secret-handshake.
+func WithConnLimits(perIP, perKey int, window time.Duration) Option {
+	return func(s *Sbot) error {
+		if perIP < 0 || perKey < 0 || window < 0 {
+			return fmt.Errorf("WithConnLimits: negative limit")
+		}
+		s.connLimits = &network.ConnLimits{
+			PerIP:  perIP,
+			PerKey: perKey,
+			Window: window,
+		}
+		return nil
+	}
+}
+
+// WithInviteExpiry makes invites that are created with invite.create expire after d.
+// Using an expired invite fails with legacyinvites.ErrInviteExpired.
+func WithInviteExpiry(d time.Duration) Option {
+	return func(s *Sbot) error {
+		if d < 0 {
+			return fmt.Errorf("WithInviteExpiry: negative duration")
+		}
+		s.inviteExpiry = d
+		return nil
+	}
+}
+
+// WithSearchIndex enables the full-text index over the text of public posts, which is used by Search and search.query.
+func WithSearchIndex() Option {
+	return func(s *Sbot) error {
+		s.enableSearch = true
+		return nil
+	}
+}
+
+// WithPublishValidator runs v on the JSON encoded content of each new message of the bot, before it is signed.
+// A non-nil error aborts the publish and is returned to the caller.
+func WithPublishValidator(v func(contentType string, raw json.RawMessage) error) Option {
+	return func(s *Sbot) error {
+		s.publishValidator = v
+		return nil
+	}
+}
+
 // WithWebsocketAddress changes the HTTP listener address, by default it's :8989.
 func WithWebsocketAddress(addr string) Option {
 	return func(s *Sbot) error {
@@ -416,8 +705,22 @@ func WithPromisc(yes bool) Option {
 	}
 }
 
+// WithRoomServer lets the bot act as a room: connected peers can list each other with room.attendants or tunnel.endpoints
+// and open tunneled connections to each other with tunnel.connect. Peers that are not allowed to connect otherwise
+// are accepted as room guests, they can only use the room and tunnel methods.
+// Blocked peers are refused, use WithAuthorizer or a ConnPolicyNever peer policy to keep other peers out of the room.
+// Since everyone is let in as a guest, a room doesn't re-sync its own feed with trust-on-first-use.
+// With WithDisableTOFU, a room with no stored feeds doesn't accept guests either.
+func WithRoomServer() Option {
+	return func(s *Sbot) error {
+		s.enableRoomServer = true
+		return nil
+	}
+}
+
 // WithPublicAuthorizer configures who is considered "public" when accepting connections.
 // By default, this is covered by the list of followed and blocked peers using the graph implementation.
+// The passed authorizer replaces that, see WithAuthorizer for checks on top of it.
 func WithPublicAuthorizer(auth ssb.Authorizer) Option {
 	return func(s *Sbot) error {
 		if s.authorizer != nil {
@@ -428,6 +731,45 @@ func WithPublicAuthorizer(auth ssb.Authorizer) Option {
 	}
 }
 
+// WithAuthorizer adds a check that every remote peer has to pass before it can connect,
+// in addition to the public authorizer (see WithPublicAuthorizer). It is also consulted in promiscuous mode.
+// This can be used for allowlist-only pubs or to ask an external policy service.
+// Own connections and invite redemption are not affected. Can be used multiple times, all checks need to pass.
+func WithAuthorizer(auth ssb.Authorizer) Option {
+	return func(s *Sbot) error {
+		if auth == nil {
+			return fmt.Errorf("sbot: authorizer can't be nil")
+		}
+		s.extraAuth = append(s.extraAuth, auth)
+		return nil
+	}
+}
+
+// WithDisableTOFU turns off trust on first use. Without it, a bot with no stored feeds lets every peer connect,
+// to restore its own feed from the network. With it, only the peers that are allowed otherwise can connect,
+// like ones with a ConnPolicyAlways peer policy or the ones accepted by WithPublicAuthorizer.
+// Curated deployments should use this, so that they don't accept strangers before the graph is populated.
+// This includes the guests of WithRoomServer.
+func WithDisableTOFU() Option {
+	return func(s *Sbot) error {
+		s.disableTOFU = true
+		return nil
+	}
+}
+
+// WithAuditLog writes every decision about an incoming connection to w, as one JSON object (see AuditEntry) per line.
+// Writes are serialized, w doesn't need to be safe for concurrent use. Finding the hop distance of a peer
+// builds the follow graph, which is why it is only done if the audit log is enabled.
+func WithAuditLog(w io.Writer) Option {
+	return func(s *Sbot) error {
+		if w == nil {
+			return fmt.Errorf("sbot: audit log writer can't be nil")
+		}
+		s.audit = newAuditLog(w)
+		return nil
+	}
+}
+
 // WithReplicator overwrites the default graph based decision maker, of which feeds to copy or block
 func WithReplicator(r ssb.Replicator) Option {
 	return func(s *Sbot) error {
@@ -464,3 +806,49 @@ func WithNumberOfConcurrentReplications(n uint) Option {
 		return nil
 	}
 }
+
+// WithConnScheduler makes the bot dial peers periodically, using cs to decide which ones.
+// The candidates are the peers passed to WithConnPeers and the ones discovered on the local network.
+// Without it, but with peers from WithConnPeers, the scheduler of NewConnScheduler is used,
+// which keeps as many connections as needed for the legacy gossip limits (see WithNumberOfConcurrentReplications).
+// The decisions are sent as events, see EventConnScheduled.
+func WithConnScheduler(cs ConnScheduler) Option {
+	return func(s *Sbot) error {
+		s.connScheduler = cs
+		return nil
+	}
+}
+
+// WithConnPeers adds peers for the connection scheduler to dial, as multiserver addresses with a net:, onion: or tunnel: part.
+func WithConnPeers(addrs ...string) Option {
+	return func(s *Sbot) error {
+		for _, a := range addrs {
+			addr, peer, err := network.ParseDialAddress(a)
+			if err != nil {
+				return fmt.Errorf("invalid connection peer %q: %w", a, err)
+			}
+			s.connPeers = append(s.connPeers, ConnCandidate{Peer: peer, Addr: addr})
+		}
+		return nil
+	}
+}
+
+// WithPeerPolicies pins the connection policy of some peers, regardless of the follow graph.
+// Peers with ConnPolicyNever can't connect and aren't dialed. Peers with ConnPolicyAlways can always connect
+// and are dialed by the connection scheduler, if they have an address. See SetPeerPolicies to change them while running.
+func WithPeerPolicies(policies ...PeerPolicy) Option {
+	return func(s *Sbot) error {
+		return s.SetPeerPolicies(policies)
+	}
+}
+
+// WithConnScheduleInterval sets how often the connection scheduler runs, the default is DefaultConnScheduleInterval.
+func WithConnScheduleInterval(d time.Duration) Option {
+	return func(s *Sbot) error {
+		if d <= 0 {
+			return fmt.Errorf("connection schedule interval has to be positive: %s", d)
+		}
+		s.connScheduleInterval = d
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/network"
+)
+
+// ConnPolicy pins how the bot treats connections with a peer, regardless of the follow graph
+type ConnPolicy string
+
+const (
+	// ConnPolicyDefault leaves the peer to the graph and the connection scheduler
+	ConnPolicyDefault ConnPolicy = "default"
+
+	// ConnPolicyAlways accepts connections from the peer and keeps dialing it, even if all connection slots are in use
+	ConnPolicyAlways ConnPolicy = "always"
+
+	// ConnPolicyNever refuses connections with the peer and never dials it
+	ConnPolicyNever ConnPolicy = "never"
+)
+
+// PeerPolicy is the connection policy for one peer.
+// Addr is an optional multiserver address, like net:example.org:8008~shs:<key>. The scheduler needs it to dial
+// peers with ConnPolicyAlways that aren't passed to WithConnPeers or discovered on the local network.
+type PeerPolicy struct {
+	Peer   refs.FeedRef
+	Policy ConnPolicy
+	Addr   string
+}
+
+type peerPolicy struct {
+	peer   refs.FeedRef
+	policy ConnPolicy
+	addr   net.Addr
+}
+
+// SetPeerPolicies replaces the connection policies of the bot, like WithPeerPolicies does when the bot is created.
+// Open connections are not affected.
+func (s *Sbot) SetPeerPolicies(policies []PeerPolicy) error {
+	parsed := make(map[string]peerPolicy, len(policies))
+	for _, p := range policies {
+		pp := peerPolicy{peer: p.Peer, policy: p.Policy}
+		switch p.Policy {
+		case ConnPolicyDefault, ConnPolicyAlways, ConnPolicyNever:
+		default:
+			return fmt.Errorf("sbot: invalid connection policy %q for %s", p.Policy, p.Peer.ShortSigil())
+		}
+
+		if p.Addr != "" {
+			addr, ref, err := network.ParseDialAddress(p.Addr)
+			if err != nil {
+				return fmt.Errorf("sbot: invalid address for %s: %w", p.Peer.ShortSigil(), err)
+			}
+			if !ref.PubKey().Equal(p.Peer.PubKey()) {
+				return fmt.Errorf("sbot: address of %s has the key of %s", p.Peer.ShortSigil(), ref.ShortSigil())
+			}
+			pp.addr = addr
+		}
+
+		// the same key can be used with different feed formats
+		parsed[string(p.Peer.PubKey())] = pp
+	}
+
+	s.settingsMu.Lock()
+	s.peerPolicies = parsed
+	s.settingsMu.Unlock()
+	return nil
+}
+
+// connPolicy returns the policy for remote, ConnPolicyDefault if there is none
+func (s *Sbot) connPolicy(remote refs.FeedRef) ConnPolicy {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	if pp, has := s.peerPolicies[string(remote.PubKey())]; has {
+		return pp.policy
+	}
+	return ConnPolicyDefault
+}
+
+// alwaysPeers returns the peers with ConnPolicyAlways that have an address
+func (s *Sbot) alwaysPeers() []ConnCandidate {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	var peers []ConnCandidate
+	for _, pp := range s.peerPolicies {
+		if pp.policy != ConnPolicyAlways || pp.addr == nil {
+			continue
+		}
+		peers = append(peers, ConnCandidate{Peer: pp.peer, Addr: pp.addr, Policy: ConnPolicyAlways})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Peer.String() < peers[j].Peer.String() })
+	return peers
+}
@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerPolicies(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	ctx := tn.ctx
+	alice := tn.newBot("alice")
+	bob := tn.newBot("bob")
+
+	// the pub replicates bob but not alice, the policies turn that around
+	pub := tn.newBot("pub", WithPeerPolicies(
+		PeerPolicy{Peer: alice.KeyPair.ID(), Policy: ConnPolicyAlways},
+		PeerPolicy{Peer: bob.KeyPair.ID(), Policy: ConnPolicyNever},
+	))
+	pub.Replicate(bob.KeyPair.ID())
+
+	// a stored feed, so that the pub doesn't trust everyone on first use
+	_, err := pub.PublishLog.Publish(refs.NewContactFollow(bob.KeyPair.ID()))
+	r.NoError(err)
+
+	connected := func(bot *Sbot) bool {
+		_, has := pub.Network.GetEndpointFor(bot.KeyPair.ID())
+		return has
+	}
+
+	r.NoError(alice.Network.Connect(ctx, pub.Network.GetListenAddr()))
+	r.Eventually(func() bool { return connected(alice) }, 5*time.Second, 50*time.Millisecond, "alice didn't get in")
+
+	bob.Network.Connect(ctx, pub.Network.GetListenAddr())
+	time.Sleep(time.Second / 2)
+	r.False(connected(bob), "bob got in")
+
+	// the policies can be changed while running
+	r.NoError(pub.SetPeerPolicies([]PeerPolicy{
+		{Peer: bob.KeyPair.ID(), Policy: ConnPolicyDefault},
+	}))
+	r.NoError(bob.Network.Connect(ctx, pub.Network.GetListenAddr()))
+	r.Eventually(func() bool { return connected(bob) }, 5*time.Second, 50*time.Millisecond, "bob didn't get in")
+
+	r.Error(pub.SetPeerPolicies([]PeerPolicy{{Peer: bob.KeyPair.ID(), Policy: "sometimes"}}))
+	r.Error(pub.SetPeerPolicies([]PeerPolicy{{Peer: bob.KeyPair.ID(), Policy: ConnPolicyAlways, Addr: "net:127.0.0.1:8008"}}))
+	r.Equal(ConnPolicyDefault, pub.connPolicy(bob.KeyPair.ID()), "failed updates keep the current policies")
+
+	tn.close()
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// PublishBatch publishes all the contents on the feed of the bot as one contiguous run of messages.
+// This is much quicker than calling Publish for each of them, since the indexes are only waited for once.
+// If one of the contents is rejected, none of them are published. See message.OpenPublishLog for the details.
+func (sbot *Sbot) PublishBatch(contents []interface{}) ([]refs.MessageRef, error) {
+	bp, ok := sbot.PublishLog.(ssb.BatchPublisher)
+	if !ok {
+		return nil, fmt.Errorf("sbot: publish log does not support batches (%T)", sbot.PublishLog)
+	}
+
+	written, err := bp.PublishBatch(contents)
+	if err != nil {
+		return nil, fmt.Errorf("sbot: failed to publish batch: %w", err)
+	}
+
+	// let the batch show up in the indexes before returning, like the next Publish would
+	sbot.WaitUntilIndexesAreSynced()
+	return written, nil
+}
+
+// DryRunPublish returns the message that Publish would store for content, signed against the current tip of the
+// feed of the bot, without appending it.
+//
+// The feed doesn't advance and the message is kept until it does: calling it twice returns the same message,
+// and publishing the same content next stores exactly these bytes, with the same key. The timestamp is the one of the dry run.
+func (sbot *Sbot) DryRunPublish(content interface{}) (refs.Message, error) {
+	dp, ok := sbot.PublishLog.(ssb.DryRunPublisher)
+	if !ok {
+		return nil, fmt.Errorf("sbot: publish log does not support dry runs (%T)", sbot.PublishLog)
+	}
+
+	msg, err := dp.DryRunPublish(content)
+	if err != nil {
+		return nil, fmt.Errorf("sbot: dry run publish failed: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/message/legacy"
+)
+
+func TestPublishBatch(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	repoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(repoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(repoPath),
+		WithListenAddr(":0"),
+	)
+	r.NoError(err)
+
+	_, err = bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": 0})
+	r.NoError(err)
+
+	var batch []interface{}
+	for i := 1; i <= 10; i++ {
+		batch = append(batch, map[string]interface{}{"type": "test", "i": i})
+	}
+	written, err := bot.PublishBatch(batch)
+	r.NoError(err)
+	r.Len(written, 10)
+
+	// the indexes are up to date once the batch returned
+	r.EqualValues(10, bot.PublishLog.Seq())
+	for i, ref := range written {
+		msg, err := bot.Get(ref)
+		r.NoError(err)
+		a.EqualValues(i+2, msg.Seq())
+	}
+
+	next, err := bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": 11})
+	r.NoError(err)
+	a.EqualValues(12, next.Seq())
+	a.True(next.Previous().Equal(written[9]))
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
+
+func TestDryRunPublish(t *testing.T) {
+	r, a := require.New(t), assert.New(t)
+
+	repoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(repoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(repoPath),
+		WithListenAddr(":0"),
+	)
+	r.NoError(err)
+
+	first, err := bot.PublishLog.Publish(map[string]interface{}{"type": "test", "i": 0})
+	r.NoError(err)
+
+	content := map[string]interface{}{"type": "test", "i": 1}
+	dry, err := bot.DryRunPublish(content)
+	r.NoError(err)
+	a.EqualValues(2, dry.Seq())
+	a.True(dry.Previous().Equal(first.Key()))
+	a.True(dry.Author().Equal(bot.KeyPair.ID()))
+
+	// the signed bytes match the key
+	ref, _, err := legacy.Verify(dry.ValueContentJSON(), nil)
+	r.NoError(err)
+	a.True(ref.Equal(dry.Key()))
+
+	// nothing was stored and the feed didn't advance
+	a.EqualValues(0, bot.PublishLog.Seq())
+	_, err = bot.Get(dry.Key())
+	a.Error(err)
+
+	// the timestamp is pinned, even after a while
+	time.Sleep(5 * time.Millisecond)
+	again, err := bot.DryRunPublish(content)
+	r.NoError(err)
+	a.True(again.Key().Equal(dry.Key()), "second dry run has a different key")
+	a.Equal(string(dry.ValueContentJSON()), string(again.ValueContentJSON()))
+
+	// other content is signed anew
+	other, err := bot.DryRunPublish(map[string]interface{}{"type": "test", "i": 2})
+	r.NoError(err)
+	a.False(other.Key().Equal(dry.Key()))
+	a.EqualValues(2, other.Seq())
+
+	again, err = bot.DryRunPublish(content)
+	r.NoError(err)
+	a.EqualValues(2, again.Seq())
+
+	time.Sleep(5 * time.Millisecond)
+	published, err := bot.PublishLog.Publish(content)
+	r.NoError(err)
+	a.True(published.Key().Equal(again.Key()), "published message has a different key")
+	a.Equal(string(again.ValueContentJSON()), string(published.ValueContentJSON()))
+
+	// the next dry run continues the feed
+	next, err := bot.DryRunPublish(content)
+	r.NoError(err)
+	a.EqualValues(3, next.Seq())
+	a.True(next.Previous().Equal(published.Key()))
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
+
+func BenchmarkPublish(b *testing.B) {
+	b.Run("single", benchPublish(false))
+	b.Run("batch", benchPublish(true))
+}
+
+func benchPublish(batched bool) func(b *testing.B) {
+	return func(b *testing.B) {
+		r := require.New(b)
+
+		repoPath := filepath.Join("testrun", b.Name())
+		os.RemoveAll(repoPath)
+
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(repoPath),
+			WithListenAddr(":0"),
+		)
+		r.NoError(err)
+
+		contents := make([]interface{}, b.N)
+		for i := range contents {
+			contents[i] = map[string]interface{}{"type": "test", "i": i}
+		}
+
+		b.ResetTimer()
+		if batched {
+			_, err = bot.PublishBatch(contents)
+			r.NoError(err)
+		} else {
+			for _, c := range contents {
+				_, err = bot.PublishLog.Publish(c)
+				r.NoError(err)
+			}
+			bot.WaitUntilIndexesAreSynced()
+		}
+		b.StopTimer()
+
+		bot.Shutdown()
+		r.NoError(bot.Close())
+	}
+}
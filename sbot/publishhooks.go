@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/log/level"
+)
+
+// publishHookQueueSize is how many published messages can wait for the publish hooks
+const publishHookQueueSize = 1024
+
+// WithPublishHook calls hook with every message the bot publishes, once it is stored.
+// This includes the messages of PublishAs and of the metafeeds. It can be given more than once.
+//
+// The hooks are called one after the other on a goroutine of their own, in the order the messages were published,
+// so that a slow hook doesn't hold up publishing. A hook that panics is logged and doesn't stop the others.
+// If the hooks fall behind by more than publishHookQueueSize messages, the newer ones are not passed to them,
+// which is logged and counted as the "publish-hook-dropped" event.
+// Messages that are still queued when the bot shuts down are not passed to the hooks, Close waits for a running hook.
+func WithPublishHook(hook func(refs.Message)) Option {
+	return func(s *Sbot) error {
+		if hook == nil {
+			return fmt.Errorf("WithPublishHook: hook is nil")
+		}
+		s.publishHooks = append(s.publishHooks, hook)
+		if s.publishHookQueue == nil {
+			s.publishHookQueue = make(chan int64, publishHookQueueSize)
+		}
+		return nil
+	}
+}
+
+// publishedCallback queues a stored message for the publish hooks.
+// It is called while the publish log is locked, so it can't wait for the hooks, which might publish themselves.
+func (s *Sbot) publishedCallback(rxSeq int64) {
+	select {
+	case s.publishHookQueue <- rxSeq:
+	default:
+		level.Warn(s.info).Log("event", "publish hooks are behind, dropped message", "rxSeq", rxSeq)
+		if s.eventCounter != nil {
+			s.eventCounter.With("event", "publish-hook-dropped").Add(1)
+		}
+	}
+}
+
+// publishHookJob runs the publish hooks, see WithPublishHook
+type publishHookJob struct {
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func (s *Sbot) startPublishHooks() {
+	if len(s.publishHooks) == 0 {
+		return
+	}
+
+	job := &publishHookJob{done: make(chan struct{})}
+	s.publishHookRunner = job
+
+	var ctx context.Context
+	ctx, job.stop = context.WithCancel(s.rootCtx)
+	go func() {
+		defer close(job.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case rxSeq := <-s.publishHookQueue:
+				if ctx.Err() != nil {
+					return
+				}
+				s.runPublishHooks(rxSeq)
+			}
+		}
+	}()
+}
+
+// Close stops running the hooks and waits for a running one to finish.
+// It is called by Sbot.Close before the logs are closed.
+func (job *publishHookJob) Close() error {
+	job.stop()
+	<-job.done
+	return nil
+}
+
+func (s *Sbot) runPublishHooks(rxSeq int64) {
+	v, err := s.ReceiveLog.Get(rxSeq)
+	if err == nil {
+		if verr, ok := v.(error); ok {
+			err = verr
+		}
+	}
+	if err != nil {
+		level.Warn(s.info).Log("event", "failed to get published message for hooks", "rxSeq", rxSeq, "err", err)
+		return
+	}
+
+	msg, ok := v.(refs.Message)
+	if !ok {
+		level.Warn(s.info).Log("event", "published message for hooks has the wrong type", "rxSeq", rxSeq, "type", fmt.Sprintf("%T", v))
+		return
+	}
+
+	for _, hook := range s.publishHooks {
+		s.callPublishHook(hook, msg)
+	}
+}
+
+func (s *Sbot) callPublishHook(hook func(refs.Message), msg refs.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			level.Error(s.info).Log("event", "publish hook panicked", "msg", msg.Key().ShortSigil(), "panic", r)
+			if s.eventCounter != nil {
+				s.eventCounter.With("event", "publish-hook-panic").Add(1)
+			}
+		}
+	}()
+	hook(msg)
+}
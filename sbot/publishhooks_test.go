@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/repo"
+)
+
+func TestPublishHook(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	_, err := repo.NewKeyPair(repo.New(tRepoPath), "other", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	got := make(chan refs.Message, 10)
+	release := make(chan struct{})
+
+	var bot *Sbot
+	bot, err = New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithPublishHook(func(refs.Message) {
+			panic("the other hooks still run")
+		}),
+		WithPublishHook(func(msg refs.Message) {
+			<-release // a slow hook doesn't hold up publishing
+			got <- msg
+		}),
+	)
+	r.NoError(err)
+
+	var published []refs.MessageRef
+	for i := 0; i < 3; i++ {
+		msg, err := bot.PublishLog.Publish(refs.NewPost(fmt.Sprintf("hooked %d", i)))
+		r.NoError(err)
+		published = append(published, msg.Key())
+	}
+	batch, err := bot.PublishBatch([]interface{}{refs.NewPost("batched 1"), refs.NewPost("batched 2")})
+	r.NoError(err)
+	published = append(published, batch...)
+
+	asMsg, err := bot.PublishAs("other", refs.NewPost("as another identity"))
+	r.NoError(err)
+	published = append(published, asMsg.Key())
+
+	close(release)
+	for i, want := range published {
+		select {
+		case msg := <-got:
+			r.True(msg.Key().Equal(want), "message %d out of order", i)
+			r.False(msg.Received().IsZero(), "not the stored message")
+		case <-time.After(5 * time.Second):
+			r.FailNow("hook not called", "message %d", i)
+		}
+	}
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
+
+func TestPublishHookClose(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished int32
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithPublishHook(func(refs.Message) {
+			close(started)
+			<-release
+			atomic.StoreInt32(&finished, 1)
+		}),
+	)
+	r.NoError(err)
+
+	_, err = bot.PublishLog.Publish(refs.NewPost("hooked"))
+	r.NoError(err)
+	<-started
+
+	// close waits for the running hook
+	bot.Shutdown()
+	closed := make(chan error, 1)
+	go func() { closed <- bot.Close() }()
+	close(release)
+	r.NoError(<-closed)
+	r.EqualValues(1, atomic.LoadInt32(&finished))
+}
@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-ssb"
+)
+
+// ErrPublishRateLimited is returned by publishing when the rate limit is exceeded, see WithPublishRateLimit
+var ErrPublishRateLimited = errors.New("sbot: publish rate limit exceeded")
+
+// WithPublishRateLimit caps how many messages can be published per second, to stop a buggy loop from flooding the feed.
+// It is a token bucket, burst messages can be published at once before the rate applies.
+// Batches of more than burst messages are let through when the bucket is full.
+// Publishing waits until it is allowed again, with WithPublishRateLimitError it returns ErrPublishRateLimited instead.
+// The limit is shared by all the identities of the bot.
+//
+// The current rate is reported as the "publish-rate" part of the system gauge, see WithEventMetrics.
+// It is updated with every publish and once a second.
+// Unlike WithPublishBackpressure this is not about the indexes, it is a cap on the growth of the feed.
+func WithPublishRateLimit(perSecond float64, burst int) Option {
+	return func(s *Sbot) error {
+		if perSecond <= 0 || math.IsInf(perSecond, 0) || math.IsNaN(perSecond) {
+			return fmt.Errorf("WithPublishRateLimit: invalid rate %v", perSecond)
+		}
+		if burst < 1 {
+			return fmt.Errorf("WithPublishRateLimit: burst needs to be at least one")
+		}
+		s.publishLimiter = newTokenBucket(perSecond, burst)
+		return nil
+	}
+}
+
+// WithPublishRateLimitError makes publishing fail with ErrPublishRateLimited instead of waiting, see WithPublishRateLimit
+func WithPublishRateLimitError() Option {
+	return func(s *Sbot) error {
+		s.publishRateLimitError = true
+		return nil
+	}
+}
+
+// publishGate is the gate of the publish logs, for WithPublishRateLimit and WithPublishBackpressure
+func (s *Sbot) publishGate(n int) error {
+	if s.publishLimiter != nil {
+		if err := s.publishRateLimit(n); err != nil {
+			return err
+		}
+	}
+	if s.publishBacklogMax > 0 {
+		return s.publishBackpressure()
+	}
+	return nil
+}
+
+// publishRateLimit takes n messages from the bucket and updates the "publish-rate" gauge.
+// A call that has to wait is counted once as a "publish-rate-limited" event.
+func (s *Sbot) publishRateLimit(n int) error {
+	limited := false
+	for {
+		wait := s.publishLimiter.take(n)
+		s.updatePublishRateGauge()
+		if wait == 0 {
+			return nil
+		}
+
+		if !limited && s.eventCounter != nil {
+			s.eventCounter.With("event", "publish-rate-limited").Add(1)
+		}
+		limited = true
+		if s.publishRateLimitError {
+			return fmt.Errorf("%w (next message in %s)", ErrPublishRateLimited, wait.Round(time.Millisecond))
+		}
+
+		select {
+		case <-s.rootCtx.Done():
+			return ssb.ErrShuttingDown
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *Sbot) updatePublishRateGauge() {
+	if s.systemGauge != nil {
+		s.systemGauge.With("part", "publish-rate").Set(s.publishLimiter.rate())
+	}
+}
+
+// publishRateGaugeInterval is how often the "publish-rate" gauge is updated while nothing is published
+const publishRateGaugeInterval = time.Second
+
+// startPublishRateGauge keeps updating the "publish-rate" gauge, so that it goes down when nothing is published
+func (s *Sbot) startPublishRateGauge() {
+	if s.publishLimiter == nil || s.systemGauge == nil {
+		return
+	}
+
+	go func() {
+		tick := time.NewTicker(publishRateGaugeInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-s.rootCtx.Done():
+				return
+			case <-tick.C:
+				s.updatePublishRateGauge()
+			}
+		}
+	}()
+}
+
+// rateWindow is the time constant of the moving average of the publish rate
+const rateWindow = time.Second
+
+// tokenBucket refills perSecond tokens every second, up to burst
+type tokenBucket struct {
+	mu sync.Mutex
+
+	perSecond float64
+	burst     float64
+
+	tokens float64
+	last   time.Time
+
+	// exponential moving average of the taken tokens per second, at avgTime
+	avg     float64
+	avgTime time.Time
+
+	now func() time.Time
+}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		now:       time.Now,
+	}
+}
+
+// take removes n tokens if there are enough, or all of them for batches bigger than the bucket.
+// Otherwise it doesn't take any and returns how long to wait until there are enough.
+func (tb *tokenBucket) take(n int) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.now()
+	tb.refill(now)
+
+	need := math.Min(float64(n), tb.burst)
+	if tb.tokens < need {
+		missing := need - tb.tokens
+		wait := time.Duration(missing / tb.perSecond * float64(time.Second))
+		if wait <= 0 {
+			// rounding, don't return 0 without taking them
+			wait = time.Nanosecond
+		}
+		return wait
+	}
+
+	// bigger batches leave the bucket in debt, which needs to be refilled before the next message
+	tb.tokens -= float64(n)
+	tb.avg = tb.decayedAvg(now) + float64(n)/rateWindow.Seconds()
+	tb.avgTime = now
+	return 0
+}
+
+func (tb *tokenBucket) refill(now time.Time) {
+	if !tb.last.IsZero() {
+		elapsed := now.Sub(tb.last).Seconds()
+		if elapsed > 0 {
+			tb.tokens = math.Min(tb.burst, tb.tokens+elapsed*tb.perSecond)
+		}
+	}
+	tb.last = now
+}
+
+// rate returns the messages per second that were published recently
+func (tb *tokenBucket) rate() float64 {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return tb.decayedAvg(tb.now())
+}
+
+func (tb *tokenBucket) decayedAvg(now time.Time) float64 {
+	if tb.avgTime.IsZero() {
+		return 0
+	}
+	elapsed := now.Sub(tb.avgTime).Seconds()
+	if elapsed <= 0 {
+		return tb.avg
+	}
+	return tb.avg * math.Exp(-elapsed/rateWindow.Seconds())
+}
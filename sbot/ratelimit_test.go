@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestTokenBucket(t *testing.T) {
+	r := require.New(t)
+
+	now := time.Unix(1000, 0)
+	tb := newTokenBucket(2, 3)
+	tb.now = func() time.Time { return now }
+
+	// the burst is available right away
+	for i := 0; i < 3; i++ {
+		r.Zero(tb.take(1), "take %d", i)
+	}
+	r.Equal(500*time.Millisecond, tb.take(1))
+
+	now = now.Add(500 * time.Millisecond)
+	r.Zero(tb.take(1))
+
+	// it doesn't fill up beyond the burst
+	now = now.Add(time.Hour)
+	r.Zero(tb.take(3))
+	r.Equal(500*time.Millisecond, tb.take(1))
+
+	// a bigger batch waits for a full bucket and leaves it in debt
+	now = now.Add(time.Second)
+	r.Equal(500*time.Millisecond, tb.take(10))
+	now = now.Add(500 * time.Millisecond)
+	r.Zero(tb.take(10))
+	r.Equal(4*time.Second, tb.take(1))
+
+	// the rate decays when nothing is published
+	r.Greater(tb.rate(), 1.0)
+	now = now.Add(time.Minute)
+	r.Less(tb.rate(), 0.001)
+}
+
+func TestPublishRateLimit(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	ctr := newEventCounts()
+	gauge := newPartGauge()
+	open := func(opts ...Option) *Sbot {
+		bot, err := New(append([]Option{
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+			WithEventMetrics(ctr, gauge, discard.NewHistogram()),
+		}, opts...)...)
+		r.NoError(err)
+		return bot
+	}
+
+	bot := open(WithPublishRateLimit(0.1, 2), WithPublishRateLimitError())
+	for i := 0; i < 2; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("burst"))
+		r.NoError(err, "publish %d", i)
+	}
+	r.Greater(gauge.get("publish-rate"), 0.0)
+
+	_, err := bot.PublishLog.Publish(refs.NewPost("one too many"))
+	r.True(errors.Is(err, ErrPublishRateLimited), "unexpected error: %v", err)
+	_, err = bot.PublishBatch([]interface{}{refs.NewPost("batched")})
+	r.True(errors.Is(err, ErrPublishRateLimited), "unexpected error: %v", err)
+	r.EqualValues(1, bot.ReceiveLog.Seq(), "nothing was published")
+	r.EqualValues(2, ctr.Get("publish-rate-limited"))
+
+	// the rate goes down without publishing
+	rate := gauge.get("publish-rate")
+	r.Eventually(func() bool {
+		return gauge.get("publish-rate") < rate/2
+	}, 10*time.Second, 50*time.Millisecond, "the gauge wasn't updated")
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// without the error option publishing waits for the bucket
+	bot = open(WithPublishRateLimit(20, 1))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("paced"))
+		r.NoError(err, "publish %d", i)
+	}
+	r.GreaterOrEqual(time.Since(start), 190*time.Millisecond, "publishing wasn't limited")
+	r.EqualValues(6, bot.ReceiveLog.Seq())
+	// a publish that waited is counted once, no matter how often it checked the bucket
+	limited := ctr.Get("publish-rate-limited") - 2
+	r.Greater(limited, 0.0)
+	r.LessOrEqual(limited, 4.0)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
+
+// partGauge keeps the values per part label
+type partGauge struct {
+	mu   *sync.Mutex
+	part string
+	vals map[string]float64
+}
+
+func newPartGauge() *partGauge {
+	return &partGauge{mu: new(sync.Mutex), vals: make(map[string]float64)}
+}
+
+func (g *partGauge) With(lvs ...string) metrics.Gauge {
+	for i := 0; i+1 < len(lvs); i += 2 {
+		if lvs[i] == "part" {
+			return &partGauge{mu: g.mu, part: lvs[i+1], vals: g.vals}
+		}
+	}
+	return g
+}
+
+func (g *partGauge) Set(v float64) {
+	g.mu.Lock()
+	g.vals[g.part] = v
+	g.mu.Unlock()
+}
+
+func (g *partGauge) Add(v float64) {
+	g.mu.Lock()
+	g.vals[g.part] += v
+	g.mu.Unlock()
+}
+
+func (g *partGauge) get(part string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.vals[part]
+}
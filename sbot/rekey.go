@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ssbc/go-metafeed"
+	"github.com/ssbc/go-metafeed/metamngmt"
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/message"
+	"github.com/ssbc/go-ssb/message/legacy"
+)
+
+// ErrNotRekeyed is returned by RekeyedTo if the feed didn't move to a new key
+var ErrNotRekeyed = errors.New("sbot: feed was not rekeyed")
+
+// Rekey moves the identity of the bot to the metafeed of newMeta.
+// It first publishes a metafeed/add/existing message for the current feed on the metafeed, which is signed by both keys,
+// and then a metafeed/rekey message on the current feed that points to the metafeed and ends the current feed.
+// The returned message is the latter.
+// Restarting the bot with the new key and migrating contacts is left to the caller.
+func (s *Sbot) Rekey(newMeta ssb.KeyPair) (refs.Message, error) {
+	old := s.KeyPair.ID()
+	if old.Algo() != refs.RefAlgoFeedSSB1 {
+		return nil, fmt.Errorf("sbot/rekey: can only move classic feeds, not %s", old.Algo())
+	}
+
+	meta := newMeta.ID()
+	if meta.Algo() != refs.RefAlgoFeedBendyButt {
+		return nil, fmt.Errorf("sbot/rekey: new key needs to be a metafeed, not %s", meta.Algo())
+	}
+
+	if _, err := s.RekeyedTo(old); err == nil {
+		return nil, fmt.Errorf("sbot/rekey: %s was already rekeyed", old.ShortSigil())
+	} else if !errors.Is(err, ErrNotRekeyed) {
+		return nil, err
+	}
+
+	pubopts := []message.PublishOption{message.SetHMACKey(s.signHMACsecret)}
+	if len(s.publishHooks) > 0 {
+		pubopts = append(pubopts, message.UsePublishedCallback(s.publishedCallback))
+	}
+	metaPublisher, err := message.OpenPublishLog(s.ReceiveLog, s.Users, newMeta, pubopts...)
+	if err != nil {
+		return nil, fmt.Errorf("sbot/rekey: failed to open metafeed publisher: %w", err)
+	}
+
+	// link the old feed from the metafeed
+	addContent := metamngmt.NewAddExistingMessage(meta, old, "rekey")
+	addMsg, err := metafeed.SubSignContent(s.KeyPair.Secret(), addContent)
+	if err != nil {
+		return nil, fmt.Errorf("sbot/rekey: failed to sign metafeed/add/existing: %w", err)
+	}
+
+	if _, err = metaPublisher.Publish(addMsg); err != nil {
+		return nil, fmt.Errorf("sbot/rekey: failed to publish on metafeed: %w", err)
+	}
+
+	// point the old feed to the metafeed
+	rekey, err := legacy.NewMetafeedRekey(meta, old).Sign(newMeta.Secret(), s.signHMACsecret)
+	if err != nil {
+		return nil, fmt.Errorf("sbot/rekey: failed to sign metafeed/rekey: %w", err)
+	}
+
+	msg, err := s.PublishLog.Publish(rekey)
+	if err != nil {
+		return nil, fmt.Errorf("sbot/rekey: failed to publish on old feed: %w", err)
+	}
+	return msg, nil
+}
+
+// RekeyedTo returns the metafeed the passed feed moved to.
+// It only looks at the latest stored message of the feed, which needs to be a valid metafeed/rekey message.
+// If it is not, ErrNotRekeyed is returned.
+func (s *Sbot) RekeyedTo(feed refs.FeedRef) (refs.FeedRef, error) {
+	userLog, err := s.Users.Get(storedrefs.Feed(feed))
+	if err != nil {
+		return refs.FeedRef{}, fmt.Errorf("sbot/rekey: failed to open sublog for %s: %w", feed.ShortSigil(), err)
+	}
+
+	latest := userLog.Seq()
+	if latest < 0 {
+		return refs.FeedRef{}, ErrNotRekeyed
+	}
+
+	v, err := mutil.Indirect(s.ReceiveLog, userLog).Get(latest)
+	if err != nil {
+		return refs.FeedRef{}, fmt.Errorf("sbot/rekey: failed to get latest message of %s: %w", feed.ShortSigil(), err)
+	}
+
+	msg, ok := v.(refs.Message)
+	if !ok {
+		return refs.FeedRef{}, fmt.Errorf("sbot/rekey: unexpected message type: %T", v)
+	}
+
+	rekey, ok := legacy.VerifyMetafeedRekey(msg.ContentBytes(), feed, s.signHMACsecret)
+	if !ok {
+		return refs.FeedRef{}, ErrNotRekeyed
+	}
+	return rekey.Metafeed, nil
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ssbc/go-metafeed"
+	"github.com/ssbc/go-metafeed/metamngmt"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestRekey(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	old := bot.KeyPair.ID()
+
+	_, err = bot.PublishLog.Publish(refs.NewPost("moving soon"))
+	r.NoError(err)
+
+	_, err = bot.RekeyedTo(old)
+	r.ErrorIs(err, ErrNotRekeyed)
+
+	newMeta, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedBendyButt)
+	r.NoError(err)
+
+	notMeta, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	_, err = bot.Rekey(notMeta)
+	r.Error(err, "should only move to metafeeds")
+
+	msg, err := bot.Rekey(newMeta)
+	r.NoError(err)
+	r.EqualValues(2, msg.Seq())
+
+	moved, err := bot.RekeyedTo(old)
+	r.NoError(err)
+	r.True(moved.Equal(newMeta.ID()))
+
+	_, err = bot.Rekey(newMeta)
+	r.Error(err, "rekeyed twice")
+
+	// the metafeed links back to the old feed
+	bot.WaitUntilIndexesAreSynced()
+	metaNote, err := bot.CurrentSequence(newMeta.ID())
+	r.NoError(err)
+	r.EqualValues(1, metaNote.Seq)
+
+	v, err := bot.ReceiveLog.Get(bot.ReceiveLog.Seq() - 1)
+	r.NoError(err)
+	metaMsg := v.(refs.Message)
+	r.True(metaMsg.Author().Equal(newMeta.ID()))
+
+	var added metamngmt.AddExisting
+	r.NoError(metafeed.VerifySubSignedContent(metaMsg.ContentBytes(), &added))
+	r.True(added.SubFeed.Equal(old))
+	r.True(added.MetaFeed.Equal(newMeta.ID()))
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -18,13 +18,15 @@ import (
 
 	"github.com/ssbc/go-ssb"
 	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/go-ssb/graph"
 	"github.com/ssbc/go-ssb/internal/statematrix"
 	"github.com/ssbc/go-ssb/internal/storedrefs"
 )
 
 var _ ssb.Replicator = (*Sbot)(nil)
 
-// Replicate mark a feed for replication and connection acceptance
+// Replicate mark a feed for replication and connection acceptance.
+// It is not stored, use ReplicateFeed to keep replicating the feed after a restart.
 func (sbot *Sbot) Replicate(r refs.FeedRef) {
 	slog, err := sbot.Users.Get(storedrefs.Feed(r))
 	if err != nil {
@@ -55,9 +57,146 @@ func (sbot *Sbot) DontReplicate(r refs.FeedRef) {
 	sbot.Replicator.DontReplicate(r)
 }
 
+// ReplicateFeed replicates feed even if it is not within the hop range, for instance to archive it without following it.
+// Unlike Replicate the feed is remembered across restarts. Ignored feeds can't be added.
+func (sbot *Sbot) ReplicateFeed(feed refs.FeedRef) error {
+	if sbot.ignored.Has(feed) {
+		return errors.New("replicate feed: the feed is ignored")
+	}
+
+	changed, err := sbot.replicated.set(feed, true)
+	if err != nil || !changed {
+		return err
+	}
+
+	if err := sbot.wantFeed(feed); err != nil {
+		return fmt.Errorf("replicate feed: %w", err)
+	}
+	return nil
+}
+
+// UnreplicateFeed reverts ReplicateFeed. The feed is still replicated if it is within the hop range.
+func (sbot *Sbot) UnreplicateFeed(feed refs.FeedRef) error {
+	changed, err := sbot.replicated.set(feed, false)
+	if err != nil || !changed {
+		return err
+	}
+
+	if feed.Equal(sbot.KeyPair.ID()) || sbot.ignored.Has(feed) || sbot.wantsFeed(feed) {
+		return nil
+	}
+
+	note, err := sbot.CurrentSequence(feed)
+	if err != nil {
+		return fmt.Errorf("unreplicate feed: %w", err)
+	}
+	err = sbot.ebtState.Fill(sbot.KeyPair.ID(), []statematrix.ObservedFeed{
+		{Feed: feed, Note: ssb.Note{Seq: note.Seq, Receive: false, Replicate: false}},
+	})
+	if err != nil {
+		return fmt.Errorf("unreplicate feed: failed to update the state matrix: %w", err)
+	}
+
+	sbot.Replicator.DontReplicate(feed)
+	return nil
+}
+
+// ReplicatedFeeds returns the feeds that were added with ReplicateFeed
+func (sbot *Sbot) ReplicatedFeeds() []refs.FeedRef {
+	return sbot.replicated.List()
+}
+
+// wantsFeed returns true if feed is within the hop range or was added with ReplicateFeed
+func (sbot *Sbot) wantsFeed(feed refs.FeedRef) bool {
+	if sbot.replicated.Has(feed) {
+		return true
+	}
+	return sbot.GraphBuilder.Hops(sbot.KeyPair.ID(), int(sbot.hops())).Has(feed)
+}
+
+// wantFeed adds feed to our frontier in the state matrix and to the replication list
+func (sbot *Sbot) wantFeed(feed refs.FeedRef) error {
+	note, err := sbot.CurrentSequence(feed)
+	if err != nil {
+		return err
+	}
+	err = sbot.ebtState.Fill(sbot.KeyPair.ID(), []statematrix.ObservedFeed{
+		{Feed: feed, Note: ssb.Note{Seq: note.Seq, Receive: true, Replicate: true}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the state matrix: %w", err)
+	}
+
+	sbot.Replicator.Replicate(feed)
+	return nil
+}
+
+// PrioritizeFeed moves feed to the front of the EBT and legacy gossip requests.
+// The boost decays over statematrix.PriorityDecay.
+func (sbot *Sbot) PrioritizeFeed(feed refs.FeedRef) {
+	sbot.ebtState.Prioritize(feed)
+}
+
+// blocks tells if the bot blocks remote in the follow graph, or on the block list of the replicator
+func (sbot *Sbot) blocks(remote refs.FeedRef) bool {
+	if sbot.Replicator != nil && sbot.Replicator.Lister().BlockList().Has(remote) {
+		return true
+	}
+	if sbot.GraphBuilder == nil {
+		return false
+	}
+	fg, err := sbot.GraphBuilder.Build()
+	return err == nil && fg.Blocks(sbot.KeyPair.ID(), remote)
+}
+
+// makePeerInterest returns a func that lists the feeds within our hop count of a peer, which are the ones it most likely replicates.
+// EBT only sends notes for these and the ones the peer asks for.
+// The lists are kept until the graph or the hop count changes, so that sessions and their refreshes don't walk the graph each time.
+func (sbot *Sbot) makePeerInterest() func(peer refs.FeedRef) ([]refs.FeedRef, error) {
+	var (
+		mu sync.Mutex
+
+		// the cached lists are valid for this version of the graph and hop count
+		cachedGraph *graph.Graph
+		cachedSeq   int64
+		cachedHops  uint
+		cached      = make(map[string][]refs.FeedRef)
+	)
+
+	return func(peer refs.FeedRef) ([]refs.FeedRef, error) {
+		g, err := sbot.GraphBuilder.Build()
+		if err != nil {
+			return nil, err
+		}
+		hops := sbot.hops()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// patched graphs keep their pointer but not their sequence, rebuilt ones get a new pointer
+		if g != cachedGraph || g.Seq() != cachedSeq || hops != cachedHops {
+			cachedGraph, cachedSeq, cachedHops = g, g.Seq(), hops
+			cached = make(map[string][]refs.FeedRef)
+		}
+
+		if feeds, has := cached[peer.String()]; has {
+			return feeds, nil
+		}
+
+		feeds, err := sbot.GraphBuilder.Hops(peer, int(hops)).List()
+		if err != nil {
+			return nil, err
+		}
+		cached[peer.String()] = feeds
+		return feeds, nil
+	}
+}
+
 type graphReplicator struct {
 	bot     *Sbot
 	current *lister
+
+	update func()
 }
 
 func (s *Sbot) newGraphReplicator() (*graphReplicator, error) {
@@ -66,19 +205,20 @@ func (s *Sbot) newGraphReplicator() (*graphReplicator, error) {
 	r.current = newLister()
 
 	replicateEvt := log.With(s.info, "event", "update-replicate")
-	update := r.makeUpdater(replicateEvt, s.KeyPair.ID(), int(s.hopCount))
+	r.update = r.makeUpdater(replicateEvt, s.KeyPair.ID())
 
 	// update for new messages but only once they didnt change in a while
 	// meaning, not while sync is busy with new incoming messages
-	go debounce(s.rootCtx, 3*time.Second, s.ReceiveLog.Changes(), update)
+	go debounce(s.rootCtx, 3*time.Second, s.ReceiveLog.Changes(), r.update)
 
 	return &r, nil
 }
 
 // makeUpdater returns a func that does the hop-walk and block checks, used together with debounce
-func (r *graphReplicator) makeUpdater(log log.Logger, self refs.FeedRef, hopCount int) func() {
+func (r *graphReplicator) makeUpdater(log log.Logger, self refs.FeedRef) func() {
 	return func() {
 		start := time.Now()
+		hopCount := int(r.bot.hops())
 		newWants := r.bot.GraphBuilder.Hops(self, hopCount)
 
 		refs, err := newWants.List()
@@ -87,6 +227,9 @@ func (r *graphReplicator) makeUpdater(log log.Logger, self refs.FeedRef, hopCoun
 			return
 		}
 		for _, ref := range refs {
+			if r.bot.ignored.Has(ref) || r.bot.clockSkew.isDeferred(ref) {
+				continue
+			}
 			r.current.feedWants.AddRef(ref)
 		}
 
@@ -6,6 +6,7 @@ package sbot
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/ssbc/go-muxrpc/v2"
@@ -13,6 +14,7 @@ import (
 	"go.mindeco.de/log/level"
 
 	"github.com/ssbc/go-ssb"
+	refs "github.com/ssbc/go-ssb-refs"
 	"github.com/ssbc/go-ssb/plugins/ebt"
 	"github.com/ssbc/go-ssb/plugins/gossip"
 )
@@ -28,37 +30,109 @@ type replicateNegotiator struct {
 func (rn replicateNegotiator) HandleConnect(ctx context.Context, e muxrpc.Endpoint) {
 	remoteAddr := e.Remote()
 
+	remote, err := ssb.GetFeedRefFromAddr(remoteAddr)
+	if err != nil {
+		panic(err)
+		return
+	}
+
 	// the client calls ebt.replicate to the server
 	if !muxrpc.IsServer(e) {
 		// do nothing if we are the server, unless the peer doesn't start ebt
-		started := rn.ebt.Sessions.WaitFor(ctx, remoteAddr, 1*time.Minute)
-		if !started {
-			rn.lg.StartLegacyFetching(ctx, e)
+		err := rn.ebt.Sessions.WaitForSession(ctx, remoteAddr, 1*time.Minute)
+		if errors.Is(err, ebt.ErrSessionLimit) {
+			rn.limited(ctx, e, remote, err)
+		} else if err != nil {
+			rn.fallback(ctx, e, remote)
 		}
 		return
 	}
 
-	remote, err := ssb.GetFeedRefFromAddr(remoteAddr)
-	if err != nil {
-		panic(err)
+	// don't try ebt again with peers that recently failed to use it
+	if rn.ebt.Sessions.UsesLegacy(remote) {
+		level.Debug(rn.logger).Log("event", "using legacy gossip", "r", remote.ShortSigil())
+		rn.lg.StartLegacyFetching(ctx, e)
 		return
 	}
 
-	level.Debug(rn.logger).Log("event", "triggering ebt.replicate", "r", remote.ShortSigil())
+	// don't call the peer if we have no room for another session
+	if !rn.ebt.Sessions.Acquire(ctx) {
+		rn.limited(ctx, e, remote, ebt.ErrSessionLimit)
+		return
+	}
+	err = rn.replicate(ctx, e, remote)
+	// legacy gossip doesn't need the slot
+	rn.ebt.Sessions.Release()
+
+	if errors.Is(err, ebt.ErrSessionLimit) {
+		rn.limited(ctx, e, remote, err)
+	} else if errors.Is(err, errNoEBT) {
+		rn.fallback(ctx, e, remote)
+	}
+}
 
-	var opt = map[string]interface{}{"version": 3}
+// errNoEBT is returned by replicate if none of the versions worked with the peer
+var errNoEBT = errors.New("ebt: no session with peer")
+
+// replicate calls ebt.replicate on the peer and runs the session
+func (rn replicateNegotiator) replicate(ctx context.Context, e muxrpc.Endpoint, remote refs.FeedRef) error {
+	// try the versions we support, newest first
+	for _, version := range ebt.SupportedVersions {
+		sf, err := ebt.NewSessionFormat(version, ebt.FormatClassic)
+		if err != nil {
+			level.Warn(rn.logger).Log("event", "invalid ebt format", "err", err)
+			continue
+		}
 
-	// initiate ebt channel
-	rx, tx, err := e.Duplex(ctx, muxrpc.TypeJSON, muxrpc.Method{"ebt", "replicate"}, opt)
-	if err != nil {
-		level.Debug(rn.logger).Log("event", "no ebt support", "err", err)
+		level.Debug(rn.logger).Log("event", "triggering ebt.replicate", "r", remote.ShortSigil(), "version", version)
 
-		// fallback to legacy
-		rn.lg.StartLegacyFetching(ctx, e)
+		// initiate ebt channel
+		rx, tx, err := e.Duplex(ctx, muxrpc.TypeJSON, muxrpc.Method{"ebt", "replicate"}, sf.Args())
+		if err != nil {
+			level.Debug(rn.logger).Log("event", "no ebt support", "err", err)
+			break
+		}
+
+		err = rn.ebt.LoopWithFormat(ctx, tx, rx, e.Remote(), sf)
+		if errors.Is(err, ebt.ErrVersionRejected) {
+			level.Debug(rn.logger).Log("event", "ebt version rejected", "version", version, "err", err)
+			continue
+		}
+		if errors.Is(err, ebt.ErrSessionStalled) {
+			break
+		}
+		if errors.Is(err, ebt.ErrSessionLimit) {
+			return err
+		}
+		if err != nil && !muxrpc.IsSinkClosed(err) {
+			level.Warn(rn.logger).Log("event", "ebt session ended", "err", err)
+		}
+		return nil
+	}
+	return errNoEBT
+}
+
+// fallback records that the peer doesn't do ebt with us and hands the connection over to legacy gossip
+func (rn replicateNegotiator) fallback(ctx context.Context, e muxrpc.Endpoint, remote refs.FeedRef) {
+	// the connection might just have been closed
+	if ctx.Err() != nil {
+		return
+	}
+
+	level.Info(rn.logger).Log("event", "falling back to legacy gossip", "r", remote.ShortSigil())
+	rn.ebt.Sessions.MarkLegacy(remote)
+	rn.lg.StartLegacyFetching(ctx, e)
+}
+
+// limited hands the connection over to legacy gossip because we or the peer had no room for another ebt session.
+// Unlike fallback, ebt is tried again on the next connection.
+func (rn replicateNegotiator) limited(ctx context.Context, e muxrpc.Endpoint, remote refs.FeedRef, err error) {
+	if ctx.Err() != nil {
 		return
 	}
 
-	rn.ebt.Loop(ctx, tx, rx, remoteAddr)
+	level.Debug(rn.logger).Log("event", "no free ebt session, using legacy gossip", "r", remote.ShortSigil(), "err", err)
+	rn.lg.StartLegacyFetching(ctx, e)
 }
 
 func (replicateNegotiator) Handled(m muxrpc.Method) bool { return false }
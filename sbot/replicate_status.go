@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+	"sort"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret/multilog"
+
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/plugins/replicate"
+)
+
+// ReplicationStatus returns the state of every feed in the replication list, sorted by feed.
+// The remote sequences are taken from the EBT state of the connected peers.
+func (s *Sbot) ReplicationStatus() ([]replicate.FeedStatus, error) {
+	feeds, err := s.Lister().ReplicationList().List()
+	if err != nil {
+		return nil, fmt.Errorf("sbot/replication status: failed to get the replication list: %w", err)
+	}
+
+	snap, err := s.ebtState.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("sbot/replication status: failed to get the ebt state: %w", err)
+	}
+
+	// the peers we have an ebt session with
+	var sessionPeers []string
+	if s.ebtSessions != nil {
+		for _, si := range s.ebtSessions.List() {
+			if !si.Waiting && si.Peer != nil {
+				sessionPeers = append(sessionPeers, si.Peer.String())
+			}
+		}
+	}
+
+	self := s.KeyPair.ID().String()
+	status := make([]replicate.FeedStatus, len(feeds))
+	for i, feed := range feeds {
+		st := replicate.FeedStatus{Feed: feed}
+		if err := s.fillLocalStatus(&st); err != nil {
+			return nil, err
+		}
+
+		key := feed.String()
+		for peer, nf := range snap {
+			if peer == self {
+				continue
+			}
+			note, has := nf[key]
+			if !has || note.Seq <= st.RemoteSeq {
+				continue
+			}
+			remote, err := refs.ParseFeedRef(peer)
+			if err != nil {
+				continue
+			}
+			st.RemoteSeq = note.Seq
+			st.RemotePeer = &remote
+		}
+
+		for _, peer := range sessionPeers {
+			if note, has := snap[peer][key]; has && note.Replicate {
+				st.Active = true
+				break
+			}
+		}
+
+		status[i] = st
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Feed.String() < status[j].Feed.String() })
+	return status, nil
+}
+
+// fillLocalStatus sets the local sequence and when the newest message of the feed was received
+func (s *Sbot) fillLocalStatus(st *replicate.FeedStatus) error {
+	addr := storedrefs.Feed(st.Feed)
+	has, err := multilog.Has(s.Users, addr)
+	if err != nil {
+		return fmt.Errorf("sbot/replication status: failed to check for %s: %w", st.Feed.ShortSigil(), err)
+	}
+	if !has {
+		return nil
+	}
+
+	userLog, err := s.Users.Get(addr)
+	if err != nil {
+		return fmt.Errorf("sbot/replication status: failed to open sublog for %s: %w", st.Feed.ShortSigil(), err)
+	}
+	seq := userLog.Seq()
+	if seq < 0 {
+		return nil
+	}
+	st.LocalSeq = seq + 1
+
+	// nulled messages don't have a receive time
+	v, err := mutil.Indirect(s.ReceiveLog, userLog).Get(seq)
+	if err != nil {
+		return nil
+	}
+	if msg, ok := v.(refs.Message); ok {
+		st.LastProgress = msg.Received()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"testing"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/plugins/replicate"
+)
+
+func TestReplicationStatus(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t, WithEBTBatchWindow(50*time.Millisecond))
+	ali := tn.newBot("ali")
+	bob := tn.newBot("bob")
+
+	for i := 0; i < 3; i++ {
+		_, err := bob.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+
+	ali.Replicate(bob.KeyPair.ID())
+	bob.Replicate(ali.KeyPair.ID())
+
+	statusOf := func(feed refs.FeedRef) replicate.FeedStatus {
+		list, err := ali.ReplicationStatus()
+		r.NoError(err)
+		for _, st := range list {
+			if st.Feed.Equal(feed) {
+				return st
+			}
+		}
+		r.FailNow("feed not in the status", feed.ShortSigil())
+		return replicate.FeedStatus{}
+	}
+
+	st := statusOf(bob.KeyPair.ID())
+	r.EqualValues(0, st.LocalSeq)
+	r.EqualValues(0, st.RemoteSeq)
+	r.False(st.Active)
+	r.True(st.LastProgress.IsZero())
+
+	r.NoError(ali.Network.Connect(tn.ctx, bob.Network.GetListenAddr()))
+	r.Eventually(func() bool {
+		st = statusOf(bob.KeyPair.ID())
+		return st.LocalSeq == 3 && st.Active
+	}, 10*time.Second, 50*time.Millisecond, "ali didn't get bob's feed")
+
+	r.EqualValues(3, st.RemoteSeq)
+	r.NotNil(st.RemotePeer)
+	r.True(st.RemotePeer.Equal(bob.KeyPair.ID()))
+	r.False(st.LastProgress.IsZero())
+
+	tn.close()
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestReplicateFeed(t *testing.T) {
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	open := func() *Sbot {
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+		)
+		r.NoError(err)
+		return bot
+	}
+	bot := open()
+
+	archived, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{1}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+	noisy, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{2}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	r.NoError(bot.IgnoreFeed(noisy))
+	r.Error(bot.ReplicateFeed(noisy), "ignored feeds can't be added")
+
+	r.NoError(bot.ReplicateFeed(archived))
+	r.NoError(bot.ReplicateFeed(archived), "adding twice is fine")
+	r.Equal([]refs.FeedRef{archived}, bot.ReplicatedFeeds())
+	r.True(bot.Lister().ReplicationList().Has(archived))
+
+	front, err := bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.True(front[archived.String()].Replicate, "the state matrix should have the feed")
+	r.True(front[archived.String()].Receive)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// the list survives a restart, without following the feed
+	bot = open()
+	r.Equal([]refs.FeedRef{archived}, bot.ReplicatedFeeds())
+	r.True(bot.Lister().ReplicationList().Has(archived))
+	front, err = bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.True(front[archived.String()].Receive)
+
+	r.NoError(bot.UnreplicateFeed(archived))
+	r.Empty(bot.ReplicatedFeeds())
+	r.False(bot.Lister().ReplicationList().Has(archived))
+	front, err = bot.ebtState.Inspect(bot.KeyPair.ID())
+	r.NoError(err)
+	r.False(front[archived.String()].Receive)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
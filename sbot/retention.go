@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mindeco.de/log/level"
+
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+	"github.com/ssbc/go-ssb/repo/archive"
+)
+
+// how often old messages are moved into the archive, see WithFeedRetention
+const retentionInterval = time.Hour
+
+type retentionJob struct {
+	running sync.Mutex // only one run at a time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// startRetention starts the periodic runs, if configured
+func (s *Sbot) startRetention() {
+	if s.retentionKeep < 1 {
+		return
+	}
+
+	rj := &retentionJob{done: make(chan struct{})}
+	s.retention = rj
+
+	var ctx context.Context
+	ctx, rj.stop = context.WithCancel(s.rootCtx)
+	go func() {
+		defer close(rj.done)
+
+		tick := time.NewTicker(retentionInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+
+			start := time.Now()
+			moved, err := s.archiveOldMessages(ctx)
+			if err != nil {
+				level.Warn(s.info).Log("event", "archiving old messages failed", "err", err)
+				continue
+			}
+			level.Info(s.info).Log("event", "archived old messages", "moved", moved, "took", time.Since(start))
+
+			// the offset log only gives the space back once it is compacted
+			if moved == 0 || s.receiveLogPath == "" {
+				continue
+			}
+			reclaimed, err := s.Compact()
+			if err != nil {
+				level.Warn(s.info).Log("event", "compacting after archiving failed", "err", err)
+				continue
+			}
+			level.Info(s.info).Log("event", "compacted receive log", "reclaimed-on-restart", reclaimed)
+		}
+	}()
+}
+
+// Close stops the periodic runs and waits for a running one to finish.
+// It is called by Sbot.Close before the logs are closed.
+func (rj *retentionJob) Close() error {
+	rj.stop()
+	<-rj.done
+	return nil
+}
+
+// ArchiveOldMessages moves all but the newest messages of each feed into the archive, see WithFeedRetention.
+// It returns the number of bytes that were moved out of the receive log.
+// The default offset log keeps their space until Compact was called and the bot was started again.
+func (s *Sbot) ArchiveOldMessages() (int64, error) {
+	if s.retention == nil {
+		return 0, fmt.Errorf("sbot: feed retention is not enabled")
+	}
+	return s.archiveOldMessages(s.rootCtx)
+}
+
+func (s *Sbot) archiveOldMessages(ctx context.Context) (int64, error) {
+	s.retention.running.Lock()
+	defer s.retention.running.Unlock()
+
+	rxLog, ok := s.ReceiveLog.(*archive.Log)
+	if !ok {
+		return 0, fmt.Errorf("sbot/retention: receive log is not archived: %T", s.ReceiveLog)
+	}
+
+	// only move messages that all the indexes have seen
+	s.WaitUntilIndexesAreSynced()
+
+	feeds, err := s.Users.List()
+	if err != nil {
+		return 0, fmt.Errorf("sbot/retention: failed to list feeds: %w", err)
+	}
+
+	self := storedrefs.Feed(s.KeyPair.ID())
+
+	var (
+		moved    int64
+		archived int
+	)
+	for _, addr := range feeds {
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+
+		if addr == self {
+			continue
+		}
+
+		userLog, err := s.Users.Get(addr)
+		if err != nil {
+			return moved, fmt.Errorf("sbot/retention: failed to open feed: %w", err)
+		}
+
+		upto := userLog.Seq() + 1 - int64(s.retentionKeep)
+
+		done, err := s.archive.Archived([]byte(addr))
+		if err != nil {
+			return moved, err
+		}
+
+		for i := done; i < upto; i++ {
+			v, err := userLog.Get(i)
+			if err != nil {
+				return moved, fmt.Errorf("sbot/retention: failed to get message %d of feed: %w", i, err)
+			}
+
+			rxSeq, ok := v.(int64)
+			if !ok {
+				return moved, fmt.Errorf("sbot/retention: not a sequence: %T", v)
+			}
+
+			n, _, err := rxLog.Archive(rxSeq)
+			if err != nil {
+				return moved, err
+			}
+			if n > 0 {
+				moved += int64(n)
+				archived++
+			}
+		}
+
+		if upto > done {
+			if err := s.archive.SetArchived([]byte(addr), upto); err != nil {
+				return moved, err
+			}
+		}
+	}
+
+	if s.eventCounter != nil {
+		s.eventCounter.With("event", "retention-archived").Add(float64(archived))
+	}
+	return moved, nil
+}
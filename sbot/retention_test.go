@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/repo"
+	"github.com/ssbc/go-ssb/repo/archive"
+	"github.com/ssbc/go-ssb/repo/archive/sqlitearchive"
+)
+
+// openTestArchive opens a sqlite archive at path, the bot closes it
+func openTestArchive(t testing.TB, path string) archive.Store {
+	store, err := sqlitearchive.Open(path)
+	require.NoError(t, err)
+	return store
+}
+
+func TestFeedRetentionNeedsArchive(t *testing.T) {
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	_, err := New(
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+		WithFeedRetention(1),
+	)
+	require.Error(t, err)
+}
+
+func TestFeedRetention(t *testing.T) {
+	r := require.New(t)
+
+	hmacKey := randomKey()
+	tn := newTestNetwork(t, WithHMACSigning(hmacKey))
+	botA := tn.newBot("A",
+		WithArchiveStore(openTestArchive(t, filepath.Join("testrun", t.Name(), "archive-A.sqlite"))),
+		WithFeedRetention(2),
+	)
+
+	// A holds the feed of X, but only the newest two messages are kept in the receive log
+	repoA := repo.New(filepath.Join("testrun", t.Name(), "bot-A"))
+	kpX, err := repo.NewKeyPair(repoA, "x", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	var first refs.Message
+	for i := 0; i < 5; i++ {
+		msg, err := botA.PublishAs("x", refs.NewPost("from x"))
+		r.NoError(err)
+		if i == 0 {
+			first = msg
+		}
+	}
+
+	moved, err := botA.ArchiveOldMessages()
+	r.NoError(err)
+	r.True(moved > 0, "nothing was moved")
+
+	// nothing left to do
+	moved, err = botA.ArchiveOldMessages()
+	r.NoError(err)
+	r.EqualValues(0, moved)
+
+	// the archived messages can still be read
+	msg, err := botA.Get(first.Key())
+	r.NoError(err)
+	r.Equal(string(first.ContentBytes()), string(msg.ContentBytes()))
+
+	lastGood, err := botA.VerifyFeed(kpX.ID())
+	r.NoError(err)
+	r.EqualValues(5, lastGood)
+
+	// and are served to peers
+	botB := tn.newBot("B")
+
+	botA.Replicate(botB.KeyPair.ID())
+	botA.Replicate(kpX.ID())
+	botB.Replicate(botA.KeyPair.ID())
+	botB.Replicate(kpX.ID())
+
+	r.NoError(botB.Network.Connect(tn.ctx, botA.Network.GetListenAddr()))
+	r.Eventually(hasFeedLength(botB, kpX.ID(), 5), 10*time.Second, 50*time.Millisecond, "B didn't get X's feed")
+
+	// the space of the archived messages is given back once the compacted log replaces the old one
+	dataPath := filepath.Join("testrun", t.Name(), "bot-A", "log", "data")
+	before, err := os.Stat(dataPath)
+	r.NoError(err)
+	reclaimed, err := botA.Compact()
+	r.NoError(err)
+	r.True(reclaimed > 0, "nothing to reclaim")
+
+	kpA := botA.KeyPair
+	tn.close()
+
+	// the archive is still read without retention
+	botA, err = New(
+		WithKeyPair(kpA),
+		WithHMACSigning(hmacKey),
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(filepath.Join("testrun", t.Name(), "bot-A")),
+		WithArchiveStore(openTestArchive(t, filepath.Join("testrun", t.Name(), "archive-A.sqlite"))),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+	msg, err = botA.Get(first.Key())
+	r.NoError(err)
+	r.Equal(string(first.ContentBytes()), string(msg.ContentBytes()))
+
+	after, err := os.Stat(dataPath)
+	r.NoError(err)
+	r.True(after.Size() < before.Size(), "the log didn't shrink: %d >= %d", after.Size(), before.Size())
+
+	// reverse queries get past the archived messages
+	src, err := botA.ReceiveLog.Query(margaret.Reverse(true))
+	r.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var got int64
+	for {
+		v, err := src.Next(ctx)
+		if luigi.IsEOS(err) {
+			break
+		}
+		r.NoError(err)
+		_, isMsg := v.(refs.Message)
+		r.True(isMsg, "entry %d is not a message: %T", got, v)
+		got++
+	}
+	r.Equal(botA.ReceiveLog.Seq()+1, got)
+
+	_, err = botA.ArchiveOldMessages()
+	r.Error(err, "retention is disabled")
+
+	botA.Shutdown()
+	r.NoError(botA.Close())
+}
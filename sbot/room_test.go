@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	multiserver "github.com/ssbc/go-ssb-multiserver"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/network"
+	"github.com/ssbc/go-ssb/plugins/room"
+)
+
+func TestRoomServer(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	ctx := tn.ctx
+
+	// the room doesn't know ali and bob, they can only use it as guests
+	roomBot := tn.newBot("room", WithRoomServer())
+	ali := tn.newBot("ali")
+	bob := tn.newBot("bob")
+
+	ali.Replicate(roomBot.KeyPair.ID())
+	ali.Replicate(bob.KeyPair.ID())
+	bob.Replicate(roomBot.KeyPair.ID())
+	bob.Replicate(ali.KeyPair.ID())
+
+	const n = 3
+	for i := 0; i < n; i++ {
+		_, err := bob.PublishLog.Publish(refs.NewPost(fmt.Sprintf("bob %d", i)))
+		r.NoError(err)
+	}
+
+	r.NoError(ali.Network.Connect(ctx, roomBot.Network.GetListenAddr()))
+	var roomEdp muxrpc.Endpoint
+	r.Eventually(func() bool {
+		var has bool
+		roomEdp, has = ali.Network.GetEndpointFor(roomBot.KeyPair.ID())
+		return has
+	}, 10*time.Second, 50*time.Millisecond, "ali not connected to the room")
+
+	// guests can only use the room
+	var whoami interface{}
+	err := roomEdp.Async(ctx, &whoami, muxrpc.TypeJSON, muxrpc.Method{"whoami"})
+	r.Error(err, "guest could call whoami")
+
+	var meta room.Metadata
+	r.NoError(roomEdp.Async(ctx, &meta, muxrpc.TypeJSON, muxrpc.Method{"room", "metadata"}))
+	r.Contains(meta.Features, "tunnel")
+
+	src, err := roomEdp.Source(ctx, muxrpc.TypeJSON, muxrpc.Method{"room", "attendants"})
+	r.NoError(err)
+	nextEvent := func() room.AttendantsEvent {
+		r.True(src.Next(ctx), "attendants stream ended: %v", src.Err())
+		body, err := src.Bytes()
+		r.NoError(err)
+		var evt room.AttendantsEvent
+		r.NoError(json.Unmarshal(body, &evt))
+		return evt
+	}
+
+	state := nextEvent()
+	r.Equal("state", state.Type)
+	r.Len(state.IDs, 1)
+	r.True(state.IDs[0].Equal(ali.KeyPair.ID()))
+
+	r.NoError(bob.Network.Connect(ctx, roomBot.Network.GetListenAddr()))
+	joined := nextEvent()
+	r.Equal("joined", joined.Type)
+	r.NotNil(joined.ID)
+	r.True(joined.ID.Equal(bob.KeyPair.ID()))
+
+	tunnel := multiserver.TunnelAddress{Intermediary: roomBot.KeyPair.ID(), Target: bob.KeyPair.ID()}.String()
+	addr, _, err := network.ParseDialAddress(tunnel)
+	r.NoError(err)
+	r.NoError(ali.Network.Connect(ctx, addr))
+
+	r.Eventually(func() bool {
+		note, err := ali.CurrentSequence(bob.KeyPair.ID())
+		return err == nil && note.Seq == n
+	}, 10*time.Second, 50*time.Millisecond, "ali didn't get bob's messages through the room")
+
+	note, err := roomBot.CurrentSequence(bob.KeyPair.ID())
+	r.NoError(err)
+	r.EqualValues(-1, note.Seq, "the room stored messages of bob")
+
+	// bob leaves the room
+	bobEdp, has := roomBot.Network.GetEndpointFor(bob.KeyPair.ID())
+	r.True(has)
+	r.NoError(bobEdp.Terminate())
+	left := nextEvent()
+	r.Equal("left", left.Type)
+	r.NotNil(left.ID)
+	r.True(left.ID.Equal(bob.KeyPair.ID()))
+
+	tn.close()
+}
+
+func TestRoomServerRefuses(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	ctx := tn.ctx
+
+	openAudit := func(name string) (*os.File, string) {
+		path := filepath.Join("testrun", t.Name(), name)
+		r.NoError(os.MkdirAll(filepath.Dir(path), 0700))
+		f, err := os.Create(path)
+		r.NoError(err)
+		t.Cleanup(func() { f.Close() })
+		return f, path
+	}
+	emptyAudit, emptyAuditPath := openAudit("empty-room-audit.log")
+	roomAudit, roomAuditPath := openAudit("room-audit.log")
+
+	// an empty room without trust-on-first-use doesn't take guests
+	emptyRoom := tn.newBot("empty-room", WithRoomServer(), WithDisableTOFU(), WithAuditLog(emptyAudit))
+	roomBot := tn.newBot("room", WithRoomServer(), WithAuditLog(roomAudit))
+	ali := tn.newBot("ali")
+	eve := tn.newBot("eve")
+
+	_, err := roomBot.PublishLog.Publish(refs.NewContactBlock(eve.KeyPair.ID()))
+	r.NoError(err)
+	r.Eventually(func() bool {
+		return roomBot.blocks(eve.KeyPair.ID())
+	}, 10*time.Second, 50*time.Millisecond, "eve not blocked")
+
+	ali.Network.Connect(ctx, emptyRoom.Network.GetListenAddr())
+	r.Eventually(func() bool {
+		return len(readAuditLog(t, emptyAuditPath)) == 1
+	}, 10*time.Second, 50*time.Millisecond, "no audit entry")
+	entry := readAuditLog(t, emptyAuditPath)[0]
+	r.True(entry.Remote.Equal(ali.KeyPair.ID()))
+	r.False(entry.Accepted)
+	r.Equal(auditReasonTOFUDisabled, entry.Reason)
+
+	// blocked peers are no guests
+	eve.Network.Connect(ctx, roomBot.Network.GetListenAddr())
+	r.Eventually(func() bool {
+		return len(readAuditLog(t, roomAuditPath)) == 1
+	}, 10*time.Second, 50*time.Millisecond, "no audit entry")
+	entry = readAuditLog(t, roomAuditPath)[0]
+	r.True(entry.Remote.Equal(eve.KeyPair.ID()))
+	r.False(entry.Accepted)
+	r.Equal(auditReasonBlocked, entry.Reason)
+
+	tn.close()
+}
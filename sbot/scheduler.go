@@ -0,0 +1,310 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"go.mindeco.de/log/level"
+)
+
+// DefaultConnScheduleInterval is how often the connection scheduler is asked which peers to dial, see WithConnScheduleInterval
+const DefaultConnScheduleInterval = 30 * time.Second
+
+// DefaultConnBackoff is how long the default scheduler waits before dialing a peer again after the first failed dial.
+// The wait doubles with each further failure, up to maxConnBackoff.
+const DefaultConnBackoff = time.Minute
+
+const maxConnBackoff = time.Hour
+
+// ConnCandidate is a peer the connection scheduler can decide to dial
+type ConnCandidate struct {
+	Peer refs.FeedRef
+
+	// Addr includes the secret-handshake key and can be passed to Network.Connect
+	Addr net.Addr
+
+	Connected bool
+
+	// Policy is the connection policy of the peer, see WithPeerPolicies.
+	// Peers with ConnPolicyNever are never passed to the scheduler.
+	Policy ConnPolicy
+
+	// Behind is the number of feeds this peer has more messages of than we do, according to the EBT state
+	Behind int
+
+	// Failures is the number of dials to the peer that failed in a row, the last one at LastFailure
+	Failures    int
+	LastFailure time.Time
+}
+
+// ConnDecision is the answer of a ConnScheduler for one peer.
+// Reason is sent with the event for it, to explain why a peer was or wasn't dialed.
+type ConnDecision struct {
+	Peer    refs.FeedRef
+	Addr    net.Addr
+	Connect bool
+	Reason  string
+}
+
+// ConnScheduler decides which peers the bot dials. Schedule is called periodically with all the known peers,
+// the ones passed to WithConnPeers and the ones discovered on the local network.
+// Only the decisions with Connect set are dialed.
+type ConnScheduler interface {
+	Schedule(candidates []ConnCandidate) []ConnDecision
+}
+
+// NewConnScheduler returns the default scheduler. It keeps up to maxConns connections open and fills free slots
+// with the peers that have the most feeds we are behind on. Peers whose dials failed are left alone for backoff,
+// which doubles with every further failure. Peers with ConnPolicyAlways are dialed even if all slots are in use.
+func NewConnScheduler(maxConns int, backoff time.Duration) ConnScheduler {
+	return connScheduler{
+		maxConns: maxConns,
+		backoff:  backoff,
+		now:      time.Now,
+	}
+}
+
+type connScheduler struct {
+	maxConns int
+	backoff  time.Duration
+
+	now func() time.Time
+}
+
+func (cs connScheduler) Schedule(candidates []ConnCandidate) []ConnDecision {
+	var (
+		waiting []ConnCandidate
+		free    = cs.maxConns
+	)
+	for _, c := range candidates {
+		if c.Connected {
+			free--
+			continue
+		}
+		waiting = append(waiting, c)
+	}
+
+	sort.SliceStable(waiting, func(i, j int) bool {
+		if always := waiting[i].Policy == ConnPolicyAlways; always != (waiting[j].Policy == ConnPolicyAlways) {
+			return always
+		}
+		if waiting[i].Behind != waiting[j].Behind {
+			return waiting[i].Behind > waiting[j].Behind
+		}
+		return waiting[i].Failures < waiting[j].Failures
+	})
+
+	decisions := make([]ConnDecision, len(waiting))
+	for i, c := range waiting {
+		d := ConnDecision{Peer: c.Peer, Addr: c.Addr}
+
+		switch wait := cs.backoffFor(c.Failures); {
+		case c.Failures > 0 && cs.now().Sub(c.LastFailure) < wait:
+			d.Reason = fmt.Sprintf("backing off for %s after %d failed dials", wait, c.Failures)
+		case c.Policy == ConnPolicyAlways:
+			d.Connect = true
+			d.Reason = "always connect by peer policy"
+			free--
+		case free <= 0:
+			d.Reason = fmt.Sprintf("all %d connection slots are in use", cs.maxConns)
+		case c.Behind > 0:
+			d.Connect = true
+			d.Reason = fmt.Sprintf("has %d feeds we are behind on", c.Behind)
+			free--
+		default:
+			d.Connect = true
+			d.Reason = "free connection slot"
+			free--
+		}
+
+		decisions[i] = d
+	}
+	return decisions
+}
+
+func (cs connScheduler) backoffFor(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	wait := cs.backoff
+	for i := 1; i < failures && wait < maxConnBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxConnBackoff {
+		wait = maxConnBackoff
+	}
+	return wait
+}
+
+// connLimit derives how many connections the default scheduler keeps from the legacy gossip limits:
+// that many connections can use up the concurrent replications.
+func (s *Sbot) connLimit() int {
+	s.settingsMu.Lock()
+	perPeer, total := s.numberOfConcurrentReplicationsPerPeer, s.numberOfConcurrentReplications
+	s.settingsMu.Unlock()
+
+	if perPeer == 0 || total == 0 {
+		return 3
+	}
+	n := int((total + perPeer - 1) / perPeer)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+type dialFailures struct {
+	count int
+	last  time.Time
+}
+
+type connScheduleJob struct {
+	mu       sync.Mutex
+	failures map[string]dialFailures
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// startConnScheduler starts dialing peers periodically, if a scheduler, peers or peer policies were configured
+func (s *Sbot) startConnScheduler() {
+	if s.connScheduler == nil && len(s.connPeers) == 0 && s.peerPolicies == nil {
+		return
+	}
+
+	job := &connScheduleJob{
+		failures: make(map[string]dialFailures),
+		done:     make(chan struct{}),
+	}
+	s.connSchedule = job
+
+	var ctx context.Context
+	ctx, job.stop = context.WithCancel(s.rootCtx)
+	go func() {
+		defer close(job.done)
+
+		tick := time.NewTicker(s.connScheduleInterval)
+		defer tick.Stop()
+
+		for {
+			s.scheduleConns(ctx, job)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+}
+
+// Close stops the scheduling and waits for the current round to finish
+func (job *connScheduleJob) Close() error {
+	job.stop()
+	<-job.done
+	return nil
+}
+
+func (s *Sbot) scheduleConns(ctx context.Context, job *connScheduleJob) {
+	scheduler := s.connScheduler
+	if scheduler == nil {
+		scheduler = NewConnScheduler(s.connLimit(), DefaultConnBackoff)
+	}
+
+	var candidates []ConnCandidate
+	for _, c := range s.connCandidates(job) {
+		if c.Policy == ConnPolicyNever {
+			s.events.emit(Event{Type: EventConnSkipped, Peer: c.Peer, Reason: "never connect by peer policy"})
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	for _, d := range scheduler.Schedule(candidates) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !d.Connect {
+			s.events.emit(Event{Type: EventConnSkipped, Peer: d.Peer, Reason: d.Reason})
+			continue
+		}
+		s.events.emit(Event{Type: EventConnScheduled, Peer: d.Peer, Reason: d.Reason})
+
+		err := s.Network.Connect(ctx, d.Addr)
+
+		job.mu.Lock()
+		if err != nil {
+			f := job.failures[d.Peer.String()]
+			f.count++
+			f.last = time.Now()
+			job.failures[d.Peer.String()] = f
+		} else {
+			delete(job.failures, d.Peer.String())
+		}
+		job.mu.Unlock()
+
+		if err != nil {
+			level.Debug(s.info).Log("event", "scheduled dial failed", "peer", d.Peer.ShortSigil(), "err", err)
+			s.events.emit(Event{Type: EventConnFailed, Peer: d.Peer, Reason: err.Error()})
+		}
+	}
+}
+
+// connCandidates collects the configured, the always connected and the locally discovered peers
+func (s *Sbot) connCandidates(job *connScheduleJob) []ConnCandidate {
+	var (
+		self       = s.KeyPair.ID()
+		candidates []ConnCandidate
+		seen       = make(map[string]int)
+	)
+	add := func(peer refs.FeedRef, addr net.Addr) {
+		if peer.Equal(self) {
+			return
+		}
+		if _, has := seen[peer.String()]; has {
+			return
+		}
+		seen[peer.String()] = len(candidates)
+		candidates = append(candidates, ConnCandidate{Peer: peer, Addr: addr})
+	}
+
+	for _, p := range s.connPeers {
+		add(p.Peer, p.Addr)
+	}
+	for _, p := range s.alwaysPeers() {
+		add(p.Peer, p.Addr)
+	}
+	for _, p := range s.LocalPeers() {
+		add(p.ID, p.Addr)
+	}
+
+	if longer, err := s.ebtState.HasLonger(); err == nil {
+		for _, hl := range longer {
+			if i, has := seen[hl.Peer.String()]; has {
+				candidates[i].Behind++
+			}
+		}
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	for i, c := range candidates {
+		_, candidates[i].Connected = s.Network.GetEndpointFor(c.Peer)
+		candidates[i].Policy = s.connPolicy(c.Peer)
+
+		f := job.failures[c.Peer.String()]
+		candidates[i].Failures = f.count
+		candidates[i].LastFailure = f.last
+	}
+	return candidates
+}
@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-netwrap"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	"go.mindeco.de/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestConnSchedulerDecisions(t *testing.T) {
+	r := require.New(t)
+
+	peer := func(b byte) refs.FeedRef {
+		ref, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{b}, 32), refs.RefAlgoFeedSSB1)
+		r.NoError(err)
+		return ref
+	}
+
+	now := time.Now()
+	cs := connScheduler{maxConns: 3, backoff: time.Minute, now: func() time.Time { return now }}
+
+	decisions := cs.Schedule([]ConnCandidate{
+		{Peer: peer(1), Connected: true},
+		{Peer: peer(2)},
+		{Peer: peer(3), Behind: 5},
+		{Peer: peer(4), Behind: 9, Failures: 2, LastFailure: now.Add(-90 * time.Second)},
+		{Peer: peer(5), Failures: 1, LastFailure: now.Add(-2 * time.Minute)},
+	})
+	r.Len(decisions, 4, "connected peers are not part of the decisions")
+
+	// most behind first, the one that failed twice is still backing off for two minutes
+	r.True(decisions[0].Peer.Equal(peer(4)))
+	r.False(decisions[0].Connect)
+	r.Contains(decisions[0].Reason, "backing off")
+
+	r.True(decisions[1].Peer.Equal(peer(3)))
+	r.True(decisions[1].Connect)
+	r.Contains(decisions[1].Reason, "5 feeds")
+
+	r.True(decisions[2].Peer.Equal(peer(2)))
+	r.True(decisions[2].Connect)
+
+	// out of slots
+	r.True(decisions[3].Peer.Equal(peer(5)))
+	r.False(decisions[3].Connect)
+	r.Contains(decisions[3].Reason, "slots")
+
+	// peers that are always connected don't wait for a free slot
+	decisions = cs.Schedule([]ConnCandidate{
+		{Peer: peer(1), Connected: true},
+		{Peer: peer(2), Connected: true},
+		{Peer: peer(3), Connected: true},
+		{Peer: peer(4), Behind: 9},
+		{Peer: peer(5), Policy: ConnPolicyAlways},
+	})
+	r.Len(decisions, 2)
+	r.True(decisions[0].Peer.Equal(peer(5)))
+	r.True(decisions[0].Connect)
+	r.Contains(decisions[0].Reason, "peer policy")
+	r.False(decisions[1].Connect)
+
+	r.Equal(time.Minute, cs.backoffFor(1))
+	r.Equal(4*time.Minute, cs.backoffFor(3))
+	r.Equal(maxConnBackoff, cs.backoffFor(100))
+}
+
+func TestConnScheduler(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	botgroup, ctx := errgroup.WithContext(ctx)
+
+	info := testutils.NewRelativeTimeLogger(nil)
+	bs := newBotServer(ctx, info)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bob, err := New(
+		WithContext(ctx),
+		WithInfo(log.With(info, "peer", "bob")),
+		WithRepoPath(filepath.Join(tRepoPath, "bob")),
+		WithListenAddr("127.0.0.1:0"),
+	)
+	r.NoError(err)
+	botgroup.Go(bs.Serve(bob))
+
+	msAddr := func(addr net.Addr, ref refs.FeedRef) string {
+		return fmt.Sprintf("net:%s~shs:%s", netwrap.GetAddr(addr, "tcp"), base64.StdEncoding.EncodeToString(ref.PubKey()))
+	}
+
+	// nothing listens on the port of the closed listener
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	deadAddr := lis.Addr()
+	lis.Close()
+	dead, err := refs.NewFeedRefFromBytes(bytes.Repeat([]byte{7}, 32), refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	ali, err := New(
+		WithContext(ctx),
+		WithInfo(log.With(info, "peer", "ali")),
+		WithRepoPath(filepath.Join(tRepoPath, "ali")),
+		WithListenAddr("127.0.0.1:0"),
+		WithConnPeers(
+			msAddr(bob.Network.GetListenAddr(), bob.KeyPair.ID()),
+			msAddr(deadAddr, dead),
+		),
+		WithConnScheduleInterval(50*time.Millisecond),
+	)
+	r.NoError(err)
+	aliEvents := ali.Events()
+	botgroup.Go(bs.Serve(ali))
+
+	r.Eventually(func() bool {
+		_, has := ali.Network.GetEndpointFor(bob.KeyPair.ID())
+		return has
+	}, 10*time.Second, 50*time.Millisecond, "ali should connect to bob")
+
+	// the unreachable peer is left alone after the failed dial
+	var (
+		timeout      = time.After(10 * time.Second)
+		bobScheduled = 0
+	)
+	for backingOff := false; !backingOff; {
+		select {
+		case evt := <-aliEvents:
+			if evt.Type == EventConnScheduled && evt.Peer.Equal(bob.KeyPair.ID()) {
+				bobScheduled++
+				r.Equal(1, bobScheduled, "connected peer scheduled again")
+			}
+			backingOff = evt.Type == EventConnSkipped && evt.Peer.Equal(dead) && strings.Contains(evt.Reason, "backing off")
+		case <-timeout:
+			r.FailNow("timeout waiting for the backoff")
+		}
+	}
+
+	ali.Shutdown()
+	bob.Shutdown()
+	r.NoError(ali.Close())
+	r.NoError(bob.Close())
+
+	cancel()
+	r.NoError(botgroup.Wait())
+}
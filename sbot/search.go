@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"errors"
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/ssbc/margaret"
+	"github.com/ssbc/margaret/multilog"
+
+	"github.com/ssbc/go-ssb/multilogs"
+)
+
+// ErrSearchDisabled is returned by Search if the bot wasn't started with WithSearchIndex
+var ErrSearchDisabled = errors.New("sbot: search index disabled")
+
+// Search returns the public posts which text contains all the words of query, newest first.
+// A limit of zero or less returns all of them.
+func (s *Sbot) Search(query string, limit int) ([]refs.MessageRef, error) {
+	if s.searchIdx == nil {
+		return nil, ErrSearchDisabled
+	}
+
+	terms := multilogs.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("sbot/search: query has no searchable words")
+	}
+
+	s.WaitUntilIndexesAreSynced()
+
+	result, err := s.searchIdx.LoadInternalBitmap(multilogs.SearchTermAddr(terms[0]))
+	if err != nil {
+		if errors.Is(err, multilog.ErrSublogNotFound) {
+			return []refs.MessageRef{}, nil
+		}
+		return nil, fmt.Errorf("sbot/search: failed to load term %q: %w", terms[0], err)
+	}
+
+	for _, term := range terms[1:] {
+		termBitmap, err := s.searchIdx.LoadInternalBitmap(multilogs.SearchTermAddr(term))
+		if err != nil {
+			if errors.Is(err, multilog.ErrSublogNotFound) {
+				return []refs.MessageRef{}, nil
+			}
+			return nil, fmt.Errorf("sbot/search: failed to load term %q: %w", term, err)
+		}
+		result.And(termBitmap)
+	}
+
+	seqs := result.ToArray()
+	found := make([]refs.MessageRef, 0, len(seqs))
+	for i := len(seqs) - 1; i >= 0; i-- {
+		if limit > 0 && len(found) >= limit {
+			break
+		}
+
+		v, err := s.ReceiveLog.Get(int64(seqs[i]))
+		if err != nil {
+			if margaret.IsErrNulled(err) {
+				continue
+			}
+			return nil, fmt.Errorf("sbot/search: failed to load message %d: %w", seqs[i], err)
+		}
+
+		msg, ok := v.(refs.Message)
+		if !ok {
+			return nil, fmt.Errorf("sbot/search: wrong message type in storage: %T", v)
+		}
+		found = append(found, msg.Key())
+	}
+
+	return found, nil
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+func TestSearch(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	open := func() *Sbot {
+		bot, err := New(
+			WithInfo(testutils.NewRelativeTimeLogger(nil)),
+			WithRepoPath(tRepoPath),
+			DisableNetworkNode(),
+			WithSearchIndex(),
+		)
+		r.NoError(err)
+		return bot
+	}
+
+	bot := open()
+
+	var posts []refs.MessageRef
+	for _, text := range []string{
+		"Hello World!",
+		"hello, scuttlebutt",
+		"the world is round",
+	} {
+		msg, err := bot.PublishLog.Publish(refs.NewPost(text))
+		r.NoError(err)
+		posts = append(posts, msg.Key())
+	}
+
+	// only posts are indexed
+	_, err := bot.PublishLog.Publish(map[string]interface{}{"type": "about", "text": "hello"})
+	r.NoError(err)
+
+	found, err := bot.Search("hello", 0)
+	r.NoError(err)
+	r.Equal([]refs.MessageRef{posts[1], posts[0]}, found, "expected newest first")
+
+	found, err = bot.Search("WORLD hello", 0)
+	r.NoError(err)
+	r.Equal([]refs.MessageRef{posts[0]}, found)
+
+	found, err = bot.Search("world", 1)
+	r.NoError(err)
+	r.Equal([]refs.MessageRef{posts[2]}, found)
+
+	found, err = bot.Search("nothing", 0)
+	r.NoError(err)
+	r.Len(found, 0)
+
+	_, err = bot.Search("!", 0)
+	r.Error(err, "no words in the query")
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// the index survives a restart and continues
+	bot = open()
+
+	msg, err := bot.PublishLog.Publish(refs.NewPost("hello again"))
+	r.NoError(err)
+
+	found, err = bot.Search("hello", 0)
+	r.NoError(err)
+	r.Equal([]refs.MessageRef{msg.Key(), posts[1], posts[0]}, found)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// without the option
+	bot, err = New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+	_, err = bot.Search("hello", 0)
+	r.ErrorIs(err, ErrSearchDisabled)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import "github.com/ssbc/go-ssb/plugins/whoami"
+
+// SetHops changes how many hops of follows are replicated, like WithHops does when the bot is created.
+// Feeds that come into reach are added right away. Lowering it doesn't drop feeds that are already replicated,
+// that only happens after a restart.
+func (s *Sbot) SetHops(h uint) {
+	s.settingsMu.Lock()
+	s.hopCount = h
+	s.settingsMu.Unlock()
+
+	if gr, ok := s.Replicator.(*graphReplicator); ok {
+		go gr.update()
+	}
+}
+
+// SetPromisc changes if every peer can connect and replicate, like WithPromisc does when the bot is created.
+// Open connections are not affected.
+func (s *Sbot) SetPromisc(yes bool) {
+	s.settingsMu.Lock()
+	s.promisc = yes
+	s.settingsMu.Unlock()
+
+	if s.gossipFetcher != nil {
+		s.gossipFetcher.SetPromisc(yes)
+	}
+	if s.gossipServer != nil {
+		s.gossipServer.SetPromisc(yes)
+	}
+}
+
+// SetConcurrentReplications changes the limits of legacy gossip replication,
+// see WithNumberOfConcurrentReplicationsPerPeer and WithNumberOfConcurrentReplications. Zero keeps the current value.
+// The limit per peer applies to new connections.
+func (s *Sbot) SetConcurrentReplications(perPeer, total uint) {
+	s.settingsMu.Lock()
+	if perPeer > 0 {
+		s.numberOfConcurrentReplicationsPerPeer = perPeer
+	}
+	if total > 0 {
+		s.numberOfConcurrentReplications = total
+	}
+	s.settingsMu.Unlock()
+
+	if s.gossipFetcher != nil {
+		s.gossipFetcher.SetConcurrentReplications(int(perPeer), int(total))
+	}
+}
+
+func (s *Sbot) isPromisc() bool {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	return s.promisc
+}
+
+func (s *Sbot) hops() uint {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	return s.hopCount
+}
+
+// whoamiDetails returns the reply for whoamiDetailed
+func (s *Sbot) whoamiDetails() whoami.Details {
+	plugins := []string{"blobs", "gossip"}
+	if !s.disableEBT {
+		plugins = append(plugins, "ebt")
+	}
+
+	return whoami.Details{
+		ID:      s.KeyPair.ID(),
+		Algo:    s.KeyPair.ID().Algo(),
+		Hops:    s.hops(),
+		Plugins: plugins,
+	}
+}
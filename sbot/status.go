@@ -15,6 +15,7 @@ import (
 
 	"github.com/ssbc/go-ssb"
 	multiserver "github.com/ssbc/go-ssb-multiserver"
+	"github.com/ssbc/go-ssb/network"
 )
 
 func (sbot *Sbot) Status() (ssb.Status, error) {
@@ -29,6 +30,15 @@ func (sbot *Sbot) Status() (ssb.Status, error) {
 	sort.Sort(byConnTime(edps))
 
 	for _, es := range edps {
+		if ta, ok := netwrap.GetAddr(es.Addr, "ssb-tunnel").(network.TunnelAddr); ok {
+			tunnel := multiserver.TunnelAddress{Intermediary: ta.Portal, Target: es.ID}
+			s.Peers = append(s.Peers, ssb.PeerStatus{
+				Addr:  tunnel.String(),
+				Since: humanize.Time(time.Now().Add(-es.Since)),
+			})
+			continue
+		}
+
 		var ms multiserver.NetAddress
 		ms.Ref = es.ID
 		if tcpAddr, ok := netwrap.GetAddr(es.Addr, "tcp").(*net.TCPAddr); ok {
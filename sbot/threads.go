@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/indexes"
+)
+
+// ThreadSummary returns the number of public replies to root, who wrote them and when the latest was written.
+// The summary is kept up to date by an index, so this doesn't need to look at the replies.
+func (s *Sbot) ThreadSummary(root refs.MessageRef) (indexes.ThreadSummary, error) {
+	s.WaitUntilIndexesAreSynced()
+	return s.threads.Get(root)
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/private/box"
+	"github.com/ssbc/go-ssb/repo"
+)
+
+func TestThreadSummary(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	kpAlice, err := repo.NewKeyPair(repo.New(tRepoPath), "alice", refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	rootMsg, err := bot.PublishLog.Publish(refs.NewPost("what do you think?"))
+	r.NoError(err)
+	root := rootMsg.Key()
+
+	// nothing yet
+	sum, err := bot.ThreadSummary(root)
+	r.NoError(err)
+	r.Equal(0, sum.Replies)
+	r.Len(sum.Participants, 0)
+	r.True(sum.LatestReply.IsZero())
+
+	reply := func(as, text string) refs.Message {
+		post := refs.NewPost(text)
+		post.Root = &root
+		if as == "" {
+			msg, err := bot.PublishLog.Publish(post)
+			r.NoError(err)
+			return msg
+		}
+		msg, err := bot.PublishAs(as, post)
+		r.NoError(err)
+		return msg
+	}
+
+	reply("alice", "sounds good")
+	reply("", "thanks")
+	last := reply("alice", "you're welcome")
+
+	// not part of the thread
+	_, err = bot.PublishLog.Publish(refs.NewPost("something else"))
+	r.NoError(err)
+	privReply := refs.NewPost("psst")
+	privReply.Root = &root
+	content, err := json.Marshal(privReply)
+	r.NoError(err)
+	boxed, err := box.NewBoxer(nil).Encrypt(content, bot.KeyPair.ID())
+	r.NoError(err)
+	_, err = bot.PublishLog.Publish(boxed)
+	r.NoError(err)
+
+	sum, err = bot.ThreadSummary(root)
+	r.NoError(err)
+	r.Equal(3, sum.Replies)
+	r.Len(sum.Participants, 2)
+	r.True(sum.Participants[0].Equal(kpAlice.ID()))
+	r.True(sum.Participants[1].Equal(bot.KeyPair.ID()))
+	r.True(sum.LatestReply.Equal(last.Claimed()), "latest: %s (wanted %s)", sum.LatestReply, last.Claimed())
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+
+	// the summary survives a restart
+	bot, err = New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	sum, err = bot.ThreadSummary(root)
+	r.NoError(err)
+	r.Equal(3, sum.Replies)
+	r.Len(sum.Participants, 2)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-muxrpc/v2"
+	"github.com/ssbc/go-muxrpc/v2/typemux"
+	multiserver "github.com/ssbc/go-ssb-multiserver"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+	"go.mindeco.de/log"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/network"
+	"github.com/ssbc/go-ssb/plugins2"
+)
+
+// fakeRoom forwards tunnel.connect calls to their target, like a room server does
+type fakeRoom struct {
+	bot *Sbot
+}
+
+func (fakeRoom) Name() string          { return "tunnel" }
+func (fakeRoom) Method() muxrpc.Method { return muxrpc.Method{"tunnel", "connect"} }
+
+func (fr fakeRoom) Handler() muxrpc.Handler {
+	tm := typemux.New(log.NewNopLogger())
+	tm.RegisterDuplex(muxrpc.Method{"tunnel", "connect"}, typemux.DuplexFunc(fr.connect))
+	return &tm
+}
+
+func (fr fakeRoom) connect(ctx context.Context, req *muxrpc.Request, src *muxrpc.ByteSource, snk *muxrpc.ByteSink) error {
+	var args []struct {
+		Portal refs.FeedRef `json:"portal"`
+		Target refs.FeedRef `json:"target"`
+	}
+	if err := json.Unmarshal(req.RawArgs, &args); err != nil || len(args) != 1 {
+		return fmt.Errorf("fake room: bad arguments: %s", req.RawArgs)
+	}
+	origin, err := ssb.GetFeedRefFromAddr(req.Endpoint().Remote())
+	if err != nil {
+		return err
+	}
+
+	edp, has := fr.bot.Network.GetEndpointFor(args[0].Target)
+	if !has {
+		return errors.New("fake room: target offline")
+	}
+	arg := map[string]refs.FeedRef{
+		"portal": args[0].Portal,
+		"target": args[0].Target,
+		"origin": origin,
+	}
+	targetSrc, targetSnk, err := edp.Duplex(ctx, muxrpc.TypeBinary, muxrpc.Method{"tunnel", "connect"}, arg)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		io.Copy(muxrpc.NewSinkWriter(targetSnk), muxrpc.NewSourceReader(src))
+		targetSnk.Close()
+	}()
+	_, err = io.Copy(muxrpc.NewSinkWriter(snk), muxrpc.NewSourceReader(targetSrc))
+	snk.Close()
+	return err
+}
+
+func TestTunnelConnect(t *testing.T) {
+	r := require.New(t)
+
+	tn := newTestNetwork(t)
+	ctx := tn.ctx
+
+	// the room doesn't replicate anything, so the messages can only come through the tunnel
+	fr := &fakeRoom{}
+	room := tn.newBot("room", WithPromisc(true), LateOption(MountPlugin(fr, plugins2.AuthPublic)))
+	fr.bot = room
+
+	ali := tn.newBot("ali")
+	bob := tn.newBot("bob")
+
+	ali.Replicate(room.KeyPair.ID())
+	ali.Replicate(bob.KeyPair.ID())
+	bob.Replicate(room.KeyPair.ID())
+	bob.Replicate(ali.KeyPair.ID())
+
+	const n = 3
+	for i := 0; i < n; i++ {
+		_, err := bob.PublishLog.Publish(refs.NewPost(fmt.Sprintf("bob %d", i)))
+		r.NoError(err)
+	}
+
+	r.NoError(ali.Network.Connect(ctx, room.Network.GetListenAddr()))
+	r.NoError(bob.Network.Connect(ctx, room.Network.GetListenAddr()))
+	r.Eventually(func() bool {
+		_, hasAli := room.Network.GetEndpointFor(ali.KeyPair.ID())
+		_, hasBob := room.Network.GetEndpointFor(bob.KeyPair.ID())
+		return hasAli && hasBob
+	}, 10*time.Second, 50*time.Millisecond, "not connected to the room")
+
+	tunnel := multiserver.TunnelAddress{Intermediary: room.KeyPair.ID(), Target: bob.KeyPair.ID()}.String()
+	addr, peer, err := network.ParseDialAddress(tunnel)
+	r.NoError(err)
+	r.True(peer.Equal(bob.KeyPair.ID()))
+	r.NoError(ali.Network.Connect(ctx, addr))
+
+	r.Eventually(func() bool {
+		_, has := ali.Network.GetEndpointFor(bob.KeyPair.ID())
+		return has
+	}, 10*time.Second, 50*time.Millisecond, "no tunneled connection")
+
+	status, err := ali.Status()
+	r.NoError(err)
+	var found bool
+	for _, p := range status.Peers {
+		if strings.HasPrefix(p.Addr, "tunnel:") {
+			r.Equal(tunnel, p.Addr)
+			found = true
+		}
+	}
+	r.True(found, "the tunneled connection is missing in the status")
+
+	r.Eventually(func() bool {
+		note, err := ali.CurrentSequence(bob.KeyPair.ID())
+		return err == nil && note.Seq == n
+	}, 10*time.Second, 50*time.Millisecond, "ali didn't get bob's messages through the tunnel")
+
+	note, err := room.CurrentSequence(bob.KeyPair.ID())
+	r.NoError(err)
+	r.EqualValues(-1, note.Seq, "the room stored messages of bob")
+
+	tn.close()
+}
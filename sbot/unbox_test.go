@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb"
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+)
+
+// eventCounts is a metrics.Counter that keeps the totals per event label
+type eventCounts struct {
+	mu     *sync.Mutex
+	counts map[string]float64
+
+	event string
+}
+
+func newEventCounts() *eventCounts {
+	return &eventCounts{mu: new(sync.Mutex), counts: make(map[string]float64)}
+}
+
+func (ec *eventCounts) With(labelValues ...string) metrics.Counter {
+	c := &eventCounts{mu: ec.mu, counts: ec.counts, event: ec.event}
+	for i := 0; i+1 < len(labelValues); i += 2 {
+		if labelValues[i] == "event" {
+			c.event = labelValues[i+1]
+		}
+	}
+	return c
+}
+
+func (ec *eventCounts) Add(delta float64) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.counts[ec.event] += delta
+}
+
+func (ec *eventCounts) Get(event string) float64 {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	return ec.counts[event]
+}
+
+func TestUnboxMetrics(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	var (
+		mu     sync.Mutex
+		failed []refs.MessageRef
+	)
+	ctr := newEventCounts()
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		WithEventMetrics(ctr, discard.NewGauge(), discard.NewHistogram()),
+		WithUnboxErrorHandler(func(msg refs.Message, err error) {
+			mu.Lock()
+			failed = append(failed, msg.Key())
+			mu.Unlock()
+		}),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	other, err := ssb.NewKeyPair(nil, refs.RefAlgoFeedSSB1)
+	r.NoError(err)
+
+	// one we can read and one we left ourselves out of
+	readable, err := bot.Groups.EncryptBox1([]byte(`{"type":"test","readable":true}`), bot.KeyPair.ID())
+	r.NoError(err)
+	_, err = bot.PublishLog.Publish(readable)
+	r.NoError(err)
+
+	unreadable, err := bot.Groups.EncryptBox1([]byte(`{"type":"test","readable":false}`), other.ID())
+	r.NoError(err)
+	lost, err := bot.PublishLog.Publish(unreadable)
+	r.NoError(err)
+
+	r.Eventually(func() bool {
+		return ctr.Get("unbox-box1-attempted") == 2
+	}, 5*time.Second, 50*time.Millisecond, "not all messages were tried")
+
+	r.EqualValues(1, ctr.Get("unbox-box1-success"))
+	r.EqualValues(1, ctr.Get("unbox-own-failed"))
+
+	mu.Lock()
+	r.Len(failed, 1)
+	r.True(failed[0].Equal(lost.Key()))
+	mu.Unlock()
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"fmt"
+
+	refs "github.com/ssbc/go-ssb-refs"
+
+	"github.com/ssbc/go-ssb/internal/mutil"
+	"github.com/ssbc/go-ssb/internal/storedrefs"
+)
+
+// VerifyFeed checks the signatures and the previous links of the stored messages of feed.
+// See message.VerifyFeed for the returned values.
+func (s *Sbot) VerifyFeed(feed refs.FeedRef) (int64, error) {
+	s.WaitUntilIndexesAreSynced()
+
+	userLog, err := s.Users.Get(storedrefs.Feed(feed))
+	if err != nil {
+		return 0, fmt.Errorf("sbot/verify: failed to open sublog for %s: %w", feed.ShortSigil(), err)
+	}
+
+	return s.feedFormats.VerifyFeed(mutil.Indirect(s.ReceiveLog, userLog), feed, s.signHMACsecret)
+}
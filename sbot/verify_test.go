@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	refs "github.com/ssbc/go-ssb-refs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssbc/go-ssb/internal/leakcheck"
+	"github.com/ssbc/go-ssb/internal/testutils"
+	"github.com/ssbc/go-ssb/message"
+)
+
+func TestVerifyFeed(t *testing.T) {
+	defer leakcheck.Check(t)
+	r := require.New(t)
+
+	tRepoPath := filepath.Join("testrun", t.Name())
+	os.RemoveAll(tRepoPath)
+
+	bot, err := New(
+		WithInfo(testutils.NewRelativeTimeLogger(nil)),
+		WithRepoPath(tRepoPath),
+		DisableNetworkNode(),
+	)
+	r.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		_, err := bot.PublishLog.Publish(refs.NewPost("hello"))
+		r.NoError(err)
+	}
+
+	lastGood, err := bot.VerifyFeed(bot.KeyPair.ID())
+	r.NoError(err)
+	r.EqualValues(3, lastGood)
+
+	// store the last message a second time, which breaks the sequence of the feed
+	last, err := bot.ReceiveLog.Get(bot.ReceiveLog.Seq())
+	r.NoError(err)
+	_, err = bot.ReceiveLog.Append(last)
+	r.NoError(err)
+
+	lastGood, err = bot.VerifyFeed(bot.KeyPair.ID())
+	r.Error(err)
+	r.EqualValues(3, lastGood)
+
+	broken, ok := message.IsFeedBroken(err)
+	r.True(ok, "%s", err)
+	r.Equal(message.FeedBreakSequence, broken.Reason)
+	r.EqualValues(4, broken.Seq)
+
+	bot.Shutdown()
+	r.NoError(bot.Close())
+}
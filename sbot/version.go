@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2021 The Go-SSB Authors
+//
+// SPDX-License-Identifier: MIT
+
+package sbot
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/ssbc/go-ssb/plugins/server"
+)
+
+const modulePath = "github.com/ssbc/go-ssb"
+
+// WithVersion sets the version and build date that are reported by server.version, see VersionInfo.
+// Programs like go-sbot pass the values they got from their ldflags. Empty values are left out.
+func WithVersion(version, build string) Option {
+	return func(s *Sbot) error {
+		s.version = version
+		s.build = build
+		return nil
+	}
+}
+
+// VersionInfo returns the version of the bot and the optional features it has enabled.
+// Without WithVersion the version of the go-ssb module is reported, as recorded by the Go toolchain.
+func (s *Sbot) VersionInfo() server.VersionInfo {
+	info := server.VersionInfo{
+		Version:   s.version,
+		Build:     s.build,
+		GoVersion: runtime.Version(),
+		Features:  []string{},
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = moduleVersion(bi)
+		}
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "unknown"
+	}
+
+	s.settingsMu.Lock()
+	promisc := s.promisc
+	s.settingsMu.Unlock()
+
+	for _, f := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"ebt", !s.disableEBT},
+		{"metafeeds", s.enableMetafeeds},
+		{"search", s.searchIdx != nil},
+		{"feed-retention", s.retentionKeep > 0},
+		{"blob-gc", s.blobGCInterval > 0},
+		{"websocket", s.websocketAddr != ""},
+		{"promisc", promisc},
+		{"hmac-signing", s.signHMACsecret != nil},
+		{"clock-skew-check", s.skewTolerance > 0},
+	} {
+		if f.enabled {
+			info.Features = append(info.Features, f.name)
+		}
+	}
+	return info
+}
+
+// moduleVersion finds the version of go-ssb, which is either the program itself or one of its dependencies
+func moduleVersion(bi *debug.BuildInfo) string {
+	if bi.Main.Path == modulePath {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+}